@@ -67,5 +67,20 @@ func main() {
 	if err != nil {
 		log.Panic().Err(err).Msg("unable to create the app")
 	}
+
+	reloadSigs := make(chan os.Signal, 1)
+	signal.Notify(reloadSigs, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-mainCtx.Done():
+				return
+			case <-reloadSigs:
+				log.Info().Msg("got SIGHUP, reloading the config")
+				_ = nodeCoreApp.Reload()
+			}
+		}
+	}()
+
 	nodeCoreApp.Start()
 }
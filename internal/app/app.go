@@ -4,10 +4,12 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/drpcorg/nodecore/internal/outbox"
+	"github.com/drpcorg/nodecore/internal/server/admin_server"
 	"github.com/drpcorg/nodecore/internal/server/emerald"
 	"github.com/drpcorg/nodecore/internal/server/health_server"
 	"github.com/drpcorg/nodecore/internal/server/http_server"
@@ -23,7 +25,9 @@ import (
 	"github.com/drpcorg/nodecore/internal/rating"
 	"github.com/drpcorg/nodecore/internal/stats"
 	"github.com/drpcorg/nodecore/internal/storages"
+	"github.com/drpcorg/nodecore/internal/tracing"
 	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/internal/upstreams/flow"
 	"github.com/drpcorg/nodecore/internal/upstreams/flow/subengine"
 	"github.com/drpcorg/nodecore/pkg/pyroscope"
 	"github.com/labstack/echo-contrib/echoprometheus"
@@ -34,16 +38,22 @@ import (
 type App struct {
 	ctx context.Context
 
-	appConfig          *config.AppConfig
-	statsService       stats.StatsService
-	authProcessor      auth.AuthProcessor
-	ratingRegistry     *rating.RatingRegistry
-	cacheProcessor     caches.CacheProcessor
-	outboxStorage      outbox.Storer
-	upstreamSupervisor upstreams.UpstreamSupervisor
+	appConfig               *config.AppConfig
+	appCtx                  *server_ctx.ApplicationServerContext
+	statsService            stats.StatsService
+	authProcessor           auth.AuthProcessor
+	ratingRegistry          *rating.RatingRegistry
+	cacheProcessor          *caches.GenericCacheProcessor
+	outboxStorage           outbox.Storer
+	upstreamSupervisor      upstreams.UpstreamSupervisor
+	rateLimitBudgetRegistry *ratelimiter.RateLimitBudgetRegistry
+	inboundRateLimiter      *ratelimiter.InboundRateLimiter
+
+	reloadMu sync.Mutex
 
 	httpServer   *echo.Echo
 	healthServer *echo.Echo
+	adminServer  *echo.Echo
 	grpcServer   *emerald.GrpcServer
 }
 
@@ -73,7 +83,7 @@ func NewApp(ctx context.Context, appConfig *config.AppConfig) (*App, error) {
 		appConfig.ServerConfig.TorUrl,
 	)
 	ratingRegistry := rating.NewRatingRegistry(upstreamSupervisor, dimensionTracker, appConfig.UpstreamConfig.ScorePolicyConfig)
-	cacheProcessor, err := caches.NewGenericCacheProcessor(upstreamSupervisor, appConfig.CacheConfig, storageRegistry)
+	cacheProcessor, err := caches.NewGenericCacheProcessor(ctx, upstreamSupervisor, appConfig.CacheConfig, storageRegistry)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create the cache processor: %w", err)
 	}
@@ -85,6 +95,11 @@ func NewApp(ctx context.Context, appConfig *config.AppConfig) (*App, error) {
 
 	subEngineRegistry := subengine.NewRegistry(ctx)
 
+	inboundRateLimiter, err := ratelimiter.NewInboundRateLimiter(ctx, appConfig.ServerConfig.IpRateLimit, appConfig.AuthConfig, storageRegistry)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the inbound rate limiter: %w", err)
+	}
+
 	appCtx := server_ctx.NewApplicationServerContext(
 		upstreamSupervisor,
 		cacheProcessor,
@@ -96,6 +111,9 @@ func NewApp(ctx context.Context, appConfig *config.AppConfig) (*App, error) {
 		dimensionTracker,
 		quorumRegistry,
 		subEngineRegistry,
+		flow.NewRequestCoalescer(),
+		flow.NewFilterRegistry(ctx),
+		inboundRateLimiter,
 	)
 
 	grpcServer, err := emerald.NewGrpcServer(appCtx)
@@ -104,23 +122,31 @@ func NewApp(ctx context.Context, appConfig *config.AppConfig) (*App, error) {
 	}
 	httpServer := http_server.NewHttpServer(ctx, appCtx)
 	healthServer := health_server.NewHealthServer(upstreamSupervisor)
+	var adminServer *echo.Echo
+	if appConfig.ServerConfig.AdminConfig.Enabled() {
+		adminServer = admin_server.NewAdminServer(appConfig.ServerConfig.AdminConfig, appCtx)
+	}
 
 	outboxStorage, err := outbox.NewOutboxStorage(appConfig.StatsConfig, storageRegistry)
 	if err != nil {
 		return nil, fmt.Errorf("unable to create the outbox storage: %w", err)
 	}
 	return &App{
-		ctx:                ctx,
-		appConfig:          appConfig,
-		ratingRegistry:     ratingRegistry,
-		cacheProcessor:     cacheProcessor,
-		authProcessor:      authProcessor,
-		statsService:       statsService,
-		upstreamSupervisor: upstreamSupervisor,
-		httpServer:         httpServer,
-		healthServer:       healthServer,
-		grpcServer:         grpcServer,
-		outboxStorage:      outboxStorage,
+		ctx:                     ctx,
+		appConfig:               appConfig,
+		appCtx:                  appCtx,
+		ratingRegistry:          ratingRegistry,
+		cacheProcessor:          cacheProcessor,
+		authProcessor:           authProcessor,
+		statsService:            statsService,
+		upstreamSupervisor:      upstreamSupervisor,
+		rateLimitBudgetRegistry: rateLimitBudgetRegistry,
+		inboundRateLimiter:      inboundRateLimiter,
+		httpServer:              httpServer,
+		healthServer:            healthServer,
+		adminServer:             adminServer,
+		grpcServer:              grpcServer,
+		outboxStorage:           outboxStorage,
 	}, nil
 }
 
@@ -131,6 +157,13 @@ func (a *App) Start() {
 	go a.ratingRegistry.Start()
 	a.statsService.Start(a.outboxStorage)
 
+	if a.appConfig.ServerConfig.ConfigWatchInterval > 0 {
+		log.Info().Msgf("the config file will be checked for changes every %s", a.appConfig.ServerConfig.ConfigWatchInterval)
+		go config.WatchConfigFile(a.ctx, config.ConfigPath(), a.appConfig.ServerConfig.ConfigWatchInterval, func() {
+			_ = a.Reload()
+		})
+	}
+
 	go func() {
 		if a.appConfig.ServerConfig.PprofPort != 0 {
 			pprofServer := http.Server{
@@ -153,6 +186,17 @@ func (a *App) Start() {
 		}
 	}
 
+	shutdownTracing := func(context.Context) error { return nil }
+	if a.appConfig.ServerConfig.TracingConfig.Enabled {
+		shutdown, err := tracing.Init(a.ctx, a.appConfig.ServerConfig.TracingConfig)
+		if err != nil {
+			log.Error().Err(err).Msg("error during tracing initialization")
+		} else {
+			log.Info().Msgf("spans will be exported to %s", a.appConfig.ServerConfig.TracingConfig.Endpoint)
+			shutdownTracing = shutdown
+		}
+	}
+
 	go func() {
 		if a.appConfig.ServerConfig.MetricsPort != 0 {
 			metricsServer := echo.New()
@@ -180,6 +224,19 @@ func (a *App) Start() {
 		}
 	}()
 
+	go func() {
+		if a.adminServer != nil {
+			port := a.appConfig.ServerConfig.AdminConfig.Port
+			if adminServerErr := http_server.StartEcho(a.adminServer, fmt.Sprintf(":%d", port), nil); adminServerErr != nil {
+				if !shuttingDown.Load() {
+					log.Panic().Err(adminServerErr).Msg("admin server couldn't start")
+				}
+			}
+		} else {
+			log.Warn().Msg("admin server is disabled")
+		}
+	}()
+
 	go func() {
 		if httpServerErr := http_server.StartEcho(a.httpServer, fmt.Sprintf(":%d", a.appConfig.ServerConfig.Port), a.appConfig.ServerConfig.TlsConfig); httpServerErr != nil {
 			if !shuttingDown.Load() {
@@ -217,9 +274,22 @@ func (a *App) Start() {
 	} else {
 		log.Info().Msg("health server stopped gracefully")
 	}
+	if a.adminServer != nil {
+		err = a.adminServer.Shutdown(shutDownCtx)
+		if err != nil {
+			log.Error().Err(err).Msg("admin server couldn't stop gracefully")
+		} else {
+			log.Info().Msg("admin server stopped gracefully")
+		}
+	}
 
 	err = a.statsService.Stop(shutDownCtx)
 	if err != nil {
 		log.Error().Err(err).Msg("stats service couldn't stop gracefully")
 	}
+
+	err = shutdownTracing(shutDownCtx)
+	if err != nil {
+		log.Error().Err(err).Msg("couldn't export the remaining spans")
+	}
 }
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/drpcorg/nodecore/internal/auth"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/ratelimiter"
+	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+)
+
+// Reload reads and validates the config file and applies it to the running app:
+// cache policies, rate limit budgets, upstreams and local keys with their rate limits are updated in place,
+// only what has changed is restarted. If the new config is invalid or any part of it can't be built
+// the running one stays as a whole.
+// Sections that are wired once at startup (ports, storages, stats, integrations, the rating
+// function and the auth strategy) are not applied, a restart is required for them.
+func (a *App) Reload() error {
+	a.reloadMu.Lock()
+	defer a.reloadMu.Unlock()
+
+	log.Info().Msg("reloading the config")
+	newConfig, err := config.NewAppConfig()
+	if err != nil {
+		log.Error().Err(err).Msg("the new config is invalid, the current one stays in place")
+		return fmt.Errorf("invalid config: %w", err)
+	}
+	if newConfig.AuthConfig != nil {
+		if err = auth.ValidateKeySelectors(newConfig.AuthConfig.KeyConfigs); err != nil {
+			log.Error().Err(err).Msg("the new config is invalid, the current one stays in place")
+			return fmt.Errorf("invalid config: %w", err)
+		}
+	}
+
+	// the sections that require a restart are compared with the startup config, they are never applied
+	warnRestartRequired(a.appConfig, newConfig)
+
+	// everything that can fail is built first, nothing is applied until all of it succeeds
+	cacheUpdate, err := a.cacheProcessor.PrepareUpdate(newConfig.CacheConfig)
+	if err != nil {
+		log.Error().Err(err).Msg("couldn't apply the new cache config, the current config stays in place")
+		return fmt.Errorf("unable to update the cache processor: %w", err)
+	}
+	budgetsUpdate, err := a.rateLimitBudgetRegistry.PrepareUpdate(newConfig.RateLimit)
+	if err != nil {
+		cacheUpdate.Discard()
+		log.Error().Err(err).Msg("couldn't apply the new rate limit budgets, the current config stays in place")
+		return fmt.Errorf("unable to update the rate limit budgets: %w", err)
+	}
+	keysReloader, reloadKeys := a.authProcessor.(auth.LocalKeysReloader)
+	reloadKeys = reloadKeys && newConfig.AuthConfig != nil
+	var keysUpdate *ratelimiter.InboundKeysUpdate
+	if reloadKeys {
+		keysUpdate, err = a.inboundRateLimiter.PrepareKeysUpdate(newConfig.AuthConfig.KeyConfigs)
+		if err != nil {
+			cacheUpdate.Discard()
+			log.Error().Err(err).Msg("couldn't apply the new rate limits of keys, the current config stays in place")
+			return fmt.Errorf("unable to update the rate limits of keys: %w", err)
+		}
+	}
+
+	cacheUpdate.Apply()
+	changedBudgets := budgetsUpdate.Apply()
+	a.upstreamSupervisor.UpdateUpstreams(newConfig.UpstreamConfig, changedBudgets)
+	if reloadKeys {
+		keysReloader.ReloadLocalKeys(newConfig.AuthConfig.KeyConfigs)
+		keysUpdate.Apply()
+	}
+
+	// a.appConfig stays the startup config, the servers keep running with it
+	a.appCtx.UpdateAppConfig(newConfig)
+	log.Info().Msg("the config has been reloaded")
+
+	return nil
+}
+
+func warnRestartRequired(currentConfig, newConfig *config.AppConfig) {
+	restartRequired := make([]string, 0)
+	if !reflect.DeepEqual(currentConfig.ServerConfig, newConfig.ServerConfig) {
+		restartRequired = append(restartRequired, "server")
+	}
+	if !reflect.DeepEqual(currentConfig.AppStorages, newConfig.AppStorages) {
+		restartRequired = append(restartRequired, "app-storages")
+	}
+	if !reflect.DeepEqual(currentConfig.StatsConfig, newConfig.StatsConfig) {
+		restartRequired = append(restartRequired, "stats")
+	}
+	if !reflect.DeepEqual(currentConfig.IntegrationConfig, newConfig.IntegrationConfig) {
+		restartRequired = append(restartRequired, "integration")
+	}
+	if !sameAuthStrategy(currentConfig.AuthConfig, newConfig.AuthConfig) {
+		restartRequired = append(restartRequired, "auth")
+	}
+	if !sameScorePolicy(currentConfig.UpstreamConfig.ScorePolicyConfig, newConfig.UpstreamConfig.ScorePolicyConfig) {
+		restartRequired = append(restartRequired, "upstream-config.score-policy-config")
+	}
+	if len(restartRequired) > 0 {
+		log.Warn().Msgf("changes of %v can't be applied without a restart, they will be ignored", restartRequired)
+	}
+}
+
+// sameAuthStrategy compares everything but the keys, local keys are reloaded on the fly
+func sameAuthStrategy(currentConfig, newConfig *config.AuthConfig) bool {
+	if currentConfig == nil || newConfig == nil {
+		return currentConfig == newConfig
+	}
+	return currentConfig.Enabled == newConfig.Enabled &&
+		reflect.DeepEqual(currentConfig.RequestStrategyConfig, newConfig.RequestStrategyConfig)
+}
+
+// sameScorePolicy compares only the configured fields, the compiled function is never equal
+func sameScorePolicy(currentConfig, newConfig *config.ScorePolicyConfig) bool {
+	if currentConfig == nil || newConfig == nil {
+		return currentConfig == newConfig
+	}
+	return currentConfig.CalculationInterval == newConfig.CalculationInterval &&
+		currentConfig.CalculationFunctionName == newConfig.CalculationFunctionName &&
+		currentConfig.CalculationFunctionFilePath == newConfig.CalculationFunctionFilePath &&
+		lo.FromPtr(currentConfig.CostTieTolerance) == lo.FromPtr(newConfig.CostTieTolerance)
+}
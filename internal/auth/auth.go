@@ -3,6 +3,7 @@ package auth
 import (
 	"context"
 	"errors"
+	"fmt"
 	"net/http"
 
 	"github.com/drpcorg/nodecore/internal/config"
@@ -30,6 +31,9 @@ func NewAuthProcessor(ctx context.Context, authCfg *config.AuthConfig, integrati
 	if len(authCfg.KeyConfigs) == 0 {
 		authProcessor = newSimpleAuthProcessor(authRequestStrategy)
 	} else {
+		if err = ValidateKeySelectors(authCfg.KeyConfigs); err != nil {
+			return nil, err
+		}
 		keyService, err := keymanagement.NewGenericKeyService(ctx, authCfg.KeyConfigs, integrationResolver)
 		if err != nil {
 			return nil, err
@@ -47,6 +51,38 @@ type AuthProcessor interface {
 	GetKeyValue(payload AuthPayload) string
 }
 
+// LocalKeysReloader is implemented by auth processors backed by a key service,
+// it refreshes local keys on a config reload
+type LocalKeysReloader interface {
+	ReloadLocalKeys(keyCfgs []*config.KeyConfig)
+}
+
+// KeySelectorResolver is implemented by auth processors backed by a key service,
+// it returns the default routing selector of the request's key
+type KeySelectorResolver interface {
+	GetKeySelector(payload AuthPayload) protocol.RequestSelector
+}
+
+// KeyCacheControlResolver is implemented by auth processors backed by a key service,
+// it tells if the request's key lets its clients control the cache
+type KeyCacheControlResolver interface {
+	CacheControlAllowed(payload AuthPayload) bool
+}
+
+// ValidateKeySelectors checks that the selectors of local keys can be parsed
+func ValidateKeySelectors(keyCfgs []*config.KeyConfig) error {
+	for _, keyCfg := range keyCfgs {
+		localKeyCfg, ok := keyCfg.GetSpecificKeyConfig().(*config.LocalKeyConfig)
+		if !ok || localKeyCfg.KeySettingsConfig == nil {
+			continue
+		}
+		if _, err := protocol.ParseRequestSelector(localKeyCfg.KeySettingsConfig.Selector); err != nil {
+			return fmt.Errorf("invalid selector of the key '%s': %w", keyCfg.Id, err)
+		}
+	}
+	return nil
+}
+
 type AuthPayload interface {
 	payload()
 }
@@ -96,6 +132,26 @@ func (b *basicAuthProcessor) PostKeyValidate(ctx context.Context, payload AuthPa
 	return key.PostCheckSetting(ctx, request)
 }
 
+func (b *basicAuthProcessor) GetKeySelector(payload AuthPayload) protocol.RequestSelector {
+	key, err := b.getKey(payload)
+	if err != nil {
+		return nil
+	}
+	return key.Selector()
+}
+
+func (b *basicAuthProcessor) CacheControlAllowed(payload AuthPayload) bool {
+	key, err := b.getKey(payload)
+	if err != nil {
+		return false
+	}
+	return key.CacheControlAllowed()
+}
+
+func (b *basicAuthProcessor) ReloadLocalKeys(keyCfgs []*config.KeyConfig) {
+	b.keyService.ReloadLocalKeys(keyCfgs)
+}
+
 func (b *basicAuthProcessor) getKey(payload AuthPayload) (keydata.Key, error) {
 	keyStr := getPayloadKey(payload)
 	if keyStr == "" {
@@ -129,3 +185,5 @@ func newBasicAuthProcessor(keyService keymanagement.KeyService, requestStrategy
 }
 
 var _ AuthProcessor = (*basicAuthProcessor)(nil)
+var _ LocalKeysReloader = (*basicAuthProcessor)(nil)
+var _ KeySelectorResolver = (*basicAuthProcessor)(nil)
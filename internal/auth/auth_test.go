@@ -9,7 +9,9 @@ import (
 	"github.com/drpcorg/nodecore/internal/auth"
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/integration"
+	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/samber/lo"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -280,3 +282,93 @@ func TestBasicAuthProcessor_PostKeyValidate_KeyNotFound_Error(t *testing.T) {
 		})
 	}
 }
+
+func TestBasicAuthProcessor_GetKeySelector(t *testing.T) {
+	appCfg := &config.AuthConfig{
+		Enabled: true,
+		RequestStrategyConfig: &config.RequestStrategyConfig{
+			Type:                       config.Token,
+			TokenRequestStrategyConfig: &config.TokenRequestStrategyConfig{Value: "tok"},
+		},
+		KeyConfigs: []*config.KeyConfig{
+			{
+				Id:   "k1",
+				Type: config.Local,
+				LocalKeyConfig: &config.LocalKeyConfig{
+					Key:               "secret-key",
+					KeySettingsConfig: &config.KeySettingsConfig{Selector: "archive=true"},
+				},
+			},
+		},
+	}
+	p, err := auth.NewAuthProcessor(context.Background(), appCfg, integration.NewIntegrationResolver(nil))
+	assert.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	resolver, ok := p.(auth.KeySelectorResolver)
+	assert.True(t, ok)
+	assert.Equal(
+		t,
+		protocol.RequestLabelSelector{Name: "archive", Values: []string{"true"}},
+		resolver.GetKeySelector(newPayload(t, map[string]string{auth.XNodecoreKey: "secret-key"})),
+	)
+	assert.Nil(t, resolver.GetKeySelector(newPayload(t, map[string]string{auth.XNodecoreKey: "unknown"})))
+}
+
+func TestBasicAuthProcessor_CacheControlAllowed(t *testing.T) {
+	appCfg := &config.AuthConfig{
+		Enabled: true,
+		RequestStrategyConfig: &config.RequestStrategyConfig{
+			Type:                       config.Token,
+			TokenRequestStrategyConfig: &config.TokenRequestStrategyConfig{Value: "tok"},
+		},
+		KeyConfigs: []*config.KeyConfig{
+			{
+				Id:             "k1",
+				Type:           config.Local,
+				LocalKeyConfig: &config.LocalKeyConfig{Key: "default-key"},
+			},
+			{
+				Id:   "k2",
+				Type: config.Local,
+				LocalKeyConfig: &config.LocalKeyConfig{
+					Key:               "denied-key",
+					KeySettingsConfig: &config.KeySettingsConfig{AllowCacheControl: lo.ToPtr(false)},
+				},
+			},
+		},
+	}
+	p, err := auth.NewAuthProcessor(context.Background(), appCfg, integration.NewIntegrationResolver(nil))
+	assert.NoError(t, err)
+	time.Sleep(50 * time.Millisecond)
+
+	resolver, ok := p.(auth.KeyCacheControlResolver)
+	assert.True(t, ok)
+	assert.True(t, resolver.CacheControlAllowed(newPayload(t, map[string]string{auth.XNodecoreKey: "default-key"})))
+	assert.False(t, resolver.CacheControlAllowed(newPayload(t, map[string]string{auth.XNodecoreKey: "denied-key"})))
+	assert.False(t, resolver.CacheControlAllowed(newPayload(t, map[string]string{auth.XNodecoreKey: "unknown"})))
+}
+
+func TestNewAuthProcessor_InvalidKeySelector_Error(t *testing.T) {
+	appCfg := &config.AuthConfig{
+		Enabled: true,
+		RequestStrategyConfig: &config.RequestStrategyConfig{
+			Type:                       config.Token,
+			TokenRequestStrategyConfig: &config.TokenRequestStrategyConfig{Value: "tok"},
+		},
+		KeyConfigs: []*config.KeyConfig{
+			{
+				Id:   "k1",
+				Type: config.Local,
+				LocalKeyConfig: &config.LocalKeyConfig{
+					Key:               "secret-key",
+					KeySettingsConfig: &config.KeySettingsConfig{Selector: "archive in ()"},
+				},
+			},
+		},
+	}
+
+	_, err := auth.NewAuthProcessor(context.Background(), appCfg, integration.NewIntegrationResolver(nil))
+
+	assert.EqualError(t, err, "invalid selector of the key 'k1': 'archive in' requires at least one value")
+}
@@ -0,0 +1,220 @@
+package breaker
+
+import (
+	"sync"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/utils"
+	"github.com/failsafe-go/failsafe-go/circuitbreaker"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+var circuitBreakerStateMetric = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: config.AppName,
+		Subsystem: "upstream",
+		Name:      "circuit_breaker_state",
+		Help:      "The state of a circuit breaker of an upstream: 0 - closed, 1 - half-open, 2 - open",
+	},
+	[]string{"chain", "upstream", "method"},
+)
+
+var circuitBreakerTransitionsMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "upstream",
+		Name:      "circuit_breaker_transitions_total",
+		Help:      "The total number of state transitions of a circuit breaker of an upstream",
+	},
+	[]string{"chain", "upstream", "method", "state"},
+)
+
+func init() {
+	prometheus.MustRegister(circuitBreakerStateMetric, circuitBreakerTransitionsMetric)
+}
+
+type State int32
+
+const (
+	Closed State = iota
+	HalfOpen
+	Open
+)
+
+func (s State) String() string {
+	switch s {
+	case Closed:
+		return "closed"
+	case HalfOpen:
+		return "half-open"
+	case Open:
+		return "open"
+	default:
+		return "unknown"
+	}
+}
+
+// AnyMethod is the method of a circuit that covers all requests of an upstream
+const AnyMethod = "*"
+
+// StateListener is notified about every transition of a circuit. The notifications of one circuit are serialized
+// and each of them carries the state of the circuit at the moment, so the last one is always the current state
+type StateListener func(method string, state State)
+
+// CircuitBreaker ejects a failing upstream from the upstream selection.
+// A circuit opens after a number of consecutive failures or, if the failure rate threshold is set, once the failure rate
+// within the window is too high. It stays open for the open duration, and then lets a limited number of probe requests
+// through in the half-open state until either enough of them succeed to close it or one of them fails to open it again.
+// With per-method circuits each method of an upstream is ejected on its own
+type CircuitBreaker struct {
+	chain      chains.Chain
+	upstreamId string
+	config     *config.CircuitBreakerConfig
+	circuits   *utils.CMap[string, *circuit]
+	listener   StateListener
+}
+
+func NewCircuitBreaker(chain chains.Chain, upstreamId string, cfg *config.CircuitBreakerConfig, listener StateListener) *CircuitBreaker {
+	return &CircuitBreaker{
+		chain:      chain,
+		upstreamId: upstreamId,
+		config:     cfg,
+		circuits:   utils.NewCMap[string, *circuit](),
+		listener:   listener,
+	}
+}
+
+// Allow reports if a request of the method may be sent to the upstream, only a circuit that is open
+// and still has to wait before probing the upstream rejects requests. It takes nothing, see TryAcquire
+func (c *CircuitBreaker) Allow(method string) bool {
+	circuit, ok := c.circuits.Load(c.circuitMethod(method))
+	if !ok {
+		return true
+	}
+	return !circuit.breaker.IsOpen() || circuit.breaker.RemainingDelay() == 0
+}
+
+// TryAcquire takes a permit to send a request of the method to the upstream.
+// A closed circuit always gives one, a half-open circuit gives as many as the number of half-open successes,
+// and a permit is given back once the result of the request is recorded
+func (c *CircuitBreaker) TryAcquire(method string) bool {
+	circuit, ok := c.circuits.Load(c.circuitMethod(method))
+	if !ok {
+		return true
+	}
+	return circuit.breaker.TryAcquirePermit()
+}
+
+func (c *CircuitBreaker) State(method string) State {
+	circuit, ok := c.circuits.Load(c.circuitMethod(method))
+	if !ok {
+		return Closed
+	}
+	return fromFailsafeState(circuit.breaker.State())
+}
+
+// FailureRate is the failure rate of the circuit of the method, it tells how bad an upstream is
+// when all circuits are open and one of them has to be picked anyway
+func (c *CircuitBreaker) FailureRate(method string) float64 {
+	circuit, ok := c.circuits.Load(c.circuitMethod(method))
+	if !ok {
+		return 0
+	}
+	return circuit.breaker.Metrics().FailureRate()
+}
+
+// Record registers the result of a request of the method sent to the upstream
+func (c *CircuitBreaker) Record(method string, failed bool) {
+	circuit := c.loadCircuit(method)
+	if failed {
+		circuit.breaker.RecordFailure()
+	} else {
+		circuit.breaker.RecordSuccess()
+	}
+}
+
+// RecordCancelled registers a request of the method that was cancelled before the upstream answered.
+// It says nothing about the upstream, so it's ignored unless it's a half-open probe,
+// which has to give its permit back and is counted as failed since it hasn't proven the upstream is back
+func (c *CircuitBreaker) RecordCancelled(method string) {
+	circuit := c.loadCircuit(method)
+	if circuit.breaker.IsHalfOpen() {
+		circuit.breaker.RecordFailure()
+	}
+}
+
+func (c *CircuitBreaker) loadCircuit(method string) *circuit {
+	circuitMethod := c.circuitMethod(method)
+	circuit, _ := c.circuits.LoadOrStoreLazy(circuitMethod, func() *circuit {
+		return c.newCircuit(circuitMethod)
+	})
+	return circuit
+}
+
+func (c *CircuitBreaker) circuitMethod(method string) string {
+	if c.config.PerMethod {
+		return method
+	}
+	return AnyMethod
+}
+
+func (c *CircuitBreaker) newCircuit(method string) *circuit {
+	newCircuit := &circuit{method: method, circuitBreaker: c}
+
+	builder := circuitbreaker.NewBuilder[any]().
+		WithDelay(c.config.OpenDuration).
+		WithSuccessThreshold(uint(c.config.HalfOpenSuccesses)).
+		OnStateChanged(func(event circuitbreaker.StateChangedEvent) {
+			newCircuit.stateChanged(fromFailsafeState(event.NewState))
+		})
+	if c.config.FailureRateThreshold > 0 {
+		builder.WithFailureRateThreshold(c.config.FailureRateThreshold, uint(c.config.MinimumRequests), c.config.Window)
+	} else {
+		builder.WithFailureThreshold(uint(c.config.FailureThreshold))
+	}
+	newCircuit.breaker = builder.Build()
+
+	return newCircuit
+}
+
+type circuit struct {
+	method         string
+	circuitBreaker *CircuitBreaker
+	breaker        circuitbreaker.CircuitBreaker[any]
+
+	// publishMu serializes the notifications about transitions, failsafe calls the listeners outside its lock
+	publishMu sync.Mutex
+}
+
+func (c *circuit) stateChanged(newState State) {
+	b := c.circuitBreaker
+	circuitBreakerTransitionsMetric.WithLabelValues(b.chain.String(), b.upstreamId, c.method, newState.String()).Inc()
+	if newState == Open {
+		log.Warn().Msgf("the circuit breaker of upstream '%s' is open for method %s", b.upstreamId, c.method)
+	} else {
+		log.Info().Msgf("the circuit breaker of upstream '%s' is %s for method %s", b.upstreamId, newState, c.method)
+	}
+
+	c.publishMu.Lock()
+	defer c.publishMu.Unlock()
+
+	// a concurrent transition may have happened already, the current state is the one to publish
+	state := fromFailsafeState(c.breaker.State())
+	circuitBreakerStateMetric.WithLabelValues(b.chain.String(), b.upstreamId, c.method).Set(float64(state))
+	if b.listener != nil {
+		b.listener(c.method, state)
+	}
+}
+
+func fromFailsafeState(state circuitbreaker.State) State {
+	switch state {
+	case circuitbreaker.OpenState:
+		return Open
+	case circuitbreaker.HalfOpenState:
+		return HalfOpen
+	default:
+		return Closed
+	}
+}
@@ -0,0 +1,182 @@
+package breaker_test
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/breaker"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/stretchr/testify/assert"
+)
+
+type stateRecorder struct {
+	mu     sync.Mutex
+	states []breaker.State
+}
+
+func (s *stateRecorder) listener(_ string, state breaker.State) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.states = append(s.states, state)
+}
+
+func (s *stateRecorder) get() []breaker.State {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]breaker.State(nil), s.states...)
+}
+
+func circuitBreakerConfig() *config.CircuitBreakerConfig {
+	return &config.CircuitBreakerConfig{
+		FailureThreshold:  3,
+		MinimumRequests:   10,
+		Window:            time.Minute,
+		OpenDuration:      50 * time.Millisecond,
+		HalfOpenSuccesses: 2,
+	}
+}
+
+func openCircuit(circuitBreaker *breaker.CircuitBreaker, method string) {
+	for i := 0; i < 3; i++ {
+		circuitBreaker.Record(method, true)
+	}
+}
+
+func TestCircuitBreakerOpensOnConsecutiveFailures(t *testing.T) {
+	recorder := &stateRecorder{}
+	circuitBreaker := breaker.NewCircuitBreaker(chains.ETHEREUM, "id", circuitBreakerConfig(), recorder.listener)
+
+	circuitBreaker.Record("eth_call", true)
+	circuitBreaker.Record("eth_call", true)
+	circuitBreaker.Record("eth_call", false)
+	circuitBreaker.Record("eth_call", true)
+	circuitBreaker.Record("eth_call", true)
+	assert.True(t, circuitBreaker.Allow("eth_call"))
+
+	circuitBreaker.Record("eth_getBalance", true)
+
+	assert.False(t, circuitBreaker.Allow("eth_call"))
+	assert.False(t, circuitBreaker.Allow("eth_getBalance"))
+	assert.False(t, circuitBreaker.TryAcquire("eth_call"))
+	assert.Equal(t, breaker.Open, circuitBreaker.State("eth_call"))
+	assert.Equal(t, []breaker.State{breaker.Open}, recorder.get())
+}
+
+func TestCircuitBreakerOpensOnFailureRate(t *testing.T) {
+	cfg := circuitBreakerConfig()
+	cfg.FailureRateThreshold = 0.5
+	circuitBreaker := breaker.NewCircuitBreaker(chains.ETHEREUM, "id", cfg, nil)
+
+	for i := 0; i < 9; i++ {
+		circuitBreaker.Record("eth_call", i%2 == 0)
+	}
+	assert.True(t, circuitBreaker.Allow("eth_call"))
+
+	circuitBreaker.Record("eth_call", false)
+
+	assert.False(t, circuitBreaker.Allow("eth_call"))
+	assert.Equal(t, 0.5, circuitBreaker.FailureRate("eth_call"))
+}
+
+func TestCircuitBreakerHalfOpenThenClosed(t *testing.T) {
+	recorder := &stateRecorder{}
+	circuitBreaker := breaker.NewCircuitBreaker(chains.ETHEREUM, "id", circuitBreakerConfig(), recorder.listener)
+
+	openCircuit(circuitBreaker, "eth_call")
+	assert.Eventually(t, func() bool {
+		return circuitBreaker.Allow("eth_call")
+	}, time.Second, 5*time.Millisecond)
+
+	assert.True(t, circuitBreaker.TryAcquire("eth_call"))
+	assert.Equal(t, breaker.HalfOpen, circuitBreaker.State("eth_call"))
+	circuitBreaker.Record("eth_call", false)
+	assert.Equal(t, breaker.HalfOpen, circuitBreaker.State("eth_call"))
+	assert.True(t, circuitBreaker.TryAcquire("eth_call"))
+	circuitBreaker.Record("eth_call", false)
+
+	assert.Equal(t, breaker.Closed, circuitBreaker.State("eth_call"))
+	assert.Equal(t, []breaker.State{breaker.Open, breaker.HalfOpen, breaker.Closed}, recorder.get())
+}
+
+func TestCircuitBreakerHalfOpenLimitsProbes(t *testing.T) {
+	circuitBreaker := breaker.NewCircuitBreaker(chains.ETHEREUM, "id", circuitBreakerConfig(), nil)
+
+	openCircuit(circuitBreaker, "eth_call")
+	assert.Eventually(t, func() bool {
+		return circuitBreaker.Allow("eth_call")
+	}, time.Second, 5*time.Millisecond)
+
+	assert.True(t, circuitBreaker.TryAcquire("eth_call"))
+	assert.True(t, circuitBreaker.TryAcquire("eth_call"))
+	assert.False(t, circuitBreaker.TryAcquire("eth_call"))
+	assert.True(t, circuitBreaker.Allow("eth_call"), "a check takes no permit")
+
+	circuitBreaker.Record("eth_call", false)
+
+	assert.True(t, circuitBreaker.TryAcquire("eth_call"))
+	assert.Equal(t, breaker.HalfOpen, circuitBreaker.State("eth_call"))
+}
+
+func TestCircuitBreakerHalfOpenFailureThenOpenAgain(t *testing.T) {
+	recorder := &stateRecorder{}
+	circuitBreaker := breaker.NewCircuitBreaker(chains.ETHEREUM, "id", circuitBreakerConfig(), recorder.listener)
+
+	openCircuit(circuitBreaker, "eth_call")
+	assert.Eventually(t, func() bool {
+		return circuitBreaker.TryAcquire("eth_call")
+	}, time.Second, 5*time.Millisecond)
+
+	circuitBreaker.Record("eth_call", true)
+
+	assert.False(t, circuitBreaker.Allow("eth_call"))
+	assert.Equal(t, []breaker.State{breaker.Open, breaker.HalfOpen, breaker.Open}, recorder.get())
+}
+
+func TestCircuitBreakerCancelledProbeThenOpenAgain(t *testing.T) {
+	circuitBreaker := breaker.NewCircuitBreaker(chains.ETHEREUM, "id", circuitBreakerConfig(), nil)
+
+	circuitBreaker.Record("eth_call", true)
+	circuitBreaker.Record("eth_call", true)
+	circuitBreaker.RecordCancelled("eth_call")
+	assert.Equal(t, breaker.Closed, circuitBreaker.State("eth_call"))
+
+	circuitBreaker.Record("eth_call", true)
+	assert.Eventually(t, func() bool {
+		return circuitBreaker.TryAcquire("eth_call")
+	}, time.Second, 5*time.Millisecond)
+
+	circuitBreaker.RecordCancelled("eth_call")
+
+	assert.Equal(t, breaker.Open, circuitBreaker.State("eth_call"))
+}
+
+func TestCircuitBreakerOpenIgnoresResults(t *testing.T) {
+	cfg := circuitBreakerConfig()
+	cfg.OpenDuration = time.Minute
+	circuitBreaker := breaker.NewCircuitBreaker(chains.ETHEREUM, "id", cfg, nil)
+
+	openCircuit(circuitBreaker, "eth_call")
+	for i := 0; i < 10; i++ {
+		circuitBreaker.Record("eth_call", false)
+	}
+
+	assert.Equal(t, breaker.Open, circuitBreaker.State("eth_call"))
+	assert.False(t, circuitBreaker.TryAcquire("eth_call"))
+}
+
+func TestCircuitBreakerPerMethod(t *testing.T) {
+	cfg := circuitBreakerConfig()
+	cfg.PerMethod = true
+	circuitBreaker := breaker.NewCircuitBreaker(chains.ETHEREUM, "id", cfg, nil)
+
+	for i := 0; i < 3; i++ {
+		circuitBreaker.Record("eth_call", true)
+		circuitBreaker.Record("eth_getBalance", false)
+	}
+
+	assert.False(t, circuitBreaker.Allow("eth_call"))
+	assert.True(t, circuitBreaker.Allow("eth_getBalance"))
+	assert.True(t, circuitBreaker.Allow("eth_blockNumber"))
+}
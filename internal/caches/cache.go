@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
 	"github.com/hashicorp/golang-lru/v2"
 	"github.com/rs/zerolog/log"
 	"github.com/samber/lo"
@@ -17,19 +20,42 @@ var ErrCacheNotFound = errors.New("not found in cache")
 type CacheConnector interface {
 	Id() string
 	Store(ctx context.Context, key string, object string, ttl time.Duration) error
+	// StoreAtHeight stores an object that depends on a not finalized block of the chain,
+	// the height is indexed so the object can be removed by RemoveHeights after a reorg
+	StoreAtHeight(ctx context.Context, key string, object string, ttl time.Duration, chain chains.Chain, height uint64) error
 	Receive(ctx context.Context, key string) ([]byte, error)
+	// RemoveHeights removes all objects stored by StoreAtHeight within [fromHeight, toHeight] of the chain
+	RemoveHeights(ctx context.Context, chain chains.Chain, fromHeight, toHeight uint64) error
+	// Scan calls fn with the key and the stored size of every object whose key starts with prefix,
+	// it stops at the first error returned by fn
+	Scan(ctx context.Context, prefix string, fn func(key string, size int) error) error
+	// Remove removes the objects of the keys, unknown keys are skipped
+	Remove(ctx context.Context, keys ...string) error
 	Initialize() error
+	Close()
 }
 
 type cacheItem struct {
 	object   string
 	expireAt *time.Time
+	block    *indexedBlock
+}
+
+type indexedBlock struct {
+	chain  chains.Chain
+	height uint64
 }
 
 type InMemoryConnector struct {
 	id                    string
 	cache                 *lru.Cache[string, cacheItem]
 	expiredRemoveInterval time.Duration
+	done                  chan struct{}
+
+	// heights indexes the keys of items stored at a height, an item is removed from the index
+	// once it's evicted from the cache for any reason
+	heightsMu sync.Mutex
+	heights   map[indexedBlock]map[string]struct{}
 }
 
 func (i *InMemoryConnector) Initialize() error {
@@ -38,18 +64,20 @@ func (i *InMemoryConnector) Initialize() error {
 }
 
 func NewInMemoryConnector(id string, config *config.MemoryCacheConnectorConfig) (*InMemoryConnector, error) {
-	cache, err := lru.New[string, cacheItem](config.MaxItems)
-	if err != nil {
-		log.Error().Err(err).Msgf("couldn't create a memory cache connector with id %s", id)
-		return nil, fmt.Errorf("couldn't create a memory cache connector with id %s, reason - %s", id, err.Error())
-	}
-
 	connector := &InMemoryConnector{
 		id:                    id,
-		cache:                 cache,
 		expiredRemoveInterval: config.ExpiredRemoveInterval,
+		done:                  make(chan struct{}),
+		heights:               make(map[indexedBlock]map[string]struct{}),
 	}
 
+	cache, err := lru.NewWithEvict[string, cacheItem](config.MaxItems, connector.onEvict)
+	if err != nil {
+		log.Error().Err(err).Msgf("couldn't create a memory cache connector with id %s", id)
+		return nil, fmt.Errorf("couldn't create a memory cache connector with id %s, reason - %s", id, err.Error())
+	}
+	connector.cache = cache
+
 	return connector, nil
 }
 
@@ -63,27 +91,123 @@ func (i *InMemoryConnector) Store(_ context.Context, key string, object string,
 		expiredAt = lo.ToPtr(time.Now().Add(ttl))
 	}
 
-	i.cache.Add(key, cacheItem{object: object, expireAt: expiredAt})
+	if i.cache.Add(key, cacheItem{object: object, expireAt: expiredAt}) {
+		cacheEvictions.WithLabelValues(i.id, evictedBySize).Inc()
+	}
+
+	return nil
+}
+
+func (i *InMemoryConnector) StoreAtHeight(_ context.Context, key string, object string, ttl time.Duration, chain chains.Chain, height uint64) error {
+	var expiredAt *time.Time
+	if ttl > 0 {
+		expiredAt = lo.ToPtr(time.Now().Add(ttl))
+	}
+	block := indexedBlock{chain: chain, height: height}
+
+	i.heightsMu.Lock()
+	keys, ok := i.heights[block]
+	if !ok {
+		keys = make(map[string]struct{})
+		i.heights[block] = keys
+	}
+	keys[key] = struct{}{}
+	i.heightsMu.Unlock()
+
+	if i.cache.Add(key, cacheItem{object: object, expireAt: expiredAt, block: &block}) {
+		cacheEvictions.WithLabelValues(i.id, evictedBySize).Inc()
+	}
+
+	return nil
+}
+
+func (i *InMemoryConnector) RemoveHeights(_ context.Context, chain chains.Chain, fromHeight, toHeight uint64) error {
+	keys := make([]string, 0)
+
+	i.heightsMu.Lock()
+	for height := fromHeight; height <= toHeight; height++ {
+		for key := range i.heights[indexedBlock{chain: chain, height: height}] {
+			keys = append(keys, key)
+		}
+	}
+	i.heightsMu.Unlock()
+
+	// the index is cleaned up by onEvict, so the lock must not be held while removing
+	for _, key := range keys {
+		i.cache.Remove(key)
+	}
 
 	return nil
 }
 
+func (i *InMemoryConnector) Scan(ctx context.Context, prefix string, fn func(key string, size int) error) error {
+	now := time.Now()
+	for _, key := range i.cache.Keys() {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		item, ok := i.cache.Peek(key)
+		if !ok || (item.expireAt != nil && now.After(*item.expireAt)) {
+			continue
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := fn(key, len(item.object)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (i *InMemoryConnector) Remove(_ context.Context, keys ...string) error {
+	for _, key := range keys {
+		i.cache.Remove(key)
+	}
+	return nil
+}
+
+func (i *InMemoryConnector) onEvict(key string, item cacheItem) {
+	if item.block == nil {
+		return
+	}
+	i.heightsMu.Lock()
+	defer i.heightsMu.Unlock()
+
+	if keys, ok := i.heights[*item.block]; ok {
+		delete(keys, key)
+		if len(keys) == 0 {
+			delete(i.heights, *item.block)
+		}
+	}
+}
+
 func (i *InMemoryConnector) Receive(_ context.Context, key string) ([]byte, error) {
 	item, ok := i.cache.Get(key)
-	if !ok {
+	// an expired object is treated as missing, it stays in memory until removeExpired runs
+	if !ok || (item.expireAt != nil && time.Now().After(*item.expireAt)) {
 		return nil, ErrCacheNotFound
 	}
 	return []byte(item.object), nil
 }
 
+func (i *InMemoryConnector) Close() {
+	close(i.done)
+	i.cache.Purge()
+}
+
 func (i *InMemoryConnector) removeExpired() {
 	for {
-		<-time.After(i.expiredRemoveInterval)
+		select {
+		case <-i.done:
+			return
+		case <-time.After(i.expiredRemoveInterval):
+		}
 
 		for _, key := range i.cache.Keys() {
 			if item, ok := i.cache.Peek(key); ok {
-				if item.expireAt != nil && time.Now().After(*item.expireAt) {
-					i.cache.Remove(key)
+				if item.expireAt != nil && time.Now().After(*item.expireAt) && i.cache.Remove(key) {
+					cacheEvictions.WithLabelValues(i.id, evictedByExpired).Inc()
 				}
 			}
 		}
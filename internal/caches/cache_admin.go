@@ -0,0 +1,236 @@
+package caches
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/samber/lo"
+)
+
+// purgeBatchSize is the number of keys removed from a connector at once
+const purgeBatchSize = 1000
+
+var ErrCachePolicyNotFound = errors.New("cache policy not found")
+
+// CacheAdmin inspects and manages cached responses at runtime
+type CacheAdmin interface {
+	Lookup(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) ([]CacheEntry, error)
+	Purge(ctx context.Context, filter PurgeFilter) (int, error)
+	PurgeHeights(ctx context.Context, chain chains.Chain, fromHeight, toHeight uint64) error
+	Stats(ctx context.Context) ([]PolicyStats, error)
+}
+
+// CacheEntry is a response of a request found in the connector of a policy
+type CacheEntry struct {
+	Policy    string
+	Connector string
+	Key       string
+	Size      int
+	Stale     bool
+	// Error is set if the result is a cached upstream error
+	Error bool
+	// FreshUntil is zero if the object was stored by a policy without a stale window
+	FreshUntil time.Time
+	// StoredAt is zero for an object stored by an older version
+	StoredAt time.Time
+	Result   []byte
+}
+
+// PurgeFilter selects the cached responses to purge, an empty field matches everything
+type PurgeFilter struct {
+	Chain  chains.Chain
+	Method string
+	Policy string
+}
+
+// PolicyStats are the number and the stored size of the objects the policy could have cached,
+// objects of policies sharing a connector are counted for each of them
+type PolicyStats struct {
+	Policy    string
+	Connector string
+	Entries   int
+	Size      int64
+}
+
+// Lookup returns the response of the request from the connector of every policy the request matches
+func (c *GenericCacheProcessor) Lookup(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) ([]CacheEntry, error) {
+	cacheKey := getCacheKey(chain, request.Method(), request.RequestHash())
+	entries := make([]CacheEntry, 0)
+
+	for _, policy := range c.state.Load().policies {
+		if !policy.entryMatched(chain, request.Method()) {
+			continue
+		}
+		key := cacheKey
+		object, err := policy.connector.Receive(ctx, key)
+		if errors.Is(err, ErrCacheNotFound) {
+			// a response cached with the legacy key is shown until it's read and moved by a request
+			key = legacyCacheKey(chain, legacyRequestHash(request))
+			object, err = policy.connector.Receive(ctx, key)
+		}
+		if errors.Is(err, ErrCacheNotFound) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("connector %s of policy %s couldn't receive %s: %w", policy.connector.Id(), policy.id, key, err)
+		}
+		object, storedAt, _ := decodeStoredObject(object)
+		result, freshUntil, withStaleWindow := decodeStaleObject(object)
+		responseError, isError := decodeErrorObject(result)
+		if isError {
+			result = responseError
+		}
+		entries = append(entries, CacheEntry{
+			Policy:     policy.id,
+			Connector:  policy.connector.Id(),
+			Key:        key,
+			Size:       len(result),
+			Stale:      withStaleWindow && !time.Now().Before(freshUntil),
+			Error:      isError,
+			FreshUntil: freshUntil,
+			StoredAt:   storedAt,
+			Result:     result,
+		})
+	}
+
+	return entries, nil
+}
+
+// Purge removes the cached responses selected by the filter and returns their number. If the policy is set,
+// only responses it could have cached are removed, though another policy sharing the connector might have stored them
+func (c *GenericCacheProcessor) Purge(ctx context.Context, filter PurgeFilter) (int, error) {
+	policies := c.state.Load().policies
+	if filter.Policy != "" {
+		policies = lo.Filter(policies, func(policy *CachePolicy, _ int) bool {
+			return policy.id == filter.Policy
+		})
+		if len(policies) == 0 {
+			return 0, fmt.Errorf("%w: %s", ErrCachePolicyNotFound, filter.Policy)
+		}
+	}
+
+	prefix := ""
+	if filter.Chain != chains.Unknown {
+		prefix = chainCacheKeyPrefix(filter.Chain)
+		if filter.Method != "" {
+			prefix = methodCacheKeyPrefix(filter.Chain, filter.Method)
+		}
+	}
+
+	removed := 0
+	connectors, connectorPolicies := groupByConnector(policies)
+	for _, connector := range connectors {
+		keys := make([]string, 0, purgeBatchSize)
+		removeKeys := func() error {
+			if err := connector.Remove(ctx, keys...); err != nil {
+				return err
+			}
+			removed += len(keys)
+			keys = keys[:0]
+			return nil
+		}
+
+		err := connector.Scan(ctx, prefix, func(key string, _ int) error {
+			if !filter.matched(key, connectorPolicies[connector.Id()]) {
+				return nil
+			}
+			keys = append(keys, key)
+			if len(keys) == purgeBatchSize {
+				return removeKeys()
+			}
+			return nil
+		})
+		if err == nil {
+			err = removeKeys()
+		}
+		if err != nil {
+			return removed, fmt.Errorf("connector %s couldn't purge cached responses: %w", connector.Id(), err)
+		}
+	}
+
+	return removed, nil
+}
+
+// matched is true if the key is selected by the filter, the chain is already selected by the scanned prefix.
+// Keys without a method can't be matched by a method or a policy, so they are removed by a chain only
+func (f PurgeFilter) matched(key string, policies []*CachePolicy) bool {
+	chain, method, ok := parseCacheKey(key)
+	if !ok {
+		return f.Method == "" && f.Policy == ""
+	}
+	if f.Method != "" && method != f.Method {
+		return false
+	}
+	if f.Policy == "" {
+		return true
+	}
+	return lo.SomeBy(policies, func(policy *CachePolicy) bool {
+		return policy.entryMatched(chain, method)
+	})
+}
+
+// PurgeHeights removes the responses stored at the heights of the chain from every connector,
+// the same way they are removed after a reorg
+func (c *GenericCacheProcessor) PurgeHeights(ctx context.Context, chain chains.Chain, fromHeight, toHeight uint64) error {
+	connectors, _ := groupByConnector(c.state.Load().policies)
+	for _, connector := range connectors {
+		if err := connector.RemoveHeights(ctx, chain, fromHeight, toHeight); err != nil {
+			return fmt.Errorf("connector %s couldn't remove blocks %d-%d of %s: %w", connector.Id(), fromHeight, toHeight, chain, err)
+		}
+	}
+	return nil
+}
+
+// Stats scans every connector once, so it might take a while for a large remote cache
+func (c *GenericCacheProcessor) Stats(ctx context.Context) ([]PolicyStats, error) {
+	policies := c.state.Load().policies
+	stats := make(map[*CachePolicy]*PolicyStats, len(policies))
+	for _, policy := range policies {
+		stats[policy] = &PolicyStats{Policy: policy.id, Connector: policy.connector.Id()}
+	}
+
+	connectors, connectorPolicies := groupByConnector(policies)
+	for _, connector := range connectors {
+		err := connector.Scan(ctx, "", func(key string, size int) error {
+			chain, method, ok := parseCacheKey(key)
+			if !ok {
+				return nil
+			}
+			for _, policy := range connectorPolicies[connector.Id()] {
+				if policy.entryMatched(chain, method) {
+					stats[policy].Entries++
+					stats[policy].Size += int64(size)
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("connector %s couldn't scan cached responses: %w", connector.Id(), err)
+		}
+	}
+
+	return lo.Map(policies, func(policy *CachePolicy, _ int) PolicyStats {
+		return *stats[policy]
+	}), nil
+}
+
+// groupByConnector returns the connectors of the policies in the order of the policies
+// along with the policies of each connector, several policies might share one connector
+func groupByConnector(policies []*CachePolicy) ([]CacheConnector, map[string][]*CachePolicy) {
+	connectors := make([]CacheConnector, 0)
+	connectorPolicies := make(map[string][]*CachePolicy)
+	for _, policy := range policies {
+		id := policy.connector.Id()
+		if _, ok := connectorPolicies[id]; !ok {
+			connectors = append(connectors, policy.connector)
+		}
+		connectorPolicies[id] = append(connectorPolicies[id], policy)
+	}
+	return connectors, connectorPolicies
+}
+
+var _ CacheAdmin = (*GenericCacheProcessor)(nil)
@@ -0,0 +1,197 @@
+package caches
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newAdminTestConnector(t *testing.T, id string) *InMemoryConnector {
+	connector, err := NewInMemoryConnector(id, &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	require.NoError(t, err)
+	return connector
+}
+
+func adminTestPolicy(id string, connector CacheConnector, chain, method string) *CachePolicy {
+	policyConfig := test_utils.PolicyConfig(chain, method, connector.Id(), "10KB", "1m", true)
+	policyConfig.Id = id
+	return NewCachePolicy(nil, connector, policyConfig)
+}
+
+func storeAdminTestObjects(t *testing.T, connector CacheConnector, keys ...string) {
+	for _, key := range keys {
+		require.NoError(t, connector.Store(context.Background(), key, "object", time.Minute))
+	}
+}
+
+func receivedKeys(connector CacheConnector) []string {
+	keys := make([]string, 0)
+	_ = connector.Scan(context.Background(), "", func(key string, _ int) error {
+		keys = append(keys, key)
+		return nil
+	})
+	return keys
+}
+
+func TestParseCacheKey(t *testing.T) {
+	chain, method, ok := parseCacheKey(getCacheKey(chains.POLYGON, "eth_getBlockByNumber", "abc"))
+	assert.True(t, ok)
+	assert.Equal(t, chains.POLYGON, chain)
+	assert.Equal(t, "eth_getBlockByNumber", method)
+
+	chain, method, ok = parseCacheKey(getCacheKey(chains.BITCOIN_TESTNET, "GET#/blocks/{hash}", "abc"))
+	assert.True(t, ok)
+	assert.Equal(t, chains.BITCOIN_TESTNET, chain)
+	assert.Equal(t, "GET#/blocks/{hash}", method)
+
+	for _, key := range []string{"polygon_abc", "unknown-chain_eth_call_abc", "key"} {
+		_, _, ok = parseCacheKey(key)
+		assert.False(t, ok, key)
+	}
+}
+
+func TestCacheAdminLookupReturnsEntriesOfMatchedPolicies(t *testing.T) {
+	connector := newAdminTestConnector(t, "memory")
+	staleConnector := newAdminTestConnector(t, "stale-memory")
+	policies := []*CachePolicy{
+		adminTestPolicy("polygon", connector, "polygon", "eth_*"),
+		adminTestPolicy("stale", staleConnector, "*", "*"),
+		adminTestPolicy("ethereum", connector, "ethereum", "*"),
+	}
+	processor := createCacheProcessor(policies, time.Minute).(*GenericCacheProcessor)
+	request, _ := protocol.NewInternalUpstreamJsonRpcRequest("eth_getBlockByNumber", []any{"0x10", false}, chains.POLYGON)
+	key := getCacheKey(chains.POLYGON, request.Method(), request.RequestHash())
+	freshUntil := time.UnixMilli(time.Now().Add(-time.Second).UnixMilli())
+
+	storedAt := time.UnixMilli(time.Now().UnixMilli())
+	require.NoError(t, connector.Store(context.Background(), key, encodeTimedObject(storedObjectPrefix, []byte(`{"number":"0x10"}`), storedAt), time.Minute))
+	require.NoError(t, staleConnector.Store(context.Background(), key, encodeStaleObject([]byte(`{"number":"0x10"}`), freshUntil), time.Minute))
+
+	entries, err := processor.Lookup(context.Background(), chains.POLYGON, request)
+	require.NoError(t, err)
+
+	assert.Equal(t, []CacheEntry{
+		{Policy: "polygon", Connector: "memory", Key: key, Size: 17, StoredAt: storedAt, Result: []byte(`{"number":"0x10"}`)},
+		{Policy: "stale", Connector: "stale-memory", Key: key, Size: 17, Stale: true, FreshUntil: freshUntil, Result: []byte(`{"number":"0x10"}`)},
+	}, entries)
+}
+
+func TestCacheAdminPurgeByFilter(t *testing.T) {
+	keys := []string{
+		getCacheKey(chains.POLYGON, "eth_getBlockByNumber", "a1"),
+		getCacheKey(chains.POLYGON, "eth_call", "a2"),
+		getCacheKey(chains.ETHEREUM, "eth_getBlockByNumber", "a3"),
+		getCacheKey(chains.ETHEREUM, "eth_chainId", "a4"),
+		"polygon_a5",
+	}
+	tests := []struct {
+		name      string
+		filter    PurgeFilter
+		remaining []string
+	}{
+		{
+			name:      "chain and method",
+			filter:    PurgeFilter{Chain: chains.POLYGON, Method: "eth_getBlockByNumber"},
+			remaining: []string{keys[1], keys[2], keys[3], keys[4]},
+		},
+		{
+			name:      "chain",
+			filter:    PurgeFilter{Chain: chains.POLYGON},
+			remaining: []string{keys[2], keys[3]},
+		},
+		{
+			name:      "method",
+			filter:    PurgeFilter{Method: "eth_getBlockByNumber"},
+			remaining: []string{keys[1], keys[3], keys[4]},
+		},
+		{
+			name:      "policy",
+			filter:    PurgeFilter{Policy: "blocks"},
+			remaining: []string{keys[1], keys[3], keys[4]},
+		},
+		{
+			name:      "policy and chain",
+			filter:    PurgeFilter{Policy: "blocks", Chain: chains.ETHEREUM},
+			remaining: []string{keys[0], keys[1], keys[3], keys[4]},
+		},
+		{
+			name:      "everything",
+			filter:    PurgeFilter{},
+			remaining: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			connector := newAdminTestConnector(te, "memory")
+			processor := createCacheProcessor([]*CachePolicy{
+				adminTestPolicy("blocks", connector, "*", "eth_getBlockByNumber"),
+				adminTestPolicy("all", connector, "*", "*"),
+			}, time.Minute).(*GenericCacheProcessor)
+			storeAdminTestObjects(te, connector, keys...)
+
+			removed, err := processor.Purge(context.Background(), test.filter)
+			require.NoError(te, err)
+
+			assert.Equal(te, len(keys)-len(test.remaining), removed)
+			assert.ElementsMatch(te, test.remaining, receivedKeys(connector))
+		})
+	}
+}
+
+func TestCacheAdminPurgeUnknownPolicyThenError(t *testing.T) {
+	processor := createCacheProcessor([]*CachePolicy{}, time.Minute).(*GenericCacheProcessor)
+
+	removed, err := processor.Purge(context.Background(), PurgeFilter{Policy: "policy"})
+
+	assert.Zero(t, removed)
+	assert.ErrorIs(t, err, ErrCachePolicyNotFound)
+}
+
+func TestCacheAdminStatsCountsEntriesOfEachPolicy(t *testing.T) {
+	connector := newAdminTestConnector(t, "memory")
+	otherConnector := newAdminTestConnector(t, "other-memory")
+	processor := createCacheProcessor([]*CachePolicy{
+		adminTestPolicy("blocks", connector, "*", "eth_getBlockByNumber"),
+		adminTestPolicy("polygon", connector, "polygon", "*"),
+		adminTestPolicy("other", otherConnector, "*", "*"),
+	}, time.Minute).(*GenericCacheProcessor)
+	storeAdminTestObjects(t, connector,
+		getCacheKey(chains.POLYGON, "eth_getBlockByNumber", "a1"),
+		getCacheKey(chains.POLYGON, "eth_call", "a2"),
+		getCacheKey(chains.ETHEREUM, "eth_getBlockByNumber", "a3"),
+	)
+
+	stats, err := processor.Stats(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []PolicyStats{
+		{Policy: "blocks", Connector: "memory", Entries: 2, Size: 12},
+		{Policy: "polygon", Connector: "memory", Entries: 2, Size: 12},
+		{Policy: "other", Connector: "other-memory"},
+	}, stats)
+}
+
+func TestCacheAdminPurgeHeightsOncePerConnector(t *testing.T) {
+	connector := mocks.NewCacheConnectorMock()
+	connector.On("Id").Return("conn-id")
+	connector.On("RemoveHeights", mock.Anything, chains.POLYGON, uint64(100), uint64(102)).Return(nil).Once()
+	processor := createCacheProcessor([]*CachePolicy{
+		adminTestPolicy("policy1", connector, "*", "*"),
+		adminTestPolicy("policy2", connector, "polygon", "*"),
+	}, time.Minute).(*GenericCacheProcessor)
+
+	err := processor.PurgeHeights(context.Background(), chains.POLYGON, 100, 102)
+
+	assert.NoError(t, err)
+	connector.AssertExpectations(t)
+}
@@ -0,0 +1,88 @@
+package caches
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	CacheStatusHit   = "HIT"
+	CacheStatusStale = "STALE"
+
+	noCacheDirective      = "no-cache"
+	noStoreDirective      = "no-store"
+	maxAgeDirectivePrefix = "max-age="
+)
+
+// CacheControl is how a client wants the cache to treat its request
+type CacheControl struct {
+	// NoCache skips cached responses, the response from upstreams is still cached
+	NoCache bool
+	// NoStore doesn't cache the response
+	NoStore bool
+	// MaxAge is the maximum age of a cached response the client accepts, 0 means any age
+	MaxAge time.Duration
+}
+
+func (c CacheControl) Requested() bool {
+	return c.NoCache || c.NoStore || c.MaxAge > 0
+}
+
+// ParseCacheControl parses the request directives of the Cache-Control header, unknown directives are ignored.
+// A max-age of 0 doesn't accept any cached response, so it's the same as no-cache.
+func ParseCacheControl(value string) (CacheControl, error) {
+	cacheControl := CacheControl{}
+	for _, directive := range strings.Split(value, ",") {
+		directive = strings.ToLower(strings.TrimSpace(directive))
+		switch {
+		case directive == noCacheDirective:
+			cacheControl.NoCache = true
+		case directive == noStoreDirective:
+			cacheControl.NoStore = true
+		case strings.HasPrefix(directive, maxAgeDirectivePrefix):
+			seconds, err := strconv.ParseUint(strings.Trim(directive[len(maxAgeDirectivePrefix):], `"`), 10, 32)
+			if err != nil {
+				return CacheControl{}, fmt.Errorf("invalid max-age '%s'", directive[len(maxAgeDirectivePrefix):])
+			}
+			if seconds == 0 {
+				cacheControl.NoCache = true
+			} else {
+				cacheControl.MaxAge = time.Duration(seconds) * time.Second
+			}
+		}
+	}
+	return cacheControl, nil
+}
+
+type cacheControlCtxKey struct{}
+
+func WithCacheControl(ctx context.Context, cacheControl CacheControl) context.Context {
+	if !cacheControl.Requested() {
+		return ctx
+	}
+	return context.WithValue(ctx, cacheControlCtxKey{}, cacheControl)
+}
+
+// WithoutCacheControl drops the cache control of a client that isn't allowed to use it
+func WithoutCacheControl(ctx context.Context) context.Context {
+	if _, ok := CacheControlFromContext(ctx); !ok {
+		return ctx
+	}
+	return context.WithValue(ctx, cacheControlCtxKey{}, nil)
+}
+
+func CacheControlFromContext(ctx context.Context) (CacheControl, bool) {
+	if ctx == nil {
+		return CacheControl{}, false
+	}
+	cacheControl, ok := ctx.Value(cacheControlCtxKey{}).(CacheControl)
+	return cacheControl, ok
+}
+
+// CacheStatus is the value of the X-Nodecore-Cache header of a response served from the cache
+func CacheStatus(status, policy, connector string) string {
+	return fmt.Sprintf("%s; policy=%s; connector=%s", status, policy, connector)
+}
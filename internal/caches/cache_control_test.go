@@ -0,0 +1,58 @@
+package caches_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCacheControl(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    string
+		expected caches.CacheControl
+	}{
+		{"empty", "", caches.CacheControl{}},
+		{"no-cache", "no-cache", caches.CacheControl{NoCache: true}},
+		{"no-store", "No-Store", caches.CacheControl{NoStore: true}},
+		{"max-age", "max-age=30", caches.CacheControl{MaxAge: 30 * time.Second}},
+		{"quoted max-age", `max-age="30"`, caches.CacheControl{MaxAge: 30 * time.Second}},
+		{"zero max-age", "max-age=0", caches.CacheControl{NoCache: true}},
+		{"several directives", " no-store , max-age=5, no-transform", caches.CacheControl{NoStore: true, MaxAge: 5 * time.Second}},
+		{"unknown directives", "no-transform, only-if-cached", caches.CacheControl{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			cacheControl, err := caches.ParseCacheControl(test.value)
+
+			require.NoError(te, err)
+			assert.Equal(te, test.expected, cacheControl)
+		})
+	}
+}
+
+func TestParseCacheControlInvalidMaxAgeThenError(t *testing.T) {
+	for _, value := range []string{"max-age=", "max-age=abc", "max-age=-1", "no-cache, max-age=1.5"} {
+		_, err := caches.ParseCacheControl(value)
+
+		assert.ErrorContains(t, err, "invalid max-age", value)
+	}
+}
+
+func TestCacheControlContext(t *testing.T) {
+	_, ok := caches.CacheControlFromContext(caches.WithCacheControl(context.Background(), caches.CacheControl{}))
+	assert.False(t, ok)
+
+	ctx := caches.WithCacheControl(context.Background(), caches.CacheControl{NoStore: true})
+	cacheControl, ok := caches.CacheControlFromContext(ctx)
+	assert.True(t, ok)
+	assert.Equal(t, caches.CacheControl{NoStore: true}, cacheControl)
+
+	_, ok = caches.CacheControlFromContext(caches.WithoutCacheControl(ctx))
+	assert.False(t, ok)
+}
@@ -0,0 +1,115 @@
+package caches
+
+import (
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	storeRejectedTooLarge     = "too_large"
+	storeRejectedEmpty        = "empty"
+	storeRejectedNoBlock      = "no_block"
+	storeRejectedNotFinalized = "not_finalized"
+	storeRejectedError        = "error"
+
+	evictedBySize    = "size"
+	evictedByExpired = "expired"
+)
+
+// receiveBuckets are finer than the upstream request buckets, since a cache lookup
+// is much faster than an upstream request and is bounded by the receive-timeout
+var receiveBuckets = []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+var cachePolicyHit = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "cache",
+		Name:      "policy_hit",
+		Help:      "The total number of responses found in the cache by a cache policy",
+	},
+	[]string{"policy", "connector"},
+)
+
+var cachePolicyMiss = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "cache",
+		Name:      "policy_miss",
+		Help:      "The total number of responses not found in the cache by a cache policy",
+	},
+	[]string{"policy", "connector"},
+)
+
+var cacheReceiveTimeout = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "cache",
+		Name:      "receive_timeout",
+		Help:      "The total number of cache lookups of a cache policy not finished within the receive timeout",
+	},
+	[]string{"policy", "connector"},
+)
+
+var cacheReceiveDuration = prometheus.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Namespace: config.AppName,
+		Subsystem: "cache",
+		Name:      "receive_duration",
+		Buckets:   receiveBuckets,
+		Help:      "The duration of cache lookups of a cache policy",
+	},
+	[]string{"policy", "connector"},
+)
+
+var cacheStoreAccepted = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "cache",
+		Name:      "store_accepted",
+		Help:      "The total number of responses stored by a cache policy",
+	},
+	[]string{"policy", "connector"},
+)
+
+var cacheStoreRejected = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "cache",
+		Name:      "store_rejected",
+		Help:      "The total number of responses a cache policy matched but didn't store",
+	},
+	[]string{"policy", "connector", "reason"},
+)
+
+var cacheStoredBytes = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "cache",
+		Name:      "stored_bytes",
+		Help:      "The total size of objects stored by a cache policy",
+	},
+	[]string{"policy", "connector"},
+)
+
+var cacheEvictions = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "cache",
+		Name:      "evictions",
+		Help:      "The total number of objects a cache connector removed to free space or because they expired",
+	},
+	[]string{"connector", "reason"},
+)
+
+func init() {
+	prometheus.MustRegister(
+		cachePolicyHit,
+		cachePolicyMiss,
+		cacheReceiveTimeout,
+		cacheReceiveDuration,
+		cacheStoreAccepted,
+		cacheStoreRejected,
+		cacheStoredBytes,
+		cacheEvictions,
+	)
+}
@@ -0,0 +1,121 @@
+package caches
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// counterDelta returns how much a counter grows while fn runs, the counters are global and may be set by other runs
+func counterDelta(counter prometheus.Counter, fn func()) float64 {
+	before := testutil.ToFloat64(counter)
+	fn()
+	return testutil.ToFloat64(counter) - before
+}
+
+func metricsPolicy(t *testing.T, id, connectorId string, connector CacheConnector) *CachePolicy {
+	t.Helper()
+
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	policyCfg := test_utils.PolicyConfig("polygon", "test_method", connectorId, "1KB", "5s", false)
+	policyCfg.Id = id
+	return NewCachePolicy(upSupervisor, connector, policyCfg)
+}
+
+func TestCacheMetricsPolicyHitsMissesAndStores(t *testing.T) {
+	connector, err := NewInMemoryConnector("metrics-memory", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	require.NoError(t, err)
+	defer connector.Close()
+	policy := metricsPolicy(t, "metrics-policy", "metrics-memory", connector)
+	specMethod := test_utils.CacheableMethod("test_method")
+	stored, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("test_method", []any{"0x1"}, specMethod)
+	notStored, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("test_method", []any{"0x2"}, specMethod)
+
+	var storedBytes float64
+	accepted := counterDelta(cacheStoreAccepted.WithLabelValues("metrics-policy", "metrics-memory"), func() {
+		storedBytes = counterDelta(cacheStoredBytes.WithLabelValues("metrics-policy", "metrics-memory"), func() {
+			assert.True(t, policy.Store(context.Background(), chains.POLYGON, stored, []byte(`"result"`)))
+		})
+	})
+	hits := counterDelta(cachePolicyHit.WithLabelValues("metrics-policy", "metrics-memory"), func() {
+		_, ok := policy.Receive(context.Background(), chains.POLYGON, stored)
+		assert.True(t, ok)
+	})
+	misses := counterDelta(cachePolicyMiss.WithLabelValues("metrics-policy", "metrics-memory"), func() {
+		_, ok := policy.Receive(context.Background(), chains.POLYGON, notStored)
+		assert.False(t, ok)
+	})
+
+	assert.Equal(t, float64(1), accepted)
+	assert.Greater(t, storedBytes, float64(len(`"result"`)))
+	assert.Equal(t, float64(1), hits)
+	assert.Equal(t, float64(1), misses)
+}
+
+func TestCacheMetricsPolicyStoreRejectedByReason(t *testing.T) {
+	connector := mocks.NewCacheConnectorMock()
+	policy := metricsPolicy(t, "metrics-rejected-policy", "metrics-rejected", connector)
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("test_method", nil, test_utils.CacheableMethod("test_method"))
+	bigResponse, err := os.ReadFile("responses/big_response.json")
+	require.NoError(t, err)
+
+	tooLarge := counterDelta(cacheStoreRejected.WithLabelValues("metrics-rejected-policy", "metrics-rejected", storeRejectedTooLarge), func() {
+		assert.False(t, policy.Store(context.Background(), chains.POLYGON, request, bigResponse))
+	})
+	empty := counterDelta(cacheStoreRejected.WithLabelValues("metrics-rejected-policy", "metrics-rejected", storeRejectedEmpty), func() {
+		assert.False(t, policy.Store(context.Background(), chains.POLYGON, request, []byte(`[]`)))
+		assert.False(t, policy.Store(context.Background(), chains.POLYGON, request, []byte(`null`)))
+	})
+
+	assert.Equal(t, float64(1), tooLarge)
+	assert.Equal(t, float64(2), empty)
+	assert.Equal(t, float64(0), testutil.ToFloat64(cacheStoreAccepted.WithLabelValues("metrics-rejected-policy", "metrics-rejected")))
+}
+
+func TestCacheMetricsPolicyReceiveTimeoutIsNotMiss(t *testing.T) {
+	connector := mocks.NewCacheConnectorMock()
+	connector.On("Receive", mock.Anything, mock.Anything).Return([]byte{}, context.DeadlineExceeded)
+	policy := metricsPolicy(t, "metrics-timeout-policy", "metrics-timeout", connector)
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("test_method", nil, test_utils.CacheableMethod("test_method"))
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	timeouts := counterDelta(cacheReceiveTimeout.WithLabelValues("metrics-timeout-policy", "metrics-timeout"), func() {
+		_, ok := policy.Receive(ctx, chains.POLYGON, request)
+		assert.False(t, ok)
+	})
+
+	assert.Equal(t, float64(1), timeouts)
+	assert.Equal(t, float64(0), testutil.ToFloat64(cachePolicyMiss.WithLabelValues("metrics-timeout-policy", "metrics-timeout")))
+}
+
+func TestCacheMetricsMemoryConnectorEvictions(t *testing.T) {
+	connector, err := NewInMemoryConnector("metrics-evictions", &config.MemoryCacheConnectorConfig{MaxItems: 1, ExpiredRemoveInterval: 10 * time.Millisecond})
+	require.NoError(t, err)
+	require.NoError(t, connector.Initialize())
+	defer connector.Close()
+
+	var bySize float64
+	expired := counterDelta(cacheEvictions.WithLabelValues("metrics-evictions", evictedByExpired), func() {
+		bySize = counterDelta(cacheEvictions.WithLabelValues("metrics-evictions", evictedBySize), func() {
+			require.NoError(t, connector.Store(context.Background(), "key1", "object", 0))
+			require.NoError(t, connector.Store(context.Background(), "key2", "object", time.Millisecond))
+		})
+		time.Sleep(50 * time.Millisecond)
+	})
+
+	assert.Equal(t, float64(1), bySize)
+	assert.Equal(t, float64(1), expired)
+}
@@ -1,14 +1,18 @@
 package caches
 
 import (
+	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"path"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bytedance/sonic"
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
@@ -27,6 +31,20 @@ var EmptyResponses = [][]byte{
 	[]byte(`[]`),
 }
 
+// staleObjectPrefix marks an object stored by a policy with a stale window, it can't start a JSON document.
+// The prefix is followed by the unix millis the object is fresh until, a new line and the response itself.
+const staleObjectPrefix = "\x1estale:"
+
+// storedObjectPrefix is followed by the unix millis an object was stored at and a new line, it wraps every
+// stored object, so a client can limit the age of a cached response. Objects stored before it have no age.
+const storedObjectPrefix = "\x1estored:"
+
+// errorObjectPrefix marks a cached upstream error, the prefix is followed by the raw JSON-RPC error object
+const errorObjectPrefix = "\x1eerror:"
+
+// defaultErrorsTTL is used if a policy caches errors without a valid errors ttl
+const defaultErrorsTTL = 5 * time.Second
+
 type finalizationType int
 
 const (
@@ -35,16 +53,23 @@ const (
 )
 
 type CachePolicy struct {
-	connector          CacheConnector
-	methods            mapset.Set[string]
-	chains             mapset.Set[chains.Chain]
-	blockchainTypes    mapset.Set[chains.BlockchainType]
-	cacheEmpty         bool
-	maxSizeBytes       int
-	ttl                time.Duration
+	connector       CacheConnector
+	methods         mapset.Set[string]
+	chains          mapset.Set[chains.Chain]
+	blockchainTypes mapset.Set[chains.BlockchainType]
+	cacheEmpty      bool
+	maxSizeBytes    int
+	ttl             time.Duration
+	staleTTL        time.Duration
+	// staleIfErrorTTL follows the stale window, within it a response is served only if upstreams fail
+	staleIfErrorTTL time.Duration
+	// errorsTTL is how long non-retryable upstream errors are cached, errors aren't cached if it's 0
+	errorsTTL          time.Duration
 	upstreamSupervisor upstreams.UpstreamSupervisor
 	id                 string
-	finalizationType   finalizationType
+	// connectorId labels the metrics of the policy, it's the id the policy refers to its connector by
+	connectorId      string
+	finalizationType finalizationType
 }
 
 func NewCachePolicy(
@@ -56,13 +81,29 @@ func NewCachePolicy(
 	if err != nil {
 		ttl = 10 * time.Minute
 	}
+	var staleTTL, staleIfErrorTTL time.Duration
+	if ttl > 0 {
+		staleTTL = parseStaleWindow(policyConfig.StaleTTL)
+		staleIfErrorTTL = parseStaleWindow(policyConfig.StaleIfErrorTTL)
+	}
+	var errorsTTL time.Duration
+	if policyConfig.CacheErrors {
+		errorsTTL, err = time.ParseDuration(policyConfig.ErrorsTTL)
+		if err != nil || errorsTTL <= 0 {
+			errorsTTL = defaultErrorsTTL
+		}
+	}
 
 	return &CachePolicy{
 		id:                 policyConfig.Id,
 		connector:          cacheConnector,
+		connectorId:        policyConfig.Connector,
 		upstreamSupervisor: upstreamSupervisor,
 		cacheEmpty:         policyConfig.CacheEmpty,
 		ttl:                ttl,
+		staleTTL:           staleTTL,
+		staleIfErrorTTL:    staleIfErrorTTL,
+		errorsTTL:          errorsTTL,
 		chains:             getCacheChains(policyConfig.Chain),
 		blockchainTypes:    getCacheBlockchainTypes(policyConfig.BlockchainType),
 		methods:            getCacheMethods(policyConfig.Method),
@@ -81,38 +122,390 @@ func (c *CachePolicy) Store(
 		return false
 	}
 	if len(response) > c.maxSizeBytes { // check if a response body doesn't exceed the maximum size of a cacheable item
-		return false
+		return c.rejectStore(storeRejectedTooLarge)
 	}
 	if !c.cacheEmpty { // if empty responses can't be stored, check if a response body is one of the empty responses
 		for _, emptyResponse := range EmptyResponses {
 			if bytes.Equal(emptyResponse, response) {
-				return false
+				return c.rejectStore(storeRejectedEmpty)
 			}
 		}
 	}
-	if err := c.connector.Store(context.Background(), getCacheKey(chain, request.RequestHash()), string(response), c.ttl); err != nil {
-		log.Error().Err(err).Msgf("connector %s of policy %s couldn't cache request %s", c.connector.Id(), c.id, request.Method())
+	responseHeight, hasResponseHeight := uint64(0), false
+	if c.requestHeightUnknown(ctx, request) && request.SpecMethod().HasResponseParser() {
+		responseHeight, hasResponseHeight = parseResponseHeight(ctx, request.SpecMethod(), response)
+		if !hasResponseHeight {
+			return c.rejectStore(storeRejectedNoBlock) // a null or pending result isn't bound to a block yet, so it may change
+		}
+		if c.finalizationType == Finalized && !c.isFinalized(chain, responseHeight) {
+			return c.rejectStore(storeRejectedNotFinalized)
+		}
+	}
+	cacheKey := getCacheKey(chain, request.Method(), request.RequestHash())
+	object, ttl := string(response), c.ttl
+	if c.withStaleWindow() {
+		// the object outlives its ttl by the stale windows, the fresh deadline is kept in the object itself
+		object, ttl = encodeStaleObject(response, time.Now().Add(c.ttl)), c.ttl+c.staleTTL+c.staleIfErrorTTL
+	}
+	height, ok := c.reorgHeight(ctx, request)
+	if !ok && hasResponseHeight && c.reorgAware() {
+		height, ok = responseHeight, true
+	}
+	return c.storeObject(chain, request.Method(), cacheKey, object, ttl, height, ok)
+}
+
+// StoreError caches a non-retryable upstream error of a request for the errors ttl of the policy,
+// the same checks as for a response apply, so e.g. a finalized policy caches only errors of finalized blocks
+func (c *CachePolicy) StoreError(
+	ctx context.Context,
+	chain chains.Chain,
+	request protocol.RequestHolder,
+	responseError []byte,
+) bool {
+	if c.errorsTTL == 0 || len(responseError) == 0 {
+		return false
+	}
+	if !c.baseCacheableCheck(ctx, chain, request) {
+		return false
+	}
+	if len(responseError) > c.maxSizeBytes {
+		return c.rejectStore(storeRejectedTooLarge)
+	}
+	cacheKey := getCacheKey(chain, request.Method(), request.RequestHash())
+	height, ok := c.reorgHeight(ctx, request)
+	return c.storeObject(chain, request.Method(), cacheKey, errorObjectPrefix+string(responseError), c.errorsTTL, height, ok)
+}
+
+func (c *CachePolicy) storeObject(
+	chain chains.Chain,
+	method, cacheKey, object string,
+	ttl time.Duration,
+	height uint64,
+	atHeight bool,
+) bool {
+	var err error
+	object = encodeTimedObject(storedObjectPrefix, []byte(object), time.Now())
+	if atHeight {
+		err = c.connector.StoreAtHeight(context.Background(), cacheKey, object, ttl, chain, height)
+	} else {
+		err = c.connector.Store(context.Background(), cacheKey, object, ttl)
+	}
+	if err != nil {
+		log.Error().Err(err).Msgf("connector %s of policy %s couldn't cache request %s", c.connector.Id(), c.id, method)
+		return c.rejectStore(storeRejectedError)
+	}
+	cacheStoreAccepted.WithLabelValues(c.id, c.connectorId).Inc()
+	cacheStoredBytes.WithLabelValues(c.id, c.connectorId).Add(float64(len(object)))
+	return true
+}
+
+// rejectStore counts a response the policy matched but didn't store, false is always returned
+func (c *CachePolicy) rejectStore(reason string) bool {
+	cacheStoreRejected.WithLabelValues(c.id, c.connectorId, reason).Inc()
+	return false
+}
+
+// reorgHeight returns the highest block a request refers to if the policy caches not finalized data,
+// the response is indexed by this height to be removed if the block is reorged out
+func (c *CachePolicy) reorgHeight(ctx context.Context, request protocol.RequestHolder) (uint64, bool) {
+	if !c.reorgAware() {
+		return 0, false
+	}
+	switch param := request.ParseParams(ctx).(type) {
+	case *specs.BlockNumberParam:
+		return uint64(param.BlockNumber.Int64()), true
+	case *specs.BlockRangeParam:
+		if param.To == nil {
+			return 0, false
+		}
+		if param.From != nil && param.From.Int64() > param.To.Int64() {
+			return uint64(param.From.Int64()), true
+		}
+		return uint64(param.To.Int64()), true
+	}
+	return 0, false
+}
+
+// requestHeightUnknown is true if the request doesn't refer to a block by its number, e.g. it refers to a block
+// or a transaction by hash, so the block of its response is known only from the response itself
+func (c *CachePolicy) requestHeightUnknown(ctx context.Context, request protocol.RequestHolder) bool {
+	switch request.ParseParams(ctx).(type) {
+	case *specs.BlockNumberParam, *specs.BlockRangeParam:
 		return false
 	}
 	return true
 }
 
-func (c *CachePolicy) Receive(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) ([]byte, bool) {
+// parseResponseHeight returns the height of the block a response belongs to according to the response tag parser
+func parseResponseHeight(ctx context.Context, method *specs.Method, response []byte) (uint64, bool) {
+	var result any
+	if err := sonic.Unmarshal(response, &result); err != nil {
+		return 0, false
+	}
+	param, ok := method.ParseResponse(ctx, result).(*specs.BlockNumberParam)
+	if !ok || specs.IsBlockTagNumber(param.BlockNumber) || param.BlockNumber.Int64() < 0 {
+		return 0, false
+	}
+	return uint64(param.BlockNumber.Int64()), true
+}
+
+// isFinalized is true if the height is known to be finalized on the chain
+func (c *CachePolicy) isFinalized(chain chains.Chain, height uint64) bool {
+	finalizedHeight, ok := c.finalizedHeight(chain)
+	return ok && height <= finalizedHeight
+}
+
+func (c *CachePolicy) finalizedHeight(chain chains.Chain) (uint64, bool) {
+	chainSupervisor := c.upstreamSupervisor.GetChainSupervisor(chain)
+	if chainSupervisor == nil {
+		return 0, false
+	}
+	chainFinalizedBlock, ok := chainSupervisor.GetChainState().Blocks[protocol.FinalizedBlock]
+	if !ok || chainFinalizedBlock.IsEmptyByHeight() {
+		return 0, false
+	}
+	return chainFinalizedBlock.Height, true
+}
+
+// reorgAware is true if the policy might cache data of blocks that can be reorged out
+func (c *CachePolicy) reorgAware() bool {
+	return c.finalizationType == None
+}
+
+func (c *CachePolicy) Receive(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) (*protocol.CachedResponse, bool) {
 	localLog := zerolog.Ctx(ctx)
 	if !c.baseCacheableCheck(ctx, chain, request) {
 		return nil, false
 	}
-	cacheKey := getCacheKey(chain, request.RequestHash())
-
-	object, err := c.connector.Receive(ctx, cacheKey)
+	cacheKey := getCacheKey(chain, request.Method(), request.RequestHash())
+
+	start := time.Now()
+	var object []byte
+	var stream io.ReadCloser
+	var err error
+	if storage, ok := c.connector.(streamStorage); ok && request.IsStream() {
+		stream, err = storage.ReceiveStream(ctx, cacheKey)
+	} else {
+		object, err = c.connector.Receive(ctx, cacheKey)
+	}
+	if errors.Is(err, ErrCacheNotFound) {
+		object, err = c.receiveLegacy(ctx, chain, legacyRequestHash(request), cacheKey)
+	}
+	cacheReceiveDuration.WithLabelValues(c.id, c.connectorId).Observe(time.Since(start).Seconds())
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		if stream != nil {
+			_ = stream.Close()
+		}
+		// a lookup canceled since another policy has found the response is neither a hit nor a miss
+		if errors.Is(ctxErr, context.DeadlineExceeded) {
+			cacheReceiveTimeout.WithLabelValues(c.id, c.connectorId).Inc()
+		}
+		return nil, false
+	}
 	if err != nil {
 		localLog.
 			Debug().
 			Err(err).
 			Msgf("couldn't receive %s request from the cache connector %s with policy %s", request.Method(), c.connector.Id(), c.id)
+		cachePolicyMiss.WithLabelValues(c.id, c.connectorId).Inc()
+		return nil, false
+	}
+
+	var result *protocol.CachedResponse
+	var ok bool
+	if stream != nil {
+		result, ok = c.decodeObjectStream(ctx, stream)
+	} else {
+		result, ok = c.decodeObject(ctx, object)
+	}
+	if ok {
+		cachePolicyHit.WithLabelValues(c.id, c.connectorId).Inc()
+	} else {
+		cachePolicyMiss.WithLabelValues(c.id, c.connectorId).Inc()
+	}
+	return result, ok
+}
+
+// decodeObject returns the response of a received object if the policy and the client can use it
+func (c *CachePolicy) decodeObject(ctx context.Context, object []byte) (*protocol.CachedResponse, bool) {
+	object, storedAt, withStoredAt := decodeStoredObject(object)
+	if !maxAgeAllows(ctx, storedAt, withStoredAt) {
+		return nil, false
+	}
+	result, freshUntil, withStaleWindow := decodeStaleObject(object)
+	if len(result) == 0 {
+		return nil, false
+	}
+	if responseError, ok := decodeErrorObject(result); ok {
+		return c.errorResponse(responseError)
+	}
+	response, ok := c.freshness(freshUntil, withStaleWindow)
+	if ok {
+		response.Result = result
+	}
+	return response, ok
+}
+
+// decodeObjectStream is decodeObject for an object received as a stream. Only the prefixes of the object
+// are read here, the result is streamed to the client, so the stream is closed unless the result is returned
+func (c *CachePolicy) decodeObjectStream(ctx context.Context, stream io.ReadCloser) (*protocol.CachedResponse, bool) {
+	reader := bufio.NewReader(stream)
+	response, ok := c.decodeStreamPrefixes(ctx, reader)
+	if !ok || response.Error {
+		_ = stream.Close()
+		return response, ok
+	}
+	response.Stream = &bufferedObject{Reader: reader, Closer: stream}
+	return response, true
+}
+
+func (c *CachePolicy) decodeStreamPrefixes(ctx context.Context, reader *bufio.Reader) (*protocol.CachedResponse, bool) {
+	storedAt, withStoredAt, ok := readTimedPrefix(reader, storedObjectPrefix)
+	if !ok || !maxAgeAllows(ctx, storedAt, withStoredAt) {
 		return nil, false
 	}
-	return object, len(object) > 0
+	freshUntil, withStaleWindow, ok := readTimedPrefix(reader, staleObjectPrefix)
+	if !ok {
+		return nil, false
+	}
+	if _, err := reader.Peek(1); err != nil {
+		return nil, false
+	}
+	if hasStreamPrefix(reader, errorObjectPrefix) {
+		// errors are small, they are served as a whole
+		object, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, false
+		}
+		return c.errorResponse(object[len(errorObjectPrefix):])
+	}
+	return c.freshness(freshUntil, withStaleWindow)
+}
+
+// bufferedObject is a stream of an object read through the buffer its prefixes are read with
+type bufferedObject struct {
+	*bufio.Reader
+	io.Closer
+}
+
+// maxAgeAllows is false if the client asks for a response younger than the object stored at storedAt
+func maxAgeAllows(ctx context.Context, storedAt time.Time, withStoredAt bool) bool {
+	if cacheControl, ok := CacheControlFromContext(ctx); ok && cacheControl.MaxAge > 0 {
+		return withStoredAt && time.Since(storedAt) <= cacheControl.MaxAge
+	}
+	return true
+}
+
+func (c *CachePolicy) errorResponse(responseError []byte) (*protocol.CachedResponse, bool) {
+	if c.errorsTTL == 0 {
+		// the error is stored by another policy caching errors, this one serves responses only
+		return nil, false
+	}
+	return &protocol.CachedResponse{Result: responseError, Error: true}, true
+}
+
+// freshness returns a response without the result, it's stale past freshUntil, or false past the stale windows
+func (c *CachePolicy) freshness(freshUntil time.Time, withStaleWindow bool) (*protocol.CachedResponse, bool) {
+	now := time.Now()
+	stale := withStaleWindow && !now.Before(freshUntil)
+	if !stale {
+		return &protocol.CachedResponse{}, true
+	}
+	// the windows are the ones of this policy, the object may be stored by another policy with other windows
+	revalidateUntil := freshUntil.Add(c.staleTTL)
+	if now.Before(revalidateUntil) {
+		return &protocol.CachedResponse{Stale: true}, true
+	}
+	if now.Before(revalidateUntil.Add(c.staleIfErrorTTL)) {
+		return &protocol.CachedResponse{Stale: true, ServeStaleOnError: true}, true
+	}
+	return nil, false
+}
+
+func (c *CachePolicy) withStaleWindow() bool {
+	return c.staleTTL > 0 || c.staleIfErrorTTL > 0
+}
+
+// parseStaleWindow returns 0 for an empty window, the config validation rejects malformed ones
+func parseStaleWindow(window string) time.Duration {
+	if window == "" {
+		return 0
+	}
+	duration, _ := time.ParseDuration(window)
+	return duration
+}
+
+func encodeStaleObject(response []byte, freshUntil time.Time) string {
+	return encodeTimedObject(staleObjectPrefix, response, freshUntil)
+}
+
+// decodeStaleObject returns the response of a cached object and, if it was stored
+// by a policy with a stale window, the time it's fresh until
+func decodeStaleObject(object []byte) ([]byte, time.Time, bool) {
+	return decodeTimedObject(staleObjectPrefix, object)
+}
+
+// decodeStoredObject returns a cached object and the time it was stored at, if it's known
+func decodeStoredObject(object []byte) ([]byte, time.Time, bool) {
+	return decodeTimedObject(storedObjectPrefix, object)
+}
+
+// encodeTimedObject prepends the prefix, the unix millis of the time and a new line to the object
+func encodeTimedObject(prefix string, object []byte, t time.Time) string {
+	var builder strings.Builder
+	builder.Grow(len(prefix) + 20 + len(object))
+	builder.WriteString(prefix)
+	builder.WriteString(strconv.FormatInt(t.UnixMilli(), 10))
+	builder.WriteByte('\n')
+	builder.Write(object)
+	return builder.String()
+}
+
+// decodeTimedObject returns the object without the prefix and the time encoded after it, if the object has the prefix
+func decodeTimedObject(prefix string, object []byte) ([]byte, time.Time, bool) {
+	if !bytes.HasPrefix(object, []byte(prefix)) {
+		return object, time.Time{}, false
+	}
+	rest := object[len(prefix):]
+	newLine := bytes.IndexByte(rest, '\n')
+	if newLine < 0 {
+		return nil, time.Time{}, false
+	}
+	millis, err := strconv.ParseInt(string(rest[:newLine]), 10, 64)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return rest[newLine+1:], time.UnixMilli(millis), true
+}
+
+// readTimedPrefix is decodeTimedObject for an object read from a stream, ok is false if the prefix is malformed
+func readTimedPrefix(reader *bufio.Reader, prefix string) (t time.Time, withPrefix bool, ok bool) {
+	if !hasStreamPrefix(reader, prefix) {
+		return time.Time{}, false, true
+	}
+	_, _ = reader.Discard(len(prefix))
+	line, err := reader.ReadSlice('\n')
+	if err != nil {
+		return time.Time{}, false, false
+	}
+	millis, err := strconv.ParseInt(string(line[:len(line)-1]), 10, 64)
+	if err != nil {
+		return time.Time{}, false, false
+	}
+	return time.UnixMilli(millis), true, true
+}
+
+func hasStreamPrefix(reader *bufio.Reader, prefix string) bool {
+	peeked, err := reader.Peek(len(prefix))
+	return err == nil && string(peeked) == prefix
+}
+
+// decodeErrorObject returns the raw error of an object if it's a cached upstream error
+func decodeErrorObject(object []byte) ([]byte, bool) {
+	if !bytes.HasPrefix(object, []byte(errorObjectPrefix)) {
+		return nil, false
+	}
+	return object[len(errorObjectPrefix):], true
 }
 
 func mapFinalizationType(finalizationType config.FinalizationType) finalizationType {
@@ -212,15 +605,15 @@ func getCacheMethods(methodConfig string) mapset.Set[string] {
 	return cacheMethodsSet
 }
 
-func (c *CachePolicy) methodMatched(request protocol.RequestHolder) bool {
+func (c *CachePolicy) methodMatched(requestMethod string) bool {
 	for _, method := range c.methods.ToSlice() {
 		if strings.Contains(method, "*") {
-			ok, _ := path.Match(method, request.Method())
+			ok, _ := path.Match(method, requestMethod)
 			if ok {
 				return true
 			}
 		} else {
-			if method == request.Method() {
+			if method == requestMethod {
 				return true
 			}
 		}
@@ -228,6 +621,14 @@ func (c *CachePolicy) methodMatched(request protocol.RequestHolder) bool {
 	return false
 }
 
+// entryMatched is true if the policy could have cached a response of the method on the chain
+func (c *CachePolicy) entryMatched(chain chains.Chain, method string) bool {
+	if c.chainNotMatched(chain) || c.blockchainTypeNotMatched(chain) {
+		return false
+	}
+	return c.methods.IsEmpty() || c.methodMatched(method)
+}
+
 func (c *CachePolicy) chainNotMatched(chain chains.Chain) bool {
 	return c.chains == nil || (!c.chains.IsEmpty() && !c.chains.ContainsOne(chain))
 }
@@ -243,13 +644,80 @@ func (c *CachePolicy) blockchainTypeNotMatched(chain chains.Chain) bool {
 	return !c.blockchainTypes.ContainsOne(blockchainType)
 }
 
-func getCacheKey(chain chains.Chain, requestHash string) string {
+// getCacheKey returns the key of a cached response, the chain and the method are kept
+// in the key, so responses can be found by them without reading the objects
+func getCacheKey(chain chains.Chain, method, requestHash string) string {
+	return methodCacheKeyPrefix(chain, method) + requestHash
+}
+
+// legacyHashRequest is a request which hash has changed since its responses were cached with the legacy key
+type legacyHashRequest interface {
+	LegacyRequestHash() string
+}
+
+// legacyRequestHash is the hash of the request in its legacy cache key
+func legacyRequestHash(request protocol.RequestHolder) string {
+	if legacyRequest, ok := request.(legacyHashRequest); ok {
+		return legacyRequest.LegacyRequestHash()
+	}
+	return request.RequestHash()
+}
+
+// legacyCacheKey is the key of a response cached before the method became a part of the key
+func legacyCacheKey(chain chains.Chain, requestHash string) string {
 	return fmt.Sprintf("%s_%s", chain, requestHash)
 }
 
+// receiveLegacy reads a response by its legacy key. If the connector can tell the remaining ttl of the response,
+// the response is moved to the current key, so responses without a ttl aren't left behind in the legacy layout.
+// Responses of not finalized blocks are only read, they are indexed by height and expire soon anyway
+func (c *CachePolicy) receiveLegacy(ctx context.Context, chain chains.Chain, requestHash, cacheKey string) ([]byte, error) {
+	legacyKey := legacyCacheKey(chain, requestHash)
+	storage, ok := c.connector.(ttlStorage)
+	if !ok || c.reorgAware() {
+		return c.connector.Receive(ctx, legacyKey)
+	}
+	object, ttl, err := storage.ReceiveWithTTL(ctx, legacyKey)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := c.connector.Store(context.Background(), cacheKey, string(object), ttl); err != nil {
+			log.Warn().Err(err).Msgf("connector %s of policy %s couldn't move a response to its current key", c.connector.Id(), c.id)
+			return
+		}
+		_ = c.connector.Remove(context.Background(), legacyKey)
+	}()
+	return object, nil
+}
+
+// chainCacheKeyPrefix is the common prefix of the cache keys of the chain
+func chainCacheKeyPrefix(chain chains.Chain) string {
+	return fmt.Sprintf("%s_", chain)
+}
+
+// methodCacheKeyPrefix is the common prefix of the cache keys of the method on the chain
+func methodCacheKeyPrefix(chain chains.Chain, method string) string {
+	return fmt.Sprintf("%s_%s_", chain, method)
+}
+
+// parseCacheKey returns the chain and the method of a cache key. Chain names have no underscores
+// and the request hash is hex, so the method is everything between the first and the last underscore
+func parseCacheKey(key string) (chains.Chain, string, bool) {
+	chainEnd := strings.IndexByte(key, '_')
+	methodEnd := strings.LastIndexByte(key, '_')
+	if chainEnd < 0 || methodEnd <= chainEnd {
+		return chains.Unknown, "", false
+	}
+	chainName := key[:chainEnd]
+	if !chains.IsSupported(chainName) {
+		return chains.Unknown, "", false
+	}
+	return chains.GetChain(chainName).Chain, key[chainEnd+1 : methodEnd], true
+}
+
 func (c *CachePolicy) isMethodCacheable(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) bool {
-	chainsSupervisor := c.upstreamSupervisor.GetChainSupervisor(chain)
-	if chainsSupervisor == nil {
+	if c.upstreamSupervisor.GetChainSupervisor(chain) == nil {
 		return false
 	}
 
@@ -267,14 +735,8 @@ func (c *CachePolicy) isMethodCacheable(ctx context.Context, chain chains.Chain,
 		if specs.IsBlockTagNumber(param.BlockNumber) {
 			return false // not cache requests with block tags
 		}
-		if c.finalizationType == Finalized {
-			chainFinalizedBlock, ok := chainsSupervisor.GetChainState().Blocks[protocol.FinalizedBlock]
-			if !ok || chainFinalizedBlock.IsEmptyByHeight() {
-				return false // don't cache when finalized height is unknown
-			}
-			if uint64(param.BlockNumber.Int64()) > chainFinalizedBlock.Height {
-				return false
-			}
+		if c.finalizationType == Finalized && !c.isFinalized(chain, uint64(param.BlockNumber.Int64())) {
+			return false // don't cache when finalized height is unknown or below the block
 		}
 	case *specs.BlockRangeParam:
 		if param.From != nil && specs.IsBlockTagNumber(*param.From) {
@@ -284,10 +746,6 @@ func (c *CachePolicy) isMethodCacheable(ctx context.Context, chain chains.Chain,
 			return false // not cache requests with block tags
 		}
 		if c.finalizationType == Finalized {
-			chainFinalizedBlock, ok := chainsSupervisor.GetChainState().Blocks[protocol.FinalizedBlock]
-			if !ok || chainFinalizedBlock.IsEmptyByHeight() {
-				return false // don't cache when finalized height is unknown
-			}
 			var maxBlock int64
 			if param.From != nil && param.To != nil {
 				maxBlock = lo.Max([]int64{param.From.Int64(), param.To.Int64()})
@@ -297,8 +755,8 @@ func (c *CachePolicy) isMethodCacheable(ctx context.Context, chain chains.Chain,
 				// to is nil, but from not, means to is latest, so its a block tag
 				return false
 			}
-			if uint64(maxBlock) > chainFinalizedBlock.Height {
-				return false
+			if !c.isFinalized(chain, uint64(maxBlock)) {
+				return false // don't cache when finalized height is unknown or below the range
 			}
 		}
 	}
@@ -317,7 +775,7 @@ func (c *CachePolicy) baseCacheableCheck(ctx context.Context, chain chains.Chain
 		return false
 	}
 	if !c.methods.IsEmpty() { // check policy and request methods
-		matched := c.methodMatched(request)
+		matched := c.methodMatched(request.Method())
 		if !matched {
 			return false
 		}
@@ -3,14 +3,19 @@ package caches_test
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/upstreams"
 	"github.com/drpcorg/nodecore/internal/upstreams/fork_choice"
@@ -246,6 +251,71 @@ func TestCachePolicyIfConnectorErrorThenReceiveNothing(t *testing.T) {
 	assert.Nil(t, result)
 }
 
+func TestCachePolicyReceiveByLegacyKey(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	connector, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	assert.NoError(t, err)
+	specMethod := test_utils.CacheableMethod("test_method")
+	policy := caches.NewCachePolicy(upSupervisor, connector, test_utils.PolicyConfig("polygon", "test_method", "id", "10KB", "5s", true))
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("test_method", nil, specMethod)
+
+	err = connector.Store(context.Background(), "polygon_"+request.RequestHash(), "result", time.Minute)
+	assert.NoError(t, err)
+
+	result, ok := policy.Receive(context.Background(), chains.POLYGON, request)
+
+	assert.True(t, ok)
+	assert.Equal(t, []byte("result"), result.Result)
+}
+
+func TestCachePolicyReceiveByLegacyKeyThenMovedToCurrentKey(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	specMethod := test_utils.CacheableMethod("test_method")
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("test_method", nil, specMethod)
+	currentKey := "polygon_test_method_" + request.RequestHash()
+	legacyKey := "polygon_" + request.RequestHash()
+	moved := make(chan struct{})
+	connectorMock := &ttlCacheConnectorMock{CacheConnectorMock: mocks.NewCacheConnectorMock()}
+	connectorMock.On("Receive", mock.Anything, currentKey).Return([]byte(nil), caches.ErrCacheNotFound)
+	connectorMock.On("ReceiveWithTTL", mock.Anything, legacyKey).Return([]byte("result"), time.Duration(0), nil)
+	connectorMock.On("Store", mock.Anything, currentKey, "result", time.Duration(0)).Return(nil)
+	connectorMock.On("Remove", mock.Anything, []string{legacyKey}).Return(nil).Run(func(mock.Arguments) { close(moved) })
+	policy := caches.NewCachePolicy(upSupervisor, connectorMock, test_utils.PolicyConfigFinalized("polygon", "test_method", "id", "10KB", "0s", true))
+
+	result, ok := policy.Receive(context.Background(), chains.POLYGON, request)
+
+	assert.True(t, ok)
+	assert.Equal(t, []byte("result"), result.Result)
+	select {
+	case <-moved:
+	case <-time.After(time.Second):
+		t.Fatal("the response hasn't been moved to the current key")
+	}
+	connectorMock.AssertExpectations(t)
+}
+
+func TestCachePolicyReceiveByLegacyKeyOfParamsAsSentThenFound(t *testing.T) {
+	_ = specs.NewMethodSpecLoader().Load()
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	connector, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	assert.NoError(t, err)
+	policy := caches.NewCachePolicy(upSupervisor, connector, test_utils.PolicyConfig("polygon", "eth_getBalance", "id", "10KB", "5s", true))
+	params := json.RawMessage(`["0xAbCdEf0123456789aBcDeF0123456789ABCDEF01","0x0010"]`)
+	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_getBalance", Params: params}
+	request := protocol.NewUpstreamJsonRpcRequest("1", body, false, "eth")
+	rawRequest, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("eth_getBalance", params, nil)
+
+	assert.NotEqual(t, rawRequest.RequestHash(), request.RequestHash())
+
+	err = connector.Store(context.Background(), "polygon_"+rawRequest.RequestHash(), "result", time.Minute)
+	assert.NoError(t, err)
+
+	result, ok := policy.Receive(context.Background(), chains.POLYGON, request)
+
+	assert.True(t, ok)
+	assert.Equal(t, []byte("result"), result.Result)
+}
+
 func TestCachePolicyTooBigResponseSizeThenStoreNothing(t *testing.T) {
 	methodsMock, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
 	policyCfg := test_utils.PolicyConfig("polygon", "test_method|eth_*", "conn-id", "1KB", "5s", true)
@@ -318,11 +388,11 @@ func TestCachePolicyMultipleChainsThenReceiveAndStoreResultForAllOfThem(t *testi
 
 	result, ok := policy.Receive(context.Background(), chains.POLYGON, request)
 	assert.True(t, ok)
-	assert.True(t, bytes.Equal(result, result1))
+	assert.True(t, bytes.Equal(result.Result, result1))
 
 	result, ok = policy.Receive(context.Background(), chains.ETHEREUM, request)
 	assert.True(t, ok)
-	assert.True(t, bytes.Equal(result, result2))
+	assert.True(t, bytes.Equal(result.Result, result2))
 
 	ok = policy.Store(context.Background(), chains.POLYGON, request, result1)
 	assert.True(t, ok)
@@ -401,7 +471,7 @@ func TestCachePolicyAnyMethodThenReceiveAndStoreResult(t *testing.T) {
 
 			result, ok := policy.Receive(context.Background(), chains.POLYGON, request)
 			assert.True(t, ok)
-			assert.True(t, bytes.Equal(result, result1))
+			assert.True(t, bytes.Equal(result.Result, result1))
 
 			ok = policy.Store(context.Background(), chains.POLYGON, request, result1)
 			assert.True(te, ok)
@@ -431,7 +501,7 @@ func TestCachePolicyAllChainThenReceiveResult(t *testing.T) {
 
 			result, ok := policy.Receive(context.Background(), configuredChain.Chain, request)
 			assert.True(t, ok)
-			assert.True(t, bytes.Equal(result, result1))
+			assert.True(t, bytes.Equal(result.Result, result1))
 
 			ok = policy.Store(context.Background(), chains.POLYGON, request, result1)
 
@@ -458,7 +528,7 @@ func TestCachePolicyMatchedBlockchainTypeThenReceiveAndStoreResult(t *testing.T)
 
 	result, ok := policy.Receive(context.Background(), chains.POLYGON, request)
 	assert.True(t, ok)
-	assert.True(t, bytes.Equal(result, result1))
+	assert.True(t, bytes.Equal(result.Result, result1))
 
 	ok = policy.Store(context.Background(), chains.POLYGON, request, result1)
 	assert.True(t, ok)
@@ -543,7 +613,7 @@ func TestCachePolicySupportedMethodsThenReceiveResultAndStoreOrNothing(t *testin
 
 			result, ok := policy.Receive(context.Background(), chains.POLYGON, request)
 			assert.Equal(t, test.expected, ok)
-			assert.Equal(t, test.expected, bytes.Equal(result, result1))
+			assert.Equal(t, test.expected, bytes.Equal(lo.FromPtr(result).Result, result1))
 
 			ok = policy.Store(context.Background(), chains.POLYGON, request, result1)
 			assert.Equal(te, test.expected, ok)
@@ -591,3 +661,470 @@ func TestCachePolicyLabelSelectorsNamespaceTheCacheKey(t *testing.T) {
 	connectorMock.AssertExpectations(t)
 	upSupervisor.AssertExpectations(t)
 }
+
+func TestCachePolicyNotFinalizedBlockThenStoreAtHeight(t *testing.T) {
+	tests := []struct {
+		name   string
+		method string
+		params []byte
+		height uint64
+	}{
+		{
+			"eth_call with num",
+			"eth_call",
+			[]byte(`[false, "0x64"]`),
+			100,
+		},
+		{
+			"eth_getBlockByNumber with num",
+			"eth_getBlockByNumber",
+			[]byte(`["0x6e", false]`),
+			110,
+		},
+	}
+
+	chainSupervisor := upstreams.NewGenericChainSupervisor(context.Background(), chains.POLYGON, fork_choice.NewHeightForkChoice(), nil, false, nil)
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	upSupervisor.On("GetChainSupervisor", mock.Anything).Return(chainSupervisor)
+	_ = specs.NewMethodSpecLoader().Load()
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			connectorMock := mocks.NewCacheConnectorMock()
+			connectorMock.On("StoreAtHeight", mock.Anything, mock.Anything, storedObject("result"), 5*time.Second, chains.POLYGON, test.height).Return(nil)
+
+			policy := caches.NewCachePolicy(upSupervisor, connectorMock, test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "5s", true))
+			body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: test.method, Params: test.params}
+			request := protocol.NewUpstreamJsonRpcRequest("1", body, false, "eth")
+
+			ok := policy.Store(context.Background(), chains.POLYGON, request, []byte(`result`))
+
+			assert.True(te, ok)
+			connectorMock.AssertExpectations(te)
+			connectorMock.AssertNotCalled(te, "Store")
+		})
+	}
+}
+
+func TestCachePolicyFinalizedBlockThenStoreWithoutHeight(t *testing.T) {
+	chainSupervisor := upstreams.NewGenericChainSupervisor(context.Background(), chains.POLYGON, fork_choice.NewHeightForkChoice(), nil, false, nil)
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet("eth_call"))
+	blockInfo := protocol.NewBlockInfo()
+	blockInfo.AddBlock(protocol.NewBlockWithHeight(1000), protocol.FinalizedBlock)
+
+	go chainSupervisor.Start()
+
+	chainSupervisor.PublishUpstreamEvent(test_utils.CreateEventWithBlockData("id", protocol.Available, protocol.NewBlockWithHeight(1010), methodsMock, blockInfo))
+	time.Sleep(10 * time.Millisecond)
+
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	upSupervisor.On("GetChainSupervisor", mock.Anything).Return(chainSupervisor)
+	connectorMock := mocks.NewCacheConnectorMock()
+	connectorMock.On("Store", mock.Anything, mock.Anything, storedObject("result"), 5*time.Second).Return(nil)
+
+	policy := caches.NewCachePolicy(upSupervisor, connectorMock, test_utils.PolicyConfigFinalized("polygon", "*", "conn-id", "10KB", "5s", true))
+	_ = specs.NewMethodSpecLoader().Load()
+	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call", Params: []byte(`[false, "0x64"]`)}
+	request := protocol.NewUpstreamJsonRpcRequest("1", body, false, "eth")
+
+	ok := policy.Store(context.Background(), chains.POLYGON, request, []byte(`result`))
+
+	assert.True(t, ok)
+	connectorMock.AssertExpectations(t)
+	connectorMock.AssertNotCalled(t, "StoreAtHeight")
+}
+
+func TestCachePolicyStaleTtlThenReceiveStaleWithinStaleWindow(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	specMethod := test_utils.CacheableMethod("method")
+	connector, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	assert.NoError(t, err)
+
+	staleCfg := test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "50ms", true)
+	staleCfg.StaleTTL = "1m"
+	stalePolicy := caches.NewCachePolicy(upSupervisor, connector, staleCfg)
+	freshPolicy := caches.NewCachePolicy(upSupervisor, connector, test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "5s", true))
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("method", nil, specMethod)
+
+	ok := stalePolicy.Store(context.Background(), chains.POLYGON, request, []byte(`result`))
+	assert.True(t, ok)
+
+	result, ok := stalePolicy.Receive(context.Background(), chains.POLYGON, request)
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: []byte(`result`)}, result)
+
+	result, ok = freshPolicy.Receive(context.Background(), chains.POLYGON, request)
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: []byte(`result`)}, result)
+
+	time.Sleep(60 * time.Millisecond)
+
+	result, ok = stalePolicy.Receive(context.Background(), chains.POLYGON, request)
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: []byte(`result`), Stale: true}, result)
+
+	// a policy without a stale window doesn't serve stale objects of other policies
+	result, ok = freshPolicy.Receive(context.Background(), chains.POLYGON, request)
+	assert.False(t, ok)
+	assert.Nil(t, result)
+}
+
+func TestCachePolicyStaleIfErrorTtlThenServeOnErrorAfterStaleWindow(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	specMethod := test_utils.CacheableMethod("method")
+	connector, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	assert.NoError(t, err)
+
+	policyCfg := test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "10ms", true)
+	policyCfg.StaleTTL = "50ms"
+	policyCfg.StaleIfErrorTTL = "1m"
+	policy := caches.NewCachePolicy(upSupervisor, connector, policyCfg)
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("method", nil, specMethod)
+
+	ok := policy.Store(context.Background(), chains.POLYGON, request, []byte(`result`))
+	assert.True(t, ok)
+	time.Sleep(20 * time.Millisecond)
+
+	// within the stale window the response is revalidated in the background
+	result, ok := policy.Receive(context.Background(), chains.POLYGON, request)
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: []byte(`result`), Stale: true}, result)
+
+	time.Sleep(60 * time.Millisecond)
+
+	result, ok = policy.Receive(context.Background(), chains.POLYGON, request)
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: []byte(`result`), Stale: true, ServeStaleOnError: true}, result)
+}
+
+func TestCachePolicyStaleIfErrorTtlOnlyThenServeOnErrorAfterTtl(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	specMethod := test_utils.CacheableMethod("method")
+	connector, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	assert.NoError(t, err)
+
+	policyCfg := test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "10ms", true)
+	policyCfg.StaleIfErrorTTL = "1m"
+	policy := caches.NewCachePolicy(upSupervisor, connector, policyCfg)
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("method", nil, specMethod)
+
+	ok := policy.Store(context.Background(), chains.POLYGON, request, []byte(`result`))
+	assert.True(t, ok)
+	time.Sleep(20 * time.Millisecond)
+
+	result, ok := policy.Receive(context.Background(), chains.POLYGON, request)
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: []byte(`result`), Stale: true, ServeStaleOnError: true}, result)
+}
+
+// streamRequest is a request of a client that can receive a streamed response
+type streamRequest struct {
+	*protocol.UpstreamJsonRpcRequest
+}
+
+func (s streamRequest) IsStream() bool {
+	return true
+}
+
+func TestCachePolicyStreamRequestThenReceiveStreamOfCompressedObject(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	specMethod := test_utils.CacheableMethod("method")
+	memory, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	assert.NoError(t, err)
+	connector := caches.NewCompressedConnector(memory, &config.CacheCompressionConfig{Algorithm: config.Gzip, MinSize: "1KB"})
+
+	policyCfg := test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "50ms", true)
+	policyCfg.StaleTTL = "1m"
+	policy := caches.NewCachePolicy(upSupervisor, connector, policyCfg)
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("method", nil, specMethod)
+	object := []byte(`[` + strings.Repeat(`"0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060",`, 100) + `"0x"]`)
+
+	ok := policy.Store(context.Background(), chains.POLYGON, request, object)
+	assert.True(t, ok)
+
+	result, ok := policy.Receive(context.Background(), chains.POLYGON, streamRequest{request})
+	assert.True(t, ok)
+	assert.Nil(t, result.Result)
+	assert.False(t, result.Stale)
+	received, err := io.ReadAll(result.Stream)
+	assert.NoError(t, err)
+	assert.Equal(t, object, received)
+
+	time.Sleep(60 * time.Millisecond)
+
+	result, ok = policy.Receive(context.Background(), chains.POLYGON, streamRequest{request})
+	assert.True(t, ok)
+	assert.True(t, result.Stale)
+	received, err = io.ReadAll(result.Stream)
+	assert.NoError(t, err)
+	assert.Equal(t, object, received)
+
+	// a request that can't be streamed gets the whole result
+	result, ok = policy.Receive(context.Background(), chains.POLYGON, request)
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: object, Stale: true}, result)
+}
+
+func TestCachePolicyStreamRequestThenReceiveWholeError(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	specMethod := test_utils.CacheableMethod("method")
+	memory, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	assert.NoError(t, err)
+	connector := caches.NewCompressedConnector(memory, &config.CacheCompressionConfig{Algorithm: config.Zstd, MinSize: "1KB"})
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("method", nil, specMethod)
+
+	err = memory.Store(context.Background(), "polygon_method_"+request.RequestHash(), "\x1eerror:"+`{"code":3,"message":"execution reverted"}`, time.Minute)
+	assert.NoError(t, err)
+
+	policyCfg := test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "1m", true)
+	policyCfg.CacheErrors = true
+	policy := caches.NewCachePolicy(upSupervisor, connector, policyCfg)
+
+	result, ok := policy.Receive(context.Background(), chains.POLYGON, streamRequest{request})
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: []byte(`{"code":3,"message":"execution reverted"}`), Error: true}, result)
+}
+
+func TestCachePolicyMaxAgeThenReceiveYoungerResponsesOnly(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	connector, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	assert.NoError(t, err)
+	policy := caches.NewCachePolicy(upSupervisor, connector, test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "1m", true))
+	maxAgeCtx := caches.WithCacheControl(context.Background(), caches.CacheControl{MaxAge: time.Minute})
+
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("method", nil, test_utils.CacheableMethod("method"))
+	ok := policy.Store(context.Background(), chains.POLYGON, request, []byte(`result`))
+	assert.True(t, ok)
+
+	oldRequest, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("old_method", nil, test_utils.CacheableMethod("old_method"))
+	storedAt := strconv.FormatInt(time.Now().Add(-2*time.Minute).UnixMilli(), 10)
+	err = connector.Store(context.Background(), "polygon_old_method_"+oldRequest.RequestHash(), "\x1estored:"+storedAt+"\nresult", time.Minute)
+	assert.NoError(t, err)
+
+	// an object stored by an older version has an unknown age
+	unknownRequest, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("unknown_method", nil, test_utils.CacheableMethod("unknown_method"))
+	err = connector.Store(context.Background(), "polygon_unknown_method_"+unknownRequest.RequestHash(), "result", time.Minute)
+	assert.NoError(t, err)
+
+	result, ok := policy.Receive(maxAgeCtx, chains.POLYGON, request)
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: []byte(`result`)}, result)
+
+	for _, tooOld := range []protocol.RequestHolder{oldRequest, unknownRequest} {
+		_, ok = policy.Receive(maxAgeCtx, chains.POLYGON, tooOld)
+		assert.False(t, ok)
+
+		result, ok = policy.Receive(context.Background(), chains.POLYGON, tooOld)
+		assert.True(t, ok)
+		assert.Equal(t, &protocol.CachedResponse{Result: []byte(`result`)}, result)
+	}
+}
+
+func TestCachePolicyStaleTtlThenStoreWithStaleWindow(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	specMethod := test_utils.CacheableMethod("method")
+	connectorMock := mocks.NewCacheConnectorMock()
+	connectorMock.On("Store", mock.Anything, mock.Anything, mock.MatchedBy(func(object string) bool {
+		return strings.HasPrefix(object, "\x1estored:") && strings.Contains(object, "\n\x1estale:") && strings.HasSuffix(object, "\nresult")
+	}), time.Hour+65*time.Second).Return(nil)
+
+	policyCfg := test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "5s", true)
+	policyCfg.StaleTTL = "1m"
+	policyCfg.StaleIfErrorTTL = "1h"
+	policy := caches.NewCachePolicy(upSupervisor, connectorMock, policyCfg)
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("method", nil, specMethod)
+
+	ok := policy.Store(context.Background(), chains.POLYGON, request, []byte(`result`))
+
+	assert.True(t, ok)
+	connectorMock.AssertExpectations(t)
+}
+
+func TestCachePolicyFinalizedThenStoreHashRequestsOfFinalizedBlocksOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		response string
+		stored   bool
+	}{
+		{
+			"receipt of finalized block",
+			"eth_getTransactionReceipt",
+			`{"blockNumber":"0x3e8","status":"0x1"}`,
+			true,
+		},
+		{
+			"receipt of not finalized block",
+			"eth_getTransactionReceipt",
+			`{"blockNumber":"0x3e9","status":"0x1"}`,
+			false,
+		},
+		{
+			"no receipt",
+			"eth_getTransactionReceipt",
+			`null`,
+			false,
+		},
+		{
+			"pending transaction",
+			"eth_getTransactionByHash",
+			`{"blockNumber":null,"hash":"0x1"}`,
+			false,
+		},
+		{
+			"block of finalized block",
+			"eth_getBlockByHash",
+			`{"number":"0x3e7"}`,
+			true,
+		},
+		{
+			"not a json",
+			"eth_getBlockByHash",
+			`result`,
+			false,
+		},
+	}
+
+	chainSupervisor := upstreams.NewGenericChainSupervisor(context.Background(), chains.POLYGON, fork_choice.NewHeightForkChoice(), nil, false, nil)
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet("eth_getTransactionReceipt"))
+	blockInfo := protocol.NewBlockInfo()
+	blockInfo.AddBlock(protocol.NewBlockWithHeight(1000), protocol.FinalizedBlock)
+
+	go chainSupervisor.Start()
+
+	chainSupervisor.PublishUpstreamEvent(test_utils.CreateEventWithBlockData("id", protocol.Available, protocol.NewBlockWithHeight(1010), methodsMock, blockInfo))
+	time.Sleep(10 * time.Millisecond)
+
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	upSupervisor.On("GetChainSupervisor", mock.Anything).Return(chainSupervisor)
+	_ = specs.NewMethodSpecLoader().Load()
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			connectorMock := mocks.NewCacheConnectorMock()
+			connectorMock.On("Store", mock.Anything, mock.Anything, storedObject(test.response), 5*time.Second).Return(nil)
+
+			policy := caches.NewCachePolicy(upSupervisor, connectorMock, test_utils.PolicyConfigFinalized("polygon", "*", "conn-id", "10KB", "5s", true))
+			body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: test.method, Params: []byte(`["0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060"]`)}
+			request := protocol.NewUpstreamJsonRpcRequest("1", body, false, "eth")
+
+			ok := policy.Store(context.Background(), chains.POLYGON, request, []byte(test.response))
+
+			assert.Equal(te, test.stored, ok)
+			if test.stored {
+				connectorMock.AssertExpectations(te)
+			} else {
+				connectorMock.AssertNotCalled(te, "Store")
+			}
+			connectorMock.AssertNotCalled(te, "StoreAtHeight")
+		})
+	}
+}
+
+func TestCachePolicyNotFinalizedThenStoreHashRequestAtResponseHeight(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	connectorMock := mocks.NewCacheConnectorMock()
+	response := `{"blockNumber":"0x6e","status":"0x1"}`
+	connectorMock.On("StoreAtHeight", mock.Anything, mock.Anything, storedObject(response), 5*time.Second, chains.POLYGON, uint64(110)).Return(nil)
+
+	policy := caches.NewCachePolicy(upSupervisor, connectorMock, test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "5s", true))
+	_ = specs.NewMethodSpecLoader().Load()
+	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_getTransactionReceipt", Params: []byte(`["0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060"]`)}
+	request := protocol.NewUpstreamJsonRpcRequest("1", body, false, "eth")
+
+	ok := policy.Store(context.Background(), chains.POLYGON, request, []byte(response))
+
+	assert.True(t, ok)
+	connectorMock.AssertExpectations(t)
+	connectorMock.AssertNotCalled(t, "Store")
+}
+
+func TestCachePolicyCacheErrorsThenReceiveError(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	specMethod := test_utils.CacheableMethod("method")
+	connector, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	assert.NoError(t, err)
+
+	errorsCfg := test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "5s", true)
+	errorsCfg.CacheErrors = true
+	errorsPolicy := caches.NewCachePolicy(upSupervisor, connector, errorsCfg)
+	responsesPolicy := caches.NewCachePolicy(upSupervisor, connector, test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "5s", true))
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("method", nil, specMethod)
+	responseError := []byte(`{"code":3,"message":"execution reverted"}`)
+
+	assert.False(t, responsesPolicy.StoreError(context.Background(), chains.POLYGON, request, responseError))
+	assert.True(t, errorsPolicy.StoreError(context.Background(), chains.POLYGON, request, responseError))
+
+	result, ok := errorsPolicy.Receive(context.Background(), chains.POLYGON, request)
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: responseError, Error: true}, result)
+
+	// a policy that doesn't cache errors doesn't serve errors of other policies
+	result, ok = responsesPolicy.Receive(context.Background(), chains.POLYGON, request)
+	assert.False(t, ok)
+	assert.Nil(t, result)
+}
+
+func TestCachePolicyCacheErrorsThenResponseReplacesError(t *testing.T) {
+	_, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	specMethod := test_utils.CacheableMethod("method")
+	connector, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	assert.NoError(t, err)
+
+	errorsCfg := test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "5s", true)
+	errorsCfg.CacheErrors = true
+	policy := caches.NewCachePolicy(upSupervisor, connector, errorsCfg)
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("method", nil, specMethod)
+
+	assert.True(t, policy.StoreError(context.Background(), chains.POLYGON, request, []byte(`{"code":3,"message":"execution reverted"}`)))
+	assert.True(t, policy.Store(context.Background(), chains.POLYGON, request, []byte(`result`)))
+
+	result, ok := policy.Receive(context.Background(), chains.POLYGON, request)
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: []byte(`result`)}, result)
+}
+
+func TestCachePolicyCacheErrorsFinalizedThenStoreErrorsOfFinalizedBlocksOnly(t *testing.T) {
+	chainSupervisor := upstreams.NewGenericChainSupervisor(context.Background(), chains.POLYGON, fork_choice.NewHeightForkChoice(), nil, false, nil)
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet("eth_call"))
+	blockInfo := protocol.NewBlockInfo()
+	blockInfo.AddBlock(protocol.NewBlockWithHeight(1000), protocol.FinalizedBlock)
+
+	go chainSupervisor.Start()
+
+	chainSupervisor.PublishUpstreamEvent(test_utils.CreateEventWithBlockData("id", protocol.Available, protocol.NewBlockWithHeight(1010), methodsMock, blockInfo))
+	time.Sleep(10 * time.Millisecond)
+
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	upSupervisor.On("GetChainSupervisor", mock.Anything).Return(chainSupervisor)
+	responseError := `{"code":3,"message":"execution reverted"}`
+	connectorMock := mocks.NewCacheConnectorMock()
+	connectorMock.On("Store", mock.Anything, mock.Anything, storedObject("\x1eerror:"+responseError), 5*time.Second).Return(nil)
+
+	policyConfig := test_utils.PolicyConfigFinalized("polygon", "*", "conn-id", "10KB", "1h", true)
+	policyConfig.CacheErrors = true
+	policyConfig.ErrorsTTL = "5s"
+	policy := caches.NewCachePolicy(upSupervisor, connectorMock, policyConfig)
+	_ = specs.NewMethodSpecLoader().Load()
+
+	finalizedBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call", Params: []byte(`[false, "0x3e8"]`)}
+	finalizedRequest := protocol.NewUpstreamJsonRpcRequest("1", finalizedBody, false, "eth")
+	notFinalizedBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call", Params: []byte(`[false, "0x3e9"]`)}
+	notFinalizedRequest := protocol.NewUpstreamJsonRpcRequest("1", notFinalizedBody, false, "eth")
+
+	assert.True(t, policy.StoreError(context.Background(), chains.POLYGON, finalizedRequest, []byte(responseError)))
+	assert.False(t, policy.StoreError(context.Background(), chains.POLYGON, notFinalizedRequest, []byte(responseError)))
+
+	connectorMock.AssertNumberOfCalls(t, "Store", 1)
+	connectorMock.AssertNotCalled(t, "StoreAtHeight")
+}
+
+// storedObject matches an object stored along with the time it's stored at
+func storedObject(object string) any {
+	return mock.MatchedBy(func(stored string) bool {
+		return strings.HasPrefix(stored, "\x1estored:") && strings.HasSuffix(stored, "\n"+object)
+	})
+}
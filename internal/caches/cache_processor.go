@@ -3,14 +3,17 @@ package caches
 import (
 	"context"
 	"fmt"
+	"reflect"
 	"sync"
 	"time"
 
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/storages"
+	"github.com/drpcorg/nodecore/internal/tracing"
 	"github.com/drpcorg/nodecore/internal/upstreams"
 	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/utils"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 	"github.com/samber/lo"
@@ -32,61 +35,184 @@ func init() {
 
 type CacheProcessor interface {
 	Store(ctx context.Context, chain chains.Chain, request protocol.RequestHolder, response []byte)
-	Receive(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) ([]byte, bool)
+	// StoreError caches a non-retryable upstream error by the policies caching errors
+	StoreError(ctx context.Context, chain chains.Chain, request protocol.RequestHolder, responseError []byte)
+	Receive(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) (*protocol.CachedResponse, bool)
 }
 
 type GenericCacheProcessor struct {
+	ctx                context.Context
+	upstreamSupervisor upstreams.UpstreamSupervisor
+	storageRegistry    *storages.StorageRegistry
+	state              *utils.Atomic[*cacheProcessorState]
+
+	mu         sync.Mutex
+	connectors map[string]*cacheConnectorEntry
+
+	// reorgWatchers holds the chains whose reorgs are tracked to remove cached responses of dropped blocks
+	reorgWatchers *utils.CMap[chains.Chain, struct{}]
+}
+
+// cacheProcessorState is swapped as a whole on reload so a request never sees
+// policies of one config with the receive timeout of another
+type cacheProcessorState struct {
 	policies       []*CachePolicy
 	receiveTimeout time.Duration
 }
 
+type cacheConnectorEntry struct {
+	connector CacheConnector
+	config    *config.CacheConnectorConfig
+}
+
 func NewGenericCacheProcessor(
+	ctx context.Context,
 	upstreamSupervisor upstreams.UpstreamSupervisor,
 	cacheConfig *config.CacheConfig,
 	storageRegistry *storages.StorageRegistry,
 ) (*GenericCacheProcessor, error) {
-	cacheConnectors := make([]CacheConnector, 0)
+	cacheProcessor := &GenericCacheProcessor{
+		ctx:                ctx,
+		reorgWatchers:      utils.NewCMap[chains.Chain, struct{}](),
+		upstreamSupervisor: upstreamSupervisor,
+		storageRegistry:    storageRegistry,
+		state:              utils.NewAtomic[*cacheProcessorState](),
+		connectors:         make(map[string]*cacheConnectorEntry),
+	}
+	if err := cacheProcessor.Update(cacheConfig); err != nil {
+		return nil, err
+	}
+	return cacheProcessor, nil
+}
+
+// Update applies a new cache config, it's PrepareUpdate followed by Apply
+func (c *GenericCacheProcessor) Update(cacheConfig *config.CacheConfig) error {
+	update, err := c.PrepareUpdate(cacheConfig)
+	if err != nil {
+		return err
+	}
+	update.Apply()
+	return nil
+}
 
+// CacheUpdate is a new cache config whose connectors are created and initialized,
+// it's either applied or discarded
+type CacheUpdate struct {
+	processor         *GenericCacheProcessor
+	state             *cacheProcessorState
+	connectors        map[string]*cacheConnectorEntry
+	createdConnectors []CacheConnector
+}
+
+// PrepareUpdate builds a new cache config without touching the current one. Connectors with an unchanged config
+// are kept along with their cached data, new and changed ones are created and initialized,
+// so if any of them fails nothing is left behind
+func (c *GenericCacheProcessor) PrepareUpdate(cacheConfig *config.CacheConfig) (*CacheUpdate, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	update := &CacheUpdate{
+		processor:         c,
+		connectors:        make(map[string]*cacheConnectorEntry),
+		createdConnectors: make([]CacheConnector, 0),
+	}
 	for _, connectorCfg := range cacheConfig.CacheConnectors {
-		var connector CacheConnector
-		var err error
-		switch connectorCfg.Driver {
-		case config.Memory:
-			connector, err = NewInMemoryConnector(connectorCfg.Id, connectorCfg.Memory)
-		case config.Redis:
-			connector, err = NewRedisConnector(connectorCfg.Id, connectorCfg.Redis, storageRegistry)
-		case config.Postgres:
-			connector, err = NewPostgresConnector(connectorCfg.Id, connectorCfg.Postgres, storageRegistry)
-		default:
-			return nil, fmt.Errorf("unknown connector driver '%s'", connectorCfg.Driver)
+		if current, ok := c.connectors[connectorCfg.Id]; ok && reflect.DeepEqual(current.config, connectorCfg) {
+			update.connectors[connectorCfg.Id] = current
+			continue
 		}
+		connector, err := c.createConnector(connectorCfg)
 		if err != nil {
+			update.Discard()
 			return nil, err
 		}
-		cacheConnectors = append(cacheConnectors, connector)
-	}
-
-	for _, connector := range cacheConnectors {
-		if err := connector.Initialize(); err != nil {
+		if err = connector.Initialize(); err != nil {
+			update.Discard()
 			return nil, err
 		}
+		update.createdConnectors = append(update.createdConnectors, connector)
+		update.connectors[connectorCfg.Id] = &cacheConnectorEntry{connector: connector, config: connectorCfg}
 	}
 
 	cachePolicies := lo.FilterMap(cacheConfig.CachePolicies, func(item *config.CachePolicyConfig, index int) (*CachePolicy, bool) {
-		connector, ok := lo.Find(cacheConnectors, func(conn CacheConnector) bool {
-			return item.Connector == conn.Id()
-		})
+		entry, ok := update.connectors[item.Connector]
 		if ok {
-			log.Info().Msgf("%s cache policy with %s connector will be used to cache responses", item.Id, connector.Id())
-			return NewCachePolicy(upstreamSupervisor, connector, item), true
+			return NewCachePolicy(c.upstreamSupervisor, entry.connector, item), true
 		}
 		return nil, false
 	})
-
-	return &GenericCacheProcessor{
-		receiveTimeout: cacheConfig.ReceiveTimeout,
+	update.state = &cacheProcessorState{
 		policies:       cachePolicies,
-	}, nil
+		receiveTimeout: cacheConfig.ReceiveTimeout,
+	}
+
+	return update, nil
+}
+
+// Apply swaps the current policies with the new ones and closes the connectors that are no longer used
+func (u *CacheUpdate) Apply() {
+	c := u.processor
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, policy := range u.state.policies {
+		log.Info().Msgf("%s cache policy with %s connector will be used to cache responses", policy.id, policy.connector.Id())
+	}
+	c.state.Store(u.state)
+
+	for id, current := range c.connectors {
+		if newEntry, ok := u.connectors[id]; !ok || newEntry != current {
+			log.Info().Msgf("cache connector %s has been removed", id)
+			current.connector.Close()
+		}
+	}
+	c.connectors = u.connectors
+}
+
+// Discard closes the connectors created for the update, the current config stays in place
+func (u *CacheUpdate) Discard() {
+	for _, connector := range u.createdConnectors {
+		connector.Close()
+	}
+}
+
+func (c *GenericCacheProcessor) createConnector(connectorCfg *config.CacheConnectorConfig) (CacheConnector, error) {
+	connector, err := c.createDriverConnector(connectorCfg)
+	if err != nil || connectorCfg.Compression == nil {
+		return connector, err
+	}
+	return NewCompressedConnector(connector, connectorCfg.Compression), nil
+}
+
+func (c *GenericCacheProcessor) createDriverConnector(connectorCfg *config.CacheConnectorConfig) (CacheConnector, error) {
+	switch connectorCfg.Driver {
+	case config.Memory:
+		return NewInMemoryConnector(connectorCfg.Id, connectorCfg.Memory)
+	case config.Redis:
+		return NewRedisConnector(connectorCfg.Id, connectorCfg.Redis, c.storageRegistry)
+	case config.Postgres:
+		return NewPostgresConnector(connectorCfg.Id, connectorCfg.Postgres, c.storageRegistry)
+	case config.Tiered:
+		return c.createTieredConnector(connectorCfg.Id, connectorCfg.Tiered)
+	case config.Disk:
+		return NewDiskConnector(connectorCfg.Id, connectorCfg.Disk)
+	default:
+		return nil, fmt.Errorf("unknown connector driver '%s'", connectorCfg.Driver)
+	}
+}
+
+func (c *GenericCacheProcessor) createTieredConnector(id string, tieredCfg *config.TieredCacheConnectorConfig) (CacheConnector, error) {
+	l1, err := NewInMemoryConnector(id+"-l1", tieredCfg.L1)
+	if err != nil {
+		return nil, err
+	}
+	l2Cfg := *tieredCfg.L2
+	l2Cfg.Id = id + "-l2"
+	l2, err := c.createConnector(&l2Cfg)
+	if err != nil {
+		return nil, err
+	}
+	return NewTieredConnector(id, l1, l2, tieredCfg.L1TTL, tieredCfg.L1.MaxItems)
 }
 
 func (c *GenericCacheProcessor) Store(
@@ -95,27 +221,70 @@ func (c *GenericCacheProcessor) Store(
 	request protocol.RequestHolder,
 	response []byte,
 ) {
-	for _, policy := range c.policies {
-		policy.Store(ctx, chain, request, response)
+	ctx, span := tracing.StartSpan(ctx, "cache.store", tracing.ChainKey.String(chain.String()), tracing.MethodKey.String(request.Method()))
+	defer span.End()
+
+	c.storeByPolicies(chain, func(policy *CachePolicy) bool {
+		return policy.Store(ctx, chain, request, response)
+	})
+}
+
+func (c *GenericCacheProcessor) StoreError(
+	ctx context.Context,
+	chain chains.Chain,
+	request protocol.RequestHolder,
+	responseError []byte,
+) {
+	ctx, span := tracing.StartSpan(ctx, "cache.store-error", tracing.ChainKey.String(chain.String()), tracing.MethodKey.String(request.Method()))
+	defer span.End()
+
+	c.storeByPolicies(chain, func(policy *CachePolicy) bool {
+		return policy.StoreError(ctx, chain, request, responseError)
+	})
+}
+
+func (c *GenericCacheProcessor) storeByPolicies(chain chains.Chain, store func(policy *CachePolicy) bool) {
+	watchReorgs := false
+	for _, policy := range c.state.Load().policies {
+		if store(policy) && policy.reorgAware() {
+			watchReorgs = true
+		}
+	}
+	if watchReorgs {
+		c.watchReorgs(chain)
 	}
 }
 
-func (c *GenericCacheProcessor) Receive(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) ([]byte, bool) {
-	if len(c.policies) == 0 {
+// Receive returns the first fresh response any policy has, a stale one is returned
+// only if no policy has a fresh one, preferring a response that can be served right away
+// over the one served only if upstreams fail
+func (c *GenericCacheProcessor) Receive(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) (*protocol.CachedResponse, bool) {
+	ctx, span := tracing.StartSpan(
+		ctx,
+		"cache.receive",
+		tracing.ChainKey.String(chain.String()),
+		tracing.MethodKey.String(request.Method()),
+		tracing.CacheHitKey.Bool(false),
+	)
+	defer span.End()
+
+	state := c.state.Load()
+	if len(state.policies) == 0 {
 		return nil, false
 	}
 
-	ctx, cancel := context.WithTimeout(ctx, c.receiveTimeout)
+	ctx, cancel := context.WithTimeout(ctx, state.receiveTimeout)
 	defer cancel()
 
-	resultChan := make(chan []byte, len(c.policies))
+	resultChan := make(chan *protocol.CachedResponse, len(state.policies))
 
 	var wg sync.WaitGroup
-	wg.Add(len(c.policies))
-	for _, policy := range c.policies {
+	wg.Add(len(state.policies))
+	for _, policy := range state.policies {
 		go func(p *CachePolicy) {
 			defer wg.Done()
 			if result, ok := p.Receive(ctx, chain, request); ok {
+				result.Policy, result.Connector = p.id, p.connector.Id()
 				resultChan <- result
 			}
 		}(policy)
@@ -127,16 +296,44 @@ func (c *GenericCacheProcessor) Receive(ctx context.Context, chain chains.Chain,
 		wg.Wait()
 		close(resultChan)
 	}()
+	// the streams of the responses received after the one that is returned aren't read
+	defer func() {
+		go func() {
+			for result := range resultChan {
+				result.Close()
+			}
+		}()
+	}()
 
-	select {
-	case <-ctx.Done():
-		return nil, false
-	case result, ok := <-resultChan:
-		if !ok {
+	var staleResult *protocol.CachedResponse
+	for {
+		select {
+		case <-ctx.Done():
+			if staleResult != nil {
+				return staleResult, true
+			}
 			return nil, false
+		case result, ok := <-resultChan:
+			if !ok {
+				if staleResult != nil {
+					return staleResult, true
+				}
+				return nil, false
+			}
+			if result.Stale {
+				if staleResult == nil || (staleResult.ServeStaleOnError && !result.ServeStaleOnError) {
+					staleResult.Close()
+					staleResult = result
+				} else {
+					result.Close()
+				}
+				continue
+			}
+			staleResult.Close()
+			requestCache.WithLabelValues(chain.String(), request.Method()).Inc()
+			span.SetAttributes(tracing.CacheHitKey.Bool(true))
+			cancel()
+			return result, true
 		}
-		requestCache.WithLabelValues(chain.String(), request.Method()).Inc()
-		cancel()
-		return result, true
 	}
 }
@@ -9,9 +9,11 @@ import (
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/storages"
+	"github.com/drpcorg/nodecore/internal/upstreams/flow/subengine"
 	"github.com/drpcorg/nodecore/pkg/chains"
 	"github.com/drpcorg/nodecore/pkg/test_utils"
 	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/drpcorg/nodecore/pkg/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -19,7 +21,7 @@ import (
 func TestCacheProcessorNoPoliciesThenReceiveNothing(t *testing.T) {
 	cacheConfig := memoryCacheConfig(nil, nil)
 	storageRegistry, _ := storages.NewStorageRegistry([]config.AppStorageConfig{})
-	cacheProcessor, err := NewGenericCacheProcessor(nil, cacheConfig, storageRegistry)
+	cacheProcessor, err := NewGenericCacheProcessor(context.Background(), nil, cacheConfig, storageRegistry)
 	assert.NoError(t, err)
 
 	request, _ := protocol.NewInternalUpstreamJsonRpcRequest("method", nil, chains.ALEPHZERO)
@@ -56,6 +58,7 @@ func TestCacheProcessorReceiveFromMatchedConditions(t *testing.T) {
 
 	connector1 := mocks.NewCacheConnectorMock()
 	connector1.On("Receive", mock.Anything, mock.Anything).Return(result, nil)
+	connector1.On("Id").Return("conn-id").Maybe()
 	policy1 := NewCachePolicy(upSupervisor, connector1, test_utils.PolicyConfig("polygon", "eth_*|getLastBlock|synscing", "conn-id", "10KB", "5s", true))
 
 	connector2 := mocks.NewCacheConnectorMock()
@@ -76,7 +79,7 @@ func TestCacheProcessorReceiveFromMatchedConditions(t *testing.T) {
 	upSupervisor.AssertExpectations(t)
 
 	assert.True(t, ok)
-	assert.True(t, bytes.Equal(actual, result))
+	assert.True(t, bytes.Equal(actual.Result, result))
 }
 
 func TestCacheProcessorNoResponseWithTimeoutThenReceiveNothing(t *testing.T) {
@@ -87,14 +90,17 @@ func TestCacheProcessorNoResponseWithTimeoutThenReceiveNothing(t *testing.T) {
 
 	connector1 := mocks.NewDelayedConnector(50 * time.Millisecond)
 	connector1.On("Receive", mock.Anything, mock.Anything).Return(result, nil)
+	connector1.On("Id").Return("conn-id").Maybe()
 	policy1 := NewCachePolicy(upSupervisor, connector1, test_utils.PolicyConfig("polygon", "eth_*|getLastBlock|synscing", "conn-id", "10KB", "5s", true))
 
 	connector2 := mocks.NewDelayedConnector(50 * time.Millisecond)
 	connector2.On("Receive", mock.Anything, mock.Anything).Return(result, nil)
+	connector2.On("Id").Return("conn-id").Maybe()
 	policy2 := NewCachePolicy(upSupervisor, connector2, test_utils.PolicyConfig("ethereum|solana", "*", "conn-id", "10KB", "5s", true))
 
 	connector3 := mocks.NewDelayedConnector(50 * time.Millisecond)
 	connector3.On("Receive", mock.Anything, mock.Anything).Return(result, nil)
+	connector3.On("Id").Return("conn-id").Maybe()
 	policy3 := NewCachePolicy(upSupervisor, connector3, test_utils.PolicyConfig("gnosis|polygon", "eth_call", "conn-id", "10KB", "5s", true))
 
 	cacheProcessor := createCacheProcessor([]*CachePolicy{policy2, policy3, policy1}, 10*time.Millisecond)
@@ -116,14 +122,17 @@ func TestCacheProcessorReturnFirstResponseAndIgnoreOthers(t *testing.T) {
 
 	connector1 := mocks.NewDelayedConnector(30 * time.Millisecond)
 	connector1.On("Receive", mock.Anything, mock.Anything).Return(result, nil).Maybe()
+	connector1.On("Id").Return("conn-id").Maybe()
 	policy1 := NewCachePolicy(upSupervisor, connector1, test_utils.PolicyConfig("polygon", "eth_*|getLastBlock|synscing", "conn-id", "10KB", "5s", true))
 
 	connector2 := mocks.NewDelayedConnector(0)
 	connector2.On("Receive", mock.Anything, mock.Anything).Return(result, nil).Maybe()
+	connector2.On("Id").Return("conn-id").Maybe()
 	policy2 := NewCachePolicy(upSupervisor, connector2, test_utils.PolicyConfig("polygon|solana", "*", "conn-id", "10KB", "5s", true))
 
 	connector3 := mocks.NewDelayedConnector(0)
 	connector3.On("Receive", mock.Anything, mock.Anything).Return(result, nil).Maybe()
+	connector3.On("Id").Return("conn-id").Maybe()
 	policy3 := NewCachePolicy(upSupervisor, connector3, test_utils.PolicyConfig("gnosis|polygon", "eth_call", "conn-id", "10KB", "5s", true))
 
 	cacheProcessor := createCacheProcessor([]*CachePolicy{policy2, policy3, policy1}, 100*time.Millisecond)
@@ -140,7 +149,7 @@ func TestCacheProcessorReturnFirstResponseAndIgnoreOthers(t *testing.T) {
 	methodsMock.AssertExpectations(t)
 
 	assert.True(t, ok)
-	assert.True(t, bytes.Equal(actual, result))
+	assert.True(t, bytes.Equal(actual.Result, result))
 }
 
 func TestCacheProcessorNoResponseFromConnectorsThenNothing(t *testing.T) {
@@ -176,10 +185,73 @@ func TestCacheProcessorNoResponseFromConnectorsThenNothing(t *testing.T) {
 	assert.Nil(t, actual)
 }
 
+func TestCacheProcessorFreshResponseOverStaleOne(t *testing.T) {
+	methodsMock, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	specMethod := test_utils.CacheableMethod("eth_call")
+	staleObject := []byte(encodeStaleObject([]byte(`stale`), time.Now().Add(-time.Second)))
+
+	staleConnector := mocks.NewDelayedConnector(0)
+	staleConnector.On("Receive", mock.Anything, mock.Anything).Return(staleObject, nil)
+	staleConnector.On("Id").Return("conn-id").Maybe()
+	staleCfg := test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "5s", true)
+	staleCfg.StaleTTL = "1m"
+	stalePolicy := NewCachePolicy(upSupervisor, staleConnector, staleCfg)
+
+	freshConnector := mocks.NewDelayedConnector(20 * time.Millisecond)
+	freshConnector.On("Receive", mock.Anything, mock.Anything).Return([]byte(`fresh`), nil)
+	freshConnector.On("Id").Return("fresh-conn-id")
+	freshCfg := test_utils.PolicyConfig("polygon", "*", "fresh-conn-id", "10KB", "5s", true)
+	freshCfg.Id = "fresh"
+	freshPolicy := NewCachePolicy(upSupervisor, freshConnector, freshCfg)
+
+	cacheProcessor := createCacheProcessor([]*CachePolicy{stalePolicy, freshPolicy}, 100*time.Millisecond)
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("eth_call", nil, specMethod)
+
+	actual, ok := cacheProcessor.Receive(context.Background(), chains.POLYGON, request)
+
+	methodsMock.AssertExpectations(t)
+	upSupervisor.AssertExpectations(t)
+
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: []byte(`fresh`), Policy: "fresh", Connector: "fresh-conn-id"}, actual)
+}
+
+func TestCacheProcessorOnlyStaleResponseThenReceiveStale(t *testing.T) {
+	methodsMock, upSupervisor := test_utils.GetMethodMockAndUpSupervisor()
+	specMethod := test_utils.CacheableMethod("eth_call")
+	staleObject := []byte(encodeStaleObject([]byte(`stale`), time.Now().Add(-time.Second)))
+
+	staleConnector := mocks.NewDelayedConnector(0)
+	staleConnector.On("Receive", mock.Anything, mock.Anything).Return(staleObject, nil)
+	staleConnector.On("Id").Return("conn-id").Maybe()
+	staleCfg := test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "5s", true)
+	staleCfg.StaleIfErrorTTL = "1m"
+	staleCfg.Id = "stale"
+	stalePolicy := NewCachePolicy(upSupervisor, staleConnector, staleCfg)
+
+	missConnector := mocks.NewDelayedConnector(0)
+	missConnector.On("Receive", mock.Anything, mock.Anything).Return([]byte{}, ErrCacheNotFound)
+	missConnector.On("Id").Return("id")
+	missPolicy := NewCachePolicy(upSupervisor, missConnector, test_utils.PolicyConfig("polygon", "*", "conn-id", "10KB", "5s", true))
+
+	cacheProcessor := createCacheProcessor([]*CachePolicy{stalePolicy, missPolicy}, 100*time.Millisecond)
+	request, _ := protocol.NewUpstreamJsonRpcRequestWithSpecMethod("eth_call", nil, specMethod)
+
+	actual, ok := cacheProcessor.Receive(context.Background(), chains.POLYGON, request)
+
+	methodsMock.AssertExpectations(t)
+	upSupervisor.AssertExpectations(t)
+
+	assert.True(t, ok)
+	assert.Equal(t, &protocol.CachedResponse{Result: []byte(`stale`), Stale: true, ServeStaleOnError: true, Policy: "stale", Connector: "conn-id"}, actual)
+}
+
 func createCacheProcessor(policies []*CachePolicy, timeout time.Duration) CacheProcessor {
+	state := utils.NewAtomic[*cacheProcessorState]()
+	state.Store(&cacheProcessorState{policies: policies, receiveTimeout: timeout})
 	return &GenericCacheProcessor{
-		policies:       policies,
-		receiveTimeout: timeout,
+		ctx:   context.Background(),
+		state: state,
 	}
 }
 
@@ -190,3 +262,149 @@ func memoryCacheConfig(connectors []*config.CacheConnectorConfig, policies []*co
 		CachePolicies:   policies,
 	}
 }
+
+func TestCacheProcessorUpdateKeepsUnchangedConnectors(t *testing.T) {
+	storageRegistry, _ := storages.NewStorageRegistry([]config.AppStorageConfig{})
+	memoryConnector := func(id string, maxItems int) *config.CacheConnectorConfig {
+		return &config.CacheConnectorConfig{
+			Id:     id,
+			Driver: config.Memory,
+			Memory: &config.MemoryCacheConnectorConfig{MaxItems: maxItems, ExpiredRemoveInterval: time.Minute},
+		}
+	}
+	cacheConfig := memoryCacheConfig(
+		[]*config.CacheConnectorConfig{memoryConnector("memory-1", 100), memoryConnector("memory-2", 100)},
+		[]*config.CachePolicyConfig{test_utils.PolicyConfig("polygon", "*", "memory-1", "10KB", "5s", true)},
+	)
+	cacheProcessor, err := NewGenericCacheProcessor(context.Background(), nil, cacheConfig, storageRegistry)
+	assert.NoError(t, err)
+
+	keptConnector := cacheProcessor.connectors["memory-1"].connector
+	changedConnector := cacheProcessor.connectors["memory-2"].connector
+
+	newCacheConfig := memoryCacheConfig(
+		[]*config.CacheConnectorConfig{memoryConnector("memory-1", 100), memoryConnector("memory-2", 200)},
+		[]*config.CachePolicyConfig{
+			test_utils.PolicyConfig("polygon", "*", "memory-1", "10KB", "5s", true),
+			test_utils.PolicyConfig("ethereum", "*", "memory-2", "10KB", "5s", true),
+		},
+	)
+	newCacheConfig.ReceiveTimeout = 2 * time.Second
+	err = cacheProcessor.Update(newCacheConfig)
+	assert.NoError(t, err)
+
+	state := cacheProcessor.state.Load()
+	assert.Len(t, state.policies, 2)
+	assert.Equal(t, 2*time.Second, state.receiveTimeout)
+	assert.Same(t, keptConnector, cacheProcessor.connectors["memory-1"].connector)
+	assert.NotSame(t, changedConnector, cacheProcessor.connectors["memory-2"].connector)
+}
+
+func TestCacheProcessorUpdateDiskConnectorKeepsStoredObjects(t *testing.T) {
+	storageRegistry, _ := storages.NewStorageRegistry([]config.AppStorageConfig{})
+	directory := t.TempDir()
+	diskConnector := func(maxSize string) *config.CacheConnectorConfig {
+		return &config.CacheConnectorConfig{
+			Id:     "disk",
+			Driver: config.Disk,
+			Disk:   &config.DiskCacheConnectorConfig{Directory: directory, MaxSize: maxSize, ExpiredRemoveInterval: time.Minute},
+		}
+	}
+	policies := []*config.CachePolicyConfig{test_utils.PolicyConfig("polygon", "*", "disk", "10KB", "5s", true)}
+	cacheProcessor, err := NewGenericCacheProcessor(context.Background(), nil, memoryCacheConfig([]*config.CacheConnectorConfig{diskConnector("1MB")}, policies), storageRegistry)
+	assert.NoError(t, err)
+
+	err = cacheProcessor.connectors["disk"].connector.Store(context.Background(), "key", "object", 0)
+	assert.NoError(t, err)
+
+	err = cacheProcessor.Update(memoryCacheConfig([]*config.CacheConnectorConfig{diskConnector("2MB")}, policies))
+	assert.NoError(t, err)
+	defer cacheProcessor.connectors["disk"].connector.Close()
+
+	object, err := cacheProcessor.connectors["disk"].connector.Receive(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("object"), object)
+}
+
+func TestCacheProcessorUpdateWithInvalidConnectorThenKeepCurrentPolicies(t *testing.T) {
+	storageRegistry, _ := storages.NewStorageRegistry([]config.AppStorageConfig{})
+	cacheConfig := memoryCacheConfig(
+		[]*config.CacheConnectorConfig{
+			{Id: "memory", Driver: config.Memory, Memory: &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute}},
+		},
+		[]*config.CachePolicyConfig{test_utils.PolicyConfig("polygon", "*", "memory", "10KB", "5s", true)},
+	)
+	cacheProcessor, err := NewGenericCacheProcessor(context.Background(), nil, cacheConfig, storageRegistry)
+	assert.NoError(t, err)
+	currentState := cacheProcessor.state.Load()
+
+	newCacheConfig := memoryCacheConfig(
+		[]*config.CacheConnectorConfig{
+			{Id: "redis", Driver: config.Redis, Redis: &config.RedisCacheConnectorConfig{StorageName: "unknown"}},
+		},
+		[]*config.CachePolicyConfig{test_utils.PolicyConfig("polygon", "*", "redis", "10KB", "5s", true)},
+	)
+	err = cacheProcessor.Update(newCacheConfig)
+
+	assert.Error(t, err)
+	assert.Same(t, currentState, cacheProcessor.state.Load())
+	assert.Contains(t, cacheProcessor.connectors, "memory")
+}
+
+func TestCacheProcessorPrepareUpdateThenDiscardKeepsCurrentState(t *testing.T) {
+	storageRegistry, _ := storages.NewStorageRegistry([]config.AppStorageConfig{})
+	memoryConnector := func(maxItems int) *config.CacheConnectorConfig {
+		return &config.CacheConnectorConfig{
+			Id:     "memory",
+			Driver: config.Memory,
+			Memory: &config.MemoryCacheConnectorConfig{MaxItems: maxItems, ExpiredRemoveInterval: time.Minute},
+		}
+	}
+	policies := []*config.CachePolicyConfig{test_utils.PolicyConfig("polygon", "*", "memory", "10KB", "5s", true)}
+	cacheProcessor, err := NewGenericCacheProcessor(context.Background(), nil, memoryCacheConfig([]*config.CacheConnectorConfig{memoryConnector(100)}, policies), storageRegistry)
+	assert.NoError(t, err)
+	currentState := cacheProcessor.state.Load()
+	currentConnector := cacheProcessor.connectors["memory"].connector
+
+	update, err := cacheProcessor.PrepareUpdate(memoryCacheConfig([]*config.CacheConnectorConfig{memoryConnector(200)}, policies))
+	assert.NoError(t, err)
+
+	assert.Same(t, currentState, cacheProcessor.state.Load())
+	assert.Same(t, currentConnector, cacheProcessor.connectors["memory"].connector)
+
+	update.Discard()
+
+	assert.Same(t, currentState, cacheProcessor.state.Load())
+	assert.Same(t, currentConnector, cacheProcessor.connectors["memory"].connector)
+}
+
+func TestCacheProcessorRemoveDroppedBlocksOncePerConnector(t *testing.T) {
+	connector := mocks.NewCacheConnectorMock()
+	connector.On("Id").Return("conn-id")
+	connector.On("RemoveHeights", mock.Anything, chains.POLYGON, uint64(100), uint64(102)).Return(nil).Once()
+	finalizedConnector := mocks.NewCacheConnectorMock()
+	finalizedConnector.On("Id").Return("finalized-conn-id")
+
+	cacheProcessor := createCacheProcessor(
+		[]*CachePolicy{
+			NewCachePolicy(nil, connector, test_utils.PolicyConfig("polygon", "eth_call", "conn-id", "10KB", "5s", true)),
+			NewCachePolicy(nil, connector, test_utils.PolicyConfig("polygon", "eth_getLogs", "conn-id", "10KB", "5s", true)),
+			NewCachePolicy(nil, finalizedConnector, test_utils.PolicyConfigFinalized("polygon", "*", "finalized-conn-id", "10KB", "5s", true)),
+		},
+		1*time.Minute,
+	).(*GenericCacheProcessor)
+
+	updates := make(chan subengine.BlockUpdate, 10)
+	updates <- subengine.BlockUpdate{Block: protocol.NewBlockWithHeight(101), Kind: subengine.BlockNew}
+	updates <- subengine.BlockUpdate{Block: protocol.NewBlockWithHeight(102), Kind: subengine.BlockDrop}
+	updates <- subengine.BlockUpdate{Block: protocol.NewBlockWithHeight(101), Kind: subengine.BlockDrop}
+	updates <- subengine.BlockUpdate{Block: protocol.NewBlockWithHeight(100), Kind: subengine.BlockDrop}
+	updates <- subengine.BlockUpdate{Block: protocol.NewBlockWithHeight(100), Kind: subengine.BlockNew}
+	updates <- subengine.BlockUpdate{Block: protocol.NewBlockWithHeight(101), Kind: subengine.BlockNew}
+	close(updates)
+
+	cacheProcessor.processBlockUpdates(chains.POLYGON, updates)
+
+	connector.AssertExpectations(t)
+	finalizedConnector.AssertNotCalled(t, "RemoveHeights")
+}
@@ -0,0 +1,80 @@
+package caches
+
+import (
+	"github.com/drpcorg/nodecore/internal/upstreams/flow/subengine"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/rs/zerolog/log"
+)
+
+// watchReorgs starts tracking the block updates of the chain unless they are already tracked.
+// It's started lazily on the first store of a policy with not finalized data, since chains
+// appear along with their upstreams
+func (c *GenericCacheProcessor) watchReorgs(chain chains.Chain) {
+	if c.upstreamSupervisor == nil {
+		return
+	}
+	if _, loaded := c.reorgWatchers.LoadOrStore(chain, struct{}{}); loaded {
+		return
+	}
+	chainSupervisor := c.upstreamSupervisor.GetChainSupervisor(chain)
+	if chainSupervisor == nil {
+		c.reorgWatchers.Delete(chain)
+		return
+	}
+
+	updates := make(chan subengine.BlockUpdate, 100)
+	go subengine.StreamBlockUpdates(c.ctx, chainSupervisor, updates)
+	go func() {
+		// the stream is over if the chain is gone, so it can be watched again once it's back
+		defer c.reorgWatchers.Delete(chain)
+		c.processBlockUpdates(chain, updates)
+	}()
+}
+
+// processBlockUpdates removes cached responses of the dropped blocks. A reorg comes as a series
+// of dropped blocks followed by the new canonical block, so the drops are removed at once
+func (c *GenericCacheProcessor) processBlockUpdates(chain chains.Chain, updates <-chan subengine.BlockUpdate) {
+	var fromHeight, toHeight uint64
+	dropped := false
+
+	for update := range updates {
+		switch update.Kind {
+		case subengine.BlockDrop:
+			if !dropped || update.Block.Height < fromHeight {
+				fromHeight = update.Block.Height
+			}
+			if !dropped || update.Block.Height > toHeight {
+				toHeight = update.Block.Height
+			}
+			dropped = true
+		case subengine.BlockNew:
+			if dropped {
+				c.removeHeights(chain, fromHeight, toHeight)
+				dropped = false
+			}
+		}
+	}
+	if dropped {
+		c.removeHeights(chain, fromHeight, toHeight)
+	}
+}
+
+func (c *GenericCacheProcessor) removeHeights(chain chains.Chain, fromHeight, toHeight uint64) {
+	log.Info().Msgf("reorg on %s, removing cached responses of blocks %d-%d", chain, fromHeight, toHeight)
+
+	removed := make(map[string]struct{})
+	for _, policy := range c.state.Load().policies {
+		if !policy.reorgAware() {
+			continue
+		}
+		// several policies might share one connector
+		if _, ok := removed[policy.connector.Id()]; ok {
+			continue
+		}
+		removed[policy.connector.Id()] = struct{}{}
+
+		if err := policy.connector.RemoveHeights(c.ctx, chain, fromHeight, toHeight); err != nil {
+			log.Error().Err(err).Msgf("connector %s couldn't remove reorged blocks %d-%d of %s", policy.connector.Id(), fromHeight, toHeight, chain)
+		}
+	}
+}
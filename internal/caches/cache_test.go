@@ -9,6 +9,8 @@ import (
 
 	"github.com/drpcorg/nodecore/internal/caches"
 	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils/e2e"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -121,3 +123,61 @@ func TestInMemoryCacheStoreThenReceiveWithTtl(t *testing.T) {
 	assert.Nil(t, object)
 	assert.True(t, errors.Is(err, caches.ErrCacheNotFound))
 }
+
+func TestInMemoryCacheStoreAtHeightThenRemoveHeights(t *testing.T) {
+	inMemory, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 1000, ExpiredRemoveInterval: 1 * time.Minute})
+	assert.NoError(t, err)
+
+	e2e.TestConnectorStoreAtHeightThenRemoveHeights(t, inMemory)
+}
+
+func TestInMemoryCacheScanThenRemove(t *testing.T) {
+	inMemory, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 1000, ExpiredRemoveInterval: 1 * time.Minute})
+	assert.NoError(t, err)
+
+	e2e.TestConnectorScanThenRemove(t, inMemory)
+}
+
+func TestInMemoryCacheScanSkipsExpiredItems(t *testing.T) {
+	inMemory, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 1000, ExpiredRemoveInterval: 1 * time.Minute})
+	assert.NoError(t, err)
+
+	err = inMemory.Store(context.Background(), "expired", "item", time.Millisecond)
+	assert.NoError(t, err)
+	err = inMemory.Store(context.Background(), "alive", "item", time.Minute)
+	assert.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	keys := make([]string, 0)
+	err = inMemory.Scan(context.Background(), "", func(key string, _ int) error {
+		keys = append(keys, key)
+		return nil
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"alive"}, keys)
+}
+
+func TestInMemoryCacheRemoveHeightsAfterEviction(t *testing.T) {
+	inMemory, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 1, ExpiredRemoveInterval: 1 * time.Minute})
+	assert.NoError(t, err)
+
+	err = inMemory.Initialize()
+	assert.NoError(t, err)
+
+	err = inMemory.StoreAtHeight(context.Background(), "key1", "object", 0, chains.ETHEREUM, 100)
+	assert.Nil(t, err)
+	// evicts key1 from the cache
+	err = inMemory.StoreAtHeight(context.Background(), "key2", "object", 0, chains.ETHEREUM, 100)
+	assert.Nil(t, err)
+	err = inMemory.Store(context.Background(), "key1", "plain", 0)
+	assert.Nil(t, err)
+
+	err = inMemory.RemoveHeights(context.Background(), chains.ETHEREUM, 100, 100)
+	assert.Nil(t, err)
+
+	// key1 is not at the height anymore, so it isn't removed
+	object, err := inMemory.Receive(context.Background(), "key1")
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("plain"), object)
+}
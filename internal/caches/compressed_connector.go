@@ -0,0 +1,271 @@
+package caches
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// compressedObjectMagic starts a compressed object, it can't start a JSON document or a stale object.
+// It's followed by the format version, the algorithm, the encoding and the compressed object itself.
+// Objects without the header are stored as is, so the ones stored before the compression
+// had been enabled or smaller than the threshold stay readable.
+const compressedObjectMagic = "\x1encz"
+
+const compressedObjectVersion byte = '1'
+
+const (
+	zstdAlgorithm byte = 'z'
+	gzipAlgorithm byte = 'g'
+)
+
+const (
+	binaryEncoding byte = 'b'
+	// base64Encoding is used for connectors that can store only valid text
+	base64Encoding byte = '6'
+)
+
+// textStorage is implemented by connectors that can't store arbitrary bytes
+type textStorage interface {
+	textOnly() bool
+}
+
+func isTextOnly(connector CacheConnector) bool {
+	storage, ok := connector.(textStorage)
+	return ok && storage.textOnly()
+}
+
+// streamStorage is implemented by connectors that can return an object as a stream, the stream has to be closed
+// if it isn't read to the end
+type streamStorage interface {
+	ReceiveStream(ctx context.Context, key string) (io.ReadCloser, error)
+}
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedDefault))
+	zstdDecoders   sync.Pool
+
+	gzipWriters = sync.Pool{New: func() any { return gzip.NewWriter(nil) }}
+	gzipReaders sync.Pool
+)
+
+// CompressedConnector compresses the objects of another connector that are not smaller than minSize
+type CompressedConnector struct {
+	CacheConnector
+	algorithm byte
+	encoding  byte
+	minSize   int
+}
+
+func NewCompressedConnector(connector CacheConnector, compressionConfig *config.CacheCompressionConfig) *CompressedConnector {
+	algorithm := zstdAlgorithm
+	if compressionConfig.Algorithm == config.Gzip {
+		algorithm = gzipAlgorithm
+	}
+	encoding := binaryEncoding
+	if isTextOnly(connector) {
+		encoding = base64Encoding
+	}
+	return &CompressedConnector{
+		CacheConnector: connector,
+		algorithm:      algorithm,
+		encoding:       encoding,
+		minSize:        int(maxSizeInBytes(compressionConfig.MinSize)),
+	}
+}
+
+func (c *CompressedConnector) Store(ctx context.Context, key string, object string, ttl time.Duration) error {
+	return c.CacheConnector.Store(ctx, key, c.compress(object), ttl)
+}
+
+func (c *CompressedConnector) StoreAtHeight(ctx context.Context, key string, object string, ttl time.Duration, chain chains.Chain, height uint64) error {
+	return c.CacheConnector.StoreAtHeight(ctx, key, c.compress(object), ttl, chain, height)
+}
+
+func (c *CompressedConnector) Receive(ctx context.Context, key string) ([]byte, error) {
+	object, err := c.CacheConnector.Receive(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return decompressAll(object)
+}
+
+// ReceiveStream returns a reader decompressing the object while it's read, so a large response
+// is written to the client without being decompressed into memory first, see streamStorage
+func (c *CompressedConnector) ReceiveStream(ctx context.Context, key string) (io.ReadCloser, error) {
+	object, err := c.CacheConnector.Receive(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return decompressObject(object)
+}
+
+// ReceiveWithTTL passes the ttl of the wrapped connector through, see ttlStorage
+func (c *CompressedConnector) ReceiveWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	object, ttl, err := receiveWithTTL(ctx, c.CacheConnector, key)
+	if err != nil {
+		return nil, 0, err
+	}
+	object, err = decompressAll(object)
+	return object, ttl, err
+}
+
+// compress returns the compressed object with the header, or the object itself if it's
+// smaller than the threshold or doesn't get smaller
+func (c *CompressedConnector) compress(object string) string {
+	if len(object) < c.minSize {
+		return object
+	}
+	var compressed []byte
+	switch c.algorithm {
+	case zstdAlgorithm:
+		compressed = zstdEncoder.EncodeAll([]byte(object), nil)
+	case gzipAlgorithm:
+		var buf bytes.Buffer
+		writer := gzipWriters.Get().(*gzip.Writer)
+		writer.Reset(&buf)
+		_, err := io.WriteString(writer, object)
+		if err == nil {
+			err = writer.Close()
+		}
+		gzipWriters.Put(writer)
+		if err != nil {
+			return object
+		}
+		compressed = buf.Bytes()
+	}
+
+	var result strings.Builder
+	result.WriteString(compressedObjectMagic)
+	result.WriteByte(compressedObjectVersion)
+	result.WriteByte(c.algorithm)
+	result.WriteByte(c.encoding)
+	if c.encoding == base64Encoding {
+		result.WriteString(base64.StdEncoding.EncodeToString(compressed))
+	} else {
+		result.Write(compressed)
+	}
+
+	if result.Len() >= len(object) {
+		return object
+	}
+	return result.String()
+}
+
+// decompressAll returns the decompressed object, objects without the compression header are returned as is
+func decompressAll(object []byte) ([]byte, error) {
+	if !bytes.HasPrefix(object, []byte(compressedObjectMagic)) {
+		return object, nil
+	}
+	reader, err := decompressObject(object)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = reader.Close() }()
+	return io.ReadAll(reader)
+}
+
+// decompressObject returns a reader decompressing an object with the compression header while it's read,
+// other objects are read as is. The algorithm is read from the header, so objects compressed with another
+// algorithm stay readable.
+func decompressObject(object []byte) (io.ReadCloser, error) {
+	if !bytes.HasPrefix(object, []byte(compressedObjectMagic)) {
+		return io.NopCloser(bytes.NewReader(object)), nil
+	}
+	header := object[len(compressedObjectMagic):]
+	if len(header) < 3 {
+		return nil, fmt.Errorf("corrupted compressed object")
+	}
+	if header[0] != compressedObjectVersion {
+		return nil, fmt.Errorf("unknown compressed object version '%c'", header[0])
+	}
+	var payload io.Reader = bytes.NewReader(header[3:])
+	switch header[2] {
+	case binaryEncoding:
+	case base64Encoding:
+		payload = base64.NewDecoder(base64.StdEncoding, payload)
+	default:
+		return nil, fmt.Errorf("unknown compressed object encoding '%c'", header[2])
+	}
+
+	switch header[1] {
+	case zstdAlgorithm:
+		return unzstd(payload)
+	case gzipAlgorithm:
+		return gunzip(payload)
+	default:
+		return nil, fmt.Errorf("unknown compression algorithm '%c'", header[1])
+	}
+}
+
+func unzstd(payload io.Reader) (io.ReadCloser, error) {
+	decoder, ok := zstdDecoders.Get().(*zstd.Decoder)
+	if !ok {
+		var err error
+		// a decoder with the concurrency of 1 decodes a stream synchronously, without goroutines of its own
+		if decoder, err = zstd.NewReader(nil, zstd.WithDecoderConcurrency(1)); err != nil {
+			return nil, err
+		}
+	}
+	if err := decoder.Reset(payload); err != nil {
+		return nil, err
+	}
+	return &pooledReader{reader: decoder, release: func() {
+		_ = decoder.Reset(nil)
+		zstdDecoders.Put(decoder)
+	}}, nil
+}
+
+func gunzip(payload io.Reader) (io.ReadCloser, error) {
+	var reader *gzip.Reader
+	if pooled, ok := gzipReaders.Get().(*gzip.Reader); ok {
+		reader = pooled
+		if err := reader.Reset(payload); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if reader, err = gzip.NewReader(payload); err != nil {
+			return nil, err
+		}
+	}
+	return &pooledReader{reader: reader, release: func() { gzipReaders.Put(reader) }}, nil
+}
+
+// pooledReader gives its decompressor back to the pool once the object is read to the end, fails or is closed
+type pooledReader struct {
+	reader  io.Reader
+	release func()
+}
+
+func (r *pooledReader) Read(p []byte) (int, error) {
+	if r.reader == nil {
+		return 0, io.EOF
+	}
+	n, err := r.reader.Read(p)
+	if err != nil {
+		_ = r.Close()
+	}
+	return n, err
+}
+
+func (r *pooledReader) Close() error {
+	if r.reader != nil {
+		r.reader = nil
+		r.release()
+	}
+	return nil
+}
+
+var _ CacheConnector = (*CompressedConnector)(nil)
+var _ streamStorage = (*CompressedConnector)(nil)
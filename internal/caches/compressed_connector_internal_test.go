@@ -0,0 +1,75 @@
+package caches
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// textMemoryConnector is a memory connector that can store only valid text, like the postgres one
+type textMemoryConnector struct {
+	*InMemoryConnector
+}
+
+func (t *textMemoryConnector) textOnly() bool {
+	return true
+}
+
+func TestCompressedConnectorTextOnlyConnectorThenBase64(t *testing.T) {
+	memory, err := NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	require.NoError(t, err)
+	connector := NewCompressedConnector(&textMemoryConnector{memory}, &config.CacheCompressionConfig{Algorithm: config.Zstd, MinSize: "1KB"})
+	object := `[` + strings.Repeat(`"0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060",`, 100) + `"0x"]`
+
+	err = connector.Store(context.Background(), "key", object, time.Minute)
+	require.NoError(t, err)
+
+	stored, err := memory.Receive(context.Background(), "key")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(stored), "\x1encz1z6"))
+	assert.True(t, utf8.Valid(stored))
+	assert.NotContains(t, string(stored), "\x00")
+
+	received, err := connector.Receive(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, object, string(received))
+}
+
+func TestTieredConnectorTextOnlyByL2(t *testing.T) {
+	l1, err := NewInMemoryConnector("l1", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	require.NoError(t, err)
+	l2, err := NewInMemoryConnector("l2", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	require.NoError(t, err)
+
+	binaryTiered, err := NewTieredConnector("tiered", l1, l2, time.Minute, 100)
+	require.NoError(t, err)
+	textTiered, err := NewTieredConnector("tiered", l1, &textMemoryConnector{l2}, time.Minute, 100)
+	require.NoError(t, err)
+
+	assert.False(t, isTextOnly(binaryTiered))
+	assert.True(t, isTextOnly(textTiered))
+	assert.True(t, isTextOnly(&PostgresConnector{}))
+}
+
+func TestCompressedConnectorTextOnlyConnectorThenReceiveStream(t *testing.T) {
+	memory, err := NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	require.NoError(t, err)
+	connector := NewCompressedConnector(&textMemoryConnector{memory}, &config.CacheCompressionConfig{Algorithm: config.Gzip, MinSize: "1KB"})
+	object := `[` + strings.Repeat(`"0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060",`, 100) + `"0x"]`
+
+	err = connector.Store(context.Background(), "key", object, time.Minute)
+	require.NoError(t, err)
+
+	stream, err := connector.ReceiveStream(context.Background(), "key")
+	require.NoError(t, err)
+	received, err := io.ReadAll(stream)
+	require.NoError(t, err)
+	assert.Equal(t, object, string(received))
+}
@@ -0,0 +1,147 @@
+package caches_test
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCompressedMemoryConnector(t *testing.T, algorithm config.CompressionAlgorithm) (*caches.CompressedConnector, *caches.InMemoryConnector) {
+	memory, err := caches.NewInMemoryConnector("id", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	require.NoError(t, err)
+	return caches.NewCompressedConnector(memory, &config.CacheCompressionConfig{Algorithm: algorithm, MinSize: "1KB"}), memory
+}
+
+func bigObject() string {
+	return `{"transactions":[` + strings.Repeat(`{"hash":"0x5c504ed432cb51138bcf09aa5e8a410dd4a1e204ef84bfed1be16dfba1b22060"},`, 100) + `{}]}`
+}
+
+func TestCompressedConnectorRoundTrip(t *testing.T) {
+	for _, algorithm := range []config.CompressionAlgorithm{config.Zstd, config.Gzip} {
+		t.Run(string(algorithm), func(te *testing.T) {
+			connector, memory := newCompressedMemoryConnector(te, algorithm)
+			object := bigObject()
+
+			err := connector.Store(context.Background(), "key", object, time.Minute)
+			require.NoError(te, err)
+			err = connector.StoreAtHeight(context.Background(), "key-at-height", object, time.Minute, chains.POLYGON, 100)
+			require.NoError(te, err)
+
+			stored, err := memory.Receive(context.Background(), "key")
+			require.NoError(te, err)
+			assert.True(te, strings.HasPrefix(string(stored), "\x1encz1"))
+			assert.Less(te, len(stored), len(object))
+
+			received, err := connector.Receive(context.Background(), "key")
+			require.NoError(te, err)
+			assert.Equal(te, object, string(received))
+
+			received, err = connector.Receive(context.Background(), "key-at-height")
+			require.NoError(te, err)
+			assert.Equal(te, object, string(received))
+		})
+	}
+}
+
+func TestCompressedConnectorSmallObjectStoredAsIs(t *testing.T) {
+	connector, memory := newCompressedMemoryConnector(t, config.Zstd)
+
+	err := connector.Store(context.Background(), "key", `"0x1"`, time.Minute)
+	require.NoError(t, err)
+
+	stored, err := memory.Receive(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, `"0x1"`, string(stored))
+
+	received, err := connector.Receive(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, `"0x1"`, string(received))
+}
+
+func TestCompressedConnectorReadsObjectsStoredBefore(t *testing.T) {
+	connector, memory := newCompressedMemoryConnector(t, config.Zstd)
+	gzipConnector := caches.NewCompressedConnector(memory, &config.CacheCompressionConfig{Algorithm: config.Gzip, MinSize: "1KB"})
+	object := bigObject()
+
+	// an object stored before the compression had been enabled
+	err := memory.Store(context.Background(), "raw", object, time.Minute)
+	require.NoError(t, err)
+	// an object stored with another algorithm
+	err = gzipConnector.Store(context.Background(), "gzip", object, time.Minute)
+	require.NoError(t, err)
+
+	received, err := connector.Receive(context.Background(), "raw")
+	require.NoError(t, err)
+	assert.Equal(t, object, string(received))
+
+	received, err = connector.Receive(context.Background(), "gzip")
+	require.NoError(t, err)
+	assert.Equal(t, object, string(received))
+}
+
+func TestCompressedConnectorUnknownVersionThenError(t *testing.T) {
+	connector, memory := newCompressedMemoryConnector(t, config.Zstd)
+
+	err := memory.Store(context.Background(), "key", "\x1encz9zbpayload", time.Minute)
+	require.NoError(t, err)
+
+	received, err := connector.Receive(context.Background(), "key")
+
+	assert.Nil(t, received)
+	assert.ErrorContains(t, err, "unknown compressed object version '9'")
+}
+
+func TestCompressedConnectorReceiveStream(t *testing.T) {
+	for _, algorithm := range []config.CompressionAlgorithm{config.Zstd, config.Gzip} {
+		t.Run(string(algorithm), func(te *testing.T) {
+			connector, memory := newCompressedMemoryConnector(te, algorithm)
+			object := bigObject()
+
+			err := connector.Store(context.Background(), "key", object, time.Minute)
+			require.NoError(te, err)
+			err = memory.Store(context.Background(), "raw", object, time.Minute)
+			require.NoError(te, err)
+
+			for _, key := range []string{"key", "key", "raw"} {
+				stream, err := connector.ReceiveStream(context.Background(), key)
+				require.NoError(te, err)
+				received, err := io.ReadAll(stream)
+				require.NoError(te, err)
+				assert.Equal(te, object, string(received))
+				assert.NoError(te, stream.Close())
+			}
+		})
+	}
+}
+
+func TestCompressedConnectorReceiveStreamClosedBeforeEndThenReadsNothing(t *testing.T) {
+	connector, _ := newCompressedMemoryConnector(t, config.Gzip)
+	object := bigObject()
+
+	err := connector.Store(context.Background(), "key", object, time.Minute)
+	require.NoError(t, err)
+
+	stream, err := connector.ReceiveStream(context.Background(), "key")
+	require.NoError(t, err)
+	buf := make([]byte, 10)
+	_, err = io.ReadFull(stream, buf)
+	require.NoError(t, err)
+	assert.Equal(t, object[:10], string(buf))
+
+	require.NoError(t, stream.Close())
+	n, err := stream.Read(buf)
+	assert.Zero(t, n)
+	assert.ErrorIs(t, err, io.EOF)
+
+	received, err := connector.Receive(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, object, string(received))
+}
@@ -0,0 +1,452 @@
+package caches
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/rs/zerolog/log"
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	diskDbFile        = "cache.db"
+	diskOpenTimeout   = 5 * time.Second
+	diskItemHeaderLen = 18
+)
+
+var (
+	// itemsBucket maps a key to its item - expireAt(8) + seq(8) + heightKeyLen(2) + heightKey + object
+	itemsBucket = []byte("items")
+	// orderBucket maps the write sequence of an item to its key, the first item is the oldest one
+	orderBucket = []byte("order")
+	// heightsBucket indexes the keys of items stored at a height - chain + 0 + height(8) + key
+	heightsBucket = []byte("heights")
+	// metaBucket keeps the total size of the stored items
+	metaBucket = []byte("meta")
+	sizeKey    = []byte("size")
+
+	errDiskConnectorNotInitialized = errors.New("disk cache connector isn't initialized")
+)
+
+// DiskConnector keeps objects in an embedded B+tree database file, every write is committed
+// and synced to disk before it's acknowledged, so the objects survive restarts.
+// Once the stored objects exceed the max size, the oldest written ones are evicted
+type DiskConnector struct {
+	id                    string
+	path                  string
+	maxSize               uint64
+	expiredRemoveInterval time.Duration
+	done                  chan struct{}
+
+	db *sharedDiskDb
+}
+
+var _ CacheConnector = (*DiskConnector)(nil)
+
+func NewDiskConnector(id string, diskCfg *config.DiskCacheConnectorConfig) (*DiskConnector, error) {
+	path, err := filepath.Abs(filepath.Join(diskCfg.Directory, diskDbFile))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create a disk cache connector with id %s, reason - %s", id, err.Error())
+	}
+
+	return &DiskConnector{
+		id:                    id,
+		path:                  path,
+		maxSize:               uint64(maxSizeInBytes(diskCfg.MaxSize)),
+		expiredRemoveInterval: diskCfg.ExpiredRemoveInterval,
+		done:                  make(chan struct{}),
+	}, nil
+}
+
+func (d *DiskConnector) Id() string {
+	return d.id
+}
+
+func (d *DiskConnector) Initialize() error {
+	db, err := openSharedDiskDb(d.path)
+	if err != nil {
+		return fmt.Errorf("couldn't open disk cache %s: %w", d.path, err)
+	}
+	d.db = db
+
+	go d.removeExpired()
+
+	return nil
+}
+
+func (d *DiskConnector) Store(_ context.Context, key string, object string, ttl time.Duration) error {
+	return d.store(key, object, ttl, nil)
+}
+
+func (d *DiskConnector) StoreAtHeight(_ context.Context, key string, object string, ttl time.Duration, chain chains.Chain, height uint64) error {
+	return d.store(key, object, ttl, heightKey(chain, height, key))
+}
+
+func (d *DiskConnector) store(key string, object string, ttl time.Duration, heightKey []byte) error {
+	if d.db == nil {
+		return errDiskConnectorNotInitialized
+	}
+	if itemSize(key, len(heightKey), len(object)) > d.maxSize {
+		return fmt.Errorf("object of %d bytes exceeds the max size of the disk cache", len(object))
+	}
+	var expireAt int64
+	if ttl > 0 {
+		expireAt = time.Now().Add(ttl).UnixNano()
+	}
+
+	// Batch coalesces concurrent writes into one synced transaction, the function may be retried
+	evicted := 0
+	err := d.db.Batch(func(tx *bolt.Tx) error {
+		if err := removeItem(tx, []byte(key)); err != nil {
+			return err
+		}
+
+		seq, err := tx.Bucket(orderBucket).NextSequence()
+		if err != nil {
+			return err
+		}
+		seqBytes := binary.BigEndian.AppendUint64(nil, seq)
+
+		value := make([]byte, 0, diskItemHeaderLen+len(heightKey)+len(object))
+		value = binary.BigEndian.AppendUint64(value, uint64(expireAt))
+		value = append(value, seqBytes...)
+		value = binary.BigEndian.AppendUint16(value, uint16(len(heightKey)))
+		value = append(value, heightKey...)
+		value = append(value, object...)
+
+		if err = tx.Bucket(itemsBucket).Put([]byte(key), value); err != nil {
+			return err
+		}
+		if err = tx.Bucket(orderBucket).Put(seqBytes, []byte(key)); err != nil {
+			return err
+		}
+		if heightKey != nil {
+			if err = tx.Bucket(heightsBucket).Put(heightKey, nil); err != nil {
+				return err
+			}
+		}
+		size := addSize(tx, int64(len(key)+len(value)))
+
+		evicted, err = d.evict(tx, size)
+		return err
+	})
+	if err == nil && evicted > 0 {
+		cacheEvictions.WithLabelValues(d.id, evictedBySize).Add(float64(evicted))
+	}
+	return err
+}
+
+// evict removes the oldest items until the stored items fit the max size
+func (d *DiskConnector) evict(tx *bolt.Tx, size uint64) (int, error) {
+	if size <= d.maxSize {
+		return 0, nil
+	}
+	evicted := 0
+	cursor := tx.Bucket(orderBucket).Cursor()
+	for _, key := cursor.First(); key != nil && size > d.maxSize; _, key = cursor.First() {
+		if err := removeItem(tx, bytes.Clone(key)); err != nil {
+			return 0, err
+		}
+		size = currentSize(tx)
+		evicted++
+	}
+	log.Debug().Msgf("evicted %d items from the disk cache %s", evicted, d.id)
+	return evicted, nil
+}
+
+func (d *DiskConnector) Receive(_ context.Context, key string) ([]byte, error) {
+	if d.db == nil {
+		return nil, errDiskConnectorNotInitialized
+	}
+	var object []byte
+	err := d.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(itemsBucket).Get([]byte(key))
+		if value == nil || itemExpired(value, time.Now()) {
+			return ErrCacheNotFound
+		}
+		// the value is valid only within the transaction
+		object = bytes.Clone(itemObject(value))
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return object, nil
+}
+
+func (d *DiskConnector) RemoveHeights(_ context.Context, chain chains.Chain, fromHeight, toHeight uint64) error {
+	if d.db == nil {
+		return errDiskConnectorNotInitialized
+	}
+	prefix := heightKey(chain, 0, "")[:len(chain.String())+1]
+	to := heightKey(chain, toHeight, "")
+
+	return d.db.Update(func(tx *bolt.Tx) error {
+		keys := make([][]byte, 0)
+		cursor := tx.Bucket(heightsBucket).Cursor()
+		for indexKey, _ := cursor.Seek(heightKey(chain, fromHeight, "")); indexKey != nil && bytes.HasPrefix(indexKey, prefix); indexKey, _ = cursor.Next() {
+			if bytes.Compare(indexKey[:len(to)], to) > 0 {
+				break
+			}
+			keys = append(keys, bytes.Clone(indexKey[len(to):]))
+		}
+		// the index is cleaned up by removeItem, so the cursor must not be used while removing
+		for _, key := range keys {
+			if err := removeItem(tx, key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Scan collects the keys first, fn is called outside the transaction since it may remove the scanned keys
+func (d *DiskConnector) Scan(ctx context.Context, prefix string, fn func(key string, size int) error) error {
+	if d.db == nil {
+		return errDiskConnectorNotInitialized
+	}
+	type scannedItem struct {
+		key  string
+		size int
+	}
+	items := make([]scannedItem, 0)
+	now := time.Now()
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(itemsBucket).Cursor()
+		for key, value := cursor.Seek([]byte(prefix)); key != nil && bytes.HasPrefix(key, []byte(prefix)); key, value = cursor.Next() {
+			if itemExpired(value, now) {
+				continue
+			}
+			items = append(items, scannedItem{key: string(key), size: len(itemObject(value))})
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err = ctx.Err(); err != nil {
+			return err
+		}
+		if err = fn(item.key, item.size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *DiskConnector) Remove(_ context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if d.db == nil {
+		return errDiskConnectorNotInitialized
+	}
+	return d.db.Update(func(tx *bolt.Tx) error {
+		for _, key := range keys {
+			if err := removeItem(tx, []byte(key)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close stops removing expired items and closes the database file once no other connector uses it
+func (d *DiskConnector) Close() {
+	close(d.done)
+	if d.db != nil {
+		d.db.release()
+	}
+}
+
+func (d *DiskConnector) removeExpired() {
+	for {
+		select {
+		case <-d.done:
+			return
+		case <-time.After(d.expiredRemoveInterval):
+		}
+
+		removed, err := d.removeItems()
+		if err != nil {
+			log.Error().Err(err).Msgf("couldn't remove expired items from the disk cache %s", d.id)
+		} else if removed > 0 {
+			cacheEvictions.WithLabelValues(d.id, evictedByExpired).Add(float64(removed))
+			log.Debug().Msgf("removed %d expired items from the disk cache %s", removed, d.id)
+		}
+	}
+}
+
+func (d *DiskConnector) removeItems() (int, error) {
+	now := time.Now()
+	keys := make([][]byte, 0)
+
+	// expired keys are collected by a read transaction, so writes aren't blocked while the whole cache is read
+	err := d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(itemsBucket).ForEach(func(key, value []byte) error {
+			if itemExpired(value, now) {
+				keys = append(keys, bytes.Clone(key))
+			}
+			return nil
+		})
+	})
+	if err != nil || len(keys) == 0 {
+		return 0, err
+	}
+
+	removed := 0
+	err = d.db.Update(func(tx *bolt.Tx) error {
+		items := tx.Bucket(itemsBucket)
+		for _, key := range keys {
+			// an item could be stored again after it has been read
+			if value := items.Get(key); value == nil || !itemExpired(value, now) {
+				continue
+			}
+			if err := removeItem(tx, key); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// removeItem removes an item with its order and height index entries, an unknown key is skipped
+func removeItem(tx *bolt.Tx, key []byte) error {
+	items := tx.Bucket(itemsBucket)
+	value := items.Get(key)
+	if value == nil {
+		return nil
+	}
+	size := int64(len(key) + len(value))
+	heightKeyLen := int(binary.BigEndian.Uint16(value[16:diskItemHeaderLen]))
+	// the value is valid only until the item is deleted
+	seq := bytes.Clone(value[8:16])
+	indexKey := bytes.Clone(value[diskItemHeaderLen : diskItemHeaderLen+heightKeyLen])
+
+	if err := items.Delete(key); err != nil {
+		return err
+	}
+	if err := tx.Bucket(orderBucket).Delete(seq); err != nil {
+		return err
+	}
+	if heightKeyLen > 0 {
+		if err := tx.Bucket(heightsBucket).Delete(indexKey); err != nil {
+			return err
+		}
+	}
+	addSize(tx, -size)
+	return nil
+}
+
+func itemExpired(value []byte, now time.Time) bool {
+	expireAt := int64(binary.BigEndian.Uint64(value[:8]))
+	return expireAt != 0 && now.UnixNano() > expireAt
+}
+
+func itemObject(value []byte) []byte {
+	heightKeyLen := int(binary.BigEndian.Uint16(value[16:diskItemHeaderLen]))
+	return value[diskItemHeaderLen+heightKeyLen:]
+}
+
+func itemSize(key string, heightKeyLen, objectLen int) uint64 {
+	return uint64(len(key) + diskItemHeaderLen + heightKeyLen + objectLen)
+}
+
+func heightKey(chain chains.Chain, height uint64, key string) []byte {
+	indexKey := make([]byte, 0, len(chain.String())+9+len(key))
+	indexKey = append(indexKey, chain.String()...)
+	indexKey = append(indexKey, 0)
+	indexKey = binary.BigEndian.AppendUint64(indexKey, height)
+	return append(indexKey, key...)
+}
+
+func currentSize(tx *bolt.Tx) uint64 {
+	size := tx.Bucket(metaBucket).Get(sizeKey)
+	if size == nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(size)
+}
+
+// addSize keeps the total size within the transaction of a change, so it's consistent with the stored items
+func addSize(tx *bolt.Tx, delta int64) uint64 {
+	size := max(int64(currentSize(tx))+delta, 0)
+	_ = tx.Bucket(metaBucket).Put(sizeKey, binary.BigEndian.AppendUint64(nil, uint64(size)))
+	return uint64(size)
+}
+
+// sharedDiskDb is a database file shared by connectors with the same directory,
+// a connector recreated on config reload is initialized before the previous one is closed,
+// and the file can't be opened twice since it's locked while opened
+type sharedDiskDb struct {
+	*bolt.DB
+	path string
+	refs int
+}
+
+var (
+	diskDbsMu sync.Mutex
+	diskDbs   = make(map[string]*sharedDiskDb)
+)
+
+func openSharedDiskDb(path string) (*sharedDiskDb, error) {
+	diskDbsMu.Lock()
+	defer diskDbsMu.Unlock()
+
+	if db, ok := diskDbs[path]; ok {
+		db.refs++
+		return db, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: diskOpenTimeout, FreelistType: bolt.FreelistMapType})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{itemsBucket, orderBucket, heightsBucket, metaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	shared := &sharedDiskDb{DB: db, path: path, refs: 1}
+	diskDbs[path] = shared
+	return shared, nil
+}
+
+func (s *sharedDiskDb) release() {
+	diskDbsMu.Lock()
+	defer diskDbsMu.Unlock()
+
+	s.refs--
+	if s.refs > 0 {
+		return
+	}
+	delete(diskDbs, s.path)
+	if err := s.Close(); err != nil {
+		log.Error().Err(err).Msgf("couldn't close disk cache %s", s.path)
+	}
+}
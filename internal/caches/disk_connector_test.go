@@ -0,0 +1,181 @@
+package caches_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils/e2e"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newDiskConnector(t *testing.T, directory string, maxSize string) *caches.DiskConnector {
+	t.Helper()
+
+	connector, err := caches.NewDiskConnector("disk", &config.DiskCacheConnectorConfig{
+		Directory:             directory,
+		MaxSize:               maxSize,
+		ExpiredRemoveInterval: 10 * time.Millisecond,
+	})
+	require.NoError(t, err)
+	return connector
+}
+
+func TestDiskCacheNoItemThenErrCacheNotFound(t *testing.T) {
+	connector := newDiskConnector(t, t.TempDir(), "1MB")
+	defer connector.Close()
+
+	e2e.TestConnectorNoItemThenErrCacheNotFound(t, connector)
+}
+
+func TestDiskCacheStoreThenReceive(t *testing.T) {
+	connector := newDiskConnector(t, t.TempDir(), "1MB")
+	defer connector.Close()
+
+	e2e.TestConnectorStoreThenReceive(t, connector)
+}
+
+func TestDiskCacheStoreAndRemoveExpired(t *testing.T) {
+	connector := newDiskConnector(t, t.TempDir(), "1MB")
+	defer connector.Close()
+
+	e2e.TestConnectorStoreAndRemoveExpired(t, connector)
+}
+
+func TestDiskCacheStoreAtHeightThenRemoveHeights(t *testing.T) {
+	connector := newDiskConnector(t, t.TempDir(), "1MB")
+	defer connector.Close()
+
+	e2e.TestConnectorStoreAtHeightThenRemoveHeights(t, connector)
+}
+
+func TestDiskCacheScanThenRemove(t *testing.T) {
+	connector := newDiskConnector(t, t.TempDir(), "1MB")
+	defer connector.Close()
+
+	e2e.TestConnectorScanThenRemove(t, connector)
+}
+
+func TestDiskCacheExpiredItemNotReceived(t *testing.T) {
+	connector, err := caches.NewDiskConnector("disk", &config.DiskCacheConnectorConfig{
+		Directory:             t.TempDir(),
+		MaxSize:               "1MB",
+		ExpiredRemoveInterval: time.Minute,
+	})
+	require.NoError(t, err)
+	require.NoError(t, connector.Initialize())
+	defer connector.Close()
+
+	err = connector.Store(context.Background(), "key", "object", time.Millisecond)
+	require.NoError(t, err)
+	time.Sleep(5 * time.Millisecond)
+
+	object, err := connector.Receive(context.Background(), "key")
+
+	assert.Nil(t, object)
+	assert.ErrorIs(t, err, caches.ErrCacheNotFound)
+}
+
+func TestDiskCacheSurvivesRestart(t *testing.T) {
+	directory := t.TempDir()
+	connector := newDiskConnector(t, directory, "1MB")
+	require.NoError(t, connector.Initialize())
+
+	err := connector.Store(context.Background(), "key", "object", 0)
+	require.NoError(t, err)
+	err = connector.StoreAtHeight(context.Background(), "height-key", "object", 0, chains.ETHEREUM, 100)
+	require.NoError(t, err)
+	connector.Close()
+
+	restarted := newDiskConnector(t, directory, "1MB")
+	require.NoError(t, restarted.Initialize())
+	defer restarted.Close()
+
+	object, err := restarted.Receive(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("object"), object)
+
+	err = restarted.RemoveHeights(context.Background(), chains.ETHEREUM, 100, 100)
+	assert.NoError(t, err)
+	_, err = restarted.Receive(context.Background(), "height-key")
+	assert.ErrorIs(t, err, caches.ErrCacheNotFound)
+}
+
+func TestDiskCacheEvictsOldestItemsOverMaxSize(t *testing.T) {
+	connector := newDiskConnector(t, t.TempDir(), "1KB")
+	require.NoError(t, connector.Initialize())
+	defer connector.Close()
+
+	object := strings.Repeat("a", 300)
+	for _, key := range []string{"key1", "key2", "key3", "key4"} {
+		err := connector.Store(context.Background(), key, object, 0)
+		require.NoError(t, err)
+	}
+
+	_, err := connector.Receive(context.Background(), "key1")
+	assert.ErrorIs(t, err, caches.ErrCacheNotFound)
+	for _, key := range []string{"key2", "key3", "key4"} {
+		received, err := connector.Receive(context.Background(), key)
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(object), received)
+	}
+}
+
+func TestDiskCacheRestoredItemIsNewest(t *testing.T) {
+	connector := newDiskConnector(t, t.TempDir(), "1KB")
+	require.NoError(t, connector.Initialize())
+	defer connector.Close()
+
+	object := strings.Repeat("a", 300)
+	for _, key := range []string{"key1", "key2", "key3", "key1", "key4"} {
+		err := connector.Store(context.Background(), key, object, 0)
+		require.NoError(t, err)
+	}
+
+	_, err := connector.Receive(context.Background(), "key2")
+	assert.ErrorIs(t, err, caches.ErrCacheNotFound)
+	_, err = connector.Receive(context.Background(), "key1")
+	assert.NoError(t, err)
+}
+
+func TestDiskCacheObjectOverMaxSizeThenError(t *testing.T) {
+	connector := newDiskConnector(t, t.TempDir(), "1KB")
+	require.NoError(t, connector.Initialize())
+	defer connector.Close()
+
+	err := connector.Store(context.Background(), "key", strings.Repeat("a", 1024), 0)
+
+	assert.ErrorContains(t, err, "object of 1024 bytes exceeds the max size of the disk cache")
+}
+
+func TestDiskCacheSameDirectoryWhileReloading(t *testing.T) {
+	directory := t.TempDir()
+	current := newDiskConnector(t, directory, "1MB")
+	require.NoError(t, current.Initialize())
+
+	err := current.Store(context.Background(), "key", "object", 0)
+	require.NoError(t, err)
+
+	// a reloaded connector is initialized before the current one is closed
+	reloaded := newDiskConnector(t, directory, "1MB")
+	require.NoError(t, reloaded.Initialize())
+	defer reloaded.Close()
+	current.Close()
+
+	object, err := reloaded.Receive(context.Background(), "key")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("object"), object)
+}
+
+func TestDiskCacheNotInitializedThenError(t *testing.T) {
+	connector := newDiskConnector(t, t.TempDir(), "1MB")
+
+	_, err := connector.Receive(context.Background(), "key")
+
+	assert.ErrorContains(t, err, "disk cache connector isn't initialized")
+}
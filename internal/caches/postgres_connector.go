@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/storages"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
@@ -19,16 +21,29 @@ const (
 CREATE TABLE IF NOT EXISTS %s (
     key         TEXT PRIMARY KEY,
     value       TEXT NOT NULL,
-    expires_at  TIMESTAMPTZ
+    expires_at  TIMESTAMPTZ,
+    chain       TEXT,
+    height      BIGINT
 );`
 
+	// tables created before the height index was introduced don't have its columns
+	addHeightColumns = `
+ALTER TABLE %s
+ADD COLUMN IF NOT EXISTS chain TEXT,
+ADD COLUMN IF NOT EXISTS height BIGINT;`
+
 	createIndex = `
 CREATE INDEX IF NOT EXISTS idx_cache_items_expires_at
 ON %s (expires_at)
 WHERE expires_at IS NOT NULL;`
 
+	createHeightIndex = `
+CREATE INDEX IF NOT EXISTS idx_cache_items_chain_height
+ON %s (chain, height)
+WHERE height IS NOT NULL;`
+
 	getItem = `
-SELECT value FROM %s
+SELECT value, expires_at FROM %s
 WHERE key=$1 AND (expires_at IS NULL OR expires_at > now());`
 
 	storeItem = `
@@ -36,17 +51,38 @@ INSERT INTO %s (key, value, expires_at)
 VALUES ($1, $2, $3)
 ON CONFLICT (key) DO NOTHING;`
 
+	storeItemAtHeight = `
+INSERT INTO %s (key, value, expires_at, chain, height)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (key) DO NOTHING;`
+
+	removeHeights = `
+DELETE FROM %s
+WHERE chain = $1 AND height BETWEEN $2 AND $3;`
+
+	scanItems = `
+SELECT key, octet_length(value) FROM %s
+WHERE key LIKE $1 AND (expires_at IS NULL OR expires_at > now());`
+
+	removeKeys = `
+DELETE FROM %s
+WHERE key = ANY($1);`
+
 	removeItems = `
 DELETE FROM %s
 WHERE expires_at IS NOT NULL AND expires_at <= NOW();`
 )
 
+// likeEscaper escapes the special characters of a LIKE pattern, the escape character is the default one
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 type PostgresConnector struct {
 	id                    string
 	pool                  *pgxpool.Pool
 	table                 string
 	queryTimeout          time.Duration
 	expiredRemoveInterval time.Duration
+	done                  chan struct{}
 }
 
 var _ CacheConnector = (*PostgresConnector)(nil)
@@ -67,6 +103,7 @@ func NewPostgresConnector(id string, postgresCfg *config.PostgresCacheConnectorC
 		table:                 postgresCfg.CacheTable,
 		queryTimeout:          *postgresCfg.QueryTimeout,
 		expiredRemoveInterval: postgresCfg.ExpiredRemoveInterval,
+		done:                  make(chan struct{}),
 	}, nil
 }
 
@@ -74,6 +111,11 @@ func (p *PostgresConnector) Id() string {
 	return p.id
 }
 
+// textOnly is true since objects are stored in a TEXT column
+func (p *PostgresConnector) textOnly() bool {
+	return true
+}
+
 func (p *PostgresConnector) Store(ctx context.Context, key string, object string, ttl time.Duration) error {
 	ctx, cancel := context.WithTimeout(ctx, p.queryTimeout)
 	defer cancel()
@@ -87,17 +129,86 @@ func (p *PostgresConnector) Store(ctx context.Context, key string, object string
 	return err
 }
 
+func (p *PostgresConnector) StoreAtHeight(ctx context.Context, key string, object string, ttl time.Duration, chain chains.Chain, height uint64) error {
+	ctx, cancel := context.WithTimeout(ctx, p.queryTimeout)
+	defer cancel()
+
+	var expiresAt *time.Time
+	if ttl > 0 {
+		expiresAt = lo.ToPtr(time.Now().UTC().Add(ttl))
+	}
+
+	_, err := p.pool.Exec(ctx, fmt.Sprintf(storeItemAtHeight, p.table), key, object, expiresAt, chain.String(), int64(height))
+	return err
+}
+
+func (p *PostgresConnector) RemoveHeights(ctx context.Context, chain chains.Chain, fromHeight, toHeight uint64) error {
+	ctx, cancel := context.WithTimeout(ctx, p.queryTimeout)
+	defer cancel()
+
+	_, err := p.pool.Exec(ctx, fmt.Sprintf(removeHeights, p.table), chain.String(), int64(fromHeight), int64(toHeight))
+	return err
+}
+
+// Scan isn't bounded by the query timeout since it reads the whole table, the caller's context bounds it
+func (p *PostgresConnector) Scan(ctx context.Context, prefix string, fn func(key string, size int) error) error {
+	rows, err := p.pool.Query(ctx, fmt.Sprintf(scanItems, p.table), likeEscaper.Replace(prefix)+"%")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var key string
+	var size int
+	for rows.Next() {
+		if err = rows.Scan(&key, &size); err != nil {
+			return err
+		}
+		if err = fn(key, size); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (p *PostgresConnector) Remove(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.queryTimeout)
+	defer cancel()
+
+	_, err := p.pool.Exec(ctx, fmt.Sprintf(removeKeys, p.table), keys)
+	return err
+}
+
 func (p *PostgresConnector) Receive(ctx context.Context, key string) ([]byte, error) {
+	object, _, err := p.ReceiveWithTTL(ctx, key)
+	return object, err
+}
+
+// ReceiveWithTTL returns the object along with the time it has left to live, 0 if it doesn't expire
+func (p *PostgresConnector) ReceiveWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
 	ctx, cancel := context.WithTimeout(ctx, p.queryTimeout)
 	defer cancel()
 
 	var value string
-	err := p.pool.QueryRow(ctx, fmt.Sprintf(getItem, p.table), key).Scan(&value)
+	var expiresAt *time.Time
+	err := p.pool.QueryRow(ctx, fmt.Sprintf(getItem, p.table), key).Scan(&value, &expiresAt)
 	if errors.Is(err, pgx.ErrNoRows) {
-		return nil, ErrCacheNotFound
+		return nil, 0, ErrCacheNotFound
 	}
-
-	return []byte(value), err
+	if err != nil {
+		return nil, 0, err
+	}
+	if expiresAt == nil {
+		return []byte(value), 0, nil
+	}
+	ttl := time.Until(*expiresAt)
+	if ttl <= 0 {
+		return nil, 0, ErrCacheNotFound
+	}
+	return []byte(value), ttl, nil
 }
 
 func (p *PostgresConnector) Initialize() error {
@@ -143,12 +254,27 @@ func (p *PostgresConnector) ensureSchema() error {
 	if _, err = tx.Exec(ctx, fmt.Sprintf(createIndex, p.table)); err != nil {
 		return fmt.Errorf("couldn't create index: %w", err)
 	}
+	if _, err = tx.Exec(ctx, fmt.Sprintf(addHeightColumns, p.table)); err != nil {
+		return fmt.Errorf("couldn't add height columns: %w", err)
+	}
+	if _, err = tx.Exec(ctx, fmt.Sprintf(createHeightIndex, p.table)); err != nil {
+		return fmt.Errorf("couldn't create height index: %w", err)
+	}
 	return tx.Commit(ctx)
 }
 
+// Close stops removing expired items, the pool belongs to the storage and stays open
+func (p *PostgresConnector) Close() {
+	close(p.done)
+}
+
 func (p *PostgresConnector) removeExpired() {
 	for {
-		<-time.After(p.expiredRemoveInterval)
+		select {
+		case <-p.done:
+			return
+		case <-time.After(p.expiredRemoveInterval):
+		}
 
 		err := p.removeItems()
 		if err != nil {
@@ -167,6 +293,7 @@ func (p *PostgresConnector) removeItems() error {
 	}
 
 	if rows := result.RowsAffected(); rows > 0 {
+		cacheEvictions.WithLabelValues(p.id, evictedByExpired).Add(float64(rows))
 		log.Debug().Msgf("removed %d expired items from %s", rows, p.table)
 	}
 	if rows := result.RowsAffected(); rows > 0 {
@@ -119,3 +119,35 @@ func TestPostgresConnectorStoreAndRemoveExpired(t *testing.T) {
 
 	e2e.TestConnectorStoreAndRemoveExpired(t, connector)
 }
+
+func TestPostgresConnectorStoreAtHeightThenRemoveHeights(t *testing.T) {
+	connector, err := caches.NewPostgresConnector(
+		"id",
+		&config.PostgresCacheConnectorConfig{
+			StorageName:           "test-postgres",
+			QueryTimeout:          lo.ToPtr(1 * time.Second),
+			CacheTable:            "cache",
+			ExpiredRemoveInterval: 1 * time.Hour,
+		},
+		storageRegistry,
+	)
+	assert.Nil(t, err)
+
+	e2e.TestConnectorStoreAtHeightThenRemoveHeights(t, connector)
+}
+
+func TestPostgresConnectorScanThenRemove(t *testing.T) {
+	connector, err := caches.NewPostgresConnector(
+		"id",
+		&config.PostgresCacheConnectorConfig{
+			StorageName:           "test-postgres",
+			QueryTimeout:          lo.ToPtr(1 * time.Second),
+			CacheTable:            "cache",
+			ExpiredRemoveInterval: 1 * time.Hour,
+		},
+		storageRegistry,
+	)
+	assert.Nil(t, err)
+
+	e2e.TestConnectorScanThenRemove(t, connector)
+}
@@ -4,14 +4,25 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/storages"
+	"github.com/drpcorg/nodecore/pkg/chains"
 	"github.com/redis/go-redis/v9"
 )
 
-const cacheKeyPrefix = "nodecore:entry:"
+const (
+	cacheKeyPrefix  = "nodecore:entry:"
+	heightKeyPrefix = "nodecore:height:"
+)
+
+// scanBatchSize is the number of keys asked by one SCAN call and sized by one pipeline
+const scanBatchSize = 1000
+
+// redisGlobEscaper escapes the special characters of a SCAN MATCH pattern
+var redisGlobEscaper = strings.NewReplacer(`\`, `\\`, `*`, `\*`, `?`, `\?`, `[`, `\[`, `]`, `\]`)
 
 type RedisConnector struct {
 	id     string
@@ -40,6 +51,98 @@ func (r *RedisConnector) Store(ctx context.Context, key string, object string, t
 	return r.client.Set(ctx, cacheKey, object, ttl).Err()
 }
 
+// StoreAtHeight stores the object and adds its key to a set of the height,
+// the set lives as long as the last object stored in it
+func (r *RedisConnector) StoreAtHeight(ctx context.Context, key string, object string, ttl time.Duration, chain chains.Chain, height uint64) error {
+	cacheKey := cacheKeyPrefix + key
+	heightKey := redisHeightKey(chain, height)
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, cacheKey, object, ttl)
+		pipe.SAdd(ctx, heightKey, cacheKey)
+		if ttl > 0 {
+			pipe.Expire(ctx, heightKey, ttl)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *RedisConnector) RemoveHeights(ctx context.Context, chain chains.Chain, fromHeight, toHeight uint64) error {
+	for height := fromHeight; height <= toHeight; height++ {
+		heightKey := redisHeightKey(chain, height)
+		cacheKeys, err := r.client.SMembers(ctx, heightKey).Result()
+		if err != nil {
+			return err
+		}
+		if err = r.client.Del(ctx, append(cacheKeys, heightKey)...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func redisHeightKey(chain chains.Chain, height uint64) string {
+	return fmt.Sprintf("%s%s:%d", heightKeyPrefix, chain, height)
+}
+
+// Scan iterates the keys with SCAN, so it doesn't block the server, but a key
+// changed during the scan might be reported twice
+func (r *RedisConnector) Scan(ctx context.Context, prefix string, fn func(key string, size int) error) error {
+	iter := r.client.Scan(ctx, 0, cacheKeyPrefix+redisGlobEscaper.Replace(prefix)+"*", scanBatchSize).Iterator()
+
+	keys := make([]string, 0, scanBatchSize)
+	flush := func() error {
+		if len(keys) == 0 {
+			return nil
+		}
+		sizes := make([]*redis.IntCmd, len(keys))
+		_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, key := range keys {
+				sizes[i] = pipe.StrLen(ctx, key)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for i, key := range keys {
+			// an object expired after it had been scanned has no length
+			if size := sizes[i].Val(); size > 0 {
+				if err = fn(strings.TrimPrefix(key, cacheKeyPrefix), int(size)); err != nil {
+					return err
+				}
+			}
+		}
+		keys = keys[:0]
+		return nil
+	}
+
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) == scanBatchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return flush()
+}
+
+func (r *RedisConnector) Remove(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	cacheKeys := make([]string, 0, len(keys))
+	for _, key := range keys {
+		cacheKeys = append(cacheKeys, cacheKeyPrefix+key)
+	}
+	return r.client.Del(ctx, cacheKeys...).Err()
+}
+
 func (r *RedisConnector) Receive(ctx context.Context, key string) ([]byte, error) {
 	cacheKey := cacheKeyPrefix + key
 
@@ -50,6 +153,38 @@ func (r *RedisConnector) Receive(ctx context.Context, key string) ([]byte, error
 	return value, err
 }
 
+// ReceiveWithTTL returns the object along with the time it has left to live, 0 if it doesn't expire
+func (r *RedisConnector) ReceiveWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	cacheKey := cacheKeyPrefix + key
+
+	var get *redis.StringCmd
+	var pttl *redis.DurationCmd
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		get = pipe.Get(ctx, cacheKey)
+		pttl = pipe.PTTL(ctx, cacheKey)
+		return nil
+	})
+	if errors.Is(err, redis.Nil) {
+		return nil, 0, ErrCacheNotFound
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	value, err := get.Bytes()
+	if err != nil {
+		return nil, 0, err
+	}
+	// PTTL is -1 for a key without a ttl and -2 for a key that has expired after GET
+	switch ttl := pttl.Val(); {
+	case ttl == -2:
+		return nil, 0, ErrCacheNotFound
+	case ttl < 0:
+		return value, 0, nil
+	default:
+		return value, ttl, nil
+	}
+}
+
 func NewRedisConnector(id string, redisConfig *config.RedisCacheConnectorConfig, storageRegistry *storages.StorageRegistry) (*RedisConnector, error) {
 	storage, ok := storageRegistry.Get(redisConfig.StorageName)
 	if !ok {
@@ -65,4 +200,8 @@ func NewRedisConnector(id string, redisConfig *config.RedisCacheConnectorConfig,
 	}, nil
 }
 
+// Close is a noop, the client belongs to the storage and stays open
+func (r *RedisConnector) Close() {
+}
+
 var _ CacheConnector = (*RedisConnector)(nil)
@@ -107,3 +107,29 @@ func TestRedisConnectorStoreAndRemoveExpired(t *testing.T) {
 
 	e2e.TestConnectorStoreAndRemoveExpired(t, connector)
 }
+
+func TestRedisConnectorStoreAtHeightThenRemoveHeights(t *testing.T) {
+	connector, err := caches.NewRedisConnector(
+		"id",
+		&config.RedisCacheConnectorConfig{
+			StorageName: "test-redis",
+		},
+		storageRegistry,
+	)
+	assert.Nil(t, err)
+
+	e2e.TestConnectorStoreAtHeightThenRemoveHeights(t, connector)
+}
+
+func TestRedisConnectorScanThenRemove(t *testing.T) {
+	connector, err := caches.NewRedisConnector(
+		"id",
+		&config.RedisCacheConnectorConfig{
+			StorageName: "test-redis",
+		},
+		storageRegistry,
+	)
+	assert.Nil(t, err)
+
+	e2e.TestConnectorScanThenRemove(t, connector)
+}
@@ -0,0 +1,188 @@
+package caches
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/hashicorp/golang-lru/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	tierL1 = "l1"
+	tierL2 = "l2"
+)
+
+var cacheTierHit = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "cache",
+		Name:      "tier_hit",
+		Help:      "The total number of objects found in a tier of a tiered cache connector",
+	},
+	[]string{"connector", "tier"},
+)
+
+var cacheTierMiss = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "cache",
+		Name:      "tier_miss",
+		Help:      "The total number of objects not found in a tier of a tiered cache connector",
+	},
+	[]string{"connector", "tier"},
+)
+
+func init() {
+	prometheus.MustRegister(cacheTierHit, cacheTierMiss)
+}
+
+// ttlStorage is implemented by connectors that can tell how long a received object has left to live
+type ttlStorage interface {
+	// ReceiveWithTTL returns the object along with its remaining ttl, 0 if the object doesn't expire
+	ReceiveWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error)
+}
+
+// receiveWithTTL returns 0 as the ttl of an object of a connector that can't tell it
+func receiveWithTTL(ctx context.Context, connector CacheConnector, key string) ([]byte, time.Duration, error) {
+	if storage, ok := connector.(ttlStorage); ok {
+		return storage.ReceiveWithTTL(ctx, key)
+	}
+	object, err := connector.Receive(ctx, key)
+	return object, 0, err
+}
+
+// TieredConnector puts a memory L1 in front of a remote L2 (redis or postgres).
+// Writes go to both tiers, reads go to L2 only on an L1 miss and the object found
+// in L2 is promoted to L1 for the time it has left to live in L2. Objects live in L1 for at most l1TTL,
+// so an object changed or removed in L2 by another nodecore instance isn't served from L1 for long.
+type TieredConnector struct {
+	id    string
+	l1    *InMemoryConnector
+	l2    CacheConnector
+	l1TTL time.Duration
+
+	// promoted keeps the keys promoted from L2, their heights are unknown,
+	// so on a reorg they are removed from L1 by the chain of the key
+	promoted *lru.Cache[string, struct{}]
+}
+
+func NewTieredConnector(id string, l1 *InMemoryConnector, l2 CacheConnector, l1TTL time.Duration, maxItems int) (*TieredConnector, error) {
+	promoted, err := lru.New[string, struct{}](maxItems)
+	if err != nil {
+		return nil, err
+	}
+	return &TieredConnector{
+		id:       id,
+		l1:       l1,
+		l2:       l2,
+		l1TTL:    l1TTL,
+		promoted: promoted,
+	}, nil
+}
+
+func (t *TieredConnector) Id() string {
+	return t.id
+}
+
+func (t *TieredConnector) Store(ctx context.Context, key string, object string, ttl time.Duration) error {
+	if err := t.l2.Store(ctx, key, object, ttl); err != nil {
+		return err
+	}
+	t.promoted.Remove(key)
+	return t.l1.Store(ctx, key, object, t.boundL1TTL(ttl))
+}
+
+func (t *TieredConnector) StoreAtHeight(ctx context.Context, key string, object string, ttl time.Duration, chain chains.Chain, height uint64) error {
+	if err := t.l2.StoreAtHeight(ctx, key, object, ttl, chain, height); err != nil {
+		return err
+	}
+	t.promoted.Remove(key)
+	return t.l1.StoreAtHeight(ctx, key, object, t.boundL1TTL(ttl), chain, height)
+}
+
+func (t *TieredConnector) Receive(ctx context.Context, key string) ([]byte, error) {
+	if object, err := t.l1.Receive(ctx, key); err == nil {
+		cacheTierHit.WithLabelValues(t.id, tierL1).Inc()
+		return object, nil
+	}
+	cacheTierMiss.WithLabelValues(t.id, tierL1).Inc()
+
+	object, ttl, err := receiveWithTTL(ctx, t.l2, key)
+	if err != nil {
+		if errors.Is(err, ErrCacheNotFound) {
+			cacheTierMiss.WithLabelValues(t.id, tierL2).Inc()
+		}
+		return nil, err
+	}
+	cacheTierHit.WithLabelValues(t.id, tierL2).Inc()
+
+	t.promoted.Add(key, struct{}{})
+	// the object must not outlive its L2 copy, L1 would serve it after it has expired
+	_ = t.l1.Store(ctx, key, string(object), t.boundL1TTL(ttl))
+
+	return object, nil
+}
+
+func (t *TieredConnector) RemoveHeights(ctx context.Context, chain chains.Chain, fromHeight, toHeight uint64) error {
+	err := t.l2.RemoveHeights(ctx, chain, fromHeight, toHeight)
+	_ = t.l1.RemoveHeights(ctx, chain, fromHeight, toHeight)
+
+	prefix := chainCacheKeyPrefix(chain)
+	for _, key := range t.promoted.Keys() {
+		if strings.HasPrefix(key, prefix) {
+			t.promoted.Remove(key)
+			t.l1.cache.Remove(key)
+		}
+	}
+
+	return err
+}
+
+// Scan reads L2 only, every object of L1 is written to L2 as well
+func (t *TieredConnector) Scan(ctx context.Context, prefix string, fn func(key string, size int) error) error {
+	return t.l2.Scan(ctx, prefix, fn)
+}
+
+func (t *TieredConnector) Remove(ctx context.Context, keys ...string) error {
+	err := t.l2.Remove(ctx, keys...)
+	for _, key := range keys {
+		t.promoted.Remove(key)
+	}
+	_ = t.l1.Remove(ctx, keys...)
+	return err
+}
+
+func (t *TieredConnector) Initialize() error {
+	if err := t.l2.Initialize(); err != nil {
+		return err
+	}
+	return t.l1.Initialize()
+}
+
+func (t *TieredConnector) Close() {
+	t.l1.Close()
+	t.l2.Close()
+	t.promoted.Purge()
+}
+
+func (t *TieredConnector) textOnly() bool {
+	return isTextOnly(t.l2)
+}
+
+// boundL1TTL caps the ttl of an object written to L1, an object without a ttl lives in L1 for l1TTL
+func (t *TieredConnector) boundL1TTL(ttl time.Duration) time.Duration {
+	if ttl <= 0 || ttl > t.l1TTL {
+		return t.l1TTL
+	}
+	return ttl
+}
+
+var _ CacheConnector = (*TieredConnector)(nil)
+var _ ttlStorage = (*RedisConnector)(nil)
+var _ ttlStorage = (*PostgresConnector)(nil)
+var _ ttlStorage = (*CompressedConnector)(nil)
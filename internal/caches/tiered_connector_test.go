@@ -0,0 +1,183 @@
+package caches_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTieredConnector(t *testing.T, l2 caches.CacheConnector) *caches.TieredConnector {
+	l1, err := caches.NewInMemoryConnector("l1", &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: time.Minute})
+	require.NoError(t, err)
+	tiered, err := caches.NewTieredConnector("tiered", l1, l2, 10*time.Second, 100)
+	require.NoError(t, err)
+	return tiered
+}
+
+func TestTieredConnectorStoreWritesThroughBothTiers(t *testing.T) {
+	l2 := mocks.NewCacheConnectorMock()
+	l2.On("Store", mock.Anything, "key", "object", time.Minute).Return(nil)
+	tiered := newTieredConnector(t, l2)
+
+	err := tiered.Store(context.Background(), "key", "object", time.Minute)
+	require.NoError(t, err)
+
+	// the object is read from L1, L2 is not asked
+	object, err := tiered.Receive(context.Background(), "key")
+	require.NoError(t, err)
+
+	assert.Equal(t, []byte("object"), object)
+	l2.AssertExpectations(t)
+	l2.AssertNotCalled(t, "Receive", mock.Anything, mock.Anything)
+}
+
+func TestTieredConnectorL2FailedThenNotStoredToL1(t *testing.T) {
+	l2 := mocks.NewCacheConnectorMock()
+	l2.On("Store", mock.Anything, "key", "object", time.Minute).Return(errors.New("l2 error"))
+	l2.On("Receive", mock.Anything, "key").Return([]byte(nil), caches.ErrCacheNotFound)
+	tiered := newTieredConnector(t, l2)
+
+	err := tiered.Store(context.Background(), "key", "object", time.Minute)
+	assert.ErrorContains(t, err, "l2 error")
+
+	_, err = tiered.Receive(context.Background(), "key")
+	assert.ErrorIs(t, err, caches.ErrCacheNotFound)
+}
+
+func TestTieredConnectorL2HitPromotedToL1(t *testing.T) {
+	l2 := mocks.NewCacheConnectorMock()
+	l2.On("Receive", mock.Anything, "key").Return([]byte("object"), nil).Once()
+	tiered := newTieredConnector(t, l2)
+
+	object, err := tiered.Receive(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("object"), object)
+
+	// the second receive is served by L1
+	object, err = tiered.Receive(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("object"), object)
+
+	l2.AssertExpectations(t)
+}
+
+type ttlCacheConnectorMock struct {
+	*mocks.CacheConnectorMock
+}
+
+func (c *ttlCacheConnectorMock) ReceiveWithTTL(ctx context.Context, key string) ([]byte, time.Duration, error) {
+	args := c.Called(ctx, key)
+	return args.Get(0).([]byte), args.Get(1).(time.Duration), args.Error(2)
+}
+
+func TestTieredConnectorL2HitPromotedForRemainingTTL(t *testing.T) {
+	l2 := &ttlCacheConnectorMock{CacheConnectorMock: mocks.NewCacheConnectorMock()}
+	l2.On("ReceiveWithTTL", mock.Anything, "key").Return([]byte("object"), 50*time.Millisecond, nil).Once()
+	l2.On("ReceiveWithTTL", mock.Anything, "key").Return([]byte(nil), time.Duration(0), caches.ErrCacheNotFound).Once()
+	tiered := newTieredConnector(t, l2)
+
+	object, err := tiered.Receive(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("object"), object)
+
+	// the object has expired in L2, so it isn't served from L1 either
+	time.Sleep(100 * time.Millisecond)
+	_, err = tiered.Receive(context.Background(), "key")
+	assert.ErrorIs(t, err, caches.ErrCacheNotFound)
+
+	l2.AssertExpectations(t)
+	l2.AssertNotCalled(t, "Receive", mock.Anything, mock.Anything)
+}
+
+func TestTieredConnectorMissInBothTiers(t *testing.T) {
+	l2 := mocks.NewCacheConnectorMock()
+	l2.On("Receive", mock.Anything, "key").Return([]byte(nil), caches.ErrCacheNotFound)
+	tiered := newTieredConnector(t, l2)
+
+	object, err := tiered.Receive(context.Background(), "key")
+
+	assert.Nil(t, object)
+	assert.ErrorIs(t, err, caches.ErrCacheNotFound)
+	l2.AssertExpectations(t)
+}
+
+func TestTieredConnectorRemoveHeightsRemovesPromotedObjectsOfChain(t *testing.T) {
+	l2 := mocks.NewCacheConnectorMock()
+	l2.On("Receive", mock.Anything, "polygon_hash1").Return([]byte("promoted-polygon"), nil).Twice()
+	l2.On("Receive", mock.Anything, "ethereum_hash1").Return([]byte("promoted-ethereum"), nil).Once()
+	l2.On("StoreAtHeight", mock.Anything, "polygon_hash2", "stored", time.Minute, chains.POLYGON, uint64(100)).Return(nil)
+	l2.On("Receive", mock.Anything, "polygon_hash2").Return([]byte(nil), caches.ErrCacheNotFound).Once()
+	l2.On("RemoveHeights", mock.Anything, chains.POLYGON, uint64(100), uint64(101)).Return(nil)
+	tiered := newTieredConnector(t, l2)
+	ctx := context.Background()
+
+	_, err := tiered.Receive(ctx, "polygon_hash1")
+	require.NoError(t, err)
+	_, err = tiered.Receive(ctx, "ethereum_hash1")
+	require.NoError(t, err)
+	err = tiered.StoreAtHeight(ctx, "polygon_hash2", "stored", time.Minute, chains.POLYGON, 100)
+	require.NoError(t, err)
+
+	err = tiered.RemoveHeights(ctx, chains.POLYGON, 100, 101)
+	require.NoError(t, err)
+
+	// the promoted polygon object is read from L2 again
+	object, err := tiered.Receive(ctx, "polygon_hash1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("promoted-polygon"), object)
+	// the object of the reorged height is removed from both tiers
+	_, err = tiered.Receive(ctx, "polygon_hash2")
+	assert.ErrorIs(t, err, caches.ErrCacheNotFound)
+	// objects of other chains stay in L1
+	object, err = tiered.Receive(ctx, "ethereum_hash1")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("promoted-ethereum"), object)
+
+	l2.AssertExpectations(t)
+}
+
+func TestTieredConnectorScanReadsL2(t *testing.T) {
+	l2 := mocks.NewCacheConnectorMock()
+	l2.On("Scan", mock.Anything, "polygon_", mock.Anything).Return(nil)
+	tiered := newTieredConnector(t, l2)
+
+	err := tiered.Scan(context.Background(), "polygon_", func(key string, size int) error { return nil })
+
+	assert.NoError(t, err)
+	l2.AssertExpectations(t)
+}
+
+func TestTieredConnectorRemoveFromBothTiers(t *testing.T) {
+	l2 := mocks.NewCacheConnectorMock()
+	l2.On("Receive", mock.Anything, "promoted").Return([]byte("object"), nil).Twice()
+	l2.On("Store", mock.Anything, "stored", "object", time.Minute).Return(nil)
+	l2.On("Remove", mock.Anything, []string{"promoted", "stored"}).Return(nil)
+	l2.On("Receive", mock.Anything, "stored").Return([]byte(nil), caches.ErrCacheNotFound)
+	tiered := newTieredConnector(t, l2)
+	ctx := context.Background()
+
+	_, err := tiered.Receive(ctx, "promoted")
+	require.NoError(t, err)
+	err = tiered.Store(ctx, "stored", "object", time.Minute)
+	require.NoError(t, err)
+
+	err = tiered.Remove(ctx, "promoted", "stored")
+	require.NoError(t, err)
+
+	// both objects are gone from L1, so L2 is asked again
+	_, err = tiered.Receive(ctx, "promoted")
+	require.NoError(t, err)
+	_, err = tiered.Receive(ctx, "stored")
+	assert.ErrorIs(t, err, caches.ErrCacheNotFound)
+
+	l2.AssertExpectations(t)
+}
@@ -71,6 +71,12 @@ type KeySettingsConfig struct {
 	Methods       *AuthMethods   `yaml:"methods"`
 	AuthContracts *AuthContracts `yaml:"contracts"`
 	CorsOrigins   []string       `yaml:"cors-origins"`
+	// RateLimit limits the requests of the key
+	RateLimit *InboundRateLimitConfig `yaml:"rate-limit"`
+	// Selector is the default routing selector of the key's requests that don't carry their own one
+	Selector string `yaml:"selector"`
+	// AllowCacheControl lets clients of the key control the cache of their requests, it's allowed by default
+	AllowCacheControl *bool `yaml:"allow-cache-control"`
 }
 
 type AuthMethods struct {
@@ -95,7 +101,7 @@ func (d *DrpcKeyConfig) keyCfg() {}
 
 func (l *LocalKeyConfig) keyCfg() {}
 
-func (a *AuthConfig) validate(integrationCfg *IntegrationConfig) error {
+func (a *AuthConfig) validate(integrationCfg *IntegrationConfig, storageNames map[string]string) error {
 	if !a.Enabled {
 		return nil
 	}
@@ -117,7 +123,7 @@ func (a *AuthConfig) validate(integrationCfg *IntegrationConfig) error {
 			if keyConfig.LocalKeyConfig != nil && keys.ContainsOne(keyConfig.LocalKeyConfig.Key) {
 				return fmt.Errorf("error during key config validation, local key '%s' already exists", keyConfig.LocalKeyConfig.Key)
 			}
-			if err := keyConfig.validate(integrationCfg); err != nil {
+			if err := keyConfig.validate(integrationCfg, storageNames); err != nil {
 				return fmt.Errorf("error during '%s' key config validation, cause: %s", keyConfig.Id, err.Error())
 			}
 			keyIds.Add(keyConfig.Id)
@@ -153,7 +159,7 @@ func (r *RequestStrategyConfig) validate() error {
 	return nil
 }
 
-func (k *KeyConfig) validate(integrationCfg *IntegrationConfig) error {
+func (k *KeyConfig) validate(integrationCfg *IntegrationConfig, storageNames map[string]string) error {
 	if err := k.Type.validate(); err != nil {
 		return err
 	}
@@ -162,7 +168,7 @@ func (k *KeyConfig) validate(integrationCfg *IntegrationConfig) error {
 		if k.LocalKeyConfig == nil {
 			return keyNoSettingsError(k.Type)
 		}
-		if err := k.LocalKeyConfig.validate(); err != nil {
+		if err := k.LocalKeyConfig.validate(storageNames); err != nil {
 			return err
 		}
 	case Drpc:
@@ -196,10 +202,15 @@ func keyNoSettingsError(keyType IntegrationType) error {
 	return fmt.Errorf("specified '%s' key management rule type but there are no its settings", keyType)
 }
 
-func (l *LocalKeyConfig) validate() error {
+func (l *LocalKeyConfig) validate(storageNames map[string]string) error {
 	if l.Key == "" {
 		return errors.New("'key' field is empty")
 	}
+	if l.KeySettingsConfig != nil && l.KeySettingsConfig.RateLimit != nil {
+		if err := l.KeySettingsConfig.RateLimit.validate(storageNames); err != nil {
+			return fmt.Errorf("error during rate limit validation, cause: %s", err.Error())
+		}
+	}
 	return nil
 }
 
@@ -49,6 +49,10 @@ func TestBalancingStrategyValidate(t *testing.T) {
 	assert.NoError(t, BalancingStrategy("").validate())
 	assert.NoError(t, RatingBalancingStrategy.validate())
 	assert.NoError(t, BaseBalancingStrategy.validate())
+	assert.NoError(t, WeightedRatingBalancingStrategy.validate())
+	assert.NoError(t, WeightedBalancingStrategy.validate())
+	assert.NoError(t, P2CBalancingStrategy.validate())
+	assert.NoError(t, CostBalancingStrategy.validate())
 
 	err := BalancingStrategy("bogus").validate()
 	assert.ErrorContains(t, err, "invalid balancing strategy - 'bogus'")
@@ -3,6 +3,7 @@ package config
 import (
 	"errors"
 	"fmt"
+	"path/filepath"
 	"strconv"
 	"strings"
 	"time"
@@ -24,6 +25,10 @@ type CacheConnectorConfig struct {
 	Redis    *RedisCacheConnectorConfig    `yaml:"redis"`
 	Memory   *MemoryCacheConnectorConfig   `yaml:"memory"`
 	Postgres *PostgresCacheConnectorConfig `yaml:"postgres"`
+	Tiered   *TieredCacheConnectorConfig   `yaml:"tiered"`
+	Disk     *DiskCacheConnectorConfig     `yaml:"disk"`
+	// Compression is applied to the objects stored by the connector, no compression if nil
+	Compression *CacheCompressionConfig `yaml:"compression"`
 }
 
 type CachePolicyConfig struct {
@@ -36,6 +41,15 @@ type CachePolicyConfig struct {
 	Connector        string           `yaml:"connector-id"`
 	ObjectMaxSize    string           `yaml:"object-max-size"`
 	TTL              string           `yaml:"ttl"`
+	// StaleTTL is how long a response is kept after its ttl is over,
+	// within this window it's served stale and refreshed in the background
+	StaleTTL string `yaml:"stale-ttl"`
+	// StaleIfErrorTTL is how long a response is kept after its stale window is over,
+	// within this window it's served only if all upstreams fail with a retryable error
+	StaleIfErrorTTL string `yaml:"stale-if-error-ttl"`
+	// CacheErrors enables caching of non-retryable upstream errors, they are kept for ErrorsTTL
+	CacheErrors bool   `yaml:"cache-errors"`
+	ErrorsTTL   string `yaml:"errors-ttl"`
 }
 
 type FinalizationType string
@@ -51,6 +65,8 @@ const (
 	Memory   CacheConnectorDriver = "memory"
 	Redis    CacheConnectorDriver = "redis"
 	Postgres CacheConnectorDriver = "postgres"
+	Tiered   CacheConnectorDriver = "tiered"
+	Disk     CacheConnectorDriver = "disk"
 )
 
 type RedisCacheConnectorConfig struct {
@@ -69,8 +85,38 @@ type PostgresCacheConnectorConfig struct {
 	ExpiredRemoveInterval time.Duration  `yaml:"expired-remove-interval"`
 }
 
+// DiskCacheConnectorConfig stores objects in an embedded database file in Directory,
+// the oldest objects are evicted once the stored objects exceed MaxSize
+type DiskCacheConnectorConfig struct {
+	Directory             string        `yaml:"directory"`
+	MaxSize               string        `yaml:"max-size"`
+	ExpiredRemoveInterval time.Duration `yaml:"expired-remove-interval"`
+}
+
+type CacheCompressionConfig struct {
+	Algorithm CompressionAlgorithm `yaml:"algorithm"`
+	// MinSize is the size starting from which objects are compressed
+	MinSize string `yaml:"min-size"`
+}
+
+type CompressionAlgorithm string
+
+const (
+	Zstd CompressionAlgorithm = "zstd"
+	Gzip CompressionAlgorithm = "gzip"
+)
+
+// TieredCacheConnectorConfig composes a memory L1 with a redis or postgres L2,
+// objects received from L2 are promoted to L1 for at most L1TTL
+type TieredCacheConnectorConfig struct {
+	L1    *MemoryCacheConnectorConfig `yaml:"l1"`
+	L2    *CacheConnectorConfig       `yaml:"l2"`
+	L1TTL time.Duration               `yaml:"l1-ttl"`
+}
+
 func (c *CacheConfig) validate(storageNames map[string]string) error {
 	connectors := mapset.NewThreadUnsafeSet[string]()
+	diskDirectories := mapset.NewThreadUnsafeSet[string]()
 	for i, connector := range c.CacheConnectors {
 		if connector.Id == "" {
 			return fmt.Errorf("error during cache connectors validation, cause: no connector id under index %d", i)
@@ -81,6 +127,14 @@ func (c *CacheConfig) validate(storageNames map[string]string) error {
 		if err := connector.validate(storageNames); err != nil {
 			return fmt.Errorf("error during cache connector '%s' validation, cause: %s", connector.Id, err.Error())
 		}
+		if connector.Driver == Disk {
+			// the database file is locked by the connector that opened it
+			directory := filepath.Clean(connector.Disk.Directory)
+			if diskDirectories.ContainsOne(directory) {
+				return fmt.Errorf("error during cache connector '%s' validation, cause: disk directory '%s' is used by another connector", connector.Id, directory)
+			}
+			diskDirectories.Add(directory)
+		}
 		connectors.Add(connector.Id)
 	}
 	policies := mapset.NewThreadUnsafeSet[string]()
@@ -109,12 +163,8 @@ func (c *CacheConnectorConfig) validate(storageNames map[string]string) error {
 		}
 	}
 	if c.Redis != nil {
-		storage, ok := storageNames[c.Redis.StorageName]
-		if !ok {
-			return fmt.Errorf("redis storage name '%s' not found", c.Redis.StorageName)
-		}
-		if storage != "redis" {
-			return fmt.Errorf("redis storage name '%s' is not a redis storage", c.Redis.StorageName)
+		if err := c.Redis.validate(storageNames); err != nil {
+			return err
 		}
 	}
 	if c.Postgres != nil {
@@ -122,10 +172,67 @@ func (c *CacheConnectorConfig) validate(storageNames map[string]string) error {
 			return err
 		}
 	}
+	if c.Driver == Tiered {
+		if err := c.Tiered.validate(storageNames); err != nil {
+			return err
+		}
+	}
+	if c.Driver == Disk {
+		if err := c.Disk.validate(); err != nil {
+			return err
+		}
+	}
+	if c.Compression != nil {
+		if err := c.Compression.validate(); err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+func (c *CacheCompressionConfig) validate() error {
+	switch c.Algorithm {
+	case Zstd, Gzip:
+	default:
+		return fmt.Errorf("invalid compression algorithm - '%s'", c.Algorithm)
+	}
+	if err := validateSize(c.MinSize); err != nil {
+		return fmt.Errorf("invalid compression min-size - %s", err.Error())
+	}
+	return nil
+}
+
+func (r *RedisCacheConnectorConfig) validate(storageNames map[string]string) error {
+	storage, ok := storageNames[r.StorageName]
+	if !ok {
+		return fmt.Errorf("redis storage name '%s' not found", r.StorageName)
+	}
+	if storage != "redis" {
+		return fmt.Errorf("redis storage name '%s' is not a redis storage", r.StorageName)
+	}
+	return nil
+}
+
+func (t *TieredCacheConnectorConfig) validate(storageNames map[string]string) error {
+	if t.L1TTL <= 0 {
+		return errors.New("tiered l1-ttl must be > 0")
+	}
+	if err := t.L1.validate(); err != nil {
+		return fmt.Errorf("tiered l1: %s", err.Error())
+	}
+	if t.L2 == nil {
+		return errors.New("tiered l2 must be set")
+	}
+	if t.L2.Driver != Redis && t.L2.Driver != Postgres {
+		return fmt.Errorf("tiered l2 driver must be redis or postgres, got '%s'", t.L2.Driver)
+	}
+	if err := t.L2.validate(storageNames); err != nil {
+		return fmt.Errorf("tiered l2: %s", err.Error())
+	}
+	return nil
+}
+
 func (p *PostgresCacheConnectorConfig) validate(storageNames map[string]string) error {
 	storage, ok := storageNames[p.StorageName]
 	if !ok {
@@ -156,9 +263,23 @@ func (m *MemoryCacheConnectorConfig) validate() error {
 	return nil
 }
 
+func (d *DiskCacheConnectorConfig) validate() error {
+	if d.Directory == "" {
+		return errors.New("disk directory must be set")
+	}
+	if err := validateSize(d.MaxSize); err != nil {
+		return fmt.Errorf("invalid disk max-size - %s", err.Error())
+	}
+	if d.ExpiredRemoveInterval <= 0 {
+		return errors.New("expired remove interval must be > 0")
+	}
+
+	return nil
+}
+
 func (d CacheConnectorDriver) validate() error {
 	switch d {
-	case Memory, Redis, Postgres:
+	case Memory, Redis, Postgres, Tiered, Disk:
 	default:
 		return fmt.Errorf("invalid cache driver - '%s'", d)
 	}
@@ -201,15 +322,52 @@ func (p *CachePolicyConfig) validate(connectors mapset.Set[string]) error {
 	if err := p.FinalizationType.validate(); err != nil {
 		return err
 	}
-	if _, err := time.ParseDuration(p.TTL); err != nil {
+	ttl, err := time.ParseDuration(p.TTL)
+	if err != nil {
+		return err
+	}
+	if err := validateStaleWindow("stale-ttl", p.StaleTTL, ttl); err != nil {
 		return err
 	}
+	if err := validateStaleWindow("stale-if-error-ttl", p.StaleIfErrorTTL, ttl); err != nil {
+		return err
+	}
+	if p.ErrorsTTL != "" {
+		if !p.CacheErrors {
+			return errors.New("errors-ttl requires cache-errors")
+		}
+		errorsTTL, err := time.ParseDuration(p.ErrorsTTL)
+		if err != nil {
+			return fmt.Errorf("invalid errors-ttl - %s", err.Error())
+		}
+		if errorsTTL <= 0 {
+			return errors.New("errors-ttl must be positive")
+		}
+	}
 	if !connectors.ContainsOne(p.Connector) {
 		return fmt.Errorf("there is no such connector - '%s'", p.Connector)
 	}
 	return nil
 }
 
+// validateStaleWindow checks a window a response is kept for after its ttl, an empty one isn't set
+func validateStaleWindow(name, window string, ttl time.Duration) error {
+	if window == "" {
+		return nil
+	}
+	windowDuration, err := time.ParseDuration(window)
+	if err != nil {
+		return fmt.Errorf("invalid %s - %s", name, err.Error())
+	}
+	if windowDuration <= 0 {
+		return fmt.Errorf("%s must be positive", name)
+	}
+	if ttl == 0 {
+		return fmt.Errorf("%s can't be used with an unlimited ttl", name)
+	}
+	return nil
+}
+
 func validateSize(size string) error {
 	var maxSize string
 
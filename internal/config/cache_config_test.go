@@ -99,6 +99,100 @@ func TestCachePolicyWrongTtlThenError(t *testing.T) {
 	assert.ErrorContains(t, err, "error during cache policy 'my_policy' validation, cause: time: missing unit in duration \"10\"")
 }
 
+func TestCachePolicyStaleTtl(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/cache/cache-stale-ttl.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	policy := appConfig.CacheConfig.CachePolicies[0]
+	assert.Equal(t, "10s", policy.TTL)
+	assert.Equal(t, "1m", policy.StaleTTL)
+	assert.Equal(t, "1h", policy.StaleIfErrorTTL)
+}
+
+func TestCachePolicyWrongStaleTtlThenError(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "wrong stale-ttl",
+			path:     "configs/cache/cache-wrong-stale-ttl.yaml",
+			expected: "error during cache policy 'my_policy' validation, cause: invalid stale-ttl - time: missing unit in duration \"1\"",
+		},
+		{
+			name:     "zero stale-ttl",
+			path:     "configs/cache/cache-zero-stale-ttl.yaml",
+			expected: "error during cache policy 'my_policy' validation, cause: stale-ttl must be positive",
+		},
+		{
+			name:     "unlimited ttl",
+			path:     "configs/cache/cache-stale-ttl-unlimited-ttl.yaml",
+			expected: "error during cache policy 'my_policy' validation, cause: stale-ttl can't be used with an unlimited ttl",
+		},
+		{
+			name:     "stale-if-error-ttl with unlimited ttl",
+			path:     "configs/cache/cache-stale-if-error-ttl-unlimited-ttl.yaml",
+			expected: "error during cache policy 'my_policy' validation, cause: stale-if-error-ttl can't be used with an unlimited ttl",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			te.Setenv(config.ConfigPathVar, test.path)
+			_, err := config.NewAppConfig()
+			assert.ErrorContains(te, err, test.expected)
+		})
+	}
+}
+
+func TestCachePolicyCacheErrors(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/cache/cache-errors.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	policy := appConfig.CacheConfig.CachePolicies[0]
+	assert.True(t, policy.CacheErrors)
+	assert.Equal(t, "5s", policy.ErrorsTTL)
+
+	policy = appConfig.CacheConfig.CachePolicies[1]
+	assert.True(t, policy.CacheErrors)
+	assert.Equal(t, "30s", policy.ErrorsTTL)
+}
+
+func TestCachePolicyWrongErrorsTtlThenError(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "wrong errors-ttl",
+			path:     "configs/cache/cache-wrong-errors-ttl.yaml",
+			expected: "error during cache policy 'my_policy' validation, cause: invalid errors-ttl - time: missing unit in duration \"5\"",
+		},
+		{
+			name:     "zero errors-ttl",
+			path:     "configs/cache/cache-zero-errors-ttl.yaml",
+			expected: "error during cache policy 'my_policy' validation, cause: errors-ttl must be positive",
+		},
+		{
+			name:     "errors-ttl without cache-errors",
+			path:     "configs/cache/cache-errors-ttl-no-cache-errors.yaml",
+			expected: "error during cache policy 'my_policy' validation, cause: errors-ttl requires cache-errors",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			te.Setenv(config.ConfigPathVar, test.path)
+			_, err := config.NewAppConfig()
+			assert.ErrorContains(te, err, test.expected)
+		})
+	}
+}
+
 func TestCachePolicyNotExistedConnectorThenError(t *testing.T) {
 	t.Setenv(config.ConfigPathVar, "configs/cache/cache-not-existed-connector.yaml")
 	_, err := config.NewAppConfig()
@@ -181,3 +275,181 @@ func TestPostgresNonpositiveExpiredIntervalThenError(t *testing.T) {
 	_, err := config.NewAppConfig()
 	assert.ErrorContains(t, err, "error during cache connector 'pg5' validation, cause: expired remove interval must be > 0")
 }
+
+func TestTieredConnectorDefaults(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/cache/cache-tiered-defaults.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	expected := &config.TieredCacheConnectorConfig{
+		L1: &config.MemoryCacheConnectorConfig{
+			MaxItems:              10000,
+			ExpiredRemoveInterval: 30 * time.Second,
+		},
+		L2: &config.CacheConnectorConfig{
+			Driver: config.Redis,
+			Redis:  &config.RedisCacheConnectorConfig{StorageName: "redis-storage"},
+		},
+		L1TTL: 1 * time.Minute,
+	}
+
+	assert.Equal(t, expected, appConfig.CacheConfig.CacheConnectors[0].Tiered)
+}
+
+func TestTieredConnectorCustom(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/cache/cache-tiered-custom.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	tiered := appConfig.CacheConfig.CacheConnectors[0].Tiered
+	assert.Equal(t, 10*time.Second, tiered.L1TTL)
+	assert.Equal(t, &config.MemoryCacheConnectorConfig{MaxItems: 100, ExpiredRemoveInterval: 5 * time.Second}, tiered.L1)
+}
+
+func TestTieredConnectorWrongConfigThenError(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "no l2",
+			path:     "configs/cache/cache-tiered-no-l2.yaml",
+			expected: "error during cache connector 'tiered' validation, cause: tiered l2 must be set",
+		},
+		{
+			name:     "memory l2",
+			path:     "configs/cache/cache-tiered-wrong-l2-driver.yaml",
+			expected: "error during cache connector 'tiered' validation, cause: tiered l2 driver must be redis or postgres, got 'memory'",
+		},
+		{
+			name:     "unknown l2 storage",
+			path:     "configs/cache/cache-tiered-wrong-l2-storage.yaml",
+			expected: "error during cache connector 'tiered' validation, cause: tiered l2: redis storage name 'unknown-storage' not found",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			te.Setenv(config.ConfigPathVar, test.path)
+			_, err := config.NewAppConfig()
+			assert.ErrorContains(te, err, test.expected)
+		})
+	}
+}
+
+func TestCacheCompressionSettings(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected *config.CacheCompressionConfig
+	}{
+		{
+			name:     "defaults",
+			path:     "configs/cache/cache-compression-defaults.yaml",
+			expected: &config.CacheCompressionConfig{Algorithm: config.Zstd, MinSize: "1KB"},
+		},
+		{
+			name:     "custom",
+			path:     "configs/cache/cache-compression-custom.yaml",
+			expected: &config.CacheCompressionConfig{Algorithm: config.Gzip, MinSize: "10KB"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			te.Setenv(config.ConfigPathVar, test.path)
+			appConfig, err := config.NewAppConfig()
+			require.NoError(te, err)
+
+			assert.Equal(te, test.expected, appConfig.CacheConfig.CacheConnectors[0].Compression)
+		})
+	}
+}
+
+func TestCacheCompressionWrongSettingsThenError(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "wrong algorithm",
+			path:     "configs/cache/cache-compression-wrong-algorithm.yaml",
+			expected: "error during cache connector 'test' validation, cause: invalid compression algorithm - 'brotli'",
+		},
+		{
+			name:     "wrong min-size",
+			path:     "configs/cache/cache-compression-wrong-min-size.yaml",
+			expected: "error during cache connector 'test' validation, cause: invalid compression min-size - size must be in KB or MB",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			te.Setenv(config.ConfigPathVar, test.path)
+			_, err := config.NewAppConfig()
+			assert.ErrorContains(te, err, test.expected)
+		})
+	}
+}
+
+func TestDiskConnectorDefaults(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/cache/cache-disk-defaults.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	expected := &config.DiskCacheConnectorConfig{
+		Directory:             "cache",
+		MaxSize:               "1024MB",
+		ExpiredRemoveInterval: 30 * time.Second,
+	}
+
+	assert.Equal(t, expected, appConfig.CacheConfig.CacheConnectors[0].Disk)
+}
+
+func TestDiskConnectorCustom(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/cache/cache-disk-custom.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	expected := &config.DiskCacheConnectorConfig{
+		Directory:             "/var/lib/nodecore/cache",
+		MaxSize:               "10MB",
+		ExpiredRemoveInterval: 5 * time.Second,
+	}
+
+	assert.Equal(t, expected, appConfig.CacheConfig.CacheConnectors[0].Disk)
+}
+
+func TestDiskConnectorWrongConfigThenError(t *testing.T) {
+	tests := []struct {
+		name     string
+		path     string
+		expected string
+	}{
+		{
+			name:     "wrong max size",
+			path:     "configs/cache/cache-disk-wrong-max-size.yaml",
+			expected: "error during cache connector 'disk' validation, cause: invalid disk max-size - size must be in KB or MB",
+		},
+		{
+			name:     "nonpositive expired remove interval",
+			path:     "configs/cache/cache-disk-nonpositive-expired-interval.yaml",
+			expected: "error during cache connector 'disk' validation, cause: expired remove interval must be > 0",
+		},
+		{
+			name:     "same directory",
+			path:     "configs/cache/cache-disk-same-directory.yaml",
+			expected: "error during cache connector 'disk-2' validation, cause: disk directory 'cache' is used by another connector",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			te.Setenv(config.ConfigPathVar, test.path)
+			_, err := config.NewAppConfig()
+			assert.ErrorContains(te, err, test.expected)
+		})
+	}
+}
@@ -0,0 +1,30 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCoalesceRequestsForDefault(t *testing.T) {
+	var nilCfg *UpstreamConfig
+	assert.True(t, nilCfg.CoalesceRequestsFor(chains.ETHEREUM.String()))
+
+	cfg := &UpstreamConfig{Mode: DefaultMode}
+	assert.True(t, cfg.CoalesceRequestsFor(chains.ETHEREUM.String()))
+
+	otherChainCfg := &UpstreamConfig{Mode: StrictMode, ChainDefaults: map[string]*ChainDefaults{
+		chains.BSC.String(): {CoalesceRequests: new(false)},
+	}}
+	assert.True(t, otherChainCfg.CoalesceRequestsFor(chains.ETHEREUM.String()))
+}
+
+func TestCoalesceRequestsForPerChainOverride(t *testing.T) {
+	cfg := &UpstreamConfig{Mode: DefaultMode, ChainDefaults: map[string]*ChainDefaults{
+		chains.ETHEREUM.String(): {CoalesceRequests: new(false)},
+		chains.POLYGON.String():  {CoalesceRequests: new(true)},
+	}}
+	assert.False(t, cfg.CoalesceRequestsFor(chains.ETHEREUM.String()))
+	assert.True(t, cfg.CoalesceRequestsFor(chains.POLYGON.String()))
+}
@@ -41,11 +41,18 @@ func (s IntegrationType) validate() error {
 	return nil
 }
 
-func NewAppConfig() (*AppConfig, error) {
+// ConfigPath returns the path of the config file, it's taken from the
+// NODECORE_CONFIG_PATH env variable or defaults to ./nodecore.yml
+func ConfigPath() string {
 	configPath := os.Getenv(ConfigPathVar)
 	if configPath == "" {
 		configPath = DefaultConfigPath
 	}
+	return configPath
+}
+
+func NewAppConfig() (*AppConfig, error) {
+	configPath := ConfigPath()
 	log.Debug().Msgf("reading the config file %s", configPath)
 
 	file, err := os.ReadFile(configPath)
@@ -74,6 +74,7 @@ func TestReadFullConfig(t *testing.T) {
 				Username: "pyro-username",
 				Password: "pyro-password",
 			},
+			TracingConfig: &config.TracingConfig{SamplingRatio: 1},
 			TlsConfig: &config.TlsConfig{
 				Enabled:     true,
 				Certificate: "/path/cert",
@@ -84,6 +85,7 @@ func TestReadFullConfig(t *testing.T) {
 				PublicKeyOwner: "drpc",
 				SessionTTL:     24 * time.Hour,
 			},
+			AdminConfig: &config.AdminConfig{},
 		},
 		AuthConfig: &config.AuthConfig{
 			Enabled: true,
@@ -174,6 +176,7 @@ func TestReadFullConfig(t *testing.T) {
 			ScorePolicyConfig: &config.ScorePolicyConfig{
 				CalculationInterval:     10 * time.Second,
 				CalculationFunctionName: config.DefaultLatencyPolicyFuncName,
+				CostTieTolerance:        new(config.DefaultCostTieTolerance),
 			},
 			FailsafeConfig: &config.FailsafeConfig{
 				RetryConfig: &config.RetryConfig{
@@ -198,6 +201,7 @@ func TestReadFullConfig(t *testing.T) {
 					HeadConnector: specs.WebsocketConnector.String(),
 					PollInterval:  3 * time.Minute,
 					ChainName:     "ethereum",
+					Weight:        1,
 					RateLimit: &config.RateLimiterConfig{
 						Rules: []config.RateLimitRule{
 							{
@@ -272,6 +276,7 @@ func TestReadFullConfig(t *testing.T) {
 					HeadConnector: specs.RestConnector.String(),
 					PollInterval:  1 * time.Minute,
 					ChainName:     "polygon",
+					Weight:        1,
 					Methods: &config.MethodsConfig{
 						BanDuration: 5 * time.Minute,
 					},
@@ -1,10 +1,13 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"regexp"
 
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/samber/lo"
 )
 
 func (a *AppConfig) validate() error {
@@ -31,7 +34,7 @@ func (a *AppConfig) validate() error {
 		}
 	}
 	if a.AuthConfig != nil {
-		if err := a.AuthConfig.validate(a.IntegrationConfig); err != nil {
+		if err := a.AuthConfig.validate(a.IntegrationConfig, storageNames); err != nil {
 			return err
 		}
 	}
@@ -43,6 +46,11 @@ func (a *AppConfig) validate() error {
 	if err := a.ServerConfig.validate(); err != nil {
 		return err
 	}
+	if a.ServerConfig.IpRateLimit != nil {
+		if err := a.ServerConfig.IpRateLimit.validate(storageNames); err != nil {
+			return fmt.Errorf("error during ip rate limit validation, cause: %s", err.Error())
+		}
+	}
 
 	rateLimitBudgetNames := mapset.NewThreadUnsafeSet[string]()
 	if len(a.RateLimit) > 0 {
@@ -60,4 +68,41 @@ func (a *AppConfig) validate() error {
 	return nil
 }
 
+// PrepareUpstream sets the defaults of an upstream added at runtime and validates it
+// against the rest of the config the same way as the upstreams of the config file
+func (a *AppConfig) PrepareUpstream(upstream *Upstream) error {
+	if upstream.Id == "" {
+		return errors.New("error during upstream validation, cause: no upstream id")
+	}
+	if !chains.IsSupported(upstream.ChainName) {
+		return fmt.Errorf("error during upstream '%s' validation, cause: not supported chain '%s'", upstream.Id, upstream.ChainName)
+	}
+
+	upstream.setDefaults(a.UpstreamConfig.ChainDefaults[upstream.ChainName], a.UpstreamConfig.Mode)
+	if !a.ServerConfig.GrpcAuthConfig.Disabled() {
+		upstream.setSecureSignedLabel()
+	}
+
+	if err := upstream.validate(a.ServerConfig.TorUrl); err != nil {
+		return fmt.Errorf("error during upstream '%s' validation, cause: %s", upstream.Id, err.Error())
+	}
+	if upstream.RateLimitBudget != "" {
+		budgetExists := lo.ContainsBy(a.RateLimit, func(budgetConfig RateLimitBudgetsConfig) bool {
+			return lo.ContainsBy(budgetConfig.Budgets, func(budget RateLimitBudget) bool {
+				return budget.Name == upstream.RateLimitBudget
+			})
+		})
+		if !budgetExists {
+			return fmt.Errorf("upstream '%s' references non-existent rate limit budget '%s'", upstream.Id, upstream.RateLimitBudget)
+		}
+	}
+	if upstream.RateLimitAutoTune != nil {
+		if err := upstream.RateLimitAutoTune.validate(); err != nil {
+			return fmt.Errorf("error during rate limit auto-tune config validation, cause: %s", err.Error())
+		}
+	}
+
+	return nil
+}
+
 var methodRegex = regexp.MustCompile("^[a-zA-Z0-9_]+$")
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"os"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// WatchConfigFile polls the config file every interval and calls onChange each
+// time its content differs from the previously seen one. A file that can't be
+// read is skipped until the next tick, so a half-written file doesn't stop watching.
+func WatchConfigFile(ctx context.Context, configPath string, interval time.Duration, onChange func()) {
+	lastHash := fileHash(configPath)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+			currentHash := fileHash(configPath)
+			if currentHash == nil || bytes.Equal(currentHash, lastHash) {
+				continue
+			}
+			lastHash = currentHash
+			log.Info().Msgf("the config file %s has been changed", configPath)
+			onChange()
+		}
+	}
+}
+
+func fileHash(path string) []byte {
+	file, err := os.ReadFile(path)
+	if err != nil {
+		log.Warn().Err(err).Msgf("couldn't read the config file %s", path)
+		return nil
+	}
+	hash := sha256.Sum256(file)
+	return hash[:]
+}
@@ -0,0 +1,38 @@
+package config_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchConfigFileCallsOnChangeOnlyWhenContentChanges(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "nodecore.yml")
+	require.NoError(t, os.WriteFile(configPath, []byte("server:\n  port: 9090"), 0o644))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	changes := make(chan struct{}, 10)
+	go config.WatchConfigFile(ctx, configPath, 10*time.Millisecond, func() {
+		changes <- struct{}{}
+	})
+
+	time.Sleep(30 * time.Millisecond)
+	assert.Len(t, changes, 0)
+
+	require.NoError(t, os.WriteFile(configPath, []byte("server:\n  port: 9091"), 0o644))
+
+	select {
+	case <-changes:
+	case <-time.After(time.Second):
+		t.Fatal("no change has been detected")
+	}
+	time.Sleep(30 * time.Millisecond)
+	assert.Len(t, changes, 0)
+}
@@ -14,6 +14,8 @@ import (
 const (
 	defaultPort     = 9090
 	defaultInterval = 1 * time.Minute
+	// DefaultCostTieTolerance ties upstreams whose scores differ by up to 5%
+	DefaultCostTieTolerance = 0.05
 )
 
 func (a *AppConfig) setDefaults() {
@@ -107,15 +109,28 @@ func (s *ServerConfig) setDefaults() {
 	if s.PyroscopeConfig == nil {
 		s.PyroscopeConfig = &PyroscopeConfig{}
 	}
+	if s.TracingConfig == nil {
+		s.TracingConfig = &TracingConfig{}
+	}
+	s.TracingConfig.setDefaults()
 	if s.TlsConfig == nil {
 		s.TlsConfig = &TlsConfig{}
 	}
 	if s.GrpcAuthConfig == nil {
 		s.GrpcAuthConfig = &GrpcAuthConfig{}
 	}
+	if s.AdminConfig == nil {
+		s.AdminConfig = &AdminConfig{}
+	}
 	s.GrpcAuthConfig.setDefaults()
 }
 
+func (t *TracingConfig) setDefaults() {
+	if t.SamplingRatio == 0 {
+		t.SamplingRatio = 1
+	}
+}
+
 func (g *GrpcAuthConfig) setDefaults() {
 	if g.PublicKeyOwner == "" {
 		g.PublicKeyOwner = "drpc"
@@ -150,20 +165,21 @@ func (p *CachePolicyConfig) setDefaults() {
 	if p.TTL == "0" {
 		p.TTL = "0s"
 	}
+	if p.CacheErrors && p.ErrorsTTL == "" {
+		p.ErrorsTTL = "5s"
+	}
 }
 
 func (c *CacheConnectorConfig) setDefaults() {
+	if c.Compression != nil {
+		c.Compression.setDefaults()
+	}
 	switch c.Driver {
 	case Memory:
 		if c.Memory == nil {
 			c.Memory = &MemoryCacheConnectorConfig{}
 		}
-		if c.Memory.MaxItems == 0 {
-			c.Memory.MaxItems = 10000
-		}
-		if c.Memory.ExpiredRemoveInterval == 0 {
-			c.Memory.ExpiredRemoveInterval = 30 * time.Second
-		}
+		c.Memory.setDefaults()
 	case Redis:
 		if c.Redis == nil {
 			c.Redis = &RedisCacheConnectorConfig{}
@@ -173,6 +189,59 @@ func (c *CacheConnectorConfig) setDefaults() {
 			c.Postgres = &PostgresCacheConnectorConfig{}
 		}
 		c.Postgres.setDefaults()
+	case Tiered:
+		if c.Tiered == nil {
+			c.Tiered = &TieredCacheConnectorConfig{}
+		}
+		c.Tiered.setDefaults()
+	case Disk:
+		if c.Disk == nil {
+			c.Disk = &DiskCacheConnectorConfig{}
+		}
+		c.Disk.setDefaults()
+	}
+}
+
+func (c *CacheCompressionConfig) setDefaults() {
+	if c.Algorithm == "" {
+		c.Algorithm = Zstd
+	}
+	if c.MinSize == "" {
+		c.MinSize = "1KB"
+	}
+}
+
+func (m *MemoryCacheConnectorConfig) setDefaults() {
+	if m.MaxItems == 0 {
+		m.MaxItems = 10000
+	}
+	if m.ExpiredRemoveInterval == 0 {
+		m.ExpiredRemoveInterval = 30 * time.Second
+	}
+}
+
+func (d *DiskCacheConnectorConfig) setDefaults() {
+	if d.Directory == "" {
+		d.Directory = "cache"
+	}
+	if d.MaxSize == "" {
+		d.MaxSize = "1024MB"
+	}
+	if d.ExpiredRemoveInterval == 0 {
+		d.ExpiredRemoveInterval = 30 * time.Second
+	}
+}
+
+func (t *TieredCacheConnectorConfig) setDefaults() {
+	if t.L1 == nil {
+		t.L1 = &MemoryCacheConnectorConfig{}
+	}
+	t.L1.setDefaults()
+	if t.L1TTL == 0 {
+		t.L1TTL = 1 * time.Minute
+	}
+	if t.L2 != nil {
+		t.L2.setDefaults()
 	}
 }
 
@@ -245,6 +314,10 @@ func (u *UpstreamConfig) setDefaults(grpcAuth *GrpcAuthConfig) {
 	if u.FailsafeConfig.HedgeConfig != nil {
 		u.FailsafeConfig.HedgeConfig.setDefaults()
 	}
+	if u.FailsafeConfig.CircuitBreakerConfig != nil {
+		u.FailsafeConfig.CircuitBreakerConfig.setDefaults()
+	}
+	setMethodPoliciesDefaults(u.FailsafeConfig.MethodPolicies)
 	if u.ScorePolicyConfig == nil {
 		u.ScorePolicyConfig = &ScorePolicyConfig{}
 	}
@@ -252,10 +325,15 @@ func (u *UpstreamConfig) setDefaults(grpcAuth *GrpcAuthConfig) {
 	u.LabelBalancing.setDefaults()
 	for _, chainDefaults := range u.ChainDefaults {
 		chainDefaults.LabelBalancing.setDefaults()
+		setMethodPoliciesDefaults(chainDefaults.FailsafeMethodPolicies)
 	}
 	for _, upstream := range u.Upstreams {
 		chainDefaults := u.ChainDefaults[upstream.ChainName]
 		upstream.setDefaults(chainDefaults, u.Mode)
+		if upstream.FailsafeConfig.CircuitBreakerConfig == nil {
+			// the global circuit breaker is a default of upstreams without their own one
+			upstream.FailsafeConfig.CircuitBreakerConfig = u.FailsafeConfig.CircuitBreakerConfig
+		}
 		if !grpcAuth.Disabled() {
 			upstream.setSecureSignedLabel()
 		}
@@ -290,6 +368,36 @@ func (r *RateLimitAutoTuneConfig) setDefaults() {
 	}
 }
 
+func (c *ConcurrencyLimitConfig) setDefaults() {
+	if c.Algorithm == "" {
+		c.Algorithm = GradientConcurrencyLimit
+	}
+	if c.MinLimit == 0 {
+		c.MinLimit = 1
+	}
+	if c.MaxLimit == 0 {
+		c.MaxLimit = 1000
+	}
+	if c.InitialLimit == 0 {
+		c.InitialLimit = min(max(20, c.MinLimit), c.MaxLimit)
+	}
+	if c.Window == 0 {
+		c.Window = 1 * time.Second
+	}
+	if c.Tolerance == 0 {
+		c.Tolerance = 1.5
+	}
+	if c.BackoffRatio == 0 {
+		c.BackoffRatio = 0.9
+	}
+}
+
+func (c *CostConfig) setDefaults() {
+	if c.ComputeUnits == 0 {
+		c.ComputeUnits = 1
+	}
+}
+
 func (l *LabelBalancingConfig) setDefaults() {
 	if l == nil {
 		return
@@ -303,6 +411,9 @@ func (s *ScorePolicyConfig) setDefaults() {
 	if s.CalculationInterval == 0 {
 		s.CalculationInterval = 10 * time.Second
 	}
+	if s.CostTieTolerance == nil {
+		s.CostTieTolerance = new(DefaultCostTieTolerance)
+	}
 	if s.CalculationFunctionName == "" && s.CalculationFunctionFilePath == "" {
 		log.Warn().Msgf("no explicit rating function is specified, '%s' will be used to calculate rating", DefaultLatencyPolicyFuncName)
 		s.CalculationFunctionName = DefaultLatencyPolicyFuncName
@@ -320,12 +431,19 @@ func (u *Upstream) setDefaults(defaults *ChainDefaults, upstreamMode UpstreamMod
 	if u.Options == nil {
 		u.Options = &chains.Options{}
 	}
+	if u.Weight == 0 {
+		u.Weight = 1
+	}
 	configuredChain := chains.GetChain(u.ChainName)
 	setOptionsDefaults(u.Options, defaults, configuredChain.Settings, upstreamMode)
 	if u.FailsafeConfig != nil {
 		if u.FailsafeConfig.RetryConfig != nil {
 			u.FailsafeConfig.RetryConfig.setDefaults()
 		}
+		if u.FailsafeConfig.CircuitBreakerConfig != nil {
+			u.FailsafeConfig.CircuitBreakerConfig.setDefaults()
+		}
+		setMethodPoliciesDefaults(u.FailsafeConfig.MethodPolicies)
 	}
 	if u.HeadConnector == "" && len(u.Connectors) > 0 {
 		if headConnector := u.GetBestConnector(upstreamMode); headConnector != specs.UnknownType {
@@ -335,6 +453,12 @@ func (u *Upstream) setDefaults(defaults *ChainDefaults, upstreamMode UpstreamMod
 	if u.RateLimitAutoTune != nil {
 		u.RateLimitAutoTune.setDefaults()
 	}
+	if u.ConcurrencyLimit != nil {
+		u.ConcurrencyLimit.setDefaults()
+	}
+	if u.Cost != nil {
+		u.Cost.setDefaults()
+	}
 	if u.PollInterval == 0 {
 		pollInterval := getDefaultPollInterval(u.ChainName, upstreamMode)
 		if defaults != nil && defaults.PollInterval != 0 {
@@ -401,6 +525,17 @@ func (r *RetryConfig) setDefaults() {
 	}
 }
 
+func setMethodPoliciesDefaults(policies []*MethodFailsafeConfig) {
+	for _, policy := range policies {
+		if policy.RetryConfig != nil {
+			policy.RetryConfig.setDefaults()
+		}
+		if policy.HedgeConfig != nil {
+			policy.HedgeConfig.setDefaults()
+		}
+	}
+}
+
 func (h *HedgeConfig) setDefaults() {
 	if h.Delay == 0 {
 		h.Delay = 1 * time.Second
@@ -409,3 +544,21 @@ func (h *HedgeConfig) setDefaults() {
 		h.Count = 2
 	}
 }
+
+func (c *CircuitBreakerConfig) setDefaults() {
+	if c.FailureThreshold == 0 {
+		c.FailureThreshold = 5
+	}
+	if c.MinimumRequests == 0 {
+		c.MinimumRequests = 20
+	}
+	if c.Window == 0 {
+		c.Window = 30 * time.Second
+	}
+	if c.OpenDuration == 0 {
+		c.OpenDuration = 30 * time.Second
+	}
+	if c.HalfOpenSuccesses == 0 {
+		c.HalfOpenSuccesses = 3
+	}
+}
@@ -13,15 +13,15 @@ func TestLocalSubSettingsDefaultsEnabled(t *testing.T) {
 	// nil receiver, no chain-defaults, and a chain without the block all default
 	// to everything enabled - preserving the always-synthesize-locally behavior.
 	var nilCfg *UpstreamConfig
-	assert.Equal(t, LocalSubSettings{NewHeads: true, Logs: true, PendingTx: true}, nilCfg.LocalSubSettings(chains.ETHEREUM.String()))
+	assert.Equal(t, LocalSubSettings{NewHeads: true, Logs: true, PendingTx: true, Filters: true}, nilCfg.LocalSubSettings(chains.ETHEREUM.String()))
 
 	emptyCfg := &UpstreamConfig{}
-	assert.Equal(t, LocalSubSettings{NewHeads: true, Logs: true, PendingTx: true}, emptyCfg.LocalSubSettings(chains.ETHEREUM.String()))
+	assert.Equal(t, LocalSubSettings{NewHeads: true, Logs: true, PendingTx: true, Filters: true}, emptyCfg.LocalSubSettings(chains.ETHEREUM.String()))
 
 	otherChainCfg := &UpstreamConfig{ChainDefaults: map[string]*ChainDefaults{
 		chains.BSC.String(): {LocalSubscriptions: &LocalSubscriptionsConfig{Enable: new(false)}},
 	}}
-	assert.Equal(t, LocalSubSettings{NewHeads: true, Logs: true, PendingTx: true}, otherChainCfg.LocalSubSettings(chains.ETHEREUM.String()))
+	assert.Equal(t, LocalSubSettings{NewHeads: true, Logs: true, PendingTx: true, Filters: true}, otherChainCfg.LocalSubSettings(chains.ETHEREUM.String()))
 }
 
 func TestLocalSubSettingsMasterDisables(t *testing.T) {
@@ -38,7 +38,7 @@ func TestLocalSubSettingsPerTypeOverridesMaster(t *testing.T) {
 			EnableLogs: new(true), // re-enable a single type while master is off
 		}},
 	}}
-	assert.Equal(t, LocalSubSettings{NewHeads: false, Logs: true, PendingTx: false}, cfg.LocalSubSettings(chains.ETHEREUM.String()))
+	assert.Equal(t, LocalSubSettings{NewHeads: false, Logs: true, PendingTx: false, Filters: false}, cfg.LocalSubSettings(chains.ETHEREUM.String()))
 }
 
 func TestLocalSubSettingsPerTypeDisableUnderEnabledMaster(t *testing.T) {
@@ -47,7 +47,7 @@ func TestLocalSubSettingsPerTypeDisableUnderEnabledMaster(t *testing.T) {
 			EnableNewPendingTransactions: new(false), // master unset (defaults true), disable just one
 		}},
 	}}
-	assert.Equal(t, LocalSubSettings{NewHeads: true, Logs: true, PendingTx: false}, cfg.LocalSubSettings(chains.ETHEREUM.String()))
+	assert.Equal(t, LocalSubSettings{NewHeads: true, Logs: true, PendingTx: false, Filters: true}, cfg.LocalSubSettings(chains.ETHEREUM.String()))
 }
 
 func TestLocalSubscriptionsParseFromChainDefaults(t *testing.T) {
@@ -62,5 +62,14 @@ chain-defaults:
 `), &cfg)
 
 	require.NoError(t, err)
-	assert.Equal(t, LocalSubSettings{NewHeads: false, Logs: true, PendingTx: false}, cfg.LocalSubSettings(chains.ETHEREUM.String()))
+	assert.Equal(t, LocalSubSettings{NewHeads: false, Logs: true, PendingTx: false, Filters: false}, cfg.LocalSubSettings(chains.ETHEREUM.String()))
+}
+
+func TestLocalSubSettingsFiltersOverride(t *testing.T) {
+	cfg := &UpstreamConfig{ChainDefaults: map[string]*ChainDefaults{
+		chains.ETHEREUM.String(): {LocalSubscriptions: &LocalSubscriptionsConfig{
+			EnableFilters: new(false), // filters back to the sticky path, subscriptions stay local
+		}},
+	}}
+	assert.Equal(t, LocalSubSettings{NewHeads: true, Logs: true, PendingTx: true, Filters: false}, cfg.LocalSubSettings(chains.ETHEREUM.String()))
 }
@@ -30,6 +30,35 @@ type RateLimitRule struct {
 	Period   time.Duration `yaml:"period"`
 }
 
+// ConcurrencyLimitAlgorithm is how the concurrency limit of an upstream adapts to its latency
+type ConcurrencyLimitAlgorithm string
+
+const (
+	// GradientConcurrencyLimit scales the limit by the ratio of the no-load latency to the current one
+	GradientConcurrencyLimit ConcurrencyLimitAlgorithm = "gradient"
+	// AimdConcurrencyLimit increases the limit by one while the latency is fine and cuts it by the backoff ratio once it isn't
+	AimdConcurrencyLimit ConcurrencyLimitAlgorithm = "aimd"
+)
+
+// ConcurrencyLimitConfig limits the number of requests in flight to an upstream, the limit adapts to the upstream latency
+type ConcurrencyLimitConfig struct {
+	Enabled      bool                      `yaml:"enabled"`
+	Algorithm    ConcurrencyLimitAlgorithm `yaml:"algorithm"`
+	InitialLimit int                       `yaml:"initial-limit"`
+	MinLimit     int                       `yaml:"min-limit"`
+	MaxLimit     int                       `yaml:"max-limit"`
+	Window       time.Duration             `yaml:"window"`    // how often the limit is recalculated
+	Tolerance    float64                   `yaml:"tolerance"` // how many times the latency may exceed the no-load latency
+	BackoffRatio float64                   `yaml:"backoff-ratio"`
+}
+
+// InboundRateLimitConfig limits the requests clients send to nodecore, the counters
+// are kept in memory unless a redis app storage is specified
+type InboundRateLimitConfig struct {
+	Storage string          `yaml:"storage"`
+	Rules   []RateLimitRule `yaml:"rules"`
+}
+
 type RateLimitAutoTuneConfig struct {
 	Enabled             bool          `yaml:"enabled"`
 	Period              time.Duration `yaml:"period"`
@@ -100,6 +129,25 @@ func (r *RateLimiterConfig) validate() error {
 	return nil
 }
 
+func (r *InboundRateLimitConfig) validate(storageNames map[string]string) error {
+	if len(r.Rules) == 0 {
+		return errors.New("there are no rate limit rules")
+	}
+	if err := (&RateLimiterConfig{Rules: r.Rules}).validate(); err != nil {
+		return err
+	}
+	if r.Storage != "" {
+		storage, ok := storageNames[r.Storage]
+		if !ok {
+			return fmt.Errorf("non-existent storage '%s'", r.Storage)
+		}
+		if storage != "redis" {
+			return fmt.Errorf("storage '%s' is not a redis storage (type: %s)", r.Storage, storage)
+		}
+	}
+	return nil
+}
+
 func (r *RateLimitAutoTuneConfig) validate() error {
 	if !r.Enabled {
 		return nil
@@ -127,3 +175,41 @@ func (r *RateLimitAutoTuneConfig) validate() error {
 
 	return nil
 }
+
+func (c *ConcurrencyLimitConfig) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+
+	switch c.Algorithm {
+	case GradientConcurrencyLimit, AimdConcurrencyLimit:
+	default:
+		return fmt.Errorf("invalid concurrency limit algorithm - '%s'", c.Algorithm)
+	}
+
+	if c.MinLimit < 1 {
+		return errors.New("min-limit can't be less than 1")
+	}
+
+	if c.MaxLimit < c.MinLimit {
+		return errors.New("max-limit can't be less than min-limit")
+	}
+
+	if c.InitialLimit < c.MinLimit || c.InitialLimit > c.MaxLimit {
+		return errors.New("initial-limit must be between min-limit and max-limit")
+	}
+
+	if c.Window <= 0 {
+		return errors.New("window must be greater than 0")
+	}
+
+	if c.Tolerance < 1 {
+		return errors.New("tolerance can't be less than 1")
+	}
+
+	if c.BackoffRatio <= 0 || c.BackoffRatio >= 1 {
+		return errors.New("backoff-ratio must be between 0 and 1")
+	}
+
+	return nil
+}
@@ -2,6 +2,7 @@ package config_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/stretchr/testify/assert"
@@ -92,3 +93,68 @@ func TestRateLimitAutoTuneInitRateLimitPeriodGreaterThanPeriodThenError(t *testi
 	_, err := config.NewAppConfig()
 	assert.ErrorContains(t, err, "init-rate-limit-period must be less than or equal to the period when auto-tune is enabled")
 }
+
+func TestConcurrencyLimitValidConfig(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/ratelimit/concurrency-limit-valid.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, &config.ConcurrencyLimitConfig{
+		Enabled:      true,
+		Algorithm:    config.AimdConcurrencyLimit,
+		InitialLimit: 20,
+		MinLimit:     1,
+		MaxLimit:     200,
+		Window:       500 * time.Millisecond,
+		Tolerance:    1.5,
+		BackoffRatio: 0.9,
+	}, appConfig.UpstreamConfig.Upstreams[0].ConcurrencyLimit)
+	assert.Equal(t, &config.ConcurrencyLimitConfig{
+		Enabled:      true,
+		Algorithm:    config.GradientConcurrencyLimit,
+		InitialLimit: 20,
+		MinLimit:     1,
+		MaxLimit:     1000,
+		Window:       time.Second,
+		Tolerance:    1.5,
+		BackoffRatio: 0.9,
+	}, appConfig.UpstreamConfig.Upstreams[1].ConcurrencyLimit)
+}
+
+func TestConcurrencyLimitMaxLessThanMinThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/ratelimit/concurrency-limit-max-less-than-min.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, "error during concurrency limit config validation, cause: max-limit can't be less than min-limit")
+}
+
+func TestConcurrencyLimitInvalidAlgorithmThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/ratelimit/concurrency-limit-invalid-algorithm.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, "error during concurrency limit config validation, cause: invalid concurrency limit algorithm - 'vegas'")
+}
+
+func TestValidInboundRateLimit(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/ratelimit/valid-inbound-rate-limit.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, &config.InboundRateLimitConfig{
+		Rules: []config.RateLimitRule{{Pattern: ".*", Requests: 100, Period: time.Second}},
+	}, appConfig.ServerConfig.IpRateLimit)
+	assert.Equal(t, &config.InboundRateLimitConfig{
+		Storage: "redis-storage",
+		Rules:   []config.RateLimitRule{{Method: "eth_call", Requests: 10, Period: time.Second}},
+	}, appConfig.AuthConfig.KeyConfigs[0].LocalKeyConfig.KeySettingsConfig.RateLimit)
+}
+
+func TestInboundRateLimitNonexistentStorageThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/ratelimit/inbound-rate-limit-nonexistent-storage.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, "error during 'key1' key config validation, cause: error during rate limit validation, cause: non-existent storage 'redis-storage'")
+}
+
+func TestInboundIpRateLimitNoRulesThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/ratelimit/inbound-ip-rate-limit-no-rules.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, "error during ip rate limit validation, cause: there are no rate limit rules")
+}
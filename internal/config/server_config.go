@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"net/netip"
+	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -20,7 +21,9 @@ type ServerConfig struct {
 	HealthPort      int              `yaml:"health-port"`
 	TlsConfig       *TlsConfig       `yaml:"tls"`
 	PyroscopeConfig *PyroscopeConfig `yaml:"pyroscope-config"`
+	TracingConfig   *TracingConfig   `yaml:"tracing"`
 	GrpcAuthConfig  *GrpcAuthConfig  `yaml:"grpc-auth"`
+	AdminConfig     *AdminConfig     `yaml:"admin"`
 	TorUrl          string           `yaml:"tor-url"`
 	// TrustedProxies lists CIDRs (or bare IPs) of reverse proxies in front of
 	// nodecore. X-Forwarded-For is only honored when the direct peer matches one
@@ -28,6 +31,13 @@ type ServerConfig struct {
 	// default) keeps the legacy behavior of treating every X-Forwarded-For entry
 	// as a client IP.
 	TrustedProxies []string `yaml:"trusted-proxies"`
+	// ConfigWatchInterval is how often the config file is checked for changes to
+	// be hot reloaded. Zero (the default) disables watching, a reload can still
+	// be triggered with SIGHUP.
+	ConfigWatchInterval time.Duration `yaml:"config-watch-interval"`
+	// IpRateLimit limits the requests of every client ip, the ip is resolved
+	// with respect to TrustedProxies
+	IpRateLimit *InboundRateLimitConfig `yaml:"ip-rate-limit"`
 
 	// trustedProxyPrefixes is TrustedProxies parsed once during validation.
 	trustedProxyPrefixes []netip.Prefix
@@ -56,6 +66,17 @@ func (g *GrpcAuthConfig) Disabled() bool {
 	return !g.Enabled || g.ProviderPrivateKeyPath == ""
 }
 
+// AdminConfig enables the admin API, every request to it must carry the token
+// in the 'Authorization: Bearer <token>' header
+type AdminConfig struct {
+	Port  int    `yaml:"port"`
+	Token string `yaml:"token"`
+}
+
+func (a *AdminConfig) Enabled() bool {
+	return a.Port != 0
+}
+
 type PyroscopeConfig struct {
 	Enabled        bool              `yaml:"enabled"`
 	Url            string            `yaml:"url"`
@@ -80,6 +101,19 @@ func (p *PyroscopeConfig) GetAdditionalTags() map[string]string {
 	return p.AdditionalTags
 }
 
+// TracingConfig enables exporting OpenTelemetry spans to an OTLP HTTP collector
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// Endpoint is the url of the collector, '/v1/traces' is used if it has no path
+	Endpoint string            `yaml:"endpoint"`
+	Headers  map[string]string `yaml:"headers"`
+	// SamplingRatio is the share of traces started by nodecore that are sampled,
+	// traces started by clients follow the sampling decision of their traceparent
+	SamplingRatio float64 `yaml:"sampling-ratio"`
+	// PropagateToUpstreams sends the traceparent header to upstreams with http connectors
+	PropagateToUpstreams bool `yaml:"propagate-to-upstreams"`
+}
+
 type TlsConfig struct {
 	Enabled     bool   `yaml:"enabled"`
 	Certificate string `yaml:"certificate"`
@@ -103,6 +137,12 @@ func (s *ServerConfig) validate() error {
 	if s.HealthPort < 0 {
 		return fmt.Errorf("incorrect health port - %d", s.HealthPort)
 	}
+	if s.AdminConfig.Port < 0 {
+		return fmt.Errorf("incorrect admin port - %d", s.AdminConfig.Port)
+	}
+	if s.ConfigWatchInterval < 0 {
+		return fmt.Errorf("incorrect config watch interval - %s", s.ConfigWatchInterval)
+	}
 
 	ports := mapset.NewThreadUnsafeSet[int](s.Port)
 	if ports.Contains(s.GrpcPort) && s.GrpcPort != 0 {
@@ -120,6 +160,10 @@ func (s *ServerConfig) validate() error {
 	if ports.Contains(s.HealthPort) && s.HealthPort != 0 {
 		return fmt.Errorf("health port %d is already in use", s.HealthPort)
 	}
+	ports.Add(s.HealthPort)
+	if ports.Contains(s.AdminConfig.Port) && s.AdminConfig.Port != 0 {
+		return fmt.Errorf("admin port %d is already in use", s.AdminConfig.Port)
+	}
 
 	trustedProxyPrefixes, err := utils.ParseTrustedProxies(s.TrustedProxies)
 	if err != nil {
@@ -135,10 +179,18 @@ func (s *ServerConfig) validate() error {
 		return err
 	}
 
+	if err := s.TracingConfig.validate(); err != nil {
+		return err
+	}
+
 	if err := s.GrpcAuthConfig.validate(); err != nil {
 		return err
 	}
 
+	if err := s.AdminConfig.validate(); err != nil {
+		return err
+	}
+
 	return nil
 }
 
@@ -170,6 +222,29 @@ func (p *PyroscopeConfig) validate() error {
 	return nil
 }
 
+func (t *TracingConfig) validate() error {
+	if t.SamplingRatio < 0 || t.SamplingRatio > 1 {
+		return fmt.Errorf("tracing sampling-ratio must be between 0 and 1, got %v", t.SamplingRatio)
+	}
+	if t.Enabled {
+		if t.Endpoint == "" {
+			return errors.New("tracing is enabled, endpoint must be specified")
+		}
+		endpointUrl, err := url.Parse(t.Endpoint)
+		if err != nil || (endpointUrl.Scheme != "http" && endpointUrl.Scheme != "https") || endpointUrl.Host == "" {
+			return fmt.Errorf("tracing endpoint '%s' must be an http or https url", t.Endpoint)
+		}
+	}
+	return nil
+}
+
+func (a *AdminConfig) validate() error {
+	if a.Enabled() && strings.TrimSpace(a.Token) == "" {
+		return errors.New("admin api is enabled, token must be specified")
+	}
+	return nil
+}
+
 func (g *GrpcAuthConfig) validate() error {
 	if !g.Enabled {
 		return nil
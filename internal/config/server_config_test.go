@@ -21,11 +21,13 @@ func TestServerConfig(t *testing.T) {
 		PprofPort:       6061,
 		HealthPort:      9096,
 		PyroscopeConfig: &config.PyroscopeConfig{},
+		TracingConfig:   &config.TracingConfig{SamplingRatio: 1},
 		TlsConfig:       &config.TlsConfig{},
 		GrpcAuthConfig: &config.GrpcAuthConfig{
 			PublicKeyOwner: "drpc",
 			SessionTTL:     24 * time.Hour,
 		},
+		AdminConfig: &config.AdminConfig{},
 	}
 
 	assert.Equal(t, &expected, appConfig.ServerConfig)
@@ -108,6 +110,24 @@ func TestPyroConfigNoPasswordThenError(t *testing.T) {
 	assert.ErrorContains(t, err, `pyroscope is enabled, password must be specified`)
 }
 
+func TestTracingConfigNoEndpointThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/server/server-config-tracing-no-endpoint.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, `tracing is enabled, endpoint must be specified`)
+}
+
+func TestTracingConfigWrongEndpointThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/server/server-config-tracing-wrong-endpoint.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, `tracing endpoint 'localhost:4318' must be an http or https url`)
+}
+
+func TestTracingConfigWrongSamplingRatioThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/server/server-config-tracing-wrong-sampling-ratio.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, `tracing sampling-ratio must be between 0 and 1, got 1.5`)
+}
+
 func TestTlsEnabledNoCertificateThenError(t *testing.T) {
 	t.Setenv(config.ConfigPathVar, "configs/server/server-config-tls-enabled-no-cert.yaml")
 	_, err := config.NewAppConfig()
@@ -119,3 +139,17 @@ func TestTlsEnabledNoKeyThenError(t *testing.T) {
 	_, err := config.NewAppConfig()
 	assert.ErrorContains(t, err, "tls config validation error - the tls certificate key can't be empty")
 }
+
+func TestServerConfigWrongConfigWatchIntervalThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/server/server-config-wrong-config-watch-interval.yaml")
+	_, err := config.NewAppConfig()
+
+	assert.ErrorContains(t, err, "incorrect config watch interval - -5s")
+}
+
+func TestServerConfigAdminWithoutTokenThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/server/server-config-admin-no-token.yaml")
+	_, err := config.NewAppConfig()
+
+	assert.ErrorContains(t, err, "admin api is enabled, token must be specified")
+}
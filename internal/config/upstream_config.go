@@ -6,6 +6,7 @@ import (
 	"maps"
 	"net/url"
 	"os"
+	"path"
 	"slices"
 	"strings"
 	"time"
@@ -29,16 +30,23 @@ type UpstreamConfig struct {
 	IntegrityConfig   *IntegrityConfig          `yaml:"integrity"`
 	LabelBalancing    *LabelBalancingConfig     `yaml:"label-balancing"`
 	BalancingStrategy BalancingStrategy         `yaml:"balancing-strategy"`
+	CostSlo           *CostSloConfig            `yaml:"cost-slo"`
 	Mode              UpstreamMode              `yaml:"mode"`
 }
 
 // BalancingStrategy selects how the generic (non-special-cased) request is
-// routed to an upstream: rating-ordered selection or plain round-robin.
+// routed to an upstream: rating-ordered selection, plain round-robin, weighted
+// random selection, power-of-two-choices on the live upstream load or the
+// cheapest upstream that meets the cost SLO.
 type BalancingStrategy string
 
 const (
-	RatingBalancingStrategy BalancingStrategy = "rating"
-	BaseBalancingStrategy   BalancingStrategy = "base"
+	RatingBalancingStrategy         BalancingStrategy = "rating"
+	BaseBalancingStrategy           BalancingStrategy = "base"
+	WeightedRatingBalancingStrategy BalancingStrategy = "weighted-rating"
+	WeightedBalancingStrategy       BalancingStrategy = "weighted"
+	P2CBalancingStrategy            BalancingStrategy = "p2c"
+	CostBalancingStrategy           BalancingStrategy = "cost"
 )
 
 // validate accepts an empty value as "inherit/default" (resolved to rating by
@@ -46,7 +54,7 @@ const (
 // unknown non-empty value.
 func (s BalancingStrategy) validate() error {
 	switch s {
-	case "", RatingBalancingStrategy, BaseBalancingStrategy:
+	case "", RatingBalancingStrategy, BaseBalancingStrategy, WeightedRatingBalancingStrategy, WeightedBalancingStrategy, P2CBalancingStrategy, CostBalancingStrategy:
 		return nil
 	default:
 		return fmt.Errorf("invalid balancing strategy - '%s'", s)
@@ -75,6 +83,16 @@ func (u *UpstreamConfig) LabelBalancingFor(chain string) *LabelBalancingConfig {
 	return u.LabelBalancing
 }
 
+// CostSloFor resolves the effective cost SLO for a chain:
+// a per-chain override under chain-defaults wins over the global default; if
+// neither is set it returns nil, every upstream meets the SLO.
+func (u *UpstreamConfig) CostSloFor(chain string) *CostSloConfig {
+	if chainDefaults, ok := u.ChainDefaults[chain]; ok && chainDefaults != nil && chainDefaults.CostSlo != nil {
+		return chainDefaults.CostSlo
+	}
+	return u.CostSlo
+}
+
 type UpstreamMode string
 
 const (
@@ -129,8 +147,11 @@ type Upstream struct {
 	RateLimitBudget   string                   `yaml:"rate-limit-budget"`
 	RateLimit         *RateLimiterConfig       `yaml:"rate-limit"`
 	RateLimitAutoTune *RateLimitAutoTuneConfig `yaml:"rate-limit-auto-tune"`
+	ConcurrencyLimit  *ConcurrencyLimitConfig  `yaml:"concurrency-limit"`
 	GroupLabels       []string                 `yaml:"group-labels"`
 	Labels            UpstreamLabels           `yaml:"labels"`
+	Weight            int                      `yaml:"weight"` // a share of requests with the weighted balancing strategy
+	Cost              *CostConfig              `yaml:"cost"`
 }
 
 // UpstreamLabels is a manual upstream label map. Label values are strings, but any
@@ -216,6 +237,25 @@ type ChainDefaults struct {
 	LocalSubscriptions *LocalSubscriptionsConfig `yaml:"local-subscriptions"`
 	ValidateLag        *bool                     `yaml:"validate-lag"`
 	BalancingStrategy  BalancingStrategy         `yaml:"balancing-strategy"`
+	CostSlo            *CostSloConfig            `yaml:"cost-slo"`
+	CoalesceRequests   *bool                     `yaml:"coalesce-requests"`
+	// FailsafeMethodPolicies are the failsafe method policies of the chain, they are checked before the global ones
+	FailsafeMethodPolicies []*MethodFailsafeConfig `yaml:"failsafe-method-policies"`
+}
+
+// CoalesceRequestsFor resolves whether identical in-flight requests of the chain
+// share one upstream call. A per-chain chain-defaults override wins; otherwise
+// it's enabled.
+func (u *UpstreamConfig) CoalesceRequestsFor(chainName string) bool {
+	if u == nil {
+		return true
+	}
+	if u.ChainDefaults != nil {
+		if defaults, ok := u.ChainDefaults[chainName]; ok && defaults != nil && defaults.CoalesceRequests != nil {
+			return *defaults.CoalesceRequests
+		}
+	}
+	return true
 }
 
 // ValidateLagFor resolves whether the chain supervisor should derive upstream
@@ -235,9 +275,10 @@ func (u *UpstreamConfig) ValidateLagFor(chainName string) bool {
 }
 
 // LocalSubscriptionsConfig controls per-chain local subscription synthesis
-// (newHeads/logs/newPendingTransactions). Enable is the master switch; per-type
-// fields override it. All default to true (enabled), preserving the behavior of
-// always synthesizing locally when the chain has the capability. The synthetic
+// (newHeads/logs/newPendingTransactions) and the local EVM filters backed by the
+// newHeads and logs sources. Enable is the master switch; per-type fields
+// override it. All default to true (enabled), preserving the behavior of always
+// synthesizing locally when the chain has the capability. The synthetic
 // drpc_pendingTransactions method has no node-backed equivalent and is never
 // affected by these flags.
 type LocalSubscriptionsConfig struct {
@@ -245,6 +286,7 @@ type LocalSubscriptionsConfig struct {
 	EnableNewHeads               *bool `yaml:"enable-new-heads"` // explicit override, wins over Enable
 	EnableLogs                   *bool `yaml:"enable-logs"`
 	EnableNewPendingTransactions *bool `yaml:"enable-new-pending-transactions"`
+	EnableFilters                *bool `yaml:"enable-filters"` // eth_newFilter/eth_newBlockFilter owned by nodecore
 }
 
 // LocalSubSettings is the resolved per-chain decision for which local
@@ -253,6 +295,7 @@ type LocalSubSettings struct {
 	NewHeads  bool
 	Logs      bool
 	PendingTx bool
+	Filters   bool
 }
 
 // LocalSubSettings resolves the effective local-synthesis decision for a chain.
@@ -260,7 +303,7 @@ type LocalSubSettings struct {
 // GetDispatchOptions. Per type: explicit per-type value wins, else the master
 // Enable, else true (enabled).
 func (u *UpstreamConfig) LocalSubSettings(chainName string) LocalSubSettings {
-	settings := LocalSubSettings{NewHeads: true, Logs: true, PendingTx: true}
+	settings := LocalSubSettings{NewHeads: true, Logs: true, PendingTx: true, Filters: true}
 	if u == nil || u.ChainDefaults == nil {
 		return settings
 	}
@@ -273,6 +316,7 @@ func (u *UpstreamConfig) LocalSubSettings(chainName string) LocalSubSettings {
 	settings.NewHeads = lo.FromPtrOr(ls.EnableNewHeads, base)
 	settings.Logs = lo.FromPtrOr(ls.EnableLogs, base)
 	settings.PendingTx = lo.FromPtrOr(ls.EnableNewPendingTransactions, base)
+	settings.Filters = lo.FromPtrOr(ls.EnableFilters, base)
 	return settings
 }
 
@@ -312,6 +356,65 @@ func (l *LabelBalancingConfig) validate() error {
 	return nil
 }
 
+// CostConfig is the pricing of an upstream, a request costs its price plus its compute units
+// multiplied by the price of a compute unit. Methods overrides the price and the compute units
+// of methods or method groups of the chain method spec, a method wins over its group
+type CostConfig struct {
+	PricePerRequest     float64                      `yaml:"price-per-request"`
+	PricePerComputeUnit float64                      `yaml:"price-per-compute-unit"`
+	ComputeUnits        float64                      `yaml:"compute-units"`
+	Methods             map[string]*MethodCostConfig `yaml:"methods"`
+}
+
+type MethodCostConfig struct {
+	PricePerRequest *float64 `yaml:"price-per-request"`
+	ComputeUnits    *float64 `yaml:"compute-units"`
+}
+
+func (c *CostConfig) validate() error {
+	if c.PricePerRequest < 0 {
+		return errors.New("price-per-request can't be less than 0")
+	}
+	if c.PricePerComputeUnit < 0 {
+		return errors.New("price-per-compute-unit can't be less than 0")
+	}
+	if c.ComputeUnits < 0 {
+		return errors.New("compute-units can't be less than 0")
+	}
+	for _, method := range slices.Sorted(maps.Keys(c.Methods)) {
+		methodCost := c.Methods[method]
+		if method == "" {
+			return errors.New("cost methods must not contain an empty method")
+		}
+		if methodCost == nil {
+			continue
+		}
+		if methodCost.PricePerRequest != nil && *methodCost.PricePerRequest < 0 {
+			return fmt.Errorf("price-per-request of '%s' can't be less than 0", method)
+		}
+		if methodCost.ComputeUnits != nil && *methodCost.ComputeUnits < 0 {
+			return fmt.Errorf("compute-units of '%s' can't be less than 0", method)
+		}
+	}
+	return nil
+}
+
+// CostSloConfig is the SLO an upstream must meet to be picked by the cost balancing strategy,
+// a zero value means no limit
+type CostSloConfig struct {
+	// MaxLatency is the limit of the p90 latency of a method on an upstream
+	MaxLatency time.Duration `yaml:"max-latency"`
+	// MaxHeadLag is the limit of the upstream head lag in blocks
+	MaxHeadLag uint64 `yaml:"max-head-lag"`
+}
+
+func (c *CostSloConfig) validate() error {
+	if c.MaxLatency < 0 {
+		return errors.New("cost-slo max-latency can't be less than 0")
+	}
+	return nil
+}
+
 type DispatchOptions struct {
 	Broadcast    *bool `yaml:"broadcast"`
 	MaximumValue *bool `yaml:"maximum-value"`
@@ -319,15 +422,20 @@ type DispatchOptions struct {
 }
 
 type FailsafeConfig struct {
-	HedgeConfig   *HedgeConfig   `yaml:"hedge"`
-	TimeoutConfig *TimeoutConfig `yaml:"timeout"`
-	RetryConfig   *RetryConfig   `yaml:"retry"`
+	HedgeConfig          *HedgeConfig            `yaml:"hedge"`
+	TimeoutConfig        *TimeoutConfig          `yaml:"timeout"`
+	RetryConfig          *RetryConfig            `yaml:"retry"`
+	CircuitBreakerConfig *CircuitBreakerConfig   `yaml:"circuit-breaker"`
+	MethodPolicies       []*MethodFailsafeConfig `yaml:"method-policies"`
 }
 
 type ScorePolicyConfig struct {
 	CalculationInterval         time.Duration `yaml:"calculation-interval"`
 	CalculationFunctionName     string        `yaml:"calculation-function-name"`      // a func name from a 'defaultRatingFunctions' map
 	CalculationFunctionFilePath string        `yaml:"calculation-function-file-path"` // a path to the file with a function
+	// CostTieTolerance is how much the scores of upstreams may differ, relative to the higher one,
+	// for the upstreams to be tied, the cheaper one of tied upstreams goes first
+	CostTieTolerance *float64 `yaml:"cost-tie-tolerance"`
 
 	calculationFunc goja.Callable
 }
@@ -418,10 +526,82 @@ type HedgeConfig struct { // works only on the execution flow level
 	Count int           `yaml:"max"`
 }
 
-type TimeoutConfig struct {
+type TimeoutConfig struct { // works only on the execution flow level
 	Timeout time.Duration `yaml:"duration"`
 }
 
+// MethodFailsafeConfig overrides the failsafe config for the methods that match one of its names or
+// path.Match patterns, e.g. "debug_*". The set sections replace the ones of the failsafe config,
+// the rest are inherited from it
+type MethodFailsafeConfig struct {
+	Methods        []string       `yaml:"methods"`
+	HedgeConfig    *HedgeConfig   `yaml:"hedge"`
+	TimeoutConfig  *TimeoutConfig `yaml:"timeout"`
+	RetryConfig    *RetryConfig   `yaml:"retry"`
+	DisableHedge   bool           `yaml:"disable-hedge"`
+	DisableTimeout bool           `yaml:"disable-timeout"`
+	DisableRetry   bool           `yaml:"disable-retry"`
+}
+
+func (m *MethodFailsafeConfig) Matches(method string) bool {
+	return slices.ContainsFunc(m.Methods, func(pattern string) bool {
+		matched, _ := path.Match(pattern, method)
+		return matched
+	})
+}
+
+// Apply returns the failsafe config of the matched methods
+func (m *MethodFailsafeConfig) Apply(base *FailsafeConfig) *FailsafeConfig {
+	result := *base
+	result.MethodPolicies = nil
+	if m.HedgeConfig != nil {
+		result.HedgeConfig = m.HedgeConfig
+	}
+	if m.TimeoutConfig != nil {
+		result.TimeoutConfig = m.TimeoutConfig
+	}
+	if m.RetryConfig != nil {
+		result.RetryConfig = m.RetryConfig
+	}
+	if m.DisableHedge {
+		result.HedgeConfig = nil
+	}
+	if m.DisableTimeout {
+		result.TimeoutConfig = nil
+	}
+	if m.DisableRetry {
+		result.RetryConfig = nil
+	}
+	return &result
+}
+
+// FailsafeMethodPoliciesFor resolves the failsafe method policies of the chain,
+// the chain-defaults ones go first and win over the global ones
+func (u *UpstreamConfig) FailsafeMethodPoliciesFor(chainName string) []*MethodFailsafeConfig {
+	policies := make([]*MethodFailsafeConfig, 0)
+	if u == nil {
+		return policies
+	}
+	if defaults, ok := u.ChainDefaults[chainName]; ok && defaults != nil {
+		policies = append(policies, defaults.FailsafeMethodPolicies...)
+	}
+	if u.FailsafeConfig != nil {
+		policies = append(policies, u.FailsafeConfig.MethodPolicies...)
+	}
+	return policies
+}
+
+// CircuitBreakerConfig stops sending requests to an upstream that keeps failing, works on the upstream level
+type CircuitBreakerConfig struct {
+	FailureThreshold     int           `yaml:"failure-threshold"`      // consecutive failures that open the circuit
+	FailureRateThreshold float64       `yaml:"failure-rate-threshold"` // if set, a failure rate within the window opens the circuit instead of consecutive failures
+	MinimumRequests      int           `yaml:"minimum-requests"`       // the failure rate isn't checked until the window has that many requests
+	Window               time.Duration `yaml:"window"`
+	OpenDuration         time.Duration `yaml:"open-duration"`
+	HalfOpenSuccesses    int           `yaml:"half-open-successes"`
+	PerMethod            bool          `yaml:"per-method"`
+}
+
 type MethodsConfig struct {
 	BanDuration    time.Duration `yaml:"ban-duration"`
 	EnableMethods  []string      `yaml:"enable"`
@@ -446,6 +626,12 @@ func (u *UpstreamConfig) validate(rateLimitBudgetNames mapset.Set[string], torPr
 		return err
 	}
 
+	if u.CostSlo != nil {
+		if err := u.CostSlo.validate(); err != nil {
+			return err
+		}
+	}
+
 	for chain, chainDefault := range u.ChainDefaults {
 		if !chains.IsSupported(chain) {
 			return fmt.Errorf("error during chain defaults validation, cause: not supported chain %s", chain)
@@ -483,6 +669,11 @@ func (u *UpstreamConfig) validate(rateLimitBudgetNames mapset.Set[string], torPr
 				return fmt.Errorf("error during rate limit auto-tune config validation, cause: %s", err.Error())
 			}
 		}
+		if upstream.ConcurrencyLimit != nil {
+			if err := upstream.ConcurrencyLimit.validate(); err != nil {
+				return fmt.Errorf("error during concurrency limit config validation, cause: %s", err.Error())
+			}
+		}
 		idSet.Add(upstream.Id)
 	}
 
@@ -496,6 +687,9 @@ func (s *ScorePolicyConfig) validate() error {
 	if s.CalculationFunctionName != "" && s.CalculationFunctionFilePath != "" {
 		return errors.New("one setting must be specified - either 'calculation-function' or 'calculation-function-file-path'")
 	}
+	if s.CostTieTolerance != nil && (*s.CostTieTolerance < 0 || *s.CostTieTolerance >= 1) {
+		return errors.New("cost-tie-tolerance must be at least 0 and less than 1")
+	}
 	if s.CalculationFunctionName != "" {
 		_, ok := defaultRatingFunctions[s.CalculationFunctionName]
 		if !ok {
@@ -520,6 +714,90 @@ func (f *FailsafeConfig) validate() error {
 			return fmt.Errorf("retry config validation error - %s", err.Error())
 		}
 	}
+	if f.TimeoutConfig != nil {
+		if err := f.TimeoutConfig.validate(); err != nil {
+			return fmt.Errorf("timeout config validation error - %s", err.Error())
+		}
+	}
+	if f.CircuitBreakerConfig != nil {
+		if err := f.CircuitBreakerConfig.validate(); err != nil {
+			return fmt.Errorf("circuit breaker config validation error - %s", err.Error())
+		}
+	}
+	return validateMethodPolicies(f.MethodPolicies)
+}
+
+func validateMethodPolicies(policies []*MethodFailsafeConfig) error {
+	for i, policy := range policies {
+		if err := policy.validate(); err != nil {
+			return fmt.Errorf("method policy under index %d validation error - %s", i, err.Error())
+		}
+	}
+	return nil
+}
+
+func (m *MethodFailsafeConfig) validate() error {
+	if len(m.Methods) == 0 {
+		return errors.New("there must be at least one method")
+	}
+	for _, pattern := range m.Methods {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid method pattern '%s'", pattern)
+		}
+	}
+	if m.HedgeConfig != nil {
+		if m.DisableHedge {
+			return errors.New("hedge can't be both set and disabled")
+		}
+		if err := m.HedgeConfig.validate(); err != nil {
+			return fmt.Errorf("hedge config validation error - %s", err.Error())
+		}
+	}
+	if m.TimeoutConfig != nil {
+		if m.DisableTimeout {
+			return errors.New("timeout can't be both set and disabled")
+		}
+		if err := m.TimeoutConfig.validate(); err != nil {
+			return fmt.Errorf("timeout config validation error - %s", err.Error())
+		}
+	}
+	if m.RetryConfig != nil {
+		if m.DisableRetry {
+			return errors.New("retry can't be both set and disabled")
+		}
+		if err := m.RetryConfig.validate(); err != nil {
+			return fmt.Errorf("retry config validation error - %s", err.Error())
+		}
+	}
+	return nil
+}
+
+func (t *TimeoutConfig) validate() error {
+	if t.Timeout <= 0 {
+		return errors.New("the timeout duration must be > 0")
+	}
+	return nil
+}
+
+func (c *CircuitBreakerConfig) validate() error {
+	if c.FailureThreshold < 1 {
+		return errors.New("the failure threshold can't be less than 1")
+	}
+	if c.FailureRateThreshold < 0 || c.FailureRateThreshold > 1 {
+		return errors.New("the failure rate threshold must be in (0, 1]")
+	}
+	if c.MinimumRequests < 1 {
+		return errors.New("the minimum number of requests can't be less than 1")
+	}
+	if c.Window <= 0 {
+		return errors.New("the window must be > 0")
+	}
+	if c.OpenDuration <= 0 {
+		return errors.New("the open duration must be > 0")
+	}
+	if c.HalfOpenSuccesses < 1 {
+		return errors.New("the number of half-open successes can't be less than 1")
+	}
 	return nil
 }
 
@@ -607,6 +885,16 @@ func (u *Upstream) validate(torProxyUrl string) error {
 		return err
 	}
 
+	if u.Weight < 1 {
+		return errors.New("the weight can't be less than 1")
+	}
+
+	if u.Cost != nil {
+		if err := u.Cost.validate(); err != nil {
+			return fmt.Errorf("error during cost validation, cause: %s", err.Error())
+		}
+	}
+
 	for _, label := range u.GroupLabels {
 		if label == "" {
 			return errors.New("group-labels must not contain an empty label")
@@ -678,9 +966,21 @@ func (c *ChainDefaults) validate() error {
 	if err := c.BalancingStrategy.validate(); err != nil {
 		return err
 	}
+	if c.CostSlo != nil {
+		if err := c.CostSlo.validate(); err != nil {
+			return err
+		}
+	}
+	if err := validateMethodPolicies(c.FailsafeMethodPolicies); err != nil {
+		return fmt.Errorf("failsafe validation error - %s", err.Error())
+	}
 	return nil
 }
 
+// grpcConnectorSchemes are the accepted schemes of a grpc connector url,
+// grpc and http mean plaintext, grpcs and https mean TLS.
+var grpcConnectorSchemes = []string{"grpc", "grpcs", "http", "https"}
+
 func (a *ApiConnectorConfig) validate(torProxyUrl string) error {
 	if err := specs.ValidateApiConnectorType(a.Type); err != nil {
 		return err
@@ -696,6 +996,9 @@ func (a *ApiConnectorConfig) validate(torProxyUrl string) error {
 	if parsedUrl.Scheme == "" || parsedUrl.Host == "" {
 		return fmt.Errorf("invalid url for connector '%s' - scheme and host are required", a.Type)
 	}
+	if a.GetApiConnectorType() == specs.GrpcConnector && !slices.Contains(grpcConnectorSchemes, parsedUrl.Scheme) {
+		return fmt.Errorf("invalid url for connector '%s' - scheme must be one of %v", a.Type, grpcConnectorSchemes)
+	}
 	if strings.HasSuffix(parsedUrl.Hostname(), ".onion") {
 		if torProxyUrl == "" {
 			return errors.New("tor proxy url is required for onion endpoints")
@@ -84,6 +84,7 @@ func TestSetDefaultPollInterval(t *testing.T) {
 		HeadConnector:  specs.JsonRpcConnector.String(),
 		PollInterval:   1 * time.Minute,
 		ChainName:      "ethereum",
+		Weight:         1,
 		FailsafeConfig: &config.FailsafeConfig{},
 		Connectors: []*config.ApiConnectorConfig{
 			{
@@ -131,6 +132,7 @@ func TestSetDefaultJsonRpcHeadConnector(t *testing.T) {
 		HeadConnector: specs.JsonRpcConnector.String(),
 		PollInterval:  1 * time.Minute,
 		ChainName:     "ethereum",
+		Weight:        1,
 		Methods: &config.MethodsConfig{
 			BanDuration: 5 * time.Minute,
 		},
@@ -181,6 +183,7 @@ func TestSetDefaultRestHeadConnector(t *testing.T) {
 		HeadConnector: specs.RestConnector.String(),
 		PollInterval:  1 * time.Minute,
 		ChainName:     "ethereum",
+		Weight:        1,
 		Methods: &config.MethodsConfig{
 			BanDuration: 5 * time.Minute,
 		},
@@ -441,6 +444,7 @@ func TestSetChainsDefault(t *testing.T) {
 					HeadConnector:  specs.JsonRpcConnector.String(),
 					PollInterval:   10 * time.Minute,
 					ChainName:      "ethereum",
+					Weight:         1,
 					FailsafeConfig: &config.FailsafeConfig{},
 					Connectors: []*config.ApiConnectorConfig{
 						{
@@ -485,6 +489,7 @@ func TestScorePolicyConfigFilePath(t *testing.T) {
 	expected := &config.ScorePolicyConfig{
 		CalculationInterval:         10 * time.Second,
 		CalculationFunctionFilePath: "configs/upstreams/func.ts",
+		CostTieTolerance:            new(config.DefaultCostTieTolerance),
 	}
 
 	assert.Nil(t, err)
@@ -503,6 +508,12 @@ func TestScorePolicyConfigInvalidIntervalThenError(t *testing.T) {
 	assert.ErrorContains(t, err, "error during score policy config validation, cause: the calculation interval can't be less than 0")
 }
 
+func TestScorePolicyConfigInvalidCostTieToleranceThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/upstreams/invalid-score-policy-cost-tie-tolerance.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, "error during score policy config validation, cause: cost-tie-tolerance must be at least 0 and less than 1")
+}
+
 func TestScorePolicyConfigNoSortFuncThenError(t *testing.T) {
 	t.Setenv(config.ConfigPathVar, "configs/upstreams/no-score-policy-func.yaml")
 	_, err := config.NewAppConfig()
@@ -533,6 +544,104 @@ func TestRetryConfigJitterIsZeroThenError(t *testing.T) {
 	assert.ErrorContains(t, err, `error during upstream 'eth-upstream' validation, cause: retry config validation error - the retry jitter can't be 0`)
 }
 
+func TestCircuitBreakerFailureRateThresholdGreaterOneThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/upstreams/circuit-breaker-rate-threshold.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, `error during upstream 'eth-upstream' validation, cause: circuit breaker config validation error - the failure rate threshold must be in (0, 1]`)
+}
+
+func TestCircuitBreakerGlobalIsDefaultOfUpstreams(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/upstreams/circuit-breaker-global-and-upstream.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	globalCircuitBreaker := &config.CircuitBreakerConfig{
+		FailureThreshold:  10,
+		MinimumRequests:   20,
+		Window:            30 * time.Second,
+		OpenDuration:      30 * time.Second,
+		HalfOpenSuccesses: 3,
+		PerMethod:         true,
+	}
+	upstreamCircuitBreaker := &config.CircuitBreakerConfig{
+		FailureThreshold:  5,
+		MinimumRequests:   20,
+		Window:            30 * time.Second,
+		OpenDuration:      time.Minute,
+		HalfOpenSuccesses: 3,
+	}
+	assert.Equal(t, globalCircuitBreaker, appConfig.UpstreamConfig.FailsafeConfig.CircuitBreakerConfig)
+	assert.Equal(t, globalCircuitBreaker, appConfig.UpstreamConfig.Upstreams[0].FailsafeConfig.CircuitBreakerConfig)
+	assert.Equal(t, upstreamCircuitBreaker, appConfig.UpstreamConfig.Upstreams[1].FailsafeConfig.CircuitBreakerConfig)
+}
+
+func TestFailsafeMethodPolicies(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/upstreams/failsafe-method-policies.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	sendTxPolicy := &config.MethodFailsafeConfig{
+		Methods:      []string{"eth_sendRawTransaction"},
+		DisableHedge: true,
+		DisableRetry: true,
+	}
+	tracePolicy := &config.MethodFailsafeConfig{
+		Methods:       []string{"debug_*", "trace_*"},
+		TimeoutConfig: &config.TimeoutConfig{Timeout: time.Minute},
+		RetryConfig:   &config.RetryConfig{Attempts: 1},
+	}
+	logsPolicy := &config.MethodFailsafeConfig{
+		Methods:     []string{"eth_getLogs"},
+		HedgeConfig: &config.HedgeConfig{Delay: 2 * time.Second, Count: 2},
+	}
+	upstreamConfig := appConfig.UpstreamConfig
+	assert.Equal(t, []*config.MethodFailsafeConfig{sendTxPolicy, tracePolicy}, upstreamConfig.FailsafeConfig.MethodPolicies)
+	assert.Equal(t, []*config.MethodFailsafeConfig{logsPolicy, sendTxPolicy, tracePolicy}, upstreamConfig.FailsafeMethodPoliciesFor("ethereum"))
+	assert.Equal(t, []*config.MethodFailsafeConfig{sendTxPolicy, tracePolicy}, upstreamConfig.FailsafeMethodPoliciesFor("polygon"))
+	assert.Equal(
+		t,
+		[]*config.MethodFailsafeConfig{{Methods: []string{"eth_getLogs"}, RetryConfig: &config.RetryConfig{Attempts: 1}}},
+		upstreamConfig.Upstreams[0].FailsafeConfig.MethodPolicies,
+	)
+}
+
+func TestFailsafeMethodPolicyInvalidPatternThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/upstreams/failsafe-method-policies-invalid-pattern.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, "error during failsafe validation of upstream-conifg: method policy under index 0 validation error - invalid method pattern 'debug_['")
+}
+
+func TestFailsafeMethodPolicySetAndDisabledThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/upstreams/failsafe-method-policies-set-and-disabled.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, "error during chain 'ethereum' defaults validation, cause: failsafe validation error - method policy under index 0 validation error - retry can't be both set and disabled")
+}
+
+func TestMethodFailsafeConfigApply(t *testing.T) {
+	base := &config.FailsafeConfig{
+		HedgeConfig:          &config.HedgeConfig{Delay: time.Second, Count: 2},
+		TimeoutConfig:        &config.TimeoutConfig{Timeout: 10 * time.Second},
+		RetryConfig:          &config.RetryConfig{Attempts: 3},
+		CircuitBreakerConfig: &config.CircuitBreakerConfig{FailureThreshold: 5},
+		MethodPolicies:       []*config.MethodFailsafeConfig{{Methods: []string{"eth_call"}}},
+	}
+	policy := &config.MethodFailsafeConfig{
+		Methods:        []string{"trace_*"},
+		RetryConfig:    &config.RetryConfig{Attempts: 1},
+		DisableTimeout: true,
+	}
+
+	assert.True(t, policy.Matches("trace_block"))
+	assert.False(t, policy.Matches("eth_call"))
+	assert.Equal(t, &config.FailsafeConfig{
+		HedgeConfig:          base.HedgeConfig,
+		RetryConfig:          policy.RetryConfig,
+		CircuitBreakerConfig: base.CircuitBreakerConfig,
+	}, policy.Apply(base))
+	assert.Len(t, base.MethodPolicies, 1)
+	assert.NotNil(t, base.TimeoutConfig)
+}
+
 func TestRetryConfigDelayGreaterMaxDelayThenError(t *testing.T) {
 	t.Setenv(config.ConfigPathVar, "configs/upstreams/retry-config-delay-greater-max-delay.yaml")
 	_, err := config.NewAppConfig()
@@ -563,6 +672,12 @@ func TestInvalidConnectorUrlThenError(t *testing.T) {
 	assert.ErrorContains(t, err, "invalid url for connector 'rest' -")
 }
 
+func TestInvalidGrpcConnectorSchemeThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/upstreams/invalid-grpc-connector-scheme.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, "invalid url for connector 'grpc' - scheme must be one of [grpc grpcs http https]")
+}
+
 func TestOnionEndpointWithTorProxyThenSuccess(t *testing.T) {
 	t.Setenv(config.ConfigPathVar, "configs/upstreams/tor-onion-with-proxy.yaml")
 	appConfig, err := config.NewAppConfig()
@@ -586,6 +701,49 @@ func TestUpstreamOptionsInvalidValidationIntervalThenError(t *testing.T) {
 	assert.ErrorContains(t, err, "error during upstream 'eth-upstream' validation, cause: validation interval can't be less than 0")
 }
 
+func TestUpstreamWeightNegativeThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/upstreams/upstream-weight-negative.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, "error during upstream 'eth-upstream' validation, cause: the weight can't be less than 1")
+}
+
+func TestUpstreamWeightWithWeightedStrategy(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/upstreams/upstream-weight.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	assert.Equal(t, config.WeightedBalancingStrategy, appConfig.UpstreamConfig.BalancingStrategyFor("ethereum"))
+	assert.Equal(t, 3, appConfig.UpstreamConfig.Upstreams[0].Weight)
+	assert.Equal(t, 1, appConfig.UpstreamConfig.Upstreams[1].Weight)
+}
+
+func TestUpstreamCost(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/upstreams/upstream-cost.yaml")
+	appConfig, err := config.NewAppConfig()
+	require.NoError(t, err)
+
+	expected := &config.CostConfig{
+		PricePerRequest:     0.000001,
+		PricePerComputeUnit: 0.0000001,
+		ComputeUnits:        1,
+		Methods: map[string]*config.MethodCostConfig{
+			"trace":    {ComputeUnits: new(float64(100))},
+			"eth_call": {PricePerRequest: new(float64(0))},
+		},
+	}
+	assert.Equal(t, expected, appConfig.UpstreamConfig.Upstreams[0].Cost)
+	assert.Nil(t, appConfig.UpstreamConfig.Upstreams[1].Cost)
+	assert.Equal(t, config.CostBalancingStrategy, appConfig.UpstreamConfig.BalancingStrategyFor("ethereum"))
+	assert.Equal(t, &config.CostSloConfig{MaxLatency: 300 * time.Millisecond, MaxHeadLag: 2}, appConfig.UpstreamConfig.CostSloFor("ethereum"))
+	assert.Equal(t, &config.CostSloConfig{MaxLatency: 500 * time.Millisecond}, appConfig.UpstreamConfig.CostSloFor("polygon"))
+}
+
+func TestUpstreamCostNegativePriceThenError(t *testing.T) {
+	t.Setenv(config.ConfigPathVar, "configs/upstreams/upstream-cost-negative-price.yaml")
+	_, err := config.NewAppConfig()
+	assert.ErrorContains(t, err, "error during upstream 'eth-upstream' validation, cause: error during cost validation, cause: price-per-request of 'trace' can't be less than 0")
+}
+
 func TestUpstreamOptionsDefaultsFromChain(t *testing.T) {
 	t.Setenv(config.ConfigPathVar, "configs/upstreams/upstream-options-defaults-from-chain.yaml")
 	appConfig, err := config.NewAppConfig()
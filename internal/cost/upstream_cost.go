@@ -0,0 +1,82 @@
+package cost
+
+import (
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	specs "github.com/drpcorg/nodecore/pkg/methods"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var estimatedSpendMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "upstream",
+		Name:      "estimated_spend_total",
+		Help:      "The estimated spend on requests sent to an upstream according to its cost config",
+	},
+	[]string{"chain", "upstream"},
+)
+
+func init() {
+	prometheus.MustRegister(estimatedSpendMetric)
+}
+
+// UpstreamCost estimates the price of requests to an upstream.
+// A nil UpstreamCost is an upstream without a cost config, its requests are free
+type UpstreamCost struct {
+	chain      chains.Chain
+	upstreamId string
+	specName   string
+	config     *config.CostConfig
+}
+
+func NewUpstreamCost(chain chains.Chain, upstreamId string, cfg *config.CostConfig) *UpstreamCost {
+	return &UpstreamCost{
+		chain:      chain,
+		upstreamId: upstreamId,
+		specName:   chains.GetMethodSpecNameByChain(chain),
+		config:     cfg,
+	}
+}
+
+// RequestCost returns the estimated price of one request of the method
+func (c *UpstreamCost) RequestCost(method string) float64 {
+	if c == nil {
+		return 0
+	}
+	pricePerRequest := c.config.PricePerRequest
+	computeUnits := c.config.ComputeUnits
+	if methodCost := c.methodCost(method); methodCost != nil {
+		if methodCost.PricePerRequest != nil {
+			pricePerRequest = *methodCost.PricePerRequest
+		}
+		if methodCost.ComputeUnits != nil {
+			computeUnits = *methodCost.ComputeUnits
+		}
+	}
+	return pricePerRequest + computeUnits*c.config.PricePerComputeUnit
+}
+
+// Record adds the price of a request of the method sent to the upstream to its estimated spend
+func (c *UpstreamCost) Record(method string) {
+	if c == nil {
+		return
+	}
+	if requestCost := c.RequestCost(method); requestCost > 0 {
+		estimatedSpendMetric.WithLabelValues(c.chain.String(), c.upstreamId).Add(requestCost)
+	}
+}
+
+func (c *UpstreamCost) methodCost(method string) *config.MethodCostConfig {
+	if len(c.config.Methods) == 0 {
+		return nil
+	}
+	if methodCost, ok := c.config.Methods[method]; ok {
+		return methodCost
+	}
+	specMethod := specs.GetSpecMethod(c.specName, method)
+	if specMethod == nil {
+		return nil
+	}
+	return c.config.Methods[specMethod.Group]
+}
@@ -0,0 +1,65 @@
+package cost
+
+import (
+	"testing"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	specs "github.com/drpcorg/nodecore/pkg/methods"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUpstreamCostPricePerRequest(t *testing.T) {
+	upstreamCost := NewUpstreamCost(chains.ETHEREUM, "id", &config.CostConfig{PricePerRequest: 0.002, ComputeUnits: 1})
+
+	assert.InDelta(t, 0.002, upstreamCost.RequestCost("eth_call"), 1e-12)
+}
+
+func TestUpstreamCostPricePerComputeUnit(t *testing.T) {
+	upstreamCost := NewUpstreamCost(chains.ETHEREUM, "id", &config.CostConfig{
+		PricePerRequest:     0.001,
+		PricePerComputeUnit: 0.0001,
+		ComputeUnits:        20,
+		Methods: map[string]*config.MethodCostConfig{
+			"eth_getBalance": {ComputeUnits: new(float64(10))},
+		},
+	})
+
+	assert.InDelta(t, 0.003, upstreamCost.RequestCost("eth_call"), 1e-12)
+	assert.InDelta(t, 0.002, upstreamCost.RequestCost("eth_getBalance"), 1e-12)
+}
+
+func TestUpstreamCostMethodWinsOverGroup(t *testing.T) {
+	require.NoError(t, specs.NewMethodSpecLoader().Load())
+	upstreamCost := NewUpstreamCost(chains.ETHEREUM, "id", &config.CostConfig{
+		PricePerRequest: 0.001,
+		ComputeUnits:    1,
+		Methods: map[string]*config.MethodCostConfig{
+			"trace":      {PricePerRequest: new(0.01)},
+			"trace_call": {PricePerRequest: new(0.05)},
+		},
+	})
+
+	assert.InDelta(t, 0.01, upstreamCost.RequestCost("trace_callMany"), 1e-12)
+	assert.InDelta(t, 0.05, upstreamCost.RequestCost("trace_call"), 1e-12)
+	assert.InDelta(t, 0.001, upstreamCost.RequestCost("eth_call"), 1e-12)
+	assert.InDelta(t, 0.001, upstreamCost.RequestCost("unknown_method"), 1e-12)
+}
+
+func TestUpstreamCostNilIsFree(t *testing.T) {
+	var upstreamCost *UpstreamCost
+
+	assert.Zero(t, upstreamCost.RequestCost("eth_call"))
+	upstreamCost.Record("eth_call")
+}
+
+func TestUpstreamCostRecordSpend(t *testing.T) {
+	upstreamCost := NewUpstreamCost(chains.POLYGON, "spend-id", &config.CostConfig{PricePerRequest: 0.5, ComputeUnits: 1})
+
+	upstreamCost.Record("eth_call")
+	upstreamCost.Record("eth_call")
+
+	assert.InDelta(t, 1.0, testutil.ToFloat64(estimatedSpendMetric.WithLabelValues(chains.POLYGON.String(), "spend-id")), 1e-12)
+}
@@ -30,6 +30,9 @@ func (d *DimensionHook) OnResponseReceived(
 				if r.IsSuccessfulRetry() {
 					dims.TrackSuccessfulRetries()
 				}
+				if request.RequestObserver().GetRequestKind() != protocol.InternalUnary {
+					d.tracker.NotifyUpstreamListener(r.GetChain(), r.GetUpstreamId(), request.Method(), r)
+				}
 			}
 		}
 	}()
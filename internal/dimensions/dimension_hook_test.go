@@ -70,3 +70,47 @@ func TestDimensionsHookMultipleResults(t *testing.T) {
 	assert.Equal(t, uint64(1), dims.GetSuccessfulRetries())
 	assert.True(t, dims.GetValueAtQuantile(0.9) > 0)
 }
+
+func TestDimensionsHookNotifiesUpstreamListenerOfClientRequests(t *testing.T) {
+	tracker := dimensions.NewGenericDimensionTracker()
+	hook := dimensions.NewDimensionHook(tracker)
+	results := make(chan protocol.ResponseKind, 10)
+	removeListener := tracker.AddUpstreamListener(chains.POLYGON, "upId", func(method string, result *protocol.UnaryRequestResult) {
+		assert.Equal(t, "eth_call", method)
+		results <- result.GetRespKind()
+	})
+
+	newRequest := func(kind protocol.RequestKind, upstreamId string) protocol.RequestHolder {
+		body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call", Params: nil}
+		request := protocol.NewUpstreamJsonRpcRequest("1", body, false, "")
+		request.RequestObserver().
+			WithRequestKind(kind).
+			WithChain(chains.POLYGON).
+			AddResult(
+				protocol.NewUnaryRequestResult().
+					WithUpstreamId(upstreamId).
+					WithDuration(0.5).
+					WithRespKindFromResponse(protocol.NewPartialFailure(request, protocol.ServerError())),
+				false,
+			)
+		return request
+	}
+
+	hook.OnResponseReceived(context.Background(), newRequest(protocol.InternalUnary, "upId"), nil)
+	hook.OnResponseReceived(context.Background(), newRequest(protocol.Unary, "upId2"), nil)
+	hook.OnResponseReceived(context.Background(), newRequest(protocol.Unary, "upId"), nil)
+
+	select {
+	case kind := <-results:
+		assert.Equal(t, protocol.RetryableError, kind)
+	case <-time.After(time.Second):
+		t.Fatal("the listener hasn't been notified")
+	}
+
+	removeListener()
+	hook.OnResponseReceived(context.Background(), newRequest(protocol.Unary, "upId"), nil)
+	time.Sleep(10 * time.Millisecond)
+
+	assert.Empty(t, results)
+	assert.Equal(t, uint64(3), tracker.GetUpstreamDimensions(chains.POLYGON, "upId", "eth_call").GetTotalRequests())
+}
@@ -2,6 +2,7 @@ package dimensions
 
 import (
 	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/pkg/chains"
 	"github.com/drpcorg/nodecore/pkg/utils"
 	"github.com/prometheus/client_golang/prometheus"
@@ -81,21 +82,49 @@ func init() {
 	)
 }
 
+// UpstreamListener receives the results of client requests to an upstream right after they are tracked
+type UpstreamListener func(method string, result *protocol.UnaryRequestResult)
+
 type DimensionTracker interface {
 	GetAllDimensions(chain chains.Chain, upstreamId, method string) *FullDimensions
 	GetUpstreamDimensions(chain chains.Chain, upstreamId, method string) *UpstreamDimensions
 	GetChainDimensions(chain chains.Chain, upstreamId string) *ChainDimensions
+	// AddUpstreamListener sets the listener of the upstream and returns a func that removes it
+	AddUpstreamListener(chain chains.Chain, upstreamId string, listener UpstreamListener) func()
+	NotifyUpstreamListener(chain chains.Chain, upstreamId, method string, result *protocol.UnaryRequestResult)
 }
 
 type GenericDimensionTracker struct {
 	upstreamDimensionsMap *utils.CMap[upstreamDimensionKey, *UpstreamDimensions]
 	chainDimensionsMap    *utils.CMap[chainDimensionKey, *ChainDimensions]
+	upstreamListeners     *utils.CMap[chainDimensionKey, *upstreamListener]
+}
+
+type upstreamListener struct {
+	listener UpstreamListener
 }
 
 func NewGenericDimensionTracker() DimensionTracker {
 	return &GenericDimensionTracker{
 		upstreamDimensionsMap: utils.NewCMap[upstreamDimensionKey, *UpstreamDimensions](),
 		chainDimensionsMap:    utils.NewCMap[chainDimensionKey, *ChainDimensions](),
+		upstreamListeners:     utils.NewCMap[chainDimensionKey, *upstreamListener](),
+	}
+}
+
+func (d *GenericDimensionTracker) AddUpstreamListener(chain chains.Chain, upstreamId string, listener UpstreamListener) func() {
+	key := newChainDimensionKey(chain, upstreamId)
+	added := &upstreamListener{listener: listener}
+	d.upstreamListeners.Store(key, added)
+	return func() {
+		// an upstream with the same id may have replaced the listener already
+		d.upstreamListeners.CompareAndDelete(key, added)
+	}
+}
+
+func (d *GenericDimensionTracker) NotifyUpstreamListener(chain chains.Chain, upstreamId, method string, result *protocol.UnaryRequestResult) {
+	if listener, ok := d.upstreamListeners.Load(newChainDimensionKey(chain, upstreamId)); ok {
+		listener.listener(method, result)
 	}
 }
 
@@ -58,4 +58,12 @@ func (d *DrpcKey) PostCheckSetting(_ context.Context, request protocol.RequestHo
 	return nil
 }
 
+func (d *DrpcKey) Selector() protocol.RequestSelector {
+	return nil
+}
+
+func (d *DrpcKey) CacheControlAllowed() bool {
+	return true
+}
+
 var _ keydata.Key = (*DrpcKey)(nil)
@@ -9,6 +9,7 @@ import (
 	"github.com/drpcorg/nodecore/internal/key_management/keydata"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/pkg/utils"
+	"github.com/rs/zerolog/log"
 	"github.com/samber/lo"
 )
 
@@ -16,6 +17,7 @@ type LocalKey struct {
 	id             string
 	key            string
 	keySettingsCfg *config.KeySettingsConfig
+	selector       protocol.RequestSelector
 }
 
 func (l *LocalKey) GetKeyValue() string {
@@ -66,11 +68,32 @@ func (l *LocalKey) PostCheckSetting(_ context.Context, request protocol.RequestH
 	return nil
 }
 
+func (l *LocalKey) Selector() protocol.RequestSelector {
+	return l.selector
+}
+
+func (l *LocalKey) CacheControlAllowed() bool {
+	if l.keySettingsCfg == nil || l.keySettingsCfg.AllowCacheControl == nil {
+		return true
+	}
+	return *l.keySettingsCfg.AllowCacheControl
+}
+
 func NewLocalKey(id string, keyCfg *config.LocalKeyConfig) *LocalKey {
+	var selector protocol.RequestSelector
+	if keyCfg.KeySettingsConfig != nil {
+		var err error
+		// selectors are validated along with the auth config, so it's not expected to fail here
+		selector, err = protocol.ParseRequestSelector(keyCfg.KeySettingsConfig.Selector)
+		if err != nil {
+			log.Error().Err(err).Msgf("invalid selector of the key '%s', it will be ignored", id)
+		}
+	}
 	return &LocalKey{
 		id:             id,
 		key:            keyCfg.Key,
 		keySettingsCfg: keyCfg.KeySettingsConfig,
+		selector:       selector,
 	}
 }
 
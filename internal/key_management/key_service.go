@@ -7,6 +7,7 @@ import (
 
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/integration"
+	"github.com/drpcorg/nodecore/internal/integration/local"
 	"github.com/drpcorg/nodecore/internal/key_management/keydata"
 	"github.com/drpcorg/nodecore/pkg/utils"
 	"github.com/rs/zerolog/log"
@@ -15,6 +16,7 @@ import (
 
 type KeyService interface {
 	GetKey(keyStr string) (keydata.Key, bool)
+	ReloadLocalKeys(keyCfgs []*config.KeyConfig)
 }
 
 type GenericKeyService struct {
@@ -70,6 +72,31 @@ func (k *GenericKeyService) GetKey(keyStr string) (keydata.Key, bool) {
 	return key, ok
 }
 
+// ReloadLocalKeys replaces local keys with the ones from keyCfgs, keys of other
+// integrations are managed by their own key events and stay untouched
+func (k *GenericKeyService) ReloadLocalKeys(keyCfgs []*config.KeyConfig) {
+	newKeys := make(map[string]*local.LocalKey)
+	for _, keyCfg := range keyCfgs {
+		if localKeyCfg, ok := keyCfg.GetSpecificKeyConfig().(*config.LocalKeyConfig); ok {
+			newKeys[localKeyCfg.Key] = local.NewLocalKey(keyCfg.Id, localKeyCfg)
+		}
+	}
+
+	k.keys.Range(func(keyStr string, key keydata.Key) bool {
+		if _, ok := key.(*local.LocalKey); ok {
+			if _, exists := newKeys[keyStr]; !exists {
+				log.Info().Msgf("local key '%s' has been removed", key.Id())
+				k.keys.Delete(keyStr)
+			}
+		}
+		return true
+	})
+	for keyStr, key := range newKeys {
+		k.keys.Store(keyStr, key)
+	}
+	log.Info().Msgf("%d local keys have been loaded", len(newKeys))
+}
+
 func getIntegration(
 	resolver *integration.IntegrationResolver,
 	integrationType integration.IntegrationType,
@@ -153,3 +153,27 @@ func TestKeyResolverDrpcKeysEvents(t *testing.T) {
 
 	client.AssertExpectations(t)
 }
+
+func TestReloadLocalKeys(t *testing.T) {
+	cfg := []*config.KeyConfig{
+		{Id: "kept", Type: config.Local, LocalKeyConfig: test_utils.BuildLocalKeyConfig("kept-secret", nil, nil, nil)},
+		{Id: "removed", Type: config.Local, LocalKeyConfig: test_utils.BuildLocalKeyConfig("removed-secret", nil, nil, nil)},
+	}
+	keyService, err := keymanagement.NewGenericKeyService(context.Background(), cfg, integration.NewIntegrationResolver(nil))
+	assert.NoError(t, err)
+	time.Sleep(30 * time.Millisecond)
+
+	keyService.ReloadLocalKeys([]*config.KeyConfig{
+		{Id: "kept", Type: config.Local, LocalKeyConfig: test_utils.BuildLocalKeyConfig("kept-secret", nil, nil, nil)},
+		{Id: "added", Type: config.Local, LocalKeyConfig: test_utils.BuildLocalKeyConfig("added-secret", nil, nil, nil)},
+	})
+
+	key, ok := keyService.GetKey("kept-secret")
+	assert.True(t, ok)
+	assert.Equal(t, "kept", key.Id())
+	key, ok = keyService.GetKey("added-secret")
+	assert.True(t, ok)
+	assert.Equal(t, "added", key.Id())
+	_, ok = keyService.GetKey("removed-secret")
+	assert.False(t, ok)
+}
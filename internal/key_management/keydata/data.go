@@ -16,6 +16,10 @@ type Key interface {
 	GetKeyValue() string
 	PreCheckSetting(ctx context.Context) ([]string, error)
 	PostCheckSetting(ctx context.Context, request protocol.RequestHolder) error
+	// Selector returns the default routing selector of the key, nil if there is no one
+	Selector() protocol.RequestSelector
+	// CacheControlAllowed is true if clients of the key can control the cache of their requests
+	CacheControlAllowed() bool
 }
 
 func CheckMethod(allowedMethods, forbiddenMethods []string, method string) error {
@@ -9,6 +9,8 @@ import (
 	"math"
 
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/breaker"
+	"github.com/drpcorg/nodecore/internal/cost"
 	"github.com/drpcorg/nodecore/internal/ratelimiter"
 	"github.com/drpcorg/nodecore/internal/upstreams/methods"
 	"github.com/drpcorg/nodecore/pkg/chains"
@@ -21,6 +23,36 @@ type ResponseReceivedHook interface {
 	OnResponseReceived(ctx context.Context, request RequestHolder, respWrapper *ResponseHolderWrapper)
 }
 
+// XNodecoreStale marks a response served from the cache after its ttl
+const XNodecoreStale = "X-Nodecore-Stale"
+
+// XNodecoreCache tells a client its response is served from the cache, by which policy and connector
+const XNodecoreCache = "X-Nodecore-Cache"
+
+// CachedResponse is a response received from the cache. A stale response is past its ttl
+// but within a stale window of its cache policy, it should be refreshed from upstreams.
+type CachedResponse struct {
+	Result []byte
+	// Stream is set instead of the result for a stream request, the response has to be closed if it isn't served
+	Stream io.ReadCloser
+	Stale  bool
+	// ServeStaleOnError is set for a stale response past the stale window, within the stale-if-error window,
+	// it is served only if upstreams fail
+	ServeStaleOnError bool
+	// Error is set for a cached upstream error, the result is the raw JSON-RPC error object
+	Error bool
+	// Policy and Connector are the ids of the cache policy and the connector the response is received from
+	Policy    string
+	Connector string
+}
+
+// Close releases the stream of a response that isn't served
+func (c *CachedResponse) Close() {
+	if c != nil && c.Stream != nil {
+		_ = c.Stream.Close()
+	}
+}
+
 type SubscribeConnectorState int
 
 const (
@@ -356,7 +388,10 @@ type HeadUpstreamEvent struct {
 
 func (h HeadUpstreamEvent) eventData() {}
 
-type RemoveUpstreamEvent struct{}
+type RemoveUpstreamEvent struct {
+	// Handled is closed once the chain supervisor has removed the upstream, nil if no one waits for it
+	Handled chan struct{}
+}
 
 func (r RemoveUpstreamEvent) eventData() {}
 
@@ -393,6 +428,21 @@ const (
 	PendingTxCap
 )
 
+func (c Cap) String() string {
+	switch c {
+	case WsCap:
+		return "ws"
+	case NewHeadsCap:
+		return "new-heads"
+	case LogsCap:
+		return "logs"
+	case PendingTxCap:
+		return "pending-tx"
+	default:
+		return fmt.Sprintf("unknown(%d)", c)
+	}
+}
+
 type UpstreamState struct {
 	Status          AvailabilityStatus
 	HeadData        Block
@@ -402,10 +452,22 @@ type UpstreamState struct {
 
 	RateLimiterBudget   *ratelimiter.RateLimitBudget
 	AutoTuneRateLimiter *ratelimiter.UpstreamAutoTune
+	// CircuitBreaker is nil if the upstream has no circuit breaker
+	CircuitBreaker *breaker.CircuitBreaker
+	// Load is the live load of the upstream, shared by all copies of its state
+	Load *UpstreamLoad
+	// Cost is nil if the upstream has no cost config, its requests are free then
+	Cost *cost.UpstreamCost
+	// ConcurrencyLimiter is nil if the upstream has no concurrency limit
+	ConcurrencyLimiter *ratelimiter.ConcurrencyLimiter
 
 	BlockInfo       *BlockInfo
 	LowerBoundsInfo *LowerBoundInfo
 	Labels          *Labels
+	// BannedMethods are the methods that are banned at the moment, nil if nothing has been banned yet
+	BannedMethods mapset.Set[string]
+	// CircuitStates are the states of circuits that aren't closed, by method or breaker.AnyMethod, nil if no circuit has opened yet
+	CircuitStates map[string]breaker.State
 }
 
 func DefaultUpstreamState(upstreamMethods methods.Methods, caps mapset.Set[Cap], upstreamIndex string, rt *ratelimiter.RateLimitBudget, autoTuneRateLimiter *ratelimiter.UpstreamAutoTune) UpstreamState {
@@ -420,6 +482,7 @@ func DefaultUpstreamState(upstreamMethods methods.Methods, caps mapset.Set[Cap],
 		UpstreamIndex:       upstreamIndex,
 		RateLimiterBudget:   rt,
 		AutoTuneRateLimiter: autoTuneRateLimiter,
+		Load:                NewUpstreamLoad(),
 	}
 }
 
@@ -114,11 +114,18 @@ func TestDefaultUpstreamState(t *testing.T) {
 		Caps:            caps,
 		HeadData:        protocol.ZeroBlock{},
 		UpstreamIndex:   "55",
+		Load:            protocol.NewUpstreamLoad(),
 	}
 
 	assert.Equal(t, expectedState, defaultUpState)
 }
 
+func TestCapString(t *testing.T) {
+	assert.Equal(t, "ws", protocol.WsCap.String())
+	assert.Equal(t, "pending-tx", protocol.PendingTxCap.String())
+	assert.Equal(t, "unknown(42)", protocol.Cap(42).String())
+}
+
 func TestLabelsGetLabel(t *testing.T) {
 	labels := protocol.NewLabels()
 	labels.AddLabel("region", "us-east-1")
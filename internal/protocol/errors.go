@@ -240,3 +240,14 @@ func UnsupportedBlockTagError(chain, tag string) *ResponseError {
 		Message: fmt.Sprintf("block tag %q is not supported on chain %s", tag, chain),
 	}
 }
+
+const FilterNotFound = -32000
+
+// FilterNotFoundError is returned for an unknown, expired or uninstalled filter id,
+// with the code and the message geth uses
+func FilterNotFoundError() *ResponseError {
+	return &ResponseError{
+		Code:    FilterNotFound,
+		Message: "filter not found",
+	}
+}
@@ -19,6 +19,7 @@ type UpstreamJsonRpcRequest struct {
 	parsedParam     specs.MethodParam
 	requestParams   json.RawMessage
 	requestKey      string
+	legacyKey       string
 	specMethod      *specs.Method
 	requestObserver *RequestObserver
 	selectors       []RequestSelector
@@ -105,6 +106,29 @@ func NewStreamUpstreamJsonRpcRequest(id string, jsonRpcRequest JsonRpcRequestBod
 	return request
 }
 
+// revalidation returns a new internal request with the params and selectors of the request,
+// its response is stored under the same cache key
+func (u *UpstreamJsonRpcRequest) revalidation() *UpstreamJsonRpcRequest {
+	requestKey, legacyKey := u.RequestHash(), u.LegacyRequestHash()
+	u.mu.Lock()
+	params := u.requestParams
+	u.mu.Unlock()
+	request := &UpstreamJsonRpcRequest{
+		id:              u.id,
+		method:          u.method,
+		realId:          u.realId,
+		requestParams:   params,
+		specMethod:      u.specMethod,
+		requestObserver: NewRequestObserver(false).WithRequestKind(InternalUnary).WithMethod(u.method),
+		selectors:       u.Selectors(),
+	}
+	request.requestKeyOnce.Do(func() {
+		request.requestKey = requestKey
+		request.legacyKey = legacyKey
+	})
+	return request
+}
+
 func calculateJsonRpcHash(method string, params json.RawMessage, selectors []RequestSelector) string {
 	// The label key is the request's node-class identity (see LabelCacheKey).
 	// Hashing it alongside method+params keeps responses from one class out of
@@ -234,11 +258,27 @@ func (u *UpstreamJsonRpcRequest) RequestHash() string {
 		u.mu.Lock()
 		params := u.requestParams
 		u.mu.Unlock()
-		u.requestKey = calculateJsonRpcHash(u.method, params, u.selectors)
+		// semantically identical params, e.g. differing only in hex leading zeros or address case, have the same hash
+		canonicalParams := params
+		if u.specMethod != nil {
+			canonicalParams = u.specMethod.CanonicalParams(params)
+		}
+		u.requestKey = calculateJsonRpcHash(u.method, canonicalParams, u.selectors)
+		u.legacyKey = u.requestKey
+		if !bytes.Equal(canonicalParams, params) {
+			u.legacyKey = calculateJsonRpcHash(u.method, params, u.selectors)
+		}
 	})
 	return u.requestKey
 }
 
+// LegacyRequestHash is the hash of the params as they were sent, responses cached
+// before the params became canonical are stored under it
+func (u *UpstreamJsonRpcRequest) LegacyRequestHash() string {
+	u.RequestHash()
+	return u.legacyKey
+}
+
 func (u *UpstreamJsonRpcRequest) SpecMethod() *specs.Method {
 	return u.specMethod
 }
@@ -279,17 +279,21 @@ func resultValueStart(buf []byte, offsetAfterKey int) (start int, counter Result
 	if p >= len(buf) {
 		return 0, ResultCounter{}, false
 	}
-	switch buf[p] {
+	return p, resultCounterFor(buf[p]), true
+}
+
+// resultCounterFor returns a counter primed for the JSON value starting with the first byte
+func resultCounterFor(first byte) ResultCounter {
+	switch first {
 	case '{':
-		counter = ResultCounter{kind: counterObject}
+		return ResultCounter{kind: counterObject}
 	case '[':
-		counter = ResultCounter{kind: counterArray}
+		return ResultCounter{kind: counterArray}
 	case '"':
-		counter = ResultCounter{kind: counterString}
+		return ResultCounter{kind: counterString}
 	default:
-		counter = ResultCounter{kind: counterScalar}
+		return ResultCounter{kind: counterScalar}
 	}
-	return p, counter, true
 }
 
 // skipResultValueBytes byte-counts the result value in buf starting at start,
@@ -396,3 +400,28 @@ func (c *CloseReader) Close() error {
 	c.closeBody()
 	return nil
 }
+
+// doneReader calls done once its stream is read to the end, fails or is closed,
+// so whatever the stream holds on to can be released right when the consumer is over with it
+type doneReader struct {
+	reader   io.Reader
+	done     func()
+	doneOnce sync.Once
+}
+
+func (d *doneReader) Read(p []byte) (n int, err error) {
+	n, err = d.reader.Read(p)
+	if err != nil {
+		d.doneOnce.Do(d.done)
+	}
+	return n, err
+}
+
+func (d *doneReader) Close() error {
+	var err error
+	if closer, ok := d.reader.(io.Closer); ok {
+		err = closer.Close()
+	}
+	d.doneOnce.Do(d.done)
+	return err
+}
@@ -458,6 +458,43 @@ func TestCloseReaderCloseOnAnyError(t *testing.T) {
 	assert.True(t, err != nil)
 }
 
+func TestResponseOnStreamDoneCalledAfterStreamIsRead(t *testing.T) {
+	done := 0
+	response := protocol.NewHttpUpstreamResponseStream("1", bytes.NewReader([]byte(`{"result":"0x1"}`)), protocol.JsonRpc).
+		OnStreamDone(func() { done++ })
+
+	assert.Equal(t, 0, done)
+
+	body, err := io.ReadAll(response.EncodeResponse([]byte("1")))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"result":"0x1"}`, string(body))
+	assert.Equal(t, 1, done)
+
+	_ = response.EncodeResponse([]byte("1")).(io.Closer).Close()
+	assert.Equal(t, 1, done)
+}
+
+func TestResponseOnStreamDoneCalledOnClose(t *testing.T) {
+	done := 0
+	closerReader := newReaderMock()
+	closerReader.On("Close").Return(nil)
+	response := protocol.NewHttpUpstreamResponseStream("1", protocol.NewCloseReader(context.Background(), closerReader, closerReader), protocol.JsonRpc).
+		OnStreamDone(func() { done++ })
+
+	err := response.EncodeResponse([]byte("1")).(io.Closer).Close()
+
+	assert.NoError(t, err)
+	closerReader.AssertCalled(t, "Close")
+	assert.Equal(t, 1, done)
+}
+
+func TestResponseOnStreamDoneWithoutStreamThenCalledRightAway(t *testing.T) {
+	done := 0
+	protocol.NewSimpleHttpUpstreamResponse("1", []byte(`"0x1"`), protocol.JsonRpc).OnStreamDone(func() { done++ })
+
+	assert.Equal(t, 1, done)
+}
+
 type readerCloserMock struct {
 	mock.Mock
 }
@@ -486,3 +523,38 @@ func (r *readerCloserMock) Close() error {
 	}
 	return err
 }
+
+func TestResultResponseStreamThenEnvelopeWithRealIdAndHint(t *testing.T) {
+	closerReader := newReaderMock()
+	closerReader.On("Close").Return(nil)
+	result := struct {
+		io.Reader
+		io.Closer
+	}{bytes.NewReader([]byte(`[{"hash":"0x1"}]`)), closerReader}
+
+	response := protocol.NewResultResponseStream("1", result, protocol.JsonRpc)
+
+	assert.True(t, response.HasStream())
+	reader := response.EncodeResponse([]byte(`"real"`))
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	assert.Equal(t, `{"jsonrpc":"2.0","result":[{"hash":"0x1"}],"id":"real"}`, string(body))
+
+	hint, ok := response.GetStreamHint().(protocol.JsonRpcResultStreamHint)
+	require.True(t, ok)
+	assert.Equal(t, byte('['), body[hint.ResultStart])
+	assert.Equal(t, protocol.AnalyzeChunk(body).Counter, hint.Counter)
+
+	require.NoError(t, reader.(io.Closer).Close())
+	closerReader.AssertCalled(t, "Close")
+}
+
+func TestResultResponseStreamRestThenResultAsIs(t *testing.T) {
+	response := protocol.NewResultResponseStream("1", bytes.NewReader([]byte(`{"height":1}`)), protocol.Rest)
+
+	body, err := io.ReadAll(response.EncodeResponse([]byte("1")))
+
+	require.NoError(t, err)
+	assert.Equal(t, `{"height":1}`, string(body))
+	assert.Nil(t, response.GetStreamHint())
+}
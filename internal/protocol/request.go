@@ -54,6 +54,19 @@ func (r RequestType) String() string {
 	panic(fmt.Sprintf("unknown RequestType - %d", r))
 }
 
+// NewRevalidationRequest builds a new unary request refreshing the cached response of a request
+// that has already been answered, false if requests of its type can't be rebuilt
+func NewRevalidationRequest(request RequestHolder) (RequestHolder, bool) {
+	switch r := request.(type) {
+	case *UpstreamJsonRpcRequest:
+		return r.revalidation(), true
+	case *UpstreamRestRequest:
+		return r.revalidation(), true
+	default:
+		return nil, false
+	}
+}
+
 func calculateHash(b []byte) string {
 	hash := blake2b.Sum256(b)
 	return hex.EncodeToString(hash[:])
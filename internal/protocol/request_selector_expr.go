@@ -0,0 +1,386 @@
+package protocol
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ParseRequestSelector parses the text form of a selector tree, the one HTTP and WebSocket
+// clients pass in the X-Nodecore-Selector header or the selector query param, e.g.
+//
+//	archive=true AND (provider in (a, b) OR NOT exists(region)) AND lower-bound(state, 100)
+//
+// Terms are label matches (name=value, name!=value, name in (a,b), name not in (a,b)),
+// exists(name), height(number|latest|safe|finalized), slot(number) and
+// lower-bound(type[, height[, delta]]). Terms are combined with AND, OR, NOT and parentheses,
+// AND binds tighter than OR and keywords are case-insensitive. Values may be quoted with
+// single or double quotes. An empty expression returns a nil selector.
+func ParseRequestSelector(expr string) (RequestSelector, error) {
+	tokens, err := tokenizeSelector(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+	parser := &selectorParser{tokens: tokens}
+	selector, err := parser.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !parser.done() {
+		return nil, fmt.Errorf("unexpected '%s'", parser.peek().value)
+	}
+	return selector, nil
+}
+
+type selectorTokenType int
+
+const (
+	wordToken selectorTokenType = iota
+	quotedToken
+	openToken
+	closeToken
+	commaToken
+	equalToken
+	notEqualToken
+)
+
+type selectorToken struct {
+	tokenType selectorTokenType
+	value     string
+}
+
+func (t selectorToken) isKeyword(keyword string) bool {
+	return t.tokenType == wordToken && strings.EqualFold(t.value, keyword)
+}
+
+func tokenizeSelector(expr string) ([]selectorToken, error) {
+	tokens := make([]selectorToken, 0)
+	runes := []rune(expr)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			tokens = append(tokens, selectorToken{tokenType: openToken, value: "("})
+			i++
+		case r == ')':
+			tokens = append(tokens, selectorToken{tokenType: closeToken, value: ")"})
+			i++
+		case r == ',':
+			tokens = append(tokens, selectorToken{tokenType: commaToken, value: ","})
+			i++
+		case r == '=':
+			tokens = append(tokens, selectorToken{tokenType: equalToken, value: "="})
+			i++
+		case r == '!':
+			if i+1 >= len(runes) || runes[i+1] != '=' {
+				return nil, errors.New("'!' must be followed by '='")
+			}
+			tokens = append(tokens, selectorToken{tokenType: notEqualToken, value: "!="})
+			i += 2
+		case r == '"' || r == '\'':
+			end := i + 1
+			for end < len(runes) && runes[end] != r {
+				end++
+			}
+			if end >= len(runes) {
+				return nil, errors.New("unterminated quoted value")
+			}
+			tokens = append(tokens, selectorToken{tokenType: quotedToken, value: string(runes[i+1 : end])})
+			i = end + 1
+		default:
+			start := i
+			for i < len(runes) && !unicode.IsSpace(runes[i]) && !strings.ContainsRune("(),=!\"'", runes[i]) {
+				i++
+			}
+			tokens = append(tokens, selectorToken{tokenType: wordToken, value: string(runes[start:i])})
+		}
+	}
+	return tokens, nil
+}
+
+type selectorParser struct {
+	tokens []selectorToken
+	pos    int
+}
+
+func (p *selectorParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *selectorParser) peek() selectorToken {
+	return p.tokens[p.pos]
+}
+
+func (p *selectorParser) peekKeyword(keyword string) bool {
+	return !p.done() && p.peek().isKeyword(keyword)
+}
+
+func (p *selectorParser) next() (selectorToken, error) {
+	if p.done() {
+		return selectorToken{}, errors.New("unexpected end of the selector")
+	}
+	token := p.tokens[p.pos]
+	p.pos++
+	return token, nil
+}
+
+func (p *selectorParser) expect(tokenType selectorTokenType, value string) error {
+	token, err := p.next()
+	if err != nil {
+		return fmt.Errorf("expected '%s', got the end of the selector", value)
+	}
+	if token.tokenType != tokenType {
+		return fmt.Errorf("expected '%s', got '%s'", value, token.value)
+	}
+	return nil
+}
+
+func (p *selectorParser) parseOr() (RequestSelector, error) {
+	children, err := p.parseSequence("OR", p.parseAnd)
+	if err != nil {
+		return nil, err
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return RequestOrSelector{Children: children}, nil
+}
+
+func (p *selectorParser) parseAnd() (RequestSelector, error) {
+	children, err := p.parseSequence("AND", p.parseUnary)
+	if err != nil {
+		return nil, err
+	}
+	if len(children) == 1 {
+		return children[0], nil
+	}
+	return RequestAndSelector{Children: children}, nil
+}
+
+func (p *selectorParser) parseSequence(keyword string, parseChild func() (RequestSelector, error)) ([]RequestSelector, error) {
+	children := make([]RequestSelector, 0, 1)
+	for {
+		child, err := parseChild()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, child)
+		if !p.peekKeyword(keyword) {
+			return children, nil
+		}
+		p.pos++
+	}
+}
+
+func (p *selectorParser) parseUnary() (RequestSelector, error) {
+	if p.peekKeyword("NOT") {
+		p.pos++
+		child, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return RequestNotSelector{Child: child}, nil
+	}
+	if !p.done() && p.peek().tokenType == openToken {
+		p.pos++
+		selector, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err = p.expect(closeToken, ")"); err != nil {
+			return nil, err
+		}
+		return selector, nil
+	}
+	return p.parseTerm()
+}
+
+func (p *selectorParser) parseTerm() (RequestSelector, error) {
+	nameToken, err := p.next()
+	if err != nil {
+		return nil, err
+	}
+	if nameToken.tokenType != wordToken && nameToken.tokenType != quotedToken {
+		return nil, fmt.Errorf("unexpected '%s'", nameToken.value)
+	}
+	name := nameToken.value
+
+	operator, err := p.next()
+	if err != nil {
+		return nil, fmt.Errorf("expected an operator after '%s'", name)
+	}
+	switch {
+	case operator.tokenType == openToken && nameToken.tokenType == wordToken:
+		args, err := p.parseValues()
+		if err != nil {
+			return nil, err
+		}
+		return selectorFunction(name, args)
+	case operator.tokenType == equalToken:
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return RequestLabelSelector{Name: name, Values: []string{value}}, nil
+	case operator.tokenType == notEqualToken:
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		return RequestNotSelector{Child: RequestLabelSelector{Name: name, Values: []string{value}}}, nil
+	case operator.isKeyword("IN"):
+		return p.parseIn(name)
+	case operator.isKeyword("NOT") && p.peekKeyword("IN"):
+		p.pos++
+		selector, err := p.parseIn(name)
+		if err != nil {
+			return nil, err
+		}
+		return RequestNotSelector{Child: selector}, nil
+	default:
+		return nil, fmt.Errorf("expected an operator after '%s', got '%s'", name, operator.value)
+	}
+}
+
+func (p *selectorParser) parseIn(name string) (RequestSelector, error) {
+	if err := p.expect(openToken, "("); err != nil {
+		return nil, err
+	}
+	values, err := p.parseValues()
+	if err != nil {
+		return nil, err
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("'%s in' requires at least one value", name)
+	}
+	return RequestLabelSelector{Name: name, Values: values}, nil
+}
+
+// parseValues parses a comma-separated value list, the opening parenthesis is already consumed
+func (p *selectorParser) parseValues() ([]string, error) {
+	values := make([]string, 0)
+	if !p.done() && p.peek().tokenType == closeToken {
+		p.pos++
+		return values, nil
+	}
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+
+		token, err := p.next()
+		if err != nil {
+			return nil, errors.New("expected ')', got the end of the selector")
+		}
+		switch token.tokenType {
+		case commaToken:
+		case closeToken:
+			return values, nil
+		default:
+			return nil, fmt.Errorf("expected ',' or ')', got '%s'", token.value)
+		}
+	}
+}
+
+func (p *selectorParser) parseValue() (string, error) {
+	token, err := p.next()
+	if err != nil {
+		return "", errors.New("expected a value, got the end of the selector")
+	}
+	if token.tokenType != wordToken && token.tokenType != quotedToken {
+		return "", fmt.Errorf("expected a value, got '%s'", token.value)
+	}
+	return token.value, nil
+}
+
+func selectorFunction(name string, args []string) (RequestSelector, error) {
+	switch strings.ToLower(name) {
+	case "exists":
+		if len(args) != 1 {
+			return nil, errors.New("exists() takes exactly one label name")
+		}
+		return RequestExistsSelector{Name: args[0]}, nil
+	case "height":
+		if len(args) != 1 {
+			return nil, errors.New("height() takes exactly one argument")
+		}
+		switch strings.ToLower(args[0]) {
+		case "latest":
+			return RequestBlockTagSelector{Tag: BlockTagLatest}, nil
+		case "safe":
+			return RequestBlockTagSelector{Tag: BlockTagSafe}, nil
+		case "finalized":
+			return RequestBlockTagSelector{Tag: BlockTagFinalized}, nil
+		}
+		height, err := parseSelectorNumber("height", args[0])
+		if err != nil {
+			return nil, err
+		}
+		return RequestHeightSelector{Height: height}, nil
+	case "slot":
+		if len(args) != 1 {
+			return nil, errors.New("slot() takes exactly one argument")
+		}
+		slot, err := parseSelectorNumber("slot", args[0])
+		if err != nil {
+			return nil, err
+		}
+		return RequestSlotHeightSelector{SlotHeight: slot}, nil
+	case "lower-bound":
+		return lowerBoundSelectorFunction(args)
+	default:
+		return nil, fmt.Errorf("unknown selector function '%s'", name)
+	}
+}
+
+func lowerBoundSelectorFunction(args []string) (RequestSelector, error) {
+	if len(args) == 0 || len(args) > 3 {
+		return nil, errors.New("lower-bound() takes a bound type, an optional height and an optional height delta")
+	}
+	boundType, ok := parseLowerBoundType(args[0])
+	if !ok {
+		return nil, fmt.Errorf("unknown lower bound type '%s'", args[0])
+	}
+	selector := RequestLowerHeightSelector{LowerBoundType: boundType}
+	if len(args) > 1 {
+		height, err := parseSelectorNumber("lower-bound height", args[1])
+		if err != nil {
+			return nil, err
+		}
+		selector.Height = height
+	}
+	if len(args) > 2 {
+		delta, err := parseSelectorNumber("lower-bound height delta", args[2])
+		if err != nil {
+			return nil, err
+		}
+		selector.HeightDelta = delta
+	}
+	return selector, nil
+}
+
+func parseLowerBoundType(value string) (LowerBoundType, bool) {
+	for boundType := SlotBound; boundType <= BlobBound; boundType++ {
+		if strings.EqualFold(boundType.String(), value) {
+			return boundType, true
+		}
+	}
+	return UnknownBound, false
+}
+
+func parseSelectorNumber(name, value string) (int64, error) {
+	number, err := strconv.ParseInt(value, 0, 64)
+	if err != nil || number < 0 {
+		return 0, fmt.Errorf("%s must be a non-negative number, got '%s'", name, value)
+	}
+	return number, nil
+}
@@ -0,0 +1,84 @@
+package protocol_test
+
+import (
+	"testing"
+
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRequestSelector(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		expected protocol.RequestSelector
+	}{
+		{name: "empty", expr: "  ", expected: nil},
+		{name: "label", expr: "archive=true", expected: label("archive", "true")},
+		{name: "not equal", expr: "archive != true", expected: not(label("archive", "true"))},
+		{name: "in", expr: "provider in (a, b)", expected: label("provider", "a", "b")},
+		{name: "not in", expr: "provider NOT IN (a,b)", expected: not(label("provider", "a", "b"))},
+		{name: "exists", expr: "exists(archive)", expected: exists("archive")},
+		{name: "quoted values", expr: `region = "eu west" and 'client type' in ('geth')`, expected: and(label("region", "eu west"), label("client type", "geth"))},
+		{
+			name:     "and binds tighter than or",
+			expr:     "a=1 OR b=2 AND c=3",
+			expected: or(label("a", "1"), and(label("b", "2"), label("c", "3"))),
+		},
+		{
+			name:     "parentheses and not",
+			expr:     "archive=true AND NOT (provider in (a,b) OR exists(region))",
+			expected: and(label("archive", "true"), not(or(label("provider", "a", "b"), exists("region")))),
+		},
+		{name: "height", expr: "height(100)", expected: protocol.RequestHeightSelector{Height: 100}},
+		{name: "hex height", expr: "height(0x64)", expected: protocol.RequestHeightSelector{Height: 100}},
+		{name: "height tag", expr: "height(Finalized)", expected: protocol.RequestBlockTagSelector{Tag: protocol.BlockTagFinalized}},
+		{name: "slot", expr: "slot(42)", expected: protocol.RequestSlotHeightSelector{SlotHeight: 42}},
+		{name: "lower bound sort", expr: "lower-bound(state)", expected: protocol.RequestLowerHeightSelector{LowerBoundType: protocol.StateBound}},
+		{
+			name:     "lower bound with height and delta",
+			expr:     "lower-bound(TRACE, 1000, 10)",
+			expected: protocol.RequestLowerHeightSelector{LowerBoundType: protocol.TraceBound, Height: 1000, HeightDelta: 10},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			selector, err := protocol.ParseRequestSelector(test.expr)
+
+			require.NoError(te, err)
+			assert.Equal(te, test.expected, selector)
+		})
+	}
+}
+
+func TestParseRequestSelectorErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		expr string
+		err  string
+	}{
+		{name: "no operator", expr: "archive", err: "expected an operator after 'archive'"},
+		{name: "unknown operator", expr: "archive true", err: "expected an operator after 'archive', got 'true'"},
+		{name: "dangling and", expr: "a=1 AND", err: "unexpected end of the selector"},
+		{name: "unbalanced parentheses", expr: "(a=1", err: "expected ')', got the end of the selector"},
+		{name: "trailing tokens", expr: "a=1 b=2", err: "unexpected 'b'"},
+		{name: "single bang", expr: "a!1", err: "'!' must be followed by '='"},
+		{name: "unterminated quote", expr: `a="1`, err: "unterminated quoted value"},
+		{name: "empty in", expr: "a in ()", err: "'a in' requires at least one value"},
+		{name: "unknown function", expr: "version(1)", err: "unknown selector function 'version'"},
+		{name: "negative height", expr: "height(-1)", err: "height must be a non-negative number, got '-1'"},
+		{name: "unknown bound", expr: "lower-bound(storage)", err: "unknown lower bound type 'storage'"},
+		{name: "exists arity", expr: "exists(a, b)", err: "exists() takes exactly one label name"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			_, err := protocol.ParseRequestSelector(test.expr)
+
+			require.Error(te, err)
+			assert.Equal(te, test.err, err.Error())
+		})
+	}
+}
@@ -39,6 +39,23 @@ func TestGenerateRequestHashWithParams(t *testing.T) {
 	assert.Equal(t, expected, request.RequestHash())
 }
 
+func TestRequestHashOfSemanticallyIdenticalParams(t *testing.T) {
+	err := specs.NewMethodSpecLoader().Load()
+	assert.NoError(t, err)
+
+	request := protocol.NewUpstreamJsonRpcRequest("1", protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_getBlockByNumber", Params: []byte(`["0x010"]`)}, false, "eth")
+	sameRequest := protocol.NewUpstreamJsonRpcRequest("2", protocol.JsonRpcRequestBody{Id: []byte(`2`), Method: "eth_getBlockByNumber", Params: []byte(`["0x10", false]`)}, false, "eth")
+	otherRequest := protocol.NewUpstreamJsonRpcRequest("3", protocol.JsonRpcRequestBody{Id: []byte(`3`), Method: "eth_getBlockByNumber", Params: []byte(`["0x10", true]`)}, false, "eth")
+
+	assert.Equal(t, request.RequestHash(), sameRequest.RequestHash())
+	assert.NotEqual(t, request.RequestHash(), otherRequest.RequestHash())
+
+	// the request is sent as is
+	body, err := request.Body()
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x010"]}`, string(body))
+}
+
 func TestRequestHashForInternalJsonRpcRequest(t *testing.T) {
 	// RequestHash is now computed lazily on first access, so internal requests
 	// produce a real, stable hash (previously empty). They still bypass the
@@ -55,6 +72,24 @@ func TestRequestHashForInternalJsonRpcRequest(t *testing.T) {
 	assert.Equal(t, hash, other.RequestHash()) // deterministic across instances
 }
 
+func TestRevalidationRequestOfStreamRequestThenUnaryInternalRequestWithSameHash(t *testing.T) {
+	body := protocol.JsonRpcRequestBody{Id: []byte(`7`), Method: "eth_call", Params: []byte(`["0x1"]`)}
+	request := protocol.NewStreamUpstreamJsonRpcRequest("1", body, "eth", protocol.RequestLabelSelector{Name: "client", Values: []string{"geth"}})
+
+	revalidation, ok := protocol.NewRevalidationRequest(request)
+
+	assert.True(t, ok)
+	assert.NotSame(t, request, revalidation)
+	assert.False(t, revalidation.IsStream())
+	assert.Equal(t, request.Method(), revalidation.Method())
+	assert.Equal(t, request.RequestHash(), revalidation.RequestHash())
+	assert.Equal(t, request.Selectors(), revalidation.Selectors())
+	assert.Equal(t, protocol.InternalUnary, revalidation.RequestObserver().GetRequestKind())
+	requestBody, _ := request.Body()
+	revalidationBody, _ := revalidation.Body()
+	assert.Equal(t, requestBody, revalidationBody)
+}
+
 func TestInternalJsonRpcRequestNilParamsEncodedAsEmptyArray(t *testing.T) {
 	request, err := protocol.NewInternalUpstreamJsonRpcRequest("eth_chainId", nil, chains.ETHEREUM)
 	assert.NoError(t, err)
@@ -1,6 +1,7 @@
 package protocol
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
 	"errors"
@@ -8,6 +9,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"testing/iotest"
 
 	"github.com/bytedance/sonic"
@@ -311,6 +313,8 @@ type GenericUpstreamResponse struct {
 	// nil when there is no hint (e.g. a REST stream); the HTTP consumer ignores
 	// it and streams the whole envelope.
 	streamHint StreamHint
+	// resultStream is set if the stream holds only the result of a JSON-RPC response, not the whole envelope
+	resultStream bool
 }
 
 func (h *GenericUpstreamResponse) ResponseCode() int {
@@ -356,6 +360,9 @@ func (h *GenericUpstreamResponse) EncodeResponse(realId []byte) io.Reader {
 		if h.HasError() {
 			return jsonRpcResponseReader(realId, "error", h.ResponseResult())
 		}
+		if h.stream != nil && h.resultStream {
+			return jsonRpcResultStreamReader(realId, h.stream)
+		}
 		if h.stream != nil {
 			return h.stream
 		}
@@ -381,6 +388,68 @@ func jsonRpcResponseReader(id []byte, bodyName string, body []byte) io.Reader {
 	)
 }
 
+// jsonRpcResultStreamPrefix starts the envelope of a streamed result. The id follows the result,
+// so the result starts at the same offset whatever the id is
+const jsonRpcResultStreamPrefix = `{"jsonrpc":"2.0","result":`
+
+func jsonRpcResultStreamReader(id []byte, result io.Reader) io.Reader {
+	return &envelopeReader{
+		Reader: io.MultiReader(
+			strings.NewReader(jsonRpcResultStreamPrefix),
+			result,
+			strings.NewReader(`,"id":`),
+			bytes.NewReader(id),
+			strings.NewReader("}"),
+		),
+		result: result,
+	}
+}
+
+// envelopeReader reads the envelope of a streamed result, closing it closes the result stream
+type envelopeReader struct {
+	io.Reader
+	result io.Reader
+}
+
+func (e *envelopeReader) Close() error {
+	if closer, ok := e.result.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// NewResultResponseStream returns a response streaming a result that is known to be successful, e.g. a cached one.
+// The result of a JSON-RPC response is wrapped into the envelope with the real id when the response is encoded
+func NewResultResponseStream(id string, result io.Reader, requestType RequestType) *GenericUpstreamResponse {
+	response := NewHttpUpstreamResponseStream(id, result, requestType)
+	if requestType != JsonRpc {
+		return response
+	}
+	response.resultStream = true
+	reader := bufio.NewReaderSize(result, MaxChunkSize)
+	response.stream = reader
+	if closer, ok := result.(io.Closer); ok {
+		response.stream = &bufferedStream{Reader: reader, closer: closer}
+	}
+	if first, err := reader.Peek(1); err == nil {
+		response.streamHint = JsonRpcResultStreamHint{
+			ResultStart: len(jsonRpcResultStreamPrefix),
+			Counter:     resultCounterFor(first[0]),
+		}
+	}
+	return response
+}
+
+// bufferedStream keeps the stream closable once it's buffered
+type bufferedStream struct {
+	*bufio.Reader
+	closer io.Closer
+}
+
+func (b *bufferedStream) Close() error {
+	return b.closer.Close()
+}
+
 func NewHttpUpstreamResponseStream(id string, reader io.Reader, requestType RequestType) *GenericUpstreamResponse {
 	return &GenericUpstreamResponse{
 		id:          id,
@@ -397,6 +466,17 @@ func (h *GenericUpstreamResponse) WithStreamHint(hint StreamHint) *GenericUpstre
 	return h
 }
 
+// OnStreamDone makes done called once the stream of the response is read to the end, fails or is closed.
+// A response without a stream calls done right away
+func (h *GenericUpstreamResponse) OnStreamDone(done func()) *GenericUpstreamResponse {
+	if h.stream == nil {
+		done()
+		return h
+	}
+	h.stream = &doneReader{reader: h.stream, done: done}
+	return h
+}
+
 // GetStreamHint returns the streaming hint, or nil if none was recorded.
 func (h *GenericUpstreamResponse) GetStreamHint() StreamHint {
 	return h.streamHint
@@ -447,6 +527,16 @@ func ResultAsNumber(result []byte) uint64 {
 	return uint64(num)
 }
 
+// NewJsonRpcUpstreamErrorResponse restores an upstream error response from its raw JSON-RPC error object
+func NewJsonRpcUpstreamErrorResponse(id string, rawError []byte) *GenericUpstreamResponse {
+	return &GenericUpstreamResponse{
+		id:          id,
+		result:      rawError,
+		error:       parseJsonRpcError(rawError),
+		requestType: JsonRpc,
+	}
+}
+
 func NewHttpUpstreamResponseWithError(error *ResponseError) *GenericUpstreamResponse {
 	return &GenericUpstreamResponse{
 		error: error,
@@ -597,3 +687,24 @@ func (r *ReplyError) Id() string {
 }
 
 var _ ResponseHolder = (*ReplyError)(nil)
+
+// CopyResponseWithId returns a copy of a unary response that answers the request with the passed id,
+// the result is shared between the copies so it must not be modified.
+// Streams can be read only once, so they and subscription responses are not copied
+func CopyResponseWithId(response ResponseHolder, id string) (ResponseHolder, bool) {
+	switch resp := response.(type) {
+	case *GenericUpstreamResponse:
+		if resp.HasStream() {
+			return nil, false
+		}
+		responseCopy := *resp
+		responseCopy.id = id
+		return &responseCopy, true
+	case *WsJsonRpcResponse:
+		return NewWsJsonRpcResponse(id, resp.result, resp.error), true
+	case *ReplyError:
+		return NewReplyError(id, resp.responseError, resp.responseType, resp.ErrorKind), true
+	default:
+		return nil, false
+	}
+}
@@ -67,6 +67,19 @@ func NewStreamUpstreamRestRequest(id, methodTemplate string, requestParams *Requ
 	return request
 }
 
+// revalidation returns a new internal request with the params and selectors of the request
+func (u *UpstreamRestRequest) revalidation() *UpstreamRestRequest {
+	return &UpstreamRestRequest{
+		id:            u.id,
+		method:        u.method,
+		body:          u.body,
+		requestParams: u.requestParams,
+		observer:      NewRequestObserver(false).WithRequestKind(InternalUnary).WithMethod(u.method),
+		specMethod:    u.specMethod,
+		selectors:     u.Selectors(),
+	}
+}
+
 func (u *UpstreamRestRequest) RequestObserver() *RequestObserver {
 	return u.observer
 }
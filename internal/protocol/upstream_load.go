@@ -0,0 +1,45 @@
+package protocol
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// latencyEwmaAlpha is the weight of a new latency sample in the moving average
+const latencyEwmaAlpha = 0.2
+
+// UpstreamLoad is the exponentially weighted moving average of the latency of an upstream,
+// the requests in flight are counted by its concurrency limiter
+type UpstreamLoad struct {
+	latencyEwma atomic.Uint64 // float64 bits of seconds
+}
+
+func NewUpstreamLoad() *UpstreamLoad {
+	return &UpstreamLoad{}
+}
+
+func (l *UpstreamLoad) ObserveLatency(latency time.Duration) {
+	sample := latency.Seconds()
+	for {
+		current := l.latencyEwma.Load()
+		currentValue := math.Float64frombits(current)
+		newValue := sample
+		if current != 0 {
+			newValue = currentValue + latencyEwmaAlpha*(sample-currentValue)
+		}
+		if l.latencyEwma.CompareAndSwap(current, math.Float64bits(newValue)) {
+			return
+		}
+	}
+}
+
+// GetLatencyEwma returns the latency average in seconds, 0 if there are no samples yet
+func (l *UpstreamLoad) GetLatencyEwma() float64 {
+	return math.Float64frombits(l.latencyEwma.Load())
+}
+
+// Cost is the expected wait of a new request, the latency average multiplied by the number of requests it queues behind
+func (l *UpstreamLoad) Cost(inFlight int64) float64 {
+	return l.GetLatencyEwma() * float64(inFlight+1)
+}
@@ -0,0 +1,29 @@
+package protocol_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpstreamLoadLatencyEwma(t *testing.T) {
+	load := protocol.NewUpstreamLoad()
+	assert.Zero(t, load.GetLatencyEwma())
+
+	load.ObserveLatency(time.Second)
+	assert.InDelta(t, 1.0, load.GetLatencyEwma(), 1e-9)
+
+	load.ObserveLatency(2 * time.Second)
+	assert.InDelta(t, 1.2, load.GetLatencyEwma(), 1e-9)
+}
+
+func TestUpstreamLoadCost(t *testing.T) {
+	load := protocol.NewUpstreamLoad()
+	assert.Zero(t, load.Cost(2))
+
+	load.ObserveLatency(100 * time.Millisecond)
+	assert.InDelta(t, 0.1, load.Cost(0), 1e-9)
+	assert.InDelta(t, 0.3, load.Cost(2), 1e-9)
+}
@@ -1,7 +1,10 @@
 package protocol
 
 import (
+	"maps"
+
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/breaker"
 	"github.com/samber/lo"
 )
 
@@ -140,6 +143,21 @@ func (u *UnbanMethodUpstreamStateEvent) ProcessEvent(state UpstreamState) Upstre
 	return state
 }
 
+// MaintenanceUpstreamStateEvent puts an upstream into maintenance or takes it out.
+// An upstream in maintenance is removed from its chain, so it's drained of new requests,
+// and is added back once the maintenance is over.
+type MaintenanceUpstreamStateEvent struct {
+	Enabled bool
+}
+
+func (m *MaintenanceUpstreamStateEvent) Same(_ UpstreamState) bool {
+	return false
+}
+
+func (m *MaintenanceUpstreamStateEvent) ProcessEvent(state UpstreamState) UpstreamState {
+	return state
+}
+
 // UnsupportedMethodsUpstreamStateEvent replaces the upstream's detected-unsupported set
 // wholesale. Detection produces a whole-set view - the pipeline has already merged every
 // detector's verdict - so this carries the full set rather than incremental
@@ -199,10 +217,41 @@ func (l *LabelsUpstreamStateEvent) ProcessEvent(state UpstreamState) UpstreamSta
 	return state
 }
 
+// CircuitBreakerUpstreamStateEvent is a state transition of a circuit of the upstream circuit breaker.
+// The breaker itself is the source of truth for the upstream selection, the event only publishes the new state
+type CircuitBreakerUpstreamStateEvent struct {
+	Method string
+	State  breaker.State
+}
+
+func (c *CircuitBreakerUpstreamStateEvent) Same(state UpstreamState) bool {
+	circuitState, ok := state.CircuitStates[c.Method]
+	if !ok {
+		return c.State == breaker.Closed
+	}
+	return circuitState == c.State
+}
+
+func (c *CircuitBreakerUpstreamStateEvent) ProcessEvent(state UpstreamState) UpstreamState {
+	circuitStates := maps.Clone(state.CircuitStates)
+	if circuitStates == nil {
+		circuitStates = map[string]breaker.State{}
+	}
+	if c.State == breaker.Closed {
+		delete(circuitStates, c.Method)
+	} else {
+		circuitStates[c.Method] = c.State
+	}
+	state.CircuitStates = circuitStates
+	return state
+}
+
+var _ AbstractUpstreamStateEvent = (*CircuitBreakerUpstreamStateEvent)(nil)
 var _ AbstractUpstreamStateEvent = (*LabelsUpstreamStateEvent)(nil)
 var _ AbstractUpstreamStateEvent = (*CapsUpstreamStateEvent)(nil)
 var _ AbstractUpstreamStateEvent = (*UnsupportedMethodsUpstreamStateEvent)(nil)
 var _ AbstractUpstreamStateEvent = (*UnbanMethodUpstreamStateEvent)(nil)
+var _ AbstractUpstreamStateEvent = (*MaintenanceUpstreamStateEvent)(nil)
 var _ AbstractUpstreamStateEvent = (*BanMethodUpstreamStateEvent)(nil)
 var _ AbstractUpstreamStateEvent = (*BlockUpstreamStateEvent)(nil)
 var _ AbstractUpstreamStateEvent = (*HeadUpstreamStateEvent)(nil)
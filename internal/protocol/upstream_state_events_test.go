@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/breaker"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
 	"github.com/samber/lo"
@@ -198,3 +199,24 @@ func TestUnsupportedMethodsUpstreamStateEvent(t *testing.T) {
 	assert.False(t, event.Same(state), "dedup is the event loop's job, so Same must always report false")
 	assert.Equal(t, state, event.ProcessEvent(state), "the set is applied by processStateEvents, not here")
 }
+
+func TestCircuitBreakerUpstreamStateEvent(t *testing.T) {
+	state := newUpstreamState()
+	open := &protocol.CircuitBreakerUpstreamStateEvent{Method: breaker.AnyMethod, State: breaker.Open}
+	closed := &protocol.CircuitBreakerUpstreamStateEvent{Method: breaker.AnyMethod, State: breaker.Closed}
+
+	assert.True(t, closed.Same(state))
+	assert.False(t, open.Same(state))
+
+	openState := open.ProcessEvent(state)
+
+	assert.Nil(t, state.CircuitStates)
+	assert.Equal(t, map[string]breaker.State{breaker.AnyMethod: breaker.Open}, openState.CircuitStates)
+	assert.True(t, open.Same(openState))
+	assert.False(t, closed.Same(openState))
+
+	closedState := closed.ProcessEvent(openState)
+
+	assert.Empty(t, closedState.CircuitStates)
+	assert.Equal(t, breaker.Open, openState.CircuitStates[breaker.AnyMethod])
+}
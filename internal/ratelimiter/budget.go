@@ -34,20 +34,82 @@ type RateLimitBudget struct {
 
 func (b *RateLimitBudget) Allow(method string) (bool, error) {
 	rateLimitRequestMetrics.WithLabelValues(b.Name, method).Inc()
+	allowed, err := b.Engine.Execute(b.commands(b.Name, method))
+	if !allowed {
+		rateLimitExceededMetrics.WithLabelValues(b.Name, method).Inc()
+	}
+	return allowed, err
+}
+
+// AllowWithQuota checks the rules matching the method with separate counters for every subject,
+// e.g. a client ip, and returns the quota of the most restrictive rule or nil if no rule matches
+func (b *RateLimitBudget) AllowWithQuota(subject, method string) (bool, *RateLimitQuota, error) {
+	return b.AllowBatchWithQuota(subject, []string{method})
+}
+
+// AllowBatchWithQuota is AllowWithQuota for several requests sent together, e.g. a batch.
+// A rule counts all the matching requests at once, so either all of them fit into it or none is counted
+func (b *RateLimitBudget) AllowBatchWithQuota(subject string, methods []string) (bool, *RateLimitQuota, error) {
+	for _, method := range methods {
+		rateLimitRequestMetrics.WithLabelValues(b.Name, method).Inc()
+	}
+	quotas, err := b.Engine.ExecuteWithQuotas(b.batchCommands(b.Name+"-"+subject, methods))
+	if err != nil {
+		return false, nil, err
+	}
+	allowed, quota := b.batchQuota(quotas)
+	if !allowed {
+		b.countExceeded(methods)
+	}
+	return allowed, quota, nil
+}
+
+// CheckBatchWithQuota tells if AllowBatchWithQuota would allow the requests without counting them
+func (b *RateLimitBudget) CheckBatchWithQuota(subject string, methods []string) (bool, *RateLimitQuota, error) {
+	quotas, err := b.Engine.CheckWithQuotas(b.batchCommands(b.Name+"-"+subject, methods))
+	if err != nil {
+		return false, nil, err
+	}
+	allowed, quota := b.batchQuota(quotas)
+	return allowed, quota, nil
+}
+
+func (b *RateLimitBudget) batchQuota(quotas []RateLimitQuota) (bool, *RateLimitQuota) {
+	var quota *RateLimitQuota
+	for i := range quotas {
+		quota = restrictiveQuota(quota, &quotas[i])
+	}
+	return allQuotasAllowed(quotas), quota
+}
+
+func (b *RateLimitBudget) countExceeded(methods []string) {
+	for _, method := range methods {
+		rateLimitExceededMetrics.WithLabelValues(b.Name, method).Inc()
+	}
+}
+
+func (b *RateLimitBudget) commands(prefix, method string) []RateLimitCommand {
+	return b.batchCommands(prefix, []string{method})
+}
+
+func (b *RateLimitBudget) batchCommands(prefix string, methods []string) []RateLimitCommand {
 	items := make([]RateLimitCommand, 0)
 	for i, rule := range b.Rules {
-		if rule.Check.match(method) {
+		count := 0
+		for _, method := range methods {
+			if rule.Check.match(method) {
+				count++
+			}
+		}
+		if count > 0 {
 			items = append(items, RateLimitCommand{
-				Type: rule.Type,
-				Name: b.Name + "-" + strconv.Itoa(i) + "-" + rule.Check.name(),
+				Type:  rule.Type,
+				Name:  prefix + "-" + strconv.Itoa(i) + "-" + rule.Check.name(),
+				Count: count,
 			})
 		}
 	}
-	allowed, err := b.Engine.Execute(items)
-	if !allowed {
-		rateLimitExceededMetrics.WithLabelValues(b.Name, method).Inc()
-	}
-	return allowed, err
+	return items
 }
 
 func NewRateLimitBudget(config *config.RateLimitBudget, engine RateLimitEngine) *RateLimitBudget {
@@ -2,13 +2,25 @@ package ratelimiter
 
 import (
 	"fmt"
+	"reflect"
+	"sync"
 
+	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/storages"
+	"github.com/samber/lo"
 )
 
+type budgetEntry struct {
+	budget  *RateLimitBudget
+	config  config.RateLimitBudget
+	storage string
+}
+
 type RateLimitBudgetRegistry struct {
-	rateLimitBudgets map[string]*RateLimitBudget
+	mu               sync.RWMutex
+	rateLimitBudgets map[string]*budgetEntry
+	storageRegistry  *storages.StorageRegistry
 }
 
 func storageToEngine(name string, storage storages.Storage) (RateLimitEngine, error) {
@@ -21,47 +33,101 @@ func storageToEngine(name string, storage storages.Storage) (RateLimitEngine, er
 }
 
 func NewRateLimitBudgetRegistry(cfg []config.RateLimitBudgetsConfig, storageRegistry *storages.StorageRegistry) (*RateLimitBudgetRegistry, error) {
-	var defaultEngine RateLimitEngine
-	rateLimitBudgets := make(map[string]*RateLimitBudget)
+	registry := &RateLimitBudgetRegistry{
+		rateLimitBudgets: make(map[string]*budgetEntry),
+		storageRegistry:  storageRegistry,
+	}
+	if _, err := registry.Update(cfg); err != nil {
+		return nil, err
+	}
+	return registry, nil
+}
+
+// Update replaces the budgets with the ones from cfg, it's PrepareUpdate followed by Apply.
+// Nothing is replaced if an error is returned.
+func (r *RateLimitBudgetRegistry) Update(cfg []config.RateLimitBudgetsConfig) (mapset.Set[string], error) {
+	update, err := r.PrepareUpdate(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return update.Apply(), nil
+}
+
+// BudgetsUpdate is a new set of budgets ready to replace the current one
+type BudgetsUpdate struct {
+	registry         *RateLimitBudgetRegistry
+	rateLimitBudgets map[string]*budgetEntry
+}
+
+// PrepareUpdate builds the budgets from cfg without replacing the current ones. A budget whose config
+// and storage are unchanged keeps its instance, so its engine state (e.g. in-memory counters) survives
+func (r *RateLimitBudgetRegistry) PrepareUpdate(cfg []config.RateLimitBudgetsConfig) (*BudgetsUpdate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	rateLimitBudgets := make(map[string]*budgetEntry)
 	for _, budgetConfig := range cfg {
-		if budgetConfig.DefaultStorage == "" {
-			defaultEngine = NewRateLimitMemoryEngine()
-		} else {
-			storage, ok := storageRegistry.Get(budgetConfig.DefaultStorage)
-			if !ok {
-				return nil, fmt.Errorf("default storage %s not found", budgetConfig.DefaultStorage)
+		for _, budget := range budgetConfig.Budgets {
+			storageName := lo.CoalesceOrEmpty(budget.Storage, budgetConfig.DefaultStorage)
+			if current, ok := r.rateLimitBudgets[budget.Name]; ok && current.storage == storageName && reflect.DeepEqual(current.config, budget) {
+				rateLimitBudgets[budget.Name] = current
+				continue
 			}
-			engine, err := storageToEngine(budgetConfig.DefaultStorage, storage)
+			engine, err := createEngine(r.storageRegistry, storageName)
 			if err != nil {
-				return nil, fmt.Errorf("couldn't create a rate limit engine for storage %s, reason - %s", budgetConfig.DefaultStorage, err.Error())
+				return nil, err
+			}
+			rateLimitBudgets[budget.Name] = &budgetEntry{
+				budget:  NewRateLimitBudget(&budget, engine),
+				config:  budget,
+				storage: storageName,
 			}
-			defaultEngine = engine
 		}
+	}
 
-		for _, budget := range budgetConfig.Budgets {
-			budgetEngine := defaultEngine
-			if budget.Storage != "" {
-				var ok bool
-				storage, ok := storageRegistry.Get(budget.Storage)
-				if !ok {
-					return nil, fmt.Errorf("storage %s not found", budget.Storage)
-				}
-				engine, err := storageToEngine(budget.Storage, storage)
-				if err != nil {
-					return nil, fmt.Errorf("couldn't create a rate limit engine for storage %s, reason - %s", budget.Storage, err.Error())
-				}
-				budgetEngine = engine
-			}
-			rateLimitBudget := NewRateLimitBudget(&budget, budgetEngine)
-			rateLimitBudgets[budget.Name] = rateLimitBudget
+	return &BudgetsUpdate{registry: r, rateLimitBudgets: rateLimitBudgets}, nil
+}
+
+// Apply replaces the budgets and returns the names of the budgets that have been changed or removed -
+// upstreams referencing them hold the old instances and have to be recreated
+func (u *BudgetsUpdate) Apply() mapset.Set[string] {
+	r := u.registry
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	changedBudgets := mapset.NewThreadUnsafeSet[string]()
+	for name, current := range r.rateLimitBudgets {
+		if newEntry, ok := u.rateLimitBudgets[name]; !ok || newEntry != current {
+			changedBudgets.Add(name)
 		}
 	}
-	return &RateLimitBudgetRegistry{
-		rateLimitBudgets: rateLimitBudgets,
-	}, nil
+	r.rateLimitBudgets = u.rateLimitBudgets
+
+	return changedBudgets
+}
+
+func createEngine(storageRegistry *storages.StorageRegistry, storageName string) (RateLimitEngine, error) {
+	if storageName == "" {
+		return NewRateLimitMemoryEngine(), nil
+	}
+	storage, ok := storageRegistry.Get(storageName)
+	if !ok {
+		return nil, fmt.Errorf("storage %s not found", storageName)
+	}
+	engine, err := storageToEngine(storageName, storage)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create a rate limit engine for storage %s, reason - %s", storageName, err.Error())
+	}
+	return engine, nil
 }
 
 func (r *RateLimitBudgetRegistry) Get(name string) (*RateLimitBudget, bool) {
-	budget, ok := r.rateLimitBudgets[name]
-	return budget, ok
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	entry, ok := r.rateLimitBudgets[name]
+	if !ok {
+		return nil, false
+	}
+	return entry.budget, true
 }
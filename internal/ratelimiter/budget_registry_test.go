@@ -0,0 +1,88 @@
+package ratelimiter
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/storages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func budgetsConfig(budgets ...config.RateLimitBudget) []config.RateLimitBudgetsConfig {
+	return []config.RateLimitBudgetsConfig{{Budgets: budgets}}
+}
+
+func budgetConfig(name string, requests int) config.RateLimitBudget {
+	return config.RateLimitBudget{
+		Name: name,
+		Config: &config.RateLimiterConfig{
+			Rules: []config.RateLimitRule{{Method: "eth_call", Requests: requests, Period: time.Second}},
+		},
+	}
+}
+
+func TestRateLimitBudgetRegistryUpdateKeepsUnchangedBudgets(t *testing.T) {
+	storageRegistry, err := storages.NewStorageRegistry([]config.AppStorageConfig{})
+	require.NoError(t, err)
+	registry, err := NewRateLimitBudgetRegistry(budgetsConfig(budgetConfig("kept", 1), budgetConfig("changed", 1), budgetConfig("removed", 1)), storageRegistry)
+	require.NoError(t, err)
+
+	keptBudget, _ := registry.Get("kept")
+	changedBudget, _ := registry.Get("changed")
+
+	changedBudgets, err := registry.Update(budgetsConfig(budgetConfig("kept", 1), budgetConfig("changed", 2), budgetConfig("added", 1)))
+	require.NoError(t, err)
+
+	assert.ElementsMatch(t, []string{"changed", "removed"}, changedBudgets.ToSlice())
+
+	newKeptBudget, ok := registry.Get("kept")
+	assert.True(t, ok)
+	assert.Same(t, keptBudget, newKeptBudget)
+
+	newChangedBudget, ok := registry.Get("changed")
+	assert.True(t, ok)
+	assert.NotSame(t, changedBudget, newChangedBudget)
+
+	_, ok = registry.Get("added")
+	assert.True(t, ok)
+	_, ok = registry.Get("removed")
+	assert.False(t, ok)
+}
+
+func TestRateLimitBudgetRegistryUpdateWithUnknownStorageThenKeepCurrentBudgets(t *testing.T) {
+	storageRegistry, err := storages.NewStorageRegistry([]config.AppStorageConfig{})
+	require.NoError(t, err)
+	registry, err := NewRateLimitBudgetRegistry(budgetsConfig(budgetConfig("budget", 1)), storageRegistry)
+	require.NoError(t, err)
+
+	newBudget := budgetConfig("budget", 2)
+	newBudget.Storage = "unknown"
+	_, err = registry.Update(budgetsConfig(newBudget))
+
+	assert.ErrorContains(t, err, "storage unknown not found")
+	budget, ok := registry.Get("budget")
+	assert.True(t, ok)
+	assert.Equal(t, 1, budget.Rules[0].Type.(*FixedRateLimiterType).requests)
+}
+
+func TestRateLimitBudgetRegistryPrepareUpdateThenApply(t *testing.T) {
+	storageRegistry, err := storages.NewStorageRegistry([]config.AppStorageConfig{})
+	require.NoError(t, err)
+	registry, err := NewRateLimitBudgetRegistry(budgetsConfig(budgetConfig("budget", 1)), storageRegistry)
+	require.NoError(t, err)
+	currentBudget, _ := registry.Get("budget")
+
+	update, err := registry.PrepareUpdate(budgetsConfig(budgetConfig("budget", 2)))
+	require.NoError(t, err)
+
+	budget, _ := registry.Get("budget")
+	assert.Same(t, currentBudget, budget)
+
+	changedBudgets := update.Apply()
+
+	assert.ElementsMatch(t, []string{"budget"}, changedBudgets.ToSlice())
+	budget, _ = registry.Get("budget")
+	assert.Equal(t, 2, budget.Rules[0].Type.(*FixedRateLimiterType).requests)
+}
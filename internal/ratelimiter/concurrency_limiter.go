@@ -0,0 +1,234 @@
+package ratelimiter
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/rs/zerolog/log"
+)
+
+var concurrencyLimitMetric = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: config.AppName,
+		Subsystem: "upstream",
+		Name:      "concurrency_limit",
+		Help:      "The current adaptive concurrency limit of an upstream",
+	},
+	[]string{"chain", "upstream"},
+)
+
+var concurrencyInFlightMetric = prometheus.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Namespace: config.AppName,
+		Subsystem: "upstream",
+		Name:      "concurrency_in_flight",
+		Help:      "The number of requests in flight to an upstream with a concurrency limit",
+	},
+	[]string{"chain", "upstream"},
+)
+
+var concurrencyRejectionsMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "upstream",
+		Name:      "concurrency_limit_rejections_total",
+		Help:      "The total number of times an upstream was skipped because its concurrency limit was reached",
+	},
+	[]string{"chain", "upstream"},
+)
+
+func init() {
+	prometheus.MustRegister(concurrencyLimitMetric, concurrencyInFlightMetric, concurrencyRejectionsMetric)
+}
+
+const (
+	// minWindowSamples is the number of latency samples a window needs to recalculate the limit,
+	// the window is extended until it has that many
+	minWindowSamples = 10
+	// noLoadLatencyAlpha is the weight of a window latency in the no-load latency, the no-load latency
+	// drops to a lower window latency at once and follows a higher one slowly
+	noLoadLatencyAlpha = 0.05
+	// gradientSmoothing is the weight of a new gradient limit in the limit
+	gradientSmoothing = 0.2
+	// minGradient bounds how much the gradient algorithm can cut the limit in one window
+	minGradient = 0.5
+)
+
+// ConcurrencyLimiter limits the number of requests in flight to an upstream. The limit adapts to the latency
+// of the upstream: once the latency of a window grows beyond the no-load latency, the upstream is queueing
+// requests and the limit goes down, so that excess requests go to other upstreams instead of piling up.
+// The limit goes up only while it is used, an idle upstream keeps its limit.
+// A request takes its place in flight with TryAcquire when its upstream is selected and gives it back
+// with RequestFinished, so concurrent selections can't exceed the limit together
+type ConcurrencyLimiter struct {
+	chain      chains.Chain
+	upstreamId string
+	cfg        *config.ConcurrencyLimitConfig
+	limit      atomic.Int64
+	inFlight   atomic.Int64
+
+	mu                sync.Mutex
+	exactLimit        float64
+	noLoadLatency     float64 // seconds
+	windowStart       time.Time
+	windowLatency     float64 // the sum of latencies in seconds
+	windowMaxLatency  float64 // seconds
+	lastMaxLatency    float64 // the highest latency of the previous window in seconds
+	windowSamples     int
+	windowMaxInFlight int64
+}
+
+func NewConcurrencyLimiter(chain chains.Chain, upstreamId string, cfg *config.ConcurrencyLimitConfig) *ConcurrencyLimiter {
+	limiter := &ConcurrencyLimiter{
+		chain:       chain,
+		upstreamId:  upstreamId,
+		cfg:         cfg,
+		exactLimit:  float64(cfg.InitialLimit),
+		windowStart: time.Now(),
+	}
+	limiter.limit.Store(int64(cfg.InitialLimit))
+	concurrencyLimitMetric.WithLabelValues(chain.String(), upstreamId).Set(float64(cfg.InitialLimit))
+	return limiter
+}
+
+// TryAcquire takes a place in flight if the upstream has one under its limit, it's always true for a nil limiter.
+// The place is given back with RequestFinished
+func (c *ConcurrencyLimiter) TryAcquire() bool {
+	if c == nil {
+		return true
+	}
+	for {
+		inFlight := c.inFlight.Load()
+		if inFlight >= c.limit.Load() {
+			concurrencyRejectionsMetric.WithLabelValues(c.chain.String(), c.upstreamId).Inc()
+			return false
+		}
+		if c.inFlight.CompareAndSwap(inFlight, inFlight+1) {
+			c.started(inFlight + 1)
+			return true
+		}
+	}
+}
+
+// RequestStarted takes a place in flight regardless of the limit, for requests sent to an upstream without selecting it
+func (c *ConcurrencyLimiter) RequestStarted() {
+	if c == nil {
+		return
+	}
+	c.started(c.inFlight.Add(1))
+}
+
+func (c *ConcurrencyLimiter) started(inFlight int64) {
+	concurrencyInFlightMetric.WithLabelValues(c.chain.String(), c.upstreamId).Set(float64(inFlight))
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.windowMaxInFlight = max(c.windowMaxInFlight, inFlight)
+}
+
+func (c *ConcurrencyLimiter) RequestFinished() {
+	if c == nil {
+		return
+	}
+	inFlight := c.inFlight.Add(-1)
+	concurrencyInFlightMetric.WithLabelValues(c.chain.String(), c.upstreamId).Set(float64(inFlight))
+}
+
+// ObserveLatency adds a latency sample to the current window, the limit is recalculated once the window is over
+func (c *ConcurrencyLimiter) ObserveLatency(latency time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addSample(latency.Seconds())
+}
+
+// ObserveDropped adds a sample of a request that timed out or was cancelled before its response. Its latency
+// is unknown but at least as high as the one it was dropped after, so it counts as the highest latency
+// of this window or the previous one
+func (c *ConcurrencyLimiter) ObserveDropped(droppedAfter time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.addSample(max(droppedAfter.Seconds(), c.windowMaxLatency, c.lastMaxLatency))
+}
+
+// addSample must be called under mu
+func (c *ConcurrencyLimiter) addSample(latency float64) {
+	c.windowLatency += latency
+	c.windowMaxLatency = max(c.windowMaxLatency, latency)
+	c.windowSamples++
+	if c.windowSamples < minWindowSamples || time.Since(c.windowStart) < c.cfg.Window {
+		return
+	}
+	c.recalculate(c.windowLatency / float64(c.windowSamples))
+
+	c.windowStart = time.Now()
+	c.windowLatency = 0
+	c.lastMaxLatency = c.windowMaxLatency
+	c.windowMaxLatency = 0
+	c.windowSamples = 0
+	c.windowMaxInFlight = c.inFlight.Load()
+}
+
+func (c *ConcurrencyLimiter) GetLimit() int64 {
+	return c.limit.Load()
+}
+
+func (c *ConcurrencyLimiter) GetInFlight() int64 {
+	return c.inFlight.Load()
+}
+
+// recalculate must be called under mu
+func (c *ConcurrencyLimiter) recalculate(windowLatency float64) {
+	if c.noLoadLatency == 0 || windowLatency < c.noLoadLatency {
+		c.noLoadLatency = windowLatency
+	} else {
+		c.noLoadLatency += noLoadLatencyAlpha * (windowLatency - c.noLoadLatency)
+	}
+	// the limit is used if the window had at least half of it in flight
+	used := float64(c.windowMaxInFlight)*2 >= c.exactLimit
+
+	newLimit := c.exactLimit
+	switch c.cfg.Algorithm {
+	case config.AimdConcurrencyLimit:
+		if windowLatency > c.cfg.Tolerance*c.noLoadLatency {
+			newLimit = c.exactLimit * c.cfg.BackoffRatio
+		} else if used {
+			newLimit = c.exactLimit + 1
+		}
+	default:
+		gradient := 1.0
+		if windowLatency > 0 {
+			gradient = math.Max(minGradient, math.Min(1, c.cfg.Tolerance*c.noLoadLatency/windowLatency))
+		}
+		gradientLimit := c.exactLimit * gradient
+		if used {
+			// the headroom lets the limit grow while the latency stays within the tolerance
+			gradientLimit += math.Sqrt(c.exactLimit)
+		}
+		newLimit = c.exactLimit*(1-gradientSmoothing) + gradientLimit*gradientSmoothing
+	}
+	c.exactLimit = math.Max(float64(c.cfg.MinLimit), math.Min(float64(c.cfg.MaxLimit), newLimit))
+
+	oldLimit := c.limit.Load()
+	limit := int64(c.exactLimit)
+	if limit != oldLimit {
+		c.limit.Store(limit)
+		concurrencyLimitMetric.WithLabelValues(c.chain.String(), c.upstreamId).Set(float64(limit))
+		log.Debug().
+			Int64("old_limit", oldLimit).
+			Int64("new_limit", limit).
+			Float64("latency", windowLatency).
+			Float64("no_load_latency", c.noLoadLatency).
+			Msgf("concurrency limit of upstream %s has been changed", c.upstreamId)
+	}
+}
@@ -0,0 +1,164 @@
+package ratelimiter
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func concurrencyLimitConfig(algorithm config.ConcurrencyLimitAlgorithm) *config.ConcurrencyLimitConfig {
+	return &config.ConcurrencyLimitConfig{
+		Enabled:      true,
+		Algorithm:    algorithm,
+		InitialLimit: 20,
+		MinLimit:     2,
+		MaxLimit:     100,
+		Window:       time.Nanosecond,
+		Tolerance:    1.5,
+		BackoffRatio: 0.5,
+	}
+}
+
+// runWindow sends a window of requests with the given number in flight and the given latency
+func runWindow(limiter *ConcurrencyLimiter, inFlight int, latency time.Duration) {
+	for i := 0; i < inFlight; i++ {
+		limiter.RequestStarted()
+	}
+	for i := 0; i < minWindowSamples; i++ {
+		limiter.ObserveLatency(latency)
+	}
+	for i := 0; i < inFlight; i++ {
+		limiter.RequestFinished()
+	}
+}
+
+func TestConcurrencyLimiterTryAcquire(t *testing.T) {
+	limiter := NewConcurrencyLimiter(chains.ETHEREUM, "acquire", concurrencyLimitConfig(config.GradientConcurrencyLimit))
+
+	for i := 0; i < 20; i++ {
+		assert.True(t, limiter.TryAcquire())
+	}
+	assert.False(t, limiter.TryAcquire())
+	assert.Equal(t, float64(1), testutil.ToFloat64(concurrencyRejectionsMetric.WithLabelValues("ethereum", "acquire")))
+	assert.Equal(t, float64(20), testutil.ToFloat64(concurrencyInFlightMetric.WithLabelValues("ethereum", "acquire")))
+
+	limiter.RequestFinished()
+	assert.Equal(t, int64(19), limiter.GetInFlight())
+	assert.True(t, limiter.TryAcquire())
+	assert.Equal(t, int64(20), limiter.GetInFlight())
+}
+
+func TestConcurrencyLimiterConcurrentTryAcquireThenNoMoreThanLimit(t *testing.T) {
+	limiter := NewConcurrencyLimiter(chains.ETHEREUM, "acquire-concurrent", concurrencyLimitConfig(config.GradientConcurrencyLimit))
+
+	var acquired atomic.Int64
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if limiter.TryAcquire() {
+				acquired.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(20), acquired.Load())
+	assert.Equal(t, int64(20), limiter.GetInFlight())
+}
+
+func TestConcurrencyLimiterNilAllows(t *testing.T) {
+	var limiter *ConcurrencyLimiter
+
+	limiter.RequestStarted()
+	limiter.ObserveLatency(time.Second)
+	limiter.ObserveDropped(time.Second)
+	limiter.RequestFinished()
+
+	assert.True(t, limiter.TryAcquire())
+}
+
+func TestConcurrencyLimiterGradientGrowsWhileLatencyIsStable(t *testing.T) {
+	limiter := NewConcurrencyLimiter(chains.ETHEREUM, "gradient-grow", concurrencyLimitConfig(config.GradientConcurrencyLimit))
+
+	for i := 0; i < 10; i++ {
+		runWindow(limiter, int(limiter.GetLimit()), 10*time.Millisecond)
+	}
+
+	assert.Greater(t, limiter.GetLimit(), int64(20))
+	assert.Equal(t, float64(limiter.GetLimit()), testutil.ToFloat64(concurrencyLimitMetric.WithLabelValues("ethereum", "gradient-grow")))
+}
+
+func TestConcurrencyLimiterGradientDropsWhenLatencyGrows(t *testing.T) {
+	limiter := NewConcurrencyLimiter(chains.ETHEREUM, "gradient-drop", concurrencyLimitConfig(config.GradientConcurrencyLimit))
+	runWindow(limiter, 20, 10*time.Millisecond)
+	limit := limiter.GetLimit()
+
+	for i := 0; i < 5; i++ {
+		runWindow(limiter, int(limiter.GetLimit()), 100*time.Millisecond)
+	}
+
+	assert.Less(t, limiter.GetLimit(), limit)
+}
+
+func TestConcurrencyLimiterIdleUpstreamKeepsLimit(t *testing.T) {
+	limiter := NewConcurrencyLimiter(chains.ETHEREUM, "idle", concurrencyLimitConfig(config.GradientConcurrencyLimit))
+
+	for i := 0; i < 10; i++ {
+		runWindow(limiter, 1, 10*time.Millisecond)
+	}
+
+	assert.Equal(t, int64(20), limiter.GetLimit())
+}
+
+func TestConcurrencyLimiterAimd(t *testing.T) {
+	limiter := NewConcurrencyLimiter(chains.ETHEREUM, "aimd", concurrencyLimitConfig(config.AimdConcurrencyLimit))
+
+	runWindow(limiter, 20, 10*time.Millisecond)
+	assert.Equal(t, int64(21), limiter.GetLimit())
+	runWindow(limiter, 20, 10*time.Millisecond)
+	assert.Equal(t, int64(22), limiter.GetLimit())
+
+	runWindow(limiter, 20, 100*time.Millisecond)
+	assert.Equal(t, int64(11), limiter.GetLimit())
+
+	for i := 0; i < 10; i++ {
+		runWindow(limiter, 20, time.Second)
+	}
+	assert.Equal(t, int64(2), limiter.GetLimit())
+}
+
+func TestConcurrencyLimiterWaitsForEnoughSamples(t *testing.T) {
+	cfg := concurrencyLimitConfig(config.AimdConcurrencyLimit)
+	limiter := NewConcurrencyLimiter(chains.ETHEREUM, "samples", cfg)
+
+	limiter.RequestStarted()
+	for i := 0; i < minWindowSamples-1; i++ {
+		limiter.ObserveLatency(time.Second)
+	}
+	assert.Equal(t, int64(20), limiter.GetLimit())
+}
+
+func TestConcurrencyLimiterDroppedRequestsCountAsSlowest(t *testing.T) {
+	limiter := NewConcurrencyLimiter(chains.ETHEREUM, "dropped", concurrencyLimitConfig(config.AimdConcurrencyLimit))
+	runWindow(limiter, 20, 10*time.Millisecond)
+	assert.Equal(t, int64(21), limiter.GetLimit())
+
+	for i := 0; i < 20; i++ {
+		limiter.RequestStarted()
+	}
+	limiter.ObserveLatency(100 * time.Millisecond)
+	// requests dropped early, e.g. hedges that lost, are as slow as the slowest request of the window
+	for i := 0; i < minWindowSamples-1; i++ {
+		limiter.ObserveDropped(time.Millisecond)
+	}
+
+	assert.Equal(t, int64(10), limiter.GetLimit())
+}
@@ -0,0 +1,253 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/storages"
+	"github.com/drpcorg/nodecore/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+const idleLimitersCleanupInterval = 1 * time.Minute
+
+type inboundKeyBudget struct {
+	budget *RateLimitBudget
+	config config.InboundRateLimitConfig
+}
+
+// InboundRateLimiter limits the requests clients send to nodecore per api key and per client ip,
+// unlike budgets which limit the requests nodecore sends to upstreams
+type InboundRateLimiter struct {
+	storageRegistry *storages.StorageRegistry
+	ipBudget        *RateLimitBudget
+
+	mu         sync.RWMutex
+	keyBudgets map[string]*inboundKeyBudget
+}
+
+func NewInboundRateLimiter(
+	ctx context.Context,
+	ipRateLimit *config.InboundRateLimitConfig,
+	authConfig *config.AuthConfig,
+	storageRegistry *storages.StorageRegistry,
+) (*InboundRateLimiter, error) {
+	limiter := &InboundRateLimiter{
+		storageRegistry: storageRegistry,
+		keyBudgets:      make(map[string]*inboundKeyBudget),
+	}
+	if ipRateLimit != nil {
+		ipBudget, err := newInboundBudget("ip", ipRateLimit, storageRegistry)
+		if err != nil {
+			return nil, err
+		}
+		limiter.ipBudget = ipBudget
+	}
+	if authConfig != nil && authConfig.Enabled {
+		if err := limiter.UpdateKeys(authConfig.KeyConfigs); err != nil {
+			return nil, err
+		}
+	}
+
+	go limiter.removeIdleLimiters(ctx)
+
+	return limiter, nil
+}
+
+// Allow checks the limits of the api key and of the client ip from the context, either might be absent.
+// The methods are the requests sent together (a batch), they are allowed or rejected as a whole,
+// so a rejected batch doesn't take the quota of its first requests.
+// It returns the quota of the most restrictive limit or nil if no limit is applied to the requests.
+// If a limit can't be checked, e.g. its redis storage is unavailable, the requests are allowed
+func (l *InboundRateLimiter) Allow(ctx context.Context, key string, methods []string) (bool, *RateLimitQuota) {
+	keyBudget := l.getKeyBudget(key)
+	ip := ""
+	if l.ipBudget != nil {
+		// only the ip the client can't spoof is counted, X-Forwarded-For of an untrusted peer would
+		// give the client a new counter on every request
+		ip = utils.ClientIpFromContext(ctx)
+	}
+
+	// with both limits, the requests are checked against both of them before either counts them,
+	// so the requests rejected by the ip limit don't take the quota of the key
+	if keyBudget != nil && ip != "" {
+		if allowed, quota := l.check(keyBudget, "", methods); !allowed {
+			return false, quota
+		}
+		if allowed, quota := l.check(l.ipBudget, ip, methods); !allowed {
+			return false, quota
+		}
+	}
+
+	var quota *RateLimitQuota
+	allowed := true
+	if keyBudget != nil {
+		allowed, quota = l.allow(keyBudget, "", methods)
+	}
+	if allowed && ip != "" {
+		var ipQuota *RateLimitQuota
+		allowed, ipQuota = l.allow(l.ipBudget, ip, methods)
+		quota = restrictiveQuota(quota, ipQuota)
+	}
+
+	return allowed, quota
+}
+
+// UpdateKeys replaces the limits of local keys, it's PrepareKeysUpdate followed by Apply
+func (l *InboundRateLimiter) UpdateKeys(keyCfgs []*config.KeyConfig) error {
+	update, err := l.PrepareKeysUpdate(keyCfgs)
+	if err != nil {
+		return err
+	}
+	update.Apply()
+	return nil
+}
+
+// InboundKeysUpdate is a new set of key limits ready to replace the current one
+type InboundKeysUpdate struct {
+	limiter    *InboundRateLimiter
+	keyBudgets map[string]*inboundKeyBudget
+}
+
+// PrepareKeysUpdate builds the limits of local keys without replacing the current ones,
+// the counters of the keys whose limits are unchanged are kept
+func (l *InboundRateLimiter) PrepareKeysUpdate(keyCfgs []*config.KeyConfig) (*InboundKeysUpdate, error) {
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	keyBudgets := make(map[string]*inboundKeyBudget)
+	for _, keyCfg := range keyCfgs {
+		if keyCfg.LocalKeyConfig == nil || keyCfg.LocalKeyConfig.KeySettingsConfig == nil {
+			continue
+		}
+		rateLimit := keyCfg.LocalKeyConfig.KeySettingsConfig.RateLimit
+		if rateLimit == nil {
+			continue
+		}
+		keyValue := keyCfg.LocalKeyConfig.Key
+		if current, ok := l.keyBudgets[keyValue]; ok && reflect.DeepEqual(current.config, *rateLimit) {
+			keyBudgets[keyValue] = current
+			continue
+		}
+		budget, err := newInboundBudget("key-"+keyCfg.Id, rateLimit, l.storageRegistry)
+		if err != nil {
+			return nil, err
+		}
+		keyBudgets[keyValue] = &inboundKeyBudget{
+			budget: budget,
+			config: *rateLimit,
+		}
+	}
+
+	return &InboundKeysUpdate{limiter: l, keyBudgets: keyBudgets}, nil
+}
+
+func (u *InboundKeysUpdate) Apply() {
+	u.limiter.mu.Lock()
+	defer u.limiter.mu.Unlock()
+
+	u.limiter.keyBudgets = u.keyBudgets
+}
+
+func (l *InboundRateLimiter) allow(budget *RateLimitBudget, subject string, methods []string) (bool, *RateLimitQuota) {
+	allowed, quota, err := budget.AllowBatchWithQuota(subject, methods)
+	if err != nil {
+		log.Error().Err(err).Msgf("couldn't check the rate limit %s, the request is allowed", budget.Name)
+		return true, nil
+	}
+	return allowed, quota
+}
+
+// check tells if the budget allows the requests without counting them, the rejected ones are
+// counted in the metrics as AllowBatchWithQuota would do
+func (l *InboundRateLimiter) check(budget *RateLimitBudget, subject string, methods []string) (bool, *RateLimitQuota) {
+	allowed, quota, err := budget.CheckBatchWithQuota(subject, methods)
+	if err != nil {
+		log.Error().Err(err).Msgf("couldn't check the rate limit %s, the request is allowed", budget.Name)
+		return true, nil
+	}
+	if !allowed {
+		for _, method := range methods {
+			rateLimitRequestMetrics.WithLabelValues(budget.Name, method).Inc()
+		}
+		budget.countExceeded(methods)
+	}
+	return allowed, quota
+}
+
+func (l *InboundRateLimiter) getKeyBudget(key string) *RateLimitBudget {
+	if key == "" {
+		return nil
+	}
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	keyBudget, ok := l.keyBudgets[key]
+	if !ok {
+		return nil
+	}
+	return keyBudget.budget
+}
+
+func (l *InboundRateLimiter) removeIdleLimiters(ctx context.Context) {
+	ticker := time.NewTicker(idleLimitersCleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			budgets := make([]*RateLimitBudget, 0)
+			if l.ipBudget != nil {
+				budgets = append(budgets, l.ipBudget)
+			}
+			l.mu.RLock()
+			for _, keyBudget := range l.keyBudgets {
+				budgets = append(budgets, keyBudget.budget)
+			}
+			l.mu.RUnlock()
+
+			for _, budget := range budgets {
+				if memoryEngine, ok := budget.Engine.(*RateLimitMemoryEngine); ok {
+					memoryEngine.RemoveIdle()
+				}
+			}
+		}
+	}
+}
+
+func newInboundBudget(name string, rateLimit *config.InboundRateLimitConfig, storageRegistry *storages.StorageRegistry) (*RateLimitBudget, error) {
+	engine, err := createEngine(storageRegistry, rateLimit.Storage)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create the %s rate limit, reason - %s", name, err.Error())
+	}
+	return NewRateLimitBudget(&config.RateLimitBudget{
+		Name:    name,
+		Storage: rateLimit.Storage,
+		Config:  &config.RateLimiterConfig{Rules: rateLimit.Rules},
+	}, engine), nil
+}
+
+func restrictiveQuota(first, second *RateLimitQuota) *RateLimitQuota {
+	if first == nil {
+		return second
+	}
+	if second == nil {
+		return first
+	}
+	if first.Allowed != second.Allowed {
+		if first.Allowed {
+			return second
+		}
+		return first
+	}
+	if second.Remaining < first.Remaining {
+		return second
+	}
+	return first
+}
@@ -0,0 +1,215 @@
+package ratelimiter_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/ratelimiter"
+	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/drpcorg/nodecore/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func inboundKeyConfig(id, key string, rateLimit *config.InboundRateLimitConfig) *config.KeyConfig {
+	return &config.KeyConfig{
+		Id:   id,
+		Type: config.Local,
+		LocalKeyConfig: &config.LocalKeyConfig{
+			Key:               key,
+			KeySettingsConfig: &config.KeySettingsConfig{RateLimit: rateLimit},
+		},
+	}
+}
+
+func inboundRateLimit(requests int, rules ...config.RateLimitRule) *config.InboundRateLimitConfig {
+	if len(rules) == 0 {
+		rules = []config.RateLimitRule{{Pattern: ".*", Requests: requests, Period: time.Minute}}
+	}
+	return &config.InboundRateLimitConfig{Rules: rules}
+}
+
+func TestInboundRateLimiterNoLimitsThenAllowed(t *testing.T) {
+	limiter, err := ratelimiter.NewInboundRateLimiter(context.Background(), nil, nil, nil)
+	require.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		allowed, quota := limiter.Allow(test_utils.CtxWithRemoteAddr("1.1.1.1:80"), "key", []string{"eth_call"})
+		assert.True(t, allowed)
+		assert.Nil(t, quota)
+	}
+}
+
+func TestInboundRateLimiterKeyLimit(t *testing.T) {
+	authConfig := &config.AuthConfig{
+		Enabled: true,
+		KeyConfigs: []*config.KeyConfig{
+			inboundKeyConfig("id1", "key1", inboundRateLimit(2)),
+			inboundKeyConfig("id2", "key2", nil),
+		},
+	}
+	limiter, err := ratelimiter.NewInboundRateLimiter(context.Background(), nil, authConfig, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	allowed, quota := limiter.Allow(ctx, "key1", []string{"eth_call"})
+	assert.True(t, allowed)
+	assert.Equal(t, &ratelimiter.RateLimitQuota{Allowed: true, Limit: 2, Remaining: 1, Reset: quota.Reset}, quota)
+
+	allowed, _ = limiter.Allow(ctx, "key1", []string{"eth_call"})
+	assert.True(t, allowed)
+
+	allowed, quota = limiter.Allow(ctx, "key1", []string{"eth_getBalance"})
+	assert.False(t, allowed)
+	assert.Equal(t, 0, quota.Remaining)
+	assert.Positive(t, quota.Reset)
+
+	for i := 0; i < 5; i++ {
+		allowed, quota = limiter.Allow(ctx, "key2", []string{"eth_call"})
+		assert.True(t, allowed)
+		assert.Nil(t, quota)
+	}
+}
+
+func TestInboundRateLimiterMethodRules(t *testing.T) {
+	authConfig := &config.AuthConfig{
+		Enabled: true,
+		KeyConfigs: []*config.KeyConfig{
+			inboundKeyConfig("id1", "key1", inboundRateLimit(
+				0,
+				config.RateLimitRule{Method: "eth_call", Requests: 1, Period: time.Minute},
+				config.RateLimitRule{Pattern: "eth_get.*", Requests: 5, Period: time.Minute},
+			)),
+		},
+	}
+	limiter, err := ratelimiter.NewInboundRateLimiter(context.Background(), nil, authConfig, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	allowed, _ := limiter.Allow(ctx, "key1", []string{"eth_call"})
+	assert.True(t, allowed)
+	allowed, _ = limiter.Allow(ctx, "key1", []string{"eth_call"})
+	assert.False(t, allowed)
+
+	allowed, quota := limiter.Allow(ctx, "key1", []string{"eth_getBalance"})
+	assert.True(t, allowed)
+	assert.Equal(t, 5, quota.Limit)
+	assert.Equal(t, 4, quota.Remaining)
+
+	allowed, quota = limiter.Allow(ctx, "key1", []string{"eth_chainId"})
+	assert.True(t, allowed)
+	assert.Nil(t, quota)
+}
+
+func TestInboundRateLimiterIpLimit(t *testing.T) {
+	limiter, err := ratelimiter.NewInboundRateLimiter(context.Background(), inboundRateLimit(1), nil, nil)
+	require.NoError(t, err)
+
+	allowed, _ := limiter.Allow(test_utils.CtxWithXFF("1.1.1.1"), "", []string{"eth_call"})
+	assert.True(t, allowed)
+	allowed, quota := limiter.Allow(test_utils.CtxWithXFF("1.1.1.1"), "", []string{"eth_call"})
+	assert.False(t, allowed)
+	assert.False(t, quota.Allowed)
+
+	allowed, _ = limiter.Allow(test_utils.CtxWithXFF("2.2.2.2"), "", []string{"eth_call"})
+	assert.True(t, allowed)
+}
+
+func TestInboundRateLimiterIpLimitIgnoresXffOfUntrustedPeer(t *testing.T) {
+	limiter, err := ratelimiter.NewInboundRateLimiter(context.Background(), inboundRateLimit(1), nil, nil)
+	require.NoError(t, err)
+	ctxWithXff := func(xff string) context.Context {
+		req, _ := http.NewRequest(http.MethodGet, "http://example.com", nil)
+		req.RemoteAddr = "1.1.1.1:80"
+		req.Header.Set("X-Forwarded-For", xff)
+		return utils.ContextWithIps(context.Background(), req, nil)
+	}
+
+	allowed, _ := limiter.Allow(ctxWithXff("3.3.3.3"), "", []string{"eth_call"})
+	assert.True(t, allowed)
+	// a rotated X-Forwarded-For doesn't give the same peer a new counter
+	allowed, _ = limiter.Allow(ctxWithXff("4.4.4.4"), "", []string{"eth_call"})
+	assert.False(t, allowed)
+}
+
+func TestInboundRateLimiterRejectedBatchThenQuotaNotTaken(t *testing.T) {
+	authConfig := &config.AuthConfig{
+		Enabled:    true,
+		KeyConfigs: []*config.KeyConfig{inboundKeyConfig("id1", "key1", inboundRateLimit(2))},
+	}
+	limiter, err := ratelimiter.NewInboundRateLimiter(context.Background(), nil, authConfig, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	allowed, quota := limiter.Allow(ctx, "key1", []string{"eth_call", "eth_call", "eth_getBalance"})
+	assert.False(t, allowed)
+	assert.Equal(t, 2, quota.Remaining)
+
+	allowed, quota = limiter.Allow(ctx, "key1", []string{"eth_call", "eth_getBalance"})
+	assert.True(t, allowed)
+	assert.Equal(t, 0, quota.Remaining)
+}
+
+func TestInboundRateLimiterKeyAndIpThenMostRestrictiveQuota(t *testing.T) {
+	authConfig := &config.AuthConfig{
+		Enabled:    true,
+		KeyConfigs: []*config.KeyConfig{inboundKeyConfig("id1", "key1", inboundRateLimit(10))},
+	}
+	limiter, err := ratelimiter.NewInboundRateLimiter(context.Background(), inboundRateLimit(3), authConfig, nil)
+	require.NoError(t, err)
+	ctx := test_utils.CtxWithRemoteAddr("1.1.1.1:80")
+
+	allowed, quota := limiter.Allow(ctx, "key1", []string{"eth_call"})
+	assert.True(t, allowed)
+	assert.Equal(t, 3, quota.Limit)
+	assert.Equal(t, 2, quota.Remaining)
+}
+
+func TestInboundRateLimiterRejectedByIpThenKeyQuotaNotTaken(t *testing.T) {
+	authConfig := &config.AuthConfig{
+		Enabled:    true,
+		KeyConfigs: []*config.KeyConfig{inboundKeyConfig("id1", "key1", inboundRateLimit(2))},
+	}
+	limiter, err := ratelimiter.NewInboundRateLimiter(context.Background(), inboundRateLimit(1), authConfig, nil)
+	require.NoError(t, err)
+
+	allowed, _ := limiter.Allow(test_utils.CtxWithRemoteAddr("1.1.1.1:80"), "key1", []string{"eth_call"})
+	assert.True(t, allowed)
+	allowed, quota := limiter.Allow(test_utils.CtxWithRemoteAddr("1.1.1.1:80"), "key1", []string{"eth_call"})
+	assert.False(t, allowed)
+	assert.Equal(t, 1, quota.Limit)
+
+	// the request rejected by the ip limit hasn't taken the last request of the key
+	allowed, quota = limiter.Allow(test_utils.CtxWithRemoteAddr("2.2.2.2:80"), "key1", []string{"eth_call"})
+	assert.True(t, allowed)
+	assert.Equal(t, 0, quota.Remaining)
+}
+
+func TestInboundRateLimiterUpdateKeysKeepsUnchangedCounters(t *testing.T) {
+	keyConfigs := []*config.KeyConfig{
+		inboundKeyConfig("id1", "key1", inboundRateLimit(1)),
+		inboundKeyConfig("id2", "key2", inboundRateLimit(1)),
+	}
+	limiter, err := ratelimiter.NewInboundRateLimiter(context.Background(), nil, &config.AuthConfig{Enabled: true, KeyConfigs: keyConfigs}, nil)
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	allowed, _ := limiter.Allow(ctx, "key1", []string{"eth_call"})
+	assert.True(t, allowed)
+	allowed, _ = limiter.Allow(ctx, "key2", []string{"eth_call"})
+	assert.True(t, allowed)
+
+	err = limiter.UpdateKeys([]*config.KeyConfig{
+		inboundKeyConfig("id1", "key1", inboundRateLimit(1)),
+		inboundKeyConfig("id2", "key2", inboundRateLimit(2)),
+	})
+	require.NoError(t, err)
+
+	allowed, _ = limiter.Allow(ctx, "key1", []string{"eth_call"})
+	assert.False(t, allowed)
+	allowed, _ = limiter.Allow(ctx, "key2", []string{"eth_call"})
+	assert.True(t, allowed)
+}
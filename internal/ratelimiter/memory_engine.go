@@ -1,36 +1,104 @@
 package ratelimiter
 
 import (
+	"time"
+
 	"github.com/drpcorg/nodecore/pkg/utils"
 	"github.com/juju/ratelimit"
 )
 
+type memoryLimiter struct {
+	bucket    *ratelimit.Bucket
+	period    time.Duration
+	createdAt time.Time
+}
+
+// resetIn returns the time until the bucket is refilled, the bucket adds its tokens
+// at the end of every period since it was created
+func (l *memoryLimiter) resetIn(now time.Time) time.Duration {
+	return l.period - now.Sub(l.createdAt)%l.period
+}
+
+func (l *memoryLimiter) quota(tp *FixedRateLimiterType, allowed bool) RateLimitQuota {
+	return RateLimitQuota{
+		Allowed:   allowed,
+		Limit:     tp.requests,
+		Remaining: int(max(l.bucket.Available(), 0)),
+		Reset:     l.resetIn(time.Now()),
+	}
+}
+
 type RateLimitMemoryEngine struct {
-	limiters utils.CMap[string, *ratelimit.Bucket]
+	limiters utils.CMap[string, *memoryLimiter]
 }
 
 func NewRateLimitMemoryEngine() *RateLimitMemoryEngine {
 	return &RateLimitMemoryEngine{
-		limiters: utils.CMap[string, *ratelimit.Bucket]{},
+		limiters: utils.CMap[string, *memoryLimiter]{},
 	}
 }
 
 func (e *RateLimitMemoryEngine) Execute(cmd []RateLimitCommand) (bool, error) {
-	result := true
-	for _, cmd := range cmd {
-		limiter, ok := e.limiters.Load(cmd.Name)
+	quotas, err := e.ExecuteWithQuotas(cmd)
+	if err != nil {
+		return false, err
+	}
+	return allQuotasAllowed(quotas), nil
+}
 
+func (e *RateLimitMemoryEngine) ExecuteWithQuotas(cmd []RateLimitCommand) ([]RateLimitQuota, error) {
+	quotas, _ := e.CheckWithQuotas(cmd)
+	// the requests are counted only if every command has room for them
+	if !allQuotasAllowed(quotas) {
+		return quotas, nil
+	}
+	quotas = quotas[:0]
+	for _, cmd := range cmd {
+		tp, ok := cmd.Type.(*FixedRateLimiterType)
 		if !ok {
-			var newLimiter *ratelimit.Bucket
-			switch tp := cmd.Type.(type) {
-			case *FixedRateLimiterType:
-				newLimiter = ratelimit.NewBucketWithQuantum(tp.period, int64(tp.requests), int64(tp.requests))
-			}
+			continue
+		}
+		limiter := e.limiter(cmd.Name, tp)
+
+		// the requests of a command are taken all together or not at all
+		_, allowed := limiter.bucket.TakeMaxDuration(int64(cmd.count()), 0)
+		quotas = append(quotas, limiter.quota(tp, allowed))
+	}
+	return quotas, nil
+}
 
-			limiter, _ = e.limiters.LoadOrStore(cmd.Name, newLimiter)
+func (e *RateLimitMemoryEngine) CheckWithQuotas(cmd []RateLimitCommand) ([]RateLimitQuota, error) {
+	quotas := make([]RateLimitQuota, 0, len(cmd))
+	for _, cmd := range cmd {
+		tp, ok := cmd.Type.(*FixedRateLimiterType)
+		if !ok {
+			continue
 		}
+		limiter := e.limiter(cmd.Name, tp)
+		quotas = append(quotas, limiter.quota(tp, limiter.bucket.Available() >= int64(cmd.count())))
+	}
+	return quotas, nil
+}
 
-		result = result && limiter.TakeAvailable(1) > 0
+func (e *RateLimitMemoryEngine) limiter(name string, tp *FixedRateLimiterType) *memoryLimiter {
+	limiter, ok := e.limiters.Load(name)
+	if !ok {
+		limiter, _ = e.limiters.LoadOrStore(name, &memoryLimiter{
+			bucket:    ratelimit.NewBucketWithQuantum(tp.period, int64(tp.requests), int64(tp.requests)),
+			period:    tp.period,
+			createdAt: time.Now(),
+		})
 	}
-	return result, nil
+	return limiter
+}
+
+// RemoveIdle removes the limiters whose buckets are full, such a limiter is the same
+// as a new one, so the memory held by limiters of rarely seen names (e.g. client ips) is released
+func (e *RateLimitMemoryEngine) RemoveIdle() {
+	e.limiters.Range(func(name string, limiter *memoryLimiter) bool {
+		if limiter.bucket.Available() >= limiter.bucket.Capacity() {
+			e.limiters.CompareAndDelete(name, limiter)
+		}
+		return true
+	})
 }
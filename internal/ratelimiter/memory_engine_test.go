@@ -141,3 +141,66 @@ func TestRateLimitMemoryEngine_Execute_RateLimitRecovery(t *testing.T) {
 	require.NoError(t, err)
 	assert.True(t, result)
 }
+
+func TestRateLimitMemoryEngine_ExecuteWithQuotas(t *testing.T) {
+	engine := NewRateLimitMemoryEngine()
+	cmd := RateLimitCommand{
+		Name: "test-limiter",
+		Type: &FixedRateLimiterType{
+			requests: 2,
+			period:   time.Minute,
+		},
+	}
+
+	quotas, err := engine.ExecuteWithQuotas([]RateLimitCommand{cmd})
+	require.NoError(t, err)
+	require.Len(t, quotas, 1)
+	assert.True(t, quotas[0].Allowed)
+	assert.Equal(t, 2, quotas[0].Limit)
+	assert.Equal(t, 1, quotas[0].Remaining)
+	assert.True(t, quotas[0].Reset > 0 && quotas[0].Reset <= time.Minute)
+
+	_, _ = engine.ExecuteWithQuotas([]RateLimitCommand{cmd})
+	quotas, err = engine.ExecuteWithQuotas([]RateLimitCommand{cmd})
+	require.NoError(t, err)
+	assert.False(t, quotas[0].Allowed)
+	assert.Equal(t, 0, quotas[0].Remaining)
+}
+
+func TestRateLimitMemoryEngine_RemoveIdle(t *testing.T) {
+	engine := NewRateLimitMemoryEngine()
+	cmd := RateLimitCommand{
+		Name: "test-limiter",
+		Type: &FixedRateLimiterType{
+			requests: 1,
+			period:   50 * time.Millisecond,
+		},
+	}
+
+	_, err := engine.Execute([]RateLimitCommand{cmd})
+	require.NoError(t, err)
+
+	engine.RemoveIdle()
+	_, ok := engine.limiters.Load(cmd.Name)
+	assert.True(t, ok)
+
+	time.Sleep(60 * time.Millisecond)
+	engine.RemoveIdle()
+	_, ok = engine.limiters.Load(cmd.Name)
+	assert.False(t, ok)
+}
+
+func TestRateLimitMemoryEngine_ExecuteWithQuotasRejectedThenNothingTaken(t *testing.T) {
+	engine := NewRateLimitMemoryEngine()
+	first := RateLimitCommand{Name: "first", Type: NewFixedRateLimiterType(5, time.Minute)}
+	second := RateLimitCommand{Name: "second", Type: NewFixedRateLimiterType(1, time.Minute), Count: 2}
+
+	quotas, err := engine.ExecuteWithQuotas([]RateLimitCommand{first, second})
+	assert.NoError(t, err)
+	assert.True(t, quotas[0].Allowed)
+	assert.False(t, quotas[1].Allowed)
+
+	quotas, err = engine.CheckWithQuotas([]RateLimitCommand{first})
+	assert.NoError(t, err)
+	assert.Equal(t, 5, quotas[0].Remaining)
+}
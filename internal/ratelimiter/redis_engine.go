@@ -2,10 +2,47 @@ package ratelimiter
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+// rateLimitScript checks and counts the requests of all the commands at once, they are counted only if every
+// counter has room for them, so rejected requests aren't counted.
+// ARGV[1] is 1 to count the requests or 0 to only check them, then every key has its requests, limit and period in ms.
+// A counter expires a period after it's created, it returns the allowed flag, the counter and its ttl in ms of every key
+var rateLimitScript = redis.NewScript(`
+local count = ARGV[1] == '1'
+local counters = {}
+local fits = true
+for i, key in ipairs(KEYS) do
+	counters[i] = tonumber(redis.call('GET', key) or '0')
+	if counters[i] + tonumber(ARGV[3 * i - 1]) > tonumber(ARGV[3 * i]) then
+		fits = false
+	end
+end
+local result = {}
+for i, key in ipairs(KEYS) do
+	local requests = tonumber(ARGV[3 * i - 1])
+	local allowed = counters[i] + requests <= tonumber(ARGV[3 * i])
+	if count and fits then
+		counters[i] = redis.call('INCRBY', key, requests)
+	end
+	local ttl = redis.call('PTTL', key)
+	if ttl == -1 then
+		redis.call('PEXPIRE', key, ARGV[3 * i + 1])
+		ttl = tonumber(ARGV[3 * i + 1])
+	elseif ttl == -2 then
+		ttl = tonumber(ARGV[3 * i + 1])
+	end
+	table.insert(result, allowed and 1 or 0)
+	table.insert(result, counters[i])
+	table.insert(result, ttl)
+end
+return result
+`)
+
 type RateLimitRedisEngine struct {
 	name  string
 	redis *redis.Client
@@ -19,40 +56,59 @@ func NewRateLimitRedisEngine(name string, redis *redis.Client) *RateLimitRedisEn
 }
 
 func (e *RateLimitRedisEngine) Execute(cmd []RateLimitCommand) (bool, error) {
-	ctx := context.Background()
+	quotas, err := e.ExecuteWithQuotas(cmd)
+	if err != nil {
+		return false, err
+	}
+	return allQuotasAllowed(quotas), nil
+}
 
-	pipe := e.redis.Pipeline()
-	cmds := make([]*redis.IntCmd, 0, len(cmd))
+func (e *RateLimitRedisEngine) ExecuteWithQuotas(cmd []RateLimitCommand) ([]RateLimitQuota, error) {
+	return e.run(cmd, true)
+}
+
+func (e *RateLimitRedisEngine) CheckWithQuotas(cmd []RateLimitCommand) ([]RateLimitQuota, error) {
+	return e.run(cmd, false)
+}
 
+func (e *RateLimitRedisEngine) run(cmd []RateLimitCommand, count bool) ([]RateLimitQuota, error) {
+	types := make([]*FixedRateLimiterType, 0, len(cmd))
+	keys := make([]string, 0, len(cmd))
+	args := []any{0}
+	if count {
+		args[0] = 1
+	}
 	for _, c := range cmd {
 		tp, ok := c.Type.(*FixedRateLimiterType)
 		if !ok {
 			continue
 		}
-
-		redisKey := "ratelimit:" + e.name + ":" + c.Name
-		incrCmd := pipe.Incr(ctx, redisKey)
-		cmds = append(cmds, incrCmd)
-		pipe.Expire(ctx, redisKey, tp.period)
+		types = append(types, tp)
+		keys = append(keys, "ratelimit:"+e.name+":"+c.Name)
+		args = append(args, c.count(), tp.requests, tp.period.Milliseconds())
+	}
+	if len(keys) == 0 {
+		return []RateLimitQuota{}, nil
 	}
 
-	_, err := pipe.Exec(ctx)
+	values, err := rateLimitScript.Run(context.Background(), e.redis, keys, args...).Int64Slice()
 	if err != nil {
-		return false, err
+		return nil, err
+	}
+	if len(values) != 3*len(types) {
+		return nil, fmt.Errorf("unexpected rate limit script result of %d values for %d keys", len(values), len(types))
 	}
 
-	result := true
-	for i, c := range cmd {
-		tp, ok := c.Type.(*FixedRateLimiterType)
-		if !ok {
-			continue
-		}
-
-		count := cmds[i].Val()
-		if int(count) > tp.requests {
-			result = false
-		}
+	quotas := make([]RateLimitQuota, 0, len(types))
+	for i, tp := range types {
+		counter := int(values[3*i+1])
+		quotas = append(quotas, RateLimitQuota{
+			Allowed:   values[3*i] == 1,
+			Limit:     tp.requests,
+			Remaining: max(tp.requests-counter, 0),
+			Reset:     time.Duration(values[3*i+2]) * time.Millisecond,
+		})
 	}
 
-	return result, nil
+	return quotas, nil
 }
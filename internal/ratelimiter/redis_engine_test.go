@@ -10,14 +10,18 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// rateLimitScriptHash is the sha1 of the script the engine runs to check and count the requests
+const rateLimitScriptHash = "cdaaf4fc4d4a81cf2ce80ad41c8f88f73db07eaf"
+
 func TestRedisEngine_Execute_AllowsRequestsUnderLimit(t *testing.T) {
 	db, mock := redismock.NewClientMock()
 	engine := ratelimiter.NewRateLimitRedisEngine("test", db)
 
-	mock.ExpectIncr("ratelimit:test:budget1").SetVal(1)
-	mock.ExpectExpire("ratelimit:test:budget1", 1*time.Minute).SetVal(true)
-	mock.ExpectIncr("ratelimit:test:budget2").SetVal(2)
-	mock.ExpectExpire("ratelimit:test:budget2", 1*time.Minute).SetVal(true)
+	mock.ExpectEvalSha(
+		rateLimitScriptHash,
+		[]string{"ratelimit:test:budget1", "ratelimit:test:budget2"},
+		1, 1, 10, int64(60000), 1, 10, int64(60000),
+	).SetVal([]any{int64(1), int64(1), int64(60000), int64(1), int64(2), int64(59000)})
 
 	commands := []ratelimiter.RateLimitCommand{
 		{
@@ -41,8 +45,8 @@ func TestRedisEngine_Execute_DeniesRequestsOverLimit(t *testing.T) {
 	db, mock := redismock.NewClientMock()
 	engine := ratelimiter.NewRateLimitRedisEngine("test", db)
 
-	mock.ExpectIncr("ratelimit:test:budget1").SetVal(11)
-	mock.ExpectExpire("ratelimit:test:budget1", 1*time.Minute).SetVal(true)
+	mock.ExpectEvalSha(rateLimitScriptHash, []string{"ratelimit:test:budget1"}, 1, 1, 10, int64(60000)).
+		SetVal([]any{int64(0), int64(10), int64(30000)})
 
 	commands := []ratelimiter.RateLimitCommand{
 		{
@@ -62,10 +66,11 @@ func TestRedisEngine_Execute_DeniesIfAnyBudgetExceeded(t *testing.T) {
 	db, mock := redismock.NewClientMock()
 	engine := ratelimiter.NewRateLimitRedisEngine("test", db)
 
-	mock.ExpectIncr("ratelimit:test:budget1").SetVal(5)
-	mock.ExpectExpire("ratelimit:test:budget1", 1*time.Minute).SetVal(true)
-	mock.ExpectIncr("ratelimit:test:budget2").SetVal(15)
-	mock.ExpectExpire("ratelimit:test:budget2", 1*time.Minute).SetVal(true)
+	mock.ExpectEvalSha(
+		rateLimitScriptHash,
+		[]string{"ratelimit:test:budget1", "ratelimit:test:budget2"},
+		1, 1, 10, int64(60000), 1, 10, int64(60000),
+	).SetVal([]any{int64(1), int64(4), int64(60000), int64(0), int64(10), int64(60000)})
 
 	commands := []ratelimiter.RateLimitCommand{
 		{
@@ -89,7 +94,7 @@ func TestRedisEngine_Execute_HandlesRedisError(t *testing.T) {
 	db, mock := redismock.NewClientMock()
 	engine := ratelimiter.NewRateLimitRedisEngine("test", db)
 
-	mock.ExpectIncr("ratelimit:test:budget1").SetErr(redis.ErrClosed)
+	mock.ExpectEvalSha(rateLimitScriptHash, []string{"ratelimit:test:budget1"}, 1, 1, 10, int64(60000)).SetErr(redis.ErrClosed)
 
 	commands := []ratelimiter.RateLimitCommand{
 		{
@@ -115,3 +120,75 @@ func TestRedisEngine_Execute_EmptyCommands(t *testing.T) {
 	assert.NoError(t, err)
 	assert.True(t, allowed)
 }
+
+func TestRedisEngine_ExecuteWithQuotas(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	engine := ratelimiter.NewRateLimitRedisEngine("test", db)
+
+	// the second counter is full, so the script counts none of the requests and returns the counters as they are
+	mock.ExpectEvalSha(
+		rateLimitScriptHash,
+		[]string{"ratelimit:test:budget1", "ratelimit:test:budget2"},
+		1, 1, 10, int64(60000), 1, 10, int64(1000),
+	).SetVal([]any{int64(1), int64(2), int64(45000), int64(0), int64(10), int64(500)})
+
+	commands := []ratelimiter.RateLimitCommand{
+		{
+			Name: "budget1",
+			Type: ratelimiter.NewFixedRateLimiterType(10, 1*time.Minute),
+		},
+		{
+			Name: "budget2",
+			Type: ratelimiter.NewFixedRateLimiterType(10, 1*time.Second),
+		},
+	}
+
+	quotas, err := engine.ExecuteWithQuotas(commands)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ratelimiter.RateLimitQuota{
+		{Allowed: true, Limit: 10, Remaining: 8, Reset: 45 * time.Second},
+		{Allowed: false, Limit: 10, Remaining: 0, Reset: 500 * time.Millisecond},
+	}, quotas)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisEngine_ExecuteWithQuotas_CountsRequestsAtOnce(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	engine := ratelimiter.NewRateLimitRedisEngine("test", db)
+
+	mock.ExpectEvalSha(rateLimitScriptHash, []string{"ratelimit:test:budget1"}, 1, 3, 10, int64(60000)).
+		SetVal([]any{int64(1), int64(3), int64(60000)})
+
+	quotas, err := engine.ExecuteWithQuotas([]ratelimiter.RateLimitCommand{
+		{
+			Name:  "budget1",
+			Type:  ratelimiter.NewFixedRateLimiterType(10, 1*time.Minute),
+			Count: 3,
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ratelimiter.RateLimitQuota{{Allowed: true, Limit: 10, Remaining: 7, Reset: 1 * time.Minute}}, quotas)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedisEngine_CheckWithQuotasThenRequestsNotCounted(t *testing.T) {
+	db, mock := redismock.NewClientMock()
+	engine := ratelimiter.NewRateLimitRedisEngine("test", db)
+
+	mock.ExpectEvalSha(rateLimitScriptHash, []string{"ratelimit:test:budget1"}, 0, 2, 10, int64(60000)).
+		SetVal([]any{int64(1), int64(5), int64(20000)})
+
+	quotas, err := engine.CheckWithQuotas([]ratelimiter.RateLimitCommand{
+		{
+			Name:  "budget1",
+			Type:  ratelimiter.NewFixedRateLimiterType(10, 1*time.Minute),
+			Count: 2,
+		},
+	})
+
+	assert.NoError(t, err)
+	assert.Equal(t, []ratelimiter.RateLimitQuota{{Allowed: true, Limit: 10, Remaining: 5, Reset: 20 * time.Second}}, quotas)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -27,11 +27,38 @@ func (r *FixedRateLimiterType) tp() bool {
 
 type RateLimitEngine interface {
 	Execute([]RateLimitCommand) (bool, error)
+	// ExecuteWithQuotas does the same as Execute, but returns the quota state of every command
+	ExecuteWithQuotas([]RateLimitCommand) ([]RateLimitQuota, error)
+	// CheckWithQuotas returns the quotas the commands would get without counting their requests
+	CheckWithQuotas([]RateLimitCommand) ([]RateLimitQuota, error)
+}
+
+// RateLimitQuota is the state of a limiter after a request has been counted
+type RateLimitQuota struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration
+}
+
+func allQuotasAllowed(quotas []RateLimitQuota) bool {
+	for _, quota := range quotas {
+		if !quota.Allowed {
+			return false
+		}
+	}
+	return true
 }
 
 type RateLimitCommand struct {
 	Type RateLimiterType
 	Name string
+	// Count is the number of requests the command counts at once, 0 is the same as 1
+	Count int
+}
+
+func (c RateLimitCommand) count() int {
+	return max(c.Count, 1)
 }
 
 type ruleCheck interface {
@@ -13,6 +13,7 @@ import (
 	"github.com/drpcorg/nodecore/pkg/utils"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
 	"github.com/samber/lo/mutable"
 	"github.com/spf13/cast"
 )
@@ -36,13 +37,22 @@ func init() {
 // skipped, but a fixed gauge value keeps the metric series alive for dashboards.
 const singleUpstreamRating = 1
 
+// UpstreamMetrics are the metrics of an upstream the rating was calculated with
+type UpstreamMetrics struct {
+	LatencyP90 float64 // seconds
+	HeadLag    uint64
+}
+
 type RatingRegistry struct {
 	upstreamSupervisor  upstreams.UpstreamSupervisor
 	tracker             dimensions.DimensionTracker
 	calculationInterval time.Duration
+	costTieTolerance    float64
 	scoreFunc           goja.Callable
 	runtime             *goja.Runtime
 	sortedUpstreams     *utils.Atomic[*utils.CMap[chains.Chain, *utils.CMap[string, []string]]]
+	scores              *utils.Atomic[*utils.CMap[chains.Chain, *utils.CMap[string, map[string]float64]]]
+	metrics             *utils.Atomic[*utils.CMap[chains.Chain, *utils.CMap[string, map[string]UpstreamMetrics]]]
 }
 
 func NewRatingRegistry(
@@ -53,6 +63,10 @@ func NewRatingRegistry(
 	scoreFunc, _ := scorePolicyConfig.GetScoreFunc()
 	sortedUpstreams := utils.NewAtomic[*utils.CMap[chains.Chain, *utils.CMap[string, []string]]]()
 	sortedUpstreams.Store(utils.NewCMap[chains.Chain, *utils.CMap[string, []string]]())
+	scores := utils.NewAtomic[*utils.CMap[chains.Chain, *utils.CMap[string, map[string]float64]]]()
+	scores.Store(utils.NewCMap[chains.Chain, *utils.CMap[string, map[string]float64]]())
+	metrics := utils.NewAtomic[*utils.CMap[chains.Chain, *utils.CMap[string, map[string]UpstreamMetrics]]]()
+	metrics.Store(utils.NewCMap[chains.Chain, *utils.CMap[string, map[string]UpstreamMetrics]]())
 
 	return &RatingRegistry{
 		scoreFunc:           scoreFunc,
@@ -60,7 +74,10 @@ func NewRatingRegistry(
 		tracker:             tracker,
 		runtime:             goja.New(),
 		calculationInterval: scorePolicyConfig.CalculationInterval,
+		costTieTolerance:    lo.FromPtr(scorePolicyConfig.CostTieTolerance),
 		sortedUpstreams:     sortedUpstreams,
+		scores:              scores,
+		metrics:             metrics,
 	}
 }
 
@@ -77,6 +94,32 @@ func (r *RatingRegistry) GetSortedUpstreams(chain chains.Chain, method string) [
 	return ups
 }
 
+// GetScores returns the scores of upstreams calculated by the score function, nil if they haven't been calculated,
+// e.g. for a chain with a single upstream
+func (r *RatingRegistry) GetScores(chain chains.Chain, method string) map[string]float64 {
+	methods, ok := r.scores.Load().Load(chain)
+	if !ok {
+		return nil
+	}
+	scores, _ := methods.Load(method)
+	return scores
+}
+
+// CostTieTolerance is how much the scores of upstreams may differ, relative to the higher one, for them to be tied
+func (r *RatingRegistry) CostTieTolerance() float64 {
+	return r.costTieTolerance
+}
+
+// GetMetrics returns the metrics of upstreams the rating was calculated with, nil if it hasn't been calculated
+func (r *RatingRegistry) GetMetrics(chain chains.Chain, method string) map[string]UpstreamMetrics {
+	methods, ok := r.metrics.Load().Load(chain)
+	if !ok {
+		return nil
+	}
+	metrics, _ := methods.Load(method)
+	return metrics
+}
+
 func (r *RatingRegistry) Start() {
 	log.Info().Msgf("rating will be calculated every %s", r.calculationInterval)
 	for {
@@ -96,6 +139,8 @@ func (r *RatingRegistry) getShuffledUpstreamIds(chain chains.Chain) []string {
 
 func (r *RatingRegistry) calculateRating() {
 	newSortedUpstreams := utils.NewCMap[chains.Chain, *utils.CMap[string, []string]]()
+	newScores := utils.NewCMap[chains.Chain, *utils.CMap[string, map[string]float64]]()
+	newMetrics := utils.NewCMap[chains.Chain, *utils.CMap[string, map[string]UpstreamMetrics]]()
 
 	for _, chSupervisor := range r.upstreamSupervisor.GetChainSupervisors() {
 		upstreamIds := chSupervisor.GetUpstreamIds()
@@ -116,12 +161,23 @@ func (r *RatingRegistry) calculateRating() {
 		}
 
 		methodUpstreams := utils.NewCMap[string, []string]()
+		methodScores := utils.NewCMap[string, map[string]float64]()
+		methodMetrics := utils.NewCMap[string, map[string]UpstreamMetrics]()
 		for _, method := range methods {
 			upDataArr := make([]map[string]interface{}, 0, len(upstreamIds))
+			upstreamMetrics := make(map[string]UpstreamMetrics, len(upstreamIds))
 
 			for _, upstreamId := range upstreamIds {
 				dims := r.tracker.GetAllDimensions(chSupervisor.GetChain(), upstreamId, method)
-				upDataArr = append(upDataArr, getUpstreamData(upstreamId, method, dims))
+				requestCost := 0.0
+				if state := chSupervisor.GetUpstreamState(upstreamId); state != nil {
+					requestCost = state.Cost.RequestCost(method)
+				}
+				upDataArr = append(upDataArr, getUpstreamData(upstreamId, method, requestCost, dims))
+				upstreamMetrics[upstreamId] = UpstreamMetrics{
+					LatencyP90: dims.UpstreamDimensions.GetValueAtQuantile(0.9),
+					HeadLag:    dims.ChainDimensions.GetHeadLag(),
+				}
 			}
 
 			resultValue, err := r.scoreFunc(nil, r.runtime.ToValue(upDataArr)) // can't be executed in parallel due to a limitation of the goja lib
@@ -150,7 +206,7 @@ func (r *RatingRegistry) calculateRating() {
 				log.Error().Msg("there must be 'scores' field in the return value from the score function")
 				return
 			}
-			err = r.processScores(scoresAsObjects, chSupervisor.GetChain(), method)
+			upstreamScores, err := r.processScores(scoresAsObjects, chSupervisor.GetChain(), method)
 			if err != nil {
 				log.Error().Msg(err.Error())
 				return
@@ -167,23 +223,30 @@ func (r *RatingRegistry) calculateRating() {
 			}
 
 			methodUpstreams.Store(method, sortedUpstreams)
+			methodScores.Store(method, upstreamScores)
+			methodMetrics.Store(method, upstreamMetrics)
 		}
 
 		newSortedUpstreams.Store(chSupervisor.GetChain(), methodUpstreams)
+		newScores.Store(chSupervisor.GetChain(), methodScores)
+		newMetrics.Store(chSupervisor.GetChain(), methodMetrics)
 	}
 
 	r.sortedUpstreams.Store(newSortedUpstreams)
+	r.scores.Store(newScores)
+	r.metrics.Store(newMetrics)
 }
 
-func (r *RatingRegistry) processScores(scoresAsObjects interface{}, chain chains.Chain, method string) error {
+func (r *RatingRegistry) processScores(scoresAsObjects interface{}, chain chains.Chain, method string) (map[string]float64, error) {
 	scoresAsArray, ok := scoresAsObjects.([]interface{})
 	if !ok {
-		return fmt.Errorf("unexpected return value %s from the score function, 'scores' must be an array", reflect.TypeOf(scoresAsObjects))
+		return nil, fmt.Errorf("unexpected return value %s from the score function, 'scores' must be an array", reflect.TypeOf(scoresAsObjects))
 	}
+	scores := make(map[string]float64, len(scoresAsArray))
 	for _, scoreObject := range scoresAsArray {
 		score, ok := scoreObject.(map[string]interface{})
 		if !ok {
-			return fmt.Errorf("unexpected return value %s from the score function, score element must be an object", reflect.TypeOf(scoreObject))
+			return nil, fmt.Errorf("unexpected return value %s from the score function, score element must be an object", reflect.TypeOf(scoreObject))
 		}
 		idValue, ok := score["id"]
 		if !ok {
@@ -191,7 +254,7 @@ func (r *RatingRegistry) processScores(scoresAsObjects interface{}, chain chains
 		}
 		idString, ok := idValue.(string)
 		if !ok {
-			return fmt.Errorf("unexpected return value %s from the score function, 'id' must be string", reflect.TypeOf(idValue))
+			return nil, fmt.Errorf("unexpected return value %s from the score function, 'id' must be string", reflect.TypeOf(idValue))
 		}
 		scoreValue, ok := score["score"]
 		if !ok {
@@ -199,17 +262,19 @@ func (r *RatingRegistry) processScores(scoresAsObjects interface{}, chain chains
 		}
 		scoreNum, err := cast.ToFloat64E(scoreValue)
 		if err != nil {
-			return fmt.Errorf("unexpected return value %s from the score function, 'score' must be number", reflect.TypeOf(scoreValue))
+			return nil, fmt.Errorf("unexpected return value %s from the score function, 'score' must be number", reflect.TypeOf(scoreValue))
 		}
 		rating.WithLabelValues(chain.String(), method, idString).Set(scoreNum)
+		scores[idString] = scoreNum
 	}
-	return nil
+	return scores, nil
 }
 
-func getUpstreamData(upstreamId, method string, fullDims *dimensions.FullDimensions) map[string]interface{} {
+func getUpstreamData(upstreamId, method string, requestCost float64, fullDims *dimensions.FullDimensions) map[string]interface{} {
 	upData := map[string]interface{}{
 		"id":     upstreamId,
 		"method": method,
+		"cost":   requestCost,
 		"metrics": map[string]interface{}{
 			"latencyP90":        fullDims.UpstreamDimensions.GetValueAtQuantile(0.9),
 			"latencyP95":        fullDims.UpstreamDimensions.GetValueAtQuantile(0.95),
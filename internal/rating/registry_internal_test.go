@@ -2,11 +2,14 @@ package rating
 
 import (
 	"context"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/cost"
 	"github.com/drpcorg/nodecore/internal/dimensions"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/upstreams"
@@ -17,6 +20,7 @@ import (
 	"github.com/drpcorg/nodecore/pkg/utils"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // TestCalculateRatingSortedUpstreamsSize checks that calculateRating stores a
@@ -64,6 +68,59 @@ func TestCalculateRatingSortedUpstreamsSize(t *testing.T) {
 
 	// The single-upstream chain is skipped for sorting but still publishes a fixed rating gauge.
 	assert.Equal(t, float64(singleUpstreamRating), gaugeValue(t, chains.POLYGON, "eth_test1", "id1"))
+
+	// The scores are kept along with the order, there are none for the single-upstream chain.
+	scores := registry.GetScores(chains.ARBITRUM, "eth_test1")
+	assert.Len(t, scores, 2)
+	for upstreamId, score := range scores {
+		assert.Equal(t, gaugeValue(t, chains.ARBITRUM, "eth_test1", upstreamId), score)
+	}
+	assert.Nil(t, registry.GetScores(chains.POLYGON, "eth_test1"))
+}
+
+const costPolicyFunc = `
+	function sortUpstreams(upstreamData: UpstreamData[]): SortResponse {
+		const scores = upstreamData.map((data) => ({"id": data.id, "score": -data.cost}))
+		return {
+			sortedUpstreams: [...scores].sort((a, b) => b.score - a.score).map(data => data.id),
+			scores: scores
+		}
+	}
+`
+
+func TestCalculateRatingExposesCostAndMetrics(t *testing.T) {
+	funcPath := filepath.Join(t.TempDir(), "cost.ts")
+	require.NoError(t, os.WriteFile(funcPath, []byte(costPolicyFunc), 0o600))
+	methods := mocks.NewMethodsMock()
+	methods.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("eth_test1"))
+	chainSupervisor := upstreams.NewGenericChainSupervisor(context.Background(), chains.OPTIMISM, fork_choice.NewHeightForkChoice(), nil, false, nil)
+	go chainSupervisor.Start()
+	for upstreamId, price := range map[string]float64{"id1": 0.01, "id2": 0.001} {
+		event := test_utils.CreateEvent(upstreamId, protocol.Available, protocol.NewBlockWithHeight(100), methods)
+		event.EventType.(*protocol.StateUpstreamEvent).State.Cost = cost.NewUpstreamCost(chains.OPTIMISM, upstreamId, &config.CostConfig{PricePerRequest: price, ComputeUnits: 1})
+		chainSupervisor.PublishUpstreamEvent(event)
+	}
+	assert.Eventually(t, func() bool {
+		return len(chainSupervisor.GetUpstreamIds()) == 2
+	}, time.Second, 5*time.Millisecond)
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	upSupervisor.On("GetChainSupervisors").Return([]upstreams.ChainSupervisor{chainSupervisor})
+	tracker := dimensions.NewGenericDimensionTracker()
+	tracker.GetUpstreamDimensions(chains.OPTIMISM, "id1", "eth_test1").TrackRequestDuration(0.3)
+	tracker.GetChainDimensions(chains.OPTIMISM, "id2").TrackHeadLag(4)
+
+	registry := NewRatingRegistry(upSupervisor, tracker, &config.ScorePolicyConfig{
+		CalculationFunctionFilePath: funcPath,
+		CalculationInterval:         1 * time.Minute,
+	})
+	registry.calculateRating()
+
+	assert.Equal(t, []string{"id2", "id1"}, registry.GetSortedUpstreams(chains.OPTIMISM, "eth_test1"))
+	metrics := registry.GetMetrics(chains.OPTIMISM, "eth_test1")
+	assert.InDelta(t, 0.3, metrics["id1"].LatencyP90, 0.01)
+	assert.Equal(t, uint64(0), metrics["id1"].HeadLag)
+	assert.Equal(t, uint64(4), metrics["id2"].HeadLag)
+	assert.Nil(t, registry.GetMetrics(chains.OPTIMISM, "eth_test2"))
 }
 
 func gaugeValue(t *testing.T, chain chains.Chain, method, upstreamId string) float64 {
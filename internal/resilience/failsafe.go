@@ -1,12 +1,14 @@
 package resilience
 
 import (
+	"context"
 	"errors"
 
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/failsafe-go/failsafe-go"
 	"github.com/failsafe-go/failsafe-go/retrypolicy"
+	"github.com/failsafe-go/failsafe-go/timeout"
 	"github.com/rs/zerolog"
 )
 
@@ -14,6 +16,19 @@ type ctxKey string
 
 const RequestKey ctxKey = "request"
 
+// cancelOnTimeoutKey keeps a func that cancels the attempts of a request once its timeout is exceeded.
+// The attempts can't run on the execution context, it's also cancelled when the execution is done,
+// and a response may still be streamed then
+const cancelOnTimeoutKey ctxKey = "cancel-on-timeout"
+
+// WithCancelOnTimeout returns a context of request attempts that is cancelled by the timeout policy.
+// The returned release func must be called once the response is consumed, otherwise the context
+// lives as long as its parent, e.g. a whole ws connection
+func WithCancelOnTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	return context.WithValue(ctx, cancelOnTimeoutKey, cancel), func() { cancel(context.Canceled) }
+}
+
 func CreateFlowExecutor(policies ...failsafe.Policy[*protocol.ResponseHolderWrapper]) failsafe.Executor[*protocol.ResponseHolderWrapper] {
 	return failsafe.With[*protocol.ResponseHolderWrapper](policies...)
 }
@@ -58,6 +73,22 @@ func CreateFlowRetryPolicy(retryConfig *config.RetryConfig) failsafe.Policy[*pro
 	return retry.Build()
 }
 
+// CreateFlowTimeoutPolicy limits the whole execution of a request, hedges and retries included
+func CreateFlowTimeoutPolicy(timeoutConfig *config.TimeoutConfig) failsafe.Policy[*protocol.ResponseHolderWrapper] {
+	return timeout.NewBuilder[*protocol.ResponseHolderWrapper](timeoutConfig.Timeout).
+		OnTimeoutExceeded(func(event failsafe.ExecutionDoneEvent[*protocol.ResponseHolderWrapper]) {
+			ctx := event.Context()
+			if cancel, ok := ctx.Value(cancelOnTimeoutKey).(context.CancelCauseFunc); ok {
+				cancel(timeout.ErrExceeded)
+			}
+			request, ok := ctx.Value(RequestKey).(protocol.RequestHolder)
+			if ok && request != nil {
+				zerolog.Ctx(ctx).Debug().Msgf("request %s has exceeded the timeout %s", request.Method(), timeoutConfig.Timeout)
+			}
+		}).
+		Build()
+}
+
 func CreateFlowParallelHedgePolicy(hedgeConfig *config.HedgeConfig) failsafe.Policy[*protocol.ResponseHolderWrapper] {
 	hedge := BuilderWithDelay[*protocol.ResponseHolderWrapper](hedgeConfig.Delay).
 		WithMaxHedges(hedgeConfig.Count).
@@ -0,0 +1,41 @@
+package resilience
+
+import (
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/failsafe-go/failsafe-go"
+)
+
+// MethodExecutors holds an executor for each failsafe method policy. A request is executed
+// by the executor of the first policy that matches its method, otherwise by the default one
+type MethodExecutors[R any] struct {
+	defaultExecutor failsafe.Executor[R]
+	policies        []*config.MethodFailsafeConfig
+	executors       []failsafe.Executor[R]
+}
+
+func NewMethodExecutors[R any](defaultExecutor failsafe.Executor[R]) *MethodExecutors[R] {
+	return &MethodExecutors[R]{
+		defaultExecutor: defaultExecutor,
+		policies:        make([]*config.MethodFailsafeConfig, 0),
+		executors:       make([]failsafe.Executor[R], 0),
+	}
+}
+
+// WithPolicy adds the executor of the policy, policies are matched in the order they are added
+func (m *MethodExecutors[R]) WithPolicy(policy *config.MethodFailsafeConfig, executor failsafe.Executor[R]) *MethodExecutors[R] {
+	m.policies = append(m.policies, policy)
+	m.executors = append(m.executors, executor)
+	return m
+}
+
+func (m *MethodExecutors[R]) Get(method string) failsafe.Executor[R] {
+	if m == nil {
+		return nil
+	}
+	for i, policy := range m.policies {
+		if policy.Matches(method) {
+			return m.executors[i]
+		}
+	}
+	return m.defaultExecutor
+}
@@ -0,0 +1,252 @@
+package admin_server
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/server/server_ctx"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/labstack/echo/v4"
+	"github.com/labstack/echo/v4/middleware"
+	"github.com/samber/lo"
+	"gopkg.in/yaml.v3"
+)
+
+const maxUpstreamConfigSize = 1 << 20
+
+type blockResponse struct {
+	Height uint64 `json:"height"`
+	Slot   uint64 `json:"slot,omitempty"`
+	Hash   string `json:"hash,omitempty"`
+}
+
+type lowerBoundResponse struct {
+	Type      string `json:"type"`
+	Bound     int64  `json:"bound"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+type upstreamResponse struct {
+	Id          string `json:"id"`
+	Chain       string `json:"chain"`
+	Index       string `json:"index"`
+	Status      string `json:"status"`
+	Running     bool   `json:"running"`
+	Maintenance bool   `json:"maintenance"`
+	// InChain is true if the upstream is registered in its chain and can serve requests
+	InChain         bool                     `json:"in_chain"`
+	Head            blockResponse            `json:"head"`
+	HeadLag         *uint64                  `json:"head_lag,omitempty"`
+	FinalizationLag *uint64                  `json:"finalization_lag,omitempty"`
+	Blocks          map[string]blockResponse `json:"blocks"`
+	LowerBounds     []lowerBoundResponse     `json:"lower_bounds"`
+	Caps            []string                 `json:"caps"`
+	Labels          map[string]string        `json:"labels"`
+	BannedMethods   []string                 `json:"banned_methods"`
+	Methods         []string                 `json:"methods"`
+}
+
+// NewAdminServer creates the admin API to inspect and manage upstreams and the cache at runtime,
+// every request must be authenticated with the configured token
+func NewAdminServer(adminConfig *config.AdminConfig, appCtx *server_ctx.ApplicationServerContext) *echo.Echo {
+	e := echo.New()
+	e.HideBanner = true
+	e.Use(middleware.KeyAuthWithConfig(middleware.KeyAuthConfig{
+		Validator: func(key string, c echo.Context) (bool, error) {
+			return subtle.ConstantTimeCompare([]byte(key), []byte(adminConfig.Token)) == 1, nil
+		},
+		ErrorHandler: func(err error, c echo.Context) error {
+			return echo.NewHTTPError(http.StatusUnauthorized, "invalid or missing admin token")
+		},
+	}))
+
+	supervisor := appCtx.UpstreamSupervisor
+
+	e.GET("/upstreams", func(c echo.Context) error {
+		response := lo.Map(supervisor.GetUpstreams(), func(up upstreams.Upstream, _ int) upstreamResponse {
+			return buildUpstreamResponse(supervisor, up)
+		})
+		return c.JSON(http.StatusOK, response)
+	})
+	e.GET("/upstreams/:id", func(c echo.Context) error {
+		up, err := getUpstream(supervisor, c)
+		if err != nil {
+			return err
+		}
+		return c.JSON(http.StatusOK, buildUpstreamResponse(supervisor, up))
+	})
+	e.POST("/upstreams", func(c echo.Context) error {
+		return addUpstream(appCtx, c)
+	})
+	e.DELETE("/upstreams/:id", func(c echo.Context) error {
+		if err := supervisor.RemoveUpstream(c.Param("id")); err != nil {
+			return toHttpError(err)
+		}
+		return c.NoContent(http.StatusNoContent)
+	})
+	e.PUT("/upstreams/:id/maintenance", func(c echo.Context) error {
+		return setMaintenance(supervisor, c, true)
+	})
+	e.DELETE("/upstreams/:id/maintenance", func(c echo.Context) error {
+		return setMaintenance(supervisor, c, false)
+	})
+	e.PUT("/upstreams/:id/banned-methods/:method", func(c echo.Context) error {
+		up, err := getUpstream(supervisor, c)
+		if err != nil {
+			return err
+		}
+		up.BanMethod(c.Param("method"))
+		return c.NoContent(http.StatusAccepted)
+	})
+	e.DELETE("/upstreams/:id/banned-methods/:method", func(c echo.Context) error {
+		up, err := getUpstream(supervisor, c)
+		if err != nil {
+			return err
+		}
+		up.UnbanMethod(c.Param("method"))
+		return c.NoContent(http.StatusAccepted)
+	})
+
+	if cacheAdmin, ok := appCtx.CacheProcessor.(caches.CacheAdmin); ok {
+		registerCacheRoutes(e, appCtx, cacheAdmin)
+	}
+
+	return e
+}
+
+func addUpstream(appCtx *server_ctx.ApplicationServerContext, c echo.Context) error {
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxUpstreamConfigSize))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("couldn't read the upstream config: %s", err.Error()))
+	}
+	// yaml is a superset of json, so both formats are accepted with the keys of the config file
+	upConfig := &config.Upstream{}
+	if err = yaml.Unmarshal(body, upConfig); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("couldn't parse the upstream config: %s", err.Error()))
+	}
+	if err = appCtx.AppConfig().PrepareUpstream(upConfig); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
+	}
+	if err = appCtx.UpstreamSupervisor.AddUpstream(upConfig); err != nil {
+		return toHttpError(err)
+	}
+	return c.NoContent(http.StatusCreated)
+}
+
+func setMaintenance(supervisor upstreams.UpstreamSupervisor, c echo.Context, enabled bool) error {
+	up, err := getUpstream(supervisor, c)
+	if err != nil {
+		return err
+	}
+	up.SetMaintenance(enabled)
+	return c.NoContent(http.StatusAccepted)
+}
+
+func getUpstream(supervisor upstreams.UpstreamSupervisor, c echo.Context) (upstreams.Upstream, error) {
+	up := supervisor.GetUpstream(c.Param("id"))
+	if up == nil {
+		return nil, echo.NewHTTPError(http.StatusNotFound, fmt.Sprintf("upstream %s not found", c.Param("id")))
+	}
+	return up, nil
+}
+
+func toHttpError(err error) error {
+	switch {
+	case errors.Is(err, upstreams.ErrUpstreamNotFound):
+		return echo.NewHTTPError(http.StatusNotFound, err.Error())
+	case errors.Is(err, upstreams.ErrUpstreamExists):
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	default:
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+}
+
+func buildUpstreamResponse(supervisor upstreams.UpstreamSupervisor, up upstreams.Upstream) upstreamResponse {
+	state := up.GetUpstreamState()
+	response := upstreamResponse{
+		Id:          up.GetId(),
+		Chain:       up.GetChain().String(),
+		Running:     up.Running(),
+		Maintenance: up.InMaintenance(),
+	}
+
+	// the chain snapshot is what requests are routed by, so it's preferred if the upstream is in its chain
+	chainSupervisor := supervisor.GetChainSupervisor(up.GetChain())
+	if chainSupervisor != nil {
+		if chainState := chainSupervisor.GetUpstreamState(up.GetId()); chainState != nil {
+			state = *chainState
+			response.InChain = true
+
+			chainHead := chainSupervisor.GetChainState().HeadData.Head
+			response.HeadLag = new(lag(chainHead.Height, state.HeadData.Height))
+			if chainFinalized, ok := chainSupervisor.GetChainState().Blocks[protocol.FinalizedBlock]; ok && state.BlockInfo != nil {
+				response.FinalizationLag = new(lag(chainFinalized.Height, state.BlockInfo.GetBlock(protocol.FinalizedBlock).Height))
+			}
+		}
+	}
+
+	response.Index = state.UpstreamIndex
+	response.Status = state.Status.String()
+	response.Head = toBlockResponse(state.HeadData)
+	response.Blocks = make(map[string]blockResponse)
+	if state.BlockInfo != nil {
+		for blockType, block := range state.BlockInfo.GetBlocks() {
+			response.Blocks[blockType.String()] = toBlockResponse(block)
+		}
+	}
+	response.LowerBounds = make([]lowerBoundResponse, 0)
+	if state.LowerBoundsInfo != nil {
+		for _, bound := range state.LowerBoundsInfo.GetAllBounds() {
+			response.LowerBounds = append(response.LowerBounds, lowerBoundResponse{Type: bound.Type.String(), Bound: bound.Bound, Timestamp: bound.Timestamp})
+		}
+		slices.SortFunc(response.LowerBounds, func(a, b lowerBoundResponse) int {
+			return strings.Compare(a.Type, b.Type)
+		})
+	}
+	response.Caps = make([]string, 0)
+	if state.Caps != nil {
+		for _, capability := range state.Caps.ToSlice() {
+			response.Caps = append(response.Caps, capability.String())
+		}
+		slices.Sort(response.Caps)
+	}
+	response.Labels = make(map[string]string)
+	if state.Labels != nil {
+		response.Labels = state.Labels.GetAllLabels()
+	}
+	response.BannedMethods = make([]string, 0)
+	if state.BannedMethods != nil {
+		response.BannedMethods = state.BannedMethods.ToSlice()
+		slices.Sort(response.BannedMethods)
+	}
+	response.Methods = make([]string, 0)
+	if state.UpstreamMethods != nil {
+		response.Methods = state.UpstreamMethods.GetSupportedMethods().ToSlice()
+		slices.Sort(response.Methods)
+	}
+
+	return response
+}
+
+func toBlockResponse(block protocol.Block) blockResponse {
+	return blockResponse{
+		Height: block.Height,
+		Slot:   block.Slot,
+		Hash:   block.Hash.ToHex(),
+	}
+}
+
+func lag(chainHeight, upstreamHeight uint64) uint64 {
+	if chainHeight > upstreamHeight {
+		return chainHeight - upstreamHeight
+	}
+	return 0
+}
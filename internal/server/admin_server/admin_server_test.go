@@ -0,0 +1,188 @@
+package admin_server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/server/server_ctx"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/internal/upstreams/fork_choice"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "secret"
+
+func newTestAdminServer(supervisor upstreams.UpstreamSupervisor) *echo.Echo {
+	appConfig := &config.AppConfig{
+		ServerConfig:   &config.ServerConfig{GrpcAuthConfig: &config.GrpcAuthConfig{}},
+		UpstreamConfig: &config.UpstreamConfig{Mode: config.DefaultMode},
+	}
+	appCtx := server_ctx.NewApplicationServerContext(supervisor, nil, nil, nil, appConfig, nil, nil, nil, nil, nil, nil, nil, nil)
+	return NewAdminServer(&config.AdminConfig{Port: 9097, Token: testToken}, appCtx)
+}
+
+func doRequest(server *echo.Echo, method, path, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set(echo.HeaderAuthorization, "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	server.ServeHTTP(rec, req)
+	return rec
+}
+
+func newTestUpstream() *upstreams.GenericUpstream {
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("eth_getBalance", "eth_call"))
+	return test_utils.TestEvmUpstream(mocks.NewConnectorMock(), &config.Upstream{Id: "id"}, methodsMock, nil)
+}
+
+func TestAdminServerWithoutTokenThenUnauthorized(t *testing.T) {
+	server := newTestAdminServer(mocks.NewUpstreamSupervisorMock())
+
+	for _, authHeader := range []string{"", "Bearer wrong"} {
+		req := httptest.NewRequest(http.MethodGet, "/upstreams", nil)
+		if authHeader != "" {
+			req.Header.Set(echo.HeaderAuthorization, authHeader)
+		}
+		rec := httptest.NewRecorder()
+		server.ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	}
+}
+
+func TestAdminServerListUpstreams(t *testing.T) {
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("eth_getBalance"))
+	chainSupervisor := upstreams.NewGenericChainSupervisor(context.Background(), chains.ETHEREUM, fork_choice.NewHeightForkChoice(), nil, false, nil)
+	chainSupervisor.Start()
+	chainSupervisor.PublishUpstreamEvent(test_utils.CreateEvent("id", protocol.Available, protocol.NewBlockWithHeight(95), methodsMock))
+	chainSupervisor.PublishUpstreamEvent(test_utils.CreateEvent("other", protocol.Available, protocol.NewBlockWithHeight(100), methodsMock))
+	chainSupervisor.PublishUpstreamEvent(protocol.UpstreamEvent{
+		Id:        "other",
+		EventType: &protocol.HeadUpstreamEvent{Status: protocol.Available, Head: protocol.NewBlockWithHeight(100)},
+	})
+	time.Sleep(20 * time.Millisecond)
+
+	supervisor := mocks.NewUpstreamSupervisorMock()
+	supervisor.On("GetUpstreams").Return([]upstreams.Upstream{newTestUpstream()})
+	supervisor.On("GetChainSupervisor", chains.ETHEREUM).Return(chainSupervisor)
+	server := newTestAdminServer(supervisor)
+
+	rec := doRequest(server, http.MethodGet, "/upstreams", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body []upstreamResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	require.Len(t, body, 1)
+	assert.Equal(t, "id", body[0].Id)
+	assert.Equal(t, chains.ETHEREUM.String(), body[0].Chain)
+	assert.Equal(t, protocol.Available.String(), body[0].Status)
+	assert.True(t, body[0].InChain)
+	assert.False(t, body[0].Maintenance)
+	assert.Equal(t, uint64(95), body[0].Head.Height)
+	require.NotNil(t, body[0].HeadLag)
+	assert.Equal(t, uint64(5), *body[0].HeadLag)
+	assert.Equal(t, []string{"eth_getBalance"}, body[0].Methods)
+	assert.Empty(t, body[0].BannedMethods)
+}
+
+func TestAdminServerGetUnknownUpstreamThenNotFound(t *testing.T) {
+	supervisor := mocks.NewUpstreamSupervisorMock()
+	supervisor.On("GetUpstream", "unknown").Return(nil)
+	server := newTestAdminServer(supervisor)
+
+	rec := doRequest(server, http.MethodGet, "/upstreams/unknown", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdminServerGetUpstreamNotInChain(t *testing.T) {
+	supervisor := mocks.NewUpstreamSupervisorMock()
+	supervisor.On("GetUpstream", "id").Return(newTestUpstream())
+	supervisor.On("GetChainSupervisor", chains.ETHEREUM).Return(nil)
+	server := newTestAdminServer(supervisor)
+
+	rec := doRequest(server, http.MethodGet, "/upstreams/id", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	var body upstreamResponse
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	assert.False(t, body.InChain)
+	assert.Nil(t, body.HeadLag)
+	assert.Equal(t, "00012", body.Index)
+	assert.Equal(t, []string{"eth_call", "eth_getBalance"}, body.Methods)
+}
+
+func TestAdminServerSetMaintenance(t *testing.T) {
+	upstream := newTestUpstream()
+	supervisor := mocks.NewUpstreamSupervisorMock()
+	supervisor.On("GetUpstream", "id").Return(upstream)
+	server := newTestAdminServer(supervisor)
+
+	rec := doRequest(server, http.MethodPut, "/upstreams/id/maintenance", "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.True(t, upstream.InMaintenance())
+
+	rec = doRequest(server, http.MethodDelete, "/upstreams/id/maintenance", "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.False(t, upstream.InMaintenance())
+}
+
+func TestAdminServerAddUpstream(t *testing.T) {
+	supervisor := mocks.NewUpstreamSupervisorMock()
+	supervisor.On("AddUpstream", mock.Anything).Return(nil)
+	server := newTestAdminServer(supervisor)
+
+	rec := doRequest(server, http.MethodPost, "/upstreams", `{"id": "new", "chain": "ethereum", "connectors": [{"type": "json-rpc", "url": "https://test.com"}]}`)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	upConfig := supervisor.Calls[0].Arguments.Get(0).(*config.Upstream)
+	assert.Equal(t, "new", upConfig.Id)
+	assert.Equal(t, "json-rpc", upConfig.HeadConnector)
+	assert.NotZero(t, upConfig.PollInterval)
+}
+
+func TestAdminServerAddInvalidUpstreamThenBadRequest(t *testing.T) {
+	server := newTestAdminServer(mocks.NewUpstreamSupervisorMock())
+
+	rec := doRequest(server, http.MethodPost, "/upstreams", `{"id": "new", "chain": "ethereum"}`)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminServerAddExistingUpstreamThenConflict(t *testing.T) {
+	supervisor := mocks.NewUpstreamSupervisorMock()
+	supervisor.On("AddUpstream", mock.Anything).Return(upstreams.ErrUpstreamExists)
+	server := newTestAdminServer(supervisor)
+
+	rec := doRequest(server, http.MethodPost, "/upstreams", "id: id\nchain: ethereum\nconnectors:\n  - type: json-rpc\n    url: https://test.com")
+
+	assert.Equal(t, http.StatusConflict, rec.Code)
+}
+
+func TestAdminServerRemoveUpstream(t *testing.T) {
+	supervisor := mocks.NewUpstreamSupervisorMock()
+	supervisor.On("RemoveUpstream", "id").Return(nil)
+	supervisor.On("RemoveUpstream", "unknown").Return(upstreams.ErrUpstreamNotFound)
+	server := newTestAdminServer(supervisor)
+
+	rec := doRequest(server, http.MethodDelete, "/upstreams/id", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = doRequest(server, http.MethodDelete, "/upstreams/unknown", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
@@ -0,0 +1,272 @@
+package admin_server
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/server/server_ctx"
+	"github.com/drpcorg/nodecore/internal/upstreams/flow"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/labstack/echo/v4"
+	"github.com/samber/lo"
+)
+
+const (
+	maxLookupRequestSize = 1 << 20
+	maxWarmFileSize      = 64 << 20
+	// warmBatchSize is the number of requests of a warm file executed at once
+	warmBatchSize = 20
+)
+
+type cacheEntryResponse struct {
+	Policy     string     `json:"policy"`
+	Connector  string     `json:"connector"`
+	Key        string     `json:"key"`
+	Size       int        `json:"size"`
+	Stale      bool       `json:"stale"`
+	Error      bool       `json:"error,omitempty"`
+	FreshUntil *time.Time `json:"fresh_until,omitempty"`
+	StoredAt   *time.Time `json:"stored_at,omitempty"`
+	Result     any        `json:"result"`
+}
+
+type cachePolicyStatsResponse struct {
+	Policy    string `json:"policy"`
+	Connector string `json:"connector"`
+	Entries   int    `json:"entries"`
+	Size      int64  `json:"size"`
+}
+
+type cachePurgeResponse struct {
+	Removed int `json:"removed"`
+}
+
+type cacheWarmResponse struct {
+	Requests  int `json:"requests"`
+	Succeeded int `json:"succeeded"`
+	Failed    int `json:"failed"`
+}
+
+func registerCacheRoutes(e *echo.Echo, appCtx *server_ctx.ApplicationServerContext, cacheAdmin caches.CacheAdmin) {
+	e.GET("/cache/stats", func(c echo.Context) error {
+		stats, err := cacheAdmin.Stats(c.Request().Context())
+		if err != nil {
+			return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+		}
+		return c.JSON(http.StatusOK, lo.Map(stats, func(item caches.PolicyStats, _ int) cachePolicyStatsResponse {
+			return cachePolicyStatsResponse{Policy: item.Policy, Connector: item.Connector, Entries: item.Entries, Size: item.Size}
+		}))
+	})
+	e.POST("/cache/:chain/lookup", func(c echo.Context) error {
+		return lookupCacheEntries(cacheAdmin, c)
+	})
+	e.DELETE("/cache/entries", func(c echo.Context) error {
+		return purgeCacheEntries(cacheAdmin, c)
+	})
+	e.DELETE("/cache/:chain/heights", func(c echo.Context) error {
+		return purgeCacheHeights(cacheAdmin, c)
+	})
+	e.POST("/cache/:chain/warm", func(c echo.Context) error {
+		return warmCache(appCtx, c)
+	})
+}
+
+func lookupCacheEntries(cacheAdmin caches.CacheAdmin, c echo.Context) error {
+	chain, err := getChain(c.Param("chain"))
+	if err != nil {
+		return err
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxLookupRequestSize))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("couldn't read the request: %s", err.Error()))
+	}
+	var jsonRpcRequest protocol.JsonRpcRequestBody
+	if err = json.Unmarshal(body, &jsonRpcRequest); err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("couldn't parse the request: %s", err.Error()))
+	}
+	if jsonRpcRequest.Method == "" {
+		return echo.NewHTTPError(http.StatusBadRequest, "the request has no method")
+	}
+	request := protocol.NewUpstreamJsonRpcRequest("1", jsonRpcRequest, false, chains.GetMethodSpecNameByChainName(chain.String()))
+
+	entries, err := cacheAdmin.Lookup(c.Request().Context(), chain, request)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, lo.Map(entries, func(entry caches.CacheEntry, _ int) cacheEntryResponse {
+		response := cacheEntryResponse{
+			Policy:    entry.Policy,
+			Connector: entry.Connector,
+			Key:       entry.Key,
+			Size:      entry.Size,
+			Stale:     entry.Stale,
+			Error:     entry.Error,
+			Result:    string(entry.Result),
+		}
+		if !entry.FreshUntil.IsZero() {
+			response.FreshUntil = &entry.FreshUntil
+		}
+		if !entry.StoredAt.IsZero() {
+			response.StoredAt = &entry.StoredAt
+		}
+		if json.Valid(entry.Result) {
+			response.Result = json.RawMessage(entry.Result)
+		}
+		return response
+	}))
+}
+
+func purgeCacheEntries(cacheAdmin caches.CacheAdmin, c echo.Context) error {
+	filter := caches.PurgeFilter{
+		Method: c.QueryParam("method"),
+		Policy: c.QueryParam("policy"),
+	}
+	if chainName := c.QueryParam("chain"); chainName != "" {
+		chain, err := getChain(chainName)
+		if err != nil {
+			return err
+		}
+		filter.Chain = chain
+	}
+
+	removed, err := cacheAdmin.Purge(c.Request().Context(), filter)
+	if err != nil {
+		if errors.Is(err, caches.ErrCachePolicyNotFound) {
+			return echo.NewHTTPError(http.StatusNotFound, err.Error())
+		}
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.JSON(http.StatusOK, cachePurgeResponse{Removed: removed})
+}
+
+func purgeCacheHeights(cacheAdmin caches.CacheAdmin, c echo.Context) error {
+	chain, err := getChain(c.Param("chain"))
+	if err != nil {
+		return err
+	}
+	fromHeight, err := strconv.ParseUint(c.QueryParam("from"), 10, 64)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid from height '%s'", c.QueryParam("from")))
+	}
+	toHeight := fromHeight
+	if c.QueryParam("to") != "" {
+		if toHeight, err = strconv.ParseUint(c.QueryParam("to"), 10, 64); err != nil {
+			return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("invalid to height '%s'", c.QueryParam("to")))
+		}
+	}
+	if toHeight < fromHeight {
+		return echo.NewHTTPError(http.StatusBadRequest, "the to height can't be less than the from height")
+	}
+
+	if err = cacheAdmin.PurgeHeights(c.Request().Context(), chain, fromHeight, toHeight); err != nil {
+		return echo.NewHTTPError(http.StatusInternalServerError, err.Error())
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// warmCache replays the requests of a file through the regular execution flow,
+// so their responses are cached by the same policies as responses of clients
+func warmCache(appCtx *server_ctx.ApplicationServerContext, c echo.Context) error {
+	chain, err := getChain(c.Param("chain"))
+	if err != nil {
+		return err
+	}
+	if appCtx.UpstreamSupervisor.GetChainSupervisor(chain) == nil {
+		return echo.NewHTTPError(http.StatusServiceUnavailable, fmt.Sprintf("chain %s has no upstreams", chain))
+	}
+	body, err := io.ReadAll(io.LimitReader(c.Request().Body, maxWarmFileSize))
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("couldn't read the requests: %s", err.Error()))
+	}
+	jsonRpcRequests, err := parseWarmRequests(body)
+	if err != nil {
+		return echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("couldn't parse the requests: %s", err.Error()))
+	}
+
+	ctx := c.Request().Context()
+	specName := chains.GetMethodSpecNameByChainName(chain.String())
+	response := cacheWarmResponse{Requests: len(jsonRpcRequests)}
+
+	for _, batch := range lo.Chunk(jsonRpcRequests, warmBatchSize) {
+		requests := lo.Map(batch, func(jsonRpcRequest protocol.JsonRpcRequestBody, i int) protocol.RequestHolder {
+			return protocol.NewUpstreamJsonRpcRequest(strconv.Itoa(i), jsonRpcRequest, false, specName)
+		})
+		executionFlow := flow.NewGenericExecutionFlow(
+			chain,
+			appCtx.UpstreamSupervisor,
+			appCtx.CacheProcessor,
+			appCtx.Registry,
+			appCtx.AppConfig(),
+			nil,
+			appCtx.QuorumRegistry,
+			appCtx.SubEngineRegistry,
+			appCtx.RequestCoalescer,
+			appCtx.FilterRegistry,
+		)
+		executionFlow.AddHooks(flow.NewMethodBanHook(appCtx.UpstreamSupervisor))
+
+		go executionFlow.Execute(ctx, requests)
+		for wrapper := range executionFlow.GetResponses() {
+			if wrapper.Response.HasError() {
+				response.Failed++
+				continue
+			}
+			if wrapper.Response.HasStream() {
+				// streamed responses aren't cached, the stream is read out to release the upstream connection
+				_, _ = io.Copy(io.Discard, wrapper.Response.EncodeResponse([]byte("0")))
+			}
+			response.Succeeded++
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+
+	return c.JSON(http.StatusOK, response)
+}
+
+// parseWarmRequests accepts a JSON array of JSON-RPC requests or requests separated by new lines
+func parseWarmRequests(body []byte) ([]protocol.JsonRpcRequestBody, error) {
+	body = bytes.TrimSpace(body)
+	requests := make([]protocol.JsonRpcRequestBody, 0)
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &requests); err != nil {
+			return nil, err
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		for {
+			var request protocol.JsonRpcRequestBody
+			err := decoder.Decode(&request)
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			requests = append(requests, request)
+		}
+	}
+
+	for i, request := range requests {
+		if request.Method == "" {
+			return nil, fmt.Errorf("request %d has no method", i)
+		}
+	}
+	return requests, nil
+}
+
+func getChain(chainName string) (chains.Chain, error) {
+	if !chains.IsSupported(chainName) {
+		return chains.Unknown, echo.NewHTTPError(http.StatusBadRequest, fmt.Sprintf("chain %s is not supported", chainName))
+	}
+	return chains.GetChain(chainName).Chain, nil
+}
@@ -0,0 +1,176 @@
+package admin_server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/server/server_ctx"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// cacheAdminMock lives here since the mocks package can't import caches
+type cacheAdminMock struct {
+	*mocks.CacheProcessorMock
+}
+
+func (c *cacheAdminMock) Lookup(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) ([]caches.CacheEntry, error) {
+	args := c.Called(ctx, chain, request.Method(), request.RequestHash())
+	return args.Get(0).([]caches.CacheEntry), args.Error(1)
+}
+
+func (c *cacheAdminMock) Purge(ctx context.Context, filter caches.PurgeFilter) (int, error) {
+	args := c.Called(ctx, filter)
+	return args.Int(0), args.Error(1)
+}
+
+func (c *cacheAdminMock) PurgeHeights(ctx context.Context, chain chains.Chain, fromHeight, toHeight uint64) error {
+	args := c.Called(ctx, chain, fromHeight, toHeight)
+	return args.Error(0)
+}
+
+func (c *cacheAdminMock) Stats(ctx context.Context) ([]caches.PolicyStats, error) {
+	args := c.Called(ctx)
+	return args.Get(0).([]caches.PolicyStats), args.Error(1)
+}
+
+func newTestCacheAdminServer(supervisor upstreams.UpstreamSupervisor) (*echo.Echo, *cacheAdminMock) {
+	appConfig := &config.AppConfig{
+		ServerConfig:   &config.ServerConfig{GrpcAuthConfig: &config.GrpcAuthConfig{}},
+		UpstreamConfig: &config.UpstreamConfig{Mode: config.DefaultMode},
+	}
+	cacheAdmin := &cacheAdminMock{CacheProcessorMock: mocks.NewCacheProcessorMock()}
+	appCtx := server_ctx.NewApplicationServerContext(supervisor, cacheAdmin, nil, nil, appConfig, nil, nil, nil, nil, nil, nil, nil, nil)
+	return NewAdminServer(&config.AdminConfig{Port: 9097, Token: testToken}, appCtx), cacheAdmin
+}
+
+func TestAdminServerCacheRoutesWithoutCacheAdminThenNotFound(t *testing.T) {
+	server := newTestAdminServer(mocks.NewUpstreamSupervisorMock())
+
+	rec := doRequest(server, http.MethodGet, "/cache/stats", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdminServerCacheStats(t *testing.T) {
+	server, cacheAdmin := newTestCacheAdminServer(mocks.NewUpstreamSupervisorMock())
+	cacheAdmin.On("Stats", mock.Anything).Return([]caches.PolicyStats{
+		{Policy: "policy", Connector: "memory", Entries: 2, Size: 100},
+	}, nil)
+
+	rec := doRequest(server, http.MethodGet, "/cache/stats", "")
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[{"policy":"policy","connector":"memory","entries":2,"size":100}]`, rec.Body.String())
+}
+
+func TestAdminServerCacheLookup(t *testing.T) {
+	server, cacheAdmin := newTestCacheAdminServer(mocks.NewUpstreamSupervisorMock())
+	request := protocol.NewUpstreamJsonRpcRequest("1", protocol.JsonRpcRequestBody{Method: "eth_getBlockByNumber", Params: json.RawMessage(`["0x10",false]`)}, false, "")
+	freshUntil := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	cacheAdmin.On("Lookup", mock.Anything, chains.POLYGON, "eth_getBlockByNumber", request.RequestHash()).Return([]caches.CacheEntry{
+		{Policy: "policy", Connector: "memory", Key: "key", Size: 17, StoredAt: freshUntil, Result: []byte(`{"number":"0x10"}`)},
+		{Policy: "stale", Connector: "redis", Key: "key", Size: 3, Stale: true, FreshUntil: freshUntil, Result: []byte(`raw`)},
+	}, nil)
+
+	rec := doRequest(server, http.MethodPost, "/cache/polygon/lookup", `{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x10",false]}`)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `[
+		{"policy":"policy","connector":"memory","key":"key","size":17,"stale":false,"stored_at":"2026-01-01T00:00:00Z","result":{"number":"0x10"}},
+		{"policy":"stale","connector":"redis","key":"key","size":3,"stale":true,"fresh_until":"2026-01-01T00:00:00Z","result":"raw"}
+	]`, rec.Body.String())
+}
+
+func TestAdminServerCacheLookupInvalidRequestThenBadRequest(t *testing.T) {
+	server, _ := newTestCacheAdminServer(mocks.NewUpstreamSupervisorMock())
+
+	for _, test := range []struct{ path, body string }{
+		{"/cache/unknown-chain/lookup", `{"method":"eth_chainId"}`},
+		{"/cache/polygon/lookup", `not json`},
+		{"/cache/polygon/lookup", `{"params":[]}`},
+	} {
+		rec := doRequest(server, http.MethodPost, test.path, test.body)
+		assert.Equal(t, http.StatusBadRequest, rec.Code, test.body)
+	}
+}
+
+func TestAdminServerCachePurgeEntries(t *testing.T) {
+	server, cacheAdmin := newTestCacheAdminServer(mocks.NewUpstreamSupervisorMock())
+	cacheAdmin.On("Purge", mock.Anything, caches.PurgeFilter{Chain: chains.POLYGON, Method: "eth_call", Policy: "policy"}).Return(5, nil)
+	cacheAdmin.On("Purge", mock.Anything, caches.PurgeFilter{Policy: "unknown"}).Return(0, fmt.Errorf("%w: unknown", caches.ErrCachePolicyNotFound))
+
+	rec := doRequest(server, http.MethodDelete, "/cache/entries?chain=polygon&method=eth_call&policy=policy", "")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"removed":5}`, rec.Body.String())
+
+	rec = doRequest(server, http.MethodDelete, "/cache/entries?policy=unknown", "")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	rec = doRequest(server, http.MethodDelete, "/cache/entries?chain=unknown-chain", "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestAdminServerCachePurgeHeights(t *testing.T) {
+	server, cacheAdmin := newTestCacheAdminServer(mocks.NewUpstreamSupervisorMock())
+	cacheAdmin.On("PurgeHeights", mock.Anything, chains.POLYGON, uint64(100), uint64(110)).Return(nil).Once()
+	cacheAdmin.On("PurgeHeights", mock.Anything, chains.POLYGON, uint64(120), uint64(120)).Return(nil).Once()
+
+	rec := doRequest(server, http.MethodDelete, "/cache/polygon/heights?from=100&to=110", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	rec = doRequest(server, http.MethodDelete, "/cache/polygon/heights?from=120", "")
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	for _, query := range []string{"", "?from=abc", "?from=100&to=abc", "?from=100&to=99"} {
+		rec = doRequest(server, http.MethodDelete, "/cache/polygon/heights"+query, "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+	}
+
+	cacheAdmin.AssertExpectations(t)
+}
+
+func TestAdminServerCacheWarmChainWithoutUpstreamsThenUnavailable(t *testing.T) {
+	supervisor := mocks.NewUpstreamSupervisorMock()
+	supervisor.On("GetChainSupervisor", chains.POLYGON).Return(nil)
+	server, _ := newTestCacheAdminServer(supervisor)
+
+	rec := doRequest(server, http.MethodPost, "/cache/polygon/warm", `{"method":"eth_chainId"}`)
+
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
+
+func TestParseWarmRequests(t *testing.T) {
+	newLineRequests, err := parseWarmRequests([]byte(`
+{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x10",false]}
+{"jsonrpc":"2.0","id":2,"method":"eth_getBlockReceipts","params":["0x10"]}
+`))
+	require.NoError(t, err)
+	arrayRequests, err := parseWarmRequests([]byte(`[
+		{"jsonrpc":"2.0","id":1,"method":"eth_getBlockByNumber","params":["0x10",false]},
+		{"jsonrpc":"2.0","id":2,"method":"eth_getBlockReceipts","params":["0x10"]}
+	]`))
+	require.NoError(t, err)
+
+	for _, requests := range [][]protocol.JsonRpcRequestBody{newLineRequests, arrayRequests} {
+		require.Len(t, requests, 2)
+		assert.Equal(t, "eth_getBlockByNumber", requests[0].Method)
+		assert.JSONEq(t, `["0x10",false]`, string(requests[0].Params))
+		assert.Equal(t, "eth_getBlockReceipts", requests[1].Method)
+	}
+
+	_, err = parseWarmRequests([]byte(`{"method":"eth_chainId"}` + "\n" + `{"params":[]}`))
+	assert.ErrorContains(t, err, "request 1 has no method")
+}
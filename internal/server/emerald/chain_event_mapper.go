@@ -18,29 +18,33 @@ func SubMethodsToApi(methods []string) *dshackle.ChainEvent {
 }
 
 func LabelsToApi(labels []upstreams.AggregatedLabels) *dshackle.ChainEvent {
-	nodeDetails := lo.Map(labels, func(item upstreams.AggregatedLabels, index int) *dshackle.NodeDetails {
-		apiLabels := lo.MapToSlice(item.Labels, func(key string, value string) *dshackle.Label {
-			return &dshackle.Label{
-				Name:  key,
-				Value: value,
-			}
-		})
-
-		return &dshackle.NodeDetails{
-			Quorum: uint32(item.Amount),
-			Labels: apiLabels,
-		}
-	})
-
 	return &dshackle.ChainEvent{
 		ChainEvent: &dshackle.ChainEvent_NodesEvent{
 			NodesEvent: &dshackle.NodeDetailsEvent{
-				Nodes: nodeDetails,
+				Nodes: nodeDetailsToApi(labels),
 			},
 		},
 	}
 }
 
+func nodeDetailsToApi(labels []upstreams.AggregatedLabels) []*dshackle.NodeDetails {
+	return lo.Map(labels, func(item upstreams.AggregatedLabels, index int) *dshackle.NodeDetails {
+		return &dshackle.NodeDetails{
+			Quorum: uint32(item.Amount),
+			Labels: labelsToApi(item.Labels),
+		}
+	})
+}
+
+func labelsToApi(labels map[string]string) []*dshackle.Label {
+	return lo.MapToSlice(labels, func(key string, value string) *dshackle.Label {
+		return &dshackle.Label{
+			Name:  key,
+			Value: value,
+		}
+	})
+}
+
 func ChainStatusToApi(status protocol.AvailabilityStatus) *dshackle.ChainEvent {
 	return &dshackle.ChainEvent{
 		ChainEvent: &dshackle.ChainEvent_Status{
@@ -117,6 +121,31 @@ func HeadToApi(head protocol.Block) *dshackle.ChainEvent {
 	}
 }
 
+// ChainHeadToApi is the merged head of the chain with its finalization data and lower bounds
+func ChainHeadToApi(grpcId int, state upstreams.ChainSupervisorState) *dshackle.ChainHead {
+	head := HeadToApi(state.HeadData.Head).GetHead()
+	return &dshackle.ChainHead{
+		Chain:            dshackle.ChainRef(grpcId),
+		Height:           head.Height,
+		BlockId:          head.BlockId,
+		ParentBlockId:    head.ParentBlockId,
+		Slot:             head.Slot,
+		LowerBounds:      LowerBoundsToApi(lo.Values(state.LowerBounds)).GetLowerBoundsEvent().GetLowerBounds(),
+		FinalizationData: BlocksToApi(state.Blocks).GetFinalizationDataEvent().GetFinalizationData(),
+	}
+}
+
+func CapabilitiesToApi(state upstreams.ChainSupervisorState) []dshackle.Capabilities {
+	capabilities := make([]dshackle.Capabilities, 0)
+	if state.Methods != nil && state.Methods.GetSupportedMethods().Cardinality() > 0 {
+		capabilities = append(capabilities, dshackle.Capabilities_CAP_CALLS)
+	}
+	if state.Caps != nil && state.Caps.Contains(protocol.WsCap) {
+		capabilities = append(capabilities, dshackle.Capabilities_CAP_WS_HEAD)
+	}
+	return capabilities
+}
+
 func blockTypeToApi(blockType protocol.BlockType) dshackle.FinalizationType {
 	switch blockType {
 	case protocol.FinalizedBlock:
@@ -0,0 +1,81 @@
+package emerald
+
+import (
+	"slices"
+
+	"github.com/drpcorg/nodecore/internal/buildinfo"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/dshackle"
+)
+
+func Describe(upstreamSupervisor upstreams.UpstreamSupervisor) (*dshackle.DescribeResponse, error) {
+	if upstreamSupervisor == nil {
+		return nil, errNilUpstreamSupervisor
+	}
+
+	describeChains := make([]*dshackle.DescribeChain, 0)
+	for _, chainSupervisor := range upstreamSupervisor.GetChainSupervisors() {
+		if chainSupervisor == nil {
+			continue
+		}
+		grpcId := chains.GetChain(chainSupervisor.GetChain().String()).GrpcId
+		describeChains = append(describeChains, describeChainToApi(grpcId, chainSupervisor.GetChainState(), chainQuorum(chainSupervisor)))
+	}
+	slices.SortFunc(describeChains, func(a, b *dshackle.DescribeChain) int {
+		return int(a.Chain) - int(b.Chain)
+	})
+
+	return &dshackle.DescribeResponse{
+		Chains: describeChains,
+		BuildInfo: &dshackle.BuildInfo{
+			Version: buildinfo.ProductVersion(),
+		},
+	}, nil
+}
+
+func describeChainToApi(grpcId int, state upstreams.ChainSupervisorState, quorum uint32) *dshackle.DescribeChain {
+	supportedMethods := make([]string, 0)
+	if state.Methods != nil {
+		supportedMethods = state.Methods.GetSupportedMethods().ToSlice()
+		slices.Sort(supportedMethods)
+	}
+	supportedSubscriptions := make([]string, 0)
+	if state.SubMethods != nil {
+		supportedSubscriptions = state.SubMethods.ToSlice()
+		slices.Sort(supportedSubscriptions)
+	}
+
+	return &dshackle.DescribeChain{
+		Chain:                  dshackle.ChainRef(grpcId),
+		Status:                 chainStatusToApi(grpcId, state.Status, quorum),
+		Nodes:                  nodeDetailsToApi(state.ChainLabels),
+		SupportedMethods:       supportedMethods,
+		Capabilities:           CapabilitiesToApi(state),
+		CurrentHeight:          int64(state.HeadData.Head.Height),
+		SupportedSubscriptions: supportedSubscriptions,
+		CurrentLowerBlock:      state.LowerBounds[protocol.BlockBound].Bound,
+		CurrentLowerSlot:       state.LowerBounds[protocol.SlotBound].Bound,
+	}
+}
+
+func chainStatusToApi(grpcId int, status protocol.AvailabilityStatus, quorum uint32) *dshackle.ChainStatus {
+	return &dshackle.ChainStatus{
+		Chain:        dshackle.ChainRef(grpcId),
+		Availability: availabilityStatusToApi(status),
+		Quorum:       quorum,
+	}
+}
+
+// chainQuorum is the number of available upstreams of the chain
+func chainQuorum(chainSupervisor upstreams.ChainSupervisor) uint32 {
+	quorum := uint32(0)
+	for _, upstreamId := range chainSupervisor.GetUpstreamIds() {
+		upstreamState := chainSupervisor.GetUpstreamState(upstreamId)
+		if upstreamState != nil && upstreamState.Status == protocol.Available {
+			quorum++
+		}
+	}
+	return quorum
+}
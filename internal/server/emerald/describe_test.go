@@ -0,0 +1,86 @@
+package emerald_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/server/emerald"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/internal/upstreams/fork_choice"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/dshackle"
+	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newStartedChainSupervisor(t *testing.T, chain chains.Chain, events ...protocol.UpstreamEvent) *upstreams.GenericChainSupervisor {
+	t.Helper()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	chainSupervisor := upstreams.NewGenericChainSupervisor(ctx, chain, fork_choice.NewHeightForkChoice(), nil, false, nil)
+	chainSupervisor.Start()
+	for _, event := range events {
+		chainSupervisor.PublishUpstreamEvent(event)
+	}
+	time.Sleep(20 * time.Millisecond)
+	return chainSupervisor
+}
+
+func TestDescribe_NilSupervisorReturnsError(t *testing.T) {
+	_, err := emerald.Describe(nil)
+
+	require.Error(t, err)
+	assert.Equal(t, "upstream supervisor cannot be nil", err.Error())
+}
+
+func TestDescribe_DescribesEveryChain(t *testing.T) {
+	methodsMock := newMethodsMockWithSupported("eth_getBalance", "eth_call")
+	ethSupervisor := newStartedChainSupervisor(
+		t,
+		chains.ETHEREUM,
+		test_utils.CreateEvent("up-1", protocol.Available, protocol.NewBlockWithHeight(100), methodsMock),
+		test_utils.CreateEvent("up-2", protocol.Available, protocol.NewBlockWithHeight(95), methodsMock),
+		test_utils.CreateEvent("up-3", protocol.Unavailable, protocol.NewBlockWithHeight(90), methodsMock),
+		protocol.UpstreamEvent{
+			Id:        "up-1",
+			EventType: &protocol.HeadUpstreamEvent{Status: protocol.Available, Head: protocol.NewBlockWithHeight(100)},
+		},
+	)
+	lowerBoundsState := newChainState(chains.POLYGON, protocol.NewBlockWithHeight(50), []string{"eth_call"})
+	lowerBoundsState.LowerBounds[protocol.BlockBound] = protocol.LowerBoundData{Bound: 10, Type: protocol.BlockBound}
+	lowerBoundsState.Caps = mapset.NewThreadUnsafeSet(protocol.WsCap)
+	lowerBoundsState.SubMethods = mapset.NewThreadUnsafeSet("newHeads", "logs")
+	polygonSupervisor := newFakeChainSupervisor(chains.POLYGON, lowerBoundsState)
+
+	upstreamSupervisor := mocks.NewUpstreamSupervisorMock()
+	upstreamSupervisor.On("GetChainSupervisors").Return([]upstreams.ChainSupervisor{polygonSupervisor, ethSupervisor})
+
+	response, err := emerald.Describe(upstreamSupervisor)
+
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(response.BuildInfo.Version, "nodecore/"))
+	require.Len(t, response.Chains, 2)
+
+	eth := response.Chains[0]
+	assert.Equal(t, dshackle.ChainRef(chains.GetChain(chains.ETHEREUM.String()).GrpcId), eth.Chain)
+	assert.Equal(t, dshackle.AvailabilityEnum_AVAIL_OK, eth.Status.Availability)
+	assert.Equal(t, uint32(2), eth.Status.Quorum)
+	assert.Equal(t, int64(100), eth.CurrentHeight)
+	assert.Equal(t, []string{"eth_call", "eth_getBalance"}, eth.SupportedMethods)
+	assert.Equal(t, []dshackle.Capabilities{dshackle.Capabilities_CAP_CALLS}, eth.Capabilities)
+	assert.Empty(t, eth.SupportedSubscriptions)
+
+	polygon := response.Chains[1]
+	assert.Equal(t, dshackle.ChainRef(chains.GetChain(chains.POLYGON.String()).GrpcId), polygon.Chain)
+	assert.Equal(t, int64(50), polygon.CurrentHeight)
+	assert.Equal(t, int64(10), polygon.CurrentLowerBlock)
+	assert.Equal(t, []dshackle.Capabilities{dshackle.Capabilities_CAP_CALLS, dshackle.Capabilities_CAP_WS_HEAD}, polygon.Capabilities)
+	assert.Equal(t, []string{"logs", "newHeads"}, polygon.SupportedSubscriptions)
+}
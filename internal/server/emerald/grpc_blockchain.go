@@ -1,6 +1,7 @@
 package emerald
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,10 +11,12 @@ import (
 	"time"
 
 	"github.com/bytedance/sonic"
+	"github.com/drpcorg/nodecore/internal/caches"
 	"github.com/drpcorg/nodecore/internal/dimensions"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/server/server_ctx"
 	"github.com/drpcorg/nodecore/internal/signature"
+	"github.com/drpcorg/nodecore/internal/tracing"
 	"github.com/drpcorg/nodecore/internal/upstreams"
 	"github.com/drpcorg/nodecore/internal/upstreams/flow"
 	"github.com/drpcorg/nodecore/pkg/chains"
@@ -21,6 +24,7 @@ import (
 	specs "github.com/drpcorg/nodecore/pkg/methods"
 	"github.com/rs/zerolog/log"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 
 	_ "google.golang.org/grpc/encoding/gzip"
@@ -28,6 +32,9 @@ import (
 
 const defaultNativeSubscribeHeartbeat = 30 * time.Second
 
+// grpcCacheControlHeaderKey is the metadata key of the Cache-Control directives of a native call
+const grpcCacheControlHeaderKey = "cache-control"
+
 var errSubscribeMappingNotSupported = errors.New("unsupported subscribe method mapping")
 
 type GrpcBlockchainService struct {
@@ -66,13 +73,82 @@ func (s *GrpcBlockchainService) SubscribeChainStatus(request *dshackle.Subscribe
 	return SubscribeChainStatus(s.appCtx.UpstreamSupervisor, stream)
 }
 
-func (s *GrpcBlockchainService) NativeCall(request *dshackle.NativeCallRequest, stream dshackle.Blockchain_NativeCallServer) error {
+func (s *GrpcBlockchainService) SubscribeHead(request *dshackle.Chain, stream dshackle.Blockchain_SubscribeHeadServer) error {
+	if err := s.sessionAuth.requireSession(stream.Context()); err != nil {
+		return err
+	}
+	if request == nil {
+		return status.Error(codes.Internal, "request is nil")
+	}
+	if s.appCtx == nil || s.appCtx.UpstreamSupervisor == nil {
+		return status.Error(codes.Unavailable, "upstream supervisor is not configured")
+	}
+
+	configuredChain, chainSupervisor := s.resolveChain(request.GetType())
+	if configuredChain == nil {
+		return status.Error(codes.Unavailable, fmt.Sprintf("chain %d is not supported", request.GetType()))
+	}
+	if chainSupervisor == nil {
+		return status.Error(codes.Unavailable, protocol.NoAvailableUpstreamsError().Message)
+	}
+
+	return SubscribeHead(chainSupervisor, configuredChain.GrpcId, stream)
+}
+
+func (s *GrpcBlockchainService) Describe(ctx context.Context, _ *dshackle.DescribeRequest) (*dshackle.DescribeResponse, error) {
+	if err := s.sessionAuth.requireSession(ctx); err != nil {
+		return nil, err
+	}
+	if s.appCtx == nil || s.appCtx.UpstreamSupervisor == nil {
+		return nil, status.Error(codes.Unavailable, "upstream supervisor is not configured")
+	}
+
+	return Describe(s.appCtx.UpstreamSupervisor)
+}
+
+func (s *GrpcBlockchainService) SubscribeStatus(request *dshackle.StatusRequest, stream dshackle.Blockchain_SubscribeStatusServer) error {
+	if err := s.sessionAuth.requireSession(stream.Context()); err != nil {
+		return err
+	}
+	if request == nil {
+		return status.Error(codes.Internal, "request is nil")
+	}
+	if s.appCtx == nil || s.appCtx.UpstreamSupervisor == nil {
+		return status.Error(codes.Unavailable, "upstream supervisor is not configured")
+	}
+
+	return SubscribeStatus(s.appCtx.UpstreamSupervisor, request.GetChains(), stream)
+}
+
+func (s *GrpcBlockchainService) SubscribeNodeStatus(request *dshackle.SubscribeNodeStatusRequest, stream dshackle.Blockchain_SubscribeNodeStatusServer) error {
 	if err := s.sessionAuth.requireSession(stream.Context()); err != nil {
 		return err
 	}
+	if request == nil {
+		return status.Error(codes.Internal, "request is nil")
+	}
+	if s.appCtx == nil || s.appCtx.UpstreamSupervisor == nil {
+		return status.Error(codes.Unavailable, "upstream supervisor is not configured")
+	}
+
+	return SubscribeNodeStatus(s.appCtx.UpstreamSupervisor, stream)
+}
+
+func (s *GrpcBlockchainService) NativeCall(request *dshackle.NativeCallRequest, stream dshackle.Blockchain_NativeCallServer) error {
+	ctx, span := tracing.StartServerSpan(tracing.ExtractGrpc(stream.Context()), "grpc.native-call")
+	defer span.End()
+
+	if err := s.authenticate(ctx); err != nil {
+		return err
+	}
 	if request == nil {
 		return stream.Send(nativeCallErrorItem(0, protocol.ClientError(fmt.Errorf("request is nil")), flow.NoUpstream, nil, nil))
 	}
+	cacheControl, err := nativeCallCacheControl(ctx)
+	if err != nil {
+		return stream.Send(nativeCallErrorItem(0, protocol.ClientError(fmt.Errorf("invalid cache control: %w", err)), flow.NoUpstream, nil, nil))
+	}
+	ctx = caches.WithCacheControl(ctx, cacheControl)
 	if s.appCtx == nil || s.appCtx.UpstreamSupervisor == nil {
 		return stream.Send(nativeCallErrorItem(0, protocol.NoAvailableUpstreamsError(), flow.NoUpstream, nil, nil))
 	}
@@ -84,6 +160,7 @@ func (s *GrpcBlockchainService) NativeCall(request *dshackle.NativeCallRequest,
 	if chainSupervisor == nil {
 		return stream.Send(nativeCallErrorItem(0, protocol.NoAvailableUpstreamsError(), flow.NoUpstream, nil, nil))
 	}
+	span.SetAttributes(tracing.ChainKey.String(configuredChain.Chain.String()))
 
 	requests, items, preResponses := s.buildNativeCallRequests(configuredChain, request)
 	for _, preResponse := range preResponses {
@@ -100,17 +177,19 @@ func (s *GrpcBlockchainService) NativeCall(request *dshackle.NativeCallRequest,
 		s.appCtx.UpstreamSupervisor,
 		s.appCtx.CacheProcessor,
 		s.appCtx.Registry,
-		s.appCtx.AppConfig,
+		s.appCtx.AppConfig(),
 		flow.NewSubCtx(),
 		s.appCtx.QuorumRegistry,
 		s.appCtx.SubEngineRegistry,
+		s.appCtx.RequestCoalescer,
+		s.appCtx.FilterRegistry,
 	)
 	executionFlow.AddHooks(
 		flow.NewMethodBanHook(s.appCtx.UpstreamSupervisor),
 		dimensions.NewDimensionHook(s.appCtx.DimensionTracker),
 	)
 
-	go executionFlow.Execute(stream.Context(), requests)
+	go executionFlow.Execute(ctx, requests)
 
 	for wrapper := range executionFlow.GetResponses() {
 		item, ok := items[wrapper.RequestId]
@@ -132,7 +211,10 @@ func (s *GrpcBlockchainService) NativeCall(request *dshackle.NativeCallRequest,
 }
 
 func (s *GrpcBlockchainService) NativeSubscribe(request *dshackle.NativeSubscribeRequest, stream dshackle.Blockchain_NativeSubscribeServer) error {
-	if err := s.sessionAuth.requireSession(stream.Context()); err != nil {
+	ctx, span := tracing.StartServerSpan(tracing.ExtractGrpc(stream.Context()), "grpc.native-subscribe")
+	defer span.End()
+
+	if err := s.authenticate(ctx); err != nil {
 		return err
 	}
 	if request == nil {
@@ -149,6 +231,7 @@ func (s *GrpcBlockchainService) NativeSubscribe(request *dshackle.NativeSubscrib
 	if chainSupervisor == nil {
 		return status.Error(codes.Unavailable, protocol.NoAvailableUpstreamsError().Message)
 	}
+	span.SetAttributes(tracing.ChainKey.String(configuredChain.Chain.String()), tracing.MethodKey.String(request.GetMethod()))
 
 	if !subscribeMethodSupported(chainSupervisor, request.GetMethod()) {
 		return status.Error(codes.Unimplemented, fmt.Sprintf("subscribe %s is not supported for chain %d", request.GetMethod(), request.GetChain()))
@@ -177,14 +260,16 @@ func (s *GrpcBlockchainService) NativeSubscribe(request *dshackle.NativeSubscrib
 		s.appCtx.UpstreamSupervisor,
 		s.appCtx.CacheProcessor,
 		s.appCtx.Registry,
-		s.appCtx.AppConfig,
+		s.appCtx.AppConfig(),
 		subCtx,
 		s.appCtx.QuorumRegistry,
 		s.appCtx.SubEngineRegistry,
+		s.appCtx.RequestCoalescer,
+		s.appCtx.FilterRegistry,
 	)
 	executionFlow.AddHooks(flow.NewMethodBanHook(s.appCtx.UpstreamSupervisor))
 
-	go executionFlow.Execute(stream.Context(), []protocol.RequestHolder{subscribeRequest})
+	go executionFlow.Execute(ctx, []protocol.RequestHolder{subscribeRequest})
 
 	ticker := time.NewTicker(s.heartbeatInterval)
 	defer ticker.Stop()
@@ -233,6 +318,24 @@ func (s *GrpcBlockchainService) NativeSubscribe(request *dshackle.NativeSubscrib
 	}
 }
 
+func (s *GrpcBlockchainService) authenticate(ctx context.Context) error {
+	ctx, span := tracing.StartSpan(ctx, "auth.session")
+	defer span.End()
+
+	err := s.sessionAuth.requireSession(ctx)
+	tracing.SetError(span, err)
+	return err
+}
+
+// nativeCallCacheControl parses the cache control metadata of a native call, it applies to every item of the call
+func nativeCallCacheControl(ctx context.Context) (caches.CacheControl, error) {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return caches.CacheControl{}, nil
+	}
+	return caches.ParseCacheControl(strings.Join(md.Get(grpcCacheControlHeaderKey), ","))
+}
+
 func (s *GrpcBlockchainService) resolveChain(chainRef dshackle.ChainRef) (*chains.ConfiguredChain, upstreams.ChainSupervisor) {
 	configuredChain := chains.GetChainByGrpcId(int(chainRef))
 	if configuredChain == nil || configuredChain.Chain < 0 {
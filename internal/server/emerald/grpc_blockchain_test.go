@@ -10,6 +10,7 @@ import (
 	"time"
 
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/caches"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/signature"
 	"github.com/drpcorg/nodecore/internal/upstreams"
@@ -492,6 +493,30 @@ func TestNativeCallUnauthenticated(t *testing.T) {
 	assert.Contains(t, err.Error(), "does not exist")
 }
 
+func TestNativeCallCacheControl(t *testing.T) {
+	cacheControl, err := nativeCallCacheControl(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, caches.CacheControl{}, cacheControl)
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("cache-control", "no-cache", "cache-control", "max-age=5"))
+	cacheControl, err = nativeCallCacheControl(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, caches.CacheControl{NoCache: true, MaxAge: 5 * time.Second}, cacheControl)
+}
+
+func TestNativeCallInvalidCacheControlThenErrorItem(t *testing.T) {
+	service := NewGrpcBlockchainService(nil, newGrpcSessionAuth(false, newGrpcSessionStore(time.Minute)), signature.NewDisabledSigner())
+	stream := &testNativeCallStream{ctx: metadata.NewIncomingContext(context.Background(), metadata.Pairs("cache-control", "max-age=soon"))}
+
+	err := service.NativeCall(&dshackle.NativeCallRequest{}, stream)
+
+	require.NoError(t, err)
+	require.Len(t, stream.sent, 1)
+	assert.False(t, stream.sent[0].Succeed)
+	assert.Equal(t, int32(400), stream.sent[0].ItemErrorCode)
+	assert.Equal(t, "client error - invalid cache control: invalid max-age 'soon'", stream.sent[0].ErrorMessage)
+}
+
 func TestNativeSubscribeUnauthenticated(t *testing.T) {
 	service := NewGrpcBlockchainService(nil, newGrpcSessionAuth(true, newGrpcSessionStore(time.Minute)), signature.NewDisabledSigner())
 	stream := &testNativeSubscribeStream{ctx: context.Background()}
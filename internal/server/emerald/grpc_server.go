@@ -25,10 +25,10 @@ type GrpcServer struct {
 }
 
 func NewGrpcServer(appCtx *server_ctx.ApplicationServerContext) (*GrpcServer, error) {
-	if appCtx == nil || appCtx.AppConfig == nil || appCtx.AppConfig.ServerConfig == nil {
+	if appCtx == nil || appCtx.AppConfig() == nil || appCtx.AppConfig().ServerConfig == nil {
 		return nil, nil
 	}
-	serverConfig := appCtx.AppConfig.ServerConfig
+	serverConfig := appCtx.AppConfig().ServerConfig
 	if serverConfig.GrpcPort == 0 {
 		return nil, nil
 	}
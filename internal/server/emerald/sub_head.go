@@ -0,0 +1,79 @@
+package emerald
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/dshackle"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+var errNilChainSupervisor = errors.New("chain supervisor cannot be nil")
+
+// SubscribeHead streams the merged head of the chain chosen by its fork choice,
+// the current head is sent first as soon as the chain has one
+func SubscribeHead(
+	chainSupervisor upstreams.ChainSupervisor,
+	grpcId int,
+	stream dshackle.Blockchain_SubscribeHeadServer,
+) error {
+	if chainSupervisor == nil {
+		return errNilChainSupervisor
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	stateSub := chainSupervisor.SubscribeState(fmt.Sprintf("head_%s_%s", chainSupervisor.GetChain(), uuid.NewString()))
+	defer stateSub.Unsubscribe()
+
+	var lastHead *dshackle.ChainHead
+	sendHead := func() error {
+		state := chainSupervisor.GetChainState()
+		if state.HeadData.IsEmpty() {
+			return nil
+		}
+		head := ChainHeadToApi(grpcId, state)
+		if lastHead != nil && lastHead.Height == head.Height && lastHead.BlockId == head.BlockId {
+			return nil
+		}
+		if err := stream.Send(head); err != nil {
+			log.Error().Err(err).Msgf("failed to send a ChainHead")
+			return err
+		}
+		lastHead = head
+		return nil
+	}
+
+	if err := sendHead(); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-stateSub.Events:
+			if !ok {
+				return nil
+			}
+			if !hasHeadWrapper(event.Wrappers) {
+				continue
+			}
+			if err := sendHead(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func hasHeadWrapper(wrappers []upstreams.ChainSupervisorStateWrapper) bool {
+	for _, wrapper := range wrappers {
+		if _, ok := wrapper.(*upstreams.HeadWrapper); ok {
+			return true
+		}
+	}
+	return false
+}
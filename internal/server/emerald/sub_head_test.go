@@ -0,0 +1,136 @@
+package emerald_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/server/emerald"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/dshackle"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+// serverStream collects the messages of a server-streaming rpc
+type serverStream[T proto.Message] struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	mu        sync.RWMutex
+	responses []T
+}
+
+func newServerStream[T proto.Message]() *serverStream[T] {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &serverStream[T]{
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+func (s *serverStream[T]) SetHeader(metadata.MD) error  { return nil }
+func (s *serverStream[T]) SendHeader(metadata.MD) error { return nil }
+func (s *serverStream[T]) SetTrailer(metadata.MD)       {}
+func (s *serverStream[T]) Context() context.Context     { return s.ctx }
+func (s *serverStream[T]) SendMsg(any) error            { return nil }
+func (s *serverStream[T]) RecvMsg(any) error            { return nil }
+
+func (s *serverStream[T]) Send(resp T) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.responses = append(s.responses, proto.Clone(resp).(T))
+	return nil
+}
+
+func (s *serverStream[T]) Count() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return len(s.responses)
+}
+
+func (s *serverStream[T]) ResponseAt(index int) T {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.responses[index]
+}
+
+func TestSubscribeHead_NilSupervisorReturnsError(t *testing.T) {
+	err := emerald.SubscribeHead(nil, 100, newServerStream[*dshackle.ChainHead]())
+
+	require.Error(t, err)
+	assert.Equal(t, "chain supervisor cannot be nil", err.Error())
+}
+
+func TestSubscribeHead_SendsCurrentHeadThenNewHeads(t *testing.T) {
+	grpcId := chains.GetChain(chains.ARBITRUM.String()).GrpcId
+	state := newChainState(chains.ARBITRUM, protocol.NewBlockWithHeight(100), []string{"eth_call"})
+	state.Blocks[protocol.FinalizedBlock] = protocol.NewBlockWithHeight(90)
+	state.LowerBounds[protocol.StateBound] = protocol.LowerBoundData{Bound: 5, Type: protocol.StateBound}
+	chainSupervisor := newFakeChainSupervisor(chains.ARBITRUM, state)
+
+	stream := newServerStream[*dshackle.ChainHead]()
+	done := make(chan error, 1)
+	go func() {
+		done <- emerald.SubscribeHead(chainSupervisor, grpcId, stream)
+	}()
+
+	require.Eventually(t, func() bool {
+		return stream.Count() == 1
+	}, time.Second, 10*time.Millisecond)
+	head := stream.ResponseAt(0)
+	assert.Equal(t, dshackle.ChainRef(grpcId), head.Chain)
+	assert.Equal(t, uint64(100), head.Height)
+	require.Len(t, head.FinalizationData, 1)
+	assert.Equal(t, uint64(90), head.FinalizationData[0].Height)
+	assert.Equal(t, dshackle.FinalizationType_FINALIZATION_FINALIZED_BLOCK, head.FinalizationData[0].Type)
+	require.Len(t, head.LowerBounds, 1)
+	assert.Equal(t, uint64(5), head.LowerBounds[0].LowerBoundValue)
+
+	// only head events make a new head
+	chainSupervisor.PublishStateEvent(upstreams.NewStatusWrapper(protocol.Available))
+	newHead := protocol.NewBlockWithHeight(101)
+	state.HeadData = upstreams.NewChainHeadData(newHead, "up-1")
+	chainSupervisor.SetState(state)
+	chainSupervisor.PublishStateEvent(upstreams.NewHeadWrapper(newHead, "up-1"))
+
+	require.Eventually(t, func() bool {
+		return stream.Count() == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(101), stream.ResponseAt(1).Height)
+
+	stream.cancel()
+	require.NoError(t, <-done)
+}
+
+func TestSubscribeHead_WaitsForHead(t *testing.T) {
+	chainSupervisor := newFakeChainSupervisor(chains.ARBITRUM, newChainState(chains.ARBITRUM, protocol.Block{}, []string{"eth_call"}))
+
+	stream := newServerStream[*dshackle.ChainHead]()
+	done := make(chan error, 1)
+	go func() {
+		done <- emerald.SubscribeHead(chainSupervisor, 100, stream)
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 0, stream.Count())
+
+	head := protocol.NewBlockWithHeight(10)
+	chainSupervisor.SetState(newChainState(chains.ARBITRUM, head, []string{"eth_call"}))
+	chainSupervisor.PublishStateEvent(upstreams.NewHeadWrapper(head, "up-1"))
+
+	require.Eventually(t, func() bool {
+		return stream.Count() == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, uint64(10), stream.ResponseAt(0).Height)
+
+	stream.cancel()
+	require.NoError(t, <-done)
+}
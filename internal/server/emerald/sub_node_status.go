@@ -0,0 +1,209 @@
+package emerald
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/dshackle"
+	"github.com/drpcorg/nodecore/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+)
+
+// upstreams might be added and removed at runtime, there is no event for it, so the list is rescanned
+const defaultNodeStatusRescanInterval = 10 * time.Second
+
+// SubscribeNodeStatus streams the status of every upstream. The first response of an upstream carries its description,
+// the next ones only its head height and availability unless the upstream state has changed
+func SubscribeNodeStatus(
+	upstreamSupervisor upstreams.UpstreamSupervisor,
+	stream dshackle.Blockchain_SubscribeNodeStatusServer,
+) error {
+	return SubscribeNodeStatusWithRescan(upstreamSupervisor, stream, defaultNodeStatusRescanInterval)
+}
+
+// SubscribeNodeStatusWithRescan is SubscribeNodeStatus with a caller-chosen interval of rescanning the upstreams
+func SubscribeNodeStatusWithRescan(
+	upstreamSupervisor upstreams.UpstreamSupervisor,
+	stream dshackle.Blockchain_SubscribeNodeStatusServer,
+	rescanInterval time.Duration,
+) error {
+	if upstreamSupervisor == nil {
+		return errNilUpstreamSupervisor
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	responses := make(chan *dshackle.NodeStatusResponse, 100)
+	upstreamSubs := make(map[string]*utils.Subscription[protocol.UpstreamEvent])
+	defer func() {
+		for _, sub := range upstreamSubs {
+			sub.Unsubscribe()
+		}
+	}()
+
+	rescan := func() error {
+		currentIds := make(map[string]struct{})
+		for _, upstream := range upstreamSupervisor.GetUpstreams() {
+			currentIds[upstream.GetId()] = struct{}{}
+			subscribeUpstreamStatuses(ctx, upstream, upstreamSubs, responses)
+		}
+		for upstreamId, sub := range upstreamSubs {
+			if _, ok := currentIds[upstreamId]; ok {
+				continue
+			}
+			sub.Unsubscribe()
+			delete(upstreamSubs, upstreamId)
+			if err := sendNodeStatus(stream, removedNodeStatusToApi(upstreamId)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	if err := rescan(); err != nil {
+		return err
+	}
+
+	rescanTicker := time.NewTicker(rescanInterval)
+	defer rescanTicker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-rescanTicker.C:
+			if err := rescan(); err != nil {
+				return err
+			}
+		case response := <-responses:
+			if err := sendNodeStatus(stream, response); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func sendNodeStatus(stream dshackle.Blockchain_SubscribeNodeStatusServer, response *dshackle.NodeStatusResponse) error {
+	if err := stream.Send(response); err != nil {
+		log.Error().Err(err).Msgf("failed to send a NodeStatusResponse")
+		return err
+	}
+	return nil
+}
+
+func subscribeUpstreamStatuses(
+	ctx context.Context,
+	upstream upstreams.Upstream,
+	upstreamSubs map[string]*utils.Subscription[protocol.UpstreamEvent],
+	responses chan *dshackle.NodeStatusResponse,
+) {
+	if _, exists := upstreamSubs[upstream.GetId()]; exists {
+		return
+	}
+	upstreamEventsSub := upstream.Subscribe(fmt.Sprintf("node_status_%s_%s", upstream.GetId(), uuid.NewString()))
+	upstreamSubs[upstream.GetId()] = upstreamEventsSub
+
+	go func() {
+		send := func(response *dshackle.NodeStatusResponse) bool {
+			select {
+			case <-ctx.Done():
+				return false
+			case responses <- response:
+				return true
+			}
+		}
+
+		state := upstream.GetUpstreamState()
+		lastStatus := nodeStatusToApi(state.Status, state.HeadData)
+		if !send(nodeStatusResponseToApi(upstream, state, lastStatus)) {
+			return
+		}
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-upstreamEventsSub.Events:
+				if !ok {
+					return
+				}
+				var response *dshackle.NodeStatusResponse
+				switch e := event.EventType.(type) {
+				case *protocol.StateUpstreamEvent:
+					lastStatus = nodeStatusToApi(e.State.Status, e.State.HeadData)
+					response = nodeStatusResponseToApi(upstream, *e.State, lastStatus)
+				case *protocol.ValidUpstreamEvent:
+					lastStatus = nodeStatusToApi(e.State.Status, e.State.HeadData)
+					response = nodeStatusResponseToApi(upstream, *e.State, lastStatus)
+				case *protocol.HeadUpstreamEvent:
+					status := nodeStatusToApi(e.Status, e.Head)
+					if status.CurrentHeight == lastStatus.CurrentHeight && status.Availability == lastStatus.Availability {
+						continue
+					}
+					lastStatus = status
+					response = &dshackle.NodeStatusResponse{NodeId: upstream.GetId(), Status: status}
+				case *protocol.RemoveUpstreamEvent:
+					response = removedNodeStatusToApi(upstream.GetId())
+					lastStatus = response.Status
+				default:
+					continue
+				}
+				if !send(response) {
+					return
+				}
+			}
+		}
+	}()
+}
+
+func nodeStatusResponseToApi(upstream upstreams.Upstream, state protocol.UpstreamState, status *dshackle.NodeStatus) *dshackle.NodeStatusResponse {
+	return &dshackle.NodeStatusResponse{
+		NodeId:      upstream.GetId(),
+		Description: nodeDescriptionToApi(upstream, state),
+		Status:      status,
+	}
+}
+
+func nodeDescriptionToApi(upstream upstreams.Upstream, state protocol.UpstreamState) *dshackle.NodeDescription {
+	supportedMethods := make([]string, 0)
+	if state.UpstreamMethods != nil {
+		supportedMethods = state.UpstreamMethods.GetSupportedMethods().ToSlice()
+		slices.Sort(supportedMethods)
+	}
+	nodeLabels := make([]*dshackle.NodeLabels, 0)
+	if state.Labels != nil {
+		if labels := state.Labels.GetAllLabels(); len(labels) > 0 {
+			nodeLabels = append(nodeLabels, &dshackle.NodeLabels{Labels: labelsToApi(labels)})
+		}
+	}
+	// the hash index is the hex of the upstream index which is unique across all upstreams
+	nodeId, _ := strconv.ParseInt(upstream.GetHashIndex(), 16, 32)
+
+	return &dshackle.NodeDescription{
+		Chain:            dshackle.ChainRef(chains.GetChain(upstream.GetChain().String()).GrpcId),
+		NodeLabels:       nodeLabels,
+		SupportedMethods: supportedMethods,
+		NodeId:           int32(nodeId),
+	}
+}
+
+func nodeStatusToApi(status protocol.AvailabilityStatus, head protocol.Block) *dshackle.NodeStatus {
+	return &dshackle.NodeStatus{
+		CurrentHeight: int64(head.Height),
+		Availability:  availabilityStatusToApi(status),
+	}
+}
+
+func removedNodeStatusToApi(upstreamId string) *dshackle.NodeStatusResponse {
+	return &dshackle.NodeStatusResponse{
+		NodeId: upstreamId,
+		Status: nodeStatusToApi(protocol.Unavailable, protocol.ZeroBlock{}),
+	}
+}
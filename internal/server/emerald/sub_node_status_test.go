@@ -0,0 +1,144 @@
+package emerald_test
+
+import (
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/server/emerald"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/dshackle"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/drpcorg/nodecore/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeUpstream implements only the methods node statuses need
+type fakeUpstream struct {
+	upstreams.Upstream
+	id         string
+	state      protocol.UpstreamState
+	subManager *utils.SubscriptionManager[protocol.UpstreamEvent]
+}
+
+func newFakeUpstream(id string, state protocol.UpstreamState) *fakeUpstream {
+	return &fakeUpstream{
+		id:         id,
+		state:      state,
+		subManager: utils.NewSubscriptionManager[protocol.UpstreamEvent]("fake-upstream"),
+	}
+}
+
+func (u *fakeUpstream) GetId() string                            { return u.id }
+func (u *fakeUpstream) GetChain() chains.Chain                   { return chains.ETHEREUM }
+func (u *fakeUpstream) GetHashIndex() string                     { return "0000a" }
+func (u *fakeUpstream) GetUpstreamState() protocol.UpstreamState { return u.state }
+func (u *fakeUpstream) Subscribe(name string) *utils.Subscription[protocol.UpstreamEvent] {
+	return u.subManager.Subscribe(name)
+}
+
+func (u *fakeUpstream) publish(eventType protocol.UpstreamEventType) {
+	u.subManager.Publish(protocol.UpstreamEvent{Id: u.id, Chain: chains.ETHEREUM, EventType: eventType})
+}
+
+func newUpstreamState(status protocol.AvailabilityStatus, height uint64, methods ...string) protocol.UpstreamState {
+	state := protocol.DefaultUpstreamState(newMethodsMockWithSupported(methods...), mapset.NewThreadUnsafeSet[protocol.Cap](), "0000a", nil, nil)
+	state.Status = status
+	state.HeadData = protocol.NewBlockWithHeight(height)
+	return state
+}
+
+func TestSubscribeNodeStatus_NilSupervisorReturnsError(t *testing.T) {
+	err := emerald.SubscribeNodeStatus(nil, newServerStream[*dshackle.NodeStatusResponse]())
+
+	require.Error(t, err)
+	assert.Equal(t, "upstream supervisor cannot be nil", err.Error())
+}
+
+func TestSubscribeNodeStatus_SendsDescriptionThenStatusChanges(t *testing.T) {
+	state := newUpstreamState(protocol.Available, 100, "eth_getBalance", "eth_call")
+	state.Labels.AddLabel("client_type", "geth")
+	upstream := newFakeUpstream("up-1", state)
+
+	upstreamSupervisor := mocks.NewUpstreamSupervisorMock()
+	upstreamSupervisor.On("GetUpstreams").Return([]upstreams.Upstream{upstream})
+
+	stream := newServerStream[*dshackle.NodeStatusResponse]()
+	done := make(chan error, 1)
+	go func() {
+		done <- emerald.SubscribeNodeStatus(upstreamSupervisor, stream)
+	}()
+
+	require.Eventually(t, func() bool {
+		return stream.Count() == 1
+	}, time.Second, 10*time.Millisecond)
+	response := stream.ResponseAt(0)
+	assert.Equal(t, "up-1", response.NodeId)
+	require.NotNil(t, response.Description)
+	assert.Equal(t, dshackle.ChainRef(chains.GetChain(chains.ETHEREUM.String()).GrpcId), response.Description.Chain)
+	assert.Equal(t, int32(10), response.Description.NodeId)
+	assert.Equal(t, []string{"eth_call", "eth_getBalance"}, response.Description.SupportedMethods)
+	require.Len(t, response.Description.NodeLabels, 1)
+	require.Len(t, response.Description.NodeLabels[0].Labels, 1)
+	assert.Equal(t, "client_type", response.Description.NodeLabels[0].Labels[0].Name)
+	assert.Equal(t, int64(100), response.Status.CurrentHeight)
+	assert.Equal(t, dshackle.AvailabilityEnum_AVAIL_OK, response.Status.Availability)
+
+	// the same head isn't sent again
+	upstream.publish(&protocol.HeadUpstreamEvent{Status: protocol.Available, Head: protocol.NewBlockWithHeight(100)})
+	upstream.publish(&protocol.HeadUpstreamEvent{Status: protocol.Available, Head: protocol.NewBlockWithHeight(101)})
+
+	require.Eventually(t, func() bool {
+		return stream.Count() == 2
+	}, time.Second, 10*time.Millisecond)
+	response = stream.ResponseAt(1)
+	assert.Nil(t, response.Description)
+	assert.Equal(t, int64(101), response.Status.CurrentHeight)
+
+	newState := newUpstreamState(protocol.Syncing, 101, "eth_call")
+	upstream.publish(&protocol.StateUpstreamEvent{State: &newState})
+
+	require.Eventually(t, func() bool {
+		return stream.Count() == 3
+	}, time.Second, 10*time.Millisecond)
+	response = stream.ResponseAt(2)
+	assert.Equal(t, []string{"eth_call"}, response.Description.SupportedMethods)
+	assert.Equal(t, dshackle.AvailabilityEnum_AVAIL_SYNCING, response.Status.Availability)
+
+	stream.cancel()
+	require.NoError(t, <-done)
+}
+
+func TestSubscribeNodeStatus_RescansAddedAndRemovedUpstreams(t *testing.T) {
+	first := newFakeUpstream("up-1", newUpstreamState(protocol.Available, 100))
+	second := newFakeUpstream("up-2", newUpstreamState(protocol.Available, 100))
+
+	upstreamSupervisor := mocks.NewUpstreamSupervisorMock()
+	upstreamSupervisor.On("GetUpstreams").Return([]upstreams.Upstream{first}).Once()
+	upstreamSupervisor.On("GetUpstreams").Return([]upstreams.Upstream{second})
+
+	stream := newServerStream[*dshackle.NodeStatusResponse]()
+	done := make(chan error, 1)
+	go func() {
+		done <- emerald.SubscribeNodeStatusWithRescan(upstreamSupervisor, stream, 20*time.Millisecond)
+	}()
+
+	require.Eventually(t, func() bool {
+		return stream.Count() == 3
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, "up-1", stream.ResponseAt(0).NodeId)
+	statuses := map[string]*dshackle.NodeStatusResponse{
+		stream.ResponseAt(1).NodeId: stream.ResponseAt(1),
+		stream.ResponseAt(2).NodeId: stream.ResponseAt(2),
+	}
+	require.Contains(t, statuses, "up-1")
+	assert.Equal(t, dshackle.AvailabilityEnum_AVAIL_UNAVAILABLE, statuses["up-1"].Status.Availability)
+	require.Contains(t, statuses, "up-2")
+	assert.NotNil(t, statuses["up-2"].Description)
+
+	stream.cancel()
+	require.NoError(t, <-done)
+}
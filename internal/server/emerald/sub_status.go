@@ -0,0 +1,145 @@
+package emerald
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/dshackle"
+	"github.com/drpcorg/nodecore/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog/log"
+	"google.golang.org/protobuf/proto"
+)
+
+// SubscribeStatus streams the availability and the quorum of the requested chains, of all the chains if none are requested.
+// The current status is sent first, then only its changes
+func SubscribeStatus(
+	upstreamSupervisor upstreams.UpstreamSupervisor,
+	chainRefs []dshackle.ChainRef,
+	stream dshackle.Blockchain_SubscribeStatusServer,
+) error {
+	return SubscribeStatusWithResync(upstreamSupervisor, chainRefs, stream, defaultChainStateResyncInterval)
+}
+
+// SubscribeStatusWithResync is SubscribeStatus with a caller-chosen interval of checking the status,
+// the quorum might change without any chain state event
+func SubscribeStatusWithResync(
+	upstreamSupervisor upstreams.UpstreamSupervisor,
+	chainRefs []dshackle.ChainRef,
+	stream dshackle.Blockchain_SubscribeStatusServer,
+	resyncInterval time.Duration,
+) error {
+	if upstreamSupervisor == nil {
+		return errNilUpstreamSupervisor
+	}
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+
+	requestedChains := mapset.NewThreadUnsafeSet(chainRefs...)
+	responses := make(chan *dshackle.ChainStatus, 100)
+	chainSubs := make(map[chains.Chain]*utils.Subscription[*upstreams.ChainSupervisorStateWrapperEvent])
+	chainSupervisorEventsSub := upstreamSupervisor.SubscribeChainSupervisor(fmt.Sprintf("status_%s", uuid.NewString()))
+	defer func() {
+		chainSupervisorEventsSub.Unsubscribe()
+		for _, sub := range chainSubs {
+			sub.Unsubscribe()
+		}
+	}()
+
+	subscribe := func(chainSupervisor upstreams.ChainSupervisor) {
+		if chainSupervisor == nil {
+			return
+		}
+		grpcId := chains.GetChain(chainSupervisor.GetChain().String()).GrpcId
+		if !requestedChains.IsEmpty() && !requestedChains.ContainsOne(dshackle.ChainRef(grpcId)) {
+			return
+		}
+		subscribeChainStatuses(ctx, chainSupervisor, grpcId, chainSubs, responses, resyncInterval)
+	}
+
+	for _, chainSupervisor := range upstreamSupervisor.GetChainSupervisors() {
+		subscribe(chainSupervisor)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case chainSupervisorEvent, ok := <-chainSupervisorEventsSub.Events:
+			if ok {
+				switch c := chainSupervisorEvent.(type) {
+				case *upstreams.AddChainSupervisorEvent:
+					subscribe(c.ChainSupervisor)
+				}
+			}
+		case response := <-responses:
+			if err := stream.Send(response); err != nil {
+				log.Error().Err(err).Msgf("failed to send a ChainStatus")
+				return err
+			}
+		}
+	}
+}
+
+func subscribeChainStatuses(
+	ctx context.Context,
+	chainSupervisor upstreams.ChainSupervisor,
+	grpcId int,
+	chainSubs map[chains.Chain]*utils.Subscription[*upstreams.ChainSupervisorStateWrapperEvent],
+	responses chan *dshackle.ChainStatus,
+	resyncInterval time.Duration,
+) {
+	if _, exists := chainSubs[chainSupervisor.GetChain()]; exists {
+		return
+	}
+	chainSupervisorStatesSub := chainSupervisor.SubscribeState(
+		fmt.Sprintf("chain_supervisor_statuses_%s_%s", chainSupervisor.GetChain(), uuid.NewString()),
+	)
+	chainSubs[chainSupervisor.GetChain()] = chainSupervisorStatesSub
+
+	go func() {
+		var lastStatus *dshackle.ChainStatus
+		sendStatus := func() bool {
+			chainStatus := chainStatusToApi(grpcId, chainSupervisor.GetChainState().Status, chainQuorum(chainSupervisor))
+			if lastStatus != nil && proto.Equal(lastStatus, chainStatus) {
+				return true
+			}
+			select {
+			case <-ctx.Done():
+				return false
+			case responses <- chainStatus:
+				lastStatus = chainStatus
+				return true
+			}
+		}
+
+		if !sendStatus() {
+			return
+		}
+
+		resyncTicker := time.NewTicker(resyncInterval)
+		defer resyncTicker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-resyncTicker.C:
+				if !sendStatus() {
+					return
+				}
+			case _, ok := <-chainSupervisorStatesSub.Events:
+				if !ok {
+					return
+				}
+				if !sendStatus() {
+					return
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,99 @@
+package emerald_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/server/emerald"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/dshackle"
+	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/drpcorg/nodecore/pkg/utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubscribeStatus_NilSupervisorReturnsError(t *testing.T) {
+	err := emerald.SubscribeStatus(nil, nil, newServerStream[*dshackle.ChainStatus]())
+
+	require.Error(t, err)
+	assert.Equal(t, "upstream supervisor cannot be nil", err.Error())
+}
+
+func TestSubscribeStatus_SendsRequestedChainsThenChanges(t *testing.T) {
+	methodsMock := newMethodsMockWithSupported("eth_call")
+	ethSupervisor := newStartedChainSupervisor(
+		t,
+		chains.ETHEREUM,
+		test_utils.CreateEvent("up-1", protocol.Available, protocol.NewBlockWithHeight(100), methodsMock),
+		test_utils.CreateEvent("up-2", protocol.Available, protocol.NewBlockWithHeight(100), methodsMock),
+	)
+	polygonSupervisor := newFakeChainSupervisor(chains.POLYGON, newChainState(chains.POLYGON, protocol.NewBlockWithHeight(10), nil))
+	ethGrpcId := dshackle.ChainRef(chains.GetChain(chains.ETHEREUM.String()).GrpcId)
+
+	manager := utils.NewSubscriptionManager[upstreams.ChainSupervisorEvent]("chain-supervisors")
+	upstreamSupervisor := mocks.NewUpstreamSupervisorMock()
+	upstreamSupervisor.On("SubscribeChainSupervisor", mock.Anything).Return(manager.Subscribe("sub"))
+	upstreamSupervisor.On("GetChainSupervisors").Return([]upstreams.ChainSupervisor{ethSupervisor, polygonSupervisor})
+
+	stream := newServerStream[*dshackle.ChainStatus]()
+	done := make(chan error, 1)
+	go func() {
+		done <- emerald.SubscribeStatusWithResync(upstreamSupervisor, []dshackle.ChainRef{ethGrpcId}, stream, 20*time.Millisecond)
+	}()
+
+	require.Eventually(t, func() bool {
+		return stream.Count() == 1
+	}, time.Second, 10*time.Millisecond)
+	status := stream.ResponseAt(0)
+	assert.Equal(t, ethGrpcId, status.Chain)
+	assert.Equal(t, dshackle.AvailabilityEnum_AVAIL_OK, status.Availability)
+	assert.Equal(t, uint32(2), status.Quorum)
+
+	// an unchanged status isn't sent again
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, 1, stream.Count())
+
+	ethSupervisor.PublishUpstreamEvent(test_utils.CreateEvent("up-2", protocol.Unavailable, protocol.NewBlockWithHeight(100), methodsMock))
+
+	require.Eventually(t, func() bool {
+		return stream.Count() == 2
+	}, time.Second, 10*time.Millisecond)
+	status = stream.ResponseAt(1)
+	assert.Equal(t, dshackle.AvailabilityEnum_AVAIL_OK, status.Availability)
+	assert.Equal(t, uint32(1), status.Quorum)
+
+	stream.cancel()
+	require.NoError(t, <-done)
+}
+
+func TestSubscribeStatus_WithoutChainsSubscribesAllChains(t *testing.T) {
+	manager := utils.NewSubscriptionManager[upstreams.ChainSupervisorEvent]("chain-supervisors")
+	upstreamSupervisor := mocks.NewUpstreamSupervisorMock()
+	upstreamSupervisor.On("SubscribeChainSupervisor", mock.Anything).Return(manager.Subscribe("sub"))
+	upstreamSupervisor.On("GetChainSupervisors").Return([]upstreams.ChainSupervisor{})
+
+	stream := newServerStream[*dshackle.ChainStatus]()
+	done := make(chan error, 1)
+	go func() {
+		done <- emerald.SubscribeStatus(upstreamSupervisor, nil, stream)
+	}()
+
+	state := newChainState(chains.POLYGON, protocol.NewBlockWithHeight(10), nil)
+	state.Status = protocol.Syncing
+	manager.Publish(&upstreams.AddChainSupervisorEvent{ChainSupervisor: newFakeChainSupervisor(chains.POLYGON, state)})
+
+	require.Eventually(t, func() bool {
+		return stream.Count() == 1
+	}, time.Second, 10*time.Millisecond)
+	status := stream.ResponseAt(0)
+	assert.Equal(t, dshackle.ChainRef(chains.GetChain(chains.POLYGON.String()).GrpcId), status.Chain)
+	assert.Equal(t, dshackle.AvailabilityEnum_AVAIL_SYNCING, status.Availability)
+
+	stream.cancel()
+	require.NoError(t, <-done)
+}
@@ -6,6 +6,8 @@ import (
 	"net/http/httptest"
 	"testing"
 
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/upstreams"
 	"github.com/drpcorg/nodecore/pkg/chains"
@@ -78,10 +80,15 @@ func (h *healthSupervisorStub) GetChainSupervisor(chain chains.Chain) upstreams.
 }
 func (h *healthSupervisorStub) GetChainSupervisors() []upstreams.ChainSupervisor { return h.chains }
 func (h *healthSupervisorStub) GetUpstream(string) upstreams.Upstream            { return nil }
-func (h *healthSupervisorStub) GetExecutor() failsafe.Executor[*protocol.ResponseHolderWrapper] {
+func (h *healthSupervisorStub) GetUpstreams() []upstreams.Upstream               { return nil }
+func (h *healthSupervisorStub) GetExecutor(chains.Chain, string) failsafe.Executor[*protocol.ResponseHolderWrapper] {
 	return nil
 }
 func (h *healthSupervisorStub) StartUpstreams() {}
+func (h *healthSupervisorStub) UpdateUpstreams(*config.UpstreamConfig, mapset.Set[string]) {
+}
+func (h *healthSupervisorStub) AddUpstream(*config.Upstream) error { return nil }
+func (h *healthSupervisorStub) RemoveUpstream(string) error        { return nil }
 func (h *healthSupervisorStub) SubscribeChainSupervisor(name string) *utils.Subscription[upstreams.ChainSupervisorEvent] {
 	return nil
 }
@@ -0,0 +1,30 @@
+package http_server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/drpcorg/nodecore/internal/auth"
+	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/drpcorg/nodecore/internal/server/server_ctx"
+)
+
+const cacheControlQueryParam = "cache-control"
+
+// cacheControlFromHttpRequest parses the cache control of a request, the header takes precedence over the query param
+func cacheControlFromHttpRequest(req *http.Request) (caches.CacheControl, error) {
+	value := req.Header.Get("Cache-Control")
+	if value == "" {
+		value = req.URL.Query().Get(cacheControlQueryParam)
+	}
+	return caches.ParseCacheControl(value)
+}
+
+// withKeyCacheControl drops the cache control of the request if its api-key doesn't allow it
+func withKeyCacheControl(ctx context.Context, appCtx *server_ctx.ApplicationServerContext, authPayload auth.AuthPayload) context.Context {
+	resolver, ok := appCtx.AuthProcessor.(auth.KeyCacheControlResolver)
+	if !ok || resolver.CacheControlAllowed(authPayload) {
+		return ctx
+	}
+	return caches.WithoutCacheControl(ctx)
+}
@@ -0,0 +1,121 @@
+package http_server_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/auth"
+	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/drpcorg/nodecore/internal/server/http_server"
+	servernodecore "github.com/drpcorg/nodecore/internal/server/server_ctx"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// cacheControlAuthProcessor is an auth processor of a key that allows or denies the cache control
+type cacheControlAuthProcessor struct {
+	*mocks.MockAuthProcessor
+	allowed bool
+}
+
+func (c *cacheControlAuthProcessor) CacheControlAllowed(_ auth.AuthPayload) bool {
+	return c.allowed
+}
+
+// sendWithCacheControl sends a request and returns the cache control its upstream request is processed with
+func sendWithCacheControl(t *testing.T, authProc auth.AuthProcessor, mockAuthProc *mocks.MockAuthProcessor, path string, header string) (caches.CacheControl, bool) {
+	t.Helper()
+
+	upSup := mocks.NewUpstreamSupervisorMock()
+	appCtx := servernodecore.NewApplicationServerContext(upSup, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	ts := httptest.NewServer(http_server.NewHttpServer(context.Background(), appCtx))
+	defer ts.Close()
+
+	var cacheControl caches.CacheControl
+	var ok bool
+	mockAuthProc.On("Authenticate", mock.Anything, mock.Anything).Return(nil)
+	mockAuthProc.On("PreKeyValidate", mock.Anything, mock.Anything).Return(nil, nil)
+	mockAuthProc.On("PostKeyValidate", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			cacheControl, ok = caches.CacheControlFromContext(args.Get(0).(context.Context))
+		}).
+		Return(errors.New("stop"))
+	upSup.On("GetChainSupervisor", chains.POLYGON).Return(test_utils.CreateChainSupervisor())
+
+	body := `{"jsonrpc" : "2.0","id" : 42,"method" : "eth_chainId"}`
+	req, err := http.NewRequest(http.MethodPost, ts.URL+path, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	if header != "" {
+		req.Header.Set("Cache-Control", header)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	return cacheControl, ok
+}
+
+func TestHttpServerCacheControlFromHeader(t *testing.T) {
+	authProc := mocks.NewMockAuthProcessor()
+
+	cacheControl, ok := sendWithCacheControl(t, authProc, authProc, "/queries/polygon?cache-control=no-store", "no-cache, max-age=10")
+
+	assert.True(t, ok)
+	assert.Equal(t, caches.CacheControl{NoCache: true, MaxAge: 10 * time.Second}, cacheControl)
+}
+
+func TestHttpServerCacheControlFromQuery(t *testing.T) {
+	authProc := mocks.NewMockAuthProcessor()
+
+	cacheControl, ok := sendWithCacheControl(t, authProc, authProc, "/queries/polygon?cache-control=no-store", "")
+
+	assert.True(t, ok)
+	assert.Equal(t, caches.CacheControl{NoStore: true}, cacheControl)
+}
+
+func TestHttpServerCacheControlOfKey(t *testing.T) {
+	for _, allowed := range []bool{true, false} {
+		mockAuthProc := mocks.NewMockAuthProcessor()
+		authProc := &cacheControlAuthProcessor{MockAuthProcessor: mockAuthProc, allowed: allowed}
+
+		_, ok := sendWithCacheControl(t, authProc, mockAuthProc, "/queries/polygon", "no-cache")
+
+		assert.Equal(t, allowed, ok)
+	}
+}
+
+func TestHttpServerInvalidCacheControlThenErr(t *testing.T) {
+	authProc := mocks.NewMockAuthProcessor()
+	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	ts := httptest.NewServer(http_server.NewHttpServer(context.Background(), appCtx))
+	defer ts.Close()
+
+	authProc.On("Authenticate", mock.Anything, mock.Anything).Return(nil)
+
+	body := `{"jsonrpc" : "2.0","id" : 42,"method" : "eth_chainId"}`
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/queries/polygon", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	req.Header.Set("Cache-Control", "max-age=soon")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(
+		t,
+		`{"id":0,"jsonrpc":"2.0","error":{"message":"client error - invalid cache control: invalid max-age 'soon'","code":400}}`,
+		string(respBody),
+	)
+}
@@ -68,7 +68,7 @@ func NewRestHandler(preReq *Request, req *http.Request, restPath string) (*RestH
 	}, nil
 }
 
-func (r *RestHandler) RequestDecode(_ context.Context) (*Request, error) {
+func (r *RestHandler) RequestDecode(ctx context.Context) (*Request, error) {
 	specName := chains.GetMethodSpecNameByChainName(r.preReq.Chain)
 	upstreamReq := protocol.NewUpstreamRestRequest(
 		"1",
@@ -76,6 +76,7 @@ func (r *RestHandler) RequestDecode(_ context.Context) (*Request, error) {
 		r.requestParams,
 		r.requestBody,
 		specName,
+		requestSelectorsFromContext(ctx)...,
 	)
 	return &Request{
 		Chain:            r.preReq.Chain,
@@ -177,6 +178,7 @@ func (j *JsonRpcHandler) RequestCount() int {
 
 func (j *JsonRpcHandler) RequestDecode(ctx context.Context) (*Request, error) {
 	upstreamRequests := make([]protocol.RequestHolder, 0)
+	selectors := requestSelectorsFromContext(ctx)
 
 	for i, jsonRpcReq := range j.jsonRpcRequests {
 		id, err := uuid.NewUUID()
@@ -190,9 +192,9 @@ func (j *JsonRpcHandler) RequestDecode(ctx context.Context) (*Request, error) {
 
 		var upstreamReq protocol.RequestHolder
 		if protocol.IsStream(jsonRpcReq.Method) { // for tests
-			upstreamReq = protocol.NewStreamUpstreamJsonRpcRequest(id.String(), jsonRpcReq, specName)
+			upstreamReq = protocol.NewStreamUpstreamJsonRpcRequest(id.String(), jsonRpcReq, specName, selectors...)
 		} else {
-			upstreamReq = protocol.NewUpstreamJsonRpcRequest(id.String(), jsonRpcReq, isSub, specName)
+			upstreamReq = protocol.NewUpstreamJsonRpcRequest(id.String(), jsonRpcReq, isSub, specName, selectors...)
 		}
 		upstreamRequests = append(upstreamRequests, upstreamReq)
 	}
@@ -2,10 +2,13 @@ package http_server
 
 import (
 	"context"
+	"fmt"
 	"io"
+	"math"
 	"net"
 	"net/http"
 	"net/netip"
+	"strconv"
 	"time"
 	"unicode/utf8"
 
@@ -15,10 +18,13 @@ import (
 	"github.com/bytedance/sonic/decoder"
 	"github.com/bytedance/sonic/encoder"
 	"github.com/drpcorg/nodecore/internal/auth"
+	"github.com/drpcorg/nodecore/internal/caches"
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/dimensions"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/quorum"
+	"github.com/drpcorg/nodecore/internal/ratelimiter"
+	"github.com/drpcorg/nodecore/internal/tracing"
 	"github.com/drpcorg/nodecore/internal/upstreams/flow"
 	"github.com/drpcorg/nodecore/pkg/chains"
 	"github.com/drpcorg/nodecore/pkg/utils"
@@ -28,6 +34,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog/log"
 	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/trace"
 )
 
 var requestTimeToLastByte = prometheus.NewHistogram(
@@ -46,6 +53,8 @@ func init() {
 type HandleResponse struct {
 	responseWrappers chan *protocol.ResponseHolderWrapper
 	corsOrigins      []string
+	// rateLimitQuota is the state of the most restrictive inbound rate limit applied to the request
+	rateLimitQuota *ratelimiter.RateLimitQuota
 }
 
 func NewHandleResponse(responseWrappers chan *protocol.ResponseHolderWrapper, corsOrigins []string) *HandleResponse {
@@ -111,7 +120,8 @@ func NewHttpServer(ctx context.Context, appCtx *server_ctx.ApplicationServerCont
 		c.Request().SetPathValue("key", c.Param("key"))
 		chain := c.Param("chain")
 		restPath := c.Param("*") // for rest requests
-		reqCtx := utils.ContextWithIps(c.Request().Context(), c.Request(), trustedProxies)
+		reqCtx := tracing.ExtractHttp(c.Request().Context(), c.Request().Header)
+		reqCtx = utils.ContextWithIps(reqCtx, c.Request(), trustedProxies)
 		reqCtx = quorum.WithParams(reqCtx, quorum.ParamsFromQuery(c.Request().URL.Query()))
 		// An empty rest path with a GET is a REST call on the API root: a
 		// JSON-RPC request is always a POST, so nothing legitimate is
@@ -131,9 +141,15 @@ func NewHttpServer(ctx context.Context, appCtx *server_ctx.ApplicationServerCont
 			protocol.Rest,
 			protocol.JsonRpc,
 		)
+		reqCtx, span := tracing.StartServerSpan(
+			reqCtx,
+			lo.Ternary(isWsUpgrade, "ws.connection", "http.request"),
+			tracing.ChainKey.String(chain),
+		)
+		defer span.End()
 		authPayload := auth.NewHttpAuthPayload(c.Request())
 
-		err := appCtx.AuthProcessor.Authenticate(c.Request().Context(), authPayload)
+		err := authenticate(reqCtx, appCtx, authPayload)
 		if err != nil {
 			resp := protocol.NewTotalFailureFromErr("0", protocol.AuthError(err), reqType)
 			return writeResponse(
@@ -158,6 +174,30 @@ func NewHttpServer(ctx context.Context, appCtx *server_ctx.ApplicationServerCont
 			)
 		}
 
+		// a selector and a cache control are parsed before the upgrade as well, so a ws client learns about
+		// a malformed one on the handshake, they then apply to every request of the connection
+		selector, err := selectorFromHttpRequest(c.Request())
+		if err != nil {
+			resp := protocol.NewTotalFailureFromErr("0", protocol.ClientError(fmt.Errorf("invalid selector: %w", err)), reqType)
+			return writeResponse(
+				c.Response(),
+				protocol.ToHttpCode(resp),
+				resp.EncodeResponse([]byte("0")),
+			)
+		}
+		reqCtx = withRequestSelector(reqCtx, selector)
+
+		cacheControl, err := cacheControlFromHttpRequest(c.Request())
+		if err != nil {
+			resp := protocol.NewTotalFailureFromErr("0", protocol.ClientError(fmt.Errorf("invalid cache control: %w", err)), reqType)
+			return writeResponse(
+				c.Response(),
+				protocol.ToHttpCode(resp),
+				resp.EncodeResponse([]byte("0")),
+			)
+		}
+		reqCtx = caches.WithCacheControl(reqCtx, cacheControl)
+
 		if isWsUpgrade {
 			conn, err := upgrader.Upgrade(c.Response().Writer, c.Request(), nil)
 			if err != nil {
@@ -180,13 +220,34 @@ func NewHttpServer(ctx context.Context, appCtx *server_ctx.ApplicationServerCont
 	return httpServer
 }
 
+func authenticate(ctx context.Context, appCtx *server_ctx.ApplicationServerContext, authPayload auth.AuthPayload) error {
+	ctx, span := tracing.StartSpan(ctx, "auth.authenticate")
+	defer span.End()
+
+	err := appCtx.AuthProcessor.Authenticate(ctx, authPayload)
+	tracing.SetError(span, err)
+	return err
+}
+
+// withKeySelector applies the default selector of the api-key if the request doesn't carry its own one
+func withKeySelector(ctx context.Context, appCtx *server_ctx.ApplicationServerContext, authPayload auth.AuthPayload) context.Context {
+	if requestSelectorFromContext(ctx) != nil {
+		return ctx
+	}
+	resolver, ok := appCtx.AuthProcessor.(auth.KeySelectorResolver)
+	if !ok {
+		return ctx
+	}
+	return withRequestSelector(ctx, resolver.GetKeySelector(authPayload))
+}
+
 // trustedProxiesFromConfig safely extracts the parsed trusted-proxy prefixes,
 // tolerating a nil app/server config.
 func trustedProxiesFromConfig(appCtx *server_ctx.ApplicationServerContext) []netip.Prefix {
-	if appCtx == nil || appCtx.AppConfig == nil {
+	if appCtx == nil || appCtx.AppConfig() == nil {
 		return nil
 	}
-	return appCtx.AppConfig.ServerConfig.TrustedProxyPrefixes()
+	return appCtx.AppConfig().ServerConfig.TrustedProxyPrefixes()
 }
 
 var corsHeaders = []lo.Tuple2[string, string]{
@@ -250,11 +311,15 @@ func handleResponse(
 	var responseReader io.Reader
 	code := http.StatusOK
 	httpResponse := reqCtx.Response()
+	setRateLimitHeaders(httpResponse.Header(), handleResp.rateLimitQuota)
 	if !requestHandler.IsSingle() {
 		responses := utils.Map(handleResp.responseWrappers, func(wrapper *protocol.ResponseHolderWrapper) *Response {
 			return requestHandler.ResponseEncode(wrapper.Response)
 		})
 		responseReader = ArraySortingStream(ctx, responses, requestHandler.RequestCount())
+		if handleResp.rateLimitQuota != nil && !handleResp.rateLimitQuota.Allowed {
+			code = http.StatusTooManyRequests
+		}
 	} else {
 		select {
 		case <-ctx.Done():
@@ -297,6 +362,21 @@ func copyUpstreamResponseHeaders(dst http.Header, response protocol.ResponseHold
 	}
 }
 
+// setRateLimitHeaders reports the quota of the most restrictive inbound rate limit,
+// Retry-After is added if the request has been rejected
+func setRateLimitHeaders(header http.Header, quota *ratelimiter.RateLimitQuota) {
+	if quota == nil {
+		return
+	}
+	resetSeconds := strconv.Itoa(int(math.Ceil(quota.Reset.Seconds())))
+	header.Set("X-RateLimit-Limit", strconv.Itoa(quota.Limit))
+	header.Set("X-RateLimit-Remaining", strconv.Itoa(quota.Remaining))
+	header.Set("X-RateLimit-Reset", resetSeconds)
+	if !quota.Allowed {
+		header.Set("Retry-After", resetSeconds)
+	}
+}
+
 func setCorsHeaders(reqCtx echo.Context, corsOrigins []string) {
 	if len(corsOrigins) > 0 {
 		origin := reqCtx.Request().Header.Get("Origin")
@@ -330,7 +410,7 @@ func handleRequest(
 ) *HandleResponse {
 	var request *Request
 
-	corsOrigins, err := appCtx.AuthProcessor.PreKeyValidate(ctx, authPayload)
+	corsOrigins, err := preKeyValidate(ctx, appCtx, authPayload)
 	if err != nil {
 		return NewHandleResponse(
 			createWrapperFromError(request, protocol.AuthError(err), requestHandler.GetRequestType()),
@@ -338,6 +418,8 @@ func handleRequest(
 		)
 	}
 
+	ctx = withKeySelector(ctx, appCtx, authPayload)
+	ctx = withKeyCacheControl(ctx, appCtx, authPayload)
 	request, err = requestHandler.RequestDecode(ctx)
 	if err != nil {
 		return NewHandleResponse(createWrapperFromError(request, err, requestHandler.GetRequestType()), nil)
@@ -357,16 +439,33 @@ func handleRequest(
 		)
 	}
 
-	for _, requestHolder := range request.UpstreamRequests {
-		err = appCtx.AuthProcessor.PostKeyValidate(ctx, authPayload, requestHolder)
-		if err != nil {
-			return NewHandleResponse(
-				createWrapperFromError(request, protocol.AuthError(err), requestHandler.GetRequestType()),
-				nil,
+	trace.SpanFromContext(ctx).SetAttributes(tracing.RequestCountKey.Int(len(request.UpstreamRequests)))
+
+	err = postKeyValidate(ctx, appCtx, authPayload, request.UpstreamRequests)
+	if err != nil {
+		return NewHandleResponse(
+			createWrapperFromError(request, protocol.AuthError(err), requestHandler.GetRequestType()),
+			nil,
+		)
+	}
+
+	var rateLimitQuota *ratelimiter.RateLimitQuota
+	if appCtx.InboundRateLimiter != nil {
+		key := appCtx.AuthProcessor.GetKeyValue(authPayload)
+		methods := lo.Map(request.UpstreamRequests, func(requestHolder protocol.RequestHolder, _ int) string {
+			return requestHolder.Method()
+		})
+		var allowed bool
+		allowed, rateLimitQuota = appCtx.InboundRateLimiter.Allow(ctx, key, methods)
+		if !allowed {
+			trace.SpanFromContext(ctx).SetAttributes(tracing.RateLimitedKey.Bool(true))
+			handleResp := NewHandleResponse(
+				createWrapperFromError(request, protocol.RateLimitError(), requestHandler.GetRequestType()),
+				corsOrigins,
 			)
+			handleResp.rateLimitQuota = rateLimitQuota
+			return handleResp
 		}
-		requestHolder.RequestObserver().
-			WithApiKey(appCtx.AuthProcessor.GetKeyValue(authPayload))
 	}
 
 	executionFlow := flow.NewGenericExecutionFlow(
@@ -374,10 +473,12 @@ func handleRequest(
 		appCtx.UpstreamSupervisor,
 		appCtx.CacheProcessor,
 		appCtx.Registry,
-		appCtx.AppConfig,
+		appCtx.AppConfig(),
 		subCtx,
 		appCtx.QuorumRegistry,
 		appCtx.SubEngineRegistry,
+		appCtx.RequestCoalescer,
+		appCtx.FilterRegistry,
 	)
 	executionFlow.AddHooks(
 		flow.NewMethodBanHook(appCtx.UpstreamSupervisor),
@@ -388,7 +489,39 @@ func handleRequest(
 	go executionFlow.Execute(ctx, request.UpstreamRequests)
 	responseChan := executionFlow.GetResponses()
 
-	return NewHandleResponse(responseChan, corsOrigins)
+	handleResp := NewHandleResponse(responseChan, corsOrigins)
+	handleResp.rateLimitQuota = rateLimitQuota
+	return handleResp
+}
+
+func preKeyValidate(ctx context.Context, appCtx *server_ctx.ApplicationServerContext, authPayload auth.AuthPayload) ([]string, error) {
+	ctx, span := tracing.StartSpan(ctx, "auth.pre-key-validate")
+	defer span.End()
+
+	corsOrigins, err := appCtx.AuthProcessor.PreKeyValidate(ctx, authPayload)
+	tracing.SetError(span, err)
+	return corsOrigins, err
+}
+
+func postKeyValidate(
+	ctx context.Context,
+	appCtx *server_ctx.ApplicationServerContext,
+	authPayload auth.AuthPayload,
+	requests []protocol.RequestHolder,
+) error {
+	ctx, span := tracing.StartSpan(ctx, "auth.post-key-validate")
+	defer span.End()
+
+	for _, requestHolder := range requests {
+		err := appCtx.AuthProcessor.PostKeyValidate(ctx, authPayload, requestHolder)
+		if err != nil {
+			tracing.SetError(span, err)
+			return err
+		}
+		requestHolder.RequestObserver().
+			WithApiKey(appCtx.AuthProcessor.GetKeyValue(authPayload))
+	}
+	return nil
 }
 
 func createWrapperFromError(request *Request, err error, requestType protocol.RequestType) chan *protocol.ResponseHolderWrapper {
@@ -8,14 +8,20 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/ratelimiter"
 	"github.com/drpcorg/nodecore/internal/server/http_server"
 	servernodecore "github.com/drpcorg/nodecore/internal/server/server_ctx"
+	"github.com/drpcorg/nodecore/internal/tracing"
 	"github.com/drpcorg/nodecore/pkg/chains"
 	"github.com/drpcorg/nodecore/pkg/test_utils"
 	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/codes"
 )
 
 func TestHttpServerOptionsRequest(t *testing.T) {
@@ -127,7 +133,7 @@ func TestHttpServerCantAuthenticate(t *testing.T) {
 	}
 
 	authProc := mocks.NewMockAuthProcessor()
-	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil)
+	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	server := http_server.NewHttpServer(context.Background(), appCtx)
 	ts := httptest.NewServer(server)
 	defer ts.Close()
@@ -156,7 +162,7 @@ func TestHttpServerCantAuthenticate(t *testing.T) {
 
 func TestHttServerCantParseJsonRpcThenErr(t *testing.T) {
 	authProc := mocks.NewMockAuthProcessor()
-	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil)
+	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	server := http_server.NewHttpServer(context.Background(), appCtx)
 	ts := httptest.NewServer(server)
 	defer ts.Close()
@@ -188,7 +194,7 @@ func TestHttServerCantParseJsonRpcThenErr(t *testing.T) {
 // repro and is not rejected.
 func TestHttpServerNonUtf8ChainThenErr(t *testing.T) {
 	authProc := mocks.NewMockAuthProcessor()
-	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil)
+	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	server := http_server.NewHttpServer(context.Background(), appCtx)
 	ts := httptest.NewServer(server)
 	defer ts.Close()
@@ -212,7 +218,7 @@ func TestHttpServerNonUtf8ChainThenErr(t *testing.T) {
 
 func TestHttpServerPreKeyValidateWithErr(t *testing.T) {
 	authProc := mocks.NewMockAuthProcessor()
-	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil)
+	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	server := http_server.NewHttpServer(context.Background(), appCtx)
 	ts := httptest.NewServer(server)
 	defer ts.Close()
@@ -239,7 +245,7 @@ func TestHttpServerPreKeyValidateWithErr(t *testing.T) {
 
 func TestHttpServerNotSupportedChainThenErr(t *testing.T) {
 	authProc := mocks.NewMockAuthProcessor()
-	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil)
+	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	server := http_server.NewHttpServer(context.Background(), appCtx)
 	ts := httptest.NewServer(server)
 	defer ts.Close()
@@ -267,7 +273,7 @@ func TestHttpServerNotSupportedChainThenErr(t *testing.T) {
 func TestHttpServerChainSupervisorIsNilThenErr(t *testing.T) {
 	upSup := mocks.NewUpstreamSupervisorMock()
 	authProc := mocks.NewMockAuthProcessor()
-	appCtx := servernodecore.NewApplicationServerContext(upSup, nil, nil, authProc, nil, nil, nil, nil, nil, nil)
+	appCtx := servernodecore.NewApplicationServerContext(upSup, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	server := http_server.NewHttpServer(context.Background(), appCtx)
 	ts := httptest.NewServer(server)
 	defer ts.Close()
@@ -297,7 +303,7 @@ func TestHttpServerChainSupervisorIsNilThenErr(t *testing.T) {
 func TestHttpServerPostKeyValidateWithErr(t *testing.T) {
 	upSup := mocks.NewUpstreamSupervisorMock()
 	authProc := mocks.NewMockAuthProcessor()
-	appCtx := servernodecore.NewApplicationServerContext(upSup, nil, nil, authProc, nil, nil, nil, nil, nil, nil)
+	appCtx := servernodecore.NewApplicationServerContext(upSup, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	server := http_server.NewHttpServer(context.Background(), appCtx)
 	ts := httptest.NewServer(server)
 	defer ts.Close()
@@ -332,7 +338,7 @@ func TestHttpServerPostKeyValidateWithErr(t *testing.T) {
 // JSON-RPC errors are a jsonrpc envelope.
 func TestHttpServerRoutesEmptyPathGetAsRest(t *testing.T) {
 	authProc := mocks.NewMockAuthProcessor()
-	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil)
+	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	server := http_server.NewHttpServer(context.Background(), appCtx)
 	ts := httptest.NewServer(server)
 	defer ts.Close()
@@ -356,7 +362,7 @@ func TestHttpServerRoutesEmptyPathGetAsRest(t *testing.T) {
 // The POST side must not move: an empty-path POST stays JSON-RPC.
 func TestHttpServerKeepsEmptyPathPostAsJsonRpc(t *testing.T) {
 	authProc := mocks.NewMockAuthProcessor()
-	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil)
+	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	server := http_server.NewHttpServer(context.Background(), appCtx)
 	ts := httptest.NewServer(server)
 	defer ts.Close()
@@ -385,7 +391,7 @@ func TestHttpServerKeepsEmptyPathPostAsJsonRpc(t *testing.T) {
 // its errors have to keep the JSON-RPC envelope and its error code.
 func TestHttpServerWsHandshakeAuthErrorKeepsJsonRpcShape(t *testing.T) {
 	authProc := mocks.NewMockAuthProcessor()
-	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil)
+	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	server := http_server.NewHttpServer(context.Background(), appCtx)
 	ts := httptest.NewServer(server)
 	defer ts.Close()
@@ -411,7 +417,7 @@ func TestHttpServerWsHandshakeAuthErrorKeepsJsonRpcShape(t *testing.T) {
 // routing rule exists for.
 func TestHttpServerPlainGetWithoutUpgradeStaysRest(t *testing.T) {
 	authProc := mocks.NewMockAuthProcessor()
-	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil)
+	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
 	server := http_server.NewHttpServer(context.Background(), appCtx)
 	ts := httptest.NewServer(server)
 	defer ts.Close()
@@ -430,3 +436,78 @@ func TestHttpServerPlainGetWithoutUpgradeStaysRest(t *testing.T) {
 	assert.Equal(t, `{"message":"auth error - bad key"}`, string(respBody))
 	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
 }
+
+func TestHttpServerInboundRateLimitExceededThenTooManyRequests(t *testing.T) {
+	upSup := mocks.NewUpstreamSupervisorMock()
+	authProc := mocks.NewMockAuthProcessor()
+	ipRateLimit := &config.InboundRateLimitConfig{
+		Rules: []config.RateLimitRule{{Method: "eth_chainId", Requests: 1, Period: time.Minute}},
+	}
+	inboundRateLimiter, err := ratelimiter.NewInboundRateLimiter(context.Background(), ipRateLimit, nil, nil)
+	require.NoError(t, err)
+	appCtx := servernodecore.NewApplicationServerContext(upSup, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, inboundRateLimiter)
+	server := http_server.NewHttpServer(context.Background(), appCtx)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+	body := `[{"jsonrpc" : "2.0","id" : 1,"method" : "eth_chainId"}, {"jsonrpc" : "2.0","id" : 2,"method" : "eth_chainId"}]`
+
+	authProc.On("Authenticate", mock.Anything, mock.Anything).Return(nil)
+	authProc.On("PreKeyValidate", mock.Anything, mock.Anything).Return(nil, nil)
+	authProc.On("PostKeyValidate", mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	authProc.On("GetKeyValue", mock.Anything).Return("")
+	upSup.On("GetChainSupervisor", chains.POLYGON).Return(test_utils.CreateChainSupervisor())
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/queries/polygon", bytes.NewReader([]byte(body)))
+	assert.NoError(t, err)
+
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+
+	respBody, err := io.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.JSONEq(
+		t,
+		`[{"id":1,"jsonrpc":"2.0","error":{"message":"rate limit exceeded","code":429}},{"id":2,"jsonrpc":"2.0","error":{"message":"rate limit exceeded","code":429}}]`,
+		string(respBody),
+	)
+	assert.Equal(t, "1", resp.Header.Get("X-RateLimit-Limit"))
+	// the rejected batch takes nothing from the limit
+	assert.Equal(t, "1", resp.Header.Get("X-RateLimit-Remaining"))
+	assert.NotEmpty(t, resp.Header.Get("X-RateLimit-Reset"))
+	assert.Equal(t, resp.Header.Get("X-RateLimit-Reset"), resp.Header.Get("Retry-After"))
+}
+
+func TestHttpServerTraceparentThenSpansJoinClientTrace(t *testing.T) {
+	recorder := test_utils.EnableTracing(t, false)
+	authProc := mocks.NewMockAuthProcessor()
+	appCtx := servernodecore.NewApplicationServerContext(mocks.NewUpstreamSupervisorMock(), nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	server := http_server.NewHttpServer(context.Background(), appCtx)
+	ts := httptest.NewServer(server)
+	defer ts.Close()
+
+	authProc.On("Authenticate", mock.Anything, mock.Anything).Return(errors.New("auth error"))
+
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/queries/polygon", bytes.NewReader([]byte(`{"jsonrpc":"2.0","id":1,"method":"eth_chainId"}`)))
+	require.NoError(t, err)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	// the span is ended right after the response is written
+	require.Eventually(t, func() bool {
+		return len(test_utils.EndedSpans(recorder, "http.request")) == 1
+	}, time.Second, 5*time.Millisecond)
+	requestSpans := test_utils.EndedSpans(recorder, "http.request")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", requestSpans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", requestSpans[0].Parent().SpanID().String())
+	assert.Equal(t, "polygon", test_utils.SpanAttribute(requestSpans[0], tracing.ChainKey).AsString())
+
+	authSpans := test_utils.EndedSpans(recorder, "auth.authenticate")
+	require.Len(t, authSpans, 1)
+	assert.Equal(t, requestSpans[0].SpanContext().SpanID(), authSpans[0].Parent().SpanID())
+	assert.Equal(t, codes.Error, authSpans[0].Status().Code)
+}
@@ -11,12 +11,15 @@ import (
 
 // reservedQueryParams names every query-string key that nodecore consumes for
 // its own routing/control plane and therefore must NOT be forwarded to the
-// upstream. Today that is just the quorum read parameters parsed by
-// quorum.ParamsFromQuery; when new control params are introduced, add them
-// here so the REST parser keeps stripping the right set in one place.
+// upstream. Today those are the quorum read parameters parsed by
+// quorum.ParamsFromQuery, the routing selector and the cache control; when new
+// control params are introduced, add them here so the REST parser keeps
+// stripping the right set in one place.
 var reservedQueryParams = mapset.NewThreadUnsafeSet[string](
 	"quorum",
 	"quorum_required",
+	selectorQueryParam,
+	cacheControlQueryParam,
 )
 
 // parseRestRequest extracts the canonical method template, the wildcard
@@ -189,12 +189,14 @@ func TestFilteredQuery_RemovesReservedKeysOnly(t *testing.T) {
 	out := filteredQuery(map[string][]string{
 		"quorum":          {"3"},
 		"quorum_required": {"2"},
+		"cache-control":   {"no-cache"},
 		"token":           {"A", "B"},
 		"format":          {"json"},
 	})
 
 	assert.NotContains(t, out, "quorum")
 	assert.NotContains(t, out, "quorum_required")
+	assert.NotContains(t, out, "cache-control")
 	assert.Equal(t, []string{"A", "B"}, out["token"])
 	assert.Equal(t, []string{"json"}, out["format"])
 }
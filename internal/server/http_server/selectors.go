@@ -0,0 +1,48 @@
+package http_server
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/drpcorg/nodecore/internal/protocol"
+)
+
+const (
+	XNodecoreSelector  = "X-Nodecore-Selector"
+	selectorQueryParam = "selector"
+)
+
+type selectorCtxKey struct{}
+
+// selectorFromHttpRequest parses the routing selector of a request, the header takes precedence over the query param
+func selectorFromHttpRequest(req *http.Request) (protocol.RequestSelector, error) {
+	expr := req.Header.Get(XNodecoreSelector)
+	if expr == "" {
+		expr = req.URL.Query().Get(selectorQueryParam)
+	}
+	return protocol.ParseRequestSelector(expr)
+}
+
+func withRequestSelector(ctx context.Context, selector protocol.RequestSelector) context.Context {
+	if selector == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, selectorCtxKey{}, selector)
+}
+
+func requestSelectorFromContext(ctx context.Context) protocol.RequestSelector {
+	if ctx == nil {
+		return nil
+	}
+	selector, _ := ctx.Value(selectorCtxKey{}).(protocol.RequestSelector)
+	return selector
+}
+
+// requestSelectorsFromContext returns the selectors upstream requests are built with
+func requestSelectorsFromContext(ctx context.Context) []protocol.RequestSelector {
+	selector := requestSelectorFromContext(ctx)
+	if selector == nil {
+		return nil
+	}
+	return []protocol.RequestSelector{selector}
+}
@@ -0,0 +1,151 @@
+package http_server_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/drpcorg/nodecore/internal/auth"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/server/http_server"
+	servernodecore "github.com/drpcorg/nodecore/internal/server/server_ctx"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// keySelectorAuthProcessor is an auth processor with a default selector of the key
+type keySelectorAuthProcessor struct {
+	*mocks.MockAuthProcessor
+	selector protocol.RequestSelector
+}
+
+func (k *keySelectorAuthProcessor) GetKeySelector(_ auth.AuthPayload) protocol.RequestSelector {
+	return k.selector
+}
+
+// sendWithSelector sends a request and returns the selectors of the upstream request
+func sendWithSelector(t *testing.T, authProc auth.AuthProcessor, mockAuthProc *mocks.MockAuthProcessor, path string, header string) []protocol.RequestSelector {
+	t.Helper()
+
+	upSup := mocks.NewUpstreamSupervisorMock()
+	appCtx := servernodecore.NewApplicationServerContext(upSup, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	ts := httptest.NewServer(http_server.NewHttpServer(context.Background(), appCtx))
+	defer ts.Close()
+
+	var selectors []protocol.RequestSelector
+	mockAuthProc.On("Authenticate", mock.Anything, mock.Anything).Return(nil)
+	mockAuthProc.On("PreKeyValidate", mock.Anything, mock.Anything).Return(nil, nil)
+	mockAuthProc.On("PostKeyValidate", mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			selectors = args.Get(2).(protocol.RequestHolder).Selectors()
+		}).
+		Return(errors.New("stop"))
+	upSup.On("GetChainSupervisor", chains.POLYGON).Return(test_utils.CreateChainSupervisor())
+
+	body := `{"jsonrpc" : "2.0","id" : 42,"method" : "eth_chainId"}`
+	req, err := http.NewRequest(http.MethodPost, ts.URL+path, bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	if header != "" {
+		req.Header.Set(http_server.XNodecoreSelector, header)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	return selectors
+}
+
+func TestHttpServerSelectorFromHeader(t *testing.T) {
+	authProc := mocks.NewMockAuthProcessor()
+
+	selectors := sendWithSelector(t, authProc, authProc, "/queries/polygon", "archive=true AND provider in (a,b)")
+
+	assert.Equal(
+		t,
+		[]protocol.RequestSelector{
+			protocol.RequestAndSelector{Children: []protocol.RequestSelector{
+				protocol.RequestLabelSelector{Name: "archive", Values: []string{"true"}},
+				protocol.RequestLabelSelector{Name: "provider", Values: []string{"a", "b"}},
+			}},
+		},
+		selectors,
+	)
+}
+
+func TestHttpServerSelectorFromQuery(t *testing.T) {
+	authProc := mocks.NewMockAuthProcessor()
+
+	selectors := sendWithSelector(t, authProc, authProc, "/queries/polygon?selector="+url.QueryEscape("exists(archive)"), "")
+
+	assert.Equal(t, []protocol.RequestSelector{protocol.RequestExistsSelector{Name: "archive"}}, selectors)
+}
+
+func TestHttpServerWithoutSelector(t *testing.T) {
+	authProc := mocks.NewMockAuthProcessor()
+
+	selectors := sendWithSelector(t, authProc, authProc, "/queries/polygon", "")
+
+	assert.Empty(t, selectors)
+}
+
+func TestHttpServerKeySelectorIsDefault(t *testing.T) {
+	keySelector := protocol.RequestLabelSelector{Name: "archive", Values: []string{"true"}}
+	tests := []struct {
+		name     string
+		header   string
+		expected []protocol.RequestSelector
+	}{
+		{name: "key selector", expected: []protocol.RequestSelector{keySelector}},
+		{
+			name:     "request selector takes precedence",
+			header:   "height(latest)",
+			expected: []protocol.RequestSelector{protocol.RequestBlockTagSelector{Tag: protocol.BlockTagLatest}},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			mockAuthProc := mocks.NewMockAuthProcessor()
+			authProc := &keySelectorAuthProcessor{MockAuthProcessor: mockAuthProc, selector: keySelector}
+
+			selectors := sendWithSelector(te, authProc, mockAuthProc, "/queries/polygon", test.header)
+
+			assert.Equal(te, test.expected, selectors)
+		})
+	}
+}
+
+func TestHttpServerInvalidSelectorThenErr(t *testing.T) {
+	authProc := mocks.NewMockAuthProcessor()
+	appCtx := servernodecore.NewApplicationServerContext(nil, nil, nil, authProc, nil, nil, nil, nil, nil, nil, nil, nil, nil)
+	ts := httptest.NewServer(http_server.NewHttpServer(context.Background(), appCtx))
+	defer ts.Close()
+
+	authProc.On("Authenticate", mock.Anything, mock.Anything).Return(nil)
+
+	body := `{"jsonrpc" : "2.0","id" : 42,"method" : "eth_chainId"}`
+	req, err := http.NewRequest(http.MethodPost, ts.URL+"/queries/polygon", bytes.NewReader([]byte(body)))
+	require.NoError(t, err)
+	req.Header.Set(http_server.XNodecoreSelector, "archive")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	respBody, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	assert.Equal(
+		t,
+		`{"id":0,"jsonrpc":"2.0","error":{"message":"client error - invalid selector: expected an operator after 'archive'","code":400}}`,
+		string(respBody),
+	)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/server/server_ctx"
+	"github.com/drpcorg/nodecore/internal/tracing"
 	"github.com/drpcorg/nodecore/internal/upstreams/flow"
 	"github.com/gorilla/websocket"
 	"github.com/prometheus/client_golang/prometheus"
@@ -103,11 +104,14 @@ loop:
 				break loop
 			}
 
-			handleResp := handleRequest(cancelCtx, requestHandler, authPayload, appCtx, subCtx)
+			messageCtx, span := tracing.StartSpan(cancelCtx, "ws.message", tracing.ChainKey.String(chain))
+			handleResp := handleRequest(messageCtx, requestHandler, authPayload, appCtx, subCtx)
 
 			wg.Add(1)
 			go func(ctx context.Context) {
 				defer wg.Done()
+				// the span of a subscription lasts until it's over
+				defer span.End()
 				closeFunc := func() {
 					select {
 					case <-ctx.Done():
@@ -6,11 +6,14 @@ import (
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/dimensions"
 	"github.com/drpcorg/nodecore/internal/quorum"
+	"github.com/drpcorg/nodecore/internal/ratelimiter"
 	"github.com/drpcorg/nodecore/internal/rating"
 	"github.com/drpcorg/nodecore/internal/stats"
 	"github.com/drpcorg/nodecore/internal/storages"
 	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/internal/upstreams/flow"
 	"github.com/drpcorg/nodecore/internal/upstreams/flow/subengine"
+	"github.com/drpcorg/nodecore/pkg/utils"
 )
 
 type ApplicationServerContext struct {
@@ -18,12 +21,15 @@ type ApplicationServerContext struct {
 	CacheProcessor     caches.CacheProcessor
 	Registry           *rating.RatingRegistry
 	AuthProcessor      auth.AuthProcessor
-	AppConfig          *config.AppConfig
+	appConfig          *utils.Atomic[*config.AppConfig]
 	StorageRegistry    *storages.StorageRegistry
 	StatsService       stats.StatsService
 	DimensionTracker   dimensions.DimensionTracker
 	QuorumRegistry     *quorum.Registry
 	SubEngineRegistry  *subengine.Registry
+	RequestCoalescer   *flow.RequestCoalescer
+	FilterRegistry     *flow.FilterRegistry
+	InboundRateLimiter *ratelimiter.InboundRateLimiter
 }
 
 func NewApplicationServerContext(
@@ -37,17 +43,36 @@ func NewApplicationServerContext(
 	dimensionTracker dimensions.DimensionTracker,
 	quorumRegistry *quorum.Registry,
 	subEngineRegistry *subengine.Registry,
+	requestCoalescer *flow.RequestCoalescer,
+	filterRegistry *flow.FilterRegistry,
+	inboundRateLimiter *ratelimiter.InboundRateLimiter,
 ) *ApplicationServerContext {
+	appConfigAtomic := utils.NewAtomic[*config.AppConfig]()
+	appConfigAtomic.Store(appConfig)
+
 	return &ApplicationServerContext{
 		UpstreamSupervisor: upstreamSupervisor,
 		CacheProcessor:     cacheProcessor,
 		Registry:           registry,
 		AuthProcessor:      authProcessor,
-		AppConfig:          appConfig,
+		appConfig:          appConfigAtomic,
 		StorageRegistry:    storageRegistry,
 		StatsService:       statsService,
 		DimensionTracker:   dimensionTracker,
 		QuorumRegistry:     quorumRegistry,
 		SubEngineRegistry:  subEngineRegistry,
+		RequestCoalescer:   requestCoalescer,
+		FilterRegistry:     filterRegistry,
+		InboundRateLimiter: inboundRateLimiter,
 	}
 }
+
+// AppConfig returns the current config, it's replaced on every successful reload
+// so it must be read per request rather than cached
+func (a *ApplicationServerContext) AppConfig() *config.AppConfig {
+	return a.appConfig.Load()
+}
+
+func (a *ApplicationServerContext) UpdateAppConfig(appConfig *config.AppConfig) {
+	a.appConfig.Store(appConfig)
+}
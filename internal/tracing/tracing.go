@@ -0,0 +1,188 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync/atomic"
+
+	"github.com/drpcorg/nodecore/internal/buildinfo"
+	"github.com/drpcorg/nodecore/internal/config"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+	"go.opentelemetry.io/otel/trace/noop"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	tracerName        = "github.com/drpcorg/nodecore"
+	defaultTracesPath = "/v1/traces"
+)
+
+const (
+	ChainKey         = attribute.Key("nodecore.chain")
+	MethodKey        = attribute.Key("nodecore.method")
+	UpstreamIdKey    = attribute.Key("nodecore.upstream.id")
+	ResponseKindKey  = attribute.Key("nodecore.response.kind")
+	ConnectorTypeKey = attribute.Key("nodecore.connector.type")
+	AttemptKey       = attribute.Key("nodecore.attempt")
+	RetryKey         = attribute.Key("nodecore.attempt.retry")
+	HedgeKey         = attribute.Key("nodecore.attempt.hedge")
+	CacheHitKey      = attribute.Key("nodecore.cache.hit")
+	RequestCountKey  = attribute.Key("nodecore.request.count")
+	RateLimitedKey   = attribute.Key("nodecore.rate-limited")
+)
+
+// incoming requests are joined to the client's trace only through the W3C traceparent header
+var propagator = propagation.TraceContext{}
+
+var noopSpan = noop.Span{}
+
+var propagateToUpstreams atomic.Bool
+
+// tracer is nil unless tracing is enabled, then spans aren't started at all and the contexts are kept as they are
+var tracer atomic.Pointer[trace.Tracer]
+
+// Init registers the global tracer provider which exports spans to the OTLP HTTP endpoint.
+// Without it all the spans are no-op. The returned func flushes the remaining spans
+func Init(ctx context.Context, tracingConfig *config.TracingConfig) (func(context.Context) error, error) {
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpointURL(tracingConfig.Endpoint)}
+	if endpointUrl, err := url.Parse(tracingConfig.Endpoint); err == nil && (endpointUrl.Path == "" || endpointUrl.Path == "/") {
+		options = append(options, otlptracehttp.WithURLPath(defaultTracesPath))
+	}
+	if len(tracingConfig.Headers) > 0 {
+		options = append(options, otlptracehttp.WithHeaders(tracingConfig.Headers))
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create an otlp exporter - %w", err)
+	}
+
+	tracerProvider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(tracingConfig.SamplingRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(
+			attribute.String("service.name", config.AppName),
+			attribute.String("service.version", buildinfo.ProductVersion()),
+		)),
+	)
+	otel.SetTextMapPropagator(propagator)
+	SetTracerProvider(tracerProvider, tracingConfig.PropagateToUpstreams)
+
+	return tracerProvider.Shutdown, nil
+}
+
+// SetTracerProvider enables tracing with the provider, a nil provider disables it
+func SetTracerProvider(tracerProvider trace.TracerProvider, propagate bool) {
+	if tracerProvider == nil {
+		tracer.Store(nil)
+		propagateToUpstreams.Store(false)
+		return
+	}
+	otel.SetTracerProvider(tracerProvider)
+	tracer.Store(new(tracerProvider.Tracer(tracerName)))
+	propagateToUpstreams.Store(propagate)
+}
+
+func Enabled() bool {
+	return tracer.Load() != nil
+}
+
+func StartSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return startSpan(ctx, name, trace.SpanKindInternal, attrs)
+}
+
+func StartServerSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return startSpan(ctx, name, trace.SpanKindServer, attrs)
+}
+
+func StartClientSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return startSpan(ctx, name, trace.SpanKindClient, attrs)
+}
+
+func startSpan(ctx context.Context, name string, kind trace.SpanKind, attrs []attribute.KeyValue) (context.Context, trace.Span) {
+	currentTracer := tracer.Load()
+	if currentTracer == nil {
+		return ctx, noopSpan
+	}
+	return (*currentTracer).Start(ctx, name, trace.WithAttributes(attrs...), trace.WithSpanKind(kind))
+}
+
+// SetError marks the span as failed if there is an error
+func SetError(span trace.Span, err error) {
+	if err == nil {
+		return
+	}
+	span.RecordError(err)
+	span.SetStatus(codes.Error, err.Error())
+}
+
+// ExtractHttp returns the context with the remote span from the W3C traceparent header if there is one
+func ExtractHttp(ctx context.Context, header http.Header) context.Context {
+	if !Enabled() {
+		return ctx
+	}
+	return propagator.Extract(ctx, propagation.HeaderCarrier(header))
+}
+
+// ExtractGrpc returns the context with the remote span from the traceparent metadata of an incoming grpc call
+func ExtractGrpc(ctx context.Context) context.Context {
+	if !Enabled() {
+		return ctx
+	}
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ctx
+	}
+	return propagator.Extract(ctx, metadataCarrier(md))
+}
+
+// InjectHttp sets the traceparent header of an upstream request if propagation to upstreams is enabled
+func InjectHttp(ctx context.Context, header http.Header) {
+	if !propagateToUpstreams.Load() {
+		return
+	}
+	propagator.Inject(ctx, propagation.HeaderCarrier(header))
+}
+
+// InjectGrpc returns the context with the traceparent in the outgoing metadata of an upstream call
+// if propagation to upstreams is enabled
+func InjectGrpc(ctx context.Context) context.Context {
+	if !propagateToUpstreams.Load() || !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx
+	}
+	md, _ := metadata.FromOutgoingContext(ctx)
+	md = md.Copy()
+	propagator.Inject(ctx, metadataCarrier(md))
+	return metadata.NewOutgoingContext(ctx, md)
+}
+
+// metadataCarrier adapts grpc metadata whose keys are lowercase, unlike the canonical keys of http.Header
+type metadataCarrier metadata.MD
+
+func (m metadataCarrier) Get(key string) string {
+	values := metadata.MD(m).Get(key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+func (m metadataCarrier) Set(key, value string) {
+	metadata.MD(m).Set(key, value)
+}
+
+func (m metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	return keys
+}
@@ -0,0 +1,169 @@
+package tracing_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/tracing"
+	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/protobuf/proto"
+)
+
+const traceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+type collectedRequest struct {
+	header  http.Header
+	request *coltracepb.ExportTraceServiceRequest
+}
+
+// newCollector is an OTLP HTTP collector stand-in that decodes the exported spans
+func newCollector(t *testing.T) (*httptest.Server, chan collectedRequest) {
+	requests := make(chan collectedRequest, 10)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		exportRequest := &coltracepb.ExportTraceServiceRequest{}
+		require.NoError(t, proto.Unmarshal(body, exportRequest))
+		requests <- collectedRequest{header: r.Header, request: exportRequest}
+
+		w.Header().Set("Content-Type", "application/x-protobuf")
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(collector.Close)
+	return collector, requests
+}
+
+func TestInitThenSpansExportedToCollector(t *testing.T) {
+	collector, requests := newCollector(t)
+	t.Cleanup(func() {
+		tracing.SetTracerProvider(nil, false)
+	})
+	ctx := context.Background()
+
+	shutdown, err := tracing.Init(ctx, &config.TracingConfig{
+		Enabled:       true,
+		Endpoint:      collector.URL,
+		Headers:       map[string]string{"X-Token": "secret"},
+		SamplingRatio: 1,
+	})
+	require.NoError(t, err)
+
+	_, span := tracing.StartSpan(ctx, "flow.process-request", tracing.ChainKey.String("ethereum"))
+	span.End()
+	require.NoError(t, shutdown(ctx))
+
+	var collected collectedRequest
+	select {
+	case collected = <-requests:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no spans were exported")
+	}
+
+	assert.Equal(t, "secret", collected.header.Get("X-Token"))
+	require.Len(t, collected.request.ResourceSpans, 1)
+	resourceSpans := collected.request.ResourceSpans[0]
+	serviceName := ""
+	for _, attr := range resourceSpans.Resource.Attributes {
+		if attr.Key == "service.name" {
+			serviceName = attr.Value.GetStringValue()
+		}
+	}
+	assert.Equal(t, config.AppName, serviceName)
+	require.Len(t, resourceSpans.ScopeSpans, 1)
+	require.Len(t, resourceSpans.ScopeSpans[0].Spans, 1)
+	exportedSpan := resourceSpans.ScopeSpans[0].Spans[0]
+	assert.Equal(t, "flow.process-request", exportedSpan.Name)
+	require.Len(t, exportedSpan.Attributes, 1)
+	assert.Equal(t, string(tracing.ChainKey), exportedSpan.Attributes[0].Key)
+	assert.Equal(t, "ethereum", exportedSpan.Attributes[0].Value.GetStringValue())
+}
+
+func TestExtractHttpThenSpanJoinsClientTrace(t *testing.T) {
+	recorder := test_utils.EnableTracing(t, false)
+	header := http.Header{}
+	header.Set("traceparent", traceparent)
+
+	_, span := tracing.StartServerSpan(tracing.ExtractHttp(context.Background(), header), "http.request")
+	span.End()
+
+	spans := test_utils.EndedSpans(recorder, "http.request")
+	require.Len(t, spans, 1)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", spans[0].SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", spans[0].Parent().SpanID().String())
+	assert.True(t, spans[0].Parent().IsRemote())
+	assert.Equal(t, trace.SpanKindServer, spans[0].SpanKind())
+}
+
+func TestInjectHttpWithPropagationThenTraceparentSet(t *testing.T) {
+	test_utils.EnableTracing(t, true)
+	header := http.Header{}
+	header.Set("traceparent", traceparent)
+
+	ctx, span := tracing.StartClientSpan(tracing.ExtractHttp(context.Background(), header), "connector.send")
+	defer span.End()
+	upstreamHeader := http.Header{}
+	tracing.InjectHttp(ctx, upstreamHeader)
+
+	expected := "00-4bf92f3577b34da6a3ce929d0e0e4736-" + span.SpanContext().SpanID().String() + "-01"
+	assert.Equal(t, expected, upstreamHeader.Get("traceparent"))
+}
+
+func TestInjectHttpWithoutPropagationThenNoTraceparent(t *testing.T) {
+	test_utils.EnableTracing(t, false)
+
+	ctx, span := tracing.StartClientSpan(context.Background(), "connector.send")
+	defer span.End()
+	upstreamHeader := http.Header{}
+	tracing.InjectHttp(ctx, upstreamHeader)
+
+	assert.Empty(t, upstreamHeader.Get("traceparent"))
+}
+
+func TestInjectGrpcWithPropagationThenTraceparentAddedToMetadata(t *testing.T) {
+	test_utils.EnableTracing(t, true)
+
+	ctx, span := tracing.StartClientSpan(context.Background(), "connector.native-call")
+	defer span.End()
+	ctx = tracing.InjectGrpc(metadata.NewOutgoingContext(ctx, metadata.Pairs("sessionid", "session")))
+
+	md, ok := metadata.FromOutgoingContext(ctx)
+	require.True(t, ok)
+	expected := "00-" + span.SpanContext().TraceID().String() + "-" + span.SpanContext().SpanID().String() + "-01"
+	assert.Equal(t, []string{expected}, md.Get("traceparent"))
+	assert.Equal(t, []string{"session"}, md.Get("sessionid"))
+}
+
+func TestInjectGrpcWithoutPropagationThenContextKept(t *testing.T) {
+	test_utils.EnableTracing(t, false)
+
+	ctx, span := tracing.StartClientSpan(context.Background(), "connector.native-call")
+	defer span.End()
+
+	assert.Equal(t, ctx, tracing.InjectGrpc(ctx))
+}
+
+func TestTracingDisabledThenContextKept(t *testing.T) {
+	header := http.Header{}
+	header.Set("traceparent", traceparent)
+	ctx := context.Background()
+
+	spanCtx, span := tracing.StartSpan(tracing.ExtractHttp(ctx, header), "flow.process-request")
+	span.End()
+
+	assert.Equal(t, ctx, spanCtx)
+	assert.False(t, span.SpanContext().IsValid())
+}
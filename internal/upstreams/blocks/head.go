@@ -244,3 +244,82 @@ func NewSubHead(
 
 	return &head
 }
+
+// GrpcHead follows the heads a dshackle-compatible upstream streams via
+// SubscribeHead. The stream starts with the current head, so unlike
+// SubscriptionHead there is no need to request the latest block separately.
+type GrpcHead struct {
+	lifecycle      *utils.GenericLifecycle
+	block          *utils.Atomic[protocol.Block]
+	headSubscriber connectors.HeadSubscriber
+	upstreamId     string
+	headsChan      chan protocol.Block
+}
+
+func NewGrpcHead(ctx context.Context, upstreamId string, headSubscriber connectors.HeadSubscriber) *GrpcHead {
+	return &GrpcHead{
+		lifecycle:      utils.NewGenericLifecycle(fmt.Sprintf("%s_grpc_head", upstreamId), ctx),
+		block:          utils.NewAtomic[protocol.Block](),
+		headSubscriber: headSubscriber,
+		upstreamId:     upstreamId,
+		headsChan:      make(chan protocol.Block),
+	}
+}
+
+var _ Head = (*GrpcHead)(nil)
+
+func (g *GrpcHead) Start() {
+	log.Info().Msgf("starting a grpc head of upstream %s", g.upstreamId)
+	g.lifecycle.Start(func(ctx context.Context) error {
+		heads, err := g.headSubscriber.SubscribeHead(ctx)
+		if err != nil {
+			return err
+		}
+		go func() {
+			for {
+				select {
+				case block, ok := <-heads:
+					if !ok {
+						return
+					}
+					g.block.Store(block)
+					select {
+					case g.headsChan <- block:
+					case <-ctx.Done():
+						return
+					}
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+		return nil
+	})
+}
+
+func (g *GrpcHead) Stop() {
+	log.Info().Msgf("stopping a grpc head of upstream '%s'", g.upstreamId)
+	g.lifecycle.Stop()
+}
+
+func (g *GrpcHead) Running() bool {
+	return g.lifecycle.Running()
+}
+
+func (g *GrpcHead) HeadsChan() chan protocol.Block {
+	return g.headsChan
+}
+
+func (g *GrpcHead) OnNoHeadUpdates() {
+	log.Info().Msgf("trying to resubscribe to grpc heads of upstream %s", g.upstreamId)
+	g.Stop()
+	g.Start()
+}
+
+func (g *GrpcHead) GetCurrentBlock() protocol.Block {
+	return g.block.Load()
+}
+
+func (g *GrpcHead) UpdateHead(newHead protocol.Block) {
+	g.block.Store(newHead)
+}
@@ -52,7 +52,7 @@ func NewGenericHeadProcessor(
 		if upConfig.PollInterval >= headNoUpdatesTimeout {
 			headNoUpdatesTimeout = upConfig.PollInterval * 3
 		}
-	case *SubscriptionHead:
+	case *SubscriptionHead, *GrpcHead:
 		if configuredChain.Settings.ExpectedBlockTime >= headNoUpdatesTimeout {
 			headNoUpdatesTimeout = configuredChain.Settings.ExpectedBlockTime + headNoUpdatesTimeout
 		}
@@ -139,6 +139,11 @@ func createHead(
 		return NewRpcHead(ctx, id, options.InternalTimeout, pollInterval, specific)
 	case specs.WebsocketConnector:
 		return NewSubHead(ctx, id, options.InternalTimeout, headConnector, specific)
+	case specs.GrpcConnector:
+		if headSubscriber, ok := headConnector.(connectors.HeadSubscriber); ok {
+			return NewGrpcHead(ctx, id, headSubscriber)
+		}
+		return NewRpcHead(ctx, id, options.InternalTimeout, pollInterval, specific)
 	default:
 		return nil
 	}
@@ -224,3 +224,32 @@ func TestSubHeadGetLastBlock(t *testing.T) {
 	reqConnector.AssertExpectations(t)
 	connector.AssertExpectations(t)
 }
+
+type headSubscriberStub struct {
+	heads chan protocol.Block
+}
+
+func (h *headSubscriberStub) SubscribeHead(_ context.Context) (chan protocol.Block, error) {
+	return h.heads, nil
+}
+
+func TestGrpcHeadSubscribe(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	heads := make(chan protocol.Block, 1)
+	expected := protocol.NewBlock(100, 0, blockchain.NewHashIdFromString("0x0a"), blockchain.NewHashIdFromString("0x0b"))
+	heads <- expected
+	grpcHead := blocks.NewGrpcHead(ctx, "id", &headSubscriberStub{heads: heads})
+
+	grpcHead.Start()
+	defer grpcHead.Stop()
+
+	select {
+	case block := <-grpcHead.HeadsChan():
+		assert.Equal(t, expected, block)
+		assert.Equal(t, expected, grpcHead.GetCurrentBlock())
+	case <-time.After(5 * time.Second):
+		t.Fatal("no head")
+	}
+}
@@ -191,6 +191,9 @@ func (b *GenericChainSupervisor) processEvents() {
 						b.updateState()
 						b.updateHead(event.Id, &protocol.HeadUpstreamEvent{Status: protocol.Unavailable, Head: upHead})
 					}
+					if eventType.Handled != nil {
+						close(eventType.Handled)
+					}
 				case *protocol.HeadUpstreamEvent:
 					// Keep the per-upstream snapshot's head fresh - head updates
 					// arrive as HeadUpstreamEvent (not StateUpstreamEvent), so
@@ -575,6 +575,30 @@ func TestChainSupervisorRemoveUpstreamState(t *testing.T) {
 	}, eventuallyWait, eventuallyTick)
 }
 
+func TestChainSupervisorRemoveUpstreamThenHandledClosedAfterRemoval(t *testing.T) {
+	chainSupervisor := upstreams.NewGenericChainSupervisor(context.Background(), chains.ARBITRUM, fork_choice.NewHeightForkChoice(), nil, false, nil)
+	methods := mocks.NewMethodsMock()
+	methods.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("test1"))
+
+	go chainSupervisor.Start()
+
+	chainSupervisor.PublishUpstreamEvent(test_utils.CreateEvent("id", protocol.Available, protocol.NewBlockWithHeight(100), methods))
+	assert.Eventually(t, func() bool {
+		return chainSupervisor.GetUpstreamState("id") != nil
+	}, eventuallyWait, eventuallyTick)
+
+	handled := make(chan struct{})
+	chainSupervisor.PublishUpstreamEvent(protocol.UpstreamEvent{Id: "id", EventType: &protocol.RemoveUpstreamEvent{Handled: handled}})
+
+	select {
+	case <-handled:
+		assert.Nil(t, chainSupervisor.GetUpstreamState("id"))
+		assert.Empty(t, chainSupervisor.GetUpstreamIds())
+	case <-time.After(eventuallyWait):
+		t.Fatal("the removal hasn't been handled")
+	}
+}
+
 func TestChainSupervisorHeadEventRefreshesUpstreamSnapshot(t *testing.T) {
 	chainSupervisor := upstreams.NewGenericChainSupervisor(context.Background(), chains.ARBITRUM, fork_choice.NewHeightForkChoice(), nil, false, nil)
 	methods := mocks.NewMethodsMock()
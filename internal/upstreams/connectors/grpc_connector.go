@@ -0,0 +1,511 @@
+package connectors
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/tracing"
+	"github.com/drpcorg/nodecore/pkg/blockchain"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/dshackle"
+	"github.com/drpcorg/nodecore/pkg/methods"
+	"github.com/drpcorg/nodecore/pkg/utils"
+	"github.com/google/uuid"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"golang.org/x/net/proxy"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+const grpcSubscriptionBufferSize = 100
+
+// HeadSubscriber is implemented by connectors that can stream chain heads
+// natively instead of polling or subscribing through the chain's own API.
+// The returned channel is closed once the head stream ends.
+type HeadSubscriber interface {
+	SubscribeHead(ctx context.Context) (chan protocol.Block, error)
+}
+
+// GrpcConnector talks to another dshackle-compatible instance (dshackle,
+// another nodecore) via the emerald Blockchain API: unary requests go through
+// NativeCall, subscriptions through NativeSubscribe and heads through
+// SubscribeHead. The remote side does the routing, so a single connector
+// serves every API kind (JSON-RPC and REST) of the configured chain.
+type GrpcConnector struct {
+	endpoint          string
+	upstreamId        string
+	chainRef          dshackle.ChainRef
+	conn              *grpc.ClientConn
+	client            dshackle.BlockchainClient
+	additionalHeaders metadata.MD
+	subscriptions     *utils.CMap[string, context.CancelFunc]
+}
+
+func NewGrpcConnector(
+	connectorConfig *config.ApiConnectorConfig,
+	configuredChain *chains.ConfiguredChain,
+	torProxyUrl string,
+	upstreamId string,
+) (*GrpcConnector, error) {
+	endpoint, err := url.Parse(connectorConfig.Url)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing the endpoint: %v", err)
+	}
+
+	dialOptions := make([]grpc.DialOption, 0, 2)
+	switch endpoint.Scheme {
+	case "grpc", "http":
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	case "grpcs", "https":
+		customCA, err := utils.GetCustomCAPool(connectorConfig.Ca)
+		if err != nil {
+			return nil, err
+		}
+		dialOptions = append(dialOptions, grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{RootCAs: customCA})))
+	default:
+		return nil, fmt.Errorf("unsupported grpc endpoint scheme '%s'", endpoint.Scheme)
+	}
+
+	if strings.HasSuffix(endpoint.Hostname(), ".onion") {
+		if torProxyUrl == "" {
+			return nil, errors.New("tor proxy url is required for onion endpoints")
+		}
+		dialer := &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}
+		socksProxy, err := proxy.SOCKS5("tcp", torProxyUrl, nil, dialer)
+		if err != nil {
+			return nil, fmt.Errorf("error creating socks5 proxy: %v", err)
+		}
+		dialOptions = append(dialOptions, grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return socksProxy.Dial("tcp", addr)
+		}))
+	}
+
+	conn, err := grpc.NewClient(endpoint.Host, dialOptions...)
+	if err != nil {
+		return nil, fmt.Errorf("error creating a grpc client: %v", err)
+	}
+
+	return newGrpcConnector(connectorConfig, configuredChain, upstreamId, conn), nil
+}
+
+func newGrpcConnector(
+	connectorConfig *config.ApiConnectorConfig,
+	configuredChain *chains.ConfiguredChain,
+	upstreamId string,
+	conn *grpc.ClientConn,
+) *GrpcConnector {
+	// metadata keys are lowercase by the grpc spec
+	headers := metadata.MD{}
+	for key, value := range connectorConfig.Headers {
+		headers.Append(strings.ToLower(key), value)
+	}
+
+	return &GrpcConnector{
+		endpoint:          connectorConfig.Url,
+		upstreamId:        upstreamId,
+		chainRef:          dshackle.ChainRef(configuredChain.GrpcId),
+		conn:              conn,
+		client:            dshackle.NewBlockchainClient(conn),
+		additionalHeaders: headers,
+		subscriptions:     utils.NewCMap[string, context.CancelFunc](),
+	}
+}
+
+func (g *GrpcConnector) GetUrl() string {
+	return g.endpoint
+}
+
+func (g *GrpcConnector) GetType() specs.ApiConnectorType {
+	return specs.GrpcConnector
+}
+
+func (g *GrpcConnector) Start() {
+	g.conn.Connect()
+}
+
+func (g *GrpcConnector) Stop() {
+	g.subscriptions.Range(func(opId string, cancel context.CancelFunc) bool {
+		cancel()
+		g.subscriptions.Delete(opId)
+		return true
+	})
+	if err := g.conn.Close(); err != nil {
+		log.Warn().Err(err).Msgf("couldn't close the grpc connection of upstream %s", g.upstreamId)
+	}
+}
+
+func (g *GrpcConnector) Running() bool {
+	return g.conn.GetState() != connectivity.Shutdown
+}
+
+func (g *GrpcConnector) SubscribeStates(_ string) *utils.Subscription[protocol.SubscribeConnectorState] {
+	return nil
+}
+
+func (g *GrpcConnector) SendRequest(ctx context.Context, request protocol.RequestHolder) protocol.ResponseHolder {
+	ctx, span := tracing.StartClientSpan(
+		ctx,
+		"connector.native-call",
+		tracing.UpstreamIdKey.String(g.upstreamId),
+		tracing.MethodKey.String(request.Method()),
+	)
+	defer span.End()
+
+	response := g.sendRequest(ctx, request)
+	span.SetAttributes(tracing.ResponseKindKey.String(protocol.GetRespKindFromResponse(response).String()))
+	return response
+}
+
+func (g *GrpcConnector) sendRequest(ctx context.Context, request protocol.RequestHolder) protocol.ResponseHolder {
+	item, err := nativeCallItem(request)
+	if err != nil {
+		return clientFailure(request, err)
+	}
+
+	stream, err := g.client.NativeCall(g.outgoingContext(ctx), &dshackle.NativeCallRequest{
+		Chain: g.chainRef,
+		Items: []*dshackle.NativeCallItem{item},
+	})
+	if err != nil {
+		return g.callFailure(ctx, request, err)
+	}
+
+	return g.receiveReply(ctx, request, stream)
+}
+
+// receiveReply reads the reply of the single item sent by SendRequest. A reply
+// is either one item or a sequence of chunks terminated by a final chunk; the
+// chunks are joined back together, response-level metadata travels on the first one.
+func (g *GrpcConnector) receiveReply(
+	ctx context.Context,
+	request protocol.RequestHolder,
+	stream grpc.ServerStreamingClient[dshackle.NativeCallReplyItem],
+) protocol.ResponseHolder {
+	var payload []byte
+	var headers http.Header
+	received := false
+
+	for {
+		replyItem, err := stream.Recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) && received {
+				// the remote side closed the stream without a final chunk
+				break
+			}
+			return g.callFailure(ctx, request, err)
+		}
+		if !received {
+			headers = mapResponseHeaders(replyItem.GetResponseHeaders())
+			received = true
+		}
+		if !replyItem.GetSucceed() {
+			return nativeCallErrorResponse(request, replyItem).WithResponseHeaders(headers)
+		}
+		payload = append(payload, replyItem.GetPayload()...)
+		if !replyItem.GetChunked() || replyItem.GetFinalChunk() {
+			break
+		}
+	}
+
+	if request.RequestType() == protocol.Rest {
+		return protocol.NewHttpUpstreamResponse(request.Id(), payload, http.StatusOK, protocol.Rest).WithResponseHeaders(headers)
+	}
+	// the remote side unwraps a json-rpc response and sends only its result
+	if len(payload) == 0 {
+		payload = []byte("null")
+	}
+	return protocol.NewSimpleHttpUpstreamResponse(request.Id(), payload, protocol.JsonRpc).WithResponseHeaders(headers)
+}
+
+func (g *GrpcConnector) Subscribe(ctx context.Context, request protocol.RequestHolder) (protocol.UpstreamSubscriptionResponse, error) {
+	// the span covers opening the subscription, the events outlive it
+	ctx, span := tracing.StartClientSpan(
+		ctx,
+		"connector.native-subscribe",
+		tracing.UpstreamIdKey.String(g.upstreamId),
+		tracing.MethodKey.String(request.Method()),
+	)
+	defer span.End()
+
+	response, err := g.subscribe(ctx, request)
+	tracing.SetError(span, err)
+	return response, err
+}
+
+func (g *GrpcConnector) subscribe(ctx context.Context, request protocol.RequestHolder) (protocol.UpstreamSubscriptionResponse, error) {
+	method, payload, err := nativeSubscribeMethod(request)
+	if err != nil {
+		return nil, err
+	}
+
+	subCtx, cancel := context.WithCancel(ctx)
+	stream, err := g.client.NativeSubscribe(g.outgoingContext(subCtx), &dshackle.NativeSubscribeRequest{
+		Chain:   g.chainRef,
+		Method:  method,
+		Payload: payload,
+	})
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("couldn't subscribe to %s via upstream %s: %w", request.Method(), g.upstreamId, err)
+	}
+
+	opId := uuid.NewString()
+	g.subscriptions.Store(opId, cancel)
+
+	respChan := make(chan *protocol.WsResponse, grpcSubscriptionBufferSize)
+	go g.forwardSubscription(subCtx, opId, stream, respChan)
+
+	return protocol.NewJsonRpcWsUpstreamResponse(respChan, opId), nil
+}
+
+func (g *GrpcConnector) forwardSubscription(
+	ctx context.Context,
+	opId string,
+	stream grpc.ServerStreamingClient[dshackle.NativeSubscribeReplyItem],
+	respChan chan *protocol.WsResponse,
+) {
+	defer close(respChan)
+	defer g.Unsubscribe(opId)
+
+	for {
+		replyItem, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil {
+				return
+			}
+			log.Warn().Err(err).Msgf("subscription %s of upstream %s has been terminated", opId, g.upstreamId)
+			select {
+			case respChan <- &protocol.WsResponse{
+				SubId: opId,
+				Type:  protocol.Ws,
+				Error: protocol.ServerErrorWithCause(fmt.Errorf("subscription stream of upstream %s failed", g.upstreamId)),
+			}:
+			case <-ctx.Done():
+			}
+			return
+		}
+		if replyItem.GetHeartbeat() {
+			continue
+		}
+		select {
+		case respChan <- &protocol.WsResponse{SubId: opId, Type: protocol.Ws, Message: replyItem.GetPayload()}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func (g *GrpcConnector) Unsubscribe(opId string) {
+	if cancel, ok := g.subscriptions.LoadAndDelete(opId); ok {
+		cancel()
+	}
+}
+
+func (g *GrpcConnector) SubscribeHead(ctx context.Context) (chan protocol.Block, error) {
+	stream, err := g.client.SubscribeHead(g.outgoingContext(ctx), &dshackle.Chain{Type: g.chainRef})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't subscribe to heads of upstream %s: %w", g.upstreamId, err)
+	}
+
+	heads := make(chan protocol.Block)
+	go func() {
+		defer close(heads)
+		for {
+			head, err := stream.Recv()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Warn().Err(err).Msgf("head stream of upstream %s has been terminated", g.upstreamId)
+				}
+				return
+			}
+			block := protocol.NewBlock(
+				head.GetHeight(),
+				head.GetSlot(),
+				blockchain.NewHashIdFromString(head.GetBlockId()),
+				blockchain.NewHashIdFromString(head.GetParentBlockId()),
+			)
+			select {
+			case heads <- block:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return heads, nil
+}
+
+func (g *GrpcConnector) outgoingContext(ctx context.Context) context.Context {
+	if len(g.additionalHeaders) > 0 {
+		ctx = metadata.NewOutgoingContext(ctx, g.additionalHeaders.Copy())
+	}
+	return tracing.InjectGrpc(ctx)
+}
+
+func (g *GrpcConnector) callFailure(ctx context.Context, request protocol.RequestHolder, err error) protocol.ResponseHolder {
+	if ctx.Err() != nil {
+		return protocol.NewTotalFailure(request, protocol.CtxError(fmt.Errorf("upstream %s: %v", g.upstreamId, ctx.Err())))
+	}
+	// Log the full error for operators; surface only the upstream id to the caller.
+	zerolog.Ctx(ctx).Warn().Err(err).Str("upstream", g.upstreamId).Msg("upstream grpc request failed")
+	return protocol.NewPartialFailure(
+		request,
+		protocol.ServerErrorWithCause(fmt.Errorf("upstream %s request failed", g.upstreamId)),
+	)
+}
+
+func nativeCallItem(request protocol.RequestHolder) (*dshackle.NativeCallItem, error) {
+	item := &dshackle.NativeCallItem{
+		Id:     1,
+		Method: request.Method(),
+	}
+
+	switch request.RequestType() {
+	case protocol.Rest:
+		body, err := request.Body()
+		if err != nil {
+			return nil, fmt.Errorf("error parsing a request body: %v", err)
+		}
+		restData := &dshackle.RestData{Payload: body}
+		if requestParams := request.RequestParams(); requestParams != nil {
+			restData.PathParams = requestParams.PathParams
+			restData.Headers = mapToKeyValues(requestParams.Headers)
+			restData.QueryParams = mapToKeyValues(requestParams.QueryParams)
+		}
+		item.Data = &dshackle.NativeCallItem_RestData{RestData: restData}
+	default:
+		params, err := jsonRpcParams(request)
+		if err != nil {
+			return nil, err
+		}
+		item.Data = &dshackle.NativeCallItem_Payload{Payload: params}
+	}
+
+	return item, nil
+}
+
+// jsonRpcParams extracts the raw "params" of a json-rpc request, NativeCall and
+// NativeSubscribe carry only them, the method travels separately.
+func jsonRpcParams(request protocol.RequestHolder) ([]byte, error) {
+	body, err := request.Body()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing a request body: %v", err)
+	}
+	paramsNode, err := sonic.Get(body, "params")
+	if err != nil {
+		return []byte("[]"), nil
+	}
+	params, err := paramsNode.Raw()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing request params: %v", err)
+	}
+	return []byte(params), nil
+}
+
+// nativeSubscribeMethod returns the method and the payload of a NativeSubscribe request.
+// The server advertises eth_subscribe topics rather than eth_subscribe itself, so
+// eth_subscribe["logs", {...}] is sent as the logs method with the filter as its payload,
+// other subscribe methods are sent as is with their params
+func nativeSubscribeMethod(request protocol.RequestHolder) (string, []byte, error) {
+	params, err := jsonRpcParams(request)
+	if err != nil {
+		return "", nil, err
+	}
+	if request.Method() != "eth_subscribe" {
+		return request.Method(), params, nil
+	}
+
+	var args []json.RawMessage
+	if err = sonic.Unmarshal(params, &args); err != nil || len(args) == 0 {
+		return "", nil, fmt.Errorf("eth_subscribe params must start with a subscription type")
+	}
+	var topic string
+	if err = sonic.Unmarshal(args[0], &topic); err != nil {
+		return "", nil, fmt.Errorf("eth_subscribe subscription type must be a string")
+	}
+	if len(args) > 1 {
+		return topic, args[1], nil
+	}
+	return topic, nil, nil
+}
+
+type nativeCallJsonRpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// nativeCallErrorResponse rebuilds the upstream response of a failed item so it
+// goes through the same parsing (and retry classification) as an http one.
+// ErrorAsIs carries the original error body when the remote side has it.
+func nativeCallErrorResponse(request protocol.RequestHolder, replyItem *dshackle.NativeCallReplyItem) *protocol.GenericUpstreamResponse {
+	errorBody := replyItem.GetErrorAsIs()
+	if len(errorBody) == 0 {
+		jsonRpcError := nativeCallJsonRpcError{Code: int(replyItem.GetItemErrorCode()), Message: replyItem.GetErrorMessage()}
+		if errorData := replyItem.GetErrorData(); errorData != "" {
+			if json.Valid([]byte(errorData)) {
+				jsonRpcError.Data = json.RawMessage(errorData)
+			} else {
+				jsonRpcError.Data = errorData
+			}
+		}
+		errorBody, _ = sonic.Marshal(jsonRpcError)
+	}
+
+	if request.RequestType() == protocol.Rest {
+		status := int(replyItem.GetItemErrorCode())
+		if status < 400 || status > 599 {
+			status = http.StatusBadGateway
+		}
+		return protocol.NewHttpUpstreamResponse(request.Id(), errorBody, status, protocol.Rest)
+	}
+
+	body := make([]byte, 0, len(errorBody)+32)
+	body = append(body, `{"jsonrpc":"2.0","id":1,"error":`...)
+	body = append(body, errorBody...)
+	body = append(body, '}')
+	return protocol.NewHttpUpstreamResponse(request.Id(), body, http.StatusOK, protocol.JsonRpc)
+}
+
+func mapToKeyValues(values map[string][]string) []*dshackle.KeyValue {
+	keyValues := make([]*dshackle.KeyValue, 0, len(values))
+	for key, items := range values {
+		for _, value := range items {
+			keyValues = append(keyValues, &dshackle.KeyValue{Key: key, Value: value})
+		}
+	}
+	return keyValues
+}
+
+func mapResponseHeaders(keyValues []*dshackle.KeyValue) http.Header {
+	if len(keyValues) == 0 {
+		return nil
+	}
+	headers := make(http.Header, len(keyValues))
+	for _, keyValue := range keyValues {
+		headers.Add(keyValue.GetKey(), keyValue.GetValue())
+	}
+	return headers
+}
+
+var _ ApiConnector = (*GrpcConnector)(nil)
+var _ HeadSubscriber = (*GrpcConnector)(nil)
@@ -0,0 +1,358 @@
+package connectors_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/server/emerald"
+	"github.com/drpcorg/nodecore/internal/server/server_ctx"
+	"github.com/drpcorg/nodecore/internal/signature"
+	"github.com/drpcorg/nodecore/internal/tracing"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/internal/upstreams/connectors"
+	"github.com/drpcorg/nodecore/internal/upstreams/flow/subengine"
+	"github.com/drpcorg/nodecore/internal/upstreams/fork_choice"
+	"github.com/drpcorg/nodecore/pkg/blockchain"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/dshackle"
+	"github.com/drpcorg/nodecore/pkg/methods"
+	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+type testBlockchainServer struct {
+	dshackle.UnimplementedBlockchainServer
+
+	callReplies      []*dshackle.NativeCallReplyItem
+	subscribeReplies []*dshackle.NativeSubscribeReplyItem
+	heads            []*dshackle.ChainHead
+
+	callRequests      chan *dshackle.NativeCallRequest
+	subscribeRequests chan *dshackle.NativeSubscribeRequest
+	callMetadata      chan metadata.MD
+}
+
+func newTestBlockchainServer() *testBlockchainServer {
+	return &testBlockchainServer{
+		callRequests:      make(chan *dshackle.NativeCallRequest, 10),
+		subscribeRequests: make(chan *dshackle.NativeSubscribeRequest, 10),
+		callMetadata:      make(chan metadata.MD, 10),
+	}
+}
+
+func (s *testBlockchainServer) NativeCall(request *dshackle.NativeCallRequest, stream dshackle.Blockchain_NativeCallServer) error {
+	s.callRequests <- request
+	md, _ := metadata.FromIncomingContext(stream.Context())
+	s.callMetadata <- md
+	for _, reply := range s.callReplies {
+		if err := stream.Send(reply); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *testBlockchainServer) NativeSubscribe(request *dshackle.NativeSubscribeRequest, stream dshackle.Blockchain_NativeSubscribeServer) error {
+	s.subscribeRequests <- request
+	for _, reply := range s.subscribeReplies {
+		if err := stream.Send(reply); err != nil {
+			return err
+		}
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func (s *testBlockchainServer) SubscribeHead(_ *dshackle.Chain, stream dshackle.Blockchain_SubscribeHeadServer) error {
+	for _, head := range s.heads {
+		if err := stream.Send(head); err != nil {
+			return err
+		}
+	}
+	<-stream.Context().Done()
+	return nil
+}
+
+func startTestBlockchainServer(t *testing.T, server dshackle.BlockchainServer) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	grpcServer := grpc.NewServer()
+	dshackle.RegisterBlockchainServer(grpcServer, server)
+	go func() {
+		_ = grpcServer.Serve(listener)
+	}()
+	t.Cleanup(grpcServer.Stop)
+
+	return fmt.Sprintf("grpc://%s", listener.Addr().String())
+}
+
+func newTestGrpcConnector(t *testing.T, url string, headers map[string]string) *connectors.GrpcConnector {
+	connector, err := connectors.NewGrpcConnector(
+		&config.ApiConnectorConfig{Type: "grpc", Url: url, Headers: headers},
+		chains.GetChain("ethereum"),
+		"",
+		"id",
+	)
+	require.NoError(t, err)
+	connector.Start()
+	t.Cleanup(connector.Stop)
+	return connector
+}
+
+func TestGrpcConnectorType(t *testing.T) {
+	connector := newTestGrpcConnector(t, "grpc://localhost:2449", nil)
+
+	assert.Equal(t, specs.GrpcConnector, connector.GetType())
+	assert.Equal(t, "grpc://localhost:2449", connector.GetUrl())
+}
+
+func TestGrpcConnectorUnsupportedSchemeThenError(t *testing.T) {
+	_, err := connectors.NewGrpcConnector(
+		&config.ApiConnectorConfig{Type: "grpc", Url: "ws://localhost:2449"},
+		chains.GetChain("ethereum"),
+		"",
+		"id",
+	)
+
+	assert.ErrorContains(t, err, "unsupported grpc endpoint scheme 'ws'")
+}
+
+func TestGrpcConnectorSendJsonRpcRequestThenResult(t *testing.T) {
+	server := newTestBlockchainServer()
+	server.callReplies = []*dshackle.NativeCallReplyItem{
+		{Id: 1, Succeed: true, Payload: []byte(`"0x10"`), ResponseHeaders: []*dshackle.KeyValue{{Key: "X-Upstream", Value: "node"}}},
+	}
+	connector := newTestGrpcConnector(t, startTestBlockchainServer(t, server), map[string]string{"SessionId": "session"})
+	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_getBalance", Params: []byte(`["0x1","latest"]`)}
+	request := protocol.NewUpstreamJsonRpcRequest("223", body, false, "")
+
+	response := connector.SendRequest(context.Background(), request)
+
+	assert.IsType(t, &protocol.GenericUpstreamResponse{}, response)
+	assert.False(t, response.HasError())
+	assert.Equal(t, "223", response.Id())
+	assert.Equal(t, []byte(`"0x10"`), response.ResponseResult())
+	assert.Equal(t, "node", response.(*protocol.GenericUpstreamResponse).ResponseHeaders().Get("X-Upstream"))
+
+	callRequest := <-server.callRequests
+	assert.Equal(t, dshackle.ChainRef(chains.GetChain("ethereum").GrpcId), callRequest.GetChain())
+	require.Len(t, callRequest.GetItems(), 1)
+	assert.Equal(t, "eth_getBalance", callRequest.GetItems()[0].GetMethod())
+	assert.Equal(t, []byte(`["0x1","latest"]`), callRequest.GetItems()[0].GetPayload())
+	assert.Equal(t, []string{"session"}, (<-server.callMetadata).Get("sessionid"))
+}
+
+func TestGrpcConnectorSendRequestWithTracingThenClientSpanPropagated(t *testing.T) {
+	recorder := test_utils.EnableTracing(t, true)
+	server := newTestBlockchainServer()
+	server.callReplies = []*dshackle.NativeCallReplyItem{{Id: 1, Succeed: true, Payload: []byte(`"0x10"`)}}
+	connector := newTestGrpcConnector(t, startTestBlockchainServer(t, server), map[string]string{"SessionId": "session"})
+	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_getBalance", Params: []byte(`["0x1","latest"]`)}
+	request := protocol.NewUpstreamJsonRpcRequest("1", body, false, "")
+
+	response := connector.SendRequest(context.Background(), request)
+
+	assert.False(t, response.HasError())
+	spans := test_utils.EndedSpans(recorder, "connector.native-call")
+	require.Len(t, spans, 1)
+	assert.Equal(t, trace.SpanKindClient, spans[0].SpanKind())
+	assert.Equal(t, "id", test_utils.SpanAttribute(spans[0], tracing.UpstreamIdKey).AsString())
+	assert.Equal(t, "ok", test_utils.SpanAttribute(spans[0], tracing.ResponseKindKey).AsString())
+	callMetadata := <-server.callMetadata
+	expected := "00-" + spans[0].SpanContext().TraceID().String() + "-" + spans[0].SpanContext().SpanID().String() + "-01"
+	assert.Equal(t, []string{expected}, callMetadata.Get("traceparent"))
+	assert.Equal(t, []string{"session"}, callMetadata.Get("sessionid"))
+}
+
+func TestGrpcConnectorSendJsonRpcRequestThenChunkedResult(t *testing.T) {
+	server := newTestBlockchainServer()
+	server.callReplies = []*dshackle.NativeCallReplyItem{
+		{Id: 1, Succeed: true, Chunked: true, Payload: []byte(`[{"a":`)},
+		{Id: 1, Succeed: true, Chunked: true, Payload: []byte(`1}]`)},
+		{Id: 1, Succeed: true, Chunked: true, FinalChunk: true},
+	}
+	connector := newTestGrpcConnector(t, startTestBlockchainServer(t, server), nil)
+	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_getLogs", Params: []byte(`[{}]`)}
+	request := protocol.NewUpstreamJsonRpcRequest("1", body, false, "")
+
+	response := connector.SendRequest(context.Background(), request)
+
+	assert.False(t, response.HasError())
+	assert.Equal(t, []byte(`[{"a":1}]`), response.ResponseResult())
+}
+
+func TestGrpcConnectorSendJsonRpcRequestThenError(t *testing.T) {
+	server := newTestBlockchainServer()
+	server.callReplies = []*dshackle.NativeCallReplyItem{
+		{Id: 1, Succeed: false, ItemErrorCode: 3, ErrorMessage: "execution reverted", ErrorData: `"0x08c379a0"`},
+	}
+	connector := newTestGrpcConnector(t, startTestBlockchainServer(t, server), nil)
+	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call", Params: []byte(`[{}]`)}
+	request := protocol.NewUpstreamJsonRpcRequest("1", body, false, "")
+
+	response := connector.SendRequest(context.Background(), request)
+
+	assert.IsType(t, &protocol.GenericUpstreamResponse{}, response)
+	assert.True(t, response.HasError())
+	assert.Equal(t, protocol.ResponseErrorWithData(3, "execution reverted", "0x08c379a0"), response.GetError())
+}
+
+func TestGrpcConnectorSendRestRequestThenResult(t *testing.T) {
+	server := newTestBlockchainServer()
+	server.callReplies = []*dshackle.NativeCallReplyItem{
+		{Id: 1, Succeed: true, Payload: []byte(`{"height":10}`)},
+	}
+	connector := newTestGrpcConnector(t, startTestBlockchainServer(t, server), nil)
+	requestParams := &protocol.RequestParams{PathParams: []string{"10"}, QueryParams: map[string][]string{"full": {"true"}}}
+	request := protocol.NewUpstreamRestRequest("1", "GET#/blocks/*", requestParams, nil, "")
+
+	response := connector.SendRequest(context.Background(), request)
+
+	assert.False(t, response.HasError())
+	assert.Equal(t, []byte(`{"height":10}`), response.ResponseResult())
+
+	item := (<-server.callRequests).GetItems()[0]
+	assert.Equal(t, "GET#/blocks/*", item.GetMethod())
+	assert.Equal(t, []string{"10"}, item.GetRestData().GetPathParams())
+	assert.Equal(t, []*dshackle.KeyValue{{Key: "full", Value: "true"}}, item.GetRestData().GetQueryParams())
+}
+
+func TestGrpcConnectorUnavailableThenPartialFailure(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	url := fmt.Sprintf("grpc://%s", listener.Addr().String())
+	require.NoError(t, listener.Close())
+	connector := newTestGrpcConnector(t, url, nil)
+	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_chainId", Params: []byte(`[]`)}
+	request := protocol.NewUpstreamJsonRpcRequest("1", body, false, "")
+
+	response := connector.SendRequest(context.Background(), request)
+
+	assert.IsType(t, &protocol.ReplyError{}, response)
+	assert.True(t, protocol.IsRetryable(response))
+	assert.Equal(t, "internal server error: upstream id request failed", response.GetError().Message)
+}
+
+func TestGrpcConnectorSubscribeThenEvents(t *testing.T) {
+	server := newTestBlockchainServer()
+	server.subscribeReplies = []*dshackle.NativeSubscribeReplyItem{
+		{Payload: []byte(`{"number":"0x1"}`)},
+		{Heartbeat: true},
+		{Payload: []byte(`{"number":"0x2"}`)},
+	}
+	connector := newTestGrpcConnector(t, startTestBlockchainServer(t, server), nil)
+	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_subscribe", Params: []byte(`["newHeads"]`)}
+	request := protocol.NewUpstreamJsonRpcRequest("1", body, true, "")
+
+	subResponse, err := connector.Subscribe(context.Background(), request)
+	require.NoError(t, err)
+
+	subscribeRequest := <-server.subscribeRequests
+	assert.Equal(t, "newHeads", subscribeRequest.GetMethod())
+	assert.Empty(t, subscribeRequest.GetPayload())
+
+	for _, expected := range []string{`{"number":"0x1"}`, `{"number":"0x2"}`} {
+		select {
+		case event := <-subResponse.ResponseChan():
+			assert.Equal(t, protocol.Ws, event.Type)
+			assert.Equal(t, subResponse.OpId(), event.SubId)
+			assert.Equal(t, []byte(expected), event.Message)
+		case <-time.After(5 * time.Second):
+			t.Fatal("no subscription event")
+		}
+	}
+
+	connector.Unsubscribe(subResponse.OpId())
+
+	select {
+	case _, ok := <-subResponse.ResponseChan():
+		assert.False(t, ok)
+	case <-time.After(5 * time.Second):
+		t.Fatal("subscription hasn't been closed")
+	}
+}
+
+func TestGrpcConnectorSubscribeToBlockchainServiceThenEvents(t *testing.T) {
+	require.NoError(t, specs.NewMethodSpecLoader().Load())
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the served chain advertises newHeads only, local heads are off so the node subscription is used
+	upstreamMethods := mocks.NewMethodsMock()
+	upstreamMethods.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("eth_subscribe")).Maybe()
+	upstreamMethods.On("HasMethod", mock.Anything).Return(true).Maybe()
+	upstreamState := protocol.DefaultUpstreamState(upstreamMethods, mapset.NewThreadUnsafeSet(protocol.WsCap, protocol.NewHeadsCap), "00012", nil, nil)
+	upstreamState.Status = protocol.Available
+	chainSupervisor := upstreams.NewGenericChainSupervisor(ctx, chains.ETHEREUM, fork_choice.NewHeightForkChoice(), nil, false, nil)
+	go chainSupervisor.Start()
+	chainSupervisor.PublishUpstreamEvent(protocol.UpstreamEvent{Id: "id", EventType: &protocol.StateUpstreamEvent{State: &upstreamState}})
+	require.Eventually(t, func() bool {
+		return chainSupervisor.GetChainState().SubMethods.ContainsOne("newHeads")
+	}, 5*time.Second, 10*time.Millisecond)
+
+	wsConnector := mocks.NewWsConnectorMock()
+	events := make(chan *protocol.WsResponse, 1)
+	events <- &protocol.WsResponse{SubId: "upstream-sub", Message: []byte(`{"number":"0x1"}`)}
+	wsConnector.On("Subscribe", mock.Anything, mock.Anything).Return(protocol.NewJsonRpcWsUpstreamResponse(events, "op-1"), nil)
+	wsConnector.On("SubscribeStates", mock.Anything).Return(nil).Maybe()
+	wsConnector.On("Unsubscribe", mock.Anything).Return().Maybe()
+	upstream := test_utils.TestEvmUpstream(wsConnector, &config.Upstream{Id: "id", Options: &chains.Options{InternalTimeout: 5 * time.Second}}, upstreamMethods, nil)
+
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	upSupervisor.On("GetChainSupervisor", chains.ETHEREUM).Return(chainSupervisor)
+	upSupervisor.On("GetUpstream", "id").Return(upstream)
+	appConfig := &config.AppConfig{UpstreamConfig: &config.UpstreamConfig{ChainDefaults: map[string]*config.ChainDefaults{
+		"ethereum": {LocalSubscriptions: &config.LocalSubscriptionsConfig{Enable: new(false)}},
+	}}}
+	appCtx := server_ctx.NewApplicationServerContext(upSupervisor, nil, nil, nil, appConfig, nil, nil, nil, nil, subengine.NewRegistry(ctx), nil, nil, nil)
+	service := emerald.NewGrpcBlockchainService(appCtx, nil, signature.NewDisabledSigner())
+
+	connector := newTestGrpcConnector(t, startTestBlockchainServer(t, service), nil)
+	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_subscribe", Params: []byte(`["newHeads"]`)}
+	request := protocol.NewUpstreamJsonRpcRequest("1", body, true, "")
+
+	subResponse, err := connector.Subscribe(ctx, request)
+	require.NoError(t, err)
+
+	select {
+	case event := <-subResponse.ResponseChan():
+		assert.Nil(t, event.Error)
+		assert.Equal(t, []byte(`{"number":"0x1"}`), event.Message)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no subscription event")
+	}
+	connector.Unsubscribe(subResponse.OpId())
+}
+
+func TestGrpcConnectorSubscribeHeadThenBlocks(t *testing.T) {
+	server := newTestBlockchainServer()
+	server.heads = []*dshackle.ChainHead{
+		{Height: 100, Slot: 5, BlockId: "0a0b", ParentBlockId: "0c0d"},
+	}
+	connector := newTestGrpcConnector(t, startTestBlockchainServer(t, server), nil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	heads, err := connector.SubscribeHead(ctx)
+	require.NoError(t, err)
+
+	select {
+	case block := <-heads:
+		assert.Equal(t, protocol.NewBlock(100, 5, blockchain.NewHashIdFromString("0a0b"), blockchain.NewHashIdFromString("0c0d")), block)
+	case <-time.After(5 * time.Second):
+		t.Fatal("no head")
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/quorum"
+	"github.com/drpcorg/nodecore/internal/tracing"
 	"github.com/drpcorg/nodecore/pkg/methods"
 	"github.com/drpcorg/nodecore/pkg/utils"
 	"github.com/rs/zerolog"
@@ -212,6 +213,7 @@ func (h *HttpConnector) sendJsonRpc(ctx context.Context, request protocol.Reques
 		return clientFailure(request, fmt.Errorf("error creating an http request: %v", err))
 	}
 	h.applyConfigHeaders(req)
+	tracing.InjectHttp(ctx, req.Header)
 
 	// JSON-RPC streaming requires peeking the body to distinguish an error
 	// envelope from a result value before we commit to streaming it.
@@ -270,6 +272,7 @@ func (h *HttpConnector) sendRest(ctx context.Context, request protocol.RequestHo
 	if rp != nil {
 		h.applyClientHeaders(req, rp.Headers, body)
 	}
+	tracing.InjectHttp(ctx, req.Header)
 
 	// REST bodies are opaque pass-through; if the caller asked for streaming
 	// we hand them whatever the upstream gave us.
@@ -5,6 +5,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -13,6 +14,7 @@ import (
 
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/tracing"
 	"github.com/drpcorg/nodecore/internal/upstreams/connectors"
 	"github.com/drpcorg/nodecore/pkg/chains"
 	"github.com/drpcorg/nodecore/pkg/methods"
@@ -892,3 +894,32 @@ func TestRestConnector_RejectsNonRestRequest(t *testing.T) {
 		"feeding a JSON-RPC request to the REST connector must surface a client error")
 	assert.Equal(t, protocol.ClientErrorCode, r.GetError().Code)
 }
+
+func TestJsonRpcRequestWithTracePropagationThenTraceparentSent(t *testing.T) {
+	for _, propagate := range []bool{true, false} {
+		t.Run(fmt.Sprintf("propagate %v", propagate), func(te *testing.T) {
+			test_utils.EnableTracing(te, propagate)
+			traceparentChan := make(chan string, 1)
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				traceparentChan <- r.Header.Get("traceparent")
+				_, _ = w.Write([]byte(`{"id": 1, "jsonrpc": "2.0", "result": "0x1"}`))
+			}))
+			defer srv.Close()
+
+			connector := connectors.NewHttpConnectorWithDefaultClient(&config.ApiConnectorConfig{Url: srv.URL}, specs.JsonRpcConnector, "", "test-upstream")
+			req, _ := protocol.NewInternalUpstreamJsonRpcRequest("eth_chainId", nil, chains.ETHEREUM)
+			ctx, span := tracing.StartClientSpan(context.Background(), "connector.send")
+			defer span.End()
+
+			r := connector.SendRequest(ctx, req)
+
+			require.False(te, r.HasError())
+			traceparent := <-traceparentChan
+			if propagate {
+				assert.Equal(te, fmt.Sprintf("00-%s-%s-01", span.SpanContext().TraceID(), span.SpanContext().SpanID()), traceparent)
+			} else {
+				assert.Empty(te, traceparent)
+			}
+		})
+	}
+}
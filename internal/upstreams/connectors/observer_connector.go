@@ -2,6 +2,7 @@ package connectors
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/drpcorg/nodecore/internal/protocol"
@@ -17,7 +18,7 @@ type ObserverConnector struct {
 	chain                 chains.Chain
 	upstreamId            string
 	responseReceivedHooks []protocol.ResponseReceivedHook
-	executor              failsafe.Executor[protocol.ResponseHolder]
+	executors             *resilience.MethodExecutors[protocol.ResponseHolder]
 }
 
 func (o *ObserverConnector) GetUrl() string {
@@ -33,13 +34,13 @@ func NewObserverConnector(
 	upstreamId string,
 	delegate ApiConnector,
 	responseReceivedHooks []protocol.ResponseReceivedHook,
-	executor failsafe.Executor[protocol.ResponseHolder],
+	executors *resilience.MethodExecutors[protocol.ResponseHolder],
 ) *ObserverConnector {
 	return &ObserverConnector{
 		chain:                 chain,
 		delegate:              delegate,
 		upstreamId:            upstreamId,
-		executor:              executor,
+		executors:             executors,
 		responseReceivedHooks: responseReceivedHooks,
 	}
 }
@@ -60,7 +61,7 @@ func (o *ObserverConnector) SendRequest(ctx context.Context, request protocol.Re
 		reqObserver.WithChain(o.chain)
 	}
 
-	response, _ := o.executor.
+	response, _ := o.executors.Get(request.Method()).
 		WithContext(executorCtx).
 		GetWithExecution(func(exec failsafe.Execution[protocol.ResponseHolder]) (protocol.ResponseHolder, error) {
 			return o.sendRequest(ctx, exec, request)
@@ -80,6 +81,14 @@ func (o *ObserverConnector) Subscribe(ctx context.Context, holder protocol.Reque
 	return o.delegate.Subscribe(ctx, holder)
 }
 
+func (o *ObserverConnector) SubscribeHead(ctx context.Context) (chan protocol.Block, error) {
+	headSubscriber, ok := o.delegate.(HeadSubscriber)
+	if !ok {
+		return nil, fmt.Errorf("connector %s of upstream %s can't subscribe to heads", o.delegate.GetType(), o.upstreamId)
+	}
+	return headSubscriber.SubscribeHead(ctx)
+}
+
 func (o *ObserverConnector) GetType() specs.ApiConnectorType {
 	return o.delegate.GetType()
 }
@@ -126,3 +135,4 @@ func (o *ObserverConnector) sendRequest(
 }
 
 var _ ApiConnector = (*ObserverConnector)(nil)
+var _ HeadSubscriber = (*ObserverConnector)(nil)
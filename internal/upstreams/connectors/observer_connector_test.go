@@ -22,9 +22,9 @@ import (
 func TestObserverConnectorSuccessfulResponse(t *testing.T) {
 	tracker := dimensions.NewGenericDimensionTracker()
 	hooks := []protocol.ResponseReceivedHook{dimensions.NewDimensionHook(tracker)}
-	executor := resilience.CreateUpstreamExecutor()
+	executors := resilience.NewMethodExecutors(resilience.CreateUpstreamExecutor())
 	connectorMock := mocks.NewConnectorMock()
-	observerConnector := connectors.NewObserverConnector(chains.ARBITRUM, "id", connectorMock, hooks, executor)
+	observerConnector := connectors.NewObserverConnector(chains.ARBITRUM, "id", connectorMock, hooks, executors)
 
 	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call", Params: nil}
 	request := protocol.NewUpstreamJsonRpcRequest("223", body, false, "")
@@ -49,11 +49,11 @@ func TestObserverConnectorSuccessfulResponse(t *testing.T) {
 func TestObserverConnectorRetryRequest(t *testing.T) {
 	tracker := dimensions.NewGenericDimensionTracker()
 	hooks := []protocol.ResponseReceivedHook{dimensions.NewDimensionHook(tracker)}
-	executor := resilience.CreateUpstreamExecutor(
+	executors := resilience.NewMethodExecutors(resilience.CreateUpstreamExecutor(
 		resilience.CreateUpstreamRetryPolicy(&config.RetryConfig{Attempts: 3, Delay: 10 * time.Millisecond}),
-	)
+	))
 	connectorMock := mocks.NewConnectorMock()
-	observerConnector := connectors.NewObserverConnector(chains.ARBITRUM, "id", connectorMock, hooks, executor)
+	observerConnector := connectors.NewObserverConnector(chains.ARBITRUM, "id", connectorMock, hooks, executors)
 
 	body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call", Params: nil}
 	request := protocol.NewUpstreamJsonRpcRequest("223", body, false, "")
@@ -135,9 +135,9 @@ func TestObserverConnectorRetryableNonRetryableErrors(t *testing.T) {
 		t.Run(test.name, func(te *testing.T) {
 			tracker := dimensions.NewGenericDimensionTracker()
 			hooks := []protocol.ResponseReceivedHook{dimensions.NewDimensionHook(tracker)}
-			executor := resilience.CreateUpstreamExecutor()
+			executors := resilience.NewMethodExecutors(resilience.CreateUpstreamExecutor())
 			connectorMock := mocks.NewConnectorMock()
-			observerConnector := connectors.NewObserverConnector(chains.ARBITRUM, "id", connectorMock, hooks, executor)
+			observerConnector := connectors.NewObserverConnector(chains.ARBITRUM, "id", connectorMock, hooks, executors)
 
 			body := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call", Params: nil}
 			request := protocol.NewUpstreamJsonRpcRequest("223", body, false, "")
@@ -2,11 +2,34 @@ package flow
 
 import (
 	"context"
+	"net/http"
 
 	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/quorum"
+	"github.com/drpcorg/nodecore/internal/resilience"
 	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var staleResponsesMetric = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Namespace: config.AppName,
+		Subsystem: "request",
+		Name:      "cache_stale",
+		Help:      "The total number of RPC requests served from cache after the ttl of the response, by reason",
+	},
+	[]string{"chain", "method", "reason"},
+)
+
+func init() {
+	prometheus.MustRegister(staleResponsesMetric)
+}
+
+const (
+	staleReasonRevalidate = "revalidate"
+	staleReasonError      = "error"
 )
 
 // CacheRequestProcessor decorates another RequestProcessor with caching: it does
@@ -14,20 +37,52 @@ import (
 // stores a cacheable response. A cache hit short-circuits the inner processor
 // entirely, so expensive delegates (e.g. NotNullRequestProcessor walking upstreams)
 // don't run when the answer is already cached.
+// On a miss identical in-flight requests are coalesced into one delegate call if
+// the coalescer is set, so a burst of the same uncached request goes upstream once.
+//
+// A stale response, one past its ttl but within the stale window of its policy, is
+// served right away and refreshed in the background by a new request with its own
+// upstream strategy, built by the revalidation strategy factory. Past the stale window,
+// within the stale-if-error window of its policy, a stale response is served only
+// when all upstreams fail with a retryable error.
+// Stale responses are marked with the X-Nodecore-Stale header.
+//
+// Non-retryable upstream errors of JSON-RPC requests are stored too and replayed with
+// their original code and message by the policies caching errors.
+//
+// A client controls the cache of its request with the Cache-Control directives carried by the context:
+// no-cache skips cached responses, no-store skips caching the response and max-age limits the age of
+// a cached response. Responses served from the cache are marked with the X-Nodecore-Cache header.
 type CacheRequestProcessor struct {
 	chain          chains.Chain
 	cacheProcessor caches.CacheProcessor
+	coalescer      *RequestCoalescer
 	delegate       RequestProcessor
+	// newStrategy builds the upstream strategy of a revalidation request, stale responses aren't revalidated if nil
+	newStrategy func(ctx context.Context, request protocol.RequestHolder) UpstreamStrategy
 }
 
-func NewCacheRequestProcessor(chain chains.Chain, cacheProcessor caches.CacheProcessor, delegate RequestProcessor) *CacheRequestProcessor {
+func NewCacheRequestProcessor(
+	chain chains.Chain,
+	cacheProcessor caches.CacheProcessor,
+	coalescer *RequestCoalescer,
+	delegate RequestProcessor,
+) *CacheRequestProcessor {
 	return &CacheRequestProcessor{
 		chain:          chain,
 		cacheProcessor: cacheProcessor,
+		coalescer:      coalescer,
 		delegate:       delegate,
 	}
 }
 
+func (p *CacheRequestProcessor) WithRevalidationStrategy(
+	newStrategy func(ctx context.Context, request protocol.RequestHolder) UpstreamStrategy,
+) *CacheRequestProcessor {
+	p.newStrategy = newStrategy
+	return p
+}
+
 var _ RequestProcessor = (*CacheRequestProcessor)(nil)
 
 func (p *CacheRequestProcessor) ProcessRequest(
@@ -42,27 +97,167 @@ func (p *CacheRequestProcessor) ProcessRequest(
 		return p.delegate.ProcessRequest(ctx, upstreamStrategy, request)
 	}
 
-	if result, ok := p.cacheProcessor.Receive(ctx, p.chain, request); ok {
-		// change the previous request type since it will not be sent to the upstream
-		request.RequestObserver().
-			WithRequestKind(protocol.Cached)
-		return &UnaryResponse{
-			ResponseWrapper: &protocol.ResponseHolderWrapper{
-				UpstreamId: NoUpstream,
-				RequestId:  request.Id(),
-				Response:   protocol.NewSimpleHttpUpstreamResponse(request.Id(), result, request.RequestType()),
-			},
+	cacheControl, _ := caches.CacheControlFromContext(ctx)
+
+	var staleResult *protocol.CachedResponse
+	if cached, ok := p.receive(ctx, request, cacheControl); ok {
+		switch {
+		case cached.Error:
+			return p.cachedErrorResponse(request, cached)
+		case !cached.Stale:
+			return p.cachedResponse(request, cached)
+		case !cached.ServeStaleOnError:
+			staleResponsesMetric.WithLabelValues(p.chain.String(), request.Method(), staleReasonRevalidate).Inc()
+			response := p.cachedResponse(request, cached)
+			if !cacheControl.NoStore {
+				go p.revalidate(context.WithoutCancel(ctx), request)
+			}
+			return response
+		default:
+			staleResult = cached
 		}
 	}
 
-	processedResponse := p.delegate.ProcessRequest(ctx, upstreamStrategy, request)
+	processedResponse, shared := p.processOnMiss(ctx, upstreamStrategy, request)
+	if staleResult != nil && upstreamsFailed(processedResponse) {
+		staleResponsesMetric.WithLabelValues(p.chain.String(), request.Method(), staleReasonError).Inc()
+		return p.cachedResponse(request, staleResult)
+	}
+	staleResult.Close()
+	if shared || cacheControl.NoStore {
+		// the leader of the coalesced requests stores the response, unless its client asks not to
+		return processedResponse
+	}
 
+	p.store(ctx, request, processedResponse)
+
+	return processedResponse
+}
+
+// revalidate refreshes a stale response that has already been served. The request and
+// the strategy of the client are done with, so the refresh is a new request with a new
+// strategy. Refreshes of the same request are not repeated while one of them is in flight.
+func (p *CacheRequestProcessor) revalidate(ctx context.Context, request protocol.RequestHolder) {
+	if p.newStrategy == nil || (p.coalescer != nil && p.coalescer.inFlight(p.chain, request)) {
+		return
+	}
+	revalidation, ok := protocol.NewRevalidationRequest(request)
+	if !ok {
+		return
+	}
+	revalidation.RequestObserver().WithChain(p.chain)
+	ctx = context.WithValue(ctx, resilience.RequestKey, revalidation)
+
+	processedResponse, shared := p.processOnMiss(ctx, p.newStrategy(ctx, revalidation), revalidation)
+	if !shared {
+		p.store(ctx, revalidation, processedResponse)
+	}
+}
+
+func (p *CacheRequestProcessor) store(ctx context.Context, request protocol.RequestHolder, processedResponse ProcessedResponse) {
 	if unaryResponse, ok := processedResponse.(*UnaryResponse); ok {
 		response := unaryResponse.ResponseWrapper.Response
-		if !response.HasError() && !response.HasStream() {
+		switch {
+		case response.HasStream():
+		case !response.HasError():
 			go p.cacheProcessor.Store(ctx, p.chain, request, response.ResponseResult())
+		case cacheableError(request, response):
+			go p.cacheProcessor.StoreError(ctx, p.chain, request, response.ResponseResult())
 		}
 	}
+}
 
-	return processedResponse
+// cacheableError is true if an upstream error of a JSON-RPC request isn't retryable, so the same request would
+// fail the same way again. Errors produced by nodecore itself, e.g. when no upstream is available, aren't cached.
+func cacheableError(request protocol.RequestHolder, response protocol.ResponseHolder) bool {
+	upstreamResponse, ok := response.(*protocol.GenericUpstreamResponse)
+	if !ok || request.RequestType() != protocol.JsonRpc || len(upstreamResponse.ResponseResult()) == 0 {
+		return false
+	}
+	if upstreamResponse.GetError().Code == protocol.IncorrectResponseBody {
+		return false
+	}
+	return !protocol.IsRetryable(upstreamResponse)
+}
+
+// receive skips the cache if the client asks for a response from upstreams
+func (p *CacheRequestProcessor) receive(
+	ctx context.Context,
+	request protocol.RequestHolder,
+	cacheControl caches.CacheControl,
+) (*protocol.CachedResponse, bool) {
+	if cacheControl.NoCache {
+		return nil, false
+	}
+	return p.cacheProcessor.Receive(ctx, p.chain, request)
+}
+
+func (p *CacheRequestProcessor) cachedResponse(request protocol.RequestHolder, cached *protocol.CachedResponse) *UnaryResponse {
+	var response *protocol.GenericUpstreamResponse
+	if cached.Stream != nil {
+		// the cached result is decompressed while it's written to the client
+		response = protocol.NewResultResponseStream(request.Id(), cached.Stream, request.RequestType())
+	} else {
+		response = protocol.NewSimpleHttpUpstreamResponse(request.Id(), cached.Result, request.RequestType())
+	}
+	return p.cachedUnaryResponse(request, response.WithResponseHeaders(cachedResponseHeaders(cached)))
+}
+
+func (p *CacheRequestProcessor) cachedErrorResponse(request protocol.RequestHolder, cached *protocol.CachedResponse) *UnaryResponse {
+	response := protocol.NewJsonRpcUpstreamErrorResponse(request.Id(), cached.Result)
+	return p.cachedUnaryResponse(request, response.WithResponseHeaders(cachedResponseHeaders(cached)))
+}
+
+// cachedResponseHeaders tell a client its response is served from the cache and by which policy
+func cachedResponseHeaders(cached *protocol.CachedResponse) http.Header {
+	headers := http.Header{}
+	status := caches.CacheStatusHit
+	if cached.Stale {
+		status = caches.CacheStatusStale
+		headers.Set(protocol.XNodecoreStale, "true")
+	}
+	headers.Set(protocol.XNodecoreCache, caches.CacheStatus(status, cached.Policy, cached.Connector))
+	return headers
+}
+
+func (p *CacheRequestProcessor) cachedUnaryResponse(request protocol.RequestHolder, response protocol.ResponseHolder) *UnaryResponse {
+	// change the previous request type since it will not be sent to the upstream
+	request.RequestObserver().
+		WithRequestKind(protocol.Cached)
+	return &UnaryResponse{
+		ResponseWrapper: &protocol.ResponseHolderWrapper{
+			UpstreamId: NoUpstream,
+			RequestId:  request.Id(),
+			Response:   response,
+		},
+	}
+}
+
+// upstreamsFailed is true if the request couldn't be served because all upstreams failed
+// with a retryable error or none of them was available
+func upstreamsFailed(processedResponse ProcessedResponse) bool {
+	unaryResponse, ok := processedResponse.(*UnaryResponse)
+	if !ok || unaryResponse.ResponseWrapper == nil {
+		return false
+	}
+	response := unaryResponse.ResponseWrapper.Response
+	if protocol.IsRetryable(response) {
+		return true
+	}
+	return response.HasError() && response.GetError().Code == protocol.NoAvailableUpstreams
+}
+
+func (p *CacheRequestProcessor) processOnMiss(
+	ctx context.Context,
+	upstreamStrategy UpstreamStrategy,
+	request protocol.RequestHolder,
+) (ProcessedResponse, bool) {
+	process := func() ProcessedResponse {
+		return p.delegate.ProcessRequest(ctx, upstreamStrategy, request)
+	}
+	// a streamed response can be read only once, so there is nothing to share
+	if p.coalescer == nil || request.IsStream() {
+		return process(), false
+	}
+	return p.coalescer.Do(ctx, p.chain, request, process)
 }
@@ -2,9 +2,12 @@ package flow_test
 
 import (
 	"context"
+	"io"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/drpcorg/nodecore/internal/caches"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/quorum"
 	"github.com/drpcorg/nodecore/internal/upstreams/flow"
@@ -13,6 +16,7 @@ import (
 	"github.com/drpcorg/nodecore/pkg/test_utils"
 	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
 )
 
 func TestCacheRequestProcessorReceiveFromCacheSkipsDelegate(t *testing.T) {
@@ -25,9 +29,9 @@ func TestCacheRequestProcessorReceiveFromCacheSkipsDelegate(t *testing.T) {
 	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
 	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
 
-	cacheProcessor.On("Receive", ctx, chain, request).Return(result, true)
+	cacheProcessor.On("Receive", ctx, chain, request).Return(&protocol.CachedResponse{Result: result, Policy: "policy", Connector: "memory"}, true)
 
-	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, delegate)
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
 	response := processor.ProcessRequest(ctx, strategy, request)
 
 	assert.IsType(t, &flow.UnaryResponse{}, response)
@@ -45,6 +49,74 @@ func TestCacheRequestProcessorReceiveFromCacheSkipsDelegate(t *testing.T) {
 	assert.False(t, unaryRespWrapper.Response.HasError())
 	assert.False(t, unaryRespWrapper.Response.HasStream())
 	assert.Equal(t, result, unaryRespWrapper.Response.ResponseResult())
+	headers := unaryRespWrapper.Response.(protocol.HasResponseHeaders).ResponseHeaders()
+	assert.Equal(t, "HIT; policy=policy; connector=memory", headers.Get(protocol.XNodecoreCache))
+	assert.Empty(t, headers.Get(protocol.XNodecoreStale))
+}
+
+// closeRecorder is a cached stream that records whether it's closed
+type closeRecorder struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeRecorder) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestCacheRequestProcessorCachedStreamServedAsStream(t *testing.T) {
+	strategy := mocks.NewMockStrategy()
+	cacheProcessor := mocks.NewCacheProcessorMock()
+	delegate := NewRequestProcessorMock()
+	chain := chains.POLYGON
+	ctx := context.Background()
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`7`), Method: "eth_call"}
+	request := protocol.NewStreamUpstreamJsonRpcRequest("223", jsonBody, "")
+	stream := &closeRecorder{Reader: strings.NewReader(`{"hash":"0x1"}`)}
+
+	cacheProcessor.On("Receive", ctx, chain, request).Return(&protocol.CachedResponse{Stream: stream, Policy: "policy", Connector: "memory"}, true)
+
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
+	response := processor.ProcessRequest(ctx, strategy, request)
+
+	delegate.AssertNotCalled(t, "ProcessRequest")
+	unaryRespWrapper := response.(*flow.UnaryResponse).ResponseWrapper
+	assert.True(t, unaryRespWrapper.Response.HasStream())
+	body, err := io.ReadAll(unaryRespWrapper.Response.EncodeResponse([]byte(`7`)))
+	assert.NoError(t, err)
+	assert.Equal(t, `{"jsonrpc":"2.0","result":{"hash":"0x1"},"id":7}`, string(body))
+	headers := unaryRespWrapper.Response.(protocol.HasResponseHeaders).ResponseHeaders()
+	assert.Equal(t, "HIT; policy=policy; connector=memory", headers.Get(protocol.XNodecoreCache))
+}
+
+func TestCacheRequestProcessorServeStaleOnErrorUnusedStreamClosed(t *testing.T) {
+	strategy := mocks.NewMockStrategy()
+	cacheProcessor := mocks.NewCacheProcessorMock()
+	delegate := NewRequestProcessorMock()
+	chain := chains.POLYGON
+	ctx := context.Background()
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`7`), Method: "eth_call"}
+	request := protocol.NewStreamUpstreamJsonRpcRequest("223", jsonBody, "")
+	stream := &closeRecorder{Reader: strings.NewReader(`"stale"`)}
+	delegateResponse := &flow.UnaryResponse{ResponseWrapper: &protocol.ResponseHolderWrapper{
+		UpstreamId: "id",
+		RequestId:  "223",
+		Response:   protocol.NewSimpleHttpUpstreamResponse("223", []byte(`"fresh"`), protocol.JsonRpc),
+	}}
+
+	cacheProcessor.On("Receive", ctx, chain, request).
+		Return(&protocol.CachedResponse{Stream: stream, Stale: true, ServeStaleOnError: true}, true)
+	cacheProcessor.On("Store", ctx, chain, request, []byte(`"fresh"`)).Return()
+	delegate.On("ProcessRequest", ctx, strategy, request).Return(delegateResponse)
+
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
+	response := processor.ProcessRequest(ctx, strategy, request)
+
+	time.Sleep(10 * time.Millisecond)
+
+	assert.Same(t, delegateResponse, response)
+	assert.True(t, stream.closed)
 }
 
 func TestCacheRequestProcessorQuorumSkipsCacheAndDelegates(t *testing.T) {
@@ -64,7 +136,7 @@ func TestCacheRequestProcessorQuorumSkipsCacheAndDelegates(t *testing.T) {
 	// No cacheProcessor.On(...) — touching the cache under quorum fails the mock.
 	delegate.On("ProcessRequest", ctx, strategy, request).Return(delegateResponse)
 
-	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, delegate)
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
 	response := processor.ProcessRequest(ctx, strategy, request)
 
 	time.Sleep(10 * time.Millisecond)
@@ -90,11 +162,11 @@ func TestCacheRequestProcessorMissDelegatesAndStores(t *testing.T) {
 		Response:   protocol.NewSimpleHttpUpstreamResponse("223", result, protocol.JsonRpc),
 	}}
 
-	cacheProcessor.On("Receive", ctx, chain, request).Return([]byte{}, false)
+	cacheProcessor.On("Receive", ctx, chain, request).Return((*protocol.CachedResponse)(nil), false)
 	cacheProcessor.On("Store", ctx, chain, request, result).Return()
 	delegate.On("ProcessRequest", ctx, strategy, request).Return(delegateResponse)
 
-	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, delegate)
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
 	response := processor.ProcessRequest(ctx, strategy, request)
 
 	time.Sleep(10 * time.Millisecond)
@@ -118,10 +190,10 @@ func TestCacheRequestProcessorErrorResponseNotStored(t *testing.T) {
 		Response:   protocol.NewTotalFailureFromErr("223", assert.AnError, request.RequestType()),
 	}}
 
-	cacheProcessor.On("Receive", ctx, chain, request).Return([]byte{}, false)
+	cacheProcessor.On("Receive", ctx, chain, request).Return((*protocol.CachedResponse)(nil), false)
 	delegate.On("ProcessRequest", ctx, strategy, request).Return(delegateResponse)
 
-	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, delegate)
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
 	response := processor.ProcessRequest(ctx, strategy, request)
 
 	time.Sleep(10 * time.Millisecond)
@@ -142,10 +214,10 @@ func TestCacheRequestProcessorSubscriptionResponseNotStored(t *testing.T) {
 	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
 	delegateResponse := &flow.SubscriptionResponse{ResponseWrappers: make(chan *protocol.ResponseHolderWrapper)}
 
-	cacheProcessor.On("Receive", ctx, chain, request).Return([]byte{}, false)
+	cacheProcessor.On("Receive", ctx, chain, request).Return((*protocol.CachedResponse)(nil), false)
 	delegate.On("ProcessRequest", ctx, strategy, request).Return(delegateResponse)
 
-	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, delegate)
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
 	response := processor.ProcessRequest(ctx, strategy, request)
 
 	time.Sleep(10 * time.Millisecond)
@@ -173,14 +245,14 @@ func TestCacheRequestProcessorWrappingUnaryStoresFreshResponse(t *testing.T) {
 	result := []byte("result")
 	responseHolder := protocol.NewSimpleHttpUpstreamResponse("1", result, protocol.JsonRpc)
 
-	cacheProcessor.On("Receive", ctx, chain, request).Return([]byte{}, false)
+	cacheProcessor.On("Receive", ctx, chain, request).Return((*protocol.CachedResponse)(nil), false)
 	cacheProcessor.On("Store", ctx, chain, request, result).Return()
-	upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 	strategy.On("SelectUpstream", request).Return("id", nil)
 	upSupervisor.On("GetUpstream", "id").Return(upstream)
-	apiConnector.On("SendRequest", ctx, request).Return(responseHolder)
+	apiConnector.On("SendRequest", mock.Anything, request).Return(responseHolder)
 
-	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, flow.NewUnaryRequestProcessor(chain, upSupervisor))
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, flow.NewUnaryRequestProcessor(chain, upSupervisor))
 	response := processor.ProcessRequest(ctx, strategy, request)
 
 	assert.IsType(t, &flow.UnaryResponse{}, response)
@@ -197,3 +269,328 @@ func TestCacheRequestProcessorWrappingUnaryStoresFreshResponse(t *testing.T) {
 	assert.False(t, unaryRespWrapper.Response.HasError())
 	assert.Equal(t, result, unaryRespWrapper.Response.ResponseResult())
 }
+
+func TestCacheRequestProcessorStaleResponseServedAndRevalidated(t *testing.T) {
+	strategy := mocks.NewMockStrategy()
+	revalidationStrategy := mocks.NewMockStrategy()
+	cacheProcessor := mocks.NewCacheProcessorMock()
+	delegate := NewRequestProcessorMock()
+	chain := chains.POLYGON
+	ctx := context.Background()
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call", Params: []byte(`["0x1"]`)}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
+	fresh := []byte("fresh")
+	delegateResponse := &flow.UnaryResponse{ResponseWrapper: &protocol.ResponseHolderWrapper{
+		UpstreamId: "id",
+		RequestId:  "223",
+		Response:   protocol.NewSimpleHttpUpstreamResponse("223", fresh, protocol.JsonRpc),
+	}}
+	isRevalidation := mock.MatchedBy(func(revalidation protocol.RequestHolder) bool {
+		return revalidation != request &&
+			revalidation.Method() == request.Method() &&
+			revalidation.RequestHash() == request.RequestHash() &&
+			revalidation.RequestObserver().GetRequestKind() == protocol.InternalUnary
+	})
+
+	cacheProcessor.On("Receive", ctx, chain, request).Return(&protocol.CachedResponse{Result: []byte("stale"), Stale: true}, true)
+	cacheProcessor.On("Store", mock.Anything, chain, isRevalidation, fresh).Return()
+	delegate.On("ProcessRequest", mock.Anything, revalidationStrategy, isRevalidation).Return(delegateResponse)
+
+	var strategyRequest protocol.RequestHolder
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, flow.NewRequestCoalescer(), delegate).
+		WithRevalidationStrategy(func(_ context.Context, revalidation protocol.RequestHolder) flow.UpstreamStrategy {
+			strategyRequest = revalidation
+			return revalidationStrategy
+		})
+	response := processor.ProcessRequest(ctx, strategy, request)
+
+	assert.IsType(t, &flow.UnaryResponse{}, response)
+	unaryRespWrapper := response.(*flow.UnaryResponse).ResponseWrapper
+	time.Sleep(20 * time.Millisecond)
+
+	// the stale response is served and refreshed in the background by a new request with a new strategy
+	delegate.AssertExpectations(t)
+	cacheProcessor.AssertExpectations(t)
+
+	assert.Same(t, revalidationStrategy, delegate.Calls[0].Arguments.Get(1))
+	assert.Same(t, strategyRequest, delegate.Calls[0].Arguments.Get(2))
+	assert.Equal(t, protocol.Cached, request.RequestObserver().GetRequestKind())
+	assert.Equal(t, flow.NoUpstream, unaryRespWrapper.UpstreamId)
+	assert.Equal(t, []byte("stale"), unaryRespWrapper.Response.ResponseResult())
+	assert.Equal(t, "true", unaryRespWrapper.Response.(protocol.HasResponseHeaders).ResponseHeaders().Get(protocol.XNodecoreStale))
+}
+
+func TestCacheRequestProcessorServeStaleOnError(t *testing.T) {
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
+
+	tests := []struct {
+		name             string
+		delegateResponse protocol.ResponseHolder
+		stale            bool
+	}{
+		{
+			name:             "all upstreams failed",
+			delegateResponse: protocol.NewPartialFailure(request, protocol.ServerError()),
+			stale:            true,
+		},
+		{
+			name:             "no available upstreams",
+			delegateResponse: protocol.NewTotalFailure(request, protocol.NoAvailableUpstreamsError()),
+			stale:            true,
+		},
+		{
+			name:             "not retryable error",
+			delegateResponse: protocol.NewTotalFailure(request, protocol.ServerError()),
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			strategy := mocks.NewMockStrategy()
+			cacheProcessor := mocks.NewCacheProcessorMock()
+			delegate := NewRequestProcessorMock()
+			chain := chains.POLYGON
+			ctx := context.Background()
+			delegateResponse := &flow.UnaryResponse{ResponseWrapper: &protocol.ResponseHolderWrapper{
+				UpstreamId: flow.NoUpstream,
+				RequestId:  "223",
+				Response:   test.delegateResponse,
+			}}
+
+			cacheProcessor.On("Receive", ctx, chain, request).
+				Return(&protocol.CachedResponse{Result: []byte("stale"), Stale: true, ServeStaleOnError: true}, true)
+			delegate.On("ProcessRequest", ctx, strategy, request).Return(delegateResponse)
+
+			processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
+			response := processor.ProcessRequest(ctx, strategy, request)
+
+			time.Sleep(10 * time.Millisecond)
+
+			delegate.AssertExpectations(te)
+			cacheProcessor.AssertNotCalled(te, "Store")
+			if test.stale {
+				unaryRespWrapper := response.(*flow.UnaryResponse).ResponseWrapper
+				assert.False(te, unaryRespWrapper.Response.HasError())
+				assert.Equal(te, []byte("stale"), unaryRespWrapper.Response.ResponseResult())
+				assert.Equal(te, "true", unaryRespWrapper.Response.(protocol.HasResponseHeaders).ResponseHeaders().Get(protocol.XNodecoreStale))
+			} else {
+				assert.Same(te, delegateResponse, response)
+			}
+		})
+	}
+}
+
+func TestCacheRequestProcessorServeStaleOnErrorUpstreamResponseStored(t *testing.T) {
+	strategy := mocks.NewMockStrategy()
+	cacheProcessor := mocks.NewCacheProcessorMock()
+	delegate := NewRequestProcessorMock()
+	chain := chains.POLYGON
+	ctx := context.Background()
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
+	fresh := []byte("fresh")
+	delegateResponse := &flow.UnaryResponse{ResponseWrapper: &protocol.ResponseHolderWrapper{
+		UpstreamId: "id",
+		RequestId:  "223",
+		Response:   protocol.NewSimpleHttpUpstreamResponse("223", fresh, protocol.JsonRpc),
+	}}
+
+	cacheProcessor.On("Receive", ctx, chain, request).
+		Return(&protocol.CachedResponse{Result: []byte("stale"), Stale: true, ServeStaleOnError: true}, true)
+	cacheProcessor.On("Store", ctx, chain, request, fresh).Return()
+	delegate.On("ProcessRequest", ctx, strategy, request).Return(delegateResponse)
+
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
+	response := processor.ProcessRequest(ctx, strategy, request)
+
+	time.Sleep(10 * time.Millisecond)
+
+	delegate.AssertExpectations(t)
+	cacheProcessor.AssertExpectations(t)
+	assert.Same(t, delegateResponse, response)
+}
+
+func TestCacheRequestProcessorNonRetryableUpstreamErrorStored(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   []byte
+		stored bool
+	}{
+		{
+			name:   "execution reverted",
+			body:   []byte(`{"jsonrpc":"2.0","id":1,"error":{"code":3,"message":"execution reverted","data":"0x08c379a0"}}`),
+			stored: true,
+		},
+		{
+			name:   "method not found by one of upstreams",
+			body:   []byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32601,"message":"the method eth_superCall does not exist/is not available"}}`),
+			stored: false,
+		},
+		{
+			name:   "invalid params",
+			body:   []byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid argument 0: hex string without 0x prefix"}}`),
+			stored: true,
+		},
+		{
+			name:   "rate limit",
+			body:   []byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"too many requests"}}`),
+			stored: false,
+		},
+		{
+			name:   "incorrect body",
+			body:   []byte(`{"jsonrpc":"2.0","id":1}`),
+			stored: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			strategy := mocks.NewMockStrategy()
+			cacheProcessor := mocks.NewCacheProcessorMock()
+			delegate := NewRequestProcessorMock()
+			chain := chains.POLYGON
+			ctx := context.Background()
+			jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+			request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
+			upstreamResponse := protocol.NewHttpUpstreamResponse("223", test.body, 200, protocol.JsonRpc)
+			delegateResponse := &flow.UnaryResponse{ResponseWrapper: &protocol.ResponseHolderWrapper{
+				UpstreamId: "id",
+				RequestId:  "223",
+				Response:   upstreamResponse,
+			}}
+
+			cacheProcessor.On("Receive", ctx, chain, request).Return((*protocol.CachedResponse)(nil), false)
+			cacheProcessor.On("StoreError", ctx, chain, request, upstreamResponse.ResponseResult()).Return()
+			delegate.On("ProcessRequest", ctx, strategy, request).Return(delegateResponse)
+
+			processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
+			response := processor.ProcessRequest(ctx, strategy, request)
+
+			time.Sleep(10 * time.Millisecond)
+
+			if test.stored {
+				cacheProcessor.AssertCalled(te, "StoreError", ctx, chain, request, upstreamResponse.ResponseResult())
+			} else {
+				cacheProcessor.AssertNotCalled(te, "StoreError", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			}
+			cacheProcessor.AssertNotCalled(te, "Store", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+			assert.Same(te, delegateResponse, response)
+		})
+	}
+}
+
+func TestCacheRequestProcessorCachedErrorReplayed(t *testing.T) {
+	strategy := mocks.NewMockStrategy()
+	cacheProcessor := mocks.NewCacheProcessorMock()
+	delegate := NewRequestProcessorMock()
+	chain := chains.POLYGON
+	ctx := context.Background()
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
+	responseError := []byte(`{"code":3,"message":"execution reverted","data":"0x08c379a0"}`)
+
+	cacheProcessor.On("Receive", ctx, chain, request).Return(&protocol.CachedResponse{Result: responseError, Error: true}, true)
+
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate).
+		WithRevalidationStrategy(func(context.Context, protocol.RequestHolder) flow.UpstreamStrategy { return strategy })
+	response := processor.ProcessRequest(ctx, strategy, request)
+
+	time.Sleep(10 * time.Millisecond)
+
+	delegate.AssertNotCalled(t, "ProcessRequest")
+	cacheProcessor.AssertNotCalled(t, "StoreError", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	unaryRespWrapper := response.(*flow.UnaryResponse).ResponseWrapper
+	assert.Equal(t, protocol.Cached, request.RequestObserver().GetRequestKind())
+	assert.Equal(t, flow.NoUpstream, unaryRespWrapper.UpstreamId)
+	assert.True(t, unaryRespWrapper.Response.HasError())
+	assert.Equal(t, &protocol.ResponseError{Code: 3, Message: "execution reverted", Data: "0x08c379a0"}, unaryRespWrapper.Response.GetError())
+
+	body, err := io.ReadAll(unaryRespWrapper.Response.EncodeResponse([]byte(`1`)))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"jsonrpc":"2.0","id":1,"error":{"code":3,"message":"execution reverted","data":"0x08c379a0"}}`, string(body))
+}
+
+func TestCacheRequestProcessorNoCacheSkipsReceiveAndStores(t *testing.T) {
+	strategy := mocks.NewMockStrategy()
+	cacheProcessor := mocks.NewCacheProcessorMock()
+	delegate := NewRequestProcessorMock()
+	chain := chains.POLYGON
+	result := []byte("result")
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
+	delegateResponse := &flow.UnaryResponse{ResponseWrapper: &protocol.ResponseHolderWrapper{
+		UpstreamId: "id",
+		RequestId:  "223",
+		Response:   protocol.NewSimpleHttpUpstreamResponse("223", result, protocol.JsonRpc),
+	}}
+
+	ctx := caches.WithCacheControl(context.Background(), caches.CacheControl{NoCache: true})
+	cacheProcessor.On("Store", ctx, chain, request, result).Return()
+	delegate.On("ProcessRequest", ctx, strategy, request).Return(delegateResponse)
+
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
+	response := processor.ProcessRequest(ctx, strategy, request)
+
+	time.Sleep(10 * time.Millisecond)
+
+	cacheProcessor.AssertNotCalled(t, "Receive")
+	cacheProcessor.AssertExpectations(t)
+	delegate.AssertExpectations(t)
+	assert.Same(t, delegateResponse, response)
+}
+
+func TestCacheRequestProcessorNoStoreSkipsStore(t *testing.T) {
+	strategy := mocks.NewMockStrategy()
+	cacheProcessor := mocks.NewCacheProcessorMock()
+	delegate := NewRequestProcessorMock()
+	chain := chains.POLYGON
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
+	delegateResponse := &flow.UnaryResponse{ResponseWrapper: &protocol.ResponseHolderWrapper{
+		UpstreamId: "id",
+		RequestId:  "223",
+		Response:   protocol.NewSimpleHttpUpstreamResponse("223", []byte("result"), protocol.JsonRpc),
+	}}
+
+	ctx := caches.WithCacheControl(context.Background(), caches.CacheControl{NoStore: true})
+	cacheProcessor.On("Receive", ctx, chain, request).Return((*protocol.CachedResponse)(nil), false)
+	delegate.On("ProcessRequest", ctx, strategy, request).Return(delegateResponse)
+
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate)
+	response := processor.ProcessRequest(ctx, strategy, request)
+
+	time.Sleep(10 * time.Millisecond)
+
+	cacheProcessor.AssertNotCalled(t, "Store")
+	cacheProcessor.AssertNotCalled(t, "StoreError")
+	cacheProcessor.AssertExpectations(t)
+	delegate.AssertExpectations(t)
+	assert.Same(t, delegateResponse, response)
+}
+
+func TestCacheRequestProcessorNoStoreThenStaleResponseNotRevalidated(t *testing.T) {
+	strategy := mocks.NewMockStrategy()
+	cacheProcessor := mocks.NewCacheProcessorMock()
+	delegate := NewRequestProcessorMock()
+	chain := chains.POLYGON
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
+
+	ctx := caches.WithCacheControl(context.Background(), caches.CacheControl{NoStore: true})
+	cacheProcessor.On("Receive", ctx, chain, request).
+		Return(&protocol.CachedResponse{Result: []byte("stale"), Stale: true, Policy: "policy", Connector: "redis"}, true)
+
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, nil, delegate).
+		WithRevalidationStrategy(func(context.Context, protocol.RequestHolder) flow.UpstreamStrategy { return strategy })
+	response := processor.ProcessRequest(ctx, strategy, request)
+
+	time.Sleep(10 * time.Millisecond)
+
+	delegate.AssertNotCalled(t, "ProcessRequest")
+	cacheProcessor.AssertNotCalled(t, "Store")
+	headers := response.(*flow.UnaryResponse).ResponseWrapper.Response.(protocol.HasResponseHeaders).ResponseHeaders()
+	assert.Equal(t, "STALE; policy=policy; connector=redis", headers.Get(protocol.XNodecoreCache))
+	assert.Equal(t, "true", headers.Get(protocol.XNodecoreStale))
+}
@@ -0,0 +1,148 @@
+package flow
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"sync"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/rating"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/chains"
+)
+
+// CostStrategy routes requests to the cheapest upstream that meets the cost SLO,
+// the upstreams that meet it are ordered by the price of a request of the method and then by the rating,
+// the upstreams that don't are tried after them in the rating order.
+// The SLO is checked against the metrics of the last rating calculation, until then every upstream meets it
+type CostStrategy struct {
+	chainSupervisor    upstreams.ChainSupervisor
+	selectedUpstreams  mapset.Set[string]
+	ups                []string
+	additionalMatchers []Matcher
+	order              UpstreamOrder
+	mu                 sync.Mutex
+}
+
+func NewCostStrategy(
+	chain chains.Chain,
+	method string,
+	slo *config.CostSloConfig,
+	additionalMatchers []Matcher,
+	chainSupervisor upstreams.ChainSupervisor,
+	registry *rating.RatingRegistry,
+) *CostStrategy {
+	sortedIds := registry.GetSortedUpstreams(chain, method)
+	return &CostStrategy{
+		chainSupervisor:    chainSupervisor,
+		ups:                costOrder(sortedIds, registry.GetMetrics(chain, method), slo, upstreamCosts(sortedIds, method, chainSupervisor)),
+		additionalMatchers: additionalMatchers,
+		selectedUpstreams:  mapset.NewThreadUnsafeSet[string](),
+	}
+}
+
+func (c *CostStrategy) WithOrder(order UpstreamOrder) *CostStrategy {
+	c.order = order
+	return c
+}
+
+func (c *CostStrategy) SelectUpstream(request protocol.RequestHolder) (string, error) {
+	if len(c.ups) == 0 {
+		return "", protocol.NoAvailableUpstreamsError()
+	}
+
+	selectedUpstream, currentReason, trace := filterUpstreams(&c.mu, request, c.ups, c.chainSupervisor, c.selectedUpstreams, c.additionalMatchers, c.order)
+	if selectedUpstream != "" {
+		return selectedUpstream, nil
+	}
+
+	return "", selectionError(currentReason, trace)
+}
+
+var _ UpstreamStrategy = (*CostStrategy)(nil)
+
+// ratingOrder is the rating order of upstreams where the cheaper upstream goes first among the ones with equal scores
+func ratingOrder(chain chains.Chain, method string, chainSupervisor upstreams.ChainSupervisor, registry *rating.RatingRegistry) []string {
+	sortedIds := registry.GetSortedUpstreams(chain, method)
+	costs := upstreamCosts(sortedIds, method, chainSupervisor)
+	if costs == nil {
+		return sortedIds
+	}
+	return cheaperFirstOnTies(sortedIds, registry.GetScores(chain, method), costs, registry.CostTieTolerance())
+}
+
+// upstreamCosts returns the price of a request of the method on each upstream, nil if the prices are all the same
+func upstreamCosts(upstreamIds []string, method string, chainSupervisor upstreams.ChainSupervisor) map[string]float64 {
+	costs := make(map[string]float64, len(upstreamIds))
+	samePrice := true
+	for _, upstreamId := range upstreamIds {
+		requestCost := 0.0
+		if state := chainSupervisor.GetUpstreamState(upstreamId); state != nil {
+			requestCost = state.Cost.RequestCost(method)
+		}
+		costs[upstreamId] = requestCost
+		samePrice = samePrice && requestCost == costs[upstreamIds[0]]
+	}
+	if samePrice {
+		return nil
+	}
+	return costs
+}
+
+// cheaperFirstOnTies orders each run of tied upstreams by their costs. Upstreams are tied with the first upstream
+// of the run if their scores differ by no more than the tolerance relative to the higher score,
+// without scores all upstreams are tied
+func cheaperFirstOnTies(sortedIds []string, scores map[string]float64, costs map[string]float64, tolerance float64) []string {
+	ordered := slices.Clone(sortedIds)
+	for start := 0; start < len(ordered); {
+		end := start + 1
+		for end < len(ordered) && tiedScores(scores[ordered[start]], scores[ordered[end]], tolerance) {
+			end++
+		}
+		slices.SortStableFunc(ordered[start:end], func(a, b string) int {
+			return cmp.Compare(costs[a], costs[b])
+		})
+		start = end
+	}
+	return ordered
+}
+
+func tiedScores(a, b, tolerance float64) bool {
+	return math.Abs(a-b) <= tolerance*max(math.Abs(a), math.Abs(b))
+}
+
+func costOrder(sortedIds []string, metrics map[string]rating.UpstreamMetrics, slo *config.CostSloConfig, costs map[string]float64) []string {
+	meeting := make([]string, 0, len(sortedIds))
+	rest := make([]string, 0)
+	for _, upstreamId := range sortedIds {
+		if meetsCostSlo(metrics, upstreamId, slo) {
+			meeting = append(meeting, upstreamId)
+		} else {
+			rest = append(rest, upstreamId)
+		}
+	}
+	slices.SortStableFunc(meeting, func(a, b string) int {
+		return cmp.Compare(costs[a], costs[b])
+	})
+	return append(meeting, rest...)
+}
+
+func meetsCostSlo(metrics map[string]rating.UpstreamMetrics, upstreamId string, slo *config.CostSloConfig) bool {
+	if slo == nil {
+		return true
+	}
+	upstreamMetrics, ok := metrics[upstreamId]
+	if !ok {
+		return true
+	}
+	if slo.MaxLatency > 0 && upstreamMetrics.LatencyP90 > slo.MaxLatency.Seconds() {
+		return false
+	}
+	if slo.MaxHeadLag > 0 && upstreamMetrics.HeadLag > slo.MaxHeadLag {
+		return false
+	}
+	return true
+}
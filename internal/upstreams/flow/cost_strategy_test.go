@@ -0,0 +1,70 @@
+package flow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/rating"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheaperFirstOnTies(t *testing.T) {
+	scores := map[string]float64{"id1": 2, "id2": 1, "id3": 1, "id4": 0.5}
+	costs := map[string]float64{"id1": 5, "id2": 3, "id3": 1, "id4": 0}
+	sortedIds := []string{"id1", "id2", "id3", "id4"}
+
+	ordered := cheaperFirstOnTies(sortedIds, scores, costs, 0)
+
+	assert.Equal(t, []string{"id1", "id3", "id2", "id4"}, ordered)
+	assert.Equal(t, []string{"id1", "id2", "id3", "id4"}, sortedIds)
+}
+
+func TestCheaperFirstOnTiesWithoutScores(t *testing.T) {
+	costs := map[string]float64{"id1": 5, "id2": 3, "id3": 3, "id4": 0}
+
+	ordered := cheaperFirstOnTies([]string{"id1", "id2", "id3", "id4"}, nil, costs, 0)
+
+	assert.Equal(t, []string{"id4", "id2", "id3", "id1"}, ordered)
+}
+
+func TestCheaperFirstOnTiesWithinTolerance(t *testing.T) {
+	scores := map[string]float64{"id1": 2, "id2": 1.96, "id3": 1.9, "id4": 1.89}
+	costs := map[string]float64{"id1": 5, "id2": 3, "id3": 1, "id4": 0}
+	sortedIds := []string{"id1", "id2", "id3", "id4"}
+
+	// scores are tied with the first upstream of the run, so 1.9 isn't tied with 2 even though it's close to 1.96
+	assert.Equal(t, []string{"id2", "id1", "id4", "id3"}, cheaperFirstOnTies(sortedIds, scores, costs, 0.03))
+	assert.Equal(t, []string{"id4", "id3", "id2", "id1"}, cheaperFirstOnTies(sortedIds, scores, costs, 0.1))
+	assert.Equal(t, sortedIds, cheaperFirstOnTies(sortedIds, scores, costs, 0))
+}
+
+func TestCostOrder(t *testing.T) {
+	slo := &config.CostSloConfig{MaxLatency: 200 * time.Millisecond, MaxHeadLag: 2}
+	metrics := map[string]rating.UpstreamMetrics{
+		"id1": {LatencyP90: 0.1},
+		"id2": {LatencyP90: 0.5},
+		"id3": {LatencyP90: 0.1, HeadLag: 3},
+		"id4": {LatencyP90: 0.15, HeadLag: 2},
+	}
+	costs := map[string]float64{"id1": 0.01, "id2": 0, "id3": 0, "id4": 0.001}
+
+	tests := []struct {
+		name     string
+		slo      *config.CostSloConfig
+		metrics  map[string]rating.UpstreamMetrics
+		expected []string
+	}{
+		{name: "the cheapest upstreams that meet the slo go first", slo: slo, metrics: metrics, expected: []string{"id4", "id1", "id2", "id3"}},
+		{name: "without slo the cheapest go first", slo: nil, metrics: metrics, expected: []string{"id2", "id3", "id4", "id1"}},
+		{name: "without metrics the cheapest go first", slo: slo, metrics: nil, expected: []string{"id2", "id3", "id4", "id1"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			ordered := costOrder([]string{"id1", "id2", "id3", "id4"}, test.metrics, test.slo, costs)
+
+			assert.Equal(te, test.expected, ordered)
+		})
+	}
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/drpcorg/nodecore/internal/quorum"
 	"github.com/drpcorg/nodecore/internal/rating"
 	"github.com/drpcorg/nodecore/internal/resilience"
+	"github.com/drpcorg/nodecore/internal/tracing"
 	"github.com/drpcorg/nodecore/internal/upstreams"
 	"github.com/drpcorg/nodecore/internal/upstreams/flow/subengine"
 	"github.com/drpcorg/nodecore/pkg/chains"
@@ -110,6 +111,8 @@ type GenericExecutionFlow struct {
 	registry           *rating.RatingRegistry
 	appConfig          *config.AppConfig
 	quorumRegistry     *quorum.Registry
+	requestCoalescer   *RequestCoalescer
+	filterRegistry     *FilterRegistry
 
 	hooks struct {
 		receivedHooks []protocol.ResponseReceivedHook
@@ -125,6 +128,8 @@ func NewGenericExecutionFlow(
 	subCtx *SubCtx,
 	quorumRegistry *quorum.Registry,
 	subEngineRegistry *subengine.Registry,
+	requestCoalescer *RequestCoalescer,
+	filterRegistry *FilterRegistry,
 ) *GenericExecutionFlow {
 	return &GenericExecutionFlow{
 		chain:              chain,
@@ -136,6 +141,8 @@ func NewGenericExecutionFlow(
 		registry:           registry,
 		appConfig:          appConfig,
 		quorumRegistry:     quorumRegistry,
+		requestCoalescer:   requestCoalescer,
+		filterRegistry:     filterRegistry,
 	}
 }
 
@@ -217,6 +224,15 @@ func (e *GenericExecutionFlow) createStrategy(ctx context.Context, request proto
 	switch e.appConfig.UpstreamConfig.BalancingStrategyFor(e.chain.String()) {
 	case config.BaseBalancingStrategy:
 		return NewGenericStrategyWithOptions(chainSupervisor, additionalMatchers, order)
+	case config.WeightedRatingBalancingStrategy:
+		return NewWeightedRatingStrategy(e.chain, request.Method(), additionalMatchers, chainSupervisor, e.registry).WithOrder(order)
+	case config.WeightedBalancingStrategy:
+		return NewStaticWeightedStrategy(additionalMatchers, chainSupervisor, e.upstreamSupervisor).WithOrder(order)
+	case config.P2CBalancingStrategy:
+		return NewP2CStrategy(chainSupervisor, additionalMatchers).WithOrder(order)
+	case config.CostBalancingStrategy:
+		costSlo := e.appConfig.UpstreamConfig.CostSloFor(e.chain.String())
+		return NewCostStrategy(e.chain, request.Method(), costSlo, additionalMatchers, chainSupervisor, e.registry).WithOrder(order)
 	default: // rating
 		return NewRatingStrategy(e.chain, request.Method(), additionalMatchers, chainSupervisor, e.registry).WithOrder(order)
 	}
@@ -259,6 +275,13 @@ func hasHttpConnector(up upstreams.Upstream, requestType protocol.RequestType) b
 func (e *GenericExecutionFlow) processRequest(ctx context.Context, upstreamStrategy UpstreamStrategy, request protocol.RequestHolder) {
 	go func() {
 		defer e.wg.Done()
+		ctx, span := tracing.StartSpan(
+			ctx,
+			"flow.process-request",
+			tracing.ChainKey.String(e.chain.String()),
+			tracing.MethodKey.String(request.Method()),
+		)
+		defer span.End()
 		requestTotalMetric.WithLabelValues(e.chain.String(), request.Method()).Inc()
 
 		if request.SpecMethod() == nil {
@@ -306,6 +329,10 @@ func (e *GenericExecutionFlow) processRequest(ctx context.Context, upstreamStrat
 					WithRespKindFromResponse(resp.ResponseWrapper.Response),
 				true,
 			)
+			span.SetAttributes(
+				tracing.UpstreamIdKey.String(resp.ResponseWrapper.UpstreamId),
+				tracing.ResponseKindKey.String(protocol.GetRespKindFromResponse(resp.ResponseWrapper.Response).String()),
+			)
 
 			e.responseReceive(ctx, request, resp.ResponseWrapper)
 			e.sendResponse(ctx, resp.ResponseWrapper, request)
@@ -333,18 +360,23 @@ func (e *GenericExecutionFlow) createRequestProcessor(request protocol.RequestHo
 	} else if request.SpecMethod().IsLocal() {
 		requestProcessor = NewLocalRequestProcessor(e.chain, e.subCtx)
 		reqObserver.WithRequestKind(protocol.Local)
+	} else if e.isLocalFilterRequest(request) {
+		requestProcessor = NewFilterRequestProcessor(e.chain, e.upstreamSupervisor, e.subEngineRegistry.Get(e.chain), e.registry, e.filterRegistry)
+		reqObserver.WithRequestKind(protocol.Local)
 	} else if isStickyRequest(request.SpecMethod()) {
 		requestProcessor = NewStickyRequestProcessor(e.chain, e.upstreamSupervisor)
 		reqObserver.WithRequestKind(protocol.Unary)
 	} else if request.SpecMethod().DispatchPolicy() != specs.DispatchDefault {
 		if e.dispatchEnabled(request.SpecMethod().DispatchPolicy()) {
 			if request.SpecMethod().DispatchPolicy() == specs.DispatchNotNull {
-				requestProcessor = NewCacheRequestProcessor(e.chain, e.cacheProcessor, NewNotNullRequestProcessor(e.upstreamSupervisor))
+				requestProcessor = NewCacheRequestProcessor(e.chain, e.cacheProcessor, e.chainCoalescer(), NewNotNullRequestProcessor(e.upstreamSupervisor)).
+					WithRevalidationStrategy(e.createStrategy)
 			} else {
 				requestProcessor = NewFanoutRequestProcessor(e.upstreamSupervisor, request.SpecMethod().DispatchPolicy())
 			}
 		} else {
-			requestProcessor = NewCacheRequestProcessor(e.chain, e.cacheProcessor, NewUnaryRequestProcessor(e.chain, e.upstreamSupervisor))
+			requestProcessor = NewCacheRequestProcessor(e.chain, e.cacheProcessor, e.chainCoalescer(), NewUnaryRequestProcessor(e.chain, e.upstreamSupervisor)).
+				WithRevalidationStrategy(e.createStrategy)
 		}
 		reqObserver.WithRequestKind(protocol.Unary)
 	} else if shouldEnforceIntegrity(request.SpecMethod(), e.appConfig.UpstreamConfig.IntegrityConfig) {
@@ -355,13 +387,31 @@ func (e *GenericExecutionFlow) createRequestProcessor(request protocol.RequestHo
 		)
 		reqObserver.WithRequestKind(protocol.Unary)
 	} else {
-		requestProcessor = NewCacheRequestProcessor(e.chain, e.cacheProcessor, NewUnaryRequestProcessor(e.chain, e.upstreamSupervisor))
+		requestProcessor = NewCacheRequestProcessor(e.chain, e.cacheProcessor, e.chainCoalescer(), NewUnaryRequestProcessor(e.chain, e.upstreamSupervisor)).
+			WithRevalidationStrategy(e.createStrategy)
 		reqObserver.WithRequestKind(protocol.Unary)
 	}
 
 	return requestProcessor
 }
 
+// isLocalFilterRequest reports whether a filter request is served from the filters nodecore owns
+func (e *GenericExecutionFlow) isLocalFilterRequest(request protocol.RequestHolder) bool {
+	if e.filterRegistry == nil || e.appConfig == nil {
+		return false
+	}
+	settings := e.appConfig.UpstreamConfig.LocalSubSettings(e.chain.String())
+	return isLocalFilterRequest(e.chain, e.upstreamSupervisor, e.filterRegistry, settings, request)
+}
+
+// chainCoalescer returns the request coalescer if coalescing is enabled for the chain, otherwise nil
+func (e *GenericExecutionFlow) chainCoalescer() *RequestCoalescer {
+	if e.requestCoalescer == nil || e.appConfig == nil || !e.appConfig.UpstreamConfig.CoalesceRequestsFor(e.chain.String()) {
+		return nil
+	}
+	return e.requestCoalescer
+}
+
 func (e *GenericExecutionFlow) dispatchEnabled(policy specs.DispatchPolicy) bool {
 	if e == nil || e.appConfig == nil || e.appConfig.UpstreamConfig == nil {
 		return false
@@ -68,3 +68,31 @@ func TestCreateStrategyPerChainOverrideWinsOverGlobal(t *testing.T) {
 
 	assert.IsType(t, &RatingStrategy{}, strategy)
 }
+
+func TestCreateStrategyUsesRandomAndCostStrategies(t *testing.T) {
+	tests := []struct {
+		name     string
+		strategy config.BalancingStrategy
+		expected UpstreamStrategy
+	}{
+		{name: "weighted rating", strategy: config.WeightedRatingBalancingStrategy, expected: &WeightedStrategy{}},
+		{name: "weighted", strategy: config.WeightedBalancingStrategy, expected: &WeightedStrategy{}},
+		{name: "p2c", strategy: config.P2CBalancingStrategy, expected: &P2CStrategy{}},
+		{name: "cost", strategy: config.CostBalancingStrategy, expected: &CostStrategy{}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			exec := newStrategyExec(te, &config.UpstreamConfig{
+				ChainDefaults: map[string]*config.ChainDefaults{
+					chains.ETHEREUM.String(): {BalancingStrategy: test.strategy},
+				},
+			})
+			request := protocol.NewUpstreamJsonRpcRequest("1", protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}, false, "eth")
+
+			strategy := exec.createStrategy(context.Background(), request)
+
+			assert.IsType(te, test.expected, strategy)
+		})
+	}
+}
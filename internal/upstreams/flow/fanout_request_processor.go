@@ -34,7 +34,7 @@ func (f *FanoutRequestProcessor) ProcessRequest(
 	upstreamStrategy UpstreamStrategy,
 	request protocol.RequestHolder,
 ) ProcessedResponse {
-	upstreamIDs, err := collectDispatchUpstreamIDs(upstreamStrategy, request)
+	upstreamIDs, err := collectDispatchUpstreamIDs(f.upstreamSupervisor, upstreamStrategy, request)
 	if err != nil {
 		return &UnaryResponse{ResponseWrapper: totalFailureWrapper(request, err)}
 	}
@@ -96,7 +96,11 @@ type fanoutResult struct {
 	err        error
 }
 
-func collectDispatchUpstreamIDs(upstreamStrategy UpstreamStrategy, request protocol.RequestHolder) ([]string, error) {
+func collectDispatchUpstreamIDs(
+	upstreamSupervisor upstreams.UpstreamSupervisor,
+	upstreamStrategy UpstreamStrategy,
+	request protocol.RequestHolder,
+) ([]string, error) {
 	var upstreamIDs []string
 	seen := make(map[string]struct{})
 	for {
@@ -114,6 +118,7 @@ func collectDispatchUpstreamIDs(upstreamStrategy UpstreamStrategy, request proto
 			return upstreamIDs, nil
 		}
 		if _, ok := seen[upstreamID]; ok {
+			releaseUpstreams(upstreamSupervisor, upstreamID)
 			if len(upstreamIDs) == 0 {
 				return nil, protocol.NoAvailableUpstreamsError()
 			}
@@ -0,0 +1,191 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bytedance/sonic"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/rating"
+	"github.com/drpcorg/nodecore/internal/resilience"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/internal/upstreams/flow/subengine"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	specs "github.com/drpcorg/nodecore/pkg/methods"
+)
+
+// FilterRequestProcessor serves the EVM filter methods from the filters nodecore
+// owns itself (see FilterRegistry) instead of sticking them to the upstream that
+// created the filter.
+type FilterRequestProcessor struct {
+	chain              chains.Chain
+	upstreamSupervisor upstreams.UpstreamSupervisor
+	engine             subengine.Engine
+	registry           *rating.RatingRegistry
+	filters            *FilterRegistry
+}
+
+func NewFilterRequestProcessor(
+	chain chains.Chain,
+	upstreamSupervisor upstreams.UpstreamSupervisor,
+	engine subengine.Engine,
+	registry *rating.RatingRegistry,
+	filters *FilterRegistry,
+) *FilterRequestProcessor {
+	return &FilterRequestProcessor{
+		chain:              chain,
+		upstreamSupervisor: upstreamSupervisor,
+		engine:             engine,
+		registry:           registry,
+		filters:            filters,
+	}
+}
+
+func (f *FilterRequestProcessor) ProcessRequest(
+	ctx context.Context,
+	_ UpstreamStrategy,
+	request protocol.RequestHolder,
+) ProcessedResponse {
+	switch request.Method() {
+	case specs.EthNewBlockFilter:
+		id, err := f.filters.install(f.chain, blockFilter, nil, f.engine, localNewHeadsKey, subengine.NewHeadsSourceBuilder(f.upstreamSupervisor, f.chain))
+		if err != nil {
+			return &UnaryResponse{totalFailureWrapper(request, err)}
+		}
+		return &UnaryResponse{localResultWrapper(request, []byte(fmt.Sprintf(`"%s"`, id)))}
+	case specs.EthNewFilter:
+		criteria, err := parseFilterCriteria(request)
+		if err != nil {
+			return &UnaryResponse{errorWrapper(request, protocol.InvalidParamsError(fmt.Sprintf("invalid filter: %s", err.Error())))}
+		}
+		id, err := f.filters.install(f.chain, logsFilter, criteria, f.engine, localLogsKey, newLogsSourceBuilder(f.upstreamSupervisor, f.chain, f.registry))
+		if err != nil {
+			return &UnaryResponse{totalFailureWrapper(request, err)}
+		}
+		return &UnaryResponse{localResultWrapper(request, []byte(fmt.Sprintf(`"%s"`, id)))}
+	}
+
+	id, ok := filterIdParam(request)
+	if !ok {
+		return &UnaryResponse{errorWrapper(request, protocol.InvalidParamsError("filter id is required"))}
+	}
+	filter, ok := f.filters.get(f.chain, id)
+	if !ok {
+		return &UnaryResponse{errorWrapper(request, protocol.FilterNotFoundError())}
+	}
+
+	switch request.Method() {
+	case specs.EthGetFilterChanges:
+		return &UnaryResponse{localResultWrapper(request, filter.poll())}
+	case specs.EthGetFilterLogs:
+		if filter.kind != logsFilter {
+			return &UnaryResponse{errorWrapper(request, protocol.FilterNotFoundError())}
+		}
+		return &UnaryResponse{f.getFilterLogs(ctx, request, filter)}
+	case specs.EthUninstallFilter:
+		result := []byte(`false`)
+		if f.filters.uninstall(f.chain, id) {
+			result = ResultTrue
+		}
+		return &UnaryResponse{localResultWrapper(request, result)}
+	default:
+		return &UnaryResponse{processedServerError(request, fmt.Errorf("method '%s' is not a filter method", request.Method()))}
+	}
+}
+
+// getFilterLogs fetches all the logs matching the filter criteria with eth_getLogs
+func (f *FilterRequestProcessor) getFilterLogs(ctx context.Context, request protocol.RequestHolder, filter *localFilter) *protocol.ResponseHolderWrapper {
+	logsRequest, err := protocol.NewInternalUpstreamJsonRpcRequest("eth_getLogs", []any{filter.criteria.raw}, f.chain)
+	if err != nil {
+		return processedServerError(request, err)
+	}
+	chainSupervisor := f.upstreamSupervisor.GetChainSupervisor(f.chain)
+	matchers, order := buildSelectorRouting(request.Selectors(), f.upstreamSupervisor, chainSupervisor)
+	strategy := NewRatingStrategy(f.chain, logsRequest.Method(), matchers, chainSupervisor, f.registry).WithOrder(order)
+
+	execCtx := context.WithValue(ctx, resilience.RequestKey, logsRequest)
+	response, err := executeUnaryRequest(execCtx, f.chain, logsRequest, f.upstreamSupervisor, strategy)
+	if err != nil {
+		return totalFailureWrapper(request, err)
+	}
+	if response.Response.HasError() {
+		return &protocol.ResponseHolderWrapper{
+			UpstreamId: response.UpstreamId,
+			RequestId:  request.Id(),
+			Response:   protocol.NewReplyError(request.Id(), response.Response.GetError(), request.RequestType(), protocol.PartialFailure),
+		}
+	}
+	return &protocol.ResponseHolderWrapper{
+		UpstreamId: response.UpstreamId,
+		RequestId:  request.Id(),
+		Response:   protocol.NewSimpleHttpUpstreamResponse(request.Id(), response.Response.ResponseResult(), request.RequestType()),
+	}
+}
+
+var _ RequestProcessor = (*FilterRequestProcessor)(nil)
+
+// isLocalFilterRequest decides whether an EVM filter request is served by nodecore
+// itself. New filters are local when the chain can feed them from its local
+// newHeads or logs source, the other methods when their filter id was issued
+// locally - ids of filters created on an upstream keep the sticky path.
+// eth_newPendingTransactionFilter is always sticky.
+func isLocalFilterRequest(
+	chain chains.Chain,
+	supervisor upstreams.UpstreamSupervisor,
+	filters *FilterRegistry,
+	settings config.LocalSubSettings,
+	request protocol.RequestHolder,
+) bool {
+	if filters == nil || !settings.Filters {
+		return false
+	}
+	switch request.Method() {
+	case specs.EthNewBlockFilter:
+		return localNewHeadsAvailable(chain, supervisor)
+	case specs.EthNewFilter:
+		return localLogsAvailable(chain, supervisor) && !hasEffectiveSelectors(request.Selectors())
+	case specs.EthGetFilterChanges, specs.EthGetFilterLogs, specs.EthUninstallFilter:
+		id, ok := filterIdParam(request)
+		if !ok {
+			return false
+		}
+		_, ok = filters.get(chain, id)
+		return ok
+	default:
+		return false
+	}
+}
+
+// filterIdParam returns the filter id, the first param of a filter request
+func filterIdParam(request protocol.RequestHolder) (string, bool) {
+	body, err := request.Body()
+	if err != nil {
+		return "", false
+	}
+	node, err := sonic.Get(body, "params", 0)
+	if err != nil {
+		return "", false
+	}
+	id, err := node.String()
+	if err != nil || id == "" {
+		return "", false
+	}
+	return id, true
+}
+
+func localResultWrapper(request protocol.RequestHolder, result []byte) *protocol.ResponseHolderWrapper {
+	return &protocol.ResponseHolderWrapper{
+		UpstreamId: NoUpstream,
+		RequestId:  request.Id(),
+		Response:   protocol.NewSimpleHttpUpstreamResponse(request.Id(), result, request.RequestType()),
+	}
+}
+
+func errorWrapper(request protocol.RequestHolder, responseError *protocol.ResponseError) *protocol.ResponseHolderWrapper {
+	return &protocol.ResponseHolderWrapper{
+		UpstreamId: NoUpstream,
+		RequestId:  request.Id(),
+		Response:   protocol.NewTotalFailure(request, responseError),
+	}
+}
@@ -0,0 +1,335 @@
+package flow
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/upstreams/flow/subengine"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/utils"
+	"github.com/rs/zerolog/log"
+)
+
+// defaultFilterTimeout mirrors geth's filter deadline: a filter that hasn't been
+// polled for this long is uninstalled.
+const defaultFilterTimeout = 5 * time.Minute
+
+// maxFilterChanges bounds the changes buffered by a single filter between polls.
+// A client that stops polling would otherwise grow the buffer until the filter
+// expires; the oldest changes are dropped first.
+const maxFilterChanges = 10000
+
+// filterResubscribeDelay is the pause before a filter re-attaches to its local
+// source after the source ended (e.g. the chain temporarily lost the capability).
+const filterResubscribeDelay = time.Second
+
+type filterKind int
+
+const (
+	blockFilter filterKind = iota
+	logsFilter
+)
+
+// filterCriteria is the parsed eth_newFilter object. fromBlock and toBlock only
+// bound the emitted logs when they are numbers, block tags leave the range open.
+type filterCriteria struct {
+	logFilter *logFilter
+	fromBlock *uint64
+	toBlock   *uint64
+	raw       json.RawMessage
+}
+
+// inRange reports whether the block number of the log is within the criteria range
+func (c *filterCriteria) inRange(raw []byte) bool {
+	if c.fromBlock == nil && c.toBlock == nil {
+		return true
+	}
+	node, err := sonic.Get(raw, "blockNumber")
+	if err != nil {
+		return false
+	}
+	value, err := node.String()
+	if err != nil {
+		return false
+	}
+	number, ok := parseFilterBlockNumber(value)
+	if !ok {
+		return false
+	}
+	if c.fromBlock != nil && number < *c.fromBlock {
+		return false
+	}
+	return c.toBlock == nil || number <= *c.toBlock
+}
+
+// localFilter is a single filter installed by a client. Its changes are buffered
+// by the filter goroutine and drained by eth_getFilterChanges.
+type localFilter struct {
+	id       string
+	chain    chains.Chain
+	kind     filterKind
+	criteria *filterCriteria
+	cancel   context.CancelFunc
+
+	mu       sync.Mutex
+	changes  []json.RawMessage
+	lastPoll time.Time
+}
+
+func (f *localFilter) addChange(change json.RawMessage) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.changes) >= maxFilterChanges {
+		f.changes = f.changes[1:]
+	}
+	f.changes = append(f.changes, change)
+}
+
+// poll returns the buffered changes as a json array and resets the buffer and the expiry
+func (f *localFilter) poll() []byte {
+	f.mu.Lock()
+	changes := f.changes
+	f.changes = nil
+	f.lastPoll = time.Now()
+	f.mu.Unlock()
+
+	result, err := sonic.Marshal(changes)
+	if err != nil || changes == nil {
+		return []byte(`[]`)
+	}
+	return result
+}
+
+func (f *localFilter) expired(timeout time.Duration) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return time.Since(f.lastPoll) >= timeout
+}
+
+// handle buffers the change a source event carries, if any
+func (f *localFilter) handle(event *protocol.WsResponse) {
+	if event.Error != nil || len(event.Message) == 0 {
+		return
+	}
+	switch f.kind {
+	case blockFilter:
+		node, err := sonic.Get(event.Message, "hash")
+		if err != nil {
+			return
+		}
+		hash, err := node.Raw()
+		if err != nil {
+			return
+		}
+		f.addChange(json.RawMessage(hash))
+	case logsFilter:
+		if !f.criteria.logFilter.Matches(event.ParsedEvent) || !f.criteria.inRange(event.Message) {
+			return
+		}
+		f.addChange(append(json.RawMessage(nil), event.Message...))
+	}
+}
+
+// FilterRegistry keeps the filters installed by eth_newFilter and eth_newBlockFilter.
+// Filters are owned by nodecore, not by an upstream: each one is backed by the
+// chain's shared local newHeads or logs source in the subscription engine, so it
+// keeps working when the upstream that served the creating request goes away.
+// It is process-wide and shared by both the HTTP/WS and gRPC entry points.
+type FilterRegistry struct {
+	ctx     context.Context
+	timeout time.Duration
+	filters *utils.CMap[string, *localFilter]
+}
+
+func NewFilterRegistry(ctx context.Context) *FilterRegistry {
+	return newFilterRegistryWithTimeout(ctx, defaultFilterTimeout)
+}
+
+func newFilterRegistryWithTimeout(ctx context.Context, timeout time.Duration) *FilterRegistry {
+	return &FilterRegistry{
+		ctx:     ctx,
+		timeout: timeout,
+		filters: utils.NewCMap[string, *localFilter](),
+	}
+}
+
+// install creates a filter fed by the engine source identified by key and
+// returns its id. The filter lives until it's uninstalled or isn't polled
+// within the registry timeout.
+func (r *FilterRegistry) install(
+	chain chains.Chain,
+	kind filterKind,
+	criteria *filterCriteria,
+	engine subengine.Engine,
+	key string,
+	build subengine.SourceBuilder,
+) (string, error) {
+	idBytes, err := nextSubscriptionId(16)
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithCancel(r.ctx)
+	filter := &localFilter{
+		id:       "0x" + hex.EncodeToString(idBytes),
+		chain:    chain,
+		kind:     kind,
+		criteria: criteria,
+		cancel:   cancel,
+		lastPoll: time.Now(),
+	}
+	// subscribe before the id is returned, so the changes made right after the
+	// creation are not missed
+	sub, err := engine.Subscribe(key, build)
+	if err != nil {
+		cancel()
+		return "", err
+	}
+	r.filters.Store(filter.id, filter)
+	go r.run(ctx, filter, sub, engine, key, build)
+
+	return filter.id, nil
+}
+
+// get returns the filter of the chain, filters of other chains are invisible
+func (r *FilterRegistry) get(chain chains.Chain, id string) (*localFilter, bool) {
+	filter, ok := r.filters.Load(strings.ToLower(id))
+	if !ok || filter.chain != chain {
+		return nil, false
+	}
+	return filter, true
+}
+
+// uninstall removes the filter and reports whether it existed
+func (r *FilterRegistry) uninstall(chain chains.Chain, id string) bool {
+	filter, ok := r.get(chain, id)
+	if !ok || !r.filters.CompareAndDelete(filter.id, filter) {
+		return false
+	}
+	filter.cancel()
+	return true
+}
+
+// run feeds the filter from its source until the filter is uninstalled or expires.
+// If the source ends, the filter re-attaches to a fresh one.
+func (r *FilterRegistry) run(
+	ctx context.Context,
+	filter *localFilter,
+	sub *subengine.Subscription,
+	engine subengine.Engine,
+	key string,
+	build subengine.SourceBuilder,
+) {
+	defer r.filters.CompareAndDelete(filter.id, filter)
+	defer filter.cancel()
+
+	expiryCheck := time.NewTicker(r.timeout / 10)
+	defer expiryCheck.Stop()
+
+	for {
+		if sub != nil {
+			if !r.consume(ctx, filter, sub, expiryCheck.C) {
+				sub.Unsubscribe()
+				return
+			}
+			sub.Unsubscribe()
+			if err := sub.Err(); err != nil {
+				log.Debug().Msgf("filter %s on %s lost its source: %s", filter.id, filter.chain, err.Message)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(filterResubscribeDelay):
+		}
+		if filter.expired(r.timeout) {
+			log.Debug().Msgf("filter %s on %s expired", filter.id, filter.chain)
+			return
+		}
+
+		var err error
+		sub, err = engine.Subscribe(key, build)
+		if err != nil {
+			log.Warn().Err(err).Msgf("couldn't resubscribe filter %s on %s", filter.id, filter.chain)
+			sub = nil
+		}
+	}
+}
+
+// consume buffers the source events. It returns true when the source ended and
+// the filter should re-attach, false when the filter is done.
+func (r *FilterRegistry) consume(ctx context.Context, filter *localFilter, sub *subengine.Subscription, expiryCheck <-chan time.Time) bool {
+	for {
+		select {
+		case <-ctx.Done():
+			return false
+		case <-expiryCheck:
+			if filter.expired(r.timeout) {
+				log.Debug().Msgf("filter %s on %s expired", filter.id, filter.chain)
+				return false
+			}
+		case event, ok := <-sub.Events:
+			if !ok {
+				return true
+			}
+			filter.handle(event)
+		}
+	}
+}
+
+// parseFilterCriteria parses the filter object (params[0]) of an eth_newFilter request
+func parseFilterCriteria(request protocol.RequestHolder) (*filterCriteria, error) {
+	logFilter, err := parseLogFilterParam(request, 0)
+	if err != nil {
+		return nil, err
+	}
+	criteria := &filterCriteria{logFilter: logFilter, raw: json.RawMessage(`{}`)}
+
+	body, err := request.Body()
+	if err != nil {
+		return nil, err
+	}
+	node, err := sonic.Get(body, "params", 0)
+	if err != nil {
+		return criteria, nil
+	}
+	raw, err := node.Raw()
+	if err != nil {
+		return criteria, nil
+	}
+	var blockRange struct {
+		FromBlock string `json:"fromBlock"`
+		ToBlock   string `json:"toBlock"`
+	}
+	if err := sonic.UnmarshalString(raw, &blockRange); err != nil {
+		return nil, err
+	}
+	if from, ok := parseFilterBlockNumber(blockRange.FromBlock); ok {
+		criteria.fromBlock = &from
+	}
+	if to, ok := parseFilterBlockNumber(blockRange.ToBlock); ok {
+		criteria.toBlock = &to
+	}
+	criteria.raw = json.RawMessage(raw)
+
+	return criteria, nil
+}
+
+// parseFilterBlockNumber parses a hex block number, block tags are not numbers
+func parseFilterBlockNumber(value string) (uint64, bool) {
+	if !strings.HasPrefix(value, "0x") {
+		return 0, false
+	}
+	number, err := strconv.ParseUint(value[2:], 16, 64)
+	if err != nil {
+		return 0, false
+	}
+	return number, true
+}
@@ -0,0 +1,255 @@
+package flow
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/internal/upstreams/flow/subengine"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testFilterSource is a source builder fed by the test
+func testFilterSource(events chan *protocol.WsResponse) subengine.SourceBuilder {
+	return func(_ context.Context) (*subengine.Source, error) {
+		return &subengine.Source{Events: events, Stop: func() {}}, nil
+	}
+}
+
+func filterRequest(method, params string) protocol.RequestHolder {
+	return protocol.NewUpstreamJsonRpcRequest("1", protocol.JsonRpcRequestBody{Method: method, Params: []byte(params)}, false, "eth")
+}
+
+func pollFilter(t *testing.T, filters *FilterRegistry, id string) []json.RawMessage {
+	t.Helper()
+	filter, ok := filters.get(chains.ETHEREUM, id)
+	require.True(t, ok)
+	var changes []json.RawMessage
+	require.NoError(t, json.Unmarshal(filter.poll(), &changes))
+	return changes
+}
+
+func TestFilterRegistryBlockFilterBuffersHashes(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filters := NewFilterRegistry(ctx)
+	events := make(chan *protocol.WsResponse)
+
+	id, err := filters.install(chains.ETHEREUM, blockFilter, nil, subengine.NewEngine(ctx, chains.ETHEREUM), "heads", testFilterSource(events))
+	require.NoError(t, err)
+
+	events <- &protocol.WsResponse{Message: []byte(`{"number":"0x1","hash":"0xaa"}`)}
+	events <- &protocol.WsResponse{Message: []byte(`{"number":"0x2","hash":"0xbb"}`)}
+
+	assert.Eventually(t, func() bool {
+		filter, _ := filters.get(chains.ETHEREUM, id)
+		filter.mu.Lock()
+		defer filter.mu.Unlock()
+		return len(filter.changes) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []json.RawMessage{json.RawMessage(`"0xaa"`), json.RawMessage(`"0xbb"`)}, pollFilter(t, filters, id))
+	assert.Empty(t, pollFilter(t, filters, id))
+}
+
+func TestFilterRegistryLogsFilterMatchesCriteria(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filters := NewFilterRegistry(ctx)
+	events := make(chan *protocol.WsResponse)
+
+	criteria, err := parseFilterCriteria(filterRequest("eth_newFilter", `[{"address":"0xAB","fromBlock":"0x10","toBlock":"latest"}]`))
+	require.NoError(t, err)
+	id, err := filters.install(chains.ETHEREUM, logsFilter, criteria, subengine.NewEngine(ctx, chains.ETHEREUM), "logs", testFilterSource(events))
+	require.NoError(t, err)
+
+	logs := []string{
+		`{"address":"0xab","blockNumber":"0x10","topics":[]}`,
+		`{"address":"0xcd","blockNumber":"0x11","topics":[]}`, // other address
+		`{"address":"0xab","blockNumber":"0xf","topics":[]}`,  // before fromBlock
+		`{"address":"0xab","blockNumber":"0x12","topics":[],"removed":true}`,
+	}
+	for _, raw := range logs {
+		events <- &protocol.WsResponse{Message: []byte(raw), ParsedEvent: parseLogEvent([]byte(raw))}
+	}
+
+	assert.Eventually(t, func() bool {
+		filter, _ := filters.get(chains.ETHEREUM, id)
+		filter.mu.Lock()
+		defer filter.mu.Unlock()
+		return len(filter.changes) == 2
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []json.RawMessage{json.RawMessage(logs[0]), json.RawMessage(logs[3])}, pollFilter(t, filters, id))
+	assert.JSONEq(t, `{"address":"0xAB","fromBlock":"0x10","toBlock":"latest"}`, string(criteria.raw))
+}
+
+func TestFilterRegistryExpiresUnpolledFilter(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filters := newFilterRegistryWithTimeout(ctx, 50*time.Millisecond)
+
+	id, err := filters.install(chains.ETHEREUM, blockFilter, nil, subengine.NewEngine(ctx, chains.ETHEREUM), "heads", testFilterSource(make(chan *protocol.WsResponse)))
+	require.NoError(t, err)
+
+	assert.Eventually(t, func() bool {
+		_, ok := filters.get(chains.ETHEREUM, id)
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestFilterRegistryUninstall(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filters := NewFilterRegistry(ctx)
+
+	id, err := filters.install(chains.ETHEREUM, blockFilter, nil, subengine.NewEngine(ctx, chains.ETHEREUM), "heads", testFilterSource(make(chan *protocol.WsResponse)))
+	require.NoError(t, err)
+
+	assert.False(t, filters.uninstall(chains.POLYGON, id))
+	assert.True(t, filters.uninstall(chains.ETHEREUM, id))
+	assert.False(t, filters.uninstall(chains.ETHEREUM, id))
+	_, ok := filters.get(chains.ETHEREUM, id)
+	assert.False(t, ok)
+}
+
+func TestFilterRegistryResubscribesWhenSourceEnds(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filters := NewFilterRegistry(ctx)
+
+	first := make(chan *protocol.WsResponse)
+	second := make(chan *protocol.WsResponse)
+	sources := make(chan chan *protocol.WsResponse, 2)
+	sources <- first
+	sources <- second
+	build := func(_ context.Context) (*subengine.Source, error) {
+		return &subengine.Source{Events: <-sources, Stop: func() {}}, nil
+	}
+
+	id, err := filters.install(chains.ETHEREUM, blockFilter, nil, subengine.NewEngine(ctx, chains.ETHEREUM), "heads", build)
+	require.NoError(t, err)
+
+	close(first)
+	select {
+	case second <- &protocol.WsResponse{Message: []byte(`{"hash":"0xaa"}`)}:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the filter didn't re-attach to a new source")
+	}
+
+	assert.Eventually(t, func() bool {
+		filter, _ := filters.get(chains.ETHEREUM, id)
+		filter.mu.Lock()
+		defer filter.mu.Unlock()
+		return len(filter.changes) == 1
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestParseFilterCriteriaBlockRange(t *testing.T) {
+	criteria, err := parseFilterCriteria(filterRequest("eth_newFilter", `[{"fromBlock":"earliest","toBlock":"0x20"}]`))
+	require.NoError(t, err)
+	assert.Nil(t, criteria.fromBlock)
+	require.NotNil(t, criteria.toBlock)
+	assert.Equal(t, uint64(0x20), *criteria.toBlock)
+
+	criteria, err = parseFilterCriteria(filterRequest("eth_newFilter", `[]`))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{}`, string(criteria.raw))
+
+	_, err = parseFilterCriteria(filterRequest("eth_newFilter", `[{"address":1}]`))
+	assert.Error(t, err)
+}
+
+func TestIsLocalFilterRequest(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filters := NewFilterRegistry(ctx)
+	id, err := filters.install(chains.ETHEREUM, blockFilter, nil, subengine.NewEngine(ctx, chains.ETHEREUM), "heads", testFilterSource(make(chan *protocol.WsResponse)))
+	require.NoError(t, err)
+
+	enabled := config.LocalSubSettings{Filters: true}
+	noCapsSup := mocks.NewUpstreamSupervisorMock()
+	noCapsSup.On("GetChainSupervisor", chains.ETHEREUM).Return(&stubChainSupervisor{
+		state: upstreams.ChainSupervisorState{Caps: mapset.NewThreadUnsafeSet[protocol.Cap](protocol.WsCap)},
+	})
+
+	tests := []struct {
+		name       string
+		supervisor upstreams.UpstreamSupervisor
+		filters    *FilterRegistry
+		settings   config.LocalSubSettings
+		request    protocol.RequestHolder
+		expected   bool
+	}{
+		{name: "new block filter", supervisor: allCapsSupervisor(), filters: filters, settings: enabled, request: filterRequest("eth_newBlockFilter", `[]`), expected: true},
+		{name: "new filter", supervisor: allCapsSupervisor(), filters: filters, settings: enabled, request: filterRequest("eth_newFilter", `[{}]`), expected: true},
+		{name: "disabled", supervisor: allCapsSupervisor(), filters: filters, settings: config.LocalSubSettings{}, request: filterRequest("eth_newBlockFilter", `[]`)},
+		{name: "no registry", supervisor: allCapsSupervisor(), settings: enabled, request: filterRequest("eth_newBlockFilter", `[]`)},
+		{name: "no caps", supervisor: noCapsSup, filters: filters, settings: enabled, request: filterRequest("eth_newFilter", `[{}]`)},
+		{
+			name:       "new filter with selectors",
+			supervisor: allCapsSupervisor(),
+			filters:    filters,
+			settings:   enabled,
+			request: protocol.NewUpstreamJsonRpcRequest(
+				"1",
+				protocol.JsonRpcRequestBody{Method: "eth_newFilter", Params: []byte(`[{}]`)},
+				false,
+				"eth",
+				protocol.RequestLabelSelector{Name: "archive", Values: []string{"true"}},
+			),
+		},
+		{name: "pending tx filter", supervisor: allCapsSupervisor(), filters: filters, settings: enabled, request: filterRequest("eth_newPendingTransactionFilter", `[]`)},
+		{name: "local filter id", supervisor: allCapsSupervisor(), filters: filters, settings: enabled, request: filterRequest("eth_getFilterChanges", `["`+id+`"]`), expected: true},
+		{name: "upstream filter id", supervisor: allCapsSupervisor(), filters: filters, settings: enabled, request: filterRequest("eth_uninstallFilter", `["0x1a2b3c"]`)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			assert.Equal(te, test.expected, isLocalFilterRequest(chains.ETHEREUM, test.supervisor, test.filters, test.settings, test.request))
+		})
+	}
+}
+
+func TestFilterRequestProcessorServesFilterState(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	filters := NewFilterRegistry(ctx)
+	events := make(chan *protocol.WsResponse)
+	id, err := filters.install(chains.ETHEREUM, blockFilter, nil, subengine.NewEngine(ctx, chains.ETHEREUM), "heads", testFilterSource(events))
+	require.NoError(t, err)
+	events <- &protocol.WsResponse{Message: []byte(`{"hash":"0xaa"}`)}
+
+	processor := NewFilterRequestProcessor(chains.ETHEREUM, mocks.NewUpstreamSupervisorMock(), nil, nil, filters)
+	process := func(method, params string) protocol.ResponseHolder {
+		response := processor.ProcessRequest(ctx, nil, filterRequest(method, params))
+		unary, ok := response.(*UnaryResponse)
+		require.True(t, ok)
+		assert.Equal(t, NoUpstream, unary.ResponseWrapper.UpstreamId)
+		return unary.ResponseWrapper.Response
+	}
+
+	assert.Eventually(t, func() bool {
+		filter, _ := filters.get(chains.ETHEREUM, id)
+		filter.mu.Lock()
+		defer filter.mu.Unlock()
+		return len(filter.changes) == 1
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, `["0xaa"]`, string(process("eth_getFilterChanges", `["`+id+`"]`).ResponseResult()))
+
+	logsResponse := process("eth_getFilterLogs", `["`+id+`"]`)
+	require.True(t, logsResponse.HasError())
+	assert.Equal(t, protocol.FilterNotFoundError(), logsResponse.GetError())
+
+	assert.Equal(t, "true", string(process("eth_uninstallFilter", `["`+id+`"]`).ResponseResult()))
+
+	changesResponse := process("eth_getFilterChanges", `["`+id+`"]`)
+	require.True(t, changesResponse.HasError())
+	assert.Equal(t, protocol.FilterNotFoundError(), changesResponse.GetError())
+}
@@ -32,7 +32,7 @@ func TestIntegrityRequestProcessor_QuorumRequested_Bypasses(t *testing.T) {
 	processor.AssertExpectations(t)
 	upSupervisor.AssertNotCalled(t, "GetChainSupervisor", mock.Anything)
 	upSupervisor.AssertNotCalled(t, "GetUpstream", mock.Anything)
-	upSupervisor.AssertNotCalled(t, "GetExecutor")
+	upSupervisor.AssertNotCalled(t, "GetExecutor", mock.Anything, mock.Anything)
 	strategy.AssertNotCalled(t, "SelectUpstream", mock.Anything)
 
 	assert.Equal(t, &flow.UnaryResponse{}, resp)
@@ -66,7 +66,7 @@ func TestIntegrityRequestProcessorNotHandledIfErr(t *testing.T) {
 	ctx := context.Background()
 	integrityProcessor := flow.NewIntegrityRequestProcessor(chains.ARBITRUM, upSupervisor, processor)
 
-	upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 	strategy.On("SelectUpstream", request).Return("", protocol.NoAvailableUpstreamsError())
 
 	resp := integrityProcessor.ProcessRequest(ctx, strategy, request)
@@ -95,10 +95,10 @@ func TestIntegrityRequestProcessorNotHandledIfRespWithErr(t *testing.T) {
 	processor := NewRequestProcessorMock()
 	integrityProcessor := flow.NewIntegrityRequestProcessor(chains.ARBITRUM, upSupervisor, processor)
 
-	upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 	strategy.On("SelectUpstream", request).Return("id", nil)
 	upSupervisor.On("GetUpstream", "id").Return(upstream)
-	apiConnector.On("SendRequest", ctx, request).Return(responseHolder)
+	apiConnector.On("SendRequest", mock.Anything, request).Return(responseHolder)
 
 	resp := integrityProcessor.ProcessRequest(ctx, strategy, request)
 
@@ -48,7 +48,8 @@ type LabelGroupStrategy struct {
 // it takes the rating-sorted upstreams for (chain, method) and partitions them
 // into ordered groups per the label-balancing config (see PartitionLabelGroups),
 // reading each upstream's config group-labels via the supervisor. This mirrors
-// NewRatingStrategy, which likewise pulls its sorted list from the registry.
+// NewRatingStrategy, which likewise pulls its sorted list from the registry and
+// puts the cheaper upstream first among the ones with equal scores.
 func NewLabelGroupStrategy(
 	chain chains.Chain,
 	method string,
@@ -57,7 +58,7 @@ func NewLabelGroupStrategy(
 	upstreamSupervisor upstreams.UpstreamSupervisor,
 	registry *rating.RatingRegistry,
 ) *LabelGroupStrategy {
-	sorted := registry.GetSortedUpstreams(chain, method)
+	sorted := ratingOrder(chain, method, chainSupervisor, registry)
 	includeDefault := labelBalancing.IncludeDefault == nil || *labelBalancing.IncludeDefault
 	groups := PartitionLabelGroups(sorted, labelBalancing.Order, includeDefault, func(id string) mapset.Set[string] {
 		up := upstreamSupervisor.GetUpstream(id)
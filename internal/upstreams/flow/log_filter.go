@@ -76,13 +76,19 @@ type rawLogFilter struct {
 // match-everything filter; a malformed object returns an error so the caller can
 // fall back to the generic node-backed path.
 func parseLogFilter(request protocol.RequestHolder) (*logFilter, error) {
+	return parseLogFilterParam(request, 1)
+}
+
+// parseLogFilterParam extracts the filter object located at params[index], e.g.
+// params[0] of an eth_newFilter request. See parseLogFilter.
+func parseLogFilterParam(request protocol.RequestHolder, index int) (*logFilter, error) {
 	body, err := request.Body()
 	if err != nil {
 		return nil, err
 	}
 	filter := &logFilter{addresses: map[string]struct{}{}}
 
-	node, err := sonic.Get(body, "params", 1)
+	node, err := sonic.Get(body, "params", index)
 	if err != nil {
 		return filter, nil // no filter object - match everything
 	}
@@ -12,6 +12,8 @@ type MatchResponseType int
 const (
 	MethodType MatchResponseType = iota
 	AvailabilityType
+	CircuitBreakerType
+	ConcurrencyLimitType
 	RateLimiterType
 	UpstreamIndexType
 	SelectorType
@@ -88,6 +90,32 @@ func (a AvailabilityResponse) Cause() string {
 
 var _ MatchResponse = (*AvailabilityResponse)(nil)
 
+type CircuitBreakerResponse struct {
+}
+
+func (c CircuitBreakerResponse) Type() MatchResponseType {
+	return CircuitBreakerType
+}
+
+func (c CircuitBreakerResponse) Cause() string {
+	return "circuit breaker is open"
+}
+
+var _ MatchResponse = (*CircuitBreakerResponse)(nil)
+
+type ConcurrencyLimitResponse struct {
+}
+
+func (c ConcurrencyLimitResponse) Type() MatchResponseType {
+	return ConcurrencyLimitType
+}
+
+func (c ConcurrencyLimitResponse) Cause() string {
+	return "concurrency limit is reached"
+}
+
+var _ MatchResponse = (*ConcurrencyLimitResponse)(nil)
+
 type Matcher interface {
 	Match(string, *protocol.UpstreamState) MatchResponse
 }
@@ -107,6 +135,43 @@ func (s *StatusMatcher) Match(_ string, state *protocol.UpstreamState) MatchResp
 
 var _ Matcher = (*StatusMatcher)(nil)
 
+// CircuitBreakerMatcher skips an upstream while its circuit breaker is open for the method
+type CircuitBreakerMatcher struct {
+	method string
+}
+
+func (c *CircuitBreakerMatcher) Match(_ string, state *protocol.UpstreamState) MatchResponse {
+	if state.CircuitBreaker == nil || state.CircuitBreaker.Allow(c.method) {
+		return SuccessResponse{}
+	}
+	return CircuitBreakerResponse{}
+}
+
+func NewCircuitBreakerMatcher(method string) *CircuitBreakerMatcher {
+	return &CircuitBreakerMatcher{method: method}
+}
+
+var _ Matcher = (*CircuitBreakerMatcher)(nil)
+
+// ConcurrencyLimitMatcher skips an upstream while it has as many requests in flight as its concurrency limit,
+// so that excess requests go to other upstreams. A match takes a place in flight at once, so concurrent
+// selections can't both take the last one; the place is given back by ConcurrencyLimiter.RequestFinished
+// once the request is done or the upstream isn't selected after all
+type ConcurrencyLimitMatcher struct{}
+
+func (c *ConcurrencyLimitMatcher) Match(_ string, state *protocol.UpstreamState) MatchResponse {
+	if state.ConcurrencyLimiter.TryAcquire() {
+		return SuccessResponse{}
+	}
+	return ConcurrencyLimitResponse{}
+}
+
+func NewConcurrencyLimitMatcher() *ConcurrencyLimitMatcher {
+	return &ConcurrencyLimitMatcher{}
+}
+
+var _ Matcher = (*ConcurrencyLimitMatcher)(nil)
+
 type MethodMatcher struct {
 	method string
 }
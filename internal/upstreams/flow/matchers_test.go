@@ -2,9 +2,14 @@ package flow
 
 import (
 	"testing"
+	"time"
 
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/breaker"
+	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/ratelimiter"
+	"github.com/drpcorg/nodecore/pkg/chains"
 	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
 	"github.com/stretchr/testify/assert"
 )
@@ -105,6 +110,75 @@ func TestWsCapMatcherNotAvailable(t *testing.T) {
 	assert.Equal(t, "method sub is not supported", resp.Cause())
 }
 
+func TestCircuitBreakerMatcherNoCircuitBreaker(t *testing.T) {
+	matcher := NewCircuitBreakerMatcher("eth_call")
+	state := protocol.UpstreamState{}
+
+	resp := matcher.Match("1", &state)
+
+	assert.Equal(t, SuccessResponse{}, resp)
+}
+
+func TestCircuitBreakerMatcherOpenCircuit(t *testing.T) {
+	circuitBreaker := breaker.NewCircuitBreaker(chains.ETHEREUM, "1", &config.CircuitBreakerConfig{
+		FailureThreshold:     1,
+		FailureRateThreshold: 1,
+		MinimumRequests:      1,
+		Window:               time.Minute,
+		OpenDuration:         time.Minute,
+		HalfOpenSuccesses:    1,
+		PerMethod:            true,
+	}, nil)
+	circuitBreaker.Record("eth_call", true)
+	matcher := NewCircuitBreakerMatcher("eth_call")
+	state := protocol.UpstreamState{CircuitBreaker: circuitBreaker}
+
+	resp := matcher.Match("1", &state)
+
+	assert.IsType(t, CircuitBreakerResponse{}, resp)
+	assert.Equal(t, CircuitBreakerType, resp.Type())
+	assert.Equal(t, "circuit breaker is open", resp.Cause())
+	assert.Equal(t, SuccessResponse{}, NewCircuitBreakerMatcher("eth_getBalance").Match("1", &state))
+}
+
+func TestConcurrencyLimitMatcherNoLimiter(t *testing.T) {
+	matcher := NewConcurrencyLimitMatcher()
+	state := protocol.UpstreamState{}
+
+	resp := matcher.Match("1", &state)
+
+	assert.Equal(t, SuccessResponse{}, resp)
+}
+
+func TestConcurrencyLimitMatcherLimitReached(t *testing.T) {
+	concurrencyLimiter := ratelimiter.NewConcurrencyLimiter(chains.ETHEREUM, "1", &config.ConcurrencyLimitConfig{
+		Enabled:      true,
+		Algorithm:    config.GradientConcurrencyLimit,
+		InitialLimit: 1,
+		MinLimit:     1,
+		MaxLimit:     10,
+		Window:       time.Second,
+		Tolerance:    1.5,
+		BackoffRatio: 0.9,
+	})
+	matcher := NewConcurrencyLimitMatcher()
+	state := protocol.UpstreamState{ConcurrencyLimiter: concurrencyLimiter}
+
+	// a match takes the place in flight at once
+	assert.Equal(t, SuccessResponse{}, matcher.Match("1", &state))
+	assert.Equal(t, int64(1), concurrencyLimiter.GetInFlight())
+
+	resp := matcher.Match("1", &state)
+
+	assert.IsType(t, ConcurrencyLimitResponse{}, resp)
+	assert.Equal(t, ConcurrencyLimitType, resp.Type())
+	assert.Equal(t, "concurrency limit is reached", resp.Cause())
+	assert.Equal(t, int64(1), concurrencyLimiter.GetInFlight())
+
+	concurrencyLimiter.RequestFinished()
+	assert.Equal(t, SuccessResponse{}, matcher.Match("1", &state))
+}
+
 func TestUpstreamIndexMatcher(t *testing.T) {
 	matcher := NewUpstreamIndexMatcher("index")
 	state := protocol.UpstreamState{UpstreamIndex: "index"}
@@ -166,7 +166,7 @@ func TestUnaryRequestProcessorTranslatesListUnspent(t *testing.T) {
 	upSupervisor := mocks.NewUpstreamSupervisorMock()
 	strategy := mocks.NewMockStrategy()
 
-	upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 	strategy.On("SelectUpstream", request).Return("id", nil)
 	upSupervisor.On("GetUpstream", "id").Return(upstream)
 	apiConnector.On("SendRequest", mock.Anything, mock.MatchedBy(func(req protocol.RequestHolder) bool {
@@ -196,7 +196,7 @@ func TestUnaryRequestProcessorTranslatesGetBlockNumber(t *testing.T) {
 	upSupervisor := mocks.NewUpstreamSupervisorMock()
 	strategy := mocks.NewMockStrategy()
 
-	upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 	strategy.On("SelectUpstream", request).Return("id", nil)
 	upSupervisor.On("GetUpstream", "id").Return(upstream)
 	apiConnector.On("SendRequest", mock.Anything, mock.MatchedBy(func(req protocol.RequestHolder) bool {
@@ -222,7 +222,7 @@ func TestUnaryRequestProcessorListUnspentInvalidParamsNoUpstreamCall(t *testing.
 	upSupervisor := mocks.NewUpstreamSupervisorMock()
 	strategy := mocks.NewMockStrategy()
 
-	upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 	strategy.On("SelectUpstream", request).Return("id", nil)
 	upSupervisor.On("GetUpstream", "id").Return(upstream)
 
@@ -29,7 +29,7 @@ func (p *NotNullRequestProcessor) ProcessRequest(
 	upstreamStrategy UpstreamStrategy,
 	request protocol.RequestHolder,
 ) ProcessedResponse {
-	upstreamIDs, err := collectDispatchUpstreamIDs(upstreamStrategy, request)
+	upstreamIDs, err := collectDispatchUpstreamIDs(p.upstreamSupervisor, upstreamStrategy, request)
 	if err != nil {
 		return &UnaryResponse{ResponseWrapper: totalFailureWrapper(request, err)}
 	}
@@ -39,12 +39,18 @@ func (p *NotNullRequestProcessor) ProcessRequest(
 	var fallback *protocol.ResponseHolderWrapper
 	var lastErr error
 
+	// the upstreams are selected at once, the ones not tried give back their places in flight
+	tried := 0
+	defer func() {
+		releaseUpstreams(p.upstreamSupervisor, upstreamIDs[tried:]...)
+	}()
 	for _, upstreamID := range upstreamIDs {
 		select {
 		case <-ctx.Done():
 			return &UnaryResponse{ResponseWrapper: totalFailureWrapper(request, ctx.Err())}
 		default:
 		}
+		tried++
 
 		upstream := p.upstreamSupervisor.GetUpstream(upstreamID)
 		if upstream == nil {
@@ -0,0 +1,91 @@
+package flow
+
+import (
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+)
+
+// P2CStrategy is the power-of-two-choices balancing: of two random upstreams the one with the lower
+// live load is picked, the load is the latency average multiplied by the number of requests in flight,
+// which is counted by the concurrency limiter of the upstream.
+// Unlike the rating it reacts to the load at once, and the random pair keeps the traffic from herding
+// to a single upstream. The order is drawn on each selection, since the load changes between retries
+type P2CStrategy struct {
+	chainSupervisor    upstreams.ChainSupervisor
+	selectedUpstreams  mapset.Set[string]
+	additionalMatchers []Matcher
+	order              UpstreamOrder
+	mu                 sync.Mutex
+}
+
+func NewP2CStrategy(chainSupervisor upstreams.ChainSupervisor, additionalMatchers []Matcher) *P2CStrategy {
+	return &P2CStrategy{
+		chainSupervisor:    chainSupervisor,
+		additionalMatchers: additionalMatchers,
+		selectedUpstreams:  mapset.NewThreadUnsafeSet[string](),
+	}
+}
+
+func (p *P2CStrategy) WithOrder(order UpstreamOrder) *P2CStrategy {
+	p.order = order
+	return p
+}
+
+func (p *P2CStrategy) SelectUpstream(request protocol.RequestHolder) (string, error) {
+	upstreamIds := p.chainSupervisor.GetUpstreamIds()
+	if len(upstreamIds) == 0 {
+		return "", protocol.NoAvailableUpstreamsError()
+	}
+
+	ordered := p2cOrder(upstreamIds, func(upstreamId string) (float64, int64) {
+		state := p.chainSupervisor.GetUpstreamState(upstreamId)
+		if state == nil {
+			return 0, 0
+		}
+		var inFlight int64
+		if state.ConcurrencyLimiter != nil {
+			inFlight = state.ConcurrencyLimiter.GetInFlight()
+		}
+		if state.Load == nil {
+			return 0, inFlight
+		}
+		return state.Load.Cost(inFlight), inFlight
+	})
+	selectedUpstream, currentReason, trace := filterUpstreams(&p.mu, request, ordered, p.chainSupervisor, p.selectedUpstreams, p.additionalMatchers, p.order)
+	if selectedUpstream != "" {
+		return selectedUpstream, nil
+	}
+
+	return "", selectionError(currentReason, trace)
+}
+
+var _ UpstreamStrategy = (*P2CStrategy)(nil)
+
+// p2cOrder orders upstreams by repeated power-of-two-choices: each next upstream is the less loaded
+// of two random upstreams that are left. Upstreams without latency samples have no cost, and are
+// compared by the number of requests in flight
+func p2cOrder(upstreamIds []string, load func(upstreamId string) (float64, int64)) []string {
+	left := slices.Clone(upstreamIds)
+	ordered := make([]string, 0, len(left))
+	for len(left) > 1 {
+		i := rand.IntN(len(left))
+		j := rand.IntN(len(left) - 1)
+		if j >= i {
+			j++
+		}
+		iCost, iInFlight := load(left[i])
+		jCost, jInFlight := load(left[j])
+		winner := i
+		if jCost < iCost || (jCost == iCost && jInFlight < iInFlight) {
+			winner = j
+		}
+		ordered = append(ordered, left[winner])
+		left = slices.Delete(left, winner, winner+1)
+	}
+	return append(ordered, left...)
+}
@@ -0,0 +1,42 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestP2COrderLessLoadedOfTwoFirst(t *testing.T) {
+	costs := map[string]float64{"id1": 0.1, "id2": 0.5}
+
+	for i := 0; i < 100; i++ {
+		ordered := p2cOrder([]string{"id1", "id2"}, func(upstreamId string) (float64, int64) { return costs[upstreamId], 0 })
+		assert.Equal(t, []string{"id1", "id2"}, ordered)
+	}
+}
+
+func TestP2COrderMostLoadedNeverFirst(t *testing.T) {
+	costs := map[string]float64{"id1": 0.1, "id2": 0.2, "id3": 0.3, "id4": 5}
+	firsts := map[string]int{}
+
+	for i := 0; i < 1000; i++ {
+		ordered := p2cOrder([]string{"id1", "id2", "id3", "id4"}, func(upstreamId string) (float64, int64) { return costs[upstreamId], 0 })
+		assert.ElementsMatch(t, []string{"id1", "id2", "id3", "id4"}, ordered)
+		firsts[ordered[0]]++
+	}
+
+	// unlike a strict order, the traffic isn't sent to the least loaded upstream only
+	assert.Zero(t, firsts["id4"])
+	assert.Positive(t, firsts["id2"])
+	assert.Positive(t, firsts["id3"])
+	assert.Greater(t, firsts["id1"], firsts["id2"])
+}
+
+func TestP2COrderEqualCostsThenFewerInFlightFirst(t *testing.T) {
+	inFlight := map[string]int64{"id1": 3, "id2": 0}
+
+	for i := 0; i < 100; i++ {
+		ordered := p2cOrder([]string{"id1", "id2"}, func(upstreamId string) (float64, int64) { return 0, inFlight[upstreamId] })
+		assert.Equal(t, []string{"id2", "id1"}, ordered)
+	}
+}
@@ -355,6 +355,8 @@ func enrichPendingTx(
 			continue
 		}
 		sent++
+		// the upstreams aren't selected, so their places in flight are taken here
+		state.ConcurrencyLimiter.RequestStarted()
 		go func(up upstreams.Upstream) {
 			resp, err := sendUnaryRequest(bcastCtx, up, request, parsedParam)
 			if err != nil || resp.Response.HasError() {
@@ -0,0 +1,99 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/utils"
+)
+
+// RequestCoalescer deduplicates identical in-flight unary requests: the first request
+// with a key becomes the leader and goes upstream, the ones that arrive while it's
+// in flight wait for its response and get a copy of it with their own ids.
+// It is process-wide and shared by both the HTTP/WS and gRPC entry points.
+type RequestCoalescer struct {
+	calls *utils.CMap[string, *inflightCall]
+}
+
+type inflightCall struct {
+	done     chan struct{}
+	response ProcessedResponse
+	// leaderCancelled is set if the leader's context was done before the response was received,
+	// in that case the response is about the leader's client and it's not shared
+	leaderCancelled bool
+}
+
+func NewRequestCoalescer() *RequestCoalescer {
+	return &RequestCoalescer{
+		calls: utils.NewCMap[string, *inflightCall](),
+	}
+}
+
+// Do executes the request with the process func unless an identical request is already in flight.
+// The returned flag is true if the response was taken from another request, such a response
+// must not be stored to the cache since the leader does it.
+//
+// A shared response is dropped and the request is executed on its own if it's a stream,
+// which can be read only once, or a retryable error, which might not happen on another try.
+func (c *RequestCoalescer) Do(
+	ctx context.Context,
+	chain chains.Chain,
+	request protocol.RequestHolder,
+	process func() ProcessedResponse,
+) (ProcessedResponse, bool) {
+	key := coalescingKey(chain, request)
+	call := &inflightCall{done: make(chan struct{})}
+
+	if leaderCall, loaded := c.calls.LoadOrStore(key, call); loaded {
+		select {
+		case <-leaderCall.done:
+			if response, ok := leaderCall.responseFor(request); ok {
+				return response, true
+			}
+		case <-ctx.Done():
+		}
+		return process(), false
+	}
+
+	call.response = process()
+	call.leaderCancelled = ctx.Err() != nil
+	c.calls.Delete(key)
+	close(call.done)
+
+	return call.response, false
+}
+
+// inFlight is true if an identical request is being executed
+func (c *RequestCoalescer) inFlight(chain chains.Chain, request protocol.RequestHolder) bool {
+	_, ok := c.calls.Load(coalescingKey(chain, request))
+	return ok
+}
+
+func (i *inflightCall) responseFor(request protocol.RequestHolder) (ProcessedResponse, bool) {
+	if i.leaderCancelled {
+		return nil, false
+	}
+	unaryResponse, ok := i.response.(*UnaryResponse)
+	if !ok || unaryResponse.ResponseWrapper == nil || protocol.IsRetryable(unaryResponse.ResponseWrapper.Response) {
+		return nil, false
+	}
+	response, ok := protocol.CopyResponseWithId(unaryResponse.ResponseWrapper.Response, request.Id())
+	if !ok {
+		return nil, false
+	}
+
+	wrapper := *unaryResponse.ResponseWrapper
+	wrapper.RequestId = request.Id()
+	wrapper.Response = response
+
+	return &UnaryResponse{ResponseWrapper: &wrapper}, true
+}
+
+// coalescingKey is the same for requests of the chain with the same method, params and selectors,
+// all of them are covered by RequestHash. Selectors are a part of the key since differently
+// routed requests might get different responses
+func coalescingKey(chain chains.Chain, request protocol.RequestHolder) string {
+	return fmt.Sprintf("%s|%s", chain.String(), request.RequestHash())
+}
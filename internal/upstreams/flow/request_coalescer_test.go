@@ -0,0 +1,222 @@
+package flow_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/upstreams/flow"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func coalescingRequest(id, params string) protocol.RequestHolder {
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(id), Method: "eth_getBlockByNumber", Params: []byte(params)}
+	return protocol.NewUpstreamJsonRpcRequest(id, jsonBody, false, "")
+}
+
+func unaryResponse(request protocol.RequestHolder, response protocol.ResponseHolder) flow.ProcessedResponse {
+	return &flow.UnaryResponse{ResponseWrapper: &protocol.ResponseHolderWrapper{
+		UpstreamId: "id",
+		RequestId:  request.Id(),
+		Response:   response,
+	}}
+}
+
+type coalescedResult struct {
+	response flow.ProcessedResponse
+	shared   bool
+}
+
+// runCoalesced executes the leader and then the followers while the leader is in flight,
+// the leader's process func returns leaderResponse once all the followers are waiting
+func runCoalesced(
+	coalescer *flow.RequestCoalescer,
+	leader protocol.RequestHolder,
+	leaderResponse flow.ProcessedResponse,
+	followers []protocol.RequestHolder,
+	calls *atomic.Int32,
+) (coalescedResult, []coalescedResult) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var leaderResult coalescedResult
+	followerResults := make([]coalescedResult, len(followers))
+
+	wg := sync.WaitGroup{}
+	wg.Go(func() {
+		leaderResult.response, leaderResult.shared = coalescer.Do(context.Background(), chains.ETHEREUM, leader, func() flow.ProcessedResponse {
+			calls.Add(1)
+			close(started)
+			<-release
+			return leaderResponse
+		})
+	})
+	<-started
+	for i, follower := range followers {
+		wg.Go(func() {
+			followerResults[i].response, followerResults[i].shared = coalescer.Do(context.Background(), chains.ETHEREUM, follower, func() flow.ProcessedResponse {
+				calls.Add(1)
+				return unaryResponse(follower, protocol.NewSimpleHttpUpstreamResponse(follower.Id(), []byte(`"own"`), protocol.JsonRpc))
+			})
+		})
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	return leaderResult, followerResults
+}
+
+func TestRequestCoalescerIdenticalRequestsShareOneCall(t *testing.T) {
+	coalescer := flow.NewRequestCoalescer()
+	leader := coalescingRequest("1", `["0x1", false]`)
+	followers := make([]protocol.RequestHolder, 0)
+	for i := 2; i < 10; i++ {
+		followers = append(followers, coalescingRequest(fmt.Sprintf("%d", i), `["0x1", false]`))
+	}
+	calls := &atomic.Int32{}
+
+	leaderResult, followerResults := runCoalesced(
+		coalescer,
+		leader,
+		unaryResponse(leader, protocol.NewSimpleHttpUpstreamResponse("1", []byte(`"block"`), protocol.JsonRpc)),
+		followers,
+		calls,
+	)
+
+	assert.Equal(t, int32(1), calls.Load())
+	assert.False(t, leaderResult.shared)
+	for i, result := range followerResults {
+		assert.True(t, result.shared)
+		wrapper := result.response.(*flow.UnaryResponse).ResponseWrapper
+		assert.Equal(t, followers[i].Id(), wrapper.RequestId)
+		assert.Equal(t, followers[i].Id(), wrapper.Response.Id())
+		assert.Equal(t, "id", wrapper.UpstreamId)
+		assert.Equal(t, []byte(`"block"`), wrapper.Response.ResponseResult())
+	}
+}
+
+func TestRequestCoalescerShareNotRetryableError(t *testing.T) {
+	coalescer := flow.NewRequestCoalescer()
+	leader := coalescingRequest("1", `["0x1", false]`)
+	follower := coalescingRequest("2", `["0x1", false]`)
+	calls := &atomic.Int32{}
+
+	_, followerResults := runCoalesced(
+		coalescer,
+		leader,
+		unaryResponse(leader, protocol.NewTotalFailure(leader, protocol.ServerError())),
+		[]protocol.RequestHolder{follower},
+		calls,
+	)
+
+	assert.Equal(t, int32(1), calls.Load())
+	require.True(t, followerResults[0].shared)
+	wrapper := followerResults[0].response.(*flow.UnaryResponse).ResponseWrapper
+	assert.True(t, wrapper.Response.HasError())
+	assert.Equal(t, "2", wrapper.Response.Id())
+}
+
+func TestRequestCoalescerRetryableErrorNotShared(t *testing.T) {
+	coalescer := flow.NewRequestCoalescer()
+	leader := coalescingRequest("1", `["0x1", false]`)
+	follower := coalescingRequest("2", `["0x1", false]`)
+	calls := &atomic.Int32{}
+
+	_, followerResults := runCoalesced(
+		coalescer,
+		leader,
+		unaryResponse(leader, protocol.NewPartialFailure(leader, protocol.ServerError())),
+		[]protocol.RequestHolder{follower},
+		calls,
+	)
+
+	assert.Equal(t, int32(2), calls.Load())
+	assert.False(t, followerResults[0].shared)
+	wrapper := followerResults[0].response.(*flow.UnaryResponse).ResponseWrapper
+	assert.Equal(t, []byte(`"own"`), wrapper.Response.ResponseResult())
+}
+
+func TestRequestCoalescerStreamNotShared(t *testing.T) {
+	coalescer := flow.NewRequestCoalescer()
+	leader := coalescingRequest("1", `["0x1", false]`)
+	follower := coalescingRequest("2", `["0x1", false]`)
+	calls := &atomic.Int32{}
+
+	_, followerResults := runCoalesced(
+		coalescer,
+		leader,
+		unaryResponse(leader, protocol.NewHttpUpstreamResponseStream("1", bytes.NewReader([]byte(`"block"`)), protocol.JsonRpc)),
+		[]protocol.RequestHolder{follower},
+		calls,
+	)
+
+	assert.Equal(t, int32(2), calls.Load())
+	assert.False(t, followerResults[0].shared)
+}
+
+func TestRequestCoalescerDifferentRequestsNotCoalesced(t *testing.T) {
+	coalescer := flow.NewRequestCoalescer()
+	leader := coalescingRequest("1", `["0x1", false]`)
+	follower := coalescingRequest("2", `["0x2", false]`)
+	calls := &atomic.Int32{}
+
+	_, followerResults := runCoalesced(
+		coalescer,
+		leader,
+		unaryResponse(leader, protocol.NewSimpleHttpUpstreamResponse("1", []byte(`"block"`), protocol.JsonRpc)),
+		[]protocol.RequestHolder{follower},
+		calls,
+	)
+
+	assert.Equal(t, int32(2), calls.Load())
+	assert.False(t, followerResults[0].shared)
+}
+
+func TestCacheRequestProcessorCoalescedResponseStoredOnce(t *testing.T) {
+	strategy := mocks.NewMockStrategy()
+	cacheProcessor := mocks.NewCacheProcessorMock()
+	delegate := NewRequestProcessorMock()
+	coalescer := flow.NewRequestCoalescer()
+	chain := chains.POLYGON
+	ctx := context.Background()
+	result := []byte(`"block"`)
+	leader := coalescingRequest("1", `["0x1", false]`)
+	follower := coalescingRequest("2", `["0x1", false]`)
+	release := make(chan struct{})
+
+	cacheProcessor.On("Receive", ctx, chain, mock.Anything).Return((*protocol.CachedResponse)(nil), false)
+	cacheProcessor.On("Store", ctx, chain, leader, result).Return()
+	delegate.On("ProcessRequest", ctx, strategy, leader).
+		Run(func(args mock.Arguments) { <-release }).
+		Return(unaryResponse(leader, protocol.NewSimpleHttpUpstreamResponse("1", result, protocol.JsonRpc)))
+
+	processor := flow.NewCacheRequestProcessor(chain, cacheProcessor, coalescer, delegate)
+	var followerResponse flow.ProcessedResponse
+	wg := sync.WaitGroup{}
+	wg.Go(func() {
+		processor.ProcessRequest(ctx, strategy, leader)
+	})
+	time.Sleep(10 * time.Millisecond)
+	wg.Go(func() {
+		followerResponse = processor.ProcessRequest(ctx, strategy, follower)
+	})
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	time.Sleep(10 * time.Millisecond)
+
+	delegate.AssertNumberOfCalls(t, "ProcessRequest", 1)
+	cacheProcessor.AssertNumberOfCalls(t, "Store", 1)
+	wrapper := followerResponse.(*flow.UnaryResponse).ResponseWrapper
+	assert.Equal(t, "2", wrapper.RequestId)
+	assert.Equal(t, result, wrapper.Response.ResponseResult())
+}
@@ -2,13 +2,17 @@ package flow
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/resilience"
+	"github.com/drpcorg/nodecore/internal/tracing"
 	"github.com/drpcorg/nodecore/internal/upstreams"
 	"github.com/drpcorg/nodecore/internal/upstreams/connectors"
 	"github.com/drpcorg/nodecore/pkg/chains"
@@ -16,6 +20,7 @@ import (
 	"github.com/drpcorg/nodecore/pkg/utils"
 	"github.com/ethereum/go-ethereum/rpc"
 	"github.com/failsafe-go/failsafe-go"
+	"github.com/failsafe-go/failsafe-go/timeout"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/rs/zerolog"
 )
@@ -118,23 +123,42 @@ func executeUnaryRequest(
 	firstUpstream := utils.NewAtomic[string]()
 	hedged := atomic.Bool{}
 
+	ctx, release := resilience.WithCancelOnTimeout(ctx)
 	parsedParam := request.ParseParams(ctx)
 	result, err := upstreamSupervisor.
-		GetExecutor().
+		GetExecutor(chain, request.Method()).
 		WithContext(ctx).
 		GetWithExecution(func(exec failsafe.Execution[*protocol.ResponseHolderWrapper]) (*protocol.ResponseHolderWrapper, error) {
 			upstreamId, err := upstreamStrategy.SelectUpstream(request)
 			if err != nil {
 				return nil, handleErrors(exec, err)
 			}
+			// the upstream might have been removed since it was selected
+			upstream := upstreamSupervisor.GetUpstream(upstreamId)
+			if upstream == nil {
+				releaseUpstreams(upstreamSupervisor, upstreamId)
+				return nil, handleErrors(exec, protocol.NoAvailableUpstreamsError())
+			}
 			if firstUpstream.Load() == "" {
 				firstUpstream.Store(upstreamId)
 			}
 
-			responseHolder, err := sendUnaryRequest(ctx, upstreamSupervisor.GetUpstream(upstreamId), request, parsedParam)
+			attemptCtx, span := tracing.StartSpan(
+				ctx,
+				"upstream.attempt",
+				tracing.UpstreamIdKey.String(upstreamId),
+				tracing.AttemptKey.Int(exec.Attempts()),
+				tracing.RetryKey.Bool(exec.IsRetry()),
+				tracing.HedgeKey.Bool(exec.IsHedge()),
+			)
+			defer span.End()
+
+			responseHolder, err := sendUnaryRequest(attemptCtx, upstream, request, parsedParam)
 			if err != nil {
+				tracing.SetError(span, err)
 				return nil, handleErrors(exec, err)
 			}
+			span.SetAttributes(tracing.ResponseKindKey.String(protocol.GetRespKindFromResponse(responseHolder.Response).String()))
 			if exec.Hedges() > 0 {
 				hedged.Store(true)
 			}
@@ -145,10 +169,33 @@ func executeUnaryRequest(
 		// it's important to track the very first upstream that caused the hedge logic
 		hedgeMetric.WithLabelValues(chain.String(), request.Method(), firstUpstream.Load()).Inc()
 	}
+	releaseOnConsumed(result, release)
+	if errors.Is(err, timeout.ErrExceeded) {
+		return nil, protocol.RequestTimeoutError()
+	}
 
 	return result, err
 }
 
+// streamDoneNotifier is implemented by responses whose stream can tell when it's consumed
+type streamDoneNotifier interface {
+	OnStreamDone(done func()) *protocol.GenericUpstreamResponse
+}
+
+// releaseOnConsumed releases the attempt context once the response no longer needs it.
+// A streamed response still reads from the upstream through that context, so it's released when the stream is done
+func releaseOnConsumed(result *protocol.ResponseHolderWrapper, release context.CancelFunc) {
+	if result == nil || result.Response == nil || !result.Response.HasStream() {
+		release()
+		return
+	}
+	if notifier, ok := result.Response.(streamDoneNotifier); ok {
+		notifier.OnStreamDone(release)
+		return
+	}
+	release()
+}
+
 // selectAndSend selects a single upstream via the strategy and sends the request
 // to it directly, WITHOUT the failsafe executor (no retry/hedge policies). It is
 // the lightweight counterpart to executeUnaryRequest for callers that just need a
@@ -167,11 +214,29 @@ func selectAndSend(
 	}
 	upstream := upstreamSupervisor.GetUpstream(upstreamId)
 	if upstream == nil {
+		releaseUpstreams(upstreamSupervisor, upstreamId)
 		return nil, protocol.NoAvailableUpstreamsError()
 	}
 	return sendUnaryRequest(ctx, upstream, request, request.ParseParams(ctx))
 }
 
+// releaseUpstreams gives back the places in flight taken by selecting upstreams no request is sent to.
+// An upstream removed since it was selected gives its place back through its state in the chain supervisor if it's still there
+func releaseUpstreams(upstreamSupervisor upstreams.UpstreamSupervisor, upstreamIds ...string) {
+	for _, upstreamId := range upstreamIds {
+		if upstream := upstreamSupervisor.GetUpstream(upstreamId); upstream != nil {
+			upstream.GetUpstreamState().ConcurrencyLimiter.RequestFinished()
+			continue
+		}
+		for _, chainSupervisor := range upstreamSupervisor.GetChainSupervisors() {
+			if state := chainSupervisor.GetUpstreamState(upstreamId); state != nil {
+				state.ConcurrencyLimiter.RequestFinished()
+				break
+			}
+		}
+	}
+}
+
 func getMethodConnector(upstream upstreams.Upstream, method *specs.Method) connectors.ApiConnector {
 	for _, connector := range method.GetApiConnectorTypes() {
 		if upConnector := upstream.GetConnector(connector); upConnector != nil {
@@ -189,11 +254,14 @@ func sendUnaryRequest(
 ) (*protocol.ResponseHolderWrapper, error) {
 	zerolog.Ctx(ctx).Debug().Msgf("sending a request %s to upstream %s", request.Method(), upstream.GetId())
 
+	// the place in flight is taken when the upstream is selected, it's given back once the upstream responds
+	concurrencyLimiter := upstream.GetUpstreamState().ConcurrencyLimiter
 	upstreamRequest := request
 	translator := getMethodTranslator(chains.GetMethodSpecNameByChain(upstream.GetChain()), request.Method())
 	if translator != nil {
 		translated, err := translator.TranslateRequest(ctx, request)
 		if err != nil {
+			concurrencyLimiter.RequestFinished()
 			return &protocol.ResponseHolderWrapper{
 				RequestId:  request.Id(),
 				UpstreamId: upstream.GetId(),
@@ -205,10 +273,35 @@ func sendUnaryRequest(
 
 	apiConnector := getMethodConnector(upstream, upstreamRequest.SpecMethod())
 	if apiConnector == nil {
+		concurrencyLimiter.RequestFinished()
 		return nil, protocol.NoApiConnectorsError(request.Method())
 	}
 
-	response := apiConnector.SendRequest(ctx, upstreamRequest)
+	connectorCtx, span := tracing.StartClientSpan(
+		ctx,
+		"connector.send",
+		tracing.UpstreamIdKey.String(upstream.GetId()),
+		tracing.MethodKey.String(upstreamRequest.Method()),
+		tracing.ConnectorTypeKey.String(apiConnector.GetType().String()),
+	)
+	load := upstream.GetUpstreamState().Load
+	sentAt := time.Now()
+	response := apiConnector.SendRequest(connectorCtx, upstreamRequest)
+	latency := time.Since(sentAt)
+	upstream.GetUpstreamState().Cost.Record(request.Method())
+	concurrencyLimiter.RequestFinished()
+	// a cancelled request, e.g. a hedge that lost, says nothing about the upstream latency but that it's
+	// higher than the time the request was dropped after, the concurrency limit counts it as slow
+	if ctx.Err() == nil {
+		if load != nil {
+			load.ObserveLatency(latency)
+		}
+		concurrencyLimiter.ObserveLatency(latency)
+	} else {
+		concurrencyLimiter.ObserveDropped(latency)
+	}
+	span.SetAttributes(tracing.ResponseKindKey.String(protocol.GetRespKindFromResponse(response).String()))
+	span.End()
 	if translator != nil {
 		response = translator.TranslateResponse(request, upstreamRequest, upstream.GetCurrentHeadHeight(), response)
 	}
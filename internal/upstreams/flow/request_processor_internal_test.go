@@ -0,0 +1,80 @@
+package flow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/ratelimiter"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/drpcorg/nodecore/pkg/test_utils"
+	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// removedAfterSelection returns a strategy selecting the upstream "id" of a chain supervisor that the upstream
+// supervisor can't find anymore, as if the upstream was removed right after it was selected
+func removedAfterSelection(t *testing.T, method string) (*mocks.UpstreamSupervisorMock, UpstreamStrategy, *ratelimiter.ConcurrencyLimiter) {
+	chainSupervisor := test_utils.CreateChainSupervisor()
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string](method))
+	methodsMock.On("HasMethod", method).Return(true)
+	concurrencyLimiter := ratelimiter.NewConcurrencyLimiter(chains.ARBITRUM, "id", &config.ConcurrencyLimitConfig{
+		Enabled:      true,
+		Algorithm:    config.AimdConcurrencyLimit,
+		InitialLimit: 10,
+		MinLimit:     1,
+		MaxLimit:     10,
+		Window:       time.Second,
+		Tolerance:    1.5,
+		BackoffRatio: 0.9,
+	})
+	state := protocol.DefaultUpstreamState(methodsMock, mapset.NewThreadUnsafeSet(protocol.WsCap), "index", nil, nil)
+	state.ConcurrencyLimiter = concurrencyLimiter
+	chainSupervisor.PublishUpstreamEvent(protocol.UpstreamEvent{Id: "id", EventType: &protocol.StateUpstreamEvent{State: &state}})
+	require.Eventually(t, func() bool {
+		return chainSupervisor.GetUpstreamState("id") != nil
+	}, time.Second, 10*time.Millisecond)
+
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	upSupervisor.On("GetUpstream", "id").Return(nil)
+	upSupervisor.On("GetChainSupervisors").Return([]upstreams.ChainSupervisor{chainSupervisor})
+	return upSupervisor, NewGenericStrategy(chainSupervisor), concurrencyLimiter
+}
+
+func TestExecuteUnaryRequestUpstreamRemovedAfterSelectionThenPlaceGivenBack(t *testing.T) {
+	upSupervisor, strategy, concurrencyLimiter := removedAfterSelection(t, "eth_getBalance")
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
+	request, _ := protocol.NewInternalUpstreamJsonRpcRequest("eth_getBalance", nil, chains.ARBITRUM)
+
+	_, err := executeUnaryRequest(context.Background(), chains.ARBITRUM, request, upSupervisor, strategy)
+
+	assert.Equal(t, protocol.NoAvailableUpstreamsError(), err)
+	assert.Equal(t, int64(0), concurrencyLimiter.GetInFlight())
+}
+
+func TestSelectAndSendUpstreamRemovedAfterSelectionThenPlaceGivenBack(t *testing.T) {
+	upSupervisor, strategy, concurrencyLimiter := removedAfterSelection(t, "eth_getLogs")
+	request, _ := protocol.NewInternalUpstreamJsonRpcRequest("eth_getLogs", nil, chains.ARBITRUM)
+
+	_, err := selectAndSend(context.Background(), upSupervisor, request, strategy)
+
+	assert.Equal(t, protocol.NoAvailableUpstreamsError(), err)
+	assert.Equal(t, int64(0), concurrencyLimiter.GetInFlight())
+}
+
+func TestGenericSourceBuilderUpstreamRemovedAfterSelectionThenPlaceGivenBack(t *testing.T) {
+	upSupervisor, strategy, concurrencyLimiter := removedAfterSelection(t, "eth_subscribe")
+	request := protocol.NewUpstreamJsonRpcRequest("1", protocol.JsonRpcRequestBody{Method: "eth_subscribe", Params: []byte(`["newHeads"]`)}, true, "")
+
+	_, err := newGenericSourceBuilder(upSupervisor, request, strategy)(context.Background())
+
+	assert.Equal(t, protocol.NoAvailableUpstreamsError(), err)
+	assert.Equal(t, int64(0), concurrencyLimiter.GetInFlight())
+}
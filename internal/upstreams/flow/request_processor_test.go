@@ -3,12 +3,17 @@ package flow_test
 import (
 	"context"
 	"errors"
+	"io"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/resilience"
+	"github.com/drpcorg/nodecore/internal/tracing"
+	"github.com/drpcorg/nodecore/internal/upstreams"
 	"github.com/drpcorg/nodecore/internal/upstreams/flow"
 	"github.com/drpcorg/nodecore/pkg/chains"
 	specs "github.com/drpcorg/nodecore/pkg/methods"
@@ -16,6 +21,7 @@ import (
 	"github.com/drpcorg/nodecore/pkg/test_utils/mocks"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 func TestUnaryRequestProcessorSubMethodThenError(t *testing.T) {
@@ -35,7 +41,7 @@ func TestUnaryRequestProcessorSubMethodThenError(t *testing.T) {
 
 	unaryRespWrapper := response.(*flow.UnaryResponse).ResponseWrapper
 
-	upSupervisor.AssertNotCalled(t, "GetExecutor")
+	upSupervisor.AssertNotCalled(t, "GetExecutor", mock.Anything, mock.Anything)
 	upSupervisor.AssertNotCalled(t, "GetUpstream")
 	strategy.AssertNotCalled(t, "SelectUpstream")
 
@@ -53,7 +59,7 @@ func TestUnaryRequestProcessorCantGetUpstreamThenError(t *testing.T) {
 	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
 	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
 
-	upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 	strategy.On("SelectUpstream", request).Return("", err)
 
 	processor := flow.NewUnaryRequestProcessor(chain, upSupervisor)
@@ -73,6 +79,31 @@ func TestUnaryRequestProcessorCantGetUpstreamThenError(t *testing.T) {
 	assert.Equal(t, protocol.ResponseErrorWithData(500, "internal server error: selection error", nil), unaryRespWrapper.Response.GetError())
 }
 
+func TestUnaryRequestProcessorUpstreamRemovedAfterSelectionThenError(t *testing.T) {
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	strategy := mocks.NewMockStrategy()
+	chain := chains.POLYGON
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
+
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
+	strategy.On("SelectUpstream", request).Return("id", nil)
+	upSupervisor.On("GetUpstream", "id").Return(nil)
+	upSupervisor.On("GetChainSupervisors").Return([]upstreams.ChainSupervisor{})
+
+	processor := flow.NewUnaryRequestProcessor(chain, upSupervisor)
+	response := processor.ProcessRequest(context.Background(), strategy, request)
+
+	unaryRespWrapper := response.(*flow.UnaryResponse).ResponseWrapper
+
+	upSupervisor.AssertExpectations(t)
+	strategy.AssertExpectations(t)
+
+	assert.Equal(t, flow.NoUpstream, unaryRespWrapper.UpstreamId)
+	assert.True(t, unaryRespWrapper.Response.HasError())
+	assert.Equal(t, protocol.NoAvailableUpstreamsError().Code, unaryRespWrapper.Response.GetError().Code)
+}
+
 func TestUnaryRequestProcessorNoConnectorThenError(t *testing.T) {
 	upSupervisor := mocks.NewUpstreamSupervisorMock()
 	strategy := mocks.NewMockStrategy()
@@ -82,7 +113,7 @@ func TestUnaryRequestProcessorNoConnectorThenError(t *testing.T) {
 	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
 	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "")
 
-	upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 	strategy.On("SelectUpstream", request).Return("id", nil)
 	upSupervisor.On("GetUpstream", "id").Return(upstream)
 
@@ -122,3 +153,142 @@ func (r *RequestProcessorMock) ProcessRequest(ctx context.Context, upstreamStrat
 func NewRequestProcessorMock() *RequestProcessorMock {
 	return &RequestProcessorMock{}
 }
+
+func TestUnaryRequestProcessorRetryThenSpanPerAttempt(t *testing.T) {
+	recorder := test_utils.EnableTracing(t, false)
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	strategy := mocks.NewMockStrategy()
+	apiConnector := mocks.NewConnectorMock()
+	chain := chains.POLYGON
+	upstream := test_utils.TestEvmUpstream(apiConnector, upConfig(), mocks.NewMethodsMock(), nil)
+	_ = specs.NewMethodSpecLoader().Load()
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "eth")
+	failure := protocol.NewPartialFailure(request, protocol.ServerError())
+	success := protocol.NewSimpleHttpUpstreamResponse("1", []byte(`"result"`), protocol.JsonRpc)
+
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(resilience.CreateFlowExecutor(resilience.CreateFlowRetryPolicy(&config.RetryConfig{Attempts: 2})))
+	strategy.On("SelectUpstream", request).Return("id", nil)
+	upSupervisor.On("GetUpstream", "id").Return(upstream)
+	apiConnector.On("SendRequest", mock.Anything, request).Return(failure).Once()
+	apiConnector.On("SendRequest", mock.Anything, request).Return(success).Once()
+
+	ctx, parentSpan := tracing.StartSpan(context.Background(), "parent")
+	processor := flow.NewUnaryRequestProcessor(chain, upSupervisor)
+	response := processor.ProcessRequest(ctx, strategy, request)
+	parentSpan.End()
+
+	assert.False(t, response.(*flow.UnaryResponse).ResponseWrapper.Response.HasError())
+
+	attempts := test_utils.EndedSpans(recorder, "upstream.attempt")
+	require.Len(t, attempts, 2)
+	assert.Equal(t, int64(1), test_utils.SpanAttribute(attempts[0], tracing.AttemptKey).AsInt64())
+	assert.False(t, test_utils.SpanAttribute(attempts[0], tracing.RetryKey).AsBool())
+	assert.Equal(t, protocol.GetRespKindFromResponse(failure).String(), test_utils.SpanAttribute(attempts[0], tracing.ResponseKindKey).AsString())
+	assert.Equal(t, int64(2), test_utils.SpanAttribute(attempts[1], tracing.AttemptKey).AsInt64())
+	assert.True(t, test_utils.SpanAttribute(attempts[1], tracing.RetryKey).AsBool())
+	assert.Equal(t, protocol.Ok.String(), test_utils.SpanAttribute(attempts[1], tracing.ResponseKindKey).AsString())
+
+	connectorSpans := test_utils.EndedSpans(recorder, "connector.send")
+	require.Len(t, connectorSpans, 2)
+	for i, connectorSpan := range connectorSpans {
+		assert.Equal(t, attempts[i].SpanContext().SpanID(), connectorSpan.Parent().SpanID())
+		assert.Equal(t, "id", test_utils.SpanAttribute(connectorSpan, tracing.UpstreamIdKey).AsString())
+		assert.Equal(t, parentSpan.SpanContext().SpanID(), attempts[i].Parent().SpanID())
+		assert.Equal(t, parentSpan.SpanContext().TraceID(), connectorSpan.SpanContext().TraceID())
+	}
+}
+
+func TestUnaryRequestProcessorTimeoutThenTimeoutError(t *testing.T) {
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	strategy := mocks.NewMockStrategy()
+	apiConnector := mocks.NewConnectorMock()
+	chain := chains.POLYGON
+	upstream := test_utils.TestEvmUpstream(apiConnector, upConfig(), mocks.NewMethodsMock(), nil)
+	_ = specs.NewMethodSpecLoader().Load()
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "eth")
+	executor := resilience.CreateFlowExecutor(
+		resilience.CreateFlowTimeoutPolicy(&config.TimeoutConfig{Timeout: 50 * time.Millisecond}),
+		resilience.CreateFlowRetryPolicy(&config.RetryConfig{Attempts: 2}),
+	)
+
+	upSupervisor.On("GetExecutor", chain, "eth_call").Return(executor)
+	strategy.On("SelectUpstream", request).Return("id", nil)
+	upSupervisor.On("GetUpstream", "id").Return(upstream)
+	apiConnector.On("SendRequest", mock.Anything, request).
+		Run(func(args mock.Arguments) {
+			<-args.Get(0).(context.Context).Done()
+		}).
+		Return(protocol.NewPartialFailure(request, protocol.ServerError()))
+
+	processor := flow.NewUnaryRequestProcessor(chain, upSupervisor)
+	start := time.Now()
+	response := processor.ProcessRequest(context.Background(), strategy, request)
+
+	upSupervisor.AssertExpectations(t)
+	assert.Less(t, time.Since(start), time.Second)
+	responseWrapper := response.(*flow.UnaryResponse).ResponseWrapper
+	assert.Equal(t, flow.NoUpstream, responseWrapper.UpstreamId)
+	assert.Equal(t, protocol.RequestTimeoutError(), responseWrapper.Response.GetError())
+	apiConnector.AssertNumberOfCalls(t, "SendRequest", 1)
+}
+
+func TestUnaryRequestProcessorResponseThenAttemptContextReleased(t *testing.T) {
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	strategy := mocks.NewMockStrategy()
+	apiConnector := mocks.NewConnectorMock()
+	chain := chains.POLYGON
+	upstream := test_utils.TestEvmUpstream(apiConnector, upConfig(), mocks.NewMethodsMock(), nil)
+	_ = specs.NewMethodSpecLoader().Load()
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "eth")
+	var attemptCtx context.Context
+
+	upSupervisor.On("GetExecutor", chain, "eth_call").Return(resilience.CreateFlowExecutor(resilience.CreateFlowRetryPolicy(&config.RetryConfig{Attempts: 1})))
+	strategy.On("SelectUpstream", request).Return("id", nil)
+	upSupervisor.On("GetUpstream", "id").Return(upstream)
+	apiConnector.On("SendRequest", mock.Anything, request).
+		Run(func(args mock.Arguments) {
+			attemptCtx = args.Get(0).(context.Context)
+		}).
+		Return(protocol.NewSimpleHttpUpstreamResponse("1", []byte(`"result"`), protocol.JsonRpc))
+
+	processor := flow.NewUnaryRequestProcessor(chain, upSupervisor)
+	response := processor.ProcessRequest(context.Background(), strategy, request)
+
+	assert.False(t, response.(*flow.UnaryResponse).ResponseWrapper.Response.HasError())
+	require.NotNil(t, attemptCtx)
+	assert.ErrorIs(t, attemptCtx.Err(), context.Canceled)
+}
+
+func TestUnaryRequestProcessorStreamThenAttemptContextReleasedWhenStreamIsRead(t *testing.T) {
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	strategy := mocks.NewMockStrategy()
+	apiConnector := mocks.NewConnectorMock()
+	chain := chains.POLYGON
+	upstream := test_utils.TestEvmUpstream(apiConnector, upConfig(), mocks.NewMethodsMock(), nil)
+	_ = specs.NewMethodSpecLoader().Load()
+	jsonBody := protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}
+	request := protocol.NewUpstreamJsonRpcRequest("223", jsonBody, false, "eth")
+	var attemptCtx context.Context
+
+	upSupervisor.On("GetExecutor", chain, "eth_call").Return(resilience.CreateFlowExecutor(resilience.CreateFlowRetryPolicy(&config.RetryConfig{Attempts: 1})))
+	strategy.On("SelectUpstream", request).Return("id", nil)
+	upSupervisor.On("GetUpstream", "id").Return(upstream)
+	apiConnector.On("SendRequest", mock.Anything, request).
+		Run(func(args mock.Arguments) {
+			attemptCtx = args.Get(0).(context.Context)
+		}).
+		Return(protocol.NewHttpUpstreamResponseStream("1", strings.NewReader(`{"result":"0x1"}`), protocol.JsonRpc))
+
+	processor := flow.NewUnaryRequestProcessor(chain, upSupervisor)
+	response := processor.ProcessRequest(context.Background(), strategy, request)
+
+	require.NotNil(t, attemptCtx)
+	assert.NoError(t, attemptCtx.Err())
+
+	_, err := io.ReadAll(response.(*flow.UnaryResponse).ResponseWrapper.Response.EncodeResponse([]byte("1")))
+	assert.NoError(t, err)
+	assert.ErrorIs(t, attemptCtx.Err(), context.Canceled)
+}
@@ -24,7 +24,7 @@ func TestNotStickyRequestThenError(t *testing.T) {
 	result := processor.ProcessRequest(context.Background(), nil, request)
 
 	upSupervisor.AssertNotCalled(t, "GetChainSupervisor", mock.Anything)
-	upSupervisor.AssertNotCalled(t, "GetExecutor")
+	upSupervisor.AssertNotCalled(t, "GetExecutor", mock.Anything, mock.Anything)
 	upSupervisor.AssertNotCalled(t, "GetUpstream", mock.Anything)
 
 	expected := &protocol.ResponseHolderWrapper{
@@ -48,7 +48,7 @@ func TestStickySendNoNothingToParseThenRequestAsIs(t *testing.T) {
 	responseHolder := protocol.NewSimpleHttpUpstreamResponse("1", result, protocol.JsonRpc)
 	processor := flow.NewStickyRequestProcessor(chains.POLYGON, upSupervisor)
 
-	upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 	strategy.On("SelectUpstream", request).Return("id", nil)
 	upSupervisor.On("GetUpstream", "id").Return(upstream)
 	apiConnector.On("SendRequest", mock.Anything, request).Return(responseHolder)
@@ -82,7 +82,7 @@ func TestStickySendModifyRequest(t *testing.T) {
 	responseHolder := protocol.NewSimpleHttpUpstreamResponse("1", result, protocol.JsonRpc)
 	processor := flow.NewStickyRequestProcessor(chains.POLYGON, upSupervisor)
 
-	upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 	strategy.On("SelectUpstream", request).Return("id", nil)
 	upSupervisor.On("GetUpstream", "id").Return(upstream)
 	apiConnector.On("SendRequest", mock.Anything, request).Return(responseHolder)
@@ -131,7 +131,7 @@ func TestStickyRequestError(t *testing.T) {
 			err := errors.New("error")
 			processor := flow.NewStickyRequestProcessor(chains.POLYGON, upSupervisor)
 
-			upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+			upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 			strategy.On("SelectUpstream", request).Return("", err)
 
 			response := processor.ProcessRequest(context.Background(), strategy, request)
@@ -161,7 +161,7 @@ func TestCreateStickyModifyResponse(t *testing.T) {
 	responseHolder := protocol.NewSimpleHttpUpstreamResponse("1", result, protocol.JsonRpc)
 	processor := flow.NewStickyRequestProcessor(chains.POLYGON, upSupervisor)
 
-	upSupervisor.On("GetExecutor").Return(test_utils.CreateExecutor())
+	upSupervisor.On("GetExecutor", mock.Anything, mock.Anything).Return(test_utils.CreateExecutor())
 	strategy.On("SelectUpstream", request).Return("id", nil)
 	upSupervisor.On("GetUpstream", "id").Return(upstream)
 	apiConnector.On("SendRequest", mock.Anything, request).Return(responseHolder)
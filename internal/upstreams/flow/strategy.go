@@ -1,6 +1,8 @@
 package flow
 
 import (
+	"cmp"
+	"slices"
 	"sync"
 
 	mapset "github.com/deckarep/golang-set/v2"
@@ -66,7 +68,7 @@ func NewRatingStrategy(
 	chainSupervisor upstreams.ChainSupervisor,
 	registry *rating.RatingRegistry,
 ) *RatingStrategy {
-	ups := registry.GetSortedUpstreams(chain, method)
+	ups := ratingOrder(chain, method, chainSupervisor, registry)
 	return &RatingStrategy{
 		chainSupervisor:    chainSupervisor,
 		ups:                ups,
@@ -165,12 +167,26 @@ func filterUpstreams(
 	}
 
 	multiMatcher := NewMultiMatcher(matchers...)
+	circuitBreakerMatcher := NewCircuitBreakerMatcher(request.Method())
+	// the concurrency limit matcher takes a place in flight, so it goes after the other matchers,
+	// and the place is given back if the upstream isn't selected
+	concurrencyLimitMatcher := NewConcurrencyLimitMatcher()
+	// ejected are the upstreams that match in every way but their circuit breaker
+	ejected := make([]string, 0)
 	for i := 0; i < len(upstreamIds); i++ {
 		upstreamState := chainSupervisor.GetUpstreamState(upstreamIds[i])
 		if upstreamState == nil {
 			continue
 		}
 		matched := multiMatcher.Match(upstreamIds[i], upstreamState)
+		if matched.Type() == SuccessType {
+			if circuitMatched := circuitBreakerMatcher.Match(upstreamIds[i], upstreamState); circuitMatched.Type() != SuccessType {
+				ejected = append(ejected, upstreamIds[i])
+				matched = circuitMatched
+			} else {
+				matched = concurrencyLimitMatcher.Match(upstreamIds[i], upstreamState)
+			}
+		}
 		trace.Add(upstreamIds[i], matched)
 
 		upstreamMatched, newReason := processMatchedResponse(mu, matched, currentReason, selectedUpstreams, upstreamIds[i], upstreamState, request)
@@ -179,19 +195,68 @@ func filterUpstreams(
 			if upstreamState.AutoTuneRateLimiter != nil {
 				allowed = upstreamState.AutoTuneRateLimiter.Allow()
 			}
-			if allowed {
+			if !allowed {
+				upstreamState.ConcurrencyLimiter.RequestFinished()
+				if currentReason == nil || (RateLimiterResponse{}).Type() < currentReason.Type() {
+					currentReason = RateLimiterResponse{}
+				}
+				continue
+			}
+			// the permit is taken last, a half-open circuit gets it back only once the request is done
+			if upstreamState.CircuitBreaker == nil || upstreamState.CircuitBreaker.TryAcquire(request.Method()) {
 				return upstreamIds[i], nil, trace
 			}
-			if currentReason == nil || (RateLimiterResponse{}).Type() < currentReason.Type() {
-				currentReason = RateLimiterResponse{}
+			upstreamState.ConcurrencyLimiter.RequestFinished()
+			unselectUpstream(mu, selectedUpstreams, upstreamIds[i])
+			ejected = append(ejected, upstreamIds[i])
+			if currentReason == nil || (CircuitBreakerResponse{}).Type() < currentReason.Type() {
+				currentReason = CircuitBreakerResponse{}
 			}
-		} else if newReason != nil {
+			continue
+		}
+		if matched.Type() == SuccessType {
+			upstreamState.ConcurrencyLimiter.RequestFinished()
+		}
+		if newReason != nil {
 			currentReason = newReason
 		}
 	}
+
+	// ejecting every upstream leaves no one to serve the request, so the least bad of them serves it anyway
+	for _, upstreamId := range leastBadFirst(ejected, chainSupervisor, request.Method()) {
+		upstreamState := chainSupervisor.GetUpstreamState(upstreamId)
+		if upstreamState == nil || concurrencyLimitMatcher.Match(upstreamId, upstreamState).Type() != SuccessType {
+			continue
+		}
+		upstreamMatched, _ := processMatchedResponse(mu, SuccessResponse{}, currentReason, selectedUpstreams, upstreamId, upstreamState, request)
+		if upstreamMatched && (upstreamState.AutoTuneRateLimiter == nil || upstreamState.AutoTuneRateLimiter.Allow()) {
+			return upstreamId, nil, trace
+		}
+		upstreamState.ConcurrencyLimiter.RequestFinished()
+	}
 	return "", currentReason, trace
 }
 
+// leastBadFirst orders the ejected upstreams by the failure rate of the method, the lowest first
+func leastBadFirst(ejected []string, chainSupervisor upstreams.ChainSupervisor, method string) []string {
+	failureRates := make(map[string]float64, len(ejected))
+	for _, upstreamId := range ejected {
+		if upstreamState := chainSupervisor.GetUpstreamState(upstreamId); upstreamState != nil && upstreamState.CircuitBreaker != nil {
+			failureRates[upstreamId] = upstreamState.CircuitBreaker.FailureRate(method)
+		}
+	}
+	slices.SortStableFunc(ejected, func(a, b string) int {
+		return cmp.Compare(failureRates[a], failureRates[b])
+	})
+	return ejected
+}
+
+func unselectUpstream(mu *sync.Mutex, selectedUpstreams mapset.Set[string], upstreamId string) {
+	mu.Lock()
+	defer mu.Unlock()
+	selectedUpstreams.Remove(upstreamId)
+}
+
 func processMatchedResponse(
 	mu *sync.Mutex,
 	matched MatchResponse,
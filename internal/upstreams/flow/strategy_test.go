@@ -5,9 +5,12 @@ import (
 	"time"
 
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/breaker"
 	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/cost"
 	"github.com/drpcorg/nodecore/internal/dimensions"
 	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/ratelimiter"
 	"github.com/drpcorg/nodecore/internal/rating"
 	"github.com/drpcorg/nodecore/internal/upstreams"
 	"github.com/drpcorg/nodecore/internal/upstreams/flow"
@@ -254,6 +257,138 @@ func TestGenericStrategyWithWsCap(t *testing.T) {
 	assert.Equal(t, "id1", upId)
 }
 
+func TestGenericStrategySkipsUpstreamWithOpenCircuit(t *testing.T) {
+	chSup := test_utils.CreateChainSupervisor()
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("eth_getBalance"))
+	methodsMock.On("HasMethod", "eth_getBalance").Return(true)
+	circuitBreaker := breaker.NewCircuitBreaker(chains.ARBITRUM, "id1", &config.CircuitBreakerConfig{
+		FailureThreshold:     1,
+		FailureRateThreshold: 1,
+		MinimumRequests:      1,
+		Window:               time.Minute,
+		OpenDuration:         time.Minute,
+		HalfOpenSuccesses:    1,
+	}, nil)
+	circuitBreaker.Record("eth_getBalance", true)
+	state := protocol.DefaultUpstreamState(methodsMock, mapset.NewThreadUnsafeSet[protocol.Cap](), "index", nil, nil)
+	state.CircuitBreaker = circuitBreaker
+	chSup.PublishUpstreamEvent(protocol.UpstreamEvent{Id: "id1", EventType: &protocol.StateUpstreamEvent{State: &state}})
+	test_utils.PublishEvent(chSup, "id2", protocol.Available, mapset.NewThreadUnsafeSet[protocol.Cap]())
+	request, _ := protocol.NewInternalUpstreamJsonRpcRequest("eth_getBalance", nil, chains.ARBITRUM)
+	genericStrategy := flow.NewGenericStrategy(chSup)
+
+	upId, err := genericStrategy.SelectUpstream(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "id2", upId)
+
+	// id2 is already taken, so the ejected id1 is the only one left to serve the request
+	upId, err = genericStrategy.SelectUpstream(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "id1", upId)
+
+	_, err = genericStrategy.SelectUpstream(request)
+	assert.Equal(t, protocol.NoAvailableUpstreamsError(), err)
+}
+
+func TestGenericStrategyAllCircuitsOpenThenLeastBadUpstream(t *testing.T) {
+	chSup := test_utils.CreateChainSupervisor()
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("eth_getBalance"))
+	methodsMock.On("HasMethod", "eth_getBalance").Return(true)
+	circuitBreakerConfig := &config.CircuitBreakerConfig{
+		FailureThreshold:     1,
+		FailureRateThreshold: 0.5,
+		MinimumRequests:      4,
+		Window:               time.Minute,
+		OpenDuration:         time.Minute,
+		HalfOpenSuccesses:    1,
+	}
+	for upstreamId, failures := range map[string]int{"id1": 4, "id2": 2} {
+		circuitBreaker := breaker.NewCircuitBreaker(chains.ARBITRUM, upstreamId, circuitBreakerConfig, nil)
+		for i := 0; i < 4; i++ {
+			circuitBreaker.Record("eth_getBalance", i < failures)
+		}
+		state := protocol.DefaultUpstreamState(methodsMock, mapset.NewThreadUnsafeSet[protocol.Cap](), "index", nil, nil)
+		state.CircuitBreaker = circuitBreaker
+		chSup.PublishUpstreamEvent(protocol.UpstreamEvent{Id: upstreamId, EventType: &protocol.StateUpstreamEvent{State: &state}})
+	}
+	time.Sleep(10 * time.Millisecond)
+	request, _ := protocol.NewInternalUpstreamJsonRpcRequest("eth_getBalance", nil, chains.ARBITRUM)
+	genericStrategy := flow.NewGenericStrategy(chSup)
+
+	upId, err := genericStrategy.SelectUpstream(request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "id2", upId)
+}
+
+func TestGenericStrategySkipsUpstreamAtConcurrencyLimit(t *testing.T) {
+	chSup := test_utils.CreateChainSupervisor()
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("eth_getBalance"))
+	methodsMock.On("HasMethod", "eth_getBalance").Return(true)
+	concurrencyLimiter := ratelimiter.NewConcurrencyLimiter(chains.ARBITRUM, "id1", &config.ConcurrencyLimitConfig{
+		Enabled:      true,
+		Algorithm:    config.AimdConcurrencyLimit,
+		InitialLimit: 1,
+		MinLimit:     1,
+		MaxLimit:     10,
+		Window:       time.Second,
+		Tolerance:    1.5,
+		BackoffRatio: 0.9,
+	})
+	concurrencyLimiter.RequestStarted()
+	state := protocol.DefaultUpstreamState(methodsMock, mapset.NewThreadUnsafeSet[protocol.Cap](), "index", nil, nil)
+	state.ConcurrencyLimiter = concurrencyLimiter
+	chSup.PublishUpstreamEvent(protocol.UpstreamEvent{Id: "id1", EventType: &protocol.StateUpstreamEvent{State: &state}})
+	test_utils.PublishEvent(chSup, "id2", protocol.Available, mapset.NewThreadUnsafeSet[protocol.Cap]())
+	request, _ := protocol.NewInternalUpstreamJsonRpcRequest("eth_getBalance", nil, chains.ARBITRUM)
+	genericStrategy := flow.NewGenericStrategy(chSup)
+
+	upId, err := genericStrategy.SelectUpstream(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "id2", upId)
+
+	_, err = genericStrategy.SelectUpstream(request)
+	assert.Equal(t, protocol.NoAvailableUpstreamsError(), err)
+}
+
+func TestGenericStrategyConcurrencyLimitThenPlaceHeldBySelectedUpstreamOnly(t *testing.T) {
+	chSup := test_utils.CreateChainSupervisor()
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("eth_getBalance"))
+	methodsMock.On("HasMethod", "eth_getBalance").Return(true)
+	concurrencyLimiter := ratelimiter.NewConcurrencyLimiter(chains.ARBITRUM, "id1", &config.ConcurrencyLimitConfig{
+		Enabled:      true,
+		Algorithm:    config.AimdConcurrencyLimit,
+		InitialLimit: 2,
+		MinLimit:     1,
+		MaxLimit:     10,
+		Window:       time.Second,
+		Tolerance:    1.5,
+		BackoffRatio: 0.9,
+	})
+	state := protocol.DefaultUpstreamState(methodsMock, mapset.NewThreadUnsafeSet[protocol.Cap](), "index", nil, nil)
+	state.ConcurrencyLimiter = concurrencyLimiter
+	chSup.PublishUpstreamEvent(protocol.UpstreamEvent{Id: "id1", EventType: &protocol.StateUpstreamEvent{State: &state}})
+	test_utils.PublishEvent(chSup, "id2", protocol.Available, mapset.NewThreadUnsafeSet[protocol.Cap]())
+	time.Sleep(10 * time.Millisecond)
+	request, _ := protocol.NewInternalUpstreamJsonRpcRequest("eth_getBalance", nil, chains.ARBITRUM)
+	strategy := flow.NewSpecificOrderUpstreamStrategy([]string{"id1", "id2"}, chSup)
+
+	upId, err := strategy.SelectUpstream(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "id1", upId)
+	assert.Equal(t, int64(1), concurrencyLimiter.GetInFlight())
+
+	// id1 matches again but it's already selected, so the place it takes is given back
+	upId, err = strategy.SelectUpstream(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "id2", upId)
+	assert.Equal(t, int64(1), concurrencyLimiter.GetInFlight())
+}
+
 func TestGenericStrategyGetUpstreams(t *testing.T) {
 	chSup := test_utils.CreateChainSupervisor()
 	test_utils.PublishEvent(chSup, "id1", protocol.Available, mapset.NewThreadUnsafeSet[protocol.Cap]())
@@ -426,3 +561,88 @@ func TestSelectorFailureDoesNotMaskMethodErrorPriority(t *testing.T) {
 
 	assert.Equal(t, protocol.NotSupportedMethodError("eth_call"), err)
 }
+
+func TestP2CStrategyPicksLessLoadedUpstream(t *testing.T) {
+	chSup := test_utils.CreateChainSupervisor()
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("eth_getBalance"))
+	methodsMock.On("HasMethod", "eth_getBalance").Return(true)
+	for upId, latency := range map[string]time.Duration{"id1": time.Second, "id2": 10 * time.Millisecond} {
+		state := protocol.DefaultUpstreamState(methodsMock, mapset.NewThreadUnsafeSet[protocol.Cap](), "index", nil, nil)
+		state.Load.ObserveLatency(latency)
+		chSup.PublishUpstreamEvent(protocol.UpstreamEvent{Id: upId, EventType: &protocol.StateUpstreamEvent{State: &state}})
+	}
+	time.Sleep(10 * time.Millisecond)
+	request, _ := protocol.NewInternalUpstreamJsonRpcRequest("eth_getBalance", nil, chains.ARBITRUM)
+	p2cStrategy := flow.NewP2CStrategy(chSup, nil)
+
+	upId, err := p2cStrategy.SelectUpstream(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "id2", upId)
+
+	upId, err = p2cStrategy.SelectUpstream(request)
+	assert.Nil(t, err)
+	assert.Equal(t, "id1", upId)
+
+	_, err = p2cStrategy.SelectUpstream(request)
+	assert.Equal(t, protocol.NoAvailableUpstreamsError(), err)
+}
+
+func TestP2CStrategyPicksUpstreamWithFewerRequestsInFlight(t *testing.T) {
+	chSup := test_utils.CreateChainSupervisor()
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("eth_getBalance"))
+	methodsMock.On("HasMethod", "eth_getBalance").Return(true)
+	for upId, inFlight := range map[string]int{"id1": 3, "id2": 0} {
+		concurrencyLimiter := ratelimiter.NewConcurrencyLimiter(chains.ARBITRUM, upId, &config.ConcurrencyLimitConfig{
+			Enabled:      true,
+			Algorithm:    config.AimdConcurrencyLimit,
+			InitialLimit: 10,
+			MinLimit:     1,
+			MaxLimit:     10,
+			Window:       time.Second,
+			Tolerance:    1.5,
+			BackoffRatio: 0.9,
+		})
+		for range inFlight {
+			concurrencyLimiter.RequestStarted()
+		}
+		state := protocol.DefaultUpstreamState(methodsMock, mapset.NewThreadUnsafeSet[protocol.Cap](), "index", nil, nil)
+		state.Load.ObserveLatency(100 * time.Millisecond)
+		state.ConcurrencyLimiter = concurrencyLimiter
+		chSup.PublishUpstreamEvent(protocol.UpstreamEvent{Id: upId, EventType: &protocol.StateUpstreamEvent{State: &state}})
+	}
+	time.Sleep(10 * time.Millisecond)
+	request, _ := protocol.NewInternalUpstreamJsonRpcRequest("eth_getBalance", nil, chains.ARBITRUM)
+	p2cStrategy := flow.NewP2CStrategy(chSup, nil)
+
+	upId, err := p2cStrategy.SelectUpstream(request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "id2", upId)
+}
+
+func TestRatingStrategyCheaperUpstreamFirstOnTies(t *testing.T) {
+	chSup := test_utils.CreateChainSupervisor()
+	methodsMock := mocks.NewMethodsMock()
+	methodsMock.On("GetSupportedMethods").Return(mapset.NewThreadUnsafeSet[string]("eth_getBalance"))
+	methodsMock.On("HasMethod", "eth_getBalance").Return(true)
+	for upId, price := range map[string]float64{"id1": 0.01, "id2": 0.001, "id3": 0.005} {
+		state := protocol.DefaultUpstreamState(methodsMock, mapset.NewThreadUnsafeSet[protocol.Cap](), "index", nil, nil)
+		state.Cost = cost.NewUpstreamCost(chains.ARBITRUM, upId, &config.CostConfig{PricePerRequest: price, ComputeUnits: 1})
+		chSup.PublishUpstreamEvent(protocol.UpstreamEvent{Id: upId, EventType: &protocol.StateUpstreamEvent{State: &state}})
+	}
+	time.Sleep(10 * time.Millisecond)
+	upSupervisor := mocks.NewUpstreamSupervisorMock()
+	upSupervisor.On("GetChainSupervisor", chains.ARBITRUM).Return(chSup)
+	// the rating isn't calculated, so all upstreams are tied
+	ratingRegistry := rating.NewRatingRegistry(upSupervisor, nil, &config.ScorePolicyConfig{CalculationFunctionName: config.DefaultLatencyPolicyFuncName, CalculationInterval: 1 * time.Minute})
+	request, _ := protocol.NewInternalUpstreamJsonRpcRequest("eth_getBalance", nil, chains.ARBITRUM)
+	ratingStrategy := flow.NewRatingStrategy(chains.ARBITRUM, "eth_getBalance", nil, chSup, ratingRegistry)
+
+	for _, expected := range []string{"id2", "id3", "id1"} {
+		upId, err := ratingStrategy.SelectUpstream(request)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, upId)
+	}
+}
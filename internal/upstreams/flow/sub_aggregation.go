@@ -227,8 +227,11 @@ func newGenericSourceBuilder(
 		}
 		upstream := supervisor.GetUpstream(upstreamId)
 		if upstream == nil {
+			releaseUpstreams(supervisor, upstreamId)
 			return nil, protocol.NoAvailableUpstreamsError()
 		}
+		// a subscription isn't a request in flight, the place taken by the selection is given back at once
+		upstream.GetUpstreamState().ConcurrencyLimiter.RequestFinished()
 		wsConn := getMethodConnector(upstream, request.SpecMethod())
 		if wsConn == nil {
 			return nil, protocol.NoApiConnectorsError(request.Method())
@@ -380,3 +380,17 @@ func TestSubscriptionKeyDependsOnMethodParamsAndSelectors(t *testing.T) {
 	// same method+params (different client id) collapse onto the same source
 	assert.Equal(t, subscriptionKey(newHeads), subscriptionKey(newHeadsAgain))
 }
+
+func TestSubscriptionKeyOfSemanticallyIdenticalParams(t *testing.T) {
+	assert.NoError(t, specs.NewMethodSpecLoader().Load())
+	logs := protocol.NewUpstreamJsonRpcRequest("1", protocol.JsonRpcRequestBody{
+		Method: "eth_subscribe",
+		Params: []byte(`["logs",{"topics":["0xDDF252AD1BE2C89B69C2B068FC378DAA952BA7F163C4A11628F55A4DF523B3EF"],"address":"0xA0B86991C6218B36C1D19D4A2E9EB0CE3606EB48"}]`),
+	}, true, "eth")
+	logsAgain := protocol.NewUpstreamJsonRpcRequest("2", protocol.JsonRpcRequestBody{
+		Method: "eth_subscribe",
+		Params: []byte(`["logs", {"address": "0xa0b86991c6218b36c1d19d4a2e9eb0ce3606eb48", "topics": ["0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"]}]`),
+	}, true, "eth")
+
+	assert.Equal(t, subscriptionKey(logs), subscriptionKey(logsAgain))
+}
@@ -0,0 +1,121 @@
+package flow
+
+import (
+	"math"
+	"math/rand/v2"
+	"slices"
+	"sync"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/rating"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/chains"
+)
+
+// minRatingWeight keeps an upstream with a non-positive rating score selectable,
+// though only after the upstreams with positive scores
+const minRatingWeight = 1e-9
+
+// WeightedStrategy picks upstreams at random in proportion to their weights, so the traffic
+// is spread across upstreams instead of going to the single best one.
+// The order is drawn once per request, further selections of the request (retries, hedges)
+// go down the same order
+type WeightedStrategy struct {
+	chainSupervisor    upstreams.ChainSupervisor
+	selectedUpstreams  mapset.Set[string]
+	ups                []string
+	additionalMatchers []Matcher
+	order              UpstreamOrder
+	mu                 sync.Mutex
+}
+
+// NewWeightedRatingStrategy weighs upstreams by the rating scores of the method,
+// the upstreams are equally weighted until the scores are calculated
+func NewWeightedRatingStrategy(
+	chain chains.Chain,
+	method string,
+	additionalMatchers []Matcher,
+	chainSupervisor upstreams.ChainSupervisor,
+	registry *rating.RatingRegistry,
+) *WeightedStrategy {
+	ups := registry.GetSortedUpstreams(chain, method)
+	scores := registry.GetScores(chain, method)
+	return newWeightedStrategy(ups, additionalMatchers, chainSupervisor, func(upstreamId string) float64 {
+		if scores == nil {
+			return 1
+		}
+		return max(scores[upstreamId], minRatingWeight)
+	})
+}
+
+// NewStaticWeightedStrategy weighs upstreams by their configured weights
+func NewStaticWeightedStrategy(
+	additionalMatchers []Matcher,
+	chainSupervisor upstreams.ChainSupervisor,
+	upstreamSupervisor upstreams.UpstreamSupervisor,
+) *WeightedStrategy {
+	return newWeightedStrategy(chainSupervisor.GetUpstreamIds(), additionalMatchers, chainSupervisor, func(upstreamId string) float64 {
+		up := upstreamSupervisor.GetUpstream(upstreamId)
+		if up == nil {
+			return 1
+		}
+		return float64(up.GetWeight())
+	})
+}
+
+func newWeightedStrategy(
+	upstreamIds []string,
+	additionalMatchers []Matcher,
+	chainSupervisor upstreams.ChainSupervisor,
+	weight func(upstreamId string) float64,
+) *WeightedStrategy {
+	return &WeightedStrategy{
+		chainSupervisor:    chainSupervisor,
+		ups:                weightedOrder(upstreamIds, weight),
+		additionalMatchers: additionalMatchers,
+		selectedUpstreams:  mapset.NewThreadUnsafeSet[string](),
+	}
+}
+
+func (w *WeightedStrategy) WithOrder(order UpstreamOrder) *WeightedStrategy {
+	w.order = order
+	return w
+}
+
+func (w *WeightedStrategy) SelectUpstream(request protocol.RequestHolder) (string, error) {
+	if len(w.ups) == 0 {
+		return "", protocol.NoAvailableUpstreamsError()
+	}
+
+	selectedUpstream, currentReason, trace := filterUpstreams(&w.mu, request, w.ups, w.chainSupervisor, w.selectedUpstreams, w.additionalMatchers, w.order)
+	if selectedUpstream != "" {
+		return selectedUpstream, nil
+	}
+
+	return "", selectionError(currentReason, trace)
+}
+
+var _ UpstreamStrategy = (*WeightedStrategy)(nil)
+
+// weightedOrder is a weighted random permutation of upstreams (Efraimidis-Spirakis sampling),
+// the first one is picked with the probability of its share of the total weight, the next one
+// with its share of the rest and so on
+func weightedOrder(upstreamIds []string, weight func(upstreamId string) float64) []string {
+	keys := make(map[string]float64, len(upstreamIds))
+	for _, upstreamId := range upstreamIds {
+		keys[upstreamId] = math.Pow(rand.Float64(), 1/weight(upstreamId))
+	}
+	ordered := slices.Clone(upstreamIds)
+	slices.SortStableFunc(ordered, func(a, b string) int {
+		switch {
+		case keys[a] > keys[b]:
+			return -1
+		case keys[a] < keys[b]:
+			return 1
+		default:
+			return 0
+		}
+	})
+	return ordered
+}
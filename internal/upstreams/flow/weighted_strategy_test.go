@@ -0,0 +1,37 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightedOrderFollowsWeights(t *testing.T) {
+	weights := map[string]float64{"id1": 9, "id2": 1}
+	firsts := map[string]int{}
+
+	for i := 0; i < 10000; i++ {
+		ordered := weightedOrder([]string{"id1", "id2"}, func(upstreamId string) float64 { return weights[upstreamId] })
+		assert.ElementsMatch(t, []string{"id1", "id2"}, ordered)
+		firsts[ordered[0]]++
+	}
+
+	assert.InDelta(t, 0.9, float64(firsts["id1"])/10000, 0.03)
+}
+
+func TestWeightedOrderMinRatingWeightIsLast(t *testing.T) {
+	weights := map[string]float64{"id1": minRatingWeight, "id2": 0.1, "id3": 0.2}
+
+	for i := 0; i < 100; i++ {
+		ordered := weightedOrder([]string{"id1", "id2", "id3"}, func(upstreamId string) float64 { return weights[upstreamId] })
+		assert.Equal(t, "id1", ordered[2])
+	}
+}
+
+func TestWeightedOrderDoesNotChangeUpstreams(t *testing.T) {
+	upstreamIds := []string{"id1", "id2", "id3"}
+
+	weightedOrder(upstreamIds, func(string) float64 { return 1 })
+
+	assert.Equal(t, []string{"id1", "id2", "id3"}, upstreamIds)
+}
@@ -2,6 +2,7 @@ package upstreams
 
 import (
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/upstreams/connectors"
 	"github.com/drpcorg/nodecore/pkg/chains"
@@ -44,8 +45,12 @@ type UpstreamSupervisor interface {
 	GetChainSupervisor(chain chains.Chain) ChainSupervisor
 	GetChainSupervisors() []ChainSupervisor
 	GetUpstream(string) Upstream
-	GetExecutor() failsafe.Executor[*protocol.ResponseHolderWrapper]
+	GetUpstreams() []Upstream
+	GetExecutor(chain chains.Chain, method string) failsafe.Executor[*protocol.ResponseHolderWrapper]
 	StartUpstreams()
+	UpdateUpstreams(upstreamsConfig *config.UpstreamConfig, changedBudgets mapset.Set[string])
+	AddUpstream(upConfig *config.Upstream) error
+	RemoveUpstream(upstreamId string) error
 
 	SubscribeChainSupervisor(name string) *utils.Subscription[ChainSupervisorEvent]
 }
@@ -62,6 +67,7 @@ type Upstream interface {
 	GetId() string
 	GetChain() chains.Chain
 	GetGroupLabels() mapset.Set[string]
+	GetWeight() int
 	GetVendorType() UpstreamVendor
 	GetUpstreamState() protocol.UpstreamState
 	GetConnector(connectorType specs.ApiConnectorType) connectors.ApiConnector
@@ -74,4 +80,7 @@ type Upstream interface {
 	UpdateBlock(block protocol.Block, blockType protocol.BlockType)
 	UpdateLowerBound(data protocol.LowerBoundData)
 	BanMethod(method string)
+	UnbanMethod(method string)
+	SetMaintenance(enabled bool)
+	InMaintenance() bool
 }
@@ -3,6 +3,7 @@ package methods
 import (
 	"fmt"
 	"maps"
+	"slices"
 
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/drpcorg/nodecore/internal/config"
@@ -29,7 +30,7 @@ func NewUpstreamMethods(
 	if methodsConfig == nil {
 		methodsConfig = &config.MethodsConfig{}
 	}
-	specMethods := specs.GetSpecMethodsByConnectors(methodSpecName, apiConnectorTypes)
+	specMethods := specs.GetSpecMethodsByConnectors(methodSpecName, expandGrpcConnector(methodSpecName, apiConnectorTypes))
 	if specMethods == nil {
 		return nil, fmt.Errorf("no method spec with name '%s'", methodSpecName)
 	}
@@ -72,6 +73,15 @@ func NewUpstreamMethods(
 	}, nil
 }
 
+// expandGrpcConnector makes a grpc connector serve the methods of every connector
+// of the spec: the remote dshackle-compatible instance proxies the whole chain API.
+func expandGrpcConnector(methodSpecName string, apiConnectorTypes []specs.ApiConnectorType) []specs.ApiConnectorType {
+	if !slices.Contains(apiConnectorTypes, specs.GrpcConnector) {
+		return apiConnectorTypes
+	}
+	return lo.Uniq(append(slices.Clone(apiConnectorTypes), specs.GetSpecConnectors(methodSpecName)...))
+}
+
 func (u *UpstreamMethods) GetSupportedMethods() mapset.Set[string] {
 	return u.methodNames.Clone()
 }
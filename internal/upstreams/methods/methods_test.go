@@ -29,6 +29,17 @@ func TestUpstreamMethodsOnlyFromSpec(t *testing.T) {
 	checkMethods(t, expected, upstreamMethods)
 }
 
+func TestUpstreamMethodsGrpcConnectorServesSpecConnectors(t *testing.T) {
+	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("full")).Load()
+	assert.NoError(t, err)
+
+	upstreamMethods, err := methods.NewUpstreamMethods("test", &config.MethodsConfig{}, []specs.ApiConnectorType{specs.GrpcConnector})
+	assert.NoError(t, err)
+
+	expected := mapset.NewThreadUnsafeSet[string]("test", "test_another", "test2")
+	checkMethods(t, expected, upstreamMethods)
+}
+
 func TestUpstreamMethodsAndEnabledMethodInConfig(t *testing.T) {
 	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("full")).Load()
 	assert.NoError(t, err)
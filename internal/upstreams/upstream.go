@@ -7,8 +7,12 @@ import (
 	"sync/atomic"
 
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/breaker"
 	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/cost"
+	"github.com/drpcorg/nodecore/internal/dimensions"
 	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/ratelimiter"
 	"github.com/drpcorg/nodecore/internal/upstreams/connectors"
 	"github.com/drpcorg/nodecore/internal/upstreams/event_processors"
 	"github.com/drpcorg/nodecore/internal/upstreams/methods"
@@ -46,7 +50,8 @@ type GenericUpstream struct {
 	upstreamCtx      *upstreamCtx
 	emitter          event_processors.Emitter
 
-	headLag atomic.Int64
+	headLag     atomic.Int64
+	maintenance atomic.Bool
 
 	processorAggregator *event_processors.UpstreamProcessorAggregator
 }
@@ -86,11 +91,27 @@ func NewGenericUpstream(
 	for label, value := range conf.Labels {
 		initialState.Labels.AddLabel(label, value)
 	}
-	upState.Store(initialState)
 	stateChan := make(chan protocol.AbstractUpstreamStateEvent, 1000)
 	emitter := func(event protocol.AbstractUpstreamStateEvent) {
 		stateChan <- event
 	}
+	if conf.FailsafeConfig != nil && conf.FailsafeConfig.CircuitBreakerConfig != nil {
+		circuitBreakerConfig := conf.FailsafeConfig.CircuitBreakerConfig
+		initialState.CircuitBreaker = breaker.NewCircuitBreaker(configuredChain.Chain, conf.Id, circuitBreakerConfig, func(method string, state breaker.State) {
+			emitter(&protocol.CircuitBreakerUpstreamStateEvent{Method: method, State: state})
+		})
+		if creationData.tracker != nil {
+			removeListener := creationData.tracker.AddUpstreamListener(configuredChain.Chain, conf.Id, circuitBreakerListener(initialState.CircuitBreaker))
+			context.AfterFunc(ctx, removeListener)
+		}
+	}
+	if conf.Cost != nil {
+		initialState.Cost = cost.NewUpstreamCost(configuredChain.Chain, conf.Id, conf.Cost)
+	}
+	if conf.ConcurrencyLimit != nil && conf.ConcurrencyLimit.Enabled {
+		initialState.ConcurrencyLimiter = ratelimiter.NewConcurrencyLimiter(configuredChain.Chain, conf.Id, conf.ConcurrencyLimit)
+	}
+	upState.Store(initialState)
 
 	mainLifecycle := utils.NewGenericLifecycle(fmt.Sprintf("%s_main_upstream", conf.Id), ctx)
 	upstream := &GenericUpstream{
@@ -131,6 +152,18 @@ func NewGenericUpstream(
 	return upstream, nil
 }
 
+// circuitBreakerListener feeds the circuit breaker with the results of client requests the dimension tracker tracks,
+// a failure is the same retryable error the tracker counts as an upstream error
+func circuitBreakerListener(circuitBreaker *breaker.CircuitBreaker) dimensions.UpstreamListener {
+	return func(method string, result *protocol.UnaryRequestResult) {
+		if result.GetRespKind() == protocol.Cancelled {
+			circuitBreaker.RecordCancelled(method)
+			return
+		}
+		circuitBreaker.Record(method, result.GetRespKind() == protocol.RetryableError)
+	}
+}
+
 func NewGenericUpstreamWithParams(
 	id string,
 	chain chains.Chain,
@@ -209,6 +242,14 @@ func (u *GenericUpstream) GetGroupLabels() mapset.Set[string] {
 	return u.groupLabels
 }
 
+// GetWeight is the configured share of requests of the upstream, 1 if it isn't configured
+func (u *GenericUpstream) GetWeight() int {
+	if u.upConfig == nil || u.upConfig.Weight < 1 {
+		return 1
+	}
+	return u.upConfig.Weight
+}
+
 func (u *GenericUpstream) Start() {
 	u.upstreamCtx.mainLifecycle.Start(func(ctx context.Context) error {
 		u.startConnectors(ctx)
@@ -311,10 +352,29 @@ func (u *GenericUpstream) BanMethod(method string) {
 	u.emitter(&protocol.BanMethodUpstreamStateEvent{Method: method})
 }
 
+func (u *GenericUpstream) UnbanMethod(method string) {
+	u.emitter(&protocol.UnbanMethodUpstreamStateEvent{Method: method})
+}
+
+func (u *GenericUpstream) SetMaintenance(enabled bool) {
+	u.maintenance.Store(enabled)
+	u.emitter(&protocol.MaintenanceUpstreamStateEvent{Enabled: enabled})
+}
+
+func (u *GenericUpstream) InMaintenance() bool {
+	return u.maintenance.Load()
+}
+
 func (u *GenericUpstream) GetConnector(connectorType specs.ApiConnectorType) connectors.ApiConnector {
-	connector, _ := lo.Find(u.apiConnectors, func(item connectors.ApiConnector) bool {
+	connector, ok := lo.Find(u.apiConnectors, func(item connectors.ApiConnector) bool {
 		return item.GetType() == connectorType
 	})
+	if !ok {
+		// a grpc connector proxies the whole chain API, so it stands in for any missing type
+		connector, _ = lo.Find(u.apiConnectors, func(item connectors.ApiConnector) bool {
+			return item.GetType() == specs.GrpcConnector
+		})
+	}
 	return connector
 }
 
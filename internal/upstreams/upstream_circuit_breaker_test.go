@@ -0,0 +1,61 @@
+package upstreams
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/drpcorg/nodecore/internal/breaker"
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/dimensions"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewGenericUpstreamCircuitBreakerFedByTracker(t *testing.T) {
+	tracker := dimensions.NewGenericDimensionTracker()
+	upstream := newStubbedGenericUpstream(t, nil, true, &config.FailsafeConfig{
+		CircuitBreakerConfig: &config.CircuitBreakerConfig{
+			FailureThreshold:  2,
+			MinimumRequests:   1,
+			Window:            time.Minute,
+			OpenDuration:      time.Minute,
+			HalfOpenSuccesses: 1,
+		},
+	}, tracker)
+	circuitBreaker := upstream.GetUpstreamState().CircuitBreaker
+	require.NotNil(t, circuitBreaker)
+	request := protocol.NewUpstreamJsonRpcRequest("1", protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}, false, "")
+	failed := protocol.NewUnaryRequestResult().WithRespKindFromResponse(protocol.NewPartialFailure(request, protocol.ServerError()))
+	cancelled := protocol.NewUnaryRequestResult().WithRespKindFromResponse(protocol.NewTotalFailure(request, protocol.CtxError(context.Canceled)))
+
+	tracker.NotifyUpstreamListener(chains.ETHEREUM, "u1", "eth_call", failed)
+	tracker.NotifyUpstreamListener(chains.ETHEREUM, "u1", "eth_call", cancelled)
+	assert.Equal(t, breaker.Closed, circuitBreaker.State("eth_call"))
+
+	tracker.NotifyUpstreamListener(chains.ETHEREUM, "u1", "eth_call", failed)
+	assert.Equal(t, breaker.Open, circuitBreaker.State("eth_call"))
+}
+
+func TestGenericUpstreamStopThenCircuitBreakerListenerRemoved(t *testing.T) {
+	tracker := dimensions.NewGenericDimensionTracker()
+	upstream := newStubbedGenericUpstream(t, nil, true, &config.FailsafeConfig{
+		CircuitBreakerConfig: &config.CircuitBreakerConfig{
+			FailureThreshold:  1,
+			MinimumRequests:   1,
+			Window:            time.Minute,
+			OpenDuration:      time.Minute,
+			HalfOpenSuccesses: 1,
+		},
+	}, tracker)
+
+	request := protocol.NewUpstreamJsonRpcRequest("1", protocol.JsonRpcRequestBody{Id: []byte(`1`), Method: "eth_call"}, false, "")
+
+	upstream.upstreamCtx.cancelFunc()
+	time.Sleep(10 * time.Millisecond)
+	tracker.NotifyUpstreamListener(chains.ETHEREUM, "u1", "eth_call", protocol.NewUnaryRequestResult().WithRespKindFromResponse(protocol.NewPartialFailure(request, protocol.ServerError())))
+
+	assert.Equal(t, breaker.Closed, upstream.GetUpstreamState().CircuitBreaker.State("eth_call"))
+}
@@ -24,6 +24,9 @@ func (u *GenericUpstream) processStateEvents(ctx context.Context, initialValid b
 		return methods.IsForceEnabled(u.upConfig.Methods, specs.GetSpecMethodWithFallback(methodSpecName, method))
 	}
 	validUpstream := initialValid
+	// maintenance keeps the upstream out of its chain the same way as invalid settings do,
+	// but its state is still tracked so that it's up to date once the maintenance is over
+	maintenance := false
 	// baseAvail is the availability reported by health probes (setStatus),
 	// tracked here rather than in the shared UpstreamState because only the
 	// derived effective availability is of interest to consumers. It is combined
@@ -47,7 +50,9 @@ func (u *GenericUpstream) processStateEvents(ctx context.Context, initialValid b
 				log.Warn().Msgf("upstream '%s' settings are invalid, it will be stopped", u.id)
 				eventType = &protocol.RemoveUpstreamEvent{}
 				validUpstream = false
-				u.publishUpstreamEvent(state, eventType)
+				if !maintenance {
+					u.publishUpstreamEvent(state, eventType)
+				}
 			case *protocol.ValidUpstreamStateEvent:
 				if validUpstream {
 					continue
@@ -55,6 +60,22 @@ func (u *GenericUpstream) processStateEvents(ctx context.Context, initialValid b
 				log.Warn().Msgf("upstream '%s' settings are valid", u.id)
 				eventType = &protocol.ValidUpstreamEvent{State: &state}
 				validUpstream = true
+			case *protocol.MaintenanceUpstreamStateEvent:
+				if stateEvent.Enabled == maintenance {
+					continue
+				}
+				maintenance = stateEvent.Enabled
+				if !validUpstream {
+					// an invalid upstream is out of its chain anyway
+					continue
+				}
+				if maintenance {
+					log.Warn().Msgf("upstream '%s' is in maintenance, it will be removed from its chain", u.id)
+					u.publishUpstreamEvent(state, &protocol.RemoveUpstreamEvent{})
+					continue
+				}
+				log.Warn().Msgf("upstream '%s' is out of maintenance", u.id)
+				eventType = &protocol.ValidUpstreamEvent{State: &state}
 			case *protocol.BanMethodUpstreamStateEvent:
 				// A ban the config enables away is not worth recording: it would leave the
 				// method enabled, fire a pointless unban later, and re-arm on the next
@@ -68,6 +89,7 @@ func (u *GenericUpstream) processStateEvents(ctx context.Context, initialValid b
 				})
 				log.Warn().Msgf("the method %s has been banned on upstream %s", stateEvent.Method, u.id)
 				bannedMethods.Add(stateEvent.Method)
+				state.BannedMethods = bannedMethods.Clone()
 				state.UpstreamMethods = u.newUpstreamMethods(bannedMethods, unsupportedMethods)
 			case *protocol.UnbanMethodUpstreamStateEvent:
 				if !bannedMethods.ContainsOne(stateEvent.Method) {
@@ -75,6 +97,7 @@ func (u *GenericUpstream) processStateEvents(ctx context.Context, initialValid b
 				}
 				log.Warn().Msgf("the method %s has been unbanned on upstream %s", stateEvent.Method, u.id)
 				bannedMethods.Remove(stateEvent.Method)
+				state.BannedMethods = bannedMethods.Clone()
 				state.UpstreamMethods = u.newUpstreamMethods(bannedMethods, unsupportedMethods)
 			case *protocol.UnsupportedMethodsUpstreamStateEvent:
 				if unsupportedMethods.Equal(stateEvent.Methods) {
@@ -100,7 +123,7 @@ func (u *GenericUpstream) processStateEvents(ctx context.Context, initialValid b
 				newAvail := protocol.StatusByLag(u.headLag.Load(), baseAvail, u.configuredChain.Settings.Lags.Syncing)
 				if newAvail != state.Status {
 					state.Status = newAvail
-					u.publishUpstreamEvent(state, eventType)
+					u.applyState(state, eventType, maintenance)
 				}
 				continue
 			case *protocol.HeadUpstreamStateEvent:
@@ -114,7 +137,7 @@ func (u *GenericUpstream) processStateEvents(ctx context.Context, initialValid b
 			}
 
 			if validUpstream {
-				u.publishUpstreamEvent(state, eventType)
+				u.applyState(state, eventType, maintenance)
 			}
 		}
 	}
@@ -128,6 +151,16 @@ func (u *GenericUpstream) createUpstreamEvent(eventType protocol.UpstreamEventTy
 	}
 }
 
+// applyState publishes the new state, an upstream in maintenance only keeps it
+// since it mustn't get back to its chain
+func (u *GenericUpstream) applyState(state protocol.UpstreamState, eventType protocol.UpstreamEventType, maintenance bool) {
+	if maintenance {
+		u.upstreamState.Store(state)
+		return
+	}
+	u.publishUpstreamEvent(state, eventType)
+}
+
 func (u *GenericUpstream) publishUpstreamEvent(state protocol.UpstreamState, eventType protocol.UpstreamEventType) {
 	u.upstreamState.Store(state)
 	upstreamEvent := u.createUpstreamEvent(eventType)
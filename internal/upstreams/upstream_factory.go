@@ -26,6 +26,7 @@ import (
 	"github.com/drpcorg/nodecore/internal/dimensions"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/ratelimiter"
+	"github.com/drpcorg/nodecore/internal/resilience"
 	"github.com/drpcorg/nodecore/internal/upstreams/blocks"
 	"github.com/drpcorg/nodecore/internal/upstreams/connectors"
 	"github.com/drpcorg/nodecore/internal/upstreams/labels"
@@ -34,7 +35,6 @@ import (
 	"github.com/drpcorg/nodecore/internal/upstreams/validations"
 	"github.com/drpcorg/nodecore/internal/upstreams/ws"
 	"github.com/drpcorg/nodecore/pkg/chains"
-	"github.com/failsafe-go/failsafe-go"
 	"github.com/rs/zerolog/log"
 	"github.com/samber/lo"
 )
@@ -48,6 +48,7 @@ type upstreamCreationData struct {
 	upstreamMethods        *methods.UpstreamMethods
 	rt                     *ratelimiter.RateLimitBudget
 	autoTune               *ratelimiter.UpstreamAutoTune
+	tracker                dimensions.DimensionTracker
 }
 
 func CreateUpstream(
@@ -55,7 +56,7 @@ func CreateUpstream(
 	conf *config.Upstream,
 	tracker dimensions.DimensionTracker,
 	statsService UpstreamStatsService,
-	executor failsafe.Executor[protocol.ResponseHolder],
+	executors *resilience.MethodExecutors[protocol.ResponseHolder],
 	upstreamIndex int,
 	rateLimitBudgetRegistry *ratelimiter.RateLimitBudgetRegistry,
 	torProxyUrl string,
@@ -63,7 +64,7 @@ func CreateUpstream(
 	ctx, cancel := context.WithCancel(ctx)
 	configuredChain := chains.GetChain(conf.ChainName)
 
-	upstreamConnectorsInfo, err := createUpstreamConnectors(ctx, conf, configuredChain, tracker, statsService, executor, torProxyUrl)
+	upstreamConnectorsInfo, err := createUpstreamConnectors(ctx, conf, configuredChain, tracker, statsService, executors, torProxyUrl)
 	if err != nil {
 		cancel()
 		return nil, err
@@ -82,6 +83,7 @@ func CreateUpstream(
 		upstreamMethods:        upstreamMethods,
 		rt:                     rt,
 		autoTune:               autoTune,
+		tracker:                tracker,
 	}
 
 	return NewGenericUpstream(ctx, cancel, conf, configuredChain, upstreamIndex, creationData)
@@ -154,6 +156,8 @@ func createConnector(
 		return connectors.NewHttpConnector(connectorConfig, specs.RestIndexer, torProxyUrl, upId)
 	case specs.RestAdditional:
 		return connectors.NewHttpConnector(connectorConfig, specs.RestAdditional, torProxyUrl, upId)
+	case specs.GrpcConnector:
+		return connectors.NewGrpcConnector(connectorConfig, configuredChain, torProxyUrl, upId)
 	default:
 		panic(fmt.Sprintf("unknown connector type - %s", connectorConfig.Type))
 	}
@@ -351,7 +355,7 @@ func createUpstreamConnectors(
 	configuredChain *chains.ConfiguredChain,
 	tracker dimensions.DimensionTracker,
 	statsService UpstreamStatsService,
-	executor failsafe.Executor[protocol.ResponseHolder],
+	executors *resilience.MethodExecutors[protocol.ResponseHolder],
 	torProxyUrl string,
 ) (*connectorsInfo, error) {
 	apiConnectors := make([]connectors.ApiConnector, 0)
@@ -367,7 +371,7 @@ func createUpstreamConnectors(
 			dimensions.NewDimensionHook(tracker),
 			hook.NewStatsHook(statsService),
 		}
-		apiConnector = connectors.NewObserverConnector(configuredChain.Chain, conf.Id, apiConnector, hooks, executor)
+		apiConnector = connectors.NewObserverConnector(configuredChain.Chain, conf.Id, apiConnector, hooks, executors)
 		if connectorConfig.GetApiConnectorType() == conf.GetHeadApiConnectorType() {
 			headConnector = apiConnector
 		}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/dimensions"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/upstreams/connectors"
 	"github.com/drpcorg/nodecore/internal/upstreams/methods"
@@ -77,17 +78,30 @@ func TestDetectorLabelOverwritesManualLabel(t *testing.T) {
 }
 
 func newUpstreamWithLabelsDetection(t *testing.T, labels config.UpstreamLabels, disableLabelsDetection bool) *GenericUpstream {
+	t.Helper()
+	return newStubbedGenericUpstream(t, labels, disableLabelsDetection, nil, nil)
+}
+
+// newStubbedGenericUpstream builds a never started upstream, failsafeConfig and tracker may be nil
+func newStubbedGenericUpstream(
+	t *testing.T,
+	labels config.UpstreamLabels,
+	disableLabelsDetection bool,
+	failsafeConfig *config.FailsafeConfig,
+	tracker dimensions.DimensionTracker,
+) *GenericUpstream {
 	t.Helper()
 	require.NoError(t, specs.NewMethodSpecLoader().Load())
 
 	disabled, enabled := false, true
 	conf := &config.Upstream{
-		Id:            "u1",
-		ChainName:     "ethereum",
-		HeadConnector: "json-rpc",
-		PollInterval:  time.Second,
-		Methods:       &config.MethodsConfig{BanDuration: time.Minute},
-		Labels:        labels,
+		Id:             "u1",
+		ChainName:      "ethereum",
+		HeadConnector:  "json-rpc",
+		PollInterval:   time.Second,
+		Methods:        &config.MethodsConfig{BanDuration: time.Minute},
+		Labels:         labels,
+		FailsafeConfig: failsafeConfig,
 		Options: &chains.Options{
 			InternalTimeout:             time.Second,
 			ValidationInterval:          time.Second,
@@ -119,6 +133,7 @@ func newUpstreamWithLabelsDetection(t *testing.T, labels config.UpstreamLabels,
 			headConnector:            stub,
 		},
 		upstreamMethods: upstreamMethods,
+		tracker:         tracker,
 	})
 	require.NoError(t, err)
 	return upstream
@@ -2,7 +2,14 @@ package upstreams
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+
+	mapset "github.com/deckarep/golang-set/v2"
 
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/dimensions"
@@ -14,6 +21,12 @@ import (
 	"github.com/drpcorg/nodecore/pkg/utils"
 	"github.com/failsafe-go/failsafe-go"
 	"github.com/rs/zerolog/log"
+	"github.com/samber/lo"
+)
+
+var (
+	ErrUpstreamExists   = errors.New("upstream already exists")
+	ErrUpstreamNotFound = errors.New("upstream not found")
 )
 
 type GenericUpstreamSupervisor struct {
@@ -23,18 +36,31 @@ type GenericUpstreamSupervisor struct {
 	upstreams        *utils.CMap[string, Upstream]
 
 	eventsChan              chan protocol.UpstreamEvent
-	upstreamsConfig         *config.UpstreamConfig
-	executor                failsafe.Executor[*protocol.ResponseHolderWrapper]
+	upstreamsConfig         *utils.Atomic[*config.UpstreamConfig]
+	executors               *utils.Atomic[*flowExecutors]
 	tracker                 dimensions.DimensionTracker
 	statsService            UpstreamStatsService
 	rateLimitBudgetRegistry *ratelimiter.RateLimitBudgetRegistry
 
-	torProxyUrl            string
+	torProxyUrl string
+
+	// mu guards everything below, upstreams are started, stopped and restarted under it
+	mu                     sync.Mutex
 	upstreamIndicesCounter int
+	upstreamIndices        map[string]int
+	upstreamHandles        map[string]*upstreamHandle
 
 	subChainSupervisorManager *utils.SubscriptionManager[ChainSupervisorEvent]
 }
 
+// upstreamHandle controls the goroutine that creates an upstream and forwards its events,
+// done is closed once the upstream has been stopped and the goroutine has exited
+type upstreamHandle struct {
+	chain  chains.Chain
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
 func NewGenericUpstreamSupervisor(
 	ctx context.Context,
 	upstreamsConfig *config.UpstreamConfig,
@@ -43,16 +69,23 @@ func NewGenericUpstreamSupervisor(
 	rateLimitBudgetRegistry *ratelimiter.RateLimitBudgetRegistry,
 	torProxyUrl string,
 ) UpstreamSupervisor {
+	upstreamsConfigAtomic := utils.NewAtomic[*config.UpstreamConfig]()
+	upstreamsConfigAtomic.Store(upstreamsConfig)
+	executors := utils.NewAtomic[*flowExecutors]()
+	executors.Store(createFlowExecutors(upstreamsConfig))
+
 	return &GenericUpstreamSupervisor{
 		ctx:                       ctx,
 		upstreams:                 utils.NewCMap[string, Upstream](),
 		chainSupervisors:          utils.NewCMap[chains.Chain, ChainSupervisor](),
 		eventsChan:                make(chan protocol.UpstreamEvent, 100),
-		upstreamsConfig:           upstreamsConfig,
+		upstreamsConfig:           upstreamsConfigAtomic,
 		tracker:                   tracker,
 		statsService:              statsService,
-		executor:                  createFlowExecutor(upstreamsConfig.FailsafeConfig),
+		executors:                 executors,
 		upstreamIndicesCounter:    1,
+		upstreamIndices:           make(map[string]int),
+		upstreamHandles:           make(map[string]*upstreamHandle),
 		rateLimitBudgetRegistry:   rateLimitBudgetRegistry,
 		torProxyUrl:               torProxyUrl,
 		subChainSupervisorManager: utils.NewSubscriptionManager[ChainSupervisorEvent]("chain_supervisor_events"),
@@ -87,59 +120,279 @@ func (b *GenericUpstreamSupervisor) GetUpstream(upstreamId string) Upstream {
 	return nil
 }
 
-func (b *GenericUpstreamSupervisor) GetExecutor() failsafe.Executor[*protocol.ResponseHolderWrapper] {
-	return b.executor
+func (b *GenericUpstreamSupervisor) GetUpstreams() []Upstream {
+	result := make([]Upstream, 0)
+	b.upstreams.Range(func(_ string, val Upstream) bool {
+		result = append(result, val)
+		return true
+	})
+	slices.SortFunc(result, func(a, b Upstream) int {
+		return strings.Compare(a.GetId(), b.GetId())
+	})
+
+	return result
+}
+
+// GetExecutor returns the flow executor of the failsafe method policy that matches the method of the chain,
+// or the one of the failsafe config if there is no such policy
+func (b *GenericUpstreamSupervisor) GetExecutor(chain chains.Chain, method string) failsafe.Executor[*protocol.ResponseHolderWrapper] {
+	return b.executors.Load().get(chain, method)
 }
 
 func (b *GenericUpstreamSupervisor) StartUpstreams() {
-	log.Info().Msgf("upstreams will be started in %s mode", b.upstreamsConfig.Mode)
+	upstreamsConfig := b.upstreamsConfig.Load()
+	log.Info().Msgf("upstreams will be started in %s mode", upstreamsConfig.Mode)
 
 	go b.processEvents()
 
-	for _, upConfig := range b.upstreamsConfig.Upstreams {
-		currentIndex := b.upstreamIndicesCounter
-		if currentIndex == 1048575 { // 0xfffff, which means that the next number will be 6 bytes
-			log.Error().Msgf("upstream indices overflow, max is %d", 1048575)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, upConfig := range upstreamsConfig.Upstreams {
+		if !b.startUpstream(upConfig) {
 			break
 		}
+	}
+}
 
+// UpdateUpstreams applies a new upstream config: upstreams that are gone are stopped and removed
+// from their chain, new ones are started, and those whose config changed or that reference a changed
+// rate limit budget are restarted keeping their index. Unchanged upstreams keep running untouched.
+func (b *GenericUpstreamSupervisor) UpdateUpstreams(upstreamsConfig *config.UpstreamConfig, changedBudgets mapset.Set[string]) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	currentConfig := b.upstreamsConfig.Load()
+	currentUpstreams := lo.SliceToMap(currentConfig.Upstreams, func(item *config.Upstream) (string, *config.Upstream) {
+		return item.Id, item
+	})
+	newUpstreams := lo.SliceToMap(upstreamsConfig.Upstreams, func(item *config.Upstream) (string, *config.Upstream) {
+		return item.Id, item
+	})
+
+	for _, upConfig := range currentConfig.Upstreams {
+		if _, ok := newUpstreams[upConfig.Id]; !ok {
+			log.Info().Msgf("upstream %s has been removed from the config, it will be stopped", upConfig.Id)
+			b.stopUpstream(upConfig.Id)
+			delete(b.upstreamIndices, upConfig.Id)
+		}
+	}
+
+	if !reflect.DeepEqual(currentConfig.FailsafeConfig, upstreamsConfig.FailsafeConfig) ||
+		!reflect.DeepEqual(chainFailsafeMethodPolicies(currentConfig), chainFailsafeMethodPolicies(upstreamsConfig)) {
+		log.Info().Msg("the failsafe config has been changed, the new one will be applied to new requests")
+		b.executors.Store(createFlowExecutors(upstreamsConfig))
+	}
+	b.upstreamsConfig.Store(upstreamsConfig)
+
+	for _, upConfig := range upstreamsConfig.Upstreams {
+		currentUpConfig, ok := currentUpstreams[upConfig.Id]
+		switch {
+		case !ok:
+			log.Info().Msgf("upstream %s has been added to the config, it will be started", upConfig.Id)
+		case !reflect.DeepEqual(currentUpConfig, upConfig):
+			log.Info().Msgf("the config of upstream %s has been changed, it will be restarted", upConfig.Id)
+			b.stopUpstream(upConfig.Id)
+		case changedBudgets != nil && changedBudgets.ContainsOne(upConfig.RateLimitBudget):
+			log.Info().Msgf("rate limit budget %s of upstream %s has been changed, it will be restarted", upConfig.RateLimitBudget, upConfig.Id)
+			b.stopUpstream(upConfig.Id)
+		default:
+			continue
+		}
+		if !b.startUpstream(upConfig) {
+			break
+		}
+	}
+}
+
+// AddUpstream starts an upstream that isn't in the config file, the upstream config must already
+// have its defaults set and be validated. It lives until it's removed or the next reload of the config file.
+func (b *GenericUpstreamSupervisor) AddUpstream(upConfig *config.Upstream) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	currentConfig := b.upstreamsConfig.Load()
+	if slices.ContainsFunc(currentConfig.Upstreams, func(item *config.Upstream) bool { return item.Id == upConfig.Id }) {
+		return fmt.Errorf("%w: %s", ErrUpstreamExists, upConfig.Id)
+	}
+	if !b.startUpstream(upConfig) {
+		return fmt.Errorf("couldn't start upstream %s, no free upstream indices", upConfig.Id)
+	}
+
+	newConfig := *currentConfig
+	newConfig.Upstreams = append(slices.Clone(currentConfig.Upstreams), upConfig)
+	b.upstreamsConfig.Store(&newConfig)
+	log.Info().Msgf("upstream %s has been added", upConfig.Id)
+
+	return nil
+}
+
+// RemoveUpstream stops an upstream and removes it from its chain, an upstream from the config file
+// comes back with the next reload of the config file if it's still there.
+func (b *GenericUpstreamSupervisor) RemoveUpstream(upstreamId string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	currentConfig := b.upstreamsConfig.Load()
+	if !slices.ContainsFunc(currentConfig.Upstreams, func(item *config.Upstream) bool { return item.Id == upstreamId }) {
+		return fmt.Errorf("%w: %s", ErrUpstreamNotFound, upstreamId)
+	}
+	b.stopUpstream(upstreamId)
+	delete(b.upstreamIndices, upstreamId)
+
+	newConfig := *currentConfig
+	newConfig.Upstreams = lo.Filter(currentConfig.Upstreams, func(item *config.Upstream, _ int) bool {
+		return item.Id != upstreamId
+	})
+	b.upstreamsConfig.Store(&newConfig)
+	log.Info().Msgf("upstream %s has been removed", upstreamId)
+
+	return nil
+}
+
+// startUpstream must be called under mu, it returns false if there are no free upstream indices
+func (b *GenericUpstreamSupervisor) startUpstream(upConfig *config.Upstream) bool {
+	upstreamIndex, ok := b.upstreamIndices[upConfig.Id]
+	if !ok {
+		upstreamIndex = b.upstreamIndicesCounter
+		if upstreamIndex == 1048575 { // 0xfffff, which means that the next number will be 6 bytes
+			log.Error().Msgf("upstream indices overflow, max is %d", 1048575)
+			return false
+		}
 		b.upstreamIndicesCounter++
+		b.upstreamIndices[upConfig.Id] = upstreamIndex
+	}
+
+	upCtx, cancel := context.WithCancel(b.ctx)
+	handle := &upstreamHandle{
+		chain:  chains.GetChain(upConfig.ChainName).Chain,
+		cancel: cancel,
+		done:   make(chan struct{}),
+	}
+	b.upstreamHandles[upConfig.Id] = handle
+
+	go func() {
+		defer close(handle.done)
+
+		upstreamConnectorExecutors := createUpstreamExecutors(upConfig.FailsafeConfig)
+		up, err := CreateUpstream(upCtx, upConfig, b.tracker, b.statsService, upstreamConnectorExecutors, upstreamIndex, b.rateLimitBudgetRegistry, b.torProxyUrl)
+		if err != nil {
+			log.Error().Err(err).Msgf("couldn't create upstream %s", upConfig.Id)
+			return
+		}
+		// Subscribe and register before Start() so no early event is lost: Start()
+		// publishes InitUpstreamStateEvent - which carries the upstream's initial state,
+		// including its config-defined labels - and Publish drops events that have no
+		// subscriber. Storing the upstream first also keeps an event emitted during
+		// Start() from reaching processEvents before GetUpstream can resolve it.
+		upSub := up.Subscribe(fmt.Sprintf("upstream_supervisor_%s_updates", up.GetId()))
+		defer upSub.Unsubscribe()
+		b.upstreams.Store(up.GetId(), up)
+		defer func() {
+			// the upstream leaves its chain supervisor first, so it's no longer selected once GetUpstream can't find it
+			b.removeFromChain(up.GetId(), handle.chain)
+			b.upstreams.Delete(up.GetId())
+			up.Stop()
+		}()
+
+		up.Start()
 
-		go func(upstreamIndex int) {
-			upstreamConnectorExecutor := createUpstreamExecutor(upConfig.FailsafeConfig)
-			up, err := CreateUpstream(b.ctx, upConfig, b.tracker, b.statsService, upstreamConnectorExecutor, upstreamIndex, b.rateLimitBudgetRegistry, b.torProxyUrl)
-			if err != nil {
-				log.Error().Err(err).Msgf("couldn't create upstream %s", upConfig.Id)
+		for {
+			select {
+			case <-upCtx.Done():
 				return
-			}
-			// Subscribe and register before Start() so no early event is lost: Start()
-			// publishes InitUpstreamStateEvent - which carries the upstream's initial state,
-			// including its config-defined labels - and Publish drops events that have no
-			// subscriber. Storing the upstream first also keeps an event emitted during
-			// Start() from reaching processEvents before GetUpstream can resolve it.
-			upSub := up.Subscribe(fmt.Sprintf("upstream_supervisor_%s_updates", up.GetId()))
-			defer upSub.Unsubscribe()
-			b.upstreams.Store(up.GetId(), up)
-
-			up.Start()
-
-			for {
-				select {
-				case <-b.ctx.Done():
-					return
-				case upstreamEvent, ok := <-upSub.Events:
-					if ok {
-						b.eventsChan <- upstreamEvent
+			case upstreamEvent, ok := <-upSub.Events:
+				if ok {
+					select {
+					case b.eventsChan <- upstreamEvent:
+					case <-upCtx.Done():
+						return
 					}
 				}
 			}
-		}(currentIndex)
+		}
+	}()
+
+	return true
+}
+
+// stopUpstream must be called under mu, it waits until the upstream is removed from its chain supervisor and stopped
+func (b *GenericUpstreamSupervisor) stopUpstream(upstreamId string) {
+	handle, ok := b.upstreamHandles[upstreamId]
+	if !ok {
+		return
+	}
+	delete(b.upstreamHandles, upstreamId)
+
+	handle.cancel()
+	<-handle.done
+}
+
+// removeFromChain removes the upstream from its chain supervisor and waits until the removal is handled,
+// on shutdown there is no one to handle it, so nothing is sent
+func (b *GenericUpstreamSupervisor) removeFromChain(upstreamId string, chain chains.Chain) {
+	handled := make(chan struct{})
+	select {
+	case b.eventsChan <- protocol.UpstreamEvent{Id: upstreamId, Chain: chain, EventType: &protocol.RemoveUpstreamEvent{Handled: handled}}:
+	case <-b.ctx.Done():
+		return
+	}
+	select {
+	case <-handled:
+	case <-b.ctx.Done():
+	}
+}
+
+// flowExecutors are the flow executors of the failsafe config and its method policies,
+// chains with their own failsafe method policies have separate executors
+type flowExecutors struct {
+	defaultExecutors *resilience.MethodExecutors[*protocol.ResponseHolderWrapper]
+	chainExecutors   map[chains.Chain]*resilience.MethodExecutors[*protocol.ResponseHolderWrapper]
+}
+
+func (f *flowExecutors) get(chain chains.Chain, method string) failsafe.Executor[*protocol.ResponseHolderWrapper] {
+	if executors, ok := f.chainExecutors[chain]; ok {
+		return executors.Get(method)
+	}
+	return f.defaultExecutors.Get(method)
+}
+
+func createFlowExecutors(upstreamsConfig *config.UpstreamConfig) *flowExecutors {
+	failsafeConfig := upstreamsConfig.FailsafeConfig
+	createExecutors := func(policies []*config.MethodFailsafeConfig) *resilience.MethodExecutors[*protocol.ResponseHolderWrapper] {
+		executors := resilience.NewMethodExecutors(createFlowExecutor(failsafeConfig))
+		for _, policy := range policies {
+			executors.WithPolicy(policy, createFlowExecutor(policy.Apply(failsafeConfig)))
+		}
+		return executors
+	}
+
+	chainExecutors := make(map[chains.Chain]*resilience.MethodExecutors[*protocol.ResponseHolderWrapper])
+	for chainName, policies := range chainFailsafeMethodPolicies(upstreamsConfig) {
+		chainExecutors[chains.GetChain(chainName).Chain] = createExecutors(policies)
+	}
+	return &flowExecutors{
+		defaultExecutors: createExecutors(failsafeConfig.MethodPolicies),
+		chainExecutors:   chainExecutors,
 	}
 }
 
+// chainFailsafeMethodPolicies returns the failsafe method policies of chains that have their own ones
+func chainFailsafeMethodPolicies(upstreamsConfig *config.UpstreamConfig) map[string][]*config.MethodFailsafeConfig {
+	policies := make(map[string][]*config.MethodFailsafeConfig)
+	for chainName, chainDefaults := range upstreamsConfig.ChainDefaults {
+		if chainDefaults != nil && len(chainDefaults.FailsafeMethodPolicies) > 0 {
+			policies[chainName] = upstreamsConfig.FailsafeMethodPoliciesFor(chainName)
+		}
+	}
+	return policies
+}
+
 func createFlowExecutor(failsafeConfig *config.FailsafeConfig) failsafe.Executor[*protocol.ResponseHolderWrapper] {
 	policies := make([]failsafe.Policy[*protocol.ResponseHolderWrapper], 0)
 
+	if failsafeConfig.TimeoutConfig != nil {
+		policies = append(policies, resilience.CreateFlowTimeoutPolicy(failsafeConfig.TimeoutConfig))
+	}
 	if failsafeConfig.HedgeConfig != nil {
 		policies = append(policies, resilience.CreateFlowParallelHedgePolicy(failsafeConfig.HedgeConfig))
 	}
@@ -150,6 +403,16 @@ func createFlowExecutor(failsafeConfig *config.FailsafeConfig) failsafe.Executor
 	return resilience.CreateFlowExecutor(policies...)
 }
 
+// createUpstreamExecutors creates the upstream executors of the failsafe config and its method policies,
+// only the retry works on the upstream level
+func createUpstreamExecutors(failsafeConfig *config.FailsafeConfig) *resilience.MethodExecutors[protocol.ResponseHolder] {
+	executors := resilience.NewMethodExecutors(createUpstreamExecutor(failsafeConfig))
+	for _, policy := range failsafeConfig.MethodPolicies {
+		executors.WithPolicy(policy, createUpstreamExecutor(policy.Apply(failsafeConfig)))
+	}
+	return executors
+}
+
 func createUpstreamExecutor(failsafeConfig *config.FailsafeConfig) failsafe.Executor[protocol.ResponseHolder] {
 	policies := make([]failsafe.Policy[protocol.ResponseHolder], 0)
 
@@ -168,7 +431,7 @@ func (b *GenericUpstreamSupervisor) processEvents() {
 		case event, ok := <-b.eventsChan:
 			if ok {
 				chainSupervisor, exists := b.chainSupervisors.LoadOrStoreLazy(event.Chain, func() ChainSupervisor {
-					return NewGenericChainSupervisor(b.ctx, event.Chain, choice.NewHeightForkChoice(), b.tracker, b.upstreamsConfig.ValidateLagFor(event.Chain.String()), b.GetUpstream)
+					return NewGenericChainSupervisor(b.ctx, event.Chain, choice.NewHeightForkChoice(), b.tracker, b.upstreamsConfig.Load().ValidateLagFor(event.Chain.String()), b.GetUpstream)
 				})
 
 				if !exists {
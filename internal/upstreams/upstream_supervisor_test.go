@@ -0,0 +1,82 @@
+package upstreams_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/drpcorg/nodecore/internal/config"
+	"github.com/drpcorg/nodecore/internal/protocol"
+	"github.com/drpcorg/nodecore/internal/upstreams"
+	"github.com/drpcorg/nodecore/pkg/chains"
+	"github.com/failsafe-go/failsafe-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestUpstreamSupervisor(upstreamIds ...string) upstreams.UpstreamSupervisor {
+	upstreamsConfig := &config.UpstreamConfig{FailsafeConfig: &config.FailsafeConfig{}}
+	for _, id := range upstreamIds {
+		upstreamsConfig.Upstreams = append(upstreamsConfig.Upstreams, &config.Upstream{Id: id, ChainName: "ethereum"})
+	}
+	return upstreams.NewGenericUpstreamSupervisor(context.Background(), upstreamsConfig, nil, nil, nil, "")
+}
+
+func TestUpstreamSupervisorAddExistingUpstreamThenError(t *testing.T) {
+	supervisor := newTestUpstreamSupervisor("id")
+
+	err := supervisor.AddUpstream(&config.Upstream{Id: "id", ChainName: "ethereum"})
+
+	assert.ErrorIs(t, err, upstreams.ErrUpstreamExists)
+}
+
+func TestUpstreamSupervisorRemoveUnknownUpstreamThenError(t *testing.T) {
+	supervisor := newTestUpstreamSupervisor("id")
+
+	err := supervisor.RemoveUpstream("unknown")
+
+	assert.ErrorIs(t, err, upstreams.ErrUpstreamNotFound)
+}
+
+func TestUpstreamSupervisorRemoveUpstreamTwiceThenError(t *testing.T) {
+	supervisor := newTestUpstreamSupervisor("id")
+
+	err := supervisor.RemoveUpstream("id")
+	assert.NoError(t, err)
+	err = supervisor.RemoveUpstream("id")
+	assert.ErrorIs(t, err, upstreams.ErrUpstreamNotFound)
+}
+
+func TestUpstreamSupervisorGetExecutorByMethodPolicies(t *testing.T) {
+	upstreamsConfig := &config.UpstreamConfig{
+		FailsafeConfig: &config.FailsafeConfig{
+			RetryConfig: &config.RetryConfig{Attempts: 3},
+			MethodPolicies: []*config.MethodFailsafeConfig{
+				{Methods: []string{"eth_send*"}, DisableRetry: true},
+			},
+		},
+		ChainDefaults: map[string]*config.ChainDefaults{
+			"polygon": {
+				FailsafeMethodPolicies: []*config.MethodFailsafeConfig{
+					{Methods: []string{"eth_call"}, RetryConfig: &config.RetryConfig{Attempts: 2}},
+				},
+			},
+		},
+	}
+	supervisor := upstreams.NewGenericUpstreamSupervisor(context.Background(), upstreamsConfig, nil, nil, nil, "")
+
+	assert.Equal(t, 3, countExecutorAttempts(supervisor.GetExecutor(chains.ETHEREUM, "eth_call")))
+	assert.Equal(t, 1, countExecutorAttempts(supervisor.GetExecutor(chains.ETHEREUM, "eth_sendRawTransaction")))
+	assert.Equal(t, 2, countExecutorAttempts(supervisor.GetExecutor(chains.POLYGON, "eth_call")))
+	assert.Equal(t, 1, countExecutorAttempts(supervisor.GetExecutor(chains.POLYGON, "eth_sendRawTransaction")))
+	assert.Equal(t, 3, countExecutorAttempts(supervisor.GetExecutor(chains.POLYGON, "eth_getBalance")))
+}
+
+func countExecutorAttempts(executor failsafe.Executor[*protocol.ResponseHolderWrapper]) int {
+	attempts := 0
+	_, _ = executor.Get(func() (*protocol.ResponseHolderWrapper, error) {
+		attempts++
+		return &protocol.ResponseHolderWrapper{
+			Response: protocol.NewReplyError("1", protocol.ServerError(), protocol.JsonRpc, protocol.PartialFailure),
+		}, nil
+	})
+	return attempts
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/breaker"
 	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/upstreams"
@@ -319,6 +320,32 @@ func TestGenericUpstreamProcessStateEvents_AppliesCaps(t *testing.T) {
 	assertUpstreamStateMatches(t, expectedState, upstream.GetUpstreamState())
 }
 
+func TestGenericUpstreamProcessStateEvents_PublishesCircuitStates(t *testing.T) {
+	upstream, emit, sub := newTestGenericUpstream(t, nil, nil, nil)
+
+	t.Cleanup(upstream.Stop)
+
+	startUpstream(t, upstream, sub)
+
+	emit(&protocol.CircuitBreakerUpstreamStateEvent{Method: breaker.AnyMethod, State: breaker.Open})
+
+	event := nextUpstreamEvent(t, sub)
+	stateEvent, ok := event.EventType.(*protocol.StateUpstreamEvent)
+	require.True(t, ok)
+	assert.Equal(t, map[string]breaker.State{breaker.AnyMethod: breaker.Open}, stateEvent.State.CircuitStates)
+
+	emit(&protocol.CircuitBreakerUpstreamStateEvent{Method: breaker.AnyMethod, State: breaker.Open})
+	assertNoUpstreamEvent(t, sub)
+
+	emit(&protocol.CircuitBreakerUpstreamStateEvent{Method: breaker.AnyMethod, State: breaker.Closed})
+
+	event = nextUpstreamEvent(t, sub)
+	stateEvent, ok = event.EventType.(*protocol.StateUpstreamEvent)
+	require.True(t, ok)
+	assert.Empty(t, stateEvent.State.CircuitStates)
+	assert.Empty(t, upstream.GetUpstreamState().CircuitStates)
+}
+
 func TestGenericUpstreamProcessStateEvents_IgnoresDuplicateCaps(t *testing.T) {
 	upstream, emit, sub := newTestGenericUpstream(t, nil, nil, nil)
 
@@ -578,6 +605,58 @@ func TestGenericUpstreamBanMethod_IgnoresEnabledMethod(t *testing.T) {
 	assert.True(t, upstream.GetUpstreamState().UpstreamMethods.HasMethod("eth_call"))
 }
 
+func TestGenericUpstreamUnbanMethod_UnbansBeforeBanDuration(t *testing.T) {
+	upConfig := newUpstreamConfig(&config.MethodsConfig{BanDuration: time.Minute})
+	upstream, _, sub := newTestGenericUpstream(t, upConfig, nil, nil)
+
+	t.Cleanup(upstream.Stop)
+
+	startUpstream(t, upstream, sub)
+
+	upstream.BanMethod("eth_call")
+
+	event := nextUpstreamEvent(t, sub)
+	stateEvent, ok := event.EventType.(*protocol.StateUpstreamEvent)
+	require.True(t, ok)
+	assert.ElementsMatch(t, []string{"eth_call"}, stateEvent.State.BannedMethods.ToSlice())
+	assert.False(t, stateEvent.State.UpstreamMethods.HasMethod("eth_call"))
+
+	upstream.UnbanMethod("eth_call")
+
+	event = nextUpstreamEvent(t, sub)
+	stateEvent, ok = event.EventType.(*protocol.StateUpstreamEvent)
+	require.True(t, ok)
+	assert.Empty(t, stateEvent.State.BannedMethods.ToSlice())
+	assert.True(t, stateEvent.State.UpstreamMethods.HasMethod("eth_call"))
+}
+
+func TestGenericUpstreamSetMaintenance_RemovesAndRestoresUpstream(t *testing.T) {
+	upstream, emit, sub := newTestGenericUpstream(t, nil, nil, nil)
+
+	t.Cleanup(upstream.Stop)
+
+	startUpstream(t, upstream, sub)
+
+	upstream.SetMaintenance(true)
+
+	event := nextUpstreamEvent(t, sub)
+	assert.IsType(t, &protocol.RemoveUpstreamEvent{}, event.EventType)
+	assert.True(t, upstream.InMaintenance())
+
+	// the state is kept up to date but isn't published while the upstream is in maintenance
+	emit(&protocol.StatusUpstreamStateEvent{Status: protocol.Unavailable})
+	assertNoUpstreamEvent(t, sub)
+	assert.Equal(t, protocol.Unavailable, upstream.GetUpstreamState().Status)
+
+	upstream.SetMaintenance(false)
+
+	event = nextUpstreamEvent(t, sub)
+	validEvent, ok := event.EventType.(*protocol.ValidUpstreamEvent)
+	require.True(t, ok)
+	assert.Equal(t, protocol.Unavailable, validEvent.State.Status)
+	assert.False(t, upstream.InMaintenance())
+}
+
 func TestGenericUpstreamGetConnector_ReturnsMatchingConnector(t *testing.T) {
 	httpConnector := mocks.NewConnectorMockWithType(specs.JsonRpcConnector)
 	wsConnector := mocks.NewConnectorMockWithType(specs.WebsocketConnector)
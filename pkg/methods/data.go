@@ -47,7 +47,11 @@ type MethodData struct {
 	Group     string          `json:"group"`
 	Settings  *MethodSettings `json:"settings"`
 	TagParser *TagParser      `json:"tag-parser"`
-	Enabled   *bool           `json:"enabled"`
+	// ResponseTagParser extracts the block of a response, it's used for requests that don't refer to a block by its number
+	ResponseTagParser *TagParser `json:"response-tag-parser"`
+	Enabled           *bool      `json:"enabled"`
+	// Params are the types of the positional params, they are used to normalize the params before hashing
+	Params []*ParamData `json:"params"`
 }
 
 type MethodSettings struct {
@@ -94,6 +98,26 @@ type TagParser struct {
 	Path       string           `json:"path"`
 }
 
+type ParamType string
+
+const (
+	AnyParamType         ParamType = "any"         // left as is
+	QuantityParamType    ParamType = "quantity"    // hex number
+	BlockNumberParamType ParamType = "blockNumber" // hex number or tag (latest, earliest, etc)
+	BlockRefParamType    ParamType = "blockRef"    // hash, hex number, tag or an EIP-1898 object
+	AddressParamType     ParamType = "address"     // 20 bytes hex
+	HashParamType        ParamType = "hash"        // 32 bytes hex
+	ObjectParamType      ParamType = "object"      // object with typed fields
+)
+
+type ParamData struct {
+	Type ParamType `json:"type"`
+	// Default is the value of an omitted or null param
+	Default any `json:"default"`
+	// Fields are the types of the object fields, the other fields are left as is
+	Fields map[string]ParamType `json:"fields"`
+}
+
 func (m *MethodData) setDefaults() {
 	if m.Group == "" {
 		m.Group = "common"
@@ -142,11 +166,21 @@ func (m *MethodData) validate() error {
 			return err
 		}
 	}
+	if m.ResponseTagParser != nil {
+		if err := m.ResponseTagParser.validateResponseParser(); err != nil {
+			return err
+		}
+	}
 	if m.Settings != nil {
 		if err := m.Settings.validate(); err != nil {
 			return err
 		}
 	}
+	for i, param := range m.Params {
+		if err := param.validate(); err != nil {
+			return fmt.Errorf("param %d - %s", i, err.Error())
+		}
+	}
 
 	return nil
 }
@@ -194,6 +228,43 @@ func (p *TagParser) validate() error {
 	return nil
 }
 
+func (p *TagParser) validateResponseParser() error {
+	if p.Path == "" {
+		return errors.New("empty response-tag-parser path")
+	}
+	if p.ReturnType != BlockNumberType {
+		return fmt.Errorf("wrong return type of response-tag-parser - %s, expected - %s", p.ReturnType, BlockNumberType)
+	}
+	return nil
+}
+
+func (p *ParamData) validate() error {
+	if err := p.Type.validate(); err != nil {
+		return err
+	}
+	if len(p.Fields) > 0 && p.Type != ObjectParamType {
+		return fmt.Errorf("fields can be set only for the %s param type", ObjectParamType)
+	}
+	for field, fieldType := range p.Fields {
+		if fieldType == ObjectParamType {
+			return fmt.Errorf("nested objects are not supported, field - %s", field)
+		}
+		if err := fieldType.validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p ParamType) validate() error {
+	switch p {
+	case AnyParamType, QuantityParamType, BlockNumberParamType, BlockRefParamType, AddressParamType, HashParamType, ObjectParamType:
+	default:
+		return fmt.Errorf("wrong param type - %s", p)
+	}
+	return nil
+}
+
 func (p ParserReturnType) validate() error {
 	switch p {
 	case BlockRefType, BlockNumberType, StringType, ObjectType, BlockRangeType:
@@ -24,8 +24,10 @@ type Method struct {
 	sticky            *Sticky
 	apiConnectorTypes []ApiConnectorType
 
-	parser       *jqParser
-	modifyParser *modifyJqParser
+	parser         *jqParser
+	responseParser *jqParser
+	modifyParser   *modifyJqParser
+	normalizer     *paramsNormalizer
 
 	enabled          bool
 	cacheable        bool
@@ -59,11 +61,22 @@ func DefaultMethodWithConnectorTypes(name string, apiConnectorTypes []ApiConnect
 }
 
 func MethodWithSettings(name string, apiConnectorTypes []ApiConnectorType, settings *MethodSettings, tagParser *TagParser) *Method {
+	return MethodWithResponseTagParser(name, apiConnectorTypes, settings, tagParser, nil)
+}
+
+func MethodWithResponseTagParser(
+	name string,
+	apiConnectorTypes []ApiConnectorType,
+	settings *MethodSettings,
+	tagParser *TagParser,
+	responseTagParser *TagParser,
+) *Method {
 	methodData := &MethodData{
-		Name:      name,
-		Enabled:   new(true),
-		Settings:  settings,
-		TagParser: tagParser,
+		Name:              name,
+		Enabled:           new(true),
+		Settings:          settings,
+		TagParser:         tagParser,
+		ResponseTagParser: responseTagParser,
 	}
 
 	method, err := fromMethodData(methodData, apiConnectorTypes)
@@ -142,6 +155,17 @@ func fromMethodData(methodData *MethodData, apiConnectorTypes []ApiConnectorType
 			query:      jqQuery,
 		}
 	}
+	var responseParser *jqParser
+	if methodData.ResponseTagParser != nil {
+		jqQuery, err := gojq.Parse(methodData.ResponseTagParser.Path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse a response jq path of method %s - %s", methodData.Name, err.Error())
+		}
+		responseParser = &jqParser{
+			returnType: methodData.ResponseTagParser.ReturnType,
+			query:      jqQuery,
+		}
+	}
 
 	var sub *Subscription
 	var sticky *Sticky
@@ -188,7 +212,9 @@ func fromMethodData(methodData *MethodData, apiConnectorTypes []ApiConnectorType
 		Name:              methodData.Name,
 		Group:             methodData.Group,
 		parser:            parser,
+		responseParser:    responseParser,
 		modifyParser:      modifyParser,
+		normalizer:        newParamsNormalizer(methodData.Params, methodData.TagParser),
 		sticky:            sticky,
 		Subscription:      sub,
 		apiConnectorTypes: apiConnectorTypes,
@@ -330,6 +356,27 @@ func (m *Method) Parse(ctx context.Context, data any) MethodParam {
 	return nil
 }
 
+func (m *Method) HasResponseParser() bool {
+	return m.responseParser != nil
+}
+
+// ParseResponse extracts the block of a decoded response result, nil is returned
+// if the result has no block, e.g. it's null or a pending transaction
+func (m *Method) ParseResponse(ctx context.Context, data any) MethodParam {
+	if m.responseParser == nil {
+		return nil
+	}
+	methodParam, err := m.jqParse(m.responseParser.query.Run(data))
+	if err != nil {
+		zerolog.Ctx(ctx).Debug().Err(err).Msgf("couldn't parse the response tag of method %s", m.Name)
+		return nil
+	}
+	if param, ok := methodParam.(string); ok {
+		return parseTag(ctx, m.Name, m.responseParser.returnType, param)
+	}
+	return nil
+}
+
 func (m *Method) jqParse(iter gojq.Iter) (any, error) {
 	for {
 		param, ok := iter.Next()
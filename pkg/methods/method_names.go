@@ -5,4 +5,9 @@ const (
 	EthGetBlockByNumber = "eth_getBlockByNumber"
 	EthChainId          = "eth_chainId"
 	NetVersion          = "net_version"
+	EthNewFilter        = "eth_newFilter"
+	EthNewBlockFilter   = "eth_newBlockFilter"
+	EthGetFilterChanges = "eth_getFilterChanges"
+	EthGetFilterLogs    = "eth_getFilterLogs"
+	EthUninstallFilter  = "eth_uninstallFilter"
 )
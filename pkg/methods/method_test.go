@@ -88,6 +88,25 @@ func TestParseBlockNumberObject(t *testing.T) {
 	assert.Equal(t, rpc.BlockNumber(2), result.(*specs.BlockNumberParam).BlockNumber)
 }
 
+func TestParseResponse(t *testing.T) {
+	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("test_specs/parsers")).Load()
+	assert.NoError(t, err)
+
+	spec := specs.GetSpecMethodsByConnectors("test", nil)
+	method := spec["trace"]["receipt"]
+
+	assert.True(t, method.HasResponseParser())
+	assert.False(t, spec[specs.DefaultMethodGroup]["call"].HasResponseParser())
+
+	result := method.ParseResponse(context.Background(), map[string]any{"blockNumber": "0x64", "status": "0x1"})
+	assert.IsType(t, &specs.BlockNumberParam{}, result)
+	assert.Equal(t, rpc.BlockNumber(100), result.(*specs.BlockNumberParam).BlockNumber)
+
+	assert.Nil(t, method.ParseResponse(context.Background(), nil))
+	assert.Nil(t, method.ParseResponse(context.Background(), map[string]any{"blockNumber": nil}))
+	assert.Nil(t, method.ParseResponse(context.Background(), []any{"0x64"}))
+}
+
 func TestParseBlockRef(t *testing.T) {
 	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("test_specs/parsers")).Load()
 	assert.NoError(t, err)
@@ -97,6 +97,18 @@ func TestLoadSpecWrongParserReturnTypeThenError(t *testing.T) {
 	assert.ErrorContains(t, err, "couldn't read method specs: error during method 'test' of 'spec1.json' validation, cause: wrong return type of tag-parser - wrong")
 }
 
+func TestLoadSpecWrongResponseParserReturnTypeThenError(t *testing.T) {
+	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("test_specs/wrong_response_parser_return_type")).Load()
+
+	assert.ErrorContains(t, err, "couldn't read method specs: error during method 'test' of 'spec1.json' validation, cause: wrong return type of response-tag-parser - blockRef, expected - blockNumber")
+}
+
+func TestLoadSpecWrongParamTypeThenError(t *testing.T) {
+	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("test_specs/wrong_param_type")).Load()
+
+	assert.ErrorContains(t, err, "couldn't read method specs: error during method 'test' of 'spec1.json' validation, cause: param 1 - fields can be set only for the object param type")
+}
+
 func TestLoadSpecExistedMethodThenError(t *testing.T) {
 	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("test_specs/existed_method")).Load()
 
@@ -0,0 +1,168 @@
+package specs
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+// tagParserIndexPath is a tag parser path that refers to a positional param, e.g. .[1]
+var tagParserIndexPath = regexp.MustCompile(`^\.\[(\d+)]$`)
+
+// paramsDecoder keeps numbers as they are written, a float64 would map distinct integers above 2^53 to the same param
+var paramsDecoder = sonic.Config{UseNumber: true}.Froze()
+
+// paramsNormalizer brings params of semantically identical requests to the same form
+type paramsNormalizer struct {
+	params []*ParamData
+}
+
+func newParamsNormalizer(params []*ParamData, tagParser *TagParser) *paramsNormalizer {
+	normalized := append([]*ParamData(nil), params...)
+
+	// a block param referred by the tag parser is normalized even if the param types are not declared
+	if tagParser != nil && (tagParser.ReturnType == BlockNumberType || tagParser.ReturnType == BlockRefType) {
+		if match := tagParserIndexPath.FindStringSubmatch(tagParser.Path); match != nil {
+			index, err := strconv.Atoi(match[1])
+			if err == nil && (index >= len(normalized) || normalized[index] == nil) {
+				for len(normalized) <= index {
+					normalized = append(normalized, nil)
+				}
+				normalized[index] = &ParamData{Type: ParamType(tagParser.ReturnType)}
+			}
+		}
+	}
+
+	if len(normalized) == 0 {
+		return nil
+	}
+	return &paramsNormalizer{params: normalized}
+}
+
+// CanonicalParams returns the params in a canonical form to be hashed, the same value for semantically identical params:
+// hex quantities without leading zeros, lowercase addresses and hashes, defaults of omitted params and sorted object keys.
+// The params are returned as is if the method has no param types or they can't be parsed
+func (m *Method) CanonicalParams(params []byte) []byte {
+	if m.normalizer == nil {
+		return params
+	}
+	var parsed any
+	if len(params) == 0 {
+		parsed = []any{}
+	} else if err := paramsDecoder.Unmarshal(params, &parsed); err != nil {
+		return params
+	}
+	positional, ok := parsed.([]any)
+	if !ok {
+		return params
+	}
+
+	// the std config sorts object keys
+	canonical, err := sonic.ConfigStd.Marshal(m.normalizer.normalize(positional))
+	if err != nil {
+		return params
+	}
+	return canonical
+}
+
+func (n *paramsNormalizer) normalize(params []any) []any {
+	for i, param := range n.params {
+		if param == nil {
+			continue
+		}
+		if i >= len(params) {
+			if param.Default == nil {
+				break
+			}
+			params = append(params, param.Default)
+			continue
+		}
+		if params[i] == nil && param.Default != nil {
+			params[i] = param.Default
+			continue
+		}
+		params[i] = normalizeParam(param.Type, param.Fields, params[i])
+	}
+	return params
+}
+
+func normalizeParam(paramType ParamType, fields map[string]ParamType, value any) any {
+	switch param := value.(type) {
+	case []any:
+		// e.g. addresses or topics of a log filter
+		if paramType == ObjectParamType {
+			return param
+		}
+		for i, item := range param {
+			param[i] = normalizeParam(paramType, fields, item)
+		}
+		return param
+	case map[string]any:
+		switch paramType {
+		case ObjectParamType:
+			for field, fieldType := range fields {
+				if fieldValue, ok := param[field]; ok {
+					param[field] = normalizeParam(fieldType, nil, fieldValue)
+				}
+			}
+		case BlockRefParamType:
+			if blockHash, ok := param["blockHash"]; ok {
+				param["blockHash"] = normalizeParam(HashParamType, nil, blockHash)
+			}
+			if blockNumber, ok := param["blockNumber"]; ok {
+				param["blockNumber"] = normalizeParam(QuantityParamType, nil, blockNumber)
+			}
+		}
+		return param
+	case string:
+		switch paramType {
+		case QuantityParamType, BlockNumberParamType:
+			return normalizeQuantity(param)
+		case BlockRefParamType:
+			if isHexOfLength(param, 64) {
+				return strings.ToLower(param)
+			}
+			return normalizeQuantity(param)
+		case AddressParamType:
+			if isHexOfLength(param, 40) {
+				return strings.ToLower(param)
+			}
+		case HashParamType:
+			if isHexOfLength(param, 64) {
+				return strings.ToLower(param)
+			}
+		}
+		return param
+	}
+	return value
+}
+
+// normalizeQuantity removes leading zeros of a hex number, any other value is left as is
+func normalizeQuantity(value string) string {
+	if !isHex(value) {
+		return value
+	}
+	digits := strings.TrimLeft(strings.ToLower(value[2:]), "0")
+	if digits == "" {
+		return "0x0"
+	}
+	return "0x" + digits
+}
+
+func isHexOfLength(value string, length int) bool {
+	return len(value) == length+2 && isHex(value)
+}
+
+func isHex(value string) bool {
+	if len(value) < 3 || value[0] != '0' || (value[1] != 'x' && value[1] != 'X') {
+		return false
+	}
+	for _, c := range value[2:] {
+		if !('0' <= c && c <= '9' || 'a' <= c && c <= 'f' || 'A' <= c && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,141 @@
+package specs_test
+
+import (
+	"os"
+	"testing"
+
+	specs "github.com/drpcorg/nodecore/pkg/methods"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanonicalParamsOfEthMethods(t *testing.T) {
+	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("specs")).Load()
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		method   string
+		params   string
+		expected string
+	}{
+		{
+			name:     "hex quantity and omitted default",
+			method:   "eth_getBlockByNumber",
+			params:   `["0x010"]`,
+			expected: `["0x10",false]`,
+		},
+		{
+			name:     "block tag",
+			method:   "eth_getBlockByNumber",
+			params:   `["latest", true]`,
+			expected: `["latest",true]`,
+		},
+		{
+			name:     "zero quantity",
+			method:   "eth_getBlockByNumber",
+			params:   `["0x000", false]`,
+			expected: `["0x0",false]`,
+		},
+		{
+			name:     "address case and omitted block",
+			method:   "eth_getBalance",
+			params:   `["0xAbCdEf0123456789aBcDeF0123456789ABCDEF01"]`,
+			expected: `["0xabcdef0123456789abcdef0123456789abcdef01","latest"]`,
+		},
+		{
+			name:     "null block",
+			method:   "eth_getCode",
+			params:   `["0xabcdef0123456789abcdef0123456789abcdef01", null]`,
+			expected: `["0xabcdef0123456789abcdef0123456789abcdef01","latest"]`,
+		},
+		{
+			name:     "call object key order",
+			method:   "eth_call",
+			params:   `[{"to": "0xABCDEF0123456789ABCDEF0123456789ABCDEF01", "data": "0xAB", "value": "0x00ff"}, "0x0a"]`,
+			expected: `[{"data":"0xAB","to":"0xabcdef0123456789abcdef0123456789abcdef01","value":"0xff"},"0xa"]`,
+		},
+		{
+			name:     "block hash",
+			method:   "eth_call",
+			params:   `[{}, "0xABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789"]`,
+			expected: `[{},"0xabcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789"]`,
+		},
+		{
+			name:     "eip-1898 block",
+			method:   "eth_call",
+			params:   `[{}, {"requireCanonical": true, "blockNumber": "0x0010"}]`,
+			expected: `[{},{"blockNumber":"0x10","requireCanonical":true}]`,
+		},
+		{
+			name:     "log filter",
+			method:   "eth_getLogs",
+			params:   `[{"topics": [["0xABCDEF0123456789ABCDEF0123456789ABCDEF0123456789ABCDEF0123456789"], null], "fromBlock": "0x01", "address": ["0xABCDEF0123456789ABCDEF0123456789ABCDEF01"]}]`,
+			expected: `[{"address":["0xabcdef0123456789abcdef0123456789abcdef01"],"fromBlock":"0x1","topics":[["0xabcdef0123456789abcdef0123456789abcdef0123456789abcdef0123456789"],null]}]`,
+		},
+		{
+			name:     "block of a tag parser",
+			method:   "trace_block",
+			params:   `["0x0001"]`,
+			expected: `["0x1"]`,
+		},
+		{
+			name:     "not hex values",
+			method:   "eth_getBalance",
+			params:   `["0xnot-an-address", "0x"]`,
+			expected: `["0xnot-an-address","0x"]`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(te *testing.T) {
+			method := specs.GetSpecMethod("eth", test.method)
+			require.NotNil(te, method)
+
+			assert.Equal(te, test.expected, string(method.CanonicalParams([]byte(test.params))))
+		})
+	}
+}
+
+func TestCanonicalParamsWithoutParamTypesThenSameParams(t *testing.T) {
+	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("specs")).Load()
+	require.NoError(t, err)
+
+	for _, method := range []*specs.Method{specs.DefaultMethod("eth_test"), specs.GetSpecMethod("eth", "eth_chainId")} {
+		params := []byte(`[ "0x010", {"b": 1, "a": 2} ]`)
+
+		assert.Equal(t, params, method.CanonicalParams(params))
+	}
+}
+
+func TestCanonicalParamsNotArrayThenSameParams(t *testing.T) {
+	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("specs")).Load()
+	require.NoError(t, err)
+
+	method := specs.GetSpecMethod("eth", "eth_getBlockByNumber")
+	for _, params := range []string{`{"block": "0x010"}`, `["0x010"`} {
+		assert.Equal(t, params, string(method.CanonicalParams([]byte(params))))
+	}
+}
+
+func TestCanonicalParamsEmptyParams(t *testing.T) {
+	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("specs")).Load()
+	require.NoError(t, err)
+
+	method := specs.GetSpecMethod("eth", "eth_getBlockByNumber")
+
+	assert.Equal(t, `[]`, string(method.CanonicalParams(nil)))
+	assert.Equal(t, `[]`, string(method.CanonicalParams([]byte(`[ ]`))))
+}
+
+func TestCanonicalParamsBigIntegersThenKeptAsIs(t *testing.T) {
+	err := specs.NewMethodSpecLoaderWithFs(os.DirFS("specs")).Load()
+	require.NoError(t, err)
+
+	method := specs.GetSpecMethod("eth", "eth_getBlockByNumber")
+	first := method.CanonicalParams([]byte(`["0x010", false, 9007199254740993, {"b": 18446744073709551615}]`))
+	second := method.CanonicalParams([]byte(`["0x010", false, 9007199254740992, {"b": 18446744073709551614}]`))
+
+	assert.Equal(t, `["0x10",false,9007199254740993,{"b":18446744073709551615}]`, string(first))
+	assert.Equal(t, `["0x10",false,9007199254740992,{"b":18446744073709551614}]`, string(second))
+}
@@ -2,10 +2,12 @@ package e2e
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
 	"github.com/drpcorg/nodecore/internal/caches"
+	"github.com/drpcorg/nodecore/pkg/chains"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -56,3 +58,62 @@ func TestConnectorStoreAndRemoveExpired(t *testing.T, connector caches.CacheConn
 	assert.Nil(t, value)
 	assert.ErrorIs(t, err, caches.ErrCacheNotFound)
 }
+
+func TestConnectorStoreAtHeightThenRemoveHeights(t *testing.T, connector caches.CacheConnector) {
+	err := connector.Initialize()
+	assert.Nil(t, err)
+
+	for height := uint64(100); height <= 103; height++ {
+		err = connector.StoreAtHeight(context.Background(), fmt.Sprintf("height-key-%d", height), "my-item", 5*time.Second, chains.ETHEREUM, height)
+		assert.Nil(t, err)
+	}
+	err = connector.StoreAtHeight(context.Background(), "other-chain-height-key", "my-item", 5*time.Second, chains.POLYGON, 101)
+	assert.Nil(t, err)
+
+	err = connector.RemoveHeights(context.Background(), chains.ETHEREUM, 101, 102)
+	assert.Nil(t, err)
+
+	for _, key := range []string{"height-key-101", "height-key-102"} {
+		value, err := connector.Receive(context.Background(), key)
+		assert.Nil(t, value)
+		assert.ErrorIs(t, err, caches.ErrCacheNotFound)
+	}
+	for _, key := range []string{"height-key-100", "height-key-103", "other-chain-height-key"} {
+		value, err := connector.Receive(context.Background(), key)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("my-item"), value)
+	}
+}
+
+func TestConnectorScanThenRemove(t *testing.T, connector caches.CacheConnector) {
+	err := connector.Initialize()
+	assert.Nil(t, err)
+
+	// the underscores of the prefix are not wildcards
+	for _, key := range []string{"scan_polygon_eth_call_1", "scan_polygon_eth_call_2", "scan_polygonXeth_callX3", "scan_polygon_eth_chainId_4"} {
+		err = connector.Store(context.Background(), key, "my-item-"+key, 5*time.Second)
+		assert.Nil(t, err)
+	}
+
+	scanned := make(map[string]int)
+	err = connector.Scan(context.Background(), "scan_polygon_eth_call_", func(key string, size int) error {
+		scanned[key] = size
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"scan_polygon_eth_call_1": 31, "scan_polygon_eth_call_2": 31}, scanned)
+
+	err = connector.Remove(context.Background(), "scan_polygon_eth_call_1", "scan_polygonXeth_callX3", "unknown-key")
+	assert.Nil(t, err)
+
+	for _, key := range []string{"scan_polygon_eth_call_1", "scan_polygonXeth_callX3"} {
+		value, err := connector.Receive(context.Background(), key)
+		assert.Nil(t, value)
+		assert.ErrorIs(t, err, caches.ErrCacheNotFound)
+	}
+	for _, key := range []string{"scan_polygon_eth_call_2", "scan_polygon_eth_chainId_4"} {
+		value, err := connector.Receive(context.Background(), key)
+		assert.Nil(t, err)
+		assert.Equal(t, []byte("my-item-"+key), value)
+	}
+}
@@ -30,9 +30,13 @@ func (c *CacheProcessorMock) Store(ctx context.Context, chain chains.Chain, requ
 	c.Called(ctx, chain, request, response)
 }
 
-func (c *CacheProcessorMock) Receive(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) ([]byte, bool) {
+func (c *CacheProcessorMock) StoreError(ctx context.Context, chain chains.Chain, request protocol.RequestHolder, responseError []byte) {
+	c.Called(ctx, chain, request, responseError)
+}
+
+func (c *CacheProcessorMock) Receive(ctx context.Context, chain chains.Chain, request protocol.RequestHolder) (*protocol.CachedResponse, bool) {
 	args := c.Called(ctx, chain, request)
-	return args.Get(0).([]byte), args.Get(1).(bool)
+	return args.Get(0).(*protocol.CachedResponse), args.Get(1).(bool)
 }
 
 func NewCacheProcessorMock() *CacheProcessorMock {
@@ -69,6 +73,26 @@ func (c *CacheConnectorMock) Store(ctx context.Context, key string, object strin
 	return args.Error(0)
 }
 
+func (c *CacheConnectorMock) StoreAtHeight(ctx context.Context, key string, object string, ttl time.Duration, chain chains.Chain, height uint64) error {
+	args := c.Called(ctx, key, object, ttl, chain, height)
+	return args.Error(0)
+}
+
+func (c *CacheConnectorMock) RemoveHeights(ctx context.Context, chain chains.Chain, fromHeight, toHeight uint64) error {
+	args := c.Called(ctx, chain, fromHeight, toHeight)
+	return args.Error(0)
+}
+
+func (c *CacheConnectorMock) Scan(ctx context.Context, prefix string, fn func(key string, size int) error) error {
+	args := c.Called(ctx, prefix, fn)
+	return args.Error(0)
+}
+
+func (c *CacheConnectorMock) Remove(ctx context.Context, keys ...string) error {
+	args := c.Called(ctx, keys)
+	return args.Error(0)
+}
+
 func (c *CacheConnectorMock) Receive(ctx context.Context, key string) ([]byte, error) {
 	args := c.Called(ctx, key)
 	return args.Get(0).([]byte), args.Error(1)
@@ -79,6 +103,9 @@ func (c *CacheConnectorMock) Initialize() error {
 	return args.Error(0)
 }
 
+func (c *CacheConnectorMock) Close() {
+}
+
 type DelayedConnector struct {
 	sleep time.Duration
 	CacheConnectorMock
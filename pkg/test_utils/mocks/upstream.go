@@ -4,6 +4,7 @@ import (
 	"context"
 
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/drpcorg/nodecore/internal/config"
 	"github.com/drpcorg/nodecore/internal/protocol"
 	"github.com/drpcorg/nodecore/internal/upstreams"
 	"github.com/drpcorg/nodecore/pkg/chains"
@@ -81,16 +82,41 @@ func (u *UpstreamSupervisorMock) GetChainSupervisor(chain chains.Chain) upstream
 
 func (u *UpstreamSupervisorMock) GetUpstream(id string) upstreams.Upstream {
 	args := u.Called(id)
+	if args.Get(0) == nil {
+		return nil
+	}
 
-	return args.Get(0).(*upstreams.GenericUpstream)
+	return args.Get(0).(upstreams.Upstream)
 }
 
-func (u *UpstreamSupervisorMock) GetExecutor() failsafe.Executor[*protocol.ResponseHolderWrapper] {
+func (u *UpstreamSupervisorMock) GetUpstreams() []upstreams.Upstream {
 	args := u.Called()
 
+	return args.Get(0).([]upstreams.Upstream)
+}
+
+func (u *UpstreamSupervisorMock) GetExecutor(chain chains.Chain, method string) failsafe.Executor[*protocol.ResponseHolderWrapper] {
+	args := u.Called(chain, method)
+
 	return args.Get(0).(failsafe.Executor[*protocol.ResponseHolderWrapper])
 }
 
 func (u *UpstreamSupervisorMock) StartUpstreams() {
 	u.Called()
 }
+
+func (u *UpstreamSupervisorMock) UpdateUpstreams(upstreamsConfig *config.UpstreamConfig, changedBudgets mapset.Set[string]) {
+	u.Called(upstreamsConfig, changedBudgets)
+}
+
+func (u *UpstreamSupervisorMock) AddUpstream(upConfig *config.Upstream) error {
+	args := u.Called(upConfig)
+
+	return args.Error(0)
+}
+
+func (u *UpstreamSupervisorMock) RemoveUpstream(upstreamId string) error {
+	args := u.Called(upstreamId)
+
+	return args.Error(0)
+}
@@ -0,0 +1,36 @@
+package test_utils
+
+import (
+	"testing"
+
+	"github.com/drpcorg/nodecore/internal/tracing"
+	"github.com/samber/lo"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// EnableTracing records all the spans of the test, tracing is disabled again once the test is over
+func EnableTracing(t *testing.T, propagateToUpstreams bool) *tracetest.SpanRecorder {
+	recorder := tracetest.NewSpanRecorder()
+	tracing.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)), propagateToUpstreams)
+	t.Cleanup(func() {
+		tracing.SetTracerProvider(nil, false)
+	})
+	return recorder
+}
+
+func EndedSpans(recorder *tracetest.SpanRecorder, name string) []sdktrace.ReadOnlySpan {
+	return lo.Filter(recorder.Ended(), func(span sdktrace.ReadOnlySpan, _ int) bool {
+		return span.Name() == name
+	})
+}
+
+func SpanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, attr := range span.Attributes() {
+		if attr.Key == key {
+			return attr.Value
+		}
+	}
+	return attribute.Value{}
+}
@@ -14,7 +14,8 @@ import (
 type contextKey string
 
 const (
-	ipKey contextKey = "ip"
+	ipKey       contextKey = "ip"
+	clientIpKey contextKey = "client-ip"
 
 	localhostIP = "127.0.0.1"
 )
@@ -60,22 +61,33 @@ func ParseTrustedProxies(entries []string) ([]netip.Prefix, error) {
 // Without trusted proxies (the default) the legacy behavior is kept for
 // backwards compatibility: every X-Forwarded-For entry is treated as a candidate
 // client IP, falling back to the direct peer when the header is absent. Note
-// that in this mode a directly connected client can present an arbitrary IP.
+// that in this mode a directly connected client can present an arbitrary IP,
+// see ClientIpFromContext for the IP that can't be spoofed.
 func ContextWithIps(ctx context.Context, request *http.Request, trustedProxies []netip.Prefix) context.Context {
 	ipValues := mapset.NewThreadUnsafeSet[string]()
+	client := clientIP(request, trustedProxies)
 	if len(trustedProxies) == 0 {
 		for _, ip := range forwardedForIPs(request) {
 			ipValues.Add(ip)
 		}
 		if ipValues.IsEmpty() {
-			ipValues.Add(remoteIP(request.RemoteAddr))
+			ipValues.Add(client)
 		}
 	} else {
-		ipValues.Add(clientIP(request, trustedProxies))
+		ipValues.Add(client)
 	}
+	ctx = context.WithValue(ctx, clientIpKey, client)
 	return context.WithValue(ctx, ipKey, ipValues)
 }
 
+// ClientIpFromContext returns the single ip the client can't spoof: the direct peer, or the ip
+// resolved from X-Forwarded-For if the peer is a trusted proxy. Unlike IpsFromContext it never
+// takes X-Forwarded-For of an untrusted peer, so it's the one to count the requests of a client by
+func ClientIpFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIpKey).(string)
+	return ip
+}
+
 func clientIP(request *http.Request, trustedProxies []netip.Prefix) string {
 	peer := remoteIP(request.RemoteAddr)
 	peerAddr, err := netip.ParseAddr(peer)